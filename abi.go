@@ -42,7 +42,7 @@ func newMapABIFromFd(fd *internal.FD) (string, *MapABI, error) {
 		return "", nil, err
 	}
 
-	return "", &MapABI{
+	return internal.CString(info.mapName[:]), &MapABI{
 		MapType(info.mapType),
 		info.keySize,
 		info.valueSize,
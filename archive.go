@@ -0,0 +1,460 @@
+package ebpf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/btf"
+
+	"golang.org/x/xerrors"
+)
+
+// archiveVersion is incremented on incompatible changes to the format.
+const archiveVersion = 1
+
+// archive is the JSON representation of a CollectionSpec.
+//
+// Binary data such as instructions, map contents and BTF is encoded in
+// ByteOrder, which is always the native byte order of the writer.
+type archive struct {
+	Version   int
+	ByteOrder string
+	// BTF contains the raw BTF shared by maps and programs.
+	BTF      [][]byte                   `json:",omitempty"`
+	Maps     map[string]*archiveMap     `json:",omitempty"`
+	Programs map[string]*archiveProgram `json:",omitempty"`
+}
+
+type archiveMap struct {
+	Name       string
+	Type       MapType
+	KeySize    uint32
+	ValueSize  uint32
+	MaxEntries uint32
+	Flags      uint32
+	Contents   []archiveKV `json:",omitempty"`
+	Freeze     bool        `json:",omitempty"`
+	InnerMap   *archiveMap `json:",omitempty"`
+	BTF        *archiveMapBTF
+	Ifindex    uint32 `json:",omitempty"`
+}
+
+type archiveMapBTF struct {
+	Spec       int
+	Key, Value btf.TypeID
+}
+
+// archiveKV is an entry of MapSpec.Contents.
+type archiveKV struct {
+	Key   []byte
+	Value []byte `json:",omitempty"`
+	// Program is the name of the program referred to by a DevMapValue or
+	// CPUMapValue. Value is omitted in this case, and the first field of
+	// the value is stored in Redirect.
+	Program  string `json:",omitempty"`
+	Redirect uint32 `json:",omitempty"`
+}
+
+type archiveProgram struct {
+	Name          string
+	Type          ProgramType
+	AttachType    AttachType
+	Instructions  []byte
+	Symbols       map[int]string          `json:",omitempty"`
+	References    map[int]string          `json:",omitempty"`
+	Relocations   map[int]*asm.Relocation `json:",omitempty"`
+	License       string
+	KernelVersion uint32 `json:",omitempty"`
+	AttachTo      string `json:",omitempty"`
+	Flags         uint32 `json:",omitempty"`
+	BTF           *archiveProgramBTF
+	Ifindex       uint32 `json:",omitempty"`
+}
+
+type archiveProgramBTF struct {
+	Spec int
+	// Infos contains the function and line infos.
+	Infos []byte
+}
+
+func byteOrderName(bo binary.ByteOrder) string {
+	if bo == binary.BigEndian {
+		return "big"
+	}
+	return "little"
+}
+
+// WriteArchive writes the spec to w in a portable format, which can be
+// read by LoadCollectionSpecFromArchive.
+//
+// The archive contains instructions including their symbols, references
+// and unresolved relocations, maps including their contents, and BTF. It
+// can be loaded without the ELF the spec was read from, on machines with
+// the same byte order. Keys and values of MapSpec.Contents are stored
+// as raw bytes, and are loaded as []byte.
+//
+// Specs referring to loaded objects can't be archived, for example a
+// ProgramSpec with an AttachTarget.
+func (cs *CollectionSpec) WriteArchive(w io.Writer) error {
+	aw := archiveWriter{
+		archive: archive{
+			Version:   archiveVersion,
+			ByteOrder: byteOrderName(internal.NativeEndian),
+			Maps:      make(map[string]*archiveMap, len(cs.Maps)),
+			Programs:  make(map[string]*archiveProgram, len(cs.Programs)),
+		},
+		specs: make(map[*btf.Spec]int),
+	}
+
+	for name, spec := range cs.Maps {
+		am, err := aw.writeMap(spec)
+		if err != nil {
+			return xerrors.Errorf("map %s: %w", name, err)
+		}
+		aw.archive.Maps[name] = am
+	}
+
+	for name, spec := range cs.Programs {
+		ap, err := aw.writeProgram(spec)
+		if err != nil {
+			return xerrors.Errorf("program %s: %w", name, err)
+		}
+		aw.archive.Programs[name] = ap
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(&aw.archive); err != nil {
+		return xerrors.Errorf("can't encode archive: %w", err)
+	}
+	return nil
+}
+
+type archiveWriter struct {
+	archive archive
+	specs   map[*btf.Spec]int
+}
+
+// writeSpec returns the index of the raw BTF of spec in the archive.
+func (aw *archiveWriter) writeSpec(spec *btf.Spec) (int, error) {
+	if i, ok := aw.specs[spec]; ok {
+		return i, nil
+	}
+
+	raw, err := btf.MarshalSpec(spec, internal.NativeEndian)
+	if err != nil {
+		return 0, err
+	}
+
+	i := len(aw.archive.BTF)
+	aw.archive.BTF = append(aw.archive.BTF, raw)
+	aw.specs[spec] = i
+	return i, nil
+}
+
+func (aw *archiveWriter) writeMap(spec *MapSpec) (*archiveMap, error) {
+	am := &archiveMap{
+		Name:       spec.Name,
+		Type:       spec.Type,
+		KeySize:    spec.KeySize,
+		ValueSize:  spec.ValueSize,
+		MaxEntries: spec.MaxEntries,
+		Flags:      spec.Flags,
+		Freeze:     spec.Freeze,
+		Ifindex:    spec.Ifindex,
+	}
+
+	for _, kv := range spec.Contents {
+		key, err := marshalBytes(kv.Key, int(spec.KeySize))
+		if err != nil {
+			return nil, xerrors.Errorf("key %v: %w", kv.Key, err)
+		}
+
+		akv := archiveKV{Key: key}
+		switch v := kv.Value.(type) {
+		case DevMapValue:
+			if v.Program == nil && v.ProgramName != "" {
+				akv.Program, akv.Redirect = v.ProgramName, v.Ifindex
+			}
+
+		case CPUMapValue:
+			if v.Program == nil && v.ProgramName != "" {
+				akv.Program, akv.Redirect = v.ProgramName, v.QueueSize
+			}
+		}
+
+		if akv.Program == "" {
+			akv.Value, err = marshalBytes(kv.Value, int(spec.ValueSize))
+			if err != nil {
+				return nil, xerrors.Errorf("value of key %v: %w", kv.Key, err)
+			}
+		}
+
+		am.Contents = append(am.Contents, akv)
+	}
+
+	if spec.InnerMap != nil {
+		inner, err := aw.writeMap(spec.InnerMap)
+		if err != nil {
+			return nil, xerrors.Errorf("inner map: %w", err)
+		}
+		am.InnerMap = inner
+	}
+
+	if spec.BTF != nil {
+		i, err := aw.writeSpec(btf.MapSpec(spec.BTF))
+		if err != nil {
+			return nil, xerrors.Errorf("BTF: %w", err)
+		}
+
+		am.BTF = &archiveMapBTF{
+			Spec:  i,
+			Key:   btf.MapKey(spec.BTF).ID(),
+			Value: btf.MapValue(spec.BTF).ID(),
+		}
+	}
+
+	return am, nil
+}
+
+func (aw *archiveWriter) writeProgram(spec *ProgramSpec) (*archiveProgram, error) {
+	if spec.AttachTarget != nil {
+		return nil, xerrors.New("can't archive AttachTarget")
+	}
+
+	// Instructions are encoded one by one, since Instructions.Marshal
+	// resolves references.
+	var insns bytes.Buffer
+	for i, ins := range spec.Instructions {
+		if _, err := ins.Marshal(&insns, internal.NativeEndian); err != nil {
+			return nil, xerrors.Errorf("instruction %d: %w", i, err)
+		}
+	}
+
+	ap := &archiveProgram{
+		Name:          spec.Name,
+		Type:          spec.Type,
+		AttachType:    spec.AttachType,
+		Instructions:  insns.Bytes(),
+		Symbols:       make(map[int]string),
+		References:    make(map[int]string),
+		Relocations:   make(map[int]*asm.Relocation),
+		License:       spec.License,
+		KernelVersion: spec.KernelVersion,
+		AttachTo:      spec.AttachTo,
+		Flags:         spec.Flags,
+		Ifindex:       spec.Ifindex,
+	}
+
+	for i, ins := range spec.Instructions {
+		if ins.Symbol != "" {
+			ap.Symbols[i] = ins.Symbol
+		}
+		if ins.Reference != "" {
+			ap.References[i] = ins.Reference
+		}
+		if rel := ins.Relocation(); rel != nil {
+			ap.Relocations[i] = rel
+		}
+	}
+
+	if spec.BTF != nil {
+		i, err := aw.writeSpec(btf.ProgramSpec(spec.BTF))
+		if err != nil {
+			return nil, xerrors.Errorf("BTF: %w", err)
+		}
+
+		infos, err := btf.MarshalProgram(spec.BTF, internal.NativeEndian)
+		if err != nil {
+			return nil, xerrors.Errorf("BTF: %w", err)
+		}
+
+		ap.BTF = &archiveProgramBTF{i, infos}
+	}
+
+	return ap, nil
+}
+
+// LoadCollectionSpecFromArchive reads a spec written by
+// CollectionSpec.WriteArchive.
+//
+// It is safe to use with untrusted input.
+func LoadCollectionSpecFromArchive(r io.Reader) (*CollectionSpec, error) {
+	var a archive
+	if err := json.NewDecoder(r).Decode(&a); err != nil {
+		return nil, xerrors.Errorf("can't decode archive: %w", err)
+	}
+
+	if a.Version != archiveVersion {
+		return nil, xerrors.Errorf("unsupported archive version %d", a.Version)
+	}
+
+	if a.ByteOrder != byteOrderName(internal.NativeEndian) {
+		return nil, xerrors.Errorf("archive has %s endian byte order: %w", a.ByteOrder, internal.ErrNotSupported)
+	}
+
+	specs := make([]*btf.Spec, 0, len(a.BTF))
+	for i, raw := range a.BTF {
+		spec, err := btf.LoadRawSpec(raw, internal.NativeEndian)
+		if err != nil {
+			return nil, xerrors.Errorf("BTF %d: %w", i, err)
+		}
+		specs = append(specs, spec)
+	}
+
+	cs := &CollectionSpec{
+		Maps:     make(map[string]*MapSpec, len(a.Maps)),
+		Programs: make(map[string]*ProgramSpec, len(a.Programs)),
+	}
+
+	for name, am := range a.Maps {
+		spec, err := readArchiveMap(am, specs, 0)
+		if err != nil {
+			return nil, xerrors.Errorf("map %s: %w", name, err)
+		}
+		cs.Maps[name] = spec
+	}
+
+	for name, ap := range a.Programs {
+		spec, err := readArchiveProgram(ap, specs)
+		if err != nil {
+			return nil, xerrors.Errorf("program %s: %w", name, err)
+		}
+		cs.Programs[name] = spec
+	}
+
+	return cs, nil
+}
+
+func archiveSpec(specs []*btf.Spec, i int) (*btf.Spec, error) {
+	if i < 0 || i >= len(specs) {
+		return nil, xerrors.Errorf("invalid BTF index %d", i)
+	}
+	return specs[i], nil
+}
+
+func readArchiveMap(am *archiveMap, specs []*btf.Spec, depth int) (*MapSpec, error) {
+	if am == nil {
+		return nil, xerrors.New("missing map")
+	}
+
+	spec := &MapSpec{
+		Name:       am.Name,
+		Type:       am.Type,
+		KeySize:    am.KeySize,
+		ValueSize:  am.ValueSize,
+		MaxEntries: am.MaxEntries,
+		Flags:      am.Flags,
+		Freeze:     am.Freeze,
+		Ifindex:    am.Ifindex,
+	}
+
+	for _, akv := range am.Contents {
+		kv := MapKV{Key: akv.Key, Value: akv.Value}
+		if akv.Program != "" {
+			switch spec.Type {
+			case DevMap, DevMapHash:
+				kv.Value = DevMapValue{Ifindex: akv.Redirect, ProgramName: akv.Program}
+			case CPUMap:
+				kv.Value = CPUMapValue{QueueSize: akv.Redirect, ProgramName: akv.Program}
+			default:
+				return nil, xerrors.Errorf("%s can't refer to program %s", spec.Type, akv.Program)
+			}
+		}
+		spec.Contents = append(spec.Contents, kv)
+	}
+
+	if am.InnerMap != nil {
+		// Maps of maps can't be nested.
+		if depth > 0 {
+			return nil, xerrors.New("inner map has an inner map")
+		}
+
+		inner, err := readArchiveMap(am.InnerMap, specs, depth+1)
+		if err != nil {
+			return nil, xerrors.Errorf("inner map: %w", err)
+		}
+		spec.InnerMap = inner
+	}
+
+	if am.BTF != nil {
+		btfSpec, err := archiveSpec(specs, am.BTF.Spec)
+		if err != nil {
+			return nil, err
+		}
+
+		spec.BTF, err = btf.MapFromTypeIDs(btfSpec, am.BTF.Key, am.BTF.Value)
+		if err != nil {
+			return nil, xerrors.Errorf("BTF: %w", err)
+		}
+	}
+
+	return spec, nil
+}
+
+func readArchiveProgram(ap *archiveProgram, specs []*btf.Spec) (*ProgramSpec, error) {
+	if ap == nil {
+		return nil, xerrors.New("missing program")
+	}
+
+	var insns asm.Instructions
+	if err := insns.Unmarshal(bytes.NewReader(ap.Instructions), internal.NativeEndian); err != nil {
+		return nil, xerrors.Errorf("instructions: %w", err)
+	}
+
+	for _, indices := range []map[int]string{ap.Symbols, ap.References} {
+		for i := range indices {
+			if i < 0 || i >= len(insns) {
+				return nil, xerrors.Errorf("invalid instruction index %d", i)
+			}
+		}
+	}
+
+	for i, name := range ap.Symbols {
+		insns[i].Symbol = name
+	}
+
+	for i, name := range ap.References {
+		insns[i].Reference = name
+	}
+
+	for i, rel := range ap.Relocations {
+		if i < 0 || i >= len(insns) || rel == nil {
+			return nil, xerrors.Errorf("invalid relocation for instruction %d", i)
+		}
+		insns[i] = insns[i].WithRelocation(rel)
+	}
+
+	spec := &ProgramSpec{
+		Name:          ap.Name,
+		Type:          ap.Type,
+		AttachType:    ap.AttachType,
+		Instructions:  insns,
+		License:       ap.License,
+		KernelVersion: ap.KernelVersion,
+		AttachTo:      ap.AttachTo,
+		Flags:         ap.Flags,
+		Ifindex:       ap.Ifindex,
+	}
+
+	if ap.BTF != nil {
+		btfSpec, err := archiveSpec(specs, ap.BTF.Spec)
+		if err != nil {
+			return nil, err
+		}
+
+		spec.BTF, err = btf.UnmarshalProgram(btfSpec, ap.BTF.Infos, internal.NativeEndian)
+		if err != nil {
+			return nil, xerrors.Errorf("BTF: %w", err)
+		}
+
+		if err := assignSourceLines(spec.Instructions, spec.BTF); err != nil {
+			return nil, xerrors.Errorf("BTF: %w", err)
+		}
+	}
+
+	return spec, nil
+}
@@ -0,0 +1,123 @@
+package ebpf
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/btf"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestCollectionSpecArchive(t *testing.T) {
+	files, err := filepath.Glob("testdata/loader-*.elf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			spec, err := LoadCollectionSpec(file)
+			if err != nil {
+				t.Fatal("Can't parse ELF:", err)
+			}
+			spec.Maps["array_of_hash_map"].InnerMap = spec.Maps["hash_map"]
+			spec.Maps["hash_of_hash_map"].InnerMap = spec.Maps["hash_map2"]
+
+			var buf bytes.Buffer
+			if err := spec.WriteArchive(&buf); err != nil {
+				t.Fatal("Can't write archive:", err)
+			}
+
+			have, err := LoadCollectionSpecFromArchive(&buf)
+			if err != nil {
+				t.Fatal("Can't load archive:", err)
+			}
+
+			for name, want := range spec.Maps {
+				checkMapSpec(t, have.Maps, name, want)
+
+				m := have.Maps[name]
+				if m == nil {
+					continue
+				}
+
+				if len(m.Contents) != len(want.Contents) {
+					t.Errorf("%s: expected %d entries, got %d", name, len(want.Contents), len(m.Contents))
+				} else {
+					for i, kv := range want.Contents {
+						key, _ := marshalBytes(kv.Key, int(want.KeySize))
+						value, _ := marshalBytes(kv.Value, int(want.ValueSize))
+						if !bytes.Equal(m.Contents[i].Key.([]byte), key) || !bytes.Equal(m.Contents[i].Value.([]byte), value) {
+							t.Errorf("%s: entry %d doesn't match", name, i)
+						}
+					}
+				}
+
+				if (m.BTF == nil) != (want.BTF == nil) {
+					t.Errorf("%s: BTF isn't preserved", name)
+				} else if m.BTF != nil && btf.MapValue(m.BTF).ID() != btf.MapValue(want.BTF).ID() {
+					t.Errorf("%s: value type isn't preserved", name)
+				}
+			}
+
+			for name, want := range spec.Programs {
+				prog := have.Programs[name]
+				if prog == nil {
+					t.Errorf("Missing program %s", name)
+					continue
+				}
+
+				if prog.Type != want.Type || prog.License != want.License || prog.AttachTo != want.AttachTo {
+					t.Errorf("%s: program attributes don't match", name)
+				}
+
+				insns := prog.Instructions
+				for _, diff := range asm.Diff(want.Instructions, insns) {
+					t.Errorf("%s: %s", name, diff)
+				}
+
+				for i := range want.Instructions {
+					if !reflect.DeepEqual(insns[i].Relocation(), want.Instructions[i].Relocation()) {
+						t.Errorf("%s: relocation of instruction %d isn't preserved", name, i)
+					}
+				}
+
+				if want.BTF != nil {
+					line, ok := insns[0].Source().(*btf.Line)
+					if !ok || !strings.HasSuffix(line.FileName(), "loader.c") {
+						t.Errorf("%s: first instruction has no source line", name)
+					}
+				}
+			}
+
+			coll, err := NewCollection(have)
+			testutils.SkipIfNotSupported(t, err)
+			if err != nil {
+				t.Fatal(err)
+			}
+			coll.Close()
+		})
+	}
+}
+
+func TestLoadCollectionSpecFromArchiveInvalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"{}",
+		`{"Version":1,"ByteOrder":"middle"}`,
+		`{"Version":1,"ByteOrder":"` + byteOrderName(internal.NativeEndian) + `","BTF":["AAAA"]}`,
+		`{"Version":1,"ByteOrder":"` + byteOrderName(internal.NativeEndian) + `","Maps":{"a":{"BTF":{"Spec":0}}}}`,
+		`{"Version":1,"ByteOrder":"` + byteOrderName(internal.NativeEndian) + `","Programs":{"a":{"Instructions":"AAAA"}}}`,
+		`{"Version":1,"ByteOrder":"` + byteOrderName(internal.NativeEndian) + `","Programs":{"a":{"Symbols":{"1":"foo"}}}}`,
+	} {
+		if _, err := LoadCollectionSpecFromArchive(strings.NewReader(input)); err == nil {
+			t.Errorf("Accepted invalid input %q", input)
+		}
+	}
+}
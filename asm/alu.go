@@ -147,3 +147,13 @@ func (op ALUOp) Imm32(dst Register, value int32) Instruction {
 		Constant: int64(value),
 	}
 }
+
+// Negate emits `dst = -dst`.
+func Negate(dst Register) Instruction {
+	return Neg.Imm(dst, 0)
+}
+
+// Negate32 emits `dst = -dst`, zeroing the upper 32 bit of dst.
+func Negate32(dst Register) Instruction {
+	return Neg.Imm32(dst, 0)
+}
@@ -0,0 +1,145 @@
+package asm
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+// BlindConstants returns a copy of insns in which immediate values are
+// XORed with random numbers, similar to the constant blinding done by
+// the kernel when net.core.bpf_jit_harden is enabled.
+//
+// This prevents attacker controlled values from appearing verbatim in
+// JITed code, which makes JIT spraying harder.
+//
+// Moves and 64 bit loads of constants are always blinded. ALU operations,
+// conditional jumps and stores of immediates need a scratch register, and
+// are only blinded if one of R6 to R9 isn't used by insns. Loads of maps
+// and other relocated values are never blinded.
+//
+// Random numbers are read from rnd, or from crypto/rand if rnd is nil.
+func (insns Instructions) BlindConstants(rnd io.Reader) (Instructions, error) {
+	l, err := newLayout(insns)
+	if err != nil {
+		return nil, err
+	}
+
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+
+	scratch, haveScratch := insns.unusedCalleeSaved()
+
+	replacements := make([]Instructions, len(insns))
+	for i, ins := range insns {
+		var r int32
+		if err := binary.Read(rnd, binary.LittleEndian, &r); err != nil {
+			return nil, xerrors.Errorf("can't read random number: %w", err)
+		}
+
+		replacements[i] = ins.blind(r, scratch, haveScratch)
+	}
+
+	return l.rewrite(replacements)
+}
+
+// unusedCalleeSaved returns a register which is preserved across calls
+// and isn't used by insns.
+func (insns Instructions) unusedCalleeSaved() (Register, bool) {
+	var used regSet
+	for _, ins := range insns {
+		used |= ins.reads() | ins.writes()
+	}
+
+	for _, r := range []Register{R6, R7, R8, R9} {
+		if !used.has(r) {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+// blind returns the instructions replacing ins, with r as the random
+// number to XOR the immediate with.
+//
+// The first instruction inherits the symbol of ins, so jumps to ins
+// land on the start of the replacement. If ins is a jump, the last
+// instruction is the jump.
+func (ins Instruction) blind(r int32, scratch Register, haveScratch bool) Instructions {
+	if ins.Constant == 0 {
+		return Instructions{ins}
+	}
+
+	op := ins.OpCode
+	var (
+		cls   = op.Class()
+		isALU = (cls == ALUClass || cls == ALU64Class) && op.Source() == ImmSource &&
+			op.ALUOp() != Neg && op.ALUOp() != Swap
+		isJump  = isBranch(ins) && op.Source() == ImmSource && ins.jumpOp() != Ja
+		isStore = cls == StClass && op.Mode() == MemMode
+	)
+
+	var blinded Instructions
+	switch {
+	case op.isDWordLoad():
+		if ins.Src != 0 || ins.Reference != "" || ins.Relocation() != nil {
+			return Instructions{ins}
+		}
+
+		// The 32 bit immediate of the XOR is sign extended.
+		load := ins
+		load.Constant ^= int64(r)
+		blinded = Instructions{
+			load,
+			Xor.Imm(ins.Dst, r),
+		}
+
+	case isALU && op.ALUOp() == Mov:
+		mov := ins
+		mov.Constant = int64(int32(ins.Constant) ^ r)
+		xor := Instruction{
+			OpCode:   op.SetALUOp(Xor),
+			Dst:      ins.Dst,
+			Constant: int64(r),
+		}
+		blinded = Instructions{mov, xor}
+
+	case !haveScratch:
+		return Instructions{ins}
+
+	case isALU, isJump:
+		use := ins
+		use.OpCode = op.SetSource(RegSource)
+		use.Src = scratch
+		use.Constant = 0
+		blinded = append(ins.loadBlinded(r, scratch), use)
+
+	case isStore:
+		store := ins
+		store.OpCode = StoreMemOp(op.Size())
+		store.Src = scratch
+		store.Constant = 0
+		blinded = append(ins.loadBlinded(r, scratch), store)
+
+	default:
+		return Instructions{ins}
+	}
+
+	for i := range blinded {
+		blinded[i].Symbol = ""
+		blinded[i].Metadata = ins.Metadata
+	}
+	blinded[0].Symbol = ins.Symbol
+	return blinded
+}
+
+// loadBlinded assigns the blinded immediate of ins to dst.
+func (ins Instruction) loadBlinded(r int32, dst Register) Instructions {
+	return Instructions{
+		Mov.Imm(dst, int32(ins.Constant)^r),
+		Xor.Imm(dst, r),
+	}
+}
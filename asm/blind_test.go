@@ -0,0 +1,80 @@
+package asm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBlindConstants(t *testing.T) {
+	insns := Instructions{
+		Mov.Imm(R0, 0x1234).Sym("main"),
+		{OpCode: JEq.Op(ImmSource), Dst: R1, Offset: 2, Constant: 0x5678},
+		Add.Imm(R0, 0x1111),
+		StoreImm(RFP, -8, 0x2222, DWord),
+		LoadImm(R2, 0x3333, DWord),
+		Return(),
+	}
+
+	rnd := bytes.NewReader(bytes.Repeat([]byte{0xaa}, 4*len(insns)))
+	blinded, err := insns.BlindConstants(rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(blinded)
+
+	if blinded[0].Symbol != "main" {
+		t.Error("Symbol wasn't preserved")
+	}
+
+	for i, ins := range blinded {
+		switch ins.Constant {
+		case 0x1234, 0x5678, 0x1111, 0x2222, 0x3333:
+			t.Errorf("Instruction %d contains an unblinded constant: %v", i, ins)
+		}
+	}
+
+	// mov, xor; mov, xor, jeq; mov, xor, add; mov, xor, stx; lddw, xor; exit
+	if n := len(blinded); n != 14 {
+		t.Fatalf("Expected 14 instructions, got %d", n)
+	}
+
+	jump := blinded[4]
+	if jump.OpCode != JEq.Op(RegSource) || jump.Src != R6 {
+		t.Fatal("Expected jump to use scratch register, got", jump)
+	}
+
+	// The jump skips the blinded add and store.
+	if jump.Offset != 6 {
+		t.Error("Expected jump offset 6, got", jump.Offset)
+	}
+
+	if err := blinded.Validate(); err != nil {
+		t.Error("Blinded instructions are invalid:", err)
+	}
+}
+
+func TestBlindConstantsWithoutScratch(t *testing.T) {
+	insns := Instructions{
+		Mov.Imm(R6, 0),
+		Mov.Imm(R7, 0),
+		Mov.Imm(R8, 0),
+		Mov.Imm(R9, 0),
+		Add.Imm(R6, 0x1111),
+		Mov.Imm(R0, 0x1234),
+		Return(),
+	}
+
+	blinded, err := insns.BlindConstants(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blinded[4].Constant != 0x1111 {
+		t.Error("Add shouldn't be blinded without a scratch register")
+	}
+
+	if blinded[5].Constant == 0x1234 {
+		t.Error("Move should be blinded without a scratch register")
+	}
+}
@@ -0,0 +1,220 @@
+package asm
+
+import (
+	"math"
+	"sort"
+)
+
+// BreakpointEventSize is the size of the header of a breakpoint event.
+//
+// The header consists of the 32 bit program ID passed to
+// InstrumentBreakpoints and the 32 bit index of the instruction, in
+// native endianness. It is followed by the 64 bit value of each
+// register of the breakpoint.
+const BreakpointEventSize = 8
+
+// Breakpoint reports the values of registers before an instruction
+// executes.
+type Breakpoint struct {
+	// Index of the instruction.
+	Index int
+	// Registers to report, out of R0 to R9.
+	Registers []Register
+}
+
+// InstrumentBreakpoints returns a copy of insns which writes an event to
+// a ring buffer each time a breakpoint is reached.
+//
+// The ring buffer is the map referenced by symbol, which must be a
+// RingBuf. Events are dropped if it is full.
+//
+// The event is assembled below the stack used by the function containing
+// the breakpoint, and submitted using bpf_ringbuf_output. Registers R0
+// to R5 which are live at the breakpoint are saved on the stack around
+// the call. Registers which may be uninitialized at the breakpoint are
+// reported as zero, since the verifier rejects reading them.
+//
+// Returns a *ValidationError if the instrumented program exceeds the
+// stack limit.
+func (insns Instructions) InstrumentBreakpoints(symbol string, id uint32, bps []Breakpoint) (Instructions, error) {
+	l, err := newLayout(insns)
+	if err != nil {
+		return nil, err
+	}
+
+	bps = append([]Breakpoint(nil), bps...)
+	sort.SliceStable(bps, func(i, j int) bool {
+		return bps[i].Index < bps[j].Index
+	})
+
+	for i, bp := range bps {
+		if bp.Index < 0 || bp.Index >= len(insns) {
+			return nil, &ValidationError{Index: bp.Index, Reason: "breakpoint is outside of the program"}
+		}
+		if i > 0 && bps[i-1].Index == bp.Index {
+			return nil, l.errorf(bp.Index, "duplicate breakpoint")
+		}
+		for _, r := range bp.Registers {
+			if r > R9 {
+				return nil, l.errorf(bp.Index, "can't report register %s", r)
+			}
+		}
+	}
+
+	liveOut, err := l.liveness()
+	if err != nil {
+		return nil, err
+	}
+
+	initRegs, err := l.initializedBefore(liveOut)
+	if err != nil {
+		return nil, err
+	}
+
+	depths, err := l.frameDepths()
+	if err != nil {
+		return nil, err
+	}
+
+	replacements := make([]Instructions, len(insns))
+	for i, ins := range insns {
+		replacements[i] = Instructions{ins}
+	}
+
+	for _, bp := range bps {
+		i := bp.Index
+		ins := insns[i]
+		live := ins.liveIn(liveOut[i])
+
+		var saved []Register
+		for r := R0; r <= R5; r++ {
+			if live.has(r) && initRegs[i].has(r) {
+				saved = append(saved, r)
+			}
+		}
+
+		// Stack slots are addressed relative to the frame pointer, below
+		// the stack used by the function.
+		spill := -align8(depths[i]) - 8*len(saved)
+		size := BreakpointEventSize + 8*len(bp.Registers)
+		event := spill - size
+		if -event > MaxStackDepth {
+			return nil, l.errorf(i, "breakpoint needs %d bytes of stack, exceeding limit of %d", -event, MaxStackDepth)
+		}
+
+		var code Instructions
+		for n, r := range saved {
+			code = append(code, StoreMem(RFP, int16(spill+8*n), r, DWord))
+		}
+
+		code = append(code,
+			StoreImm(RFP, int16(event), int64(id), Word),
+			StoreImm(RFP, int16(event+4), int64(i), Word),
+		)
+		for n, r := range bp.Registers {
+			off := int16(event + BreakpointEventSize + 8*n)
+			if initRegs[i].has(r) {
+				code = append(code, StoreMem(RFP, off, r, DWord))
+			} else {
+				code = append(code, StoreImm(RFP, off, 0, DWord))
+			}
+		}
+
+		// The fd is filled in when the map is loaded, like for other
+		// references to maps.
+		load := Instruction{
+			OpCode:    LoadImmOp(DWord),
+			Dst:       R1,
+			Src:       PseudoMapFD,
+			Constant:  math.MaxUint32,
+			Reference: symbol,
+		}
+
+		code = append(code,
+			load,
+			Mov.Reg(R2, RFP),
+			Add.Imm(R2, int32(event)),
+			Mov.Imm(R3, int32(size)),
+			Mov.Imm(R4, 0),
+			FnRingbufOutput.Call(),
+		)
+
+		for n, r := range saved {
+			code = append(code, LoadMem(r, RFP, int16(spill+8*n), DWord))
+		}
+
+		code[0].Symbol = ins.Symbol
+		ins.Symbol = ""
+		for j := range code {
+			code[j].Metadata = ins.Metadata
+		}
+		replacements[i] = append(code, ins)
+	}
+
+	out, err := l.rewrite(replacements)
+	if err != nil {
+		return nil, err
+	}
+
+	// Breakpoints increase the stack usage of all frames along a call
+	// chain.
+	if _, err := out.StackUsage(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// initializedBefore returns the registers which are initialized before
+// each instruction on all paths leading to it.
+//
+// Unlike Validate, the arguments of a bpf-to-bpf call are assumed to be
+// the registers which are live at the start of the callee.
+func (l *layout) initializedBefore(liveOut []regSet) ([]regSet, error) {
+	result := make([]regSet, len(l.insns))
+	for _, entry := range l.functions() {
+		initialized := regs(R1, RFP)
+		if entry != 0 {
+			args := regs(R1, R2, R3, R4, R5)
+			initialized = l.insns[entry].liveIn(liveOut[entry])&args | regs(RFP)
+		}
+
+		states, err := l.initialized(entry, initialized)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, state := range states {
+			result[i] = state
+		}
+	}
+
+	return result, nil
+}
+
+// frameDepths returns the stack usage of the function containing each
+// instruction.
+func (l *layout) frameDepths() ([]int, error) {
+	depths := make([]int, len(l.insns))
+	for _, entry := range l.functions() {
+		fn, _, err := l.functionStack(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		reachable, err := l.reachable(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range reachable {
+			depths[i] = fn.Depth
+		}
+	}
+
+	return depths, nil
+}
+
+func align8(n int) int {
+	return (n + 7) &^ 7
+}
@@ -0,0 +1,102 @@
+package asm
+
+import (
+	"testing"
+)
+
+func TestInstrumentBreakpoints(t *testing.T) {
+	insns := Instructions{
+		Mov.Imm(R0, 1),
+		{OpCode: JEq.Op(ImmSource), Dst: R1, Offset: 1},
+		Mov.Imm(R0, 2),
+		StoreMem(RFP, -8, R0, DWord),
+		Return(),
+	}
+
+	instrumented, err := insns.InstrumentBreakpoints("bp", 3, []Breakpoint{
+		{Index: 3, Registers: []Register{R0, R2}},
+		{Index: 1, Registers: []Register{R0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(instrumented)
+
+	if err := instrumented.Validate(); err != nil {
+		t.Fatal("Instrumented instructions are invalid:", err)
+	}
+
+	var (
+		calls   int
+		jump    = -1
+		offsets = make(map[RawInstructionOffset]int)
+	)
+	iter := instrumented.Iterate()
+	for iter.Next() {
+		offsets[iter.Offset] = iter.Index
+		if iter.Ins.jumpOp() == Call && iter.Ins.Constant == int64(FnRingbufOutput) {
+			calls++
+		}
+		if isBranch(*iter.Ins) {
+			jump = iter.Index
+		}
+	}
+
+	if calls != 2 {
+		t.Fatal("Expected two calls to bpf_ringbuf_output, got", calls)
+	}
+
+	// R0 is live at both breakpoints, and has to be saved below the
+	// stack of the program.
+	first := instrumented[1]
+	if first.OpCode != StoreMemOp(DWord) || first.Src != R0 || first.Offset != -24 {
+		t.Error("R0 isn't saved at the first breakpoint:", first)
+	}
+
+	// The jump has to hit the start of the second breakpoint.
+	var jumpOffset RawInstructionOffset
+	for offset, i := range offsets {
+		if i == jump {
+			jumpOffset = offset
+		}
+	}
+	target := instrumented[offsets[jumpOffset+RawInstructionOffset(instrumented[jump].Offset)+1]]
+	if target.OpCode != StoreMemOp(DWord) || target.Src != R0 || target.Offset != -16 {
+		t.Error("Jump doesn't hit the second breakpoint:", target)
+	}
+
+	// R2 is never initialized and must not be read.
+	for _, ins := range instrumented {
+		if ins.OpCode.Class() == StXClass && ins.Src == R2 {
+			t.Error("Uninitialized register is read:", ins)
+		}
+	}
+}
+
+func TestInstrumentBreakpointsInvalid(t *testing.T) {
+	insns := Instructions{
+		Mov.Imm(R0, 0),
+		Return(),
+	}
+
+	for name, bps := range map[string][]Breakpoint{
+		"out of bounds": {{Index: 2}},
+		"duplicate":     {{Index: 0}, {Index: 0}},
+		"frame pointer": {{Index: 0, Registers: []Register{RFP}}},
+	} {
+		if _, err := insns.InstrumentBreakpoints("bp", 0, bps); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// The breakpoint doesn't fit below the stack used by the program.
+	full := Instructions{
+		StoreImm(RFP, -MaxStackDepth, 0, DWord),
+		Mov.Imm(R0, 0),
+		Return(),
+	}
+	if _, err := full.InstrumentBreakpoints("bp", 0, []Breakpoint{{Index: 1}}); err == nil {
+		t.Error("Expected an error for a full stack")
+	}
+}
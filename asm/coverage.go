@@ -0,0 +1,140 @@
+package asm
+
+import "math"
+
+// BasicBlock is a sequence of instructions which is only entered at the
+// first and only left after the last instruction.
+type BasicBlock struct {
+	// Start is the index of the first instruction.
+	Start int
+	// Length is the number of instructions in the block.
+	Length int
+}
+
+// BasicBlocks splits insns into basic blocks, ordered by index.
+//
+// Blocks start at the first instruction, at symbols, at the targets of
+// jumps and bpf-to-bpf calls, and after jumps and exits.
+func (insns Instructions) BasicBlocks() ([]BasicBlock, error) {
+	l, err := newLayout(insns)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.basicBlocks()
+}
+
+func (l *layout) basicBlocks() ([]BasicBlock, error) {
+	leaders, err := l.jumpTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	leaders[0] = true
+	for i, ins := range l.insns {
+		if ins.Symbol != "" {
+			leaders[i] = true
+		}
+		if isBranch(ins) || ins.jumpOp() == Exit {
+			leaders[i+1] = true
+		}
+	}
+
+	var blocks []BasicBlock
+	for i := range l.insns {
+		if !leaders[i] {
+			blocks[len(blocks)-1].Length++
+			continue
+		}
+		blocks = append(blocks, BasicBlock{i, 1})
+	}
+
+	return blocks, nil
+}
+
+// InstrumentCoverage returns a copy of insns which counts how often each
+// basic block executes, and the blocks of insns in the order of their
+// counters.
+//
+// The counter of block i is a 64 bit integer at offset 8*i of the value
+// of the map referenced by symbol. The map must be an Array with a
+// single entry.
+//
+// Counters are incremented using two registers which aren't live at the
+// point of the increment, which is as close to the start of the block as
+// possible. Returns a *ValidationError for blocks without two unused
+// registers.
+func (insns Instructions) InstrumentCoverage(symbol string) (Instructions, []BasicBlock, error) {
+	l, err := newLayout(insns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blocks, err := l.basicBlocks()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	liveOut, err := l.liveness()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replacements := make([]Instructions, len(insns))
+	for i, ins := range insns {
+		replacements[i] = Instructions{ins}
+	}
+
+	for n, block := range blocks {
+		i, ptr, one, ok := l.counterSite(block, liveOut)
+		if !ok {
+			return nil, nil, l.errorf(block.Start, "no unused registers for coverage counter")
+		}
+
+		// The fd is filled in when the map is loaded, like for other
+		// references to maps.
+		load := Instruction{
+			OpCode:    LoadImmOp(DWord),
+			Dst:       ptr,
+			Src:       PseudoMapValue,
+			Constant:  int64(uint64(n*8)<<32 | math.MaxUint32),
+			Reference: symbol,
+		}
+
+		counter := Instructions{
+			load,
+			Mov.Imm(one, 1),
+			StoreXAdd(ptr, one, DWord),
+		}
+
+		ins := insns[i]
+		counter[0].Symbol = ins.Symbol
+		ins.Symbol = ""
+		for j := range counter {
+			counter[j].Metadata = ins.Metadata
+		}
+		replacements[i] = append(counter, ins)
+	}
+
+	out, err := l.rewrite(replacements)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out, blocks, nil
+}
+
+// counterSite finds the first instruction of a block before which two
+// registers aren't live.
+func (l *layout) counterSite(block BasicBlock, liveOut []regSet) (i int, ptr, one Register, ok bool) {
+	for i = block.Start; i < block.Start+block.Length; i++ {
+		free := regs(R0, R1, R2, R3, R4, R5, R6, R7, R8, R9) &^ l.insns[i].liveIn(liveOut[i])
+
+		ptr = free.lowest()
+		one = (free &^ regs(ptr)).lowest()
+		if one <= R9 {
+			return i, ptr, one, true
+		}
+	}
+	return 0, 0, 0, false
+}
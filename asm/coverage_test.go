@@ -0,0 +1,100 @@
+package asm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBasicBlocks(t *testing.T) {
+	insns := Instructions{
+		Mov.Imm(R0, 0),
+		JEq.Imm(R1, 0, "exit"),
+		Mov.Imm(R0, 1),
+		Ja.Label("exit"),
+		Mov.Imm(R0, 2),
+		Return().Sym("exit"),
+	}
+
+	blocks, err := insns.BasicBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []BasicBlock{{0, 2}, {2, 2}, {4, 1}, {5, 1}}
+	if !reflect.DeepEqual(blocks, want) {
+		t.Errorf("Expected blocks %v, got %v", want, blocks)
+	}
+}
+
+func TestInstrumentCoverage(t *testing.T) {
+	insns := Instructions{
+		Mov.Imm(R0, 0),
+		{OpCode: JEq.Op(ImmSource), Dst: R1, Offset: 1},
+		Mov.Imm(R0, 1),
+		Return(),
+	}
+
+	instrumented, blocks, err := insns.InstrumentCoverage("cov")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(instrumented)
+
+	if len(blocks) != 3 {
+		t.Fatalf("Expected three blocks, got %v", blocks)
+	}
+
+	if err := instrumented.Validate(); err != nil {
+		t.Fatal("Instrumented instructions are invalid:", err)
+	}
+
+	var offsets []uint32
+	for _, ins := range instrumented {
+		if ins.Reference != "cov" {
+			continue
+		}
+		if ins.Src != PseudoMapValue {
+			t.Fatal("Counter isn't a direct map value load:", ins)
+		}
+		offsets = append(offsets, ins.mapOffset())
+	}
+
+	if !reflect.DeepEqual(offsets, []uint32{0, 8, 16}) {
+		t.Error("Unexpected counter offsets", offsets)
+	}
+
+	// The jump has to skip the counter of the second block, and hit the
+	// counter of the third.
+	jump := instrumented[4]
+	if !isBranch(jump) || jump.Offset != 5 {
+		t.Error("Jump offset wasn't adjusted:", jump)
+	}
+}
+
+func TestInstrumentCoverageNoRegisters(t *testing.T) {
+	build := func(split bool) Instructions {
+		var insns Instructions
+		for r := R0; r <= R9; r++ {
+			insns = append(insns, Mov.Imm(r, 0))
+		}
+		for r := R1; r <= R9; r++ {
+			if split {
+				insns = append(insns, Instruction{OpCode: Ja.Op(ImmSource)})
+			}
+			insns = append(insns, Add.Reg(R0, r))
+		}
+		return append(insns, Return())
+	}
+
+	// The counter of the only block is placed at the start, where no
+	// registers are live.
+	if _, _, err := build(false).InstrumentCoverage("cov"); err != nil {
+		t.Fatal(err)
+	}
+
+	// All registers are live in the block adding R1.
+	if _, _, err := build(true).InstrumentCoverage("cov"); err == nil {
+		t.Error("Expected an error for a block without unused registers")
+	}
+}
@@ -0,0 +1,262 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DifferenceKind is the type of a Difference.
+type DifferenceKind uint8
+
+const (
+	// Inserted instructions only exist in the new instructions.
+	Inserted DifferenceKind = iota + 1
+	// Deleted instructions only exist in the old instructions.
+	Deleted
+	// Changed instructions have the same opcode, but different operands.
+	Changed
+)
+
+func (kind DifferenceKind) String() string {
+	switch kind {
+	case Inserted:
+		return "inserted"
+	case Deleted:
+		return "deleted"
+	case Changed:
+		return "changed"
+	default:
+		return fmt.Sprintf("DifferenceKind(%d)", uint8(kind))
+	}
+}
+
+// Difference is an instruction which differs between two programs.
+type Difference struct {
+	Kind DifferenceKind
+	// Index of the instruction in the old instructions. For insertions,
+	// the index of the old instruction following the insertion.
+	A int
+	// Index of the instruction in the new instructions. For deletions,
+	// the index of the new instruction following the deletion.
+	B int
+	// Old is the zero value for insertions, New for deletions.
+	Old, New Instruction
+	// Fields contains the names of the fields which differ between Old
+	// and New, for Changed instructions.
+	Fields []string
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case Inserted:
+		return fmt.Sprintf("+%d: %v", d.B, d.New)
+	case Deleted:
+		return fmt.Sprintf("-%d: %v", d.A, d.Old)
+	default:
+		return fmt.Sprintf("~%d/%d: %v -> %v (%s)", d.A, d.B, d.Old, d.New, strings.Join(d.Fields, ", "))
+	}
+}
+
+// Diff returns the instructions which differ between a and b, ordered by
+// their position.
+//
+// Instructions are aligned so that the number of differences is
+// minimal. A deleted instruction which is followed by an inserted
+// instruction with the same opcode is reported as Changed. Metadata such
+// as the source of an instruction is ignored.
+//
+// Diff is useful to compare instructions to a golden file in tests, or to
+// show what a rewrite of a program changed. Its cost grows with the
+// number of differences.
+func Diff(a, b Instructions) []Difference {
+	// Common prefixes and suffixes are the norm when comparing a program
+	// to a rewrite of itself.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && equalInstructions(a[prefix], b[prefix]) {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		equalInstructions(a[len(a)-1-suffix], b[len(b)-1-suffix]) {
+		suffix++
+	}
+
+	oldInsns := a[prefix : len(a)-suffix]
+	newInsns := b[prefix : len(b)-suffix]
+	script := shortestEditScript(len(oldInsns), len(newInsns), func(i, j int) bool {
+		return equalInstructions(oldInsns[i], newInsns[j])
+	})
+
+	var (
+		diffs      []Difference
+		dels, inss []int
+		x, y       int
+	)
+
+	// flush pairs deletions and insertions between two matches.
+	flush := func() {
+		for len(dels) > 0 || len(inss) > 0 {
+			switch {
+			case len(dels) > 0 && len(inss) > 0 && oldInsns[dels[0]].OpCode == newInsns[inss[0]].OpCode:
+				before, after := oldInsns[dels[0]], newInsns[inss[0]]
+				diffs = append(diffs, Difference{
+					Kind:   Changed,
+					A:      prefix + dels[0],
+					B:      prefix + inss[0],
+					Old:    before,
+					New:    after,
+					Fields: changedFields(before, after),
+				})
+				dels, inss = dels[1:], inss[1:]
+
+			case len(dels) > 0:
+				diffs = append(diffs, Difference{
+					Kind: Deleted,
+					A:    prefix + dels[0],
+					B:    prefix + y - len(inss),
+					Old:  oldInsns[dels[0]],
+				})
+				dels = dels[1:]
+
+			default:
+				diffs = append(diffs, Difference{
+					Kind: Inserted,
+					A:    prefix + x,
+					B:    prefix + inss[0],
+					New:  newInsns[inss[0]],
+				})
+				inss = inss[1:]
+			}
+		}
+	}
+
+	for _, op := range script {
+		switch op {
+		case editMatch:
+			flush()
+			x++
+			y++
+		case editDelete:
+			dels = append(dels, x)
+			x++
+		case editInsert:
+			inss = append(inss, y)
+			y++
+		}
+	}
+	flush()
+
+	return diffs
+}
+
+// equalInstructions compares instructions, ignoring metadata.
+func equalInstructions(a, b Instruction) bool {
+	return len(changedFields(a, b)) == 0 && a.OpCode == b.OpCode
+}
+
+func changedFields(a, b Instruction) []string {
+	var fields []string
+	if a.Dst != b.Dst {
+		fields = append(fields, "Dst")
+	}
+	if a.Src != b.Src {
+		fields = append(fields, "Src")
+	}
+	if a.Offset != b.Offset {
+		fields = append(fields, "Offset")
+	}
+	if a.Constant != b.Constant {
+		fields = append(fields, "Constant")
+	}
+	if a.Reference != b.Reference {
+		fields = append(fields, "Reference")
+	}
+	if a.Symbol != b.Symbol {
+		fields = append(fields, "Symbol")
+	}
+	return fields
+}
+
+type editOp uint8
+
+const (
+	editMatch editOp = iota
+	editDelete
+	editInsert
+)
+
+// shortestEditScript turns a sequence of length n into one of length m
+// using Myers' algorithm.
+func shortestEditScript(n, m int, equal func(i, j int) bool) []editOp {
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+
+	// trace[d] holds v[-d-1:d+2] before step d.
+	var trace [][]int
+
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && equal(x, y) {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var script []editOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			script = append(script, editMatch)
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				script = append(script, editInsert)
+			} else {
+				script = append(script, editDelete)
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(script)-1; i < j; i, j = i+1, j-1 {
+		script[i], script[j] = script[j], script[i]
+	}
+
+	return script
+}
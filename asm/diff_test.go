@@ -0,0 +1,104 @@
+package asm
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := Instructions{
+		Mov.Imm(R0, 0).Sym("prog"),
+		Mov.Imm(R1, 1),
+		Add.Reg(R0, R1),
+		JEq.Imm(R0, 1, "out"),
+		Mov.Imm(R0, 2),
+		Return().Sym("out"),
+	}
+
+	b := Instructions{
+		Mov.Imm(R0, 0).Sym("prog"),
+		Mov.Imm(R1, 2),
+		Add.Reg(R0, R1),
+		Sub.Imm(R0, 1),
+		JEq.Imm(R0, 1, "out"),
+		Return().Sym("out"),
+	}
+
+	diffs := Diff(a, b)
+	for _, d := range diffs {
+		t.Log(d)
+	}
+
+	want := []string{
+		"~1/1: MovImm dst: r1 imm: 1 -> MovImm dst: r1 imm: 2 (Constant)",
+		"+3: SubImm dst: r0 imm: 1",
+		"-4: MovImm dst: r0 imm: 2",
+	}
+
+	if len(diffs) != len(want) {
+		t.Fatalf("Expected %d differences, got %d", len(want), len(diffs))
+	}
+
+	for i := range want {
+		if have := diffs[i].String(); have != want[i] {
+			t.Errorf("Difference %d: expected %q, got %q", i, want[i], have)
+		}
+	}
+
+	if diffs[1].A != 3 || diffs[2].B != 5 {
+		t.Error("Insertions and deletions aren't aligned:", diffs[1].A, diffs[2].B)
+	}
+
+	if diffs := Diff(a, a); len(diffs) != 0 {
+		t.Error("Identical instructions have differences:", diffs)
+	}
+
+	if diffs := Diff(nil, a); len(diffs) != len(a) {
+		t.Error("Expected all instructions to be inserted:", diffs)
+	}
+}
+
+func TestDiffApply(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	random := func() Instructions {
+		insns := make(Instructions, rng.Intn(20))
+		for i := range insns {
+			insns[i] = Mov.Imm(R0, int32(rng.Intn(3)))
+		}
+		return insns
+	}
+
+	for i := 0; i < 100; i++ {
+		a, b := random(), random()
+		diffs := Diff(a, b)
+
+		// Applying the differences to a must result in b.
+		var patched Instructions
+		next := 0
+		for _, d := range diffs {
+			switch d.Kind {
+			case Deleted:
+				patched = append(patched, a[next:d.A]...)
+				next = d.A + 1
+			case Changed:
+				patched = append(patched, a[next:d.A]...)
+				patched = append(patched, d.New)
+				next = d.A + 1
+			case Inserted:
+				patched = append(patched, a[next:d.A]...)
+				patched = append(patched, d.New)
+				next = d.A
+			}
+		}
+		patched = append(patched, a[next:]...)
+
+		if fmt.Sprint(patched) != fmt.Sprint(b) {
+			t.Fatalf("Applying differences doesn't work:\n%v\n%v\n%v", a, b, diffs)
+		}
+
+		if len(diffs) > len(a)+len(b) {
+			t.Fatal("Too many differences")
+		}
+	}
+}
@@ -1,139 +1,49 @@
 package asm
 
-//go:generate stringer -output func_string.go -type=BuiltinFunc
+//go:generate go run gen_func.go /usr/include/linux/bpf.h
+//go:generate stringer -output func_string.go -type=BuiltinFunc,ArgType
 
 // BuiltinFunc is a built-in eBPF function.
+//
+// The list of functions is generated from include/uapi/linux/bpf.h, see
+// gen_func.go.
 type BuiltinFunc int32
 
-// eBPF built-in functions
-//
-// You can renegerate this list using the following gawk script:
-//
-//    /FN\(.+\),/ {
-//      match($1, /\((.+)\)/, r)
-//      split(r[1], p, "_")
-//      printf "Fn"
-//      for (i in p) {
-//        printf "%s%s", toupper(substr(p[i], 1, 1)), substr(p[i], 2)
-//      }
-//      print ""
-//    }
-//
-// The script expects include/uapi/linux/bpf.h as it's input.
+// ArgType is the type of an argument or of the return value of a
+// built-in function.
+type ArgType uint8
+
+// Types of arguments and return values.
 const (
-	FnUnspec BuiltinFunc = iota
-	FnMapLookupElem
-	FnMapUpdateElem
-	FnMapDeleteElem
-	FnProbeRead
-	FnKtimeGetNs
-	FnTracePrintk
-	FnGetPrandomU32
-	FnGetSmpProcessorId
-	FnSkbStoreBytes
-	FnL3CsumReplace
-	FnL4CsumReplace
-	FnTailCall
-	FnCloneRedirect
-	FnGetCurrentPidTgid
-	FnGetCurrentUidGid
-	FnGetCurrentComm
-	FnGetCgroupClassid
-	FnSkbVlanPush
-	FnSkbVlanPop
-	FnSkbGetTunnelKey
-	FnSkbSetTunnelKey
-	FnPerfEventRead
-	FnRedirect
-	FnGetRouteRealm
-	FnPerfEventOutput
-	FnSkbLoadBytes
-	FnGetStackid
-	FnCsumDiff
-	FnSkbGetTunnelOpt
-	FnSkbSetTunnelOpt
-	FnSkbChangeProto
-	FnSkbChangeType
-	FnSkbUnderCgroup
-	FnGetHashRecalc
-	FnGetCurrentTask
-	FnProbeWriteUser
-	FnCurrentTaskUnderCgroup
-	FnSkbChangeTail
-	FnSkbPullData
-	FnCsumUpdate
-	FnSetHashInvalid
-	FnGetNumaNodeId
-	FnSkbChangeHead
-	FnXdpAdjustHead
-	FnProbeReadStr
-	FnGetSocketCookie
-	FnGetSocketUid
-	FnSetHash
-	FnSetsockopt
-	FnSkbAdjustRoom
-	FnRedirectMap
-	FnSkRedirectMap
-	FnSockMapUpdate
-	FnXdpAdjustMeta
-	FnPerfEventReadValue
-	FnPerfProgReadValue
-	FnGetsockopt
-	FnOverrideReturn
-	FnSockOpsCbFlagsSet
-	FnMsgRedirectMap
-	FnMsgApplyBytes
-	FnMsgCorkBytes
-	FnMsgPullData
-	FnBind
-	FnXdpAdjustTail
-	FnSkbGetXfrmState
-	FnGetStack
-	FnSkbLoadBytesRelative
-	FnFibLookup
-	FnSockHashUpdate
-	FnMsgRedirectHash
-	FnSkRedirectHash
-	FnLwtPushEncap
-	FnLwtSeg6StoreBytes
-	FnLwtSeg6AdjustSrh
-	FnLwtSeg6Action
-	FnRcRepeat
-	FnRcKeydown
-	FnSkbCgroupId
-	FnGetCurrentCgroupId
-	FnGetLocalStorage
-	FnSkSelectReuseport
-	FnSkbAncestorCgroupId
-	FnSkLookupTcp
-	FnSkLookupUdp
-	FnSkRelease
-	FnMapPushElem
-	FnMapPopElem
-	FnMapPeekElem
-	FnMsgPushData
-	FnMsgPopData
-	FnRcPointerRel
-	FnSpinLock
-	FnSpinUnlock
-	FnSkFullsock
-	FnTcpSock
-	FnSkbEcnSetCe
-	FnGetListenerSock
-	FnSkcLookupTcp
-	FnTcpCheckSyncookie
-	FnSysctlGetName
-	FnSysctlGetCurrentValue
-	FnSysctlGetNewValue
-	FnSysctlSetNewValue
-	FnStrtol
-	FnStrtoul
-	FnSkStorageGet
-	FnSkStorageDelete
-	FnSendSignal
-	FnTcpGenSyncookie
+	// ArgUnknown is any value.
+	ArgUnknown ArgType = iota
+	// ArgScalar is a number.
+	ArgScalar
+	// ArgPointer is a pointer to memory. Some functions accept NULL or
+	// arbitrary numbers, for example FnProbeRead.
+	ArgPointer
+	// ArgMapPointer is a map, as loaded by LoadMapPtr.
+	ArgMapPointer
+	// ArgContext is the context of the program, passed in R1.
+	ArgContext
 )
 
+// builtinFunc describes a built-in function.
+type builtinFunc struct {
+	// The C declaration of the function, for example
+	// "u64 bpf_ktime_get_ns(void)".
+	signature string
+	// The first mainline kernel providing the function.
+	version string
+	// The types of the arguments passed in R1 to R5, without
+	// variadic arguments.
+	args []ArgType
+	ret  ArgType
+	// The function may only be called by programs with a GPL
+	// compatible license.
+	gplOnly bool
+}
+
 // Call emits a function call.
 func (fn BuiltinFunc) Call() Instruction {
 	return Instruction{
@@ -141,3 +51,40 @@ func (fn BuiltinFunc) Call() Instruction {
 		Constant: int64(fn),
 	}
 }
+
+// Signature returns the C declaration of the function, or an empty
+// string if the function is unknown.
+func (fn BuiltinFunc) Signature() string {
+	if fn < 0 || int(fn) >= len(builtinFuncs) {
+		return ""
+	}
+	return builtinFuncs[fn].signature
+}
+
+// Args returns the types of the arguments of the function, which are
+// passed in R1 to R5. Variadic arguments aren't included.
+//
+// Returns nil if the function has no arguments or is unknown.
+func (fn BuiltinFunc) Args() []ArgType {
+	if fn < 0 || int(fn) >= len(builtinFuncs) {
+		return nil
+	}
+	return append([]ArgType(nil), builtinFuncs[fn].args...)
+}
+
+// GPLOnly returns true if the kernel only allows programs with a GPL
+// compatible license to call the function.
+func (fn BuiltinFunc) GPLOnly() bool {
+	if fn < 0 || int(fn) >= len(builtinFuncs) {
+		return false
+	}
+	return builtinFuncs[fn].gplOnly
+}
+
+// Returns returns the type of the value the function returns in R0.
+func (fn BuiltinFunc) Returns() ArgType {
+	if fn < 0 || int(fn) >= len(builtinFuncs) {
+		return ArgUnknown
+	}
+	return builtinFuncs[fn].ret
+}
@@ -0,0 +1,430 @@
+// Code generated by gen_func.go; DO NOT EDIT.
+
+package asm
+
+// eBPF built-in functions
+const (
+	FnUnspec BuiltinFunc = iota
+	FnMapLookupElem
+	FnMapUpdateElem
+	FnMapDeleteElem
+	FnProbeRead
+	FnKtimeGetNs
+	FnTracePrintk
+	FnGetPrandomU32
+	FnGetSmpProcessorId
+	FnSkbStoreBytes
+	FnL3CsumReplace
+	FnL4CsumReplace
+	FnTailCall
+	FnCloneRedirect
+	FnGetCurrentPidTgid
+	FnGetCurrentUidGid
+	FnGetCurrentComm
+	FnGetCgroupClassid
+	FnSkbVlanPush
+	FnSkbVlanPop
+	FnSkbGetTunnelKey
+	FnSkbSetTunnelKey
+	FnPerfEventRead
+	FnRedirect
+	FnGetRouteRealm
+	FnPerfEventOutput
+	FnSkbLoadBytes
+	FnGetStackid
+	FnCsumDiff
+	FnSkbGetTunnelOpt
+	FnSkbSetTunnelOpt
+	FnSkbChangeProto
+	FnSkbChangeType
+	FnSkbUnderCgroup
+	FnGetHashRecalc
+	FnGetCurrentTask
+	FnProbeWriteUser
+	FnCurrentTaskUnderCgroup
+	FnSkbChangeTail
+	FnSkbPullData
+	FnCsumUpdate
+	FnSetHashInvalid
+	FnGetNumaNodeId
+	FnSkbChangeHead
+	FnXdpAdjustHead
+	FnProbeReadStr
+	FnGetSocketCookie
+	FnGetSocketUid
+	FnSetHash
+	FnSetsockopt
+	FnSkbAdjustRoom
+	FnRedirectMap
+	FnSkRedirectMap
+	FnSockMapUpdate
+	FnXdpAdjustMeta
+	FnPerfEventReadValue
+	FnPerfProgReadValue
+	FnGetsockopt
+	FnOverrideReturn
+	FnSockOpsCbFlagsSet
+	FnMsgRedirectMap
+	FnMsgApplyBytes
+	FnMsgCorkBytes
+	FnMsgPullData
+	FnBind
+	FnXdpAdjustTail
+	FnSkbGetXfrmState
+	FnGetStack
+	FnSkbLoadBytesRelative
+	FnFibLookup
+	FnSockHashUpdate
+	FnMsgRedirectHash
+	FnSkRedirectHash
+	FnLwtPushEncap
+	FnLwtSeg6StoreBytes
+	FnLwtSeg6AdjustSrh
+	FnLwtSeg6Action
+	FnRcRepeat
+	FnRcKeydown
+	FnSkbCgroupId
+	FnGetCurrentCgroupId
+	FnGetLocalStorage
+	FnSkSelectReuseport
+	FnSkbAncestorCgroupId
+	FnSkLookupTcp
+	FnSkLookupUdp
+	FnSkRelease
+	FnMapPushElem
+	FnMapPopElem
+	FnMapPeekElem
+	FnMsgPushData
+	FnMsgPopData
+	FnRcPointerRel
+	FnSpinLock
+	FnSpinUnlock
+	FnSkFullsock
+	FnTcpSock
+	FnSkbEcnSetCe
+	FnGetListenerSock
+	FnSkcLookupTcp
+	FnTcpCheckSyncookie
+	FnSysctlGetName
+	FnSysctlGetCurrentValue
+	FnSysctlGetNewValue
+	FnSysctlSetNewValue
+	FnStrtol
+	FnStrtoul
+	FnSkStorageGet
+	FnSkStorageDelete
+	FnSendSignal
+	FnTcpGenSyncookie
+	FnSkbOutput
+	FnProbeReadUser
+	FnProbeReadKernel
+	FnProbeReadUserStr
+	FnProbeReadKernelStr
+	FnTcpSendAck
+	FnSendSignalThread
+	FnJiffies64
+	FnReadBranchRecords
+	FnGetNsCurrentPidTgid
+	FnXdpOutput
+	FnGetNetnsCookie
+	FnGetCurrentAncestorCgroupId
+	FnSkAssign
+	FnKtimeGetBootNs
+	FnSeqPrintf
+	FnSeqWrite
+	FnSkCgroupId
+	FnSkAncestorCgroupId
+	FnRingbufOutput
+	FnRingbufReserve
+	FnRingbufSubmit
+	FnRingbufDiscard
+	FnRingbufQuery
+	FnCsumLevel
+	FnSkcToTcp6Sock
+	FnSkcToTcpSock
+	FnSkcToTcpTimewaitSock
+	FnSkcToTcpRequestSock
+	FnSkcToUdp6Sock
+	FnGetTaskStack
+	FnLoadHdrOpt
+	FnStoreHdrOpt
+	FnReserveHdrOpt
+	FnInodeStorageGet
+	FnInodeStorageDelete
+	FnDPath
+	FnCopyFromUser
+	FnSnprintfBtf
+	FnSeqPrintfBtf
+	FnSkbCgroupClassid
+	FnRedirectNeigh
+	FnPerCpuPtr
+	FnThisCpuPtr
+	FnRedirectPeer
+	FnTaskStorageGet
+	FnTaskStorageDelete
+	FnGetCurrentTaskBtf
+	FnBprmOptsSet
+	FnKtimeGetCoarseNs
+	FnImaInodeHash
+	FnSockFromFile
+	FnCheckMtu
+	FnForEachMapElem
+	FnSnprintf
+	FnSysBpf
+	FnBtfFindByNameKind
+	FnSysClose
+	FnTimerInit
+	FnTimerSetCallback
+	FnTimerStart
+	FnTimerCancel
+	FnGetFuncIp
+	FnGetAttachCookie
+	FnTaskPtRegs
+	FnGetBranchSnapshot
+	FnTraceVprintk
+	FnSkcToUnixSock
+	FnKallsymsLookupName
+	FnFindVma
+	FnLoop
+	FnStrncmp
+	FnGetFuncArg
+	FnGetFuncRet
+	FnGetFuncArgCnt
+	FnGetRetval
+	FnSetRetval
+	FnXdpGetBuffLen
+	FnXdpLoadBytes
+	FnXdpStoreBytes
+	FnCopyFromUserTask
+	FnSkbSetTstamp
+	FnImaFileHash
+	FnKptrXchg
+	FnMapLookupPercpuElem
+	FnSkcToMptcpSock
+	FnDynptrFromMem
+	FnRingbufReserveDynptr
+	FnRingbufSubmitDynptr
+	FnRingbufDiscardDynptr
+	FnDynptrRead
+	FnDynptrWrite
+	FnDynptrData
+	FnTcpRawGenSyncookieIpv4
+	FnTcpRawGenSyncookieIpv6
+	FnTcpRawCheckSyncookieIpv4
+	FnTcpRawCheckSyncookieIpv6
+	FnKtimeGetTaiNs
+	FnUserRingbufDrain
+)
+
+var builtinFuncs = [...]builtinFunc{
+	FnUnspec:                     {},
+	FnMapLookupElem:              {"void *bpf_map_lookup_elem(struct bpf_map *map, const void *key)", "3.19", []ArgType{ArgMapPointer, ArgPointer}, ArgPointer, false},
+	FnMapUpdateElem:              {"long bpf_map_update_elem(struct bpf_map *map, const void *key, const void *value, u64 flags)", "3.19", []ArgType{ArgMapPointer, ArgPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnMapDeleteElem:              {"long bpf_map_delete_elem(struct bpf_map *map, const void *key)", "3.19", []ArgType{ArgMapPointer, ArgPointer}, ArgScalar, false},
+	FnProbeRead:                  {"long bpf_probe_read(void *dst, u32 size, const void *unsafe_ptr)", "4.1", []ArgType{ArgPointer, ArgScalar, ArgPointer}, ArgScalar, true},
+	FnKtimeGetNs:                 {"u64 bpf_ktime_get_ns(void)", "4.1", nil, ArgScalar, false},
+	FnTracePrintk:                {"long bpf_trace_printk(const char *fmt, u32 fmt_size, ...)", "4.1", []ArgType{ArgPointer, ArgScalar}, ArgScalar, true},
+	FnGetPrandomU32:              {"u32 bpf_get_prandom_u32(void)", "4.1", nil, ArgScalar, false},
+	FnGetSmpProcessorId:          {"u32 bpf_get_smp_processor_id(void)", "4.1", nil, ArgScalar, false},
+	FnSkbStoreBytes:              {"long bpf_skb_store_bytes(struct sk_buff *skb, u32 offset, const void *from, u32 len, u64 flags)", "4.1", []ArgType{ArgContext, ArgScalar, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnL3CsumReplace:              {"long bpf_l3_csum_replace(struct sk_buff *skb, u32 offset, u64 from, u64 to, u64 size)", "4.1", []ArgType{ArgContext, ArgScalar, ArgScalar, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnL4CsumReplace:              {"long bpf_l4_csum_replace(struct sk_buff *skb, u32 offset, u64 from, u64 to, u64 flags)", "4.1", []ArgType{ArgContext, ArgScalar, ArgScalar, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnTailCall:                   {"long bpf_tail_call(void *ctx, struct bpf_map *prog_array_map, u32 index)", "4.2", []ArgType{ArgContext, ArgMapPointer, ArgScalar}, ArgScalar, false},
+	FnCloneRedirect:              {"long bpf_clone_redirect(struct sk_buff *skb, u32 ifindex, u64 flags)", "4.2", []ArgType{ArgContext, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnGetCurrentPidTgid:          {"u64 bpf_get_current_pid_tgid(void)", "4.2", nil, ArgScalar, false},
+	FnGetCurrentUidGid:           {"u64 bpf_get_current_uid_gid(void)", "4.2", nil, ArgScalar, false},
+	FnGetCurrentComm:             {"long bpf_get_current_comm(void *buf, u32 size_of_buf)", "4.2", []ArgType{ArgPointer, ArgScalar}, ArgScalar, false},
+	FnGetCgroupClassid:           {"u32 bpf_get_cgroup_classid(struct sk_buff *skb)", "4.3", []ArgType{ArgContext}, ArgScalar, false},
+	FnSkbVlanPush:                {"long bpf_skb_vlan_push(struct sk_buff *skb, __be16 vlan_proto, u16 vlan_tci)", "4.3", []ArgType{ArgContext, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSkbVlanPop:                 {"long bpf_skb_vlan_pop(struct sk_buff *skb)", "4.3", []ArgType{ArgContext}, ArgScalar, false},
+	FnSkbGetTunnelKey:            {"long bpf_skb_get_tunnel_key(struct sk_buff *skb, struct bpf_tunnel_key *key, u32 size, u64 flags)", "4.3", []ArgType{ArgContext, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSkbSetTunnelKey:            {"long bpf_skb_set_tunnel_key(struct sk_buff *skb, struct bpf_tunnel_key *key, u32 size, u64 flags)", "4.3", []ArgType{ArgContext, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnPerfEventRead:              {"u64 bpf_perf_event_read(struct bpf_map *map, u64 flags)", "4.3", []ArgType{ArgMapPointer, ArgScalar}, ArgScalar, true},
+	FnRedirect:                   {"long bpf_redirect(u32 ifindex, u64 flags)", "4.4", []ArgType{ArgScalar, ArgScalar}, ArgScalar, false},
+	FnGetRouteRealm:              {"u32 bpf_get_route_realm(struct sk_buff *skb)", "4.4", []ArgType{ArgContext}, ArgScalar, false},
+	FnPerfEventOutput:            {"long bpf_perf_event_output(void *ctx, struct bpf_map *map, u64 flags, void *data, u64 size)", "4.4", []ArgType{ArgContext, ArgMapPointer, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, true},
+	FnSkbLoadBytes:               {"long bpf_skb_load_bytes(const void *skb, u32 offset, void *to, u32 len)", "4.5", []ArgType{ArgContext, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnGetStackid:                 {"long bpf_get_stackid(void *ctx, struct bpf_map *map, u64 flags)", "4.6", []ArgType{ArgContext, ArgMapPointer, ArgScalar}, ArgScalar, true},
+	FnCsumDiff:                   {"s64 bpf_csum_diff(__be32 *from, u32 from_size, __be32 *to, u32 to_size, __wsum seed)", "4.6", []ArgType{ArgPointer, ArgScalar, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSkbGetTunnelOpt:            {"long bpf_skb_get_tunnel_opt(struct sk_buff *skb, void *opt, u32 size)", "4.6", []ArgType{ArgContext, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnSkbSetTunnelOpt:            {"long bpf_skb_set_tunnel_opt(struct sk_buff *skb, void *opt, u32 size)", "4.6", []ArgType{ArgContext, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnSkbChangeProto:             {"long bpf_skb_change_proto(struct sk_buff *skb, __be16 proto, u64 flags)", "4.8", []ArgType{ArgContext, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSkbChangeType:              {"long bpf_skb_change_type(struct sk_buff *skb, u32 type)", "4.8", []ArgType{ArgContext, ArgScalar}, ArgScalar, false},
+	FnSkbUnderCgroup:             {"long bpf_skb_under_cgroup(struct sk_buff *skb, struct bpf_map *map, u32 index)", "4.8", []ArgType{ArgContext, ArgMapPointer, ArgScalar}, ArgScalar, false},
+	FnGetHashRecalc:              {"u32 bpf_get_hash_recalc(struct sk_buff *skb)", "4.8", []ArgType{ArgContext}, ArgScalar, false},
+	FnGetCurrentTask:             {"u64 bpf_get_current_task(void)", "4.8", nil, ArgScalar, true},
+	FnProbeWriteUser:             {"long bpf_probe_write_user(void *dst, const void *src, u32 len)", "4.8", []ArgType{ArgPointer, ArgPointer, ArgScalar}, ArgScalar, true},
+	FnCurrentTaskUnderCgroup:     {"long bpf_current_task_under_cgroup(struct bpf_map *map, u32 index)", "4.9", []ArgType{ArgMapPointer, ArgScalar}, ArgScalar, false},
+	FnSkbChangeTail:              {"long bpf_skb_change_tail(struct sk_buff *skb, u32 len, u64 flags)", "4.9", []ArgType{ArgContext, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSkbPullData:                {"long bpf_skb_pull_data(struct sk_buff *skb, u32 len)", "4.9", []ArgType{ArgContext, ArgScalar}, ArgScalar, false},
+	FnCsumUpdate:                 {"s64 bpf_csum_update(struct sk_buff *skb, __wsum csum)", "4.9", []ArgType{ArgContext, ArgScalar}, ArgScalar, false},
+	FnSetHashInvalid:             {"void bpf_set_hash_invalid(struct sk_buff *skb)", "4.9", []ArgType{ArgContext}, ArgUnknown, false},
+	FnGetNumaNodeId:              {"long bpf_get_numa_node_id(void)", "4.10", nil, ArgScalar, false},
+	FnSkbChangeHead:              {"long bpf_skb_change_head(struct sk_buff *skb, u32 len, u64 flags)", "4.10", []ArgType{ArgContext, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnXdpAdjustHead:              {"long bpf_xdp_adjust_head(struct xdp_buff *xdp_md, int delta)", "4.10", []ArgType{ArgContext, ArgScalar}, ArgScalar, false},
+	FnProbeReadStr:               {"long bpf_probe_read_str(void *dst, u32 size, const void *unsafe_ptr)", "4.11", []ArgType{ArgPointer, ArgScalar, ArgPointer}, ArgScalar, true},
+	FnGetSocketCookie:            {"u64 bpf_get_socket_cookie(struct sk_buff *skb)", "4.12", []ArgType{ArgContext}, ArgScalar, false},
+	FnGetSocketUid:               {"u32 bpf_get_socket_uid(struct sk_buff *skb)", "4.12", []ArgType{ArgContext}, ArgScalar, false},
+	FnSetHash:                    {"long bpf_set_hash(struct sk_buff *skb, u32 hash)", "4.13", []ArgType{ArgContext, ArgScalar}, ArgScalar, false},
+	FnSetsockopt:                 {"long bpf_setsockopt(void *bpf_socket, int level, int optname, void *optval, int optlen)", "4.13", []ArgType{ArgPointer, ArgScalar, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnSkbAdjustRoom:              {"long bpf_skb_adjust_room(struct sk_buff *skb, s32 len_diff, u32 mode, u64 flags)", "4.13", []ArgType{ArgContext, ArgScalar, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnRedirectMap:                {"long bpf_redirect_map(struct bpf_map *map, u32 key, u64 flags)", "4.14", []ArgType{ArgMapPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSkRedirectMap:              {"long bpf_sk_redirect_map(struct sk_buff *skb, struct bpf_map *map, u32 key, u64 flags)", "4.14", []ArgType{ArgContext, ArgMapPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSockMapUpdate:              {"long bpf_sock_map_update(struct bpf_sock_ops *skops, struct bpf_map *map, void *key, u64 flags)", "4.14", []ArgType{ArgContext, ArgMapPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnXdpAdjustMeta:              {"long bpf_xdp_adjust_meta(struct xdp_buff *xdp_md, int delta)", "4.15", []ArgType{ArgContext, ArgScalar}, ArgScalar, false},
+	FnPerfEventReadValue:         {"long bpf_perf_event_read_value(struct bpf_map *map, u64 flags, struct bpf_perf_event_value *buf, u32 buf_size)", "4.15", []ArgType{ArgMapPointer, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, true},
+	FnPerfProgReadValue:          {"long bpf_perf_prog_read_value(struct bpf_perf_event_data *ctx, struct bpf_perf_event_value *buf, u32 buf_size)", "4.15", []ArgType{ArgContext, ArgPointer, ArgScalar}, ArgScalar, true},
+	FnGetsockopt:                 {"long bpf_getsockopt(void *bpf_socket, int level, int optname, void *optval, int optlen)", "4.15", []ArgType{ArgPointer, ArgScalar, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnOverrideReturn:             {"long bpf_override_return(struct pt_regs *regs, u64 rc)", "4.16", []ArgType{ArgContext, ArgScalar}, ArgScalar, true},
+	FnSockOpsCbFlagsSet:          {"long bpf_sock_ops_cb_flags_set(struct bpf_sock_ops *bpf_sock, int argval)", "4.16", []ArgType{ArgContext, ArgScalar}, ArgScalar, false},
+	FnMsgRedirectMap:             {"long bpf_msg_redirect_map(struct sk_msg_buff *msg, struct bpf_map *map, u32 key, u64 flags)", "4.17", []ArgType{ArgContext, ArgMapPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnMsgApplyBytes:              {"long bpf_msg_apply_bytes(struct sk_msg_buff *msg, u32 bytes)", "4.17", []ArgType{ArgContext, ArgScalar}, ArgScalar, false},
+	FnMsgCorkBytes:               {"long bpf_msg_cork_bytes(struct sk_msg_buff *msg, u32 bytes)", "4.17", []ArgType{ArgContext, ArgScalar}, ArgScalar, false},
+	FnMsgPullData:                {"long bpf_msg_pull_data(struct sk_msg_buff *msg, u32 start, u32 end, u64 flags)", "4.17", []ArgType{ArgContext, ArgScalar, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnBind:                       {"long bpf_bind(struct bpf_sock_addr *ctx, struct sockaddr *addr, int addr_len)", "4.17", []ArgType{ArgContext, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnXdpAdjustTail:              {"long bpf_xdp_adjust_tail(struct xdp_buff *xdp_md, int delta)", "4.18", []ArgType{ArgContext, ArgScalar}, ArgScalar, false},
+	FnSkbGetXfrmState:            {"long bpf_skb_get_xfrm_state(struct sk_buff *skb, u32 index, struct bpf_xfrm_state *xfrm_state, u32 size, u64 flags)", "4.18", []ArgType{ArgContext, ArgScalar, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnGetStack:                   {"long bpf_get_stack(void *ctx, void *buf, u32 size, u64 flags)", "4.18", []ArgType{ArgContext, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, true},
+	FnSkbLoadBytesRelative:       {"long bpf_skb_load_bytes_relative(const void *skb, u32 offset, void *to, u32 len, u32 start_header)", "4.18", []ArgType{ArgContext, ArgScalar, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnFibLookup:                  {"long bpf_fib_lookup(void *ctx, struct bpf_fib_lookup *params, int plen, u32 flags)", "4.18", []ArgType{ArgContext, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSockHashUpdate:             {"long bpf_sock_hash_update(struct bpf_sock_ops *skops, struct bpf_map *map, void *key, u64 flags)", "4.18", []ArgType{ArgContext, ArgMapPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnMsgRedirectHash:            {"long bpf_msg_redirect_hash(struct sk_msg_buff *msg, struct bpf_map *map, void *key, u64 flags)", "4.18", []ArgType{ArgContext, ArgMapPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnSkRedirectHash:             {"long bpf_sk_redirect_hash(struct sk_buff *skb, struct bpf_map *map, void *key, u64 flags)", "4.18", []ArgType{ArgContext, ArgMapPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnLwtPushEncap:               {"long bpf_lwt_push_encap(struct sk_buff *skb, u32 type, void *hdr, u32 len)", "4.18", []ArgType{ArgContext, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnLwtSeg6StoreBytes:          {"long bpf_lwt_seg6_store_bytes(struct sk_buff *skb, u32 offset, const void *from, u32 len)", "4.18", []ArgType{ArgContext, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnLwtSeg6AdjustSrh:           {"long bpf_lwt_seg6_adjust_srh(struct sk_buff *skb, u32 offset, s32 delta)", "4.18", []ArgType{ArgContext, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnLwtSeg6Action:              {"long bpf_lwt_seg6_action(struct sk_buff *skb, u32 action, void *param, u32 param_len)", "4.18", []ArgType{ArgContext, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnRcRepeat:                   {"long bpf_rc_repeat(void *ctx)", "4.18", []ArgType{ArgContext}, ArgScalar, false},
+	FnRcKeydown:                  {"long bpf_rc_keydown(void *ctx, u32 protocol, u64 scancode, u32 toggle)", "4.18", []ArgType{ArgContext, ArgScalar, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSkbCgroupId:                {"u64 bpf_skb_cgroup_id(struct sk_buff *skb)", "4.18", []ArgType{ArgContext}, ArgScalar, false},
+	FnGetCurrentCgroupId:         {"u64 bpf_get_current_cgroup_id(void)", "4.18", nil, ArgScalar, false},
+	FnGetLocalStorage:            {"void *bpf_get_local_storage(void *map, u64 flags)", "4.19", []ArgType{ArgPointer, ArgScalar}, ArgPointer, false},
+	FnSkSelectReuseport:          {"long bpf_sk_select_reuseport(struct sk_reuseport_md *reuse, struct bpf_map *map, void *key, u64 flags)", "4.19", []ArgType{ArgContext, ArgMapPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnSkbAncestorCgroupId:        {"u64 bpf_skb_ancestor_cgroup_id(struct sk_buff *skb, int ancestor_level)", "4.19", []ArgType{ArgContext, ArgScalar}, ArgScalar, false},
+	FnSkLookupTcp:                {"struct bpf_sock *bpf_sk_lookup_tcp(void *ctx, struct bpf_sock_tuple *tuple, u32 tuple_size, u64 netns, u64 flags)", "4.20", []ArgType{ArgContext, ArgPointer, ArgScalar, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSkLookupUdp:                {"struct bpf_sock *bpf_sk_lookup_udp(void *ctx, struct bpf_sock_tuple *tuple, u32 tuple_size, u64 netns, u64 flags)", "4.20", []ArgType{ArgContext, ArgPointer, ArgScalar, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSkRelease:                  {"long bpf_sk_release(void *sock)", "4.20", []ArgType{ArgPointer}, ArgScalar, false},
+	FnMapPushElem:                {"long bpf_map_push_elem(struct bpf_map *map, const void *value, u64 flags)", "4.20", []ArgType{ArgMapPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnMapPopElem:                 {"long bpf_map_pop_elem(struct bpf_map *map, void *value)", "4.20", []ArgType{ArgMapPointer, ArgPointer}, ArgScalar, false},
+	FnMapPeekElem:                {"long bpf_map_peek_elem(struct bpf_map *map, void *value)", "4.20", []ArgType{ArgMapPointer, ArgPointer}, ArgScalar, false},
+	FnMsgPushData:                {"long bpf_msg_push_data(struct sk_msg_buff *msg, u32 start, u32 len, u64 flags)", "4.20", []ArgType{ArgContext, ArgScalar, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnMsgPopData:                 {"long bpf_msg_pop_data(struct sk_msg_buff *msg, u32 start, u32 len, u64 flags)", "5.0", []ArgType{ArgContext, ArgScalar, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnRcPointerRel:               {"long bpf_rc_pointer_rel(void *ctx, s32 rel_x, s32 rel_y)", "5.0", []ArgType{ArgContext, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSpinLock:                   {"long bpf_spin_lock(struct bpf_spin_lock *lock)", "5.1", []ArgType{ArgPointer}, ArgScalar, false},
+	FnSpinUnlock:                 {"long bpf_spin_unlock(struct bpf_spin_lock *lock)", "5.1", []ArgType{ArgPointer}, ArgScalar, false},
+	FnSkFullsock:                 {"struct bpf_sock *bpf_sk_fullsock(struct bpf_sock *sk)", "5.1", []ArgType{ArgPointer}, ArgScalar, false},
+	FnTcpSock:                    {"struct bpf_tcp_sock *bpf_tcp_sock(struct bpf_sock *sk)", "5.1", []ArgType{ArgPointer}, ArgScalar, false},
+	FnSkbEcnSetCe:                {"long bpf_skb_ecn_set_ce(struct sk_buff *skb)", "5.1", []ArgType{ArgContext}, ArgScalar, false},
+	FnGetListenerSock:            {"struct bpf_sock *bpf_get_listener_sock(struct bpf_sock *sk)", "5.1", []ArgType{ArgPointer}, ArgScalar, false},
+	FnSkcLookupTcp:               {"struct bpf_sock *bpf_skc_lookup_tcp(void *ctx, struct bpf_sock_tuple *tuple, u32 tuple_size, u64 netns, u64 flags)", "5.2", []ArgType{ArgContext, ArgPointer, ArgScalar, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnTcpCheckSyncookie:          {"long bpf_tcp_check_syncookie(void *sk, void *iph, u32 iph_len, struct tcphdr *th, u32 th_len)", "5.2", []ArgType{ArgPointer, ArgPointer, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnSysctlGetName:              {"long bpf_sysctl_get_name(struct bpf_sysctl *ctx, char *buf, size_t buf_len, u64 flags)", "5.2", []ArgType{ArgContext, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSysctlGetCurrentValue:      {"long bpf_sysctl_get_current_value(struct bpf_sysctl *ctx, char *buf, size_t buf_len)", "5.2", []ArgType{ArgContext, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnSysctlGetNewValue:          {"long bpf_sysctl_get_new_value(struct bpf_sysctl *ctx, char *buf, size_t buf_len)", "5.2", []ArgType{ArgContext, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnSysctlSetNewValue:          {"long bpf_sysctl_set_new_value(struct bpf_sysctl *ctx, const char *buf, size_t buf_len)", "5.2", []ArgType{ArgContext, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnStrtol:                     {"long bpf_strtol(const char *buf, size_t buf_len, u64 flags, long *res)", "5.2", []ArgType{ArgPointer, ArgScalar, ArgScalar, ArgPointer}, ArgScalar, false},
+	FnStrtoul:                    {"long bpf_strtoul(const char *buf, size_t buf_len, u64 flags, unsigned long *res)", "5.2", []ArgType{ArgPointer, ArgScalar, ArgScalar, ArgPointer}, ArgScalar, false},
+	FnSkStorageGet:               {"void *bpf_sk_storage_get(struct bpf_map *map, void *sk, void *value, u64 flags)", "5.2", []ArgType{ArgMapPointer, ArgPointer, ArgPointer, ArgScalar}, ArgPointer, false},
+	FnSkStorageDelete:            {"long bpf_sk_storage_delete(struct bpf_map *map, void *sk)", "5.2", []ArgType{ArgMapPointer, ArgPointer}, ArgScalar, false},
+	FnSendSignal:                 {"long bpf_send_signal(u32 sig)", "5.3", []ArgType{ArgScalar}, ArgScalar, false},
+	FnTcpGenSyncookie:            {"s64 bpf_tcp_gen_syncookie(void *sk, void *iph, u32 iph_len, struct tcphdr *th, u32 th_len)", "5.3", []ArgType{ArgPointer, ArgPointer, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnSkbOutput:                  {"long bpf_skb_output(void *ctx, struct bpf_map *map, u64 flags, void *data, u64 size)", "5.5", []ArgType{ArgContext, ArgMapPointer, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, true},
+	FnProbeReadUser:              {"long bpf_probe_read_user(void *dst, u32 size, const void *unsafe_ptr)", "5.5", []ArgType{ArgPointer, ArgScalar, ArgPointer}, ArgScalar, true},
+	FnProbeReadKernel:            {"long bpf_probe_read_kernel(void *dst, u32 size, const void *unsafe_ptr)", "5.5", []ArgType{ArgPointer, ArgScalar, ArgPointer}, ArgScalar, true},
+	FnProbeReadUserStr:           {"long bpf_probe_read_user_str(void *dst, u32 size, const void *unsafe_ptr)", "5.5", []ArgType{ArgPointer, ArgScalar, ArgPointer}, ArgScalar, true},
+	FnProbeReadKernelStr:         {"long bpf_probe_read_kernel_str(void *dst, u32 size, const void *unsafe_ptr)", "5.5", []ArgType{ArgPointer, ArgScalar, ArgPointer}, ArgScalar, true},
+	FnTcpSendAck:                 {"long bpf_tcp_send_ack(void *tp, u32 rcv_nxt)", "5.5", []ArgType{ArgPointer, ArgScalar}, ArgScalar, false},
+	FnSendSignalThread:           {"long bpf_send_signal_thread(u32 sig)", "5.5", []ArgType{ArgScalar}, ArgScalar, false},
+	FnJiffies64:                  {"u64 bpf_jiffies64(void)", "5.5", nil, ArgScalar, false},
+	FnReadBranchRecords:          {"long bpf_read_branch_records(struct bpf_perf_event_data *ctx, void *buf, u32 size, u64 flags)", "5.6", []ArgType{ArgContext, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, true},
+	FnGetNsCurrentPidTgid:        {"long bpf_get_ns_current_pid_tgid(u64 dev, u64 ino, struct bpf_pidns_info *nsdata, u32 size)", "5.7", []ArgType{ArgScalar, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnXdpOutput:                  {"long bpf_xdp_output(void *ctx, struct bpf_map *map, u64 flags, void *data, u64 size)", "5.7", []ArgType{ArgContext, ArgMapPointer, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, true},
+	FnGetNetnsCookie:             {"u64 bpf_get_netns_cookie(void *ctx)", "5.7", []ArgType{ArgContext}, ArgScalar, false},
+	FnGetCurrentAncestorCgroupId: {"u64 bpf_get_current_ancestor_cgroup_id(int ancestor_level)", "5.7", []ArgType{ArgScalar}, ArgScalar, false},
+	FnSkAssign:                   {"long bpf_sk_assign(struct sk_buff *skb, void *sk, u64 flags)", "5.7", []ArgType{ArgContext, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnKtimeGetBootNs:             {"u64 bpf_ktime_get_boot_ns(void)", "5.8", nil, ArgScalar, false},
+	FnSeqPrintf:                  {"long bpf_seq_printf(struct seq_file *m, const char *fmt, u32 fmt_size, const void *data, u32 data_len)", "5.8", []ArgType{ArgPointer, ArgPointer, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, true},
+	FnSeqWrite:                   {"long bpf_seq_write(struct seq_file *m, const void *data, u32 len)", "5.8", []ArgType{ArgPointer, ArgPointer, ArgScalar}, ArgScalar, true},
+	FnSkCgroupId:                 {"u64 bpf_sk_cgroup_id(void *sk)", "5.8", []ArgType{ArgPointer}, ArgScalar, false},
+	FnSkAncestorCgroupId:         {"u64 bpf_sk_ancestor_cgroup_id(void *sk, int ancestor_level)", "5.8", []ArgType{ArgPointer, ArgScalar}, ArgScalar, false},
+	FnRingbufOutput:              {"long bpf_ringbuf_output(void *ringbuf, void *data, u64 size, u64 flags)", "5.8", []ArgType{ArgPointer, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnRingbufReserve:             {"void *bpf_ringbuf_reserve(void *ringbuf, u64 size, u64 flags)", "5.8", []ArgType{ArgPointer, ArgScalar, ArgScalar}, ArgPointer, false},
+	FnRingbufSubmit:              {"void bpf_ringbuf_submit(void *data, u64 flags)", "5.8", []ArgType{ArgPointer, ArgScalar}, ArgUnknown, false},
+	FnRingbufDiscard:             {"void bpf_ringbuf_discard(void *data, u64 flags)", "5.8", []ArgType{ArgPointer, ArgScalar}, ArgUnknown, false},
+	FnRingbufQuery:               {"u64 bpf_ringbuf_query(void *ringbuf, u64 flags)", "5.8", []ArgType{ArgPointer, ArgScalar}, ArgScalar, false},
+	FnCsumLevel:                  {"long bpf_csum_level(struct sk_buff *skb, u64 level)", "5.8", []ArgType{ArgContext, ArgScalar}, ArgScalar, false},
+	FnSkcToTcp6Sock:              {"struct tcp6_sock *bpf_skc_to_tcp6_sock(void *sk)", "5.9", []ArgType{ArgPointer}, ArgPointer, false},
+	FnSkcToTcpSock:               {"struct tcp_sock *bpf_skc_to_tcp_sock(void *sk)", "5.9", []ArgType{ArgPointer}, ArgPointer, false},
+	FnSkcToTcpTimewaitSock:       {"struct tcp_timewait_sock *bpf_skc_to_tcp_timewait_sock(void *sk)", "5.9", []ArgType{ArgPointer}, ArgPointer, false},
+	FnSkcToTcpRequestSock:        {"struct tcp_request_sock *bpf_skc_to_tcp_request_sock(void *sk)", "5.9", []ArgType{ArgPointer}, ArgPointer, false},
+	FnSkcToUdp6Sock:              {"struct udp6_sock *bpf_skc_to_udp6_sock(void *sk)", "5.9", []ArgType{ArgPointer}, ArgPointer, false},
+	FnGetTaskStack:               {"long bpf_get_task_stack(struct task_struct *task, void *buf, u32 size, u64 flags)", "5.9", []ArgType{ArgPointer, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnLoadHdrOpt:                 {"long bpf_load_hdr_opt(struct bpf_sock_ops *skops, void *searchby_res, u32 len, u64 flags)", "5.10", []ArgType{ArgContext, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnStoreHdrOpt:                {"long bpf_store_hdr_opt(struct bpf_sock_ops *skops, const void *from, u32 len, u64 flags)", "5.10", []ArgType{ArgContext, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnReserveHdrOpt:              {"long bpf_reserve_hdr_opt(struct bpf_sock_ops *skops, u32 len, u64 flags)", "5.10", []ArgType{ArgContext, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnInodeStorageGet:            {"void *bpf_inode_storage_get(struct bpf_map *map, void *inode, void *value, u64 flags)", "5.10", []ArgType{ArgMapPointer, ArgPointer, ArgPointer, ArgScalar}, ArgPointer, false},
+	FnInodeStorageDelete:         {"int bpf_inode_storage_delete(struct bpf_map *map, void *inode)", "5.10", []ArgType{ArgMapPointer, ArgPointer}, ArgScalar, false},
+	FnDPath:                      {"long bpf_d_path(struct path *path, char *buf, u32 sz)", "5.10", []ArgType{ArgPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnCopyFromUser:               {"long bpf_copy_from_user(void *dst, u32 size, const void *user_ptr)", "5.10", []ArgType{ArgPointer, ArgScalar, ArgPointer}, ArgScalar, false},
+	FnSnprintfBtf:                {"long bpf_snprintf_btf(char *str, u32 str_size, struct btf_ptr *ptr, u32 btf_ptr_size, u64 flags)", "5.10", []ArgType{ArgPointer, ArgScalar, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSeqPrintfBtf:               {"long bpf_seq_printf_btf(struct seq_file *m, struct btf_ptr *ptr, u32 ptr_size, u64 flags)", "5.10", []ArgType{ArgPointer, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, true},
+	FnSkbCgroupClassid:           {"u64 bpf_skb_cgroup_classid(struct sk_buff *skb)", "5.10", []ArgType{ArgContext}, ArgScalar, false},
+	FnRedirectNeigh:              {"long bpf_redirect_neigh(u32 ifindex, struct bpf_redir_neigh *params, int plen, u64 flags)", "5.10", []ArgType{ArgScalar, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnPerCpuPtr:                  {"void *bpf_per_cpu_ptr(const void *percpu_ptr, u32 cpu)", "5.10", []ArgType{ArgPointer, ArgScalar}, ArgPointer, false},
+	FnThisCpuPtr:                 {"void *bpf_this_cpu_ptr(const void *percpu_ptr)", "5.10", []ArgType{ArgPointer}, ArgPointer, false},
+	FnRedirectPeer:               {"long bpf_redirect_peer(u32 ifindex, u64 flags)", "5.10", []ArgType{ArgScalar, ArgScalar}, ArgScalar, false},
+	FnTaskStorageGet:             {"void *bpf_task_storage_get(struct bpf_map *map, struct task_struct *task, void *value, u64 flags)", "5.11", []ArgType{ArgMapPointer, ArgPointer, ArgPointer, ArgScalar}, ArgPointer, false},
+	FnTaskStorageDelete:          {"long bpf_task_storage_delete(struct bpf_map *map, struct task_struct *task)", "5.11", []ArgType{ArgMapPointer, ArgPointer}, ArgScalar, false},
+	FnGetCurrentTaskBtf:          {"struct task_struct *bpf_get_current_task_btf(void)", "5.11", nil, ArgPointer, true},
+	FnBprmOptsSet:                {"long bpf_bprm_opts_set(struct linux_binprm *bprm, u64 flags)", "5.11", []ArgType{ArgPointer, ArgScalar}, ArgScalar, false},
+	FnKtimeGetCoarseNs:           {"u64 bpf_ktime_get_coarse_ns(void)", "5.11", nil, ArgScalar, false},
+	FnImaInodeHash:               {"long bpf_ima_inode_hash(struct inode *inode, void *dst, u32 size)", "5.11", []ArgType{ArgPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnSockFromFile:               {"struct socket *bpf_sock_from_file(struct file *file)", "5.11", []ArgType{ArgPointer}, ArgPointer, false},
+	FnCheckMtu:                   {"long bpf_check_mtu(void *ctx, u32 ifindex, u32 *mtu_len, s32 len_diff, u64 flags)", "5.12", []ArgType{ArgContext, ArgScalar, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnForEachMapElem:             {"long bpf_for_each_map_elem(struct bpf_map *map, void *callback_fn, void *callback_ctx, u64 flags)", "5.13", []ArgType{ArgMapPointer, ArgPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnSnprintf:                   {"long bpf_snprintf(char *str, u32 str_size, const char *fmt, u64 *data, u32 data_len)", "5.13", []ArgType{ArgPointer, ArgScalar, ArgPointer, ArgPointer, ArgScalar}, ArgScalar, true},
+	FnSysBpf:                     {"long bpf_sys_bpf(u32 cmd, void *attr, u32 attr_size)", "5.14", []ArgType{ArgScalar, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnBtfFindByNameKind:          {"long bpf_btf_find_by_name_kind(char *name, int name_sz, u32 kind, int flags)", "5.14", []ArgType{ArgPointer, ArgScalar, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnSysClose:                   {"long bpf_sys_close(u32 fd)", "5.14", []ArgType{ArgScalar}, ArgScalar, false},
+	FnTimerInit:                  {"long bpf_timer_init(struct bpf_timer *timer, struct bpf_map *map, u64 flags)", "5.15", []ArgType{ArgPointer, ArgMapPointer, ArgScalar}, ArgScalar, true},
+	FnTimerSetCallback:           {"long bpf_timer_set_callback(struct bpf_timer *timer, void *callback_fn)", "5.15", []ArgType{ArgPointer, ArgPointer}, ArgScalar, true},
+	FnTimerStart:                 {"long bpf_timer_start(struct bpf_timer *timer, u64 nsecs, u64 flags)", "5.15", []ArgType{ArgPointer, ArgScalar, ArgScalar}, ArgScalar, true},
+	FnTimerCancel:                {"long bpf_timer_cancel(struct bpf_timer *timer)", "5.15", []ArgType{ArgPointer}, ArgScalar, true},
+	FnGetFuncIp:                  {"u64 bpf_get_func_ip(void *ctx)", "5.15", []ArgType{ArgContext}, ArgScalar, false},
+	FnGetAttachCookie:            {"u64 bpf_get_attach_cookie(void *ctx)", "5.15", []ArgType{ArgContext}, ArgScalar, false},
+	FnTaskPtRegs:                 {"long bpf_task_pt_regs(struct task_struct *task)", "5.15", []ArgType{ArgPointer}, ArgScalar, false},
+	FnGetBranchSnapshot:          {"long bpf_get_branch_snapshot(void *entries, u32 size, u64 flags)", "5.16", []ArgType{ArgPointer, ArgScalar, ArgScalar}, ArgScalar, true},
+	FnTraceVprintk:               {"long bpf_trace_vprintk(const char *fmt, u32 fmt_size, const void *data, u32 data_len)", "5.16", []ArgType{ArgPointer, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, true},
+	FnSkcToUnixSock:              {"struct unix_sock *bpf_skc_to_unix_sock(void *sk)", "5.16", []ArgType{ArgPointer}, ArgPointer, false},
+	FnKallsymsLookupName:         {"long bpf_kallsyms_lookup_name(const char *name, int name_sz, int flags, u64 *res)", "5.16", []ArgType{ArgPointer, ArgScalar, ArgScalar, ArgPointer}, ArgScalar, false},
+	FnFindVma:                    {"long bpf_find_vma(struct task_struct *task, u64 addr, void *callback_fn, void *callback_ctx, u64 flags)", "5.17", []ArgType{ArgPointer, ArgScalar, ArgPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnLoop:                       {"long bpf_loop(u32 nr_loops, void *callback_fn, void *callback_ctx, u64 flags)", "5.17", []ArgType{ArgScalar, ArgPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnStrncmp:                    {"long bpf_strncmp(const char *s1, u32 s1_sz, const char *s2)", "5.17", []ArgType{ArgPointer, ArgScalar, ArgPointer}, ArgScalar, false},
+	FnGetFuncArg:                 {"long bpf_get_func_arg(void *ctx, u32 n, u64 *value)", "5.17", []ArgType{ArgContext, ArgScalar, ArgPointer}, ArgScalar, false},
+	FnGetFuncRet:                 {"long bpf_get_func_ret(void *ctx, u64 *value)", "5.17", []ArgType{ArgContext, ArgPointer}, ArgScalar, false},
+	FnGetFuncArgCnt:              {"long bpf_get_func_arg_cnt(void *ctx)", "5.17", []ArgType{ArgContext}, ArgScalar, false},
+	FnGetRetval:                  {"int bpf_get_retval(void)", "5.18", nil, ArgScalar, false},
+	FnSetRetval:                  {"int bpf_set_retval(int retval)", "5.18", []ArgType{ArgScalar}, ArgScalar, false},
+	FnXdpGetBuffLen:              {"u64 bpf_xdp_get_buff_len(struct xdp_buff *xdp_md)", "5.18", []ArgType{ArgContext}, ArgScalar, false},
+	FnXdpLoadBytes:               {"long bpf_xdp_load_bytes(struct xdp_buff *xdp_md, u32 offset, void *buf, u32 len)", "5.18", []ArgType{ArgContext, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnXdpStoreBytes:              {"long bpf_xdp_store_bytes(struct xdp_buff *xdp_md, u32 offset, void *buf, u32 len)", "5.18", []ArgType{ArgContext, ArgScalar, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnCopyFromUserTask:           {"long bpf_copy_from_user_task(void *dst, u32 size, const void *user_ptr, struct task_struct *tsk, u64 flags)", "5.18", []ArgType{ArgPointer, ArgScalar, ArgPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnSkbSetTstamp:               {"long bpf_skb_set_tstamp(struct sk_buff *skb, u64 tstamp, u32 tstamp_type)", "5.18", []ArgType{ArgContext, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnImaFileHash:                {"long bpf_ima_file_hash(struct file *file, void *dst, u32 size)", "5.18", []ArgType{ArgPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnKptrXchg:                   {"void *bpf_kptr_xchg(void *map_value, void *ptr)", "5.19", []ArgType{ArgPointer, ArgPointer}, ArgPointer, false},
+	FnMapLookupPercpuElem:        {"void *bpf_map_lookup_percpu_elem(struct bpf_map *map, const void *key, u32 cpu)", "5.19", []ArgType{ArgMapPointer, ArgPointer, ArgScalar}, ArgPointer, false},
+	FnSkcToMptcpSock:             {"struct mptcp_sock *bpf_skc_to_mptcp_sock(void *sk)", "5.19", []ArgType{ArgPointer}, ArgPointer, false},
+	FnDynptrFromMem:              {"long bpf_dynptr_from_mem(void *data, u32 size, u64 flags, struct bpf_dynptr *ptr)", "5.19", []ArgType{ArgPointer, ArgScalar, ArgScalar, ArgPointer}, ArgScalar, false},
+	FnRingbufReserveDynptr:       {"long bpf_ringbuf_reserve_dynptr(void *ringbuf, u32 size, u64 flags, struct bpf_dynptr *ptr)", "5.19", []ArgType{ArgPointer, ArgScalar, ArgScalar, ArgPointer}, ArgScalar, false},
+	FnRingbufSubmitDynptr:        {"void bpf_ringbuf_submit_dynptr(struct bpf_dynptr *ptr, u64 flags)", "5.19", []ArgType{ArgPointer, ArgScalar}, ArgUnknown, false},
+	FnRingbufDiscardDynptr:       {"void bpf_ringbuf_discard_dynptr(struct bpf_dynptr *ptr, u64 flags)", "5.19", []ArgType{ArgPointer, ArgScalar}, ArgUnknown, false},
+	FnDynptrRead:                 {"long bpf_dynptr_read(void *dst, u32 len, struct bpf_dynptr *src, u32 offset, u64 flags)", "5.19", []ArgType{ArgPointer, ArgScalar, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnDynptrWrite:                {"long bpf_dynptr_write(struct bpf_dynptr *dst, u32 offset, void *src, u32 len, u64 flags)", "5.19", []ArgType{ArgPointer, ArgScalar, ArgPointer, ArgScalar, ArgScalar}, ArgScalar, false},
+	FnDynptrData:                 {"void *bpf_dynptr_data(struct bpf_dynptr *ptr, u32 offset, u32 len)", "5.19", []ArgType{ArgPointer, ArgScalar, ArgScalar}, ArgPointer, false},
+	FnTcpRawGenSyncookieIpv4:     {"s64 bpf_tcp_raw_gen_syncookie_ipv4(struct iphdr *iph, struct tcphdr *th, u32 th_len)", "6.0", []ArgType{ArgPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnTcpRawGenSyncookieIpv6:     {"s64 bpf_tcp_raw_gen_syncookie_ipv6(struct ipv6hdr *iph, struct tcphdr *th, u32 th_len)", "6.0", []ArgType{ArgPointer, ArgPointer, ArgScalar}, ArgScalar, false},
+	FnTcpRawCheckSyncookieIpv4:   {"long bpf_tcp_raw_check_syncookie_ipv4(struct iphdr *iph, struct tcphdr *th)", "6.0", []ArgType{ArgPointer, ArgPointer}, ArgScalar, false},
+	FnTcpRawCheckSyncookieIpv6:   {"long bpf_tcp_raw_check_syncookie_ipv6(struct ipv6hdr *iph, struct tcphdr *th)", "6.0", []ArgType{ArgPointer, ArgPointer}, ArgScalar, false},
+	FnKtimeGetTaiNs:              {"u64 bpf_ktime_get_tai_ns(void)", "6.1", nil, ArgScalar, false},
+	FnUserRingbufDrain:           {"long bpf_user_ringbuf_drain(struct bpf_map *map, void *callback_fn, void *ctx, u64 flags)", "6.1", []ArgType{ArgMapPointer, ArgPointer, ArgContext, ArgScalar}, ArgScalar, false},
+}
@@ -0,0 +1,99 @@
+package asm
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/sys"
+
+	"golang.org/x/xerrors"
+)
+
+var (
+	funcProbesMu sync.Mutex
+	funcProbes   = make(map[BuiltinFunc]error)
+)
+
+// Available returns an error wrapping ErrNotSupported if the running
+// kernel doesn't provide the function.
+//
+// The kernel is probed by loading a program which calls the function,
+// the result is cached. This doesn't check whether the function may be
+// called from a particular type of program. Probing requires the same
+// privileges as loading a socket filter, other errors like EPERM are
+// returned as is and aren't cached.
+func (fn BuiltinFunc) Available() error {
+	if fn <= FnUnspec || int(fn) >= len(builtinFuncs) {
+		return xerrors.Errorf("unknown function %s: %w", fn, internal.ErrNotSupported)
+	}
+
+	funcProbesMu.Lock()
+	defer funcProbesMu.Unlock()
+
+	if err, ok := funcProbes[fn]; ok {
+		return err
+	}
+
+	err := probeBuiltinFunc(fn)
+	if err != nil && !xerrors.Is(err, internal.ErrNotSupported) {
+		return err
+	}
+
+	funcProbes[fn] = err
+	return err
+}
+
+func probeBuiltinFunc(fn BuiltinFunc) error {
+	insns := Instructions{
+		fn.Call(),
+		Return(),
+	}
+
+	var buf bytes.Buffer
+	if err := insns.Marshal(&buf, internal.NativeEndian); err != nil {
+		return err
+	}
+	bytecode := buf.Bytes()
+
+	logBuf := make([]byte, 4096)
+	fd, err := sys.ProgLoad(&sys.ProgLoadAttr{
+		ProgType: sys.BPF_PROG_TYPE_SOCKET_FILTER,
+		InsnCnt:  uint32(len(bytecode) / InstructionSize),
+		Insns:    sys.NewSlicePointer(bytecode),
+		License:  sys.NewStringPointer("GPL"),
+		LogLevel: 1,
+		LogSize:  uint32(len(logBuf)),
+		LogBuf:   sys.NewSlicePointer(logBuf),
+	})
+	if err == nil {
+		fd.Close()
+		return nil
+	}
+
+	// The verifier checks whether the kernel knows a function before
+	// checking whether the program type may call it, or its arguments.
+	// The former is reported as "invalid func", the latter as
+	// "unknown func" or as a problem with the arguments. The verifier
+	// doesn't run without the necessary privileges.
+	log := internal.CString(logBuf)
+	if xerrors.Is(err, unix.EPERM) || log == "" {
+		return xerrors.Errorf("probe %s: %w", fn, err)
+	}
+
+	if !strings.Contains(log, "invalid func ") {
+		return nil
+	}
+
+	version, err := internal.NewVersion(builtinFuncs[fn].version)
+	if err != nil {
+		return err
+	}
+
+	return &internal.UnsupportedFeatureError{
+		Name:           fn.String(),
+		MinimumVersion: version,
+	}
+}
@@ -0,0 +1,53 @@
+package asm
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	"golang.org/x/xerrors"
+)
+
+func TestBuiltinFuncSignature(t *testing.T) {
+	if sig := FnKtimeGetNs.Signature(); sig != "u64 bpf_ktime_get_ns(void)" {
+		t.Error("Unexpected signature:", sig)
+	}
+
+	if sig := BuiltinFunc(-1).Signature(); sig != "" {
+		t.Error("Unknown function has a signature:", sig)
+	}
+
+	args := FnMapLookupElem.Args()
+	if len(args) != 2 || args[0] != ArgMapPointer || args[1] != ArgPointer {
+		t.Error("Unexpected arguments of FnMapLookupElem:", args)
+	}
+
+	if ret := FnMapLookupElem.Returns(); ret != ArgPointer {
+		t.Error("Unexpected return type of FnMapLookupElem:", ret)
+	}
+
+	if args := FnTailCall.Args(); len(args) != 3 || args[0] != ArgContext {
+		t.Error("Unexpected arguments of FnTailCall:", args)
+	}
+
+	if !FnProbeRead.GPLOnly() || FnMapLookupElem.GPLOnly() {
+		t.Error("GPLOnly is incorrect")
+	}
+
+	for fn := FnMapLookupElem; int(fn) < len(builtinFuncs); fn++ {
+		if _, err := internal.NewVersion(builtinFuncs[fn].version); err != nil {
+			t.Errorf("%s: %s", fn, err)
+		}
+	}
+}
+
+func TestBuiltinFuncAvailable(t *testing.T) {
+	testutils.CheckFeatureTest(t, FnMapLookupElem.Available)
+	testutils.CheckFeatureTest(t, FnRingbufOutput.Available)
+	testutils.CheckFeatureTest(t, FnKtimeGetTaiNs.Available)
+
+	if err := BuiltinFunc(len(builtinFuncs)).Available(); !xerrors.Is(err, internal.ErrNotSupported) {
+		t.Error("Unknown function isn't reported as unsupported:", err)
+	}
+}
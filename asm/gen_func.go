@@ -0,0 +1,317 @@
+//go:build ignore
+// +build ignore
+
+// This program generates func_defs.go from include/uapi/linux/bpf.h,
+// which is also the source of libbpf's bpf_helper_defs.h.
+//
+//	go run gen_func.go path/to/bpf.h
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// releases contains the first helper added by each kernel release.
+//
+// The kernel assigns helper IDs in the order they are merged, so a
+// helper requires the release of the closest preceding entry. The few
+// helpers which were merged out of order are attributed to the later
+// release.
+var releases = []struct {
+	first, version string
+}{
+	{"map_lookup_elem", "3.19"},
+	{"probe_read", "4.1"},
+	{"tail_call", "4.2"},
+	{"get_cgroup_classid", "4.3"},
+	{"redirect", "4.4"},
+	{"skb_load_bytes", "4.5"},
+	{"get_stackid", "4.6"},
+	{"skb_change_proto", "4.8"},
+	{"current_task_under_cgroup", "4.9"},
+	{"get_numa_node_id", "4.10"},
+	{"probe_read_str", "4.11"},
+	{"get_socket_cookie", "4.12"},
+	{"set_hash", "4.13"},
+	{"redirect_map", "4.14"},
+	{"xdp_adjust_meta", "4.15"},
+	{"override_return", "4.16"},
+	{"msg_redirect_map", "4.17"},
+	{"xdp_adjust_tail", "4.18"},
+	{"get_local_storage", "4.19"},
+	{"sk_lookup_tcp", "4.20"},
+	{"msg_pop_data", "5.0"},
+	{"spin_lock", "5.1"},
+	{"skc_lookup_tcp", "5.2"},
+	{"send_signal", "5.3"},
+	{"skb_output", "5.5"},
+	{"read_branch_records", "5.6"},
+	{"get_ns_current_pid_tgid", "5.7"},
+	{"ktime_get_boot_ns", "5.8"},
+	{"skc_to_tcp6_sock", "5.9"},
+	{"load_hdr_opt", "5.10"},
+	{"task_storage_get", "5.11"},
+	{"check_mtu", "5.12"},
+	{"for_each_map_elem", "5.13"},
+	{"sys_bpf", "5.14"},
+	{"timer_init", "5.15"},
+	{"get_branch_snapshot", "5.16"},
+	{"find_vma", "5.17"},
+	{"get_retval", "5.18"},
+	{"kptr_xchg", "5.19"},
+	{"tcp_raw_gen_syncookie_ipv4", "6.0"},
+	{"ktime_get_tai_ns", "6.1"},
+}
+
+// contexts are the types of arguments which receive the context of the
+// program. Arguments named ctx or skb are contexts as well.
+var contexts = map[string]bool{
+	"struct sk_buff *":             true,
+	"struct sk_msg_buff *":         true,
+	"struct xdp_buff *":            true,
+	"struct bpf_sock_ops *":        true,
+	"struct bpf_sock_addr *":       true,
+	"struct bpf_sysctl *":          true,
+	"struct bpf_perf_event_data *": true,
+	"struct sk_reuseport_md *":     true,
+	"struct pt_regs *":             true,
+}
+
+// gplOnly are the helpers whose bpf_func_proto sets gpl_only. The
+// kernel refuses to load programs calling them unless their license is
+// GPL compatible. bpf.h doesn't contain this information.
+var gplOnly = map[string]bool{
+	"probe_read":            true,
+	"probe_read_str":        true,
+	"probe_read_user":       true,
+	"probe_read_user_str":   true,
+	"probe_read_kernel":     true,
+	"probe_read_kernel_str": true,
+	"probe_write_user":      true,
+	"trace_printk":          true,
+	"trace_vprintk":         true,
+	"get_current_task":      true,
+	"get_current_task_btf":  true,
+	"perf_event_read":       true,
+	"perf_event_read_value": true,
+	"perf_event_output":     true,
+	"perf_prog_read_value":  true,
+	"get_stackid":           true,
+	"get_stack":             true,
+	"override_return":       true,
+	"skb_output":            true,
+	"xdp_output":            true,
+	"read_branch_records":   true,
+	"get_branch_snapshot":   true,
+	"seq_printf":            true,
+	"seq_write":             true,
+	"seq_printf_btf":        true,
+	"snprintf":              true,
+	"timer_init":            true,
+	"timer_set_callback":    true,
+	"timer_start":           true,
+	"timer_cancel":          true,
+}
+
+var (
+	// FN(map_lookup_elem),	\ or FN(map_lookup_elem, 1, ##ctx)	\
+	fnLine = regexp.MustCompile(`^\s*FN\((\w+)[,)]`)
+	//  * void *bpf_map_lookup_elem(struct bpf_map *map, const void *key)
+	signatureLine = regexp.MustCompile(`^ \* ([a-z].*\bbpf_(\w+)\(.*\))$`)
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatalf("Usage: %s path/to/bpf.h", os.Args[0])
+	}
+
+	names, signatures, err := parseHeader(os.Args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	versions := make(map[string]string)
+	for _, release := range releases {
+		versions[release.first] = release.version
+	}
+
+	var consts, infos bytes.Buffer
+	version := ""
+	for i, name := range names {
+		if v, ok := versions[name]; ok {
+			version = v
+		}
+
+		goName := "Fn" + camelCase(name)
+		if i == 0 {
+			fmt.Fprintf(&consts, "%s BuiltinFunc = iota\n", goName)
+			fmt.Fprintf(&infos, "%s: {},\n", goName)
+			continue
+		}
+
+		fmt.Fprintf(&consts, "%s\n", goName)
+		ret, args, err := parseSignature(signatures[name])
+		if err != nil {
+			log.Fatalf("%s: %s", name, err)
+		}
+
+		argList := "nil"
+		if len(args) > 0 {
+			argList = "[]ArgType{" + strings.Join(args, ", ") + "}"
+		}
+
+		fmt.Fprintf(&infos, "%s: {%q, %q, %s, %s, %t},\n", goName, signatures[name], version, argList, ret, gplOnly[name])
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintln(&out, "// Code generated by gen_func.go; DO NOT EDIT.")
+	fmt.Fprintln(&out)
+	fmt.Fprintln(&out, "package asm")
+	fmt.Fprintln(&out)
+	fmt.Fprintln(&out, "// eBPF built-in functions")
+	fmt.Fprintf(&out, "const (\n%s)\n\n", consts.String())
+	fmt.Fprintf(&out, "var builtinFuncs = [...]builtinFunc{\n%s}\n", infos.String())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile("func_defs.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseHeader returns the helpers in the order of their IDs, and their
+// signatures from the documentation of union bpf_attr.
+func parseHeader(path string) ([]string, map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var (
+		names      []string
+		signatures = make(map[string]string)
+	)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := signatureLine.FindStringSubmatch(line); match != nil {
+			if signatures[match[2]] == "" {
+				signatures[match[2]] = match[1]
+			}
+			continue
+		}
+
+		if match := fnLine.FindStringSubmatch(line); match != nil {
+			names = append(names, match[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(names) == 0 || names[0] != "unspec" {
+		return nil, nil, fmt.Errorf("%s: no list of helpers found", path)
+	}
+
+	for _, name := range names[1:] {
+		if signatures[name] == "" {
+			return nil, nil, fmt.Errorf("%s: missing signature for %s", path, name)
+		}
+	}
+
+	for name := range gplOnly {
+		found := false
+		for _, n := range names {
+			found = found || n == name
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("%s: missing helper %s", path, name)
+		}
+	}
+
+	for _, release := range releases {
+		found := false
+		for _, name := range names {
+			found = found || name == release.first
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("%s: missing helper %s", path, release.first)
+		}
+	}
+
+	return names, signatures, nil
+}
+
+// parseSignature returns the ArgType of the return value and of the
+// fixed arguments of a function.
+func parseSignature(sig string) (string, []string, error) {
+	open := strings.Index(sig, "bpf_")
+	params := strings.Index(sig, "(")
+	if open == -1 || params == -1 || !strings.HasSuffix(sig, ")") {
+		return "", nil, fmt.Errorf("can't parse signature %q", sig)
+	}
+
+	ret := argType(sig[:open])
+
+	var args []string
+	for _, param := range strings.Split(sig[params+1:len(sig)-1], ",") {
+		param = strings.TrimSpace(param)
+		if param == "void" || param == "..." {
+			break
+		}
+
+		// Split "const void *key" into "const void *" and "key".
+		cut := strings.LastIndexAny(param, " *") + 1
+		typ, name := strings.TrimSpace(param[:cut]), param[cut:]
+
+		if name == "ctx" || name == "skb" || contexts[typ] {
+			args = append(args, "ArgContext")
+			continue
+		}
+
+		args = append(args, argType(typ))
+	}
+
+	if len(args) > 5 {
+		return "", nil, fmt.Errorf("signature %q has more than five arguments", sig)
+	}
+
+	return ret, args, nil
+}
+
+func argType(typ string) string {
+	typ = strings.TrimSpace(typ)
+	switch {
+	case typ == "void":
+		return "ArgUnknown"
+	case strings.HasPrefix(typ, "struct bpf_map ") && strings.HasSuffix(typ, "*"):
+		return "ArgMapPointer"
+	case strings.HasSuffix(typ, "*"):
+		return "ArgPointer"
+	default:
+		return "ArgScalar"
+	}
+}
+
+// camelCase turns map_lookup_elem into MapLookupElem.
+func camelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i, part := range parts {
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return strings.Join(parts, "")
+}
@@ -3,7 +3,9 @@ package asm
 import (
 	"encoding/binary"
 	"fmt"
+	"hash"
 	"io"
+	"io/ioutil"
 	"math"
 	"strings"
 
@@ -22,6 +24,10 @@ type Instruction struct {
 	Constant  int64
 	Reference string
 	Symbol    string
+
+	// Metadata is ignored when marshaling. See Source and
+	// SectionOffset for examples.
+	Metadata Metadata
 }
 
 // Sym creates a symbol.
@@ -32,37 +38,75 @@ func (ins Instruction) Sym(name string) Instruction {
 
 // Unmarshal decodes a BPF instruction.
 func (ins *Instruction) Unmarshal(r io.Reader, bo binary.ByteOrder) (uint64, error) {
-	var bi bpfInstruction
-	err := binary.Read(r, bo, &bi)
-	if err != nil {
+	var buf [2 * InstructionSize]byte
+	if _, err := io.ReadFull(r, buf[:InstructionSize]); err != nil {
 		return 0, err
 	}
 
-	ins.OpCode = bi.OpCode
-	ins.Dst = bi.Registers.Dst()
-	ins.Src = bi.Registers.Src()
-	ins.Offset = bi.Offset
-	ins.Constant = int64(bi.Constant)
+	if !OpCode(buf[0]).isDWordLoad() {
+		return ins.unmarshalFrom(buf[:InstructionSize], bo)
+	}
+
+	if _, err := io.ReadFull(r, buf[InstructionSize:]); err != nil {
+		// No Wrap, to avoid io.EOF clash
+		return 0, xerrors.New("64bit immediate is missing second half")
+	}
 
-	if !bi.OpCode.isDWordLoad() {
+	return ins.unmarshalFrom(buf[:], bo)
+}
+
+// unmarshalFrom decodes the instruction at the start of buf.
+func (ins *Instruction) unmarshalFrom(buf []byte, bo binary.ByteOrder) (uint64, error) {
+	if len(buf) < InstructionSize {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	ins.OpCode = OpCode(buf[0])
+	ins.Dst = bpfRegisters(buf[1]).Dst()
+	ins.Src = bpfRegisters(buf[1]).Src()
+	ins.Offset = int16(bo.Uint16(buf[2:4]))
+	ins.Constant = int64(int32(bo.Uint32(buf[4:8])))
+
+	if !ins.OpCode.isDWordLoad() {
 		return InstructionSize, nil
 	}
 
-	var bi2 bpfInstruction
-	if err := binary.Read(r, bo, &bi2); err != nil {
-		// No Wrap, to avoid io.EOF clash
+	if len(buf) < 2*InstructionSize {
 		return 0, xerrors.New("64bit immediate is missing second half")
 	}
-	if bi2.OpCode != 0 || bi2.Offset != 0 || bi2.Registers != 0 {
+
+	hi := buf[InstructionSize : 2*InstructionSize]
+	if hi[0] != 0 || hi[1] != 0 || bo.Uint16(hi[2:4]) != 0 {
 		return 0, xerrors.New("64bit immediate has non-zero fields")
 	}
-	ins.Constant = int64(uint64(uint32(bi2.Constant))<<32 | uint64(uint32(bi.Constant)))
+	ins.Constant = int64(uint64(bo.Uint32(hi[4:8]))<<32 | uint64(uint32(ins.Constant)))
 
 	return 2 * InstructionSize, nil
 }
 
 // Marshal encodes a BPF instruction.
 func (ins Instruction) Marshal(w io.Writer, bo binary.ByteOrder) (uint64, error) {
+	var buf [2 * InstructionSize]byte
+	n, err := ins.marshalTo(buf[:], bo)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := w.Write(buf[:n]); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// Size returns the number of bytes ins occupies in binary form.
+func (ins Instruction) Size() uint64 {
+	return uint64(InstructionSize * ins.OpCode.marshalledInstructions())
+}
+
+// marshalTo encodes ins into buf, which must be at least ins.Size()
+// bytes long.
+func (ins Instruction) marshalTo(buf []byte, bo binary.ByteOrder) (uint64, error) {
 	if ins.OpCode == InvalidOpCode {
 		return 0, xerrors.New("invalid opcode")
 	}
@@ -75,28 +119,19 @@ func (ins Instruction) Marshal(w io.Writer, bo binary.ByteOrder) (uint64, error)
 		cons = int32(uint32(ins.Constant))
 	}
 
-	bpfi := bpfInstruction{
-		ins.OpCode,
-		newBPFRegisters(ins.Dst, ins.Src),
-		ins.Offset,
-		cons,
-	}
-
-	if err := binary.Write(w, bo, &bpfi); err != nil {
-		return 0, err
-	}
+	buf[0] = byte(ins.OpCode)
+	buf[1] = byte(newBPFRegisters(ins.Dst, ins.Src))
+	bo.PutUint16(buf[2:4], uint16(ins.Offset))
+	bo.PutUint32(buf[4:8], uint32(cons))
 
 	if !isDWordLoad {
 		return InstructionSize, nil
 	}
 
-	bpfi = bpfInstruction{
-		Constant: int32(ins.Constant >> 32),
-	}
-
-	if err := binary.Write(w, bo, &bpfi); err != nil {
-		return 0, err
-	}
+	buf = buf[InstructionSize:]
+	buf[0], buf[1] = 0, 0
+	bo.PutUint16(buf[2:4], 0)
+	bo.PutUint32(buf[4:8], uint32(ins.Constant>>32))
 
 	return 2 * InstructionSize, nil
 }
@@ -117,6 +152,7 @@ func (ins *Instruction) RewriteMapPtr(fd int) error {
 	offset := uint64(ins.Constant) & (math.MaxUint32 << 32)
 	rawFd := uint64(uint32(fd))
 	ins.Constant = int64(offset | rawFd)
+	ins.Metadata.Set(relocationMeta{}, nil)
 	return nil
 }
 
@@ -206,7 +242,7 @@ func (ins Instruction) Format(f fmt.State, c rune) {
 			fmt.Fprintf(f, "src: %s", ins.Src)
 		}
 
-	case JumpClass:
+	case JumpClass, Jump32Class:
 		switch jop := op.JumpOp(); jop {
 		case Call:
 			if ins.Src == PseudoCall {
@@ -304,14 +340,55 @@ func (insns Instructions) ReferenceOffsets() map[string][]int {
 	return offsets
 }
 
+// Iterate allows iterating a BPF program while keeping track of
+// various offsets.
+//
+// Modifying the instruction slice will lead to undefined behaviour.
+func (insns Instructions) Iterate() *InstructionIterator {
+	return &InstructionIterator{insns: insns}
+}
+
+// InstructionIterator iterates over a BPF program.
+type InstructionIterator struct {
+	insns Instructions
+	// The instruction in question.
+	Ins *Instruction
+	// The index of the instruction in the original instruction slice.
+	Index int
+	// The offset of the instruction in raw BPF instructions. This accounts
+	// for double-wide instructions.
+	Offset RawInstructionOffset
+}
+
+// Next returns true as long as there are any instructions remaining.
+func (iter *InstructionIterator) Next() bool {
+	if len(iter.insns) == 0 {
+		return false
+	}
+
+	if iter.Ins != nil {
+		iter.Index++
+		iter.Offset += RawInstructionOffset(iter.Ins.OpCode.marshalledInstructions())
+	}
+	iter.Ins = &iter.insns[0]
+	iter.insns = iter.insns[1:]
+	return true
+}
+
+// RawInstructionOffset is an offset in units of raw BPF instructions.
+type RawInstructionOffset uint64
+
+// Bytes returns the offset of an instruction in bytes.
+func (rio RawInstructionOffset) Bytes() uint64 {
+	return uint64(rio) * InstructionSize
+}
+
 func (insns Instructions) marshalledOffsets() (map[string]int, error) {
 	symbols := make(map[string]int)
 
-	marshalledPos := 0
-	for _, ins := range insns {
-		currentPos := marshalledPos
-		marshalledPos += ins.OpCode.marshalledInstructions()
-
+	iter := insns.Iterate()
+	for iter.Next() {
+		ins := iter.Ins
 		if ins.Symbol == "" {
 			continue
 		}
@@ -320,7 +397,7 @@ func (insns Instructions) marshalledOffsets() (map[string]int, error) {
 			return nil, xerrors.Errorf("duplicate symbol %s", ins.Symbol)
 		}
 
-		symbols[ins.Symbol] = currentPos
+		symbols[ins.Symbol] = int(iter.Offset)
 	}
 
 	return symbols, nil
@@ -372,27 +449,152 @@ func (insns Instructions) Format(f fmt.State, c rune) {
 	}
 	offsetWidth := int(math.Ceil(math.Log10(float64(highestOffset))))
 
-	offset := 0
-	for _, ins := range insns {
+	lastSource := ""
+	iter := insns.Iterate()
+	for iter.Next() {
+		ins := iter.Ins
 		if ins.Symbol != "" {
 			fmt.Fprintf(f, "%s%s:\n", symIndent, ins.Symbol)
 		}
-		fmt.Fprintf(f, "%s%*d: %v\n", indent, offsetWidth, offset, ins)
-		offset += ins.OpCode.marshalledInstructions()
+		if src := ins.Source(); src != nil {
+			line := strings.TrimSpace(src.String())
+			if line != lastSource {
+				fmt.Fprintf(f, "%s; %s\n", indent, line)
+				lastSource = line
+			}
+		}
+		fmt.Fprintf(f, "%s%*d: %v\n", indent, offsetWidth, iter.Offset, *ins)
 	}
 
 	return
 }
 
+// Unmarshal decodes a BPF program from the kernel format.
+//
+// Reads instructions until r returns io.EOF. Jumps and calls are
+// not resolved into references.
+func (insns *Instructions) Unmarshal(r io.Reader, bo binary.ByteOrder) error {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	dec := NewDecoder(buf, bo)
+	for dec.Next() {
+		*insns = append(*insns, dec.Ins)
+	}
+	return dec.Err()
+}
+
+// Hash calculates a hash of the instruction stream, which matches the
+// kernel's program tag when used with the kernel's hash function and
+// the native byte order.
+//
+// References to maps are ignored, since the kernel doesn't include them
+// when calculating the tag.
+func (insns Instructions) Hash(h hash.Hash, bo binary.ByteOrder) error {
+	cpy := make(Instructions, len(insns))
+	copy(cpy, insns)
+	for i := range cpy {
+		if cpy[i].isLoadFromMap() {
+			cpy[i].Constant = 0
+		}
+	}
+
+	buf := make([]byte, cpy.Size())
+	if err := cpy.MarshalTo(buf, bo); err != nil {
+		return err
+	}
+
+	_, err := h.Write(buf)
+	return err
+}
+
+// Decoder decodes the instructions of a program image one by one.
+//
+// It doesn't copy the image, which makes it cheaper than
+// Instructions.Unmarshal for large programs.
+type Decoder struct {
+	buf  []byte
+	bo   binary.ByteOrder
+	next uint64
+	err  error
+
+	// The most recently decoded instruction.
+	Ins Instruction
+	// The offset of Ins in bytes.
+	Offset uint64
+}
+
+// NewDecoder creates a Decoder for an image in byte order bo.
+func NewDecoder(buf []byte, bo binary.ByteOrder) *Decoder {
+	return &Decoder{buf: buf, bo: bo}
+}
+
+// Next decodes the next instruction.
+//
+// Returns false at the end of the image or if an instruction is
+// invalid. Check Err to tell the two apart.
+func (dec *Decoder) Next() bool {
+	if dec.err != nil || dec.next >= uint64(len(dec.buf)) {
+		return false
+	}
+
+	dec.Ins = Instruction{}
+	n, err := dec.Ins.unmarshalFrom(dec.buf[dec.next:], dec.bo)
+	if err != nil {
+		dec.err = xerrors.Errorf("offset %d: %w", dec.next, err)
+		return false
+	}
+
+	dec.Offset = dec.next
+	dec.next += n
+	return true
+}
+
+// Err returns the error which stopped decoding, if any.
+func (dec *Decoder) Err() error {
+	return dec.err
+}
+
+// Size returns the number of bytes insns occupies in binary form.
+func (insns Instructions) Size() int {
+	var size uint64
+	for _, ins := range insns {
+		size += ins.Size()
+	}
+	return int(size)
+}
+
 // Marshal encodes a BPF program into the kernel format.
 func (insns Instructions) Marshal(w io.Writer, bo binary.ByteOrder) error {
+	buf := make([]byte, insns.Size())
+	if err := insns.MarshalTo(buf, bo); err != nil {
+		return err
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// MarshalTo encodes a BPF program into the kernel format.
+//
+// buf must be at least insns.Size() bytes long. Prefer this over Marshal
+// for large programs, since it encodes directly into buf.
+func (insns Instructions) MarshalTo(buf []byte, bo binary.ByteOrder) error {
+	if size := insns.Size(); len(buf) < size {
+		return xerrors.Errorf("buffer of %d bytes is too small, need %d", len(buf), size)
+	}
+
 	absoluteOffsets, err := insns.marshalledOffsets()
 	if err != nil {
 		return err
 	}
 
-	num := 0
-	for i, ins := range insns {
+	iter := insns.Iterate()
+	for iter.Next() {
+		ins := *iter.Ins
+		i, num := iter.Index, int(iter.Offset)
 		switch {
 		case ins.OpCode.JumpOp() == Call && ins.Constant == -1:
 			// Rewrite bpf to bpf call
@@ -403,7 +605,7 @@ func (insns Instructions) Marshal(w io.Writer, bo binary.ByteOrder) error {
 
 			ins.Constant = int64(offset - num - 1)
 
-		case ins.OpCode.Class() == JumpClass && ins.Offset == -1:
+		case ins.OpCode.Class().isJump() && ins.Offset == -1:
 			// Rewrite jump to label
 			offset, ok := absoluteOffsets[ins.Reference]
 			if !ok {
@@ -413,23 +615,13 @@ func (insns Instructions) Marshal(w io.Writer, bo binary.ByteOrder) error {
 			ins.Offset = int16(offset - num - 1)
 		}
 
-		n, err := ins.Marshal(w, bo)
-		if err != nil {
+		if _, err := ins.marshalTo(buf[iter.Offset.Bytes():], bo); err != nil {
 			return xerrors.Errorf("instruction %d: %w", i, err)
 		}
-
-		num += int(n / InstructionSize)
 	}
 	return nil
 }
 
-type bpfInstruction struct {
-	OpCode    OpCode
-	Registers bpfRegisters
-	Offset    int16
-	Constant  int32
-}
-
 type bpfRegisters uint8
 
 func newBPFRegisters(dst, src Register) bpfRegisters {
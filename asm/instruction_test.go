@@ -2,6 +2,7 @@ package asm
 
 import (
 	"bytes"
+	"crypto/sha1"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
@@ -46,6 +47,174 @@ func TestWrite64bitImmediate(t *testing.T) {
 	}
 }
 
+func TestInstructionsMarshalTo(t *testing.T) {
+	insns := Instructions{
+		Mov.Imm(R0, 0),
+		LoadImm(R0, math.MinInt32-1, DWord),
+		Return(),
+	}
+
+	if size := insns.Size(); size != 4*InstructionSize {
+		t.Fatalf("Expected %d bytes, got %d", 4*InstructionSize, size)
+	}
+
+	for _, bo := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		var want bytes.Buffer
+		for _, ins := range insns {
+			if _, err := ins.Marshal(&want, bo); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		have := make([]byte, insns.Size())
+		if err := insns.MarshalTo(have, bo); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(have, want.Bytes()) {
+			t.Errorf("%s: MarshalTo doesn't match Marshal:\n%s", bo, hex.Dump(have))
+		}
+	}
+
+	if err := insns.MarshalTo(make([]byte, InstructionSize), binary.LittleEndian); err == nil {
+		t.Error("MarshalTo accepts a buffer which is too small")
+	}
+}
+
+func BenchmarkInstructionsMarshal(b *testing.B) {
+	insns := make(Instructions, 0, 4096)
+	for i := 0; i < cap(insns)-1; i++ {
+		insns = append(insns, Mov.Imm(R0, int32(i)))
+	}
+	insns = append(insns, Return())
+
+	buf := make([]byte, insns.Size())
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := insns.MarshalTo(buf, binary.LittleEndian); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestInstructionsUnmarshal(t *testing.T) {
+	want := Instructions{
+		LoadImm(R0, math.MinInt32-1, DWord),
+		Mov.Imm(R1, 1),
+		Return(),
+	}
+
+	var buf bytes.Buffer
+	if err := want.Marshal(&buf, binary.LittleEndian); err != nil {
+		t.Fatal(err)
+	}
+
+	var have Instructions
+	if err := have.Unmarshal(&buf, binary.LittleEndian); err != nil {
+		t.Fatal(err)
+	}
+
+	if have.String() != want.String() {
+		t.Errorf("Unmarshaled instructions don't match:\n%s", have)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	insns := Instructions{
+		Mov.Imm(R1, -1),
+		LoadImm(R0, math.MinInt32-1, DWord),
+		JSGT.Imm(R0, -1, "foo"),
+		Return().Sym("foo"),
+	}
+
+	buf := make([]byte, insns.Size())
+	if err := insns.MarshalTo(buf, binary.BigEndian); err != nil {
+		t.Fatal(err)
+	}
+
+	var offsets []uint64
+	dec := NewDecoder(buf, binary.BigEndian)
+	for i := 0; dec.Next(); i++ {
+		want := insns[i]
+		want.Reference, want.Symbol = "", ""
+		if i == 2 {
+			want.Offset = 0
+		}
+		if fmt.Sprint(dec.Ins) != fmt.Sprint(want) {
+			t.Errorf("Instruction %d: expected %v, got %v", i, want, dec.Ins)
+		}
+		offsets = append(offsets, dec.Offset)
+	}
+	if err := dec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(offsets) != "[0 8 24 32]" {
+		t.Error("Unexpected offsets", offsets)
+	}
+
+	dec = NewDecoder(test64bitImmProg[:InstructionSize], binary.LittleEndian)
+	if dec.Next() {
+		t.Error("Decoder accepts truncated 64bit immediate")
+	}
+	if dec.Err() == nil {
+		t.Error("Decoder doesn't return an error for truncated 64bit immediate")
+	}
+}
+
+func BenchmarkInstructionsUnmarshal(b *testing.B) {
+	insns := make(Instructions, 0, 4096)
+	for i := 0; i < cap(insns)-1; i++ {
+		insns = append(insns, Mov.Imm(R0, int32(i)))
+	}
+	insns = append(insns, Return())
+
+	buf := make([]byte, insns.Size())
+	if err := insns.MarshalTo(buf, binary.LittleEndian); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		dec := NewDecoder(buf, binary.LittleEndian)
+		for dec.Next() {
+		}
+		if err := dec.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestInstructionsHash(t *testing.T) {
+	hash := func(insns Instructions) string {
+		t.Helper()
+
+		h := sha1.New()
+		if err := insns.Hash(h, binary.LittleEndian); err != nil {
+			t.Fatal(err)
+		}
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	a := hash(Instructions{LoadMapPtr(R1, 3), Return()})
+	b := hash(Instructions{LoadMapPtr(R1, 4), Return()})
+	c := hash(Instructions{LoadMapValue(R1, 4, 8), Return()})
+	d := hash(Instructions{LoadImm(R1, 3, DWord), Return()})
+
+	if a != b {
+		t.Error("Hash depends on map fd")
+	}
+	if a == c {
+		t.Error("Hash doesn't distinguish map pointers and values")
+	}
+	if a == d {
+		t.Error("Hash doesn't distinguish map pointers and immediates")
+	}
+}
+
 func TestSignedJump(t *testing.T) {
 	insns := Instructions{
 		JSGT.Imm(R0, -1, "foo"),
@@ -179,3 +348,20 @@ func ExampleInstructions_Format() {
 	// 	1: LdImmDW dst: r0 imm: 42
 	// 	3: Exit
 }
+
+func ExampleInstructions_Iterate() {
+	insns := Instructions{
+		FnMapLookupElem.Call().Sym("my_func"),
+		LoadImm(R0, 42, DWord),
+		Return(),
+	}
+
+	iter := insns.Iterate()
+	for iter.Next() {
+		fmt.Printf("index: %d, offset: %d, bytes: %d\n", iter.Index, iter.Offset, iter.Offset.Bytes())
+	}
+
+	// Output: index: 0, offset: 0, bytes: 0
+	// index: 1, offset: 1, bytes: 8
+	// index: 2, offset: 3, bytes: 24
+}
@@ -90,6 +90,40 @@ func (op JumpOp) Reg(dst, src Register, label string) Instruction {
 	}
 }
 
+// Op32 returns the OpCode for a jump which compares the lower 32 bit of
+// its operands.
+func (op JumpOp) Op32(source Source) OpCode {
+	if op == Exit || op == Call || op == Ja {
+		return InvalidOpCode
+	}
+
+	return OpCode(Jump32Class).SetJumpOp(op).SetSource(source)
+}
+
+// Imm32 compares the lower 32 bit of dst to value, and adjusts PC by
+// offset if the condition is fulfilled.
+func (op JumpOp) Imm32(dst Register, value int32, label string) Instruction {
+	return Instruction{
+		OpCode:    op.Op32(ImmSource),
+		Dst:       dst,
+		Offset:    -1,
+		Constant:  int64(value),
+		Reference: label,
+	}
+}
+
+// Reg32 compares the lower 32 bit of dst and src, and adjusts PC by
+// offset if the condition is fulfilled.
+func (op JumpOp) Reg32(dst, src Register, label string) Instruction {
+	return Instruction{
+		OpCode:    op.Op32(RegSource),
+		Dst:       dst,
+		Src:       src,
+		Offset:    -1,
+		Reference: label,
+	}
+}
+
 // Label adjusts PC to the address of the label.
 func (op JumpOp) Label(label string) Instruction {
 	if op == Call {
@@ -0,0 +1,100 @@
+package asm
+
+import "fmt"
+
+// Metadata contains metadata about an instruction.
+//
+// Metadata is a persistent list of key value pairs. Copying an Instruction
+// shares its Metadata, and modifying the copy doesn't affect the original.
+// The zero value is ready to use.
+type Metadata struct {
+	head *metaElement
+}
+
+type metaElement struct {
+	next       *metaElement
+	key, value interface{}
+}
+
+// Get returns the value of the given key, or nil if the key is not present.
+func (m *Metadata) Get(key interface{}) interface{} {
+	for e := m.head; e != nil; e = e.next {
+		if e.key == key {
+			return e.value
+		}
+	}
+	return nil
+}
+
+// Set a key to a value.
+//
+// Setting a value to nil removes the key. Keys must be comparable, and
+// should be of an unexported type to avoid collisions, similar to
+// context.Context.
+func (m *Metadata) Set(key, value interface{}) {
+	if value == nil && m.Get(key) == nil {
+		return
+	}
+
+	// Copy elements up to the existing key, so that other instructions
+	// sharing the list are unaffected.
+	var (
+		head *metaElement
+		tail **metaElement = &head
+	)
+	for e := m.head; e != nil; e = e.next {
+		if e.key == key {
+			*tail = e.next
+			break
+		}
+
+		*tail = &metaElement{key: e.key, value: e.value}
+		tail = &(*tail).next
+	}
+
+	if value != nil {
+		head = &metaElement{head, key, value}
+	}
+
+	m.head = head
+}
+
+// Keys returns all keys in the order they were last set, most recent first.
+func (m *Metadata) Keys() []interface{} {
+	var keys []interface{}
+	for e := m.head; e != nil; e = e.next {
+		keys = append(keys, e.key)
+	}
+	return keys
+}
+
+type sourceMeta struct{}
+
+// Source returns the source code location of the instruction, if known.
+func (ins Instruction) Source() fmt.Stringer {
+	src, _ := ins.Metadata.Get(sourceMeta{}).(fmt.Stringer)
+	return src
+}
+
+// WithSource returns a copy of the instruction with the given source
+// code location.
+func (ins Instruction) WithSource(src fmt.Stringer) Instruction {
+	ins.Metadata.Set(sourceMeta{}, src)
+	return ins
+}
+
+type sectionOffsetMeta struct{}
+
+// SectionOffset returns the offset in bytes of the instruction in the ELF
+// section it was read from.
+func (ins Instruction) SectionOffset() (uint64, bool) {
+	off, ok := ins.Metadata.Get(sectionOffsetMeta{}).(uint64)
+	return off, ok
+}
+
+// WithSectionOffset returns a copy of the instruction with the given
+// ELF section offset.
+func (ins Instruction) WithSectionOffset(offset uint64) Instruction {
+	ins.Metadata.Set(sectionOffsetMeta{}, offset)
+	return ins
+}
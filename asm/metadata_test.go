@@ -0,0 +1,73 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testKey struct{}
+
+func TestMetadata(t *testing.T) {
+	var m Metadata
+	if m.Get(testKey{}) != nil {
+		t.Fatal("Zero value contains a key")
+	}
+
+	m.Set(testKey{}, 1)
+	m.Set("other", 2)
+
+	cpy := m
+	cpy.Set(testKey{}, 3)
+	cpy.Set("other", nil)
+
+	if v := m.Get(testKey{}); v != 1 {
+		t.Error("Modifying a copy changes the original:", v)
+	}
+	if v := m.Get("other"); v != 2 {
+		t.Error("Removing a key from a copy changes the original:", v)
+	}
+
+	if v := cpy.Get(testKey{}); v != 3 {
+		t.Error("Expected 3, got", v)
+	}
+	if v := cpy.Get("other"); v != nil {
+		t.Error("Key wasn't removed:", v)
+	}
+
+	if keys := cpy.Keys(); len(keys) != 1 {
+		t.Error("Expected one key, got", keys)
+	}
+}
+
+type testSource string
+
+func (ts testSource) String() string { return string(ts) }
+
+func TestInstructionSource(t *testing.T) {
+	ins := Mov.Imm(R0, 0).WithSource(testSource("return 0;"))
+	ins2 := ins
+	ins2.Constant = 1
+
+	if ins2.Source() != testSource("return 0;") {
+		t.Error("Source doesn't survive a copy")
+	}
+
+	insns := Instructions{
+		ins.WithSectionOffset(8),
+		ins2,
+		Return(),
+	}
+
+	if off, ok := insns[0].SectionOffset(); !ok || off != 8 {
+		t.Error("Expected section offset 8, got", off, ok)
+	}
+	if _, ok := insns[1].SectionOffset(); ok {
+		t.Error("Setting section offset on a copy modifies the original")
+	}
+
+	out := fmt.Sprint(insns)
+	if n := strings.Count(out, "; return 0;"); n != 1 {
+		t.Errorf("Expected source line to be printed once, got %d times:\n%s", n, out)
+	}
+}
@@ -38,15 +38,21 @@ const (
 	ALUClass Class = 0x04
 	// JumpClass jump operators
 	JumpClass Class = 0x05
+	// Jump32Class jump operators comparing the lower 32 bits
+	Jump32Class Class = 0x06
 	// ALU64Class arithmetic in 64 bit mode
 	ALU64Class Class = 0x07
 )
 
+func (cls Class) isJump() bool {
+	return cls == JumpClass || cls == Jump32Class
+}
+
 func (cls Class) encoding() encoding {
 	switch cls {
 	case LdClass, LdXClass, StClass, StXClass:
 		return loadOrStore
-	case ALU64Class, ALUClass, JumpClass:
+	case ALU64Class, ALUClass, JumpClass, Jump32Class:
 		return jumpOrALU
 	default:
 		return unknownEncoding
@@ -102,7 +108,7 @@ func (op OpCode) Size() Size {
 
 // Source returns the source for branch and ALU operations.
 func (op OpCode) Source() Source {
-	if op.Class().encoding() != jumpOrALU || op.ALUOp() == Swap {
+	if op.Class().encoding() != jumpOrALU || op.isSwap() {
 		return InvalidSource
 	}
 	return Source(op & sourceMask)
@@ -118,12 +124,19 @@ func (op OpCode) ALUOp() ALUOp {
 
 // Endianness returns the Endianness for a byte swap instruction.
 func (op OpCode) Endianness() Endianness {
-	if op.ALUOp() != Swap {
+	if !op.isSwap() {
 		return InvalidEndian
 	}
 	return Endianness(op & endianMask)
 }
 
+// isSwap returns true for byte swap instructions. Swap shares its value
+// with JSLE, so the class has to be checked as well.
+func (op OpCode) isSwap() bool {
+	class := op.Class()
+	return (class == ALUClass || class == ALU64Class) && op.ALUOp() == Swap
+}
+
 // JumpOp returns the JumpOp.
 func (op OpCode) JumpOp() JumpOp {
 	if op.Class().encoding() != jumpOrALU {
@@ -177,7 +190,7 @@ func (op OpCode) SetALUOp(alu ALUOp) OpCode {
 //
 // Returns InvalidOpCode if op is of the wrong class.
 func (op OpCode) SetJumpOp(jump JumpOp) OpCode {
-	if op.Class() != JumpClass || !valid(OpCode(jump), jumpMask) {
+	if !op.Class().isJump() || !valid(OpCode(jump), jumpMask) {
 		return InvalidOpCode
 	}
 	return (op & ^jumpMask) | OpCode(jump)
@@ -218,14 +231,18 @@ func (op OpCode) String() string {
 			f.WriteString(strings.TrimSuffix(op.Source().String(), "Source"))
 		}
 
-	case JumpClass:
+	case JumpClass, Jump32Class:
 		f.WriteString(op.JumpOp().String())
 		if jop := op.JumpOp(); jop != Exit && jop != Call {
+			if class == Jump32Class {
+				f.WriteString("32")
+			}
+
 			f.WriteString(strings.TrimSuffix(op.Source().String(), "Source"))
 		}
 
 	default:
-		fmt.Fprintf(&f, "%#x", op)
+		fmt.Fprintf(&f, "%#x", uint8(op))
 	}
 
 	return f.String()
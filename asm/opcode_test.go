@@ -0,0 +1,110 @@
+package asm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestOpCodeRoundTrip(t *testing.T) {
+	var insns []Instruction
+
+	aluOps := []ALUOp{Add, Sub, Mul, Div, Or, And, LSh, RSh, Mod, Xor, Mov, ArSh}
+	for _, op := range aluOps {
+		insns = append(insns,
+			op.Imm(R1, -2),
+			op.Reg(R1, R2),
+			op.Imm32(R1, -2),
+			op.Reg32(R1, R2),
+		)
+	}
+	insns = append(insns, Negate(R3), Negate32(R3))
+
+	jumpOps := []JumpOp{JEq, JGT, JGE, JSet, JNE, JSGT, JSGE, JLT, JLE, JSLT, JSLE}
+	for _, op := range jumpOps {
+		insns = append(insns,
+			op.Imm(R1, -2, ""),
+			op.Reg(R1, R2, ""),
+			op.Imm32(R1, -2, ""),
+			op.Reg32(R1, R2, ""),
+		)
+	}
+	insns = append(insns, Ja.Label(""), Return())
+
+	names := make(map[string]OpCode)
+	for _, ins := range insns {
+		if ins.OpCode == InvalidOpCode {
+			t.Fatalf("Invalid OpCode for %v", ins)
+		}
+
+		if ins.OpCode.Class().isJump() && ins.OpCode.JumpOp() != Exit {
+			ins.Offset = 3
+		}
+
+		var buf bytes.Buffer
+		if _, err := ins.Marshal(&buf, binary.LittleEndian); err != nil {
+			t.Fatalf("%v: %s", ins.OpCode, err)
+		}
+
+		var have Instruction
+		if _, err := have.Unmarshal(&buf, binary.LittleEndian); err != nil {
+			t.Fatalf("%v: %s", ins.OpCode, err)
+		}
+
+		ins.Reference = ""
+		if have != ins {
+			t.Errorf("%v: round trip changed instruction from %#v to %#v", ins.OpCode, ins, have)
+		}
+
+		name := ins.OpCode.String()
+		if strings.Contains(name, "0x") || strings.Contains(name, "Invalid") {
+			t.Errorf("OpCode %#x has name %s", uint8(ins.OpCode), name)
+		}
+		if other, ok := names[name]; ok && other != ins.OpCode {
+			t.Errorf("OpCodes %#x and %#x have the same name %s", uint8(other), uint8(ins.OpCode), name)
+		}
+		names[name] = ins.OpCode
+	}
+}
+
+func TestJump32(t *testing.T) {
+	for _, op := range []JumpOp{Ja, Call, Exit} {
+		if op.Op32(ImmSource) != InvalidOpCode {
+			t.Errorf("%s has a 32 bit variant", op)
+		}
+	}
+
+	ins := JSGE.Reg32(R1, R2, "label")
+	if ins.OpCode.Class() != Jump32Class {
+		t.Errorf("Expected Jump32Class, got %s", ins.OpCode.Class())
+	}
+	if !isBranch(ins) {
+		t.Error("JSGE.Reg32 isn't a branch")
+	}
+	if ins.reads() != regs(R1, R2) {
+		t.Error("JSGE.Reg32 doesn't read its operands")
+	}
+	if str := ins.OpCode.String(); str != "JSGE32Reg" {
+		t.Errorf("Unexpected name %s", str)
+	}
+
+	insns := Instructions{
+		JEq.Imm32(R1, 0, "exit"),
+		Mov.Imm(R0, 1),
+		Return().Sym("exit"),
+	}
+
+	var buf bytes.Buffer
+	if err := insns.Marshal(&buf, binary.LittleEndian); err != nil {
+		t.Fatal(err)
+	}
+
+	var have Instructions
+	if err := have.Unmarshal(&buf, binary.LittleEndian); err != nil {
+		t.Fatal(err)
+	}
+	if have[0].Offset != 1 {
+		t.Errorf("Expected jump offset 1, got %d", have[0].Offset)
+	}
+}
@@ -0,0 +1,299 @@
+package asm
+
+// Optimize returns a copy of insns with simple peephole optimizations
+// applied, which reduces the instruction count of generated programs.
+//
+// It removes moves of a register to itself and redundant back-to-back
+// moves, folds arithmetic on immediates into a single instruction and
+// merges adjacent stores of the same immediate to the stack. Instructions
+// which are the target of a jump or carry a symbol aren't merged with
+// their predecessor.
+func (insns Instructions) Optimize() (Instructions, error) {
+	for {
+		l, err := newLayout(insns)
+		if err != nil {
+			return nil, err
+		}
+
+		targets, err := l.jumpTargets()
+		if err != nil {
+			return nil, err
+		}
+
+		replacements := make([]Instructions, len(insns))
+		changed := false
+		for i := 0; i < len(insns); i++ {
+			ins := insns[i]
+			if ins.Symbol == "" && ins.isNoop() {
+				changed = true
+				continue
+			}
+
+			replacements[i] = Instructions{ins}
+			if i+1 >= len(insns) || targets[i+1] || insns[i+1].Symbol != "" {
+				continue
+			}
+
+			merged, ok := peephole(ins, insns[i+1])
+			if !ok {
+				continue
+			}
+
+			merged.Symbol = ins.Symbol
+			merged.Metadata = ins.Metadata
+			replacements[i] = Instructions{merged}
+			changed = true
+			i++
+		}
+
+		if !changed {
+			return insns, nil
+		}
+
+		insns, err = l.rewrite(replacements)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// jumpTargets returns the indices of all instructions which are the
+// target of a jump or bpf-to-bpf call.
+func (l *layout) jumpTargets() (map[int]bool, error) {
+	targets := make(map[int]bool)
+	for i, ins := range l.insns {
+		if !isBranch(ins) && !isPseudoCall(ins) {
+			continue
+		}
+
+		target, verr := l.target(i)
+		if verr != nil {
+			return nil, verr
+		}
+		targets[target] = true
+	}
+	return targets, nil
+}
+
+// isNoop returns true if ins doesn't have any effect.
+func (ins Instruction) isNoop() bool {
+	op := ins.OpCode
+	if op.Class() != ALU64Class {
+		// 32 bit ALU operations zero the upper half of dst.
+		return false
+	}
+
+	if op.Source() == RegSource {
+		return op.ALUOp() == Mov && ins.Dst == ins.Src
+	}
+
+	switch op.ALUOp() {
+	case Add, Sub, Or, Xor, LSh, RSh, ArSh:
+		return ins.Constant == 0
+	}
+	return false
+}
+
+// peephole returns a single instruction equivalent to executing a
+// followed by b.
+func peephole(a, b Instruction) (Instruction, bool) {
+	if ins, ok := foldMoves(a, b); ok {
+		return ins, true
+	}
+	if ins, ok := foldImmediates(a, b); ok {
+		return ins, true
+	}
+	return mergeStackStores(a, b)
+}
+
+// foldMoves handles moves which are undone or repeated by b.
+func foldMoves(a, b Instruction) (Instruction, bool) {
+	mov := Mov.Op(RegSource)
+	if a.OpCode != mov || b.OpCode != mov {
+		return Instruction{}, false
+	}
+
+	repeated := a.Dst == b.Dst && a.Src == b.Src
+	swapped := a.Dst == b.Src && a.Src == b.Dst
+	if !repeated && !swapped {
+		return Instruction{}, false
+	}
+
+	return a, true
+}
+
+// foldImmediates combines ALU operations on immediates.
+func foldImmediates(a, b Instruction) (Instruction, bool) {
+	cls := a.OpCode.Class()
+	if cls != ALUClass && cls != ALU64Class {
+		return Instruction{}, false
+	}
+	if a.OpCode.Source() != ImmSource || b.OpCode.Source() != ImmSource {
+		return Instruction{}, false
+	}
+	if b.OpCode.Class() != cls || a.Dst != b.Dst {
+		return Instruction{}, false
+	}
+
+	aOp, bOp := a.OpCode.ALUOp(), b.OpCode.ALUOp()
+	switch {
+	case aOp == Mov:
+		value, ok := evalALU(cls, bOp, a.Constant, b.Constant)
+		if !ok {
+			return Instruction{}, false
+		}
+
+		ins := a
+		ins.Constant = value
+		return ins, true
+
+	case (aOp == Add || aOp == Sub) && (bOp == Add || bOp == Sub):
+		sum := signedImm(aOp, a.Constant) + signedImm(bOp, b.Constant)
+		if cls == ALUClass {
+			sum = int64(int32(sum))
+		} else if int64(int32(sum)) != sum {
+			return Instruction{}, false
+		}
+
+		ins := a
+		ins.OpCode = a.OpCode.SetALUOp(Add)
+		ins.Constant = sum
+		return ins, true
+	}
+
+	return Instruction{}, false
+}
+
+func signedImm(op ALUOp, value int64) int64 {
+	if op == Sub {
+		return -value
+	}
+	return value
+}
+
+// evalALU computes the result of applying op to a register containing
+// the immediate dst. It returns false if the result can't be represented
+// as an immediate.
+func evalALU(cls Class, op ALUOp, dst, src int64) (int64, bool) {
+	if cls == ALUClass {
+		d, s := uint32(dst), uint32(src)
+		var r uint32
+		switch op {
+		case Add:
+			r = d + s
+		case Sub:
+			r = d - s
+		case Mul:
+			r = d * s
+		case Or:
+			r = d | s
+		case And:
+			r = d & s
+		case Xor:
+			r = d ^ s
+		case LSh, RSh, ArSh:
+			if s >= 32 {
+				return 0, false
+			}
+			switch op {
+			case LSh:
+				r = d << s
+			case RSh:
+				r = d >> s
+			default:
+				r = uint32(int32(d) >> s)
+			}
+		default:
+			return 0, false
+		}
+		return int64(int32(r)), true
+	}
+
+	// 64 bit immediates are sign extended.
+	d, s := uint64(int64(int32(dst))), uint64(int64(int32(src)))
+	var r uint64
+	switch op {
+	case Add:
+		r = d + s
+	case Sub:
+		r = d - s
+	case Mul:
+		r = d * s
+	case Or:
+		r = d | s
+	case And:
+		r = d & s
+	case Xor:
+		r = d ^ s
+	case LSh, RSh, ArSh:
+		if s >= 64 {
+			return 0, false
+		}
+		switch op {
+		case LSh:
+			r = d << s
+		case RSh:
+			r = d >> s
+		default:
+			r = uint64(int64(d) >> s)
+		}
+	default:
+		return 0, false
+	}
+
+	if int64(int32(r)) != int64(r) {
+		return 0, false
+	}
+	return int64(r), true
+}
+
+// mergeStackStores combines two adjacent stores of the same immediate
+// to the stack into a single store of twice the size.
+//
+// Only identical values are merged, which makes the result independent
+// of byte order.
+func mergeStackStores(a, b Instruction) (Instruction, bool) {
+	op := a.OpCode
+	if op.Class() != StClass || op.Mode() != MemMode || b.OpCode != op {
+		return Instruction{}, false
+	}
+	if a.Dst != RFP || b.Dst != RFP {
+		return Instruction{}, false
+	}
+
+	var merged Size
+	switch op.Size() {
+	case Byte:
+		merged = Half
+	case Half:
+		merged = Word
+	case Word:
+		merged = DWord
+	default:
+		return Instruction{}, false
+	}
+
+	size := op.Size().Sizeof()
+	bits := uint(size * 8)
+	mask := uint64(1)<<bits - 1
+	value := uint64(a.Constant) & mask
+	if uint64(b.Constant)&mask != value {
+		return Instruction{}, false
+	}
+
+	offset := a.Offset
+	if b.Offset < offset {
+		offset = b.Offset
+	}
+	if a.Offset+b.Offset != 2*offset+int16(size) || int(offset)%(2*size) != 0 {
+		return Instruction{}, false
+	}
+
+	combined := value | value<<bits
+	if merged == DWord && uint64(int64(int32(combined))) != combined {
+		// The immediate of a 64 bit store is sign extended.
+		return Instruction{}, false
+	}
+
+	return StoreImm(RFP, offset, int64(int32(combined)), merged), true
+}
@@ -0,0 +1,88 @@
+package asm
+
+import (
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	insns := Instructions{
+		Mov.Reg(R1, R1),
+		Mov.Reg(R2, R1),
+		Mov.Reg(R1, R2),
+		Mov.Imm(R0, 40),
+		Add.Imm(R0, 4),
+		Sub.Imm(R0, 2),
+		{OpCode: JEq.Op(ImmSource), Dst: R2, Offset: 2},
+		Add.Imm(R2, 8),
+		Add.Imm(R2, -8),
+		StoreImm(RFP, -8, 0, Word),
+		StoreImm(RFP, -4, 0, Word),
+		StoreImm(RFP, -10, 0xff, Byte),
+		StoreImm(RFP, -9, 0xff, Byte),
+		Return(),
+	}
+
+	optimized, err := insns.Optimize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Instructions{
+		Mov.Reg(R2, R1),
+		Mov.Imm(R0, 42),
+		{OpCode: JEq.Op(ImmSource), Dst: R2, Offset: 0},
+		StoreImm(RFP, -8, 0, DWord),
+		StoreImm(RFP, -10, 0xffff, Half),
+		Return(),
+	}
+
+	if len(optimized) != len(want) {
+		t.Fatalf("Expected %d instructions, got %d", len(want), len(optimized))
+	}
+
+	for i := range want {
+		if optimized[i].OpCode != want[i].OpCode ||
+			optimized[i].Dst != want[i].Dst ||
+			optimized[i].Src != want[i].Src ||
+			optimized[i].Offset != want[i].Offset ||
+			optimized[i].Constant != want[i].Constant {
+			t.Errorf("Instruction %d: expected %v, got %v", i, want[i], optimized[i])
+		}
+	}
+}
+
+func TestOptimizePreservesJumpTargets(t *testing.T) {
+	insns := Instructions{
+		Mov.Imm(R0, 0),
+		{OpCode: JEq.Op(ImmSource), Dst: R1, Offset: 1},
+		Mov.Imm(R0, 1),
+		Add.Imm(R0, 1),
+		Return(),
+	}
+
+	optimized, err := insns.Optimize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(optimized) != len(insns) {
+		t.Error("Jump target was merged:", optimized)
+	}
+}
+
+func TestOptimizeStoreSignExtension(t *testing.T) {
+	insns := Instructions{
+		StoreImm(RFP, -8, 0x7f, Word),
+		StoreImm(RFP, -4, 0x7f, Word),
+		Return(),
+	}
+
+	optimized, err := insns.Optimize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(optimized) != len(insns) {
+		t.Error("Stores which don't fit a sign extended immediate were merged")
+	}
+}
@@ -0,0 +1,333 @@
+package asm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+)
+
+// maxOutlineLength limits the length of sections considered by Outline.
+const maxOutlineLength = 64
+
+// OutlinedFunction is a bpf-to-bpf function created by Outline.
+type OutlinedFunction struct {
+	// Symbol is the name of the function.
+	Symbol string
+	// Length is the number of instructions moved into the function.
+	Length int
+	// Calls is the number of sections which were replaced by a call.
+	Calls int
+}
+
+// Outline moves straight-line sections which occur multiple times into
+// bpf-to-bpf functions, and replaces each occurrence with a call. This
+// reduces the instruction count of a program, which the kernel limits
+// to one million instructions. It doesn't reduce the number of
+// instructions processed by the verifier.
+//
+// Only sections of at least minLength instructions which don't
+// access the stack, don't use R6 to R9 and don't leave live values in
+// R1 to R5 are eligible. The returned instructions are identical to
+// insns if no eligible section saves instructions.
+func (insns Instructions) Outline(minLength int) (Instructions, []OutlinedFunction, error) {
+	if minLength < 1 {
+		minLength = 1
+	}
+
+	var fns []OutlinedFunction
+	for {
+		l, err := newLayout(insns)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		o, err := newOutliner(l)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		starts, length := o.bestSection(minLength)
+		if starts == nil {
+			return insns, fns, nil
+		}
+
+		symbol := uniqueSymbol(l.symbols, len(fns))
+
+		replacements := make([]Instructions, len(insns))
+		for i, ins := range insns {
+			replacements[i] = Instructions{ins}
+		}
+
+		for _, start := range starts {
+			call := Call.Label(symbol)
+			call.Symbol = insns[start].Symbol
+			call.Metadata = insns[start].Metadata
+			replacements[start] = Instructions{call}
+			for j := start + 1; j < start+length; j++ {
+				replacements[j] = nil
+			}
+		}
+
+		out, err := l.rewrite(replacements)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		fn := make(Instructions, length)
+		copy(fn, insns[starts[0]:starts[0]+length])
+		for i := range fn {
+			fn[i].Symbol = ""
+		}
+		fn[0].Symbol = symbol
+		if !o.writes(starts[0], length).has(R0) {
+			// The verifier requires R0 to be initialized on exit.
+			fn = append(fn, Mov.Imm(R0, 0))
+		}
+		fn = append(fn, Return())
+
+		insns = append(out, fn...)
+		fns = append(fns, OutlinedFunction{symbol, length, len(starts)})
+	}
+}
+
+func uniqueSymbol(symbols map[string]int, n int) string {
+	for {
+		symbol := fmt.Sprintf("outlined_%d", n)
+		if _, ok := symbols[symbol]; !ok {
+			return symbol
+		}
+		n++
+	}
+}
+
+type outliner struct {
+	*layout
+	// Registers which are live after each instruction.
+	liveOut []regSet
+	// The number of eligible instructions starting at each index.
+	runs []int
+}
+
+func newOutliner(l *layout) (*outliner, error) {
+	liveOut, err := l.liveness()
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := l.jumpTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]int, len(l.insns)+1)
+	for i := len(l.insns) - 1; i >= 0; i-- {
+		if !l.insns[i].outlinable() {
+			continue
+		}
+
+		runs[i] = 1
+		next := l.insns[i+1:]
+		if len(next) > 0 && next[0].Symbol == "" && !targets[i+1] {
+			runs[i] += runs[i+1]
+		}
+	}
+
+	return &outliner{l, liveOut, runs[:len(l.insns)]}, nil
+}
+
+// bestSection finds the section which saves the most instructions
+// when outlined, and returns the start of all its occurrences.
+func (o *outliner) bestSection(minLength int) ([]int, int) {
+	type window struct {
+		length int
+		hash   uint64
+	}
+
+	windows := make(map[window][]int)
+	for i := range o.insns {
+		var (
+			h       = fnv.New64a()
+			written regSet
+		)
+		for length := 1; length <= o.runs[i] && length <= maxOutlineLength; length++ {
+			ins := o.insns[i+length-1]
+			ins.hashInto(h)
+			if ins.reads()&^written&regs(R0) != 0 {
+				// The callee can't read R0 of the caller, and neither
+				// can longer sections.
+				break
+			}
+			written |= ins.writes()
+
+			if length < minLength || !o.canReturn(i, length, written) {
+				continue
+			}
+
+			w := window{length, h.Sum64()}
+			windows[w] = append(windows[w], i)
+		}
+	}
+
+	var (
+		bestStarts  []int
+		bestLength  int
+		bestSavings int
+	)
+	for w, candidates := range windows {
+		var starts []int
+		for _, start := range candidates {
+			if len(starts) > 0 {
+				prev := starts[len(starts)-1]
+				if start < prev+w.length || !o.equal(starts[0], start, w.length) {
+					continue
+				}
+			}
+			starts = append(starts, start)
+		}
+
+		raw := o.offsets[starts[0]+w.length-1] - o.offsets[starts[0]] +
+			o.insns[starts[0]+w.length-1].OpCode.marshalledInstructions()
+
+		// Each occurrence is replaced by a call, and the function needs
+		// an exit and potentially an assignment to R0.
+		cost := len(starts) + raw + 1
+		if !o.writes(starts[0], w.length).has(R0) {
+			cost++
+		}
+
+		savings := len(starts)*raw - cost
+		if savings < bestSavings || savings <= 0 {
+			continue
+		}
+
+		// Break ties deterministically, since map iteration order is random.
+		if savings > bestSavings || starts[0] < bestStarts[0] ||
+			(starts[0] == bestStarts[0] && w.length > bestLength) {
+			bestStarts, bestLength, bestSavings = starts, w.length, savings
+		}
+	}
+
+	return bestStarts, bestLength
+}
+
+// canReturn returns true if the section, which writes the given
+// registers and doesn't read R0 before writing it, can be replaced by a
+// call without changing the behaviour of the program.
+func (o *outliner) canReturn(start, length int, written regSet) bool {
+	// The call clobbers R1 to R5, and returns R0.
+	live := o.liveOut[start+length-1]
+	if live&regs(R1, R2, R3, R4, R5) != 0 {
+		return false
+	}
+	return !live.has(R0) || written.has(R0)
+}
+
+func (o *outliner) writes(start, length int) regSet {
+	var written regSet
+	for _, ins := range o.insns[start : start+length] {
+		written |= ins.writes()
+	}
+	return written
+}
+
+func (o *outliner) equal(a, b, length int) bool {
+	for i := 0; i < length; i++ {
+		if !o.insns[a+i].equivalent(o.insns[b+i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// outlinable returns true if ins may be moved into a bpf-to-bpf function.
+func (ins Instruction) outlinable() bool {
+	if (ins.reads()|ins.writes())&^regs(R0, R1, R2, R3, R4, R5) != 0 {
+		// The callee has its own stack frame and callee saved registers.
+		return false
+	}
+
+	op := ins.OpCode
+	switch op.Class() {
+	case ALUClass, ALU64Class, LdXClass, StClass, StXClass:
+		return true
+	case LdClass:
+		return op.isDWordLoad()
+	case JumpClass:
+		return ins.jumpOp() == Call && !isPseudoCall(ins) && ins.Constant != int64(FnTailCall)
+	}
+	return false
+}
+
+// equivalent returns true if two instructions are the same, ignoring
+// their symbol and metadata.
+func (ins Instruction) equivalent(other Instruction) bool {
+	return ins.OpCode == other.OpCode &&
+		ins.Dst == other.Dst &&
+		ins.Src == other.Src &&
+		ins.Offset == other.Offset &&
+		ins.Constant == other.Constant &&
+		ins.Reference == other.Reference
+}
+
+func (ins Instruction) hashInto(w io.Writer) {
+	var buf [13]byte
+	buf[0] = byte(ins.OpCode)
+	buf[1] = byte(ins.Dst)
+	buf[2] = byte(ins.Src)
+	binary.LittleEndian.PutUint16(buf[3:], uint16(ins.Offset))
+	binary.LittleEndian.PutUint64(buf[5:], uint64(ins.Constant))
+	w.Write(buf[:])
+	w.Write([]byte(ins.Reference))
+	w.Write([]byte{0})
+}
+
+// liveness returns the registers which are live after each instruction.
+//
+// Helper and bpf-to-bpf calls are assumed to read all argument registers.
+func (l *layout) liveness() ([]regSet, error) {
+	var (
+		liveIn  = make([]regSet, len(l.insns))
+		liveOut = make([]regSet, len(l.insns))
+		succs   = make([][]int, len(l.insns))
+	)
+
+	for i := range l.insns {
+		next, err := l.successors(i)
+		if err != nil {
+			return nil, err
+		}
+		succs[i] = next
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for i := len(l.insns) - 1; i >= 0; i-- {
+			ins := l.insns[i]
+
+			var out regSet
+			for _, j := range succs[i] {
+				out |= liveIn[j]
+			}
+
+			in := ins.liveIn(out)
+			if in != liveIn[i] || out != liveOut[i] {
+				liveIn[i], liveOut[i] = in, out
+				changed = true
+			}
+		}
+	}
+
+	return liveOut, nil
+}
+
+// liveIn returns the registers which are live before an instruction,
+// given the ones which are live after it.
+func (ins Instruction) liveIn(liveOut regSet) regSet {
+	use := ins.reads()
+	if ins.jumpOp() == Call {
+		use |= regs(R1, R2, R3, R4, R5)
+	}
+
+	return use | liveOut&^(ins.writes()|ins.clobbers())
+}
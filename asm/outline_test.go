@@ -0,0 +1,104 @@
+package asm
+
+import (
+	"testing"
+)
+
+func TestOutline(t *testing.T) {
+	section := func() Instructions {
+		return Instructions{
+			LoadImm(R1, 0x1234, DWord),
+			Mov.Imm(R2, 2),
+			Mul.Reg(R1, R2),
+			Mov.Reg(R0, R1),
+		}
+	}
+
+	var insns Instructions
+	for i := 0; i < 4; i++ {
+		insns = append(insns, section()...)
+	}
+	insns = append(insns, Return())
+
+	outlined, fns, err := insns.Outline(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(outlined)
+
+	if len(fns) != 1 {
+		t.Fatalf("Expected one function, got %v", fns)
+	}
+
+	if fns[0].Length != 4 || fns[0].Calls != 4 {
+		t.Errorf("Unexpected function %+v", fns[0])
+	}
+
+	if len(outlined) >= len(insns) {
+		t.Errorf("Outlining didn't reduce instruction count: %d >= %d", len(outlined), len(insns))
+	}
+
+	if err := outlined.Validate(); err != nil {
+		t.Error("Outlined instructions are invalid:", err)
+	}
+}
+
+func TestOutlineLiveRegisters(t *testing.T) {
+	var insns Instructions
+	for i := 0; i < 4; i++ {
+		insns = append(insns,
+			Mov.Imm(R1, 1),
+			Add.Imm(R1, 2),
+			Add.Imm(R1, 3),
+			Mov.Reg(R6, R1),
+		)
+	}
+	insns = append(insns, Mov.Reg(R0, R6), Return())
+
+	outlined, fns, err := insns.Outline(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fns) != 0 {
+		t.Errorf("Outlined section with live R1: %v", outlined)
+	}
+}
+
+func TestOutlineRawJumps(t *testing.T) {
+	section := Instructions{
+		Mov.Imm(R1, 1),
+		Add.Imm(R1, 2),
+		Add.Imm(R1, 3),
+		Mov.Reg(R0, R1),
+	}
+
+	insns := Instructions{
+		Mov.Imm(R0, 0),
+		{OpCode: JEq.Op(ImmSource), Dst: R0, Offset: int16(2 * len(section))},
+	}
+	insns = append(insns, section...)
+	insns = append(insns, section...)
+	insns = append(insns, section...)
+	insns = append(insns, Return())
+
+	outlined, fns, err := insns.Outline(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fns) != 1 {
+		t.Fatalf("Expected one function, got %v", outlined)
+	}
+
+	// The jump targets the start of the third section, which is now a call.
+	jump := outlined[1]
+	if jump.Offset != 2 {
+		t.Errorf("Expected jump offset 2, got %d\n%v", jump.Offset, outlined)
+	}
+
+	if err := outlined.Validate(); err != nil {
+		t.Error("Outlined instructions are invalid:", err)
+	}
+}
@@ -0,0 +1,170 @@
+package asm
+
+import (
+	"fmt"
+
+	"golang.org/x/xerrors"
+)
+
+// RelocationKind is the type of symbol a relocation refers to.
+type RelocationKind uint8
+
+const (
+	// InvalidRelocation is the zero value.
+	InvalidRelocation RelocationKind = iota
+	// MapRelocation is a load of a map pointer. It's resolved by a
+	// map file descriptor.
+	MapRelocation
+	// MapValueRelocation is a direct load of a map value, for example
+	// for global data. It's resolved by a map file descriptor.
+	MapValueRelocation
+	// SubprogRelocation is a bpf-to-bpf call. It's resolved by linking
+	// the called function into the program.
+	SubprogRelocation
+	// KsymRelocation is a load of the address of a kernel symbol.
+	KsymRelocation
+	// ExternRelocation is a load of a symbol which is defined outside
+	// of the ELF, for example by inline assembly.
+	ExternRelocation
+)
+
+func (kind RelocationKind) String() string {
+	switch kind {
+	case MapRelocation:
+		return "map"
+	case MapValueRelocation:
+		return "map value"
+	case SubprogRelocation:
+		return "subprogram"
+	case KsymRelocation:
+		return "ksym"
+	case ExternRelocation:
+		return "extern"
+	default:
+		return fmt.Sprintf("RelocationKind(%d)", uint8(kind))
+	}
+}
+
+// Relocation is a reference from an instruction to a symbol that needs
+// to be resolved before the instruction can be loaded.
+type Relocation struct {
+	Kind RelocationKind
+	// Symbol is the name of the map, function, kernel symbol or extern.
+	Symbol string
+	// Weak is true if the symbol is declared weak. Unresolved weak kernel
+	// symbols and externs load zero, like in C, while other unresolved
+	// ones prevent loading the program.
+	Weak bool
+}
+
+func (rel Relocation) String() string {
+	return fmt.Sprintf("%s %s", rel.Kind, rel.Symbol)
+}
+
+type relocationMeta struct{}
+
+// Relocation returns the unresolved relocation of the instruction, or
+// nil if there is none.
+func (ins Instruction) Relocation() *Relocation {
+	rel, _ := ins.Metadata.Get(relocationMeta{}).(*Relocation)
+	return rel
+}
+
+// WithRelocation returns a copy of the instruction with the given
+// relocation. Passing nil removes the relocation.
+func (ins Instruction) WithRelocation(rel *Relocation) Instruction {
+	if rel == nil {
+		ins.Metadata.Set(relocationMeta{}, nil)
+	} else {
+		ins.Metadata.Set(relocationMeta{}, rel)
+	}
+	return ins
+}
+
+// ResolveRelocation resolves the relocation of the instruction.
+//
+// value is a file descriptor for map relocations, and the value of the
+// 64 bit immediate for kernel symbols and externs.
+func (ins *Instruction) ResolveRelocation(value int64) error {
+	rel := ins.Relocation()
+	if rel == nil {
+		return xerrors.New("instruction has no relocation")
+	}
+
+	switch rel.Kind {
+	case MapRelocation, MapValueRelocation:
+		// Clears the relocation.
+		return ins.RewriteMapPtr(int(value))
+
+	case KsymRelocation, ExternRelocation:
+		if !ins.OpCode.isDWordLoad() {
+			return xerrors.Errorf("%s is not a 64 bit load", ins.OpCode)
+		}
+		ins.Constant = value
+
+	case SubprogRelocation:
+		return xerrors.Errorf("%s: calls are resolved by linking", rel)
+
+	default:
+		return xerrors.Errorf("unsupported relocation %s", rel)
+	}
+
+	ins.Metadata.Set(relocationMeta{}, nil)
+	return nil
+}
+
+// UnresolvedRelocations returns all unresolved relocations, keyed by
+// instruction index.
+//
+// Calls are considered unresolved if the called function isn't part
+// of insns.
+func (insns Instructions) UnresolvedRelocations() map[int]Relocation {
+	relos := make(map[int]Relocation)
+
+	var symbols map[string]int
+	for i, ins := range insns {
+		rel := ins.Relocation()
+		if rel == nil {
+			continue
+		}
+
+		if rel.Kind == SubprogRelocation {
+			if symbols == nil {
+				// Duplicate symbols are caught during marshaling.
+				symbols, _ = insns.SymbolOffsets()
+			}
+			if _, ok := symbols[rel.Symbol]; ok {
+				continue
+			}
+		}
+
+		relos[i] = *rel
+	}
+
+	return relos
+}
+
+// ResolveRelocation resolves all relocations of the given symbol.
+//
+// Returns an error if the symbol isn't used, see IsUnreferencedSymbol.
+func (insns Instructions) ResolveRelocation(symbol string, value int64) error {
+	found := false
+	for i := range insns {
+		ins := &insns[i]
+		if rel := ins.Relocation(); rel == nil || rel.Symbol != symbol {
+			continue
+		}
+
+		if err := ins.ResolveRelocation(value); err != nil {
+			return xerrors.Errorf("instruction %d: %w", i, err)
+		}
+
+		found = true
+	}
+
+	if !found {
+		return &unreferencedSymbolError{symbol}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package asm
+
+import (
+	"testing"
+)
+
+func TestResolveRelocation(t *testing.T) {
+	insns := Instructions{
+		LoadMapPtr(R1, 0).WithRelocation(&Relocation{Kind: MapRelocation, Symbol: "map"}),
+		LoadImm(R2, 0, DWord).WithRelocation(&Relocation{Kind: ExternRelocation, Symbol: "ext"}),
+		Call.Label("fn").WithRelocation(&Relocation{Kind: SubprogRelocation, Symbol: "fn"}),
+		Call.Label("missing").WithRelocation(&Relocation{Kind: SubprogRelocation, Symbol: "missing"}),
+		Return(),
+		Return().Sym("fn"),
+	}
+
+	relos := insns.UnresolvedRelocations()
+	if len(relos) != 3 {
+		t.Fatalf("Expected 3 unresolved relocations, got %v", relos)
+	}
+	if _, ok := relos[2]; ok {
+		t.Error("Call to fn is resolved, since fn is part of the instructions")
+	}
+
+	if err := insns.ResolveRelocation("map", 42); err != nil {
+		t.Fatal("Can't resolve map:", err)
+	}
+	if insns[0].Constant != 42 || insns[0].Relocation() != nil {
+		t.Error("Map relocation wasn't resolved:", insns[0])
+	}
+
+	if err := insns.ResolveRelocation("ext", 0xdeadbeef); err != nil {
+		t.Fatal("Can't resolve extern:", err)
+	}
+	if insns[1].Constant != 0xdeadbeef || insns[1].Relocation() != nil {
+		t.Error("Extern relocation wasn't resolved:", insns[1])
+	}
+
+	if err := insns.ResolveRelocation("missing", 0); err == nil {
+		t.Error("Resolving a call doesn't return an error")
+	}
+
+	if err := insns.ResolveRelocation("bogus", 0); !IsUnreferencedSymbol(err) {
+		t.Error("Expected an unreferenced symbol error, got", err)
+	}
+
+	if relos := insns.UnresolvedRelocations(); len(relos) != 1 {
+		t.Errorf("Expected 1 unresolved relocation, got %v", relos)
+	}
+}
@@ -0,0 +1,260 @@
+package asm
+
+import (
+	"sort"
+)
+
+// MaxCallDepth is the maximum number of nested stack frames, including
+// the one of the main program.
+const MaxCallDepth = 8
+
+// stackFrameAlignment is the granularity at which the verifier accounts
+// stack usage of a frame.
+const stackFrameAlignment = 32
+
+// FunctionStack is the stack usage of a single function.
+type FunctionStack struct {
+	// Symbol of the function's first instruction, if any.
+	Symbol string
+	// Index of the function's first instruction.
+	Index int
+	// Depth is the number of stack bytes used by the function itself.
+	Depth int
+	// Callees are the indices of functions invoked via bpf-to-bpf calls.
+	Callees []int
+}
+
+// StackUsage describes the stack usage of a program across bpf-to-bpf
+// calls.
+type StackUsage struct {
+	// Functions contains the main program followed by all subprograms,
+	// ordered by index.
+	Functions []FunctionStack
+	// MaxDepth is the combined size of all frames along the deepest call
+	// chain, with each frame rounded up the way the verifier does.
+	MaxDepth int
+	// MaxCallDepth is the number of frames along the longest call chain.
+	MaxCallDepth int
+}
+
+// StackUsage computes the stack usage of each function in insns, and of
+// the deepest call chain.
+//
+// Stack accesses are found by tracking pointers derived from the frame
+// pointer using constant offsets. Loads, stores and passing such a
+// pointer to a call count as usage, computing the pointer alone doesn't.
+// Usage via other means isn't accounted for, so results may be lower
+// than what the verifier computes.
+//
+// Returns a *ValidationError if the program recurses, or if it exceeds
+// MaxStackDepth or MaxCallDepth.
+func (insns Instructions) StackUsage() (*StackUsage, error) {
+	l, err := newLayout(insns)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := new(StackUsage)
+	sites := make(map[int]map[int]int)
+	for _, entry := range l.functions() {
+		fn, fnSites, err := l.functionStack(entry)
+		if err != nil {
+			return nil, err
+		}
+		usage.Functions = append(usage.Functions, *fn)
+		sites[entry] = fnSites
+	}
+
+	sort.Slice(usage.Functions, func(i, j int) bool {
+		return usage.Functions[i].Index < usage.Functions[j].Index
+	})
+
+	fns := make(map[int]*FunctionStack)
+	for i := range usage.Functions {
+		fns[usage.Functions[i].Index] = &usage.Functions[i]
+	}
+
+	w := stackWalker{layout: l, fns: fns, sites: sites, active: make(map[int]bool)}
+	if err := w.walk(0, 0, 1, 0); err != nil {
+		return nil, err
+	}
+
+	usage.MaxDepth = w.maxDepth
+	usage.MaxCallDepth = w.maxCallDepth
+	return usage, nil
+}
+
+// functionStack finds the stack usage and callees of the function
+// starting at entry. It also returns the first instruction calling each
+// callee.
+func (l *layout) functionStack(entry int) (*FunctionStack, map[int]int, error) {
+	fn := &FunctionStack{
+		Symbol: l.insns[entry].Symbol,
+		Index:  entry,
+	}
+
+	// Offsets from the frame pointer held by registers. Tracking is done
+	// in instruction order, which is good enough for the code compilers
+	// generate.
+	fpOffsets := map[Register]int{RFP: 0}
+	lowest := 0
+	record := func(offset int) {
+		if offset < lowest {
+			lowest = offset
+		}
+	}
+
+	reachable, err := l.reachable(entry)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sites := make(map[int]int)
+	for _, i := range reachable {
+		ins := l.insns[i]
+		op := ins.OpCode
+
+		if isPseudoCall(ins) {
+			target, err := l.target(i)
+			if err != nil {
+				return nil, nil, err
+			}
+			if _, ok := sites[target]; !ok {
+				sites[target] = i
+				fn.Callees = append(fn.Callees, target)
+			}
+		}
+
+		switch cls := op.Class(); {
+		case cls == LdXClass:
+			if base, ok := fpOffsets[ins.Src]; ok && op.Mode() == MemMode {
+				record(base + int(ins.Offset))
+			}
+
+		case cls == StClass || cls == StXClass:
+			if base, ok := fpOffsets[ins.Dst]; ok {
+				record(base + int(ins.Offset))
+			}
+
+		case ins.jumpOp() == Call:
+			// Helpers and subprograms access the memory passed to them.
+			for r := R1; r <= R5; r++ {
+				if base, ok := fpOffsets[r]; ok {
+					record(base)
+				}
+			}
+		}
+
+		// Update pointers derived from the frame pointer.
+		writes := ins.writes() | ins.clobbers()
+		if op.Class() == ALU64Class {
+			aluOp, src := op.ALUOp(), op.Source()
+			base, isPointer := fpOffsets[ins.Src]
+			switch {
+			case aluOp == Mov && src == RegSource && isPointer:
+				fpOffsets[ins.Dst] = base
+				continue
+
+			case (aluOp == Add || aluOp == Sub) && src == ImmSource:
+				if base, ok := fpOffsets[ins.Dst]; ok && ins.Dst != RFP {
+					delta := int(ins.Constant)
+					if aluOp == Sub {
+						delta = -delta
+					}
+					fpOffsets[ins.Dst] = base + delta
+					continue
+				}
+			}
+		}
+
+		for r := R0; r < RFP; r++ {
+			if writes.has(r) {
+				delete(fpOffsets, r)
+			}
+		}
+	}
+
+	fn.Depth = -lowest
+	return fn, sites, nil
+}
+
+// reachable returns the indices of instructions reachable from entry
+// without following calls, in ascending order.
+func (l *layout) reachable(entry int) ([]int, error) {
+	seen := map[int]bool{entry: true}
+	work := []int{entry}
+	for len(work) > 0 {
+		i := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		next, err := l.successors(i)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, j := range next {
+			if !seen[j] {
+				seen[j] = true
+				work = append(work, j)
+			}
+		}
+	}
+
+	result := make([]int, 0, len(seen))
+	for i := range seen {
+		result = append(result, i)
+	}
+	sort.Ints(result)
+	return result, nil
+}
+
+type stackWalker struct {
+	*layout
+	fns          map[int]*FunctionStack
+	sites        map[int]map[int]int
+	active       map[int]bool
+	maxDepth     int
+	maxCallDepth int
+}
+
+// walk explores all call chains starting at the function at entry,
+// called from the instruction at index site.
+func (w *stackWalker) walk(entry, depth, callDepth, site int) error {
+	fn := w.fns[entry]
+
+	if w.active[entry] {
+		return w.errorf(site, "recursive call to function at instruction %d", entry)
+	}
+
+	frame := fn.Depth
+	if frame < 1 {
+		frame = 1
+	}
+	depth += (frame + stackFrameAlignment - 1) / stackFrameAlignment * stackFrameAlignment
+
+	if depth > MaxStackDepth {
+		return w.errorf(site, "combined stack size of %d bytes exceeds limit of %d", depth, MaxStackDepth)
+	}
+	if callDepth > MaxCallDepth {
+		return w.errorf(site, "call depth of %d exceeds limit of %d", callDepth, MaxCallDepth)
+	}
+
+	if depth > w.maxDepth {
+		w.maxDepth = depth
+	}
+	if callDepth > w.maxCallDepth {
+		w.maxCallDepth = callDepth
+	}
+
+	w.active[entry] = true
+	defer delete(w.active, entry)
+
+	for _, callee := range fn.Callees {
+		site := w.sites[entry][callee]
+		if err := w.walk(callee, depth, callDepth+1, site); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package asm
+
+import (
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestStackUsage(t *testing.T) {
+	insns := Instructions{
+		StoreImm(RFP, -8, 0, DWord).Sym("main"),
+		Mov.Reg(R1, RFP),
+		Add.Imm(R1, -40),
+		Call.Label("fn"),
+		Mov.Imm(R0, 0),
+		Return(),
+
+		Mov.Reg(R2, RFP).Sym("fn"),
+		StoreMem(R2, -100, R1, DWord),
+		Call.Label("leaf"),
+		Return(),
+
+		Mov.Imm(R0, 0).Sym("leaf"),
+		Return(),
+	}
+
+	usage, err := insns.StackUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(usage.Functions) != 3 {
+		t.Fatalf("Expected 3 functions, got %d", len(usage.Functions))
+	}
+
+	for i, want := range []struct {
+		symbol string
+		depth  int
+	}{
+		{"main", 40},
+		{"fn", 100},
+		{"leaf", 0},
+	} {
+		fn := usage.Functions[i]
+		if fn.Symbol != want.symbol || fn.Depth != want.depth {
+			t.Errorf("Expected %s to use %d bytes, got %s with %d bytes", want.symbol, want.depth, fn.Symbol, fn.Depth)
+		}
+	}
+
+	// Frames are rounded up to 32 bytes: 64 + 128 + 32.
+	if usage.MaxDepth != 224 {
+		t.Error("Expected max depth of 224, got", usage.MaxDepth)
+	}
+
+	if usage.MaxCallDepth != 3 {
+		t.Error("Expected max call depth of 3, got", usage.MaxCallDepth)
+	}
+}
+
+func TestStackUsagePointerArithmetic(t *testing.T) {
+	insns := Instructions{
+		Mov.Reg(R1, RFP),
+		Add.Imm(R1, -64),
+		Mov.Reg(R2, R1),
+		Sub.Imm(R2, 8),
+		Mov.Reg(R0, R2),
+		Return(),
+	}
+
+	usage, err := insns.StackUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if depth := usage.Functions[0].Depth; depth != 0 {
+		t.Error("Pointer arithmetic without stack access uses", depth, "bytes")
+	}
+
+	insns = Instructions{
+		Mov.Reg(R1, RFP),
+		Add.Imm(R1, -64),
+		LoadMem(R0, R1, 8, DWord),
+		Return(),
+	}
+
+	usage, err = insns.StackUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if depth := usage.Functions[0].Depth; depth != 56 {
+		t.Error("Expected load at fp-56 to use 56 bytes, got", depth)
+	}
+}
+
+func TestStackUsageLimits(t *testing.T) {
+	t.Run("frame size", func(t *testing.T) {
+		insns := Instructions{
+			StoreImm(RFP, -256, 0, DWord),
+			Call.Label("fn"),
+			Return(),
+			StoreImm(RFP, -288, 0, DWord).Sym("fn"),
+			Return(),
+		}
+
+		checkStackError(t, insns, 1)
+	})
+
+	t.Run("call depth", func(t *testing.T) {
+		var insns Instructions
+		for i := 0; i <= MaxCallDepth; i++ {
+			insns = append(insns,
+				Call.Label(funcName(i+1)).Sym(funcName(i)),
+				Return(),
+			)
+		}
+		insns = append(insns,
+			Mov.Imm(R0, 0).Sym(funcName(MaxCallDepth+1)),
+			Return(),
+		)
+		insns[0].Symbol = ""
+
+		checkStackError(t, insns, 2*(MaxCallDepth-1))
+	})
+
+	t.Run("recursion", func(t *testing.T) {
+		insns := Instructions{
+			Call.Label("fn"),
+			Return(),
+			Call.Label("fn").Sym("fn"),
+			Return(),
+		}
+
+		checkStackError(t, insns, 2)
+	})
+}
+
+func funcName(i int) string {
+	return string(rune('a' + i))
+}
+
+func checkStackError(t *testing.T, insns Instructions, index int) {
+	t.Helper()
+
+	_, err := insns.StackUsage()
+	var ve *ValidationError
+	if !xerrors.As(err, &ve) {
+		t.Fatal("Expected a ValidationError, got", err)
+	}
+
+	if ve.Index != index {
+		t.Errorf("Expected error at instruction %d, got %v", index, err)
+	}
+}
@@ -0,0 +1,726 @@
+package asm
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/xerrors"
+)
+
+// MaxStackDepth is the size of a single stack frame in bytes.
+const MaxStackDepth = 512
+
+// ValidationError describes an instruction which the verifier is
+// going to reject.
+type ValidationError struct {
+	// Index of the offending instruction.
+	Index int
+	// Symbol is the closest symbol preceding the instruction, if any.
+	Symbol string
+	// SymbolOffset is the number of instructions between Symbol and
+	// the offending instruction.
+	SymbolOffset int
+	// Reason is a human readable explanation.
+	Reason string
+}
+
+func (ve *ValidationError) Error() string {
+	if ve.Symbol == "" {
+		return fmt.Sprintf("instruction %d: %s", ve.Index, ve.Reason)
+	}
+	return fmt.Sprintf("instruction %d (%s+%d): %s", ve.Index, ve.Symbol, ve.SymbolOffset, ve.Reason)
+}
+
+// Validate checks insns for mistakes which the verifier would reject.
+//
+// It finds jumps and calls with invalid targets, programs which fall off
+// the end, reads of uninitialized registers, writes to the frame pointer
+// and stack accesses which are out of bounds. Calls to built-in functions
+// must receive initialized arguments which match BuiltinFunc.Args, for
+// example a map loaded by LoadMapPtr. The returned error is a
+// *ValidationError for the first offending instruction.
+//
+// Passing validation doesn't guarantee that the verifier accepts insns.
+func (insns Instructions) Validate() error {
+	l, err := newLayout(insns)
+	if err != nil {
+		return err
+	}
+
+	if err := l.validateStructure(); err != nil {
+		return err
+	}
+
+	for _, fn := range l.functions() {
+		if err := l.validateRegisters(fn); err != nil {
+			return err
+		}
+
+		if err := l.validateCalls(fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// layout maps between instruction indices and raw offsets, which differ
+// due to 64 bit loads occupying two slots.
+type layout struct {
+	insns   Instructions
+	offsets []int
+	indices map[int]int
+	symbols map[string]int
+}
+
+func newLayout(insns Instructions) (*layout, error) {
+	if len(insns) == 0 {
+		return nil, xerrors.New("no instructions")
+	}
+
+	symbols, err := insns.SymbolOffsets()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &layout{
+		insns,
+		make([]int, len(insns)),
+		make(map[int]int, len(insns)),
+		symbols,
+	}
+
+	iter := insns.Iterate()
+	for iter.Next() {
+		l.offsets[iter.Index] = int(iter.Offset)
+		l.indices[int(iter.Offset)] = iter.Index
+	}
+
+	return l, nil
+}
+
+func (l *layout) errorf(i int, format string, args ...interface{}) *ValidationError {
+	ve := &ValidationError{
+		Index:  i,
+		Reason: fmt.Sprintf(format, args...),
+	}
+
+	for j := i; j >= 0; j-- {
+		if sym := l.insns[j].Symbol; sym != "" {
+			ve.Symbol = sym
+			ve.SymbolOffset = i - j
+			break
+		}
+	}
+
+	return ve
+}
+
+// jumpOp returns the JumpOp of ins, or InvalidJumpOp if ins isn't
+// a jump. OpCode.JumpOp can't be used since it also decodes ALU ops.
+func (ins Instruction) jumpOp() JumpOp {
+	if !ins.OpCode.Class().isJump() {
+		return InvalidJumpOp
+	}
+	return ins.OpCode.JumpOp()
+}
+
+// isPseudoCall returns true if ins is a bpf-to-bpf call.
+func isPseudoCall(ins Instruction) bool {
+	return ins.jumpOp() == Call && ins.Src == PseudoCall
+}
+
+// isBranch returns true if ins is a jump with an offset.
+func isBranch(ins Instruction) bool {
+	op := ins.jumpOp()
+	return op != InvalidJumpOp && op != Call && op != Exit
+}
+
+// target returns the index of the instruction a jump or bpf-to-bpf
+// call at index i transfers control to.
+func (l *layout) target(i int) (int, *ValidationError) {
+	ins := l.insns[i]
+
+	var delta int
+	switch {
+	case isPseudoCall(ins) && ins.Constant == -1 && ins.Reference != "":
+		fallthrough
+	case isBranch(ins) && ins.Offset == -1 && ins.Reference != "":
+		target, ok := l.symbols[ins.Reference]
+		if !ok {
+			return 0, l.errorf(i, "reference to missing symbol %s", ins.Reference)
+		}
+		return target, nil
+
+	case isPseudoCall(ins):
+		delta = int(ins.Constant)
+
+	default:
+		delta = int(ins.Offset)
+	}
+
+	raw := l.offsets[i] + 1 + delta
+	target, ok := l.indices[raw]
+	if !ok {
+		return 0, l.errorf(i, "jump to invalid offset %d", raw)
+	}
+	return target, nil
+}
+
+// isRawJump returns true if ins is a jump or bpf-to-bpf call which
+// refers to its target by offset instead of by symbol.
+func isRawJump(ins Instruction) bool {
+	switch {
+	case isPseudoCall(ins):
+		return !(ins.Constant == -1 && ins.Reference != "")
+	case isBranch(ins):
+		return !(ins.Offset == -1 && ins.Reference != "")
+	}
+	return false
+}
+
+// rewrite replaces each instruction with a possibly empty list of
+// instructions, and adjusts the offsets of raw jumps and calls.
+//
+// A jump must be the last instruction of its replacement. Jumps to an
+// instruction without replacement continue at the next instruction.
+func (l *layout) rewrite(replacements []Instructions) (Instructions, error) {
+	var (
+		out   Instructions
+		first = make([]int, len(l.insns))
+		last  = make([]int, len(l.insns))
+	)
+	for i, repl := range replacements {
+		first[i] = len(out)
+		out = append(out, repl...)
+		last[i] = len(out) - 1
+	}
+
+	offsets := make([]int, len(out))
+	iter := out.Iterate()
+	for iter.Next() {
+		offsets[iter.Index] = int(iter.Offset)
+	}
+
+	for i, ins := range l.insns {
+		if len(replacements[i]) == 0 || !isRawJump(ins) {
+			continue
+		}
+
+		target, verr := l.target(i)
+		if verr != nil {
+			return nil, verr
+		}
+
+		if first[target] >= len(out) {
+			return nil, l.errorf(i, "jump target was removed")
+		}
+
+		delta := offsets[first[target]] - offsets[last[i]] - 1
+		jump := &out[last[i]]
+		if isPseudoCall(ins) {
+			jump.Constant = int64(delta)
+			continue
+		}
+
+		if delta < math.MinInt16 || delta > math.MaxInt16 {
+			return nil, l.errorf(i, "jump offset %d exceeds 16 bits", delta)
+		}
+		jump.Offset = int16(delta)
+	}
+
+	return out, nil
+}
+
+// successors returns the instructions which may execute after the one
+// at index i in the same function.
+func (l *layout) successors(i int) ([]int, *ValidationError) {
+	ins := l.insns[i]
+
+	var next []int
+	switch {
+	case ins.jumpOp() == Exit:
+		return nil, nil
+
+	case isBranch(ins):
+		target, err := l.target(i)
+		if err != nil {
+			return nil, err
+		}
+		next = append(next, target)
+		if ins.jumpOp() == Ja {
+			return next, nil
+		}
+	}
+
+	if i+1 >= len(l.insns) {
+		return nil, l.errorf(i, "execution falls off the end of the program")
+	}
+
+	return append(next, i+1), nil
+}
+
+// functions returns the start of the main program and all bpf-to-bpf
+// call targets, in order.
+func (l *layout) functions() []int {
+	fns := []int{0}
+	seen := map[int]bool{0: true}
+	for i, ins := range l.insns {
+		if !isPseudoCall(ins) {
+			continue
+		}
+
+		// Invalid targets are caught by validateStructure.
+		target, err := l.target(i)
+		if err != nil || seen[target] {
+			continue
+		}
+
+		seen[target] = true
+		fns = append(fns, target)
+	}
+
+	return fns
+}
+
+func (l *layout) validateStructure() error {
+	last := l.insns[len(l.insns)-1].jumpOp()
+	if last != Exit && last != Ja {
+		return l.errorf(len(l.insns)-1, "last instruction is not an exit or jump")
+	}
+
+	for i, ins := range l.insns {
+		op := ins.OpCode
+		if op == InvalidOpCode || op.Class().encoding() == unknownEncoding {
+			return l.errorf(i, "invalid opcode %#x", uint8(op))
+		}
+
+		if isBranch(ins) || isPseudoCall(ins) {
+			if _, err := l.target(i); err != nil {
+				return err
+			}
+		}
+
+		if ins.writes().has(RFP) {
+			return l.errorf(i, "write to read-only frame pointer")
+		}
+
+		if off, size, ok := ins.stackAccess(); ok && (off < -MaxStackDepth || off+size > 0) {
+			return l.errorf(i, "stack access at offset %d with size %d is out of bounds", off, size)
+		}
+	}
+
+	return nil
+}
+
+// validateRegisters finds reads from uninitialized registers in the function
+// starting at entry.
+func (l *layout) validateRegisters(entry int) error {
+	initialized := regs(R1, RFP)
+	if entry != 0 {
+		// Subprograms receive up to five arguments.
+		initialized = regs(R1, R2, R3, R4, R5, RFP)
+	}
+
+	states, err := l.initialized(entry, initialized)
+	if err != nil {
+		return err
+	}
+
+	for i := range l.insns {
+		state, ok := states[i]
+		if !ok {
+			continue
+		}
+
+		if missing := l.insns[i].reads() &^ state; missing != 0 {
+			return l.errorf(i, "read from uninitialized register %s", missing.lowest())
+		}
+	}
+
+	return nil
+}
+
+// initialized returns the set of registers that are initialized on all
+// paths reaching an instruction, for the instructions reachable from
+// entry. initialized are the registers initialized at entry.
+func (l *layout) initialized(entry int, initialized regSet) (map[int]regSet, error) {
+	states := make(map[int]regSet)
+	states[entry] = initialized
+
+	work := []int{entry}
+	for len(work) > 0 {
+		i := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		ins := l.insns[i]
+		out := states[i]&^ins.clobbers() | ins.writes()
+
+		next, err := l.successors(i)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, j := range next {
+			merged := out
+			if state, ok := states[j]; ok {
+				merged &= state
+				if merged == state {
+					continue
+				}
+			}
+			states[j] = merged
+			work = append(work, j)
+		}
+	}
+
+	return states, nil
+}
+
+// validateCalls checks the arguments of calls to built-in functions in
+// the function starting at entry.
+func (l *layout) validateCalls(entry int) error {
+	var initial regKinds
+	initial[R1] = kindContext
+	if entry != 0 {
+		for r := R1; r <= R5; r++ {
+			initial[r] = kindUnknown
+		}
+	}
+	initial[RFP] = kindPointer
+
+	states := map[int]regKinds{entry: initial}
+	work := []int{entry}
+	for len(work) > 0 {
+		i := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		out := states[i].step(l.insns[i])
+
+		next, err := l.successors(i)
+		if err != nil {
+			return err
+		}
+
+		for _, j := range next {
+			merged := out
+			if state, ok := states[j]; ok {
+				merged = state.merge(out)
+				if merged == state {
+					continue
+				}
+			}
+			states[j] = merged
+			work = append(work, j)
+		}
+	}
+
+	for i, ins := range l.insns {
+		state, ok := states[i]
+		if !ok || ins.jumpOp() != Call || isPseudoCall(ins) {
+			continue
+		}
+
+		fn := BuiltinFunc(ins.Constant)
+		for j, arg := range fn.Args() {
+			reg := R1 + Register(j)
+			kind := state[reg]
+
+			switch {
+			case kind == kindUninitialized:
+				return l.errorf(i, "argument %s of %s is uninitialized", reg, fn)
+
+			case kind == kindUnknown:
+				// For example a value loaded from the stack.
+
+			case arg == ArgMapPointer && kind != kindMapPointer:
+				return l.errorf(i, "argument %s of %s must be a map pointer, not %s", reg, fn, kind)
+
+			case arg == ArgContext && kind != kindContext:
+				return l.errorf(i, "argument %s of %s must be the context, not %s", reg, fn, kind)
+			}
+		}
+	}
+
+	return nil
+}
+
+// valueKind is what validateCalls knows about the value of a register.
+type valueKind uint8
+
+const (
+	kindUninitialized valueKind = iota
+	kindUnknown
+	kindScalar
+	kindPointer
+	kindMapPointer
+	kindContext
+)
+
+func (vk valueKind) String() string {
+	switch vk {
+	case kindUninitialized:
+		return "uninitialized"
+	case kindScalar:
+		return "a scalar"
+	case kindPointer:
+		return "a pointer"
+	case kindMapPointer:
+		return "a map pointer"
+	case kindContext:
+		return "the context"
+	default:
+		return "unknown"
+	}
+}
+
+// regKinds holds the valueKind of each register.
+type regKinds [RFP + 1]valueKind
+
+func (rk regKinds) get(r Register) valueKind {
+	if r > RFP {
+		return kindUnknown
+	}
+	return rk[r]
+}
+
+func (rk *regKinds) set(r Register, kind valueKind) {
+	if r <= RFP {
+		rk[r] = kind
+	}
+}
+
+// merge returns the kinds which hold on two paths.
+func (rk regKinds) merge(other regKinds) regKinds {
+	for r := range rk {
+		switch {
+		case rk[r] == other[r]:
+		case rk[r] == kindUninitialized || other[r] == kindUninitialized:
+			rk[r] = kindUninitialized
+		default:
+			rk[r] = kindUnknown
+		}
+	}
+	return rk
+}
+
+// step returns the kinds after executing ins.
+func (rk regKinds) step(ins Instruction) regKinds {
+	out := rk
+	for r := R1; r <= R5; r++ {
+		if ins.clobbers().has(r) {
+			out[r] = kindUninitialized
+		}
+	}
+
+	op := ins.OpCode
+	switch op.Class() {
+	case ALUClass, ALU64Class:
+		out.set(ins.Dst, rk.alu(ins))
+
+	case LdClass:
+		if op.Mode() != ImmMode {
+			out[R0] = kindScalar
+			break
+		}
+
+		switch {
+		case ins.Src == PseudoMapFD:
+			out.set(ins.Dst, kindMapPointer)
+		case ins.Src == PseudoMapValue:
+			out.set(ins.Dst, kindPointer)
+		case ins.Src == R0:
+			out.set(ins.Dst, kindScalar)
+		default:
+			out.set(ins.Dst, kindUnknown)
+		}
+
+	case JumpClass:
+		if op.JumpOp() != Call {
+			break
+		}
+
+		out[R0] = kindUnknown
+		if !isPseudoCall(ins) {
+			switch BuiltinFunc(ins.Constant).Returns() {
+			case ArgScalar:
+				out[R0] = kindScalar
+			case ArgPointer:
+				out[R0] = kindPointer
+			}
+		}
+
+	default:
+		for r := R0; r <= RFP; r++ {
+			if ins.writes().has(r) {
+				out[r] = kindUnknown
+			}
+		}
+	}
+
+	return out
+}
+
+// alu returns the kind of the result of an arithmetic instruction.
+func (rk regKinds) alu(ins Instruction) valueKind {
+	op := ins.OpCode
+	dst := rk.get(ins.Dst)
+	if op.isSwap() {
+		if dst == kindScalar {
+			return kindScalar
+		}
+		return kindUnknown
+	}
+
+	src := kindScalar
+	if op.Source() == RegSource {
+		src = rk.get(ins.Src)
+	}
+
+	aluOp := op.ALUOp()
+	switch {
+	case aluOp == Mov && op.Class() == ALU64Class:
+		return src
+	case aluOp == Mov || op.Class() == ALUClass:
+		// 32 bit operations turn pointers into numbers.
+		return kindScalar
+	case dst == kindScalar && src == kindScalar:
+		return kindScalar
+	case dst == kindPointer && src == kindScalar && (aluOp == Add || aluOp == Sub):
+		return kindPointer
+	}
+
+	return kindUnknown
+}
+
+// regSet is a bitmap of registers.
+type regSet uint16
+
+func regs(rs ...Register) regSet {
+	var set regSet
+	for _, r := range rs {
+		set |= 1 << r
+	}
+	return set
+}
+
+func (rs regSet) has(r Register) bool {
+	return rs&(1<<r) != 0
+}
+
+func (rs regSet) lowest() Register {
+	for r := R0; r <= RFP; r++ {
+		if rs.has(r) {
+			return r
+		}
+	}
+	return RFP + 1
+}
+
+// reads returns the registers an instruction reads.
+//
+// Arguments to helpers and bpf-to-bpf calls aren't tracked since their
+// number isn't known.
+func (ins Instruction) reads() regSet {
+	op := ins.OpCode
+	switch op.Class() {
+	case ALUClass, ALU64Class:
+		var rs regSet
+		if op.ALUOp() != Mov {
+			rs |= regs(ins.Dst)
+		}
+		if op.Source() == RegSource {
+			rs |= regs(ins.Src)
+		}
+		return rs
+
+	case LdClass:
+		switch op.Mode() {
+		case AbsMode:
+			return regs(R6)
+		case IndMode:
+			return regs(R6, ins.Src)
+		}
+		return 0
+
+	case LdXClass:
+		return regs(ins.Src)
+
+	case StClass:
+		return regs(ins.Dst)
+
+	case StXClass:
+		return regs(ins.Dst, ins.Src)
+
+	case JumpClass, Jump32Class:
+		switch op.JumpOp() {
+		case Ja, Call:
+			return 0
+		case Exit:
+			return regs(R0)
+		}
+		if op.Source() == RegSource {
+			return regs(ins.Dst, ins.Src)
+		}
+		return regs(ins.Dst)
+	}
+
+	return 0
+}
+
+// writes returns the registers an instruction assigns a value to.
+func (ins Instruction) writes() regSet {
+	op := ins.OpCode
+	switch op.Class() {
+	case ALUClass, ALU64Class, LdXClass:
+		return regs(ins.Dst)
+
+	case LdClass:
+		if op.Mode() == ImmMode {
+			return regs(ins.Dst)
+		}
+		return regs(R0)
+
+	case JumpClass:
+		if op.JumpOp() == Call {
+			return regs(R0)
+		}
+	}
+
+	return 0
+}
+
+// clobbers returns the registers which are uninitialized after
+// executing an instruction.
+func (ins Instruction) clobbers() regSet {
+	op := ins.OpCode
+	isLegacyLoad := op.Class() == LdClass && (op.Mode() == AbsMode || op.Mode() == IndMode)
+	if ins.jumpOp() == Call || isLegacyLoad {
+		return regs(R1, R2, R3, R4, R5)
+	}
+	return 0
+}
+
+// stackAccess returns the offset and size of an access relative to the
+// frame pointer.
+func (ins Instruction) stackAccess() (offset, size int, ok bool) {
+	op := ins.OpCode
+	switch op.Class() {
+	case LdXClass:
+		if ins.Src != RFP {
+			return 0, 0, false
+		}
+	case StClass, StXClass:
+		if ins.Dst != RFP {
+			return 0, 0, false
+		}
+	default:
+		return 0, 0, false
+	}
+
+	if mode := op.Mode(); mode != MemMode && mode != XAddMode {
+		return 0, 0, false
+	}
+
+	return int(ins.Offset), op.Size().Sizeof(), true
+}
@@ -0,0 +1,235 @@
+package asm
+
+import (
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestValidate(t *testing.T) {
+	valid := map[string]Instructions{
+		"minimal": {
+			Mov.Imm(R0, 0),
+			Return(),
+		},
+		"branches": {
+			Mov.Imm(R0, 0),
+			JEq.Imm(R1, 0, "out"),
+			LoadImm(R0, 1, DWord),
+			Return().Sym("out"),
+		},
+		"raw offsets": {
+			Mov.Imm(R0, 0),
+			{OpCode: JEq.Op(ImmSource), Dst: R1, Offset: 2},
+			LoadImm(R0, 1, DWord),
+			Return(),
+		},
+		"stack": {
+			StoreImm(RFP, -512, 0, DWord),
+			LoadMem(R0, RFP, -8, DWord),
+			Return(),
+		},
+		"bpf to bpf call": {
+			Mov.Imm(R2, 0),
+			Call.Label("fn"),
+			Return(),
+			Mov.Reg(R0, R2).Sym("fn"),
+			Return(),
+		},
+		"helper call": {
+			Mov.Reg(R6, R1),
+			StoreImm(RFP, -4, 0, Word),
+			LoadMapPtr(R1, 0),
+			Mov.Reg(R2, RFP),
+			Add.Imm(R2, -4),
+			FnMapLookupElem.Call(),
+			Mov.Reg(R1, R6),
+			Mov.Imm(R2, 0),
+			Mov.Reg(R3, RFP),
+			Add.Imm(R3, -8),
+			Mov.Imm(R4, 8),
+			FnSkbLoadBytes.Call(),
+			Return(),
+		},
+		"map pointer from stack": {
+			LoadMapPtr(R1, 0),
+			StoreMem(RFP, -8, R1, DWord),
+			LoadMem(R1, RFP, -8, DWord),
+			Mov.Reg(R2, RFP),
+			FnMapLookupElem.Call(),
+			Return(),
+		},
+	}
+
+	for name, insns := range valid {
+		t.Run(name, func(t *testing.T) {
+			if err := insns.Validate(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	invalid := map[string]struct {
+		insns Instructions
+		index int
+	}{
+		"missing exit": {
+			Instructions{Mov.Imm(R0, 0)},
+			0,
+		},
+		"fall through": {
+			Instructions{
+				Mov.Imm(R0, 0),
+				JEq.Imm(R1, 0, "out"),
+				Return(),
+				Mov.Imm(R0, 1).Sym("out"),
+				Ja.Label("out"),
+				Mov.Imm(R0, 1),
+			},
+			5,
+		},
+		"uninitialized register": {
+			Instructions{
+				Mov.Reg(R0, R2),
+				Return(),
+			},
+			0,
+		},
+		"uninitialized on some paths": {
+			Instructions{
+				JEq.Imm(R1, 0, "out"),
+				Mov.Imm(R0, 1),
+				Return().Sym("out"),
+			},
+			2,
+		},
+		"clobbered by call": {
+			Instructions{
+				FnKtimeGetNs.Call(),
+				Mov.Reg(R0, R1),
+				Return(),
+			},
+			1,
+		},
+		"uninitialized argument": {
+			Instructions{
+				LoadMapPtr(R1, 0),
+				FnMapLookupElem.Call(),
+				Return(),
+			},
+			1,
+		},
+		"argument uninitialized on some paths": {
+			Instructions{
+				LoadMapPtr(R1, 0),
+				JEq.Imm(R1, 0, "call"),
+				Mov.Reg(R2, RFP),
+				FnMapLookupElem.Call().Sym("call"),
+				Return(),
+			},
+			3,
+		},
+		"scalar instead of map pointer": {
+			Instructions{
+				Mov.Imm(R1, 3),
+				Mov.Reg(R2, RFP),
+				FnMapLookupElem.Call(),
+				Return(),
+			},
+			2,
+		},
+		"missing context": {
+			Instructions{
+				Mov.Imm(R1, 0),
+				LoadMapPtr(R2, 0),
+				Mov.Imm(R3, 0),
+				FnTailCall.Call(),
+				Return(),
+			},
+			3,
+		},
+		"jump out of bounds": {
+			Instructions{
+				Mov.Imm(R0, 0),
+				{OpCode: Ja.Op(ImmSource), Offset: 5},
+				Return(),
+			},
+			1,
+		},
+		"jump into 64 bit load": {
+			Instructions{
+				Mov.Imm(R0, 0),
+				{OpCode: Ja.Op(ImmSource), Offset: 1},
+				LoadImm(R0, 1, DWord),
+				Return(),
+			},
+			1,
+		},
+		"missing label": {
+			Instructions{
+				Mov.Imm(R0, 0),
+				Ja.Label("foo"),
+				Return(),
+			},
+			1,
+		},
+		"write to frame pointer": {
+			Instructions{
+				Mov.Imm(RFP, 0),
+				Return(),
+			},
+			0,
+		},
+		"stack underflow": {
+			Instructions{
+				Mov.Imm(R0, 0),
+				StoreImm(RFP, -520, 0, DWord),
+				Return(),
+			},
+			1,
+		},
+		"stack overflow": {
+			Instructions{
+				Mov.Imm(R0, 0),
+				StoreMem(RFP, -2, R0, Word),
+				Return(),
+			},
+			1,
+		},
+	}
+
+	for name, test := range invalid {
+		t.Run(name, func(t *testing.T) {
+			err := test.insns.Validate()
+			if err == nil {
+				t.Fatal("Validate doesn't return an error")
+			}
+
+			var ve *ValidationError
+			if !xerrors.As(err, &ve) {
+				t.Fatal("Not a ValidationError:", err)
+			}
+
+			if ve.Index != test.index {
+				t.Errorf("Expected error at instruction %d, got %v", test.index, err)
+			}
+		})
+	}
+}
+
+func TestValidationErrorSymbol(t *testing.T) {
+	insns := Instructions{
+		Mov.Imm(R0, 0).Sym("prog"),
+		Mov.Reg(R0, R3),
+		Return(),
+	}
+
+	var ve *ValidationError
+	if !xerrors.As(insns.Validate(), &ve) {
+		t.Fatal("Expected a ValidationError")
+	}
+
+	if ve.Symbol != "prog" || ve.SymbolOffset != 1 {
+		t.Errorf("Expected prog+1, got %s+%d", ve.Symbol, ve.SymbolOffset)
+	}
+}
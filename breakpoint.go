@@ -0,0 +1,195 @@
+package ebpf
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// BreakpointSpec describes the events emitted by programs instrumented
+// by InstrumentBreakpoints.
+type BreakpointSpec struct {
+	// Symbol is the name under which the instrumented programs refer to
+	// Map.
+	Symbol string
+	// Map is the RingBuf receiving the events. Read it using the ringbuf
+	// package.
+	Map *MapSpec
+	// Programs are the instrumented programs, indexed by the program ID
+	// in their events.
+	Programs []*BreakpointProgram
+}
+
+// BreakpointProgram is a program instrumented with breakpoints.
+type BreakpointProgram struct {
+	Name string
+	// Instructions of the program before instrumentation.
+	Instructions asm.Instructions
+	Breakpoints  []asm.Breakpoint
+}
+
+// InstrumentBreakpoints returns a copy of the spec which emits an event
+// with the values of registers each time one of bps is reached.
+//
+// The events are written to a RingBuf, which the instrumented program
+// refers to by BreakpointSpec.Symbol. Add the map to the Maps of a
+// CollectionSpec, or use RewriteMapPtr to load the program on its own.
+// Function and line infos are dropped, since they don't match the
+// instrumented instructions.
+//
+// Events identify the program by its index in BreakpointSpec.Programs,
+// since the kernel assigns program IDs only when loading.
+//
+// Requires at least Linux 5.8.
+func (ps *ProgramSpec) InstrumentBreakpoints(bps []asm.Breakpoint) (*ProgramSpec, *BreakpointSpec, error) {
+	bs := newBreakpointSpec(ps.Name + "_breakpoints")
+
+	cpy, err := bs.instrument(ps, bps)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cpy, bs, nil
+}
+
+// InstrumentBreakpoints instruments the programs in the collection with
+// the breakpoints given by program name, and adds the map receiving the
+// events of all of them.
+func (cs *CollectionSpec) InstrumentBreakpoints(bps map[string][]asm.Breakpoint) (*BreakpointSpec, error) {
+	bs := newBreakpointSpec("breakpoints")
+	if _, ok := cs.Maps[bs.Symbol]; ok {
+		return nil, xerrors.Errorf("map %s already exists", bs.Symbol)
+	}
+
+	// Assign program IDs in a stable order.
+	names := make([]string, 0, len(bps))
+	for name := range bps {
+		if cs.Programs[name] == nil {
+			return nil, xerrors.Errorf("program %s: %w", name, ErrNotExist)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	instrumented := make(map[string]*ProgramSpec, len(names))
+	for _, name := range names {
+		cpy, err := bs.instrument(cs.Programs[name], bps[name])
+		if err != nil {
+			return nil, err
+		}
+		instrumented[name] = cpy
+	}
+
+	for name, spec := range instrumented {
+		cs.Programs[name] = spec
+	}
+	cs.Maps[bs.Symbol] = bs.Map
+
+	return bs, nil
+}
+
+func newBreakpointSpec(symbol string) *BreakpointSpec {
+	return &BreakpointSpec{
+		Symbol: symbol,
+		Map: &MapSpec{
+			Name:       "breakpoints",
+			Type:       RingBuf,
+			MaxEntries: uint32(64 * os.Getpagesize()),
+		},
+	}
+}
+
+// instrument adds ps to the programs of bs, and returns its instrumented
+// copy.
+func (bs *BreakpointSpec) instrument(ps *ProgramSpec, bps []asm.Breakpoint) (*ProgramSpec, error) {
+	id := uint32(len(bs.Programs))
+	insns, err := ps.Instructions.InstrumentBreakpoints(bs.Symbol, id, bps)
+	if err != nil {
+		return nil, xerrors.Errorf("can't instrument program %s: %w", ps.Name, err)
+	}
+
+	bs.Programs = append(bs.Programs, &BreakpointProgram{
+		Name:         ps.Name,
+		Instructions: ps.Instructions,
+		Breakpoints:  append([]asm.Breakpoint(nil), bps...),
+	})
+
+	cpy := ps.Copy()
+	cpy.Instructions = insns
+	cpy.BTF = nil
+	return cpy, nil
+}
+
+// BreakpointEvent is a breakpoint which was reached.
+type BreakpointEvent struct {
+	Program string
+	// Index of the instruction before instrumentation.
+	Index       int
+	Instruction asm.Instruction
+	Registers   map[asm.Register]uint64
+}
+
+// Parse decodes a sample read from the ring buffer.
+func (bs *BreakpointSpec) Parse(sample []byte) (*BreakpointEvent, error) {
+	if len(sample) < asm.BreakpointEventSize {
+		return nil, xerrors.Errorf("breakpoint event of %d bytes is too short", len(sample))
+	}
+
+	id := internal.NativeEndian.Uint32(sample)
+	index := int(internal.NativeEndian.Uint32(sample[4:]))
+	if int(id) >= len(bs.Programs) {
+		return nil, xerrors.Errorf("unknown program ID %d", id)
+	}
+
+	prog := bs.Programs[id]
+	var bp *asm.Breakpoint
+	for i := range prog.Breakpoints {
+		if prog.Breakpoints[i].Index == index {
+			bp = &prog.Breakpoints[i]
+			break
+		}
+	}
+	if bp == nil {
+		return nil, xerrors.Errorf("program %s: no breakpoint at instruction %d", prog.Name, index)
+	}
+
+	values := sample[asm.BreakpointEventSize:]
+	if len(values) < 8*len(bp.Registers) {
+		return nil, xerrors.Errorf("program %s: breakpoint event at instruction %d is too short", prog.Name, index)
+	}
+
+	event := &BreakpointEvent{
+		Program:     prog.Name,
+		Index:       index,
+		Instruction: prog.Instructions[index],
+		Registers:   make(map[asm.Register]uint64, len(bp.Registers)),
+	}
+	for i, r := range bp.Registers {
+		event.Registers[r] = internal.NativeEndian.Uint64(values[i*8:])
+	}
+
+	return event, nil
+}
+
+// String formats the event as the program, index and instruction,
+// followed by the values of the registers.
+func (be *BreakpointEvent) String() string {
+	regs := make([]asm.Register, 0, len(be.Registers))
+	for r := range be.Registers {
+		regs = append(regs, r)
+	}
+	sort.Slice(regs, func(i, j int) bool { return regs[i] < regs[j] })
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %d: %v ;", be.Program, be.Index, be.Instruction)
+	for _, r := range regs {
+		fmt.Fprintf(&sb, " %s=%#x", r, be.Registers[r])
+	}
+	return sb.String()
+}
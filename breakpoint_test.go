@@ -0,0 +1,85 @@
+package ebpf
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestInstrumentBreakpoints(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "ring buffer")
+
+	spec := &CollectionSpec{
+		Maps: map[string]*MapSpec{},
+		Programs: map[string]*ProgramSpec{
+			"prog": {
+				Name: "prog",
+				Type: SocketFilter,
+				Instructions: asm.Instructions{
+					asm.LoadMem(asm.R2, asm.R1, 0, asm.Word),
+					asm.Mov.Imm(asm.R0, 0),
+					asm.JLT.Imm(asm.R2, 1000, "exit"),
+					asm.Mov.Imm(asm.R0, 1),
+					asm.Return().Sym("exit"),
+				},
+				License: "MIT",
+			},
+		},
+	}
+
+	bs, err := spec.InstrumentBreakpoints(map[string][]asm.Breakpoint{
+		"prog": {
+			{Index: 2, Registers: []asm.Register{asm.R0, asm.R2}},
+			{Index: 4, Registers: []asm.Register{asm.R0, asm.R3}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if spec.Maps[bs.Symbol] != bs.Map {
+		t.Fatal("Ring buffer wasn't added to the collection")
+	}
+
+	coll, err := NewCollection(spec)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coll.Close()
+
+	ret, _, err := coll.Programs["prog"].Test(make([]byte, 14))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != 0 {
+		t.Error("Instrumentation changed the return value to", ret)
+	}
+
+	sample := make([]byte, asm.BreakpointEventSize+16)
+	internal.NativeEndian.PutUint32(sample[4:], 2)
+	internal.NativeEndian.PutUint64(sample[8:], 0)
+	internal.NativeEndian.PutUint64(sample[16:], 42)
+
+	event, err := bs.Parse(sample)
+	if err != nil {
+		t.Fatal("Can't parse event:", err)
+	}
+
+	t.Log(event)
+
+	if event.Program != "prog" || event.Index != 2 || event.Registers[asm.R2] != 42 {
+		t.Error("Unexpected event", event)
+	}
+
+	if _, err := bs.Parse(sample[:12]); err == nil {
+		t.Error("Parsing a truncated event doesn't return an error")
+	}
+
+	internal.NativeEndian.PutUint32(sample[4:], 3)
+	if _, err := bs.Parse(sample); err == nil {
+		t.Error("Parsing an event without breakpoint doesn't return an error")
+	}
+}
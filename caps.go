@@ -0,0 +1,163 @@
+package ebpf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// Capability is a Linux capability which is checked by the bpf syscall.
+type Capability uint8
+
+// Capabilities which are relevant for eBPF.
+//
+// CAP_BPF and CAP_PERFMON were split off CAP_SYS_ADMIN in Linux 5.8,
+// CAP_SYS_ADMIN is required instead on older kernels.
+const (
+	CapNetAdmin Capability = 12
+	CapSysAdmin Capability = 21
+	CapPerfmon  Capability = 38
+	CapBPF      Capability = 39
+)
+
+func (c Capability) String() string {
+	switch c {
+	case CapNetAdmin:
+		return "CAP_NET_ADMIN"
+	case CapSysAdmin:
+		return "CAP_SYS_ADMIN"
+	case CapPerfmon:
+		return "CAP_PERFMON"
+	case CapBPF:
+		return "CAP_BPF"
+	}
+	return fmt.Sprintf("Capability(%d)", uint8(c))
+}
+
+// HaveCapability returns true if c is in the effective capability set
+// of the current process.
+func HaveCapability(c Capability) (bool, error) {
+	caps, err := internal.EffectiveCapabilities()
+	if err != nil {
+		return false, xerrors.Errorf("can't read capabilities: %w", err)
+	}
+	return caps&(1<<c) != 0, nil
+}
+
+// PermissionError is returned if the kernel refuses an operation due to
+// missing privileges.
+//
+// It wraps the original error, which can be tested for using errors.Is.
+type PermissionError struct {
+	// Op describes the denied operation.
+	Op string
+	// Missing capabilities. CAP_SYS_ADMIN may be used instead.
+	Missing []Capability
+	// UnprivilegedBPFDisabled is the value of the
+	// kernel.unprivileged_bpf_disabled sysctl, if the operation could
+	// have been performed without capabilities otherwise. It is zero in
+	// all other cases.
+	UnprivilegedBPFDisabled int
+	Err                     error
+}
+
+func (pe *PermissionError) Error() string {
+	var missing []string
+	for _, c := range pe.Missing {
+		missing = append(missing, c.String())
+	}
+
+	msg := fmt.Sprintf("%s requires %s (or %s)", pe.Op, strings.Join(missing, " and "), CapSysAdmin)
+	if pe.UnprivilegedBPFDisabled != 0 {
+		msg += fmt.Sprintf(", unprivileged eBPF is disabled by kernel.unprivileged_bpf_disabled=%d", pe.UnprivilegedBPFDisabled)
+	}
+	return fmt.Sprintf("%s: %s", msg, pe.Err)
+}
+
+func (pe *PermissionError) Unwrap() error {
+	return pe.Err
+}
+
+// wrapPermissionError explains an EPERM returned by the kernel.
+//
+// required are the capabilities checked by the kernel, and unprivileged
+// is true if the operation is permitted without them when the
+// kernel.unprivileged_bpf_disabled sysctl is zero. err is returned
+// unmodified if it isn't caused by missing capabilities.
+func wrapPermissionError(op string, required []Capability, unprivileged bool, err error) error {
+	if !xerrors.Is(err, unix.EPERM) {
+		return err
+	}
+
+	caps, capErr := internal.EffectiveCapabilities()
+	if capErr != nil || caps&(1<<CapSysAdmin) != 0 {
+		return err
+	}
+
+	var missing []Capability
+	for _, c := range required {
+		if caps&(1<<c) == 0 {
+			missing = append(missing, c)
+		}
+	}
+
+	if len(missing) == 0 {
+		return err
+	}
+
+	pe := &PermissionError{Op: op, Missing: missing, Err: err}
+	if !unprivileged {
+		return pe
+	}
+
+	disabled, sysctlErr := internal.UnprivilegedBPFDisabled()
+	if sysctlErr != nil || disabled == 0 {
+		// The operation is allowed without capabilities, so they
+		// aren't the reason for the error.
+		return err
+	}
+
+	pe.UnprivilegedBPFDisabled = disabled
+	return pe
+}
+
+// capabilities returns the capabilities needed to create a map of
+// type mt, and whether unprivileged users may create it.
+func (mt MapType) capabilities() ([]Capability, bool) {
+	switch mt {
+	case Array, PerCPUArray, ProgramArray, PerfEventArray, CGroupArray,
+		ArrayOfMaps, Hash, PerCPUHash, HashOfMaps, RingBuf,
+		CGroupStorage, PerCPUCGroupStorage:
+		return []Capability{CapBPF}, true
+
+	case SockMap, SockHash, DevMap, DevMapHash, XSKMap:
+		return []Capability{CapBPF, CapNetAdmin}, false
+	}
+
+	return []Capability{CapBPF}, false
+}
+
+// capabilities returns the capabilities needed to load a program of
+// type pt, and whether unprivileged users may load it.
+func (pt ProgramType) capabilities() ([]Capability, bool) {
+	switch pt {
+	case SocketFilter, CGroupSKB:
+		return []Capability{CapBPF}, true
+
+	case SkReuseport, LircMode2, Syscall:
+		return []Capability{CapBPF}, false
+
+	case Kprobe, TracePoint, PerfEvent, RawTracepoint, RawTracepointWritable,
+		Tracing, LSM, StructOps:
+		return []Capability{CapBPF, CapPerfmon}, false
+
+	case Extension:
+		return []Capability{CapBPF, CapPerfmon, CapNetAdmin}, false
+	}
+
+	return []Capability{CapBPF, CapNetAdmin}, false
+}
@@ -0,0 +1,44 @@
+package ebpf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+func TestHaveCapability(t *testing.T) {
+	if _, err := HaveCapability(CapBPF); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPermissionError(t *testing.T) {
+	err := xerrors.Errorf("map create: %w", &PermissionError{
+		Op:                      "creating a map of type Hash",
+		Missing:                 []Capability{CapBPF},
+		UnprivilegedBPFDisabled: 2,
+		Err:                     unix.EPERM,
+	})
+
+	if !xerrors.Is(err, unix.EPERM) {
+		t.Error("PermissionError doesn't wrap EPERM")
+	}
+
+	var pe *PermissionError
+	if !xerrors.As(err, &pe) {
+		t.Fatal("Can't get PermissionError")
+	}
+
+	for _, want := range []string{"CAP_BPF", "CAP_SYS_ADMIN", "kernel.unprivileged_bpf_disabled=2"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Error %q doesn't contain %q", err, want)
+		}
+	}
+
+	if wrapped := wrapPermissionError("op", []Capability{CapBPF}, false, unix.EINVAL); wrapped != unix.EINVAL {
+		t.Error("Errors other than EPERM are modified:", wrapped)
+	}
+}
@@ -0,0 +1,201 @@
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// ErrNotFound is returned if a cgroup or the cgroup2 mountpoint
+// can't be found.
+var ErrNotFound = xerrors.New("not found")
+
+// Mountpoint returns the location of the unified cgroup hierarchy.
+//
+// This is usually /sys/fs/cgroup, or /sys/fs/cgroup/unified on systems
+// which mount both cgroup v1 and v2.
+func Mountpoint() (string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	mnt, err := findMountpoint(f, "cgroup2")
+	if err != nil {
+		return "", xerrors.Errorf("cgroup2 mountpoint: %w", err)
+	}
+	return mnt, nil
+}
+
+// findMountpoint returns the first mountpoint of a filesystem type in
+// the format of /proc/self/mountinfo:
+//
+//	<id> <parent> <major:minor> <root> <mountpoint> <options> [<optional>...] - <type> <source> <super options>
+func findMountpoint(r io.Reader, fsType string) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		for i := 6; i < len(fields)-1; i++ {
+			if fields[i] != "-" {
+				continue
+			}
+			if fields[i+1] == fsType {
+				return unescapeMountinfo(fields[4])
+			}
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrNotFound
+}
+
+// unescapeMountinfo decodes the octal escapes the kernel uses for
+// whitespace and backslashes in mountinfo.
+func unescapeMountinfo(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+3 >= len(s) {
+			return "", xerrors.Errorf("invalid escape in %q", s)
+		}
+		c, err := strconv.ParseUint(s[i+1:i+4], 8, 8)
+		if err != nil {
+			return "", xerrors.Errorf("invalid escape in %q: %w", s, err)
+		}
+		b.WriteByte(byte(c))
+		i += 3
+	}
+	return b.String(), nil
+}
+
+// PathOfPID returns the path of the cgroup a process belongs to.
+//
+// The path is relative to the cgroup namespace of the caller.
+func PathOfPID(pid int) (string, error) {
+	mnt, err := Mountpoint()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	cg, err := findUnifiedCgroup(f)
+	if err != nil {
+		return "", xerrors.Errorf("cgroup of pid %d: %w", pid, err)
+	}
+
+	return filepath.Join(mnt, cg), nil
+}
+
+// findUnifiedCgroup returns the cgroup v2 entry from the format of
+// /proc/<pid>/cgroup, which is the one with hierarchy ID zero:
+//
+//	0::<path>
+func findUnifiedCgroup(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if cg := strings.TrimPrefix(scanner.Text(), "0::"); cg != scanner.Text() {
+			return cg, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrNotFound
+}
+
+// PathOfContainer returns the path of the cgroup of a container.
+//
+// id is the ID assigned by the container runtime, or a unique prefix of
+// it. The hierarchy is searched for a cgroup named after the container,
+// either id itself or id wrapped in a systemd scope like
+// "docker-<id>.scope" or "cri-containerd-<id>.scope".
+//
+// Returns ErrNotFound if there is no such cgroup, and an error if id is
+// ambiguous.
+func PathOfContainer(id string) (string, error) {
+	mnt, err := Mountpoint()
+	if err != nil {
+		return "", err
+	}
+
+	cg, err := findContainer(mnt, id)
+	if err != nil {
+		return "", xerrors.Errorf("cgroup of container %s: %w", id, err)
+	}
+	return cg, nil
+}
+
+func findContainer(root, id string) (string, error) {
+	if id == "" {
+		return "", xerrors.New("empty container id")
+	}
+
+	var matches []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path != root && os.IsNotExist(err) {
+				// The cgroup was removed while walking.
+				return nil
+			}
+			return err
+		}
+
+		if !info.IsDir() || path == root {
+			return nil
+		}
+
+		if !strings.HasPrefix(containerID(info.Name()), id) {
+			return nil
+		}
+
+		matches = append(matches, path)
+		// Containers may create cgroups of their own.
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", ErrNotFound
+	case 1:
+		return matches[0], nil
+	default:
+		return "", xerrors.Errorf("ambiguous id matches %s", strings.Join(matches, ", "))
+	}
+}
+
+// containerID extracts the container ID from the name of a cgroup.
+func containerID(name string) string {
+	if !strings.HasSuffix(name, ".scope") {
+		return name
+	}
+
+	name = strings.TrimSuffix(name, ".scope")
+	if i := strings.LastIndexByte(name, '-'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
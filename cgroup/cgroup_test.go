@@ -0,0 +1,169 @@
+package cgroup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+func TestFindMountpoint(t *testing.T) {
+	const mountinfo = `32 24 0:28 / /sys/fs/cgroup rw,relatime - tmpfs tmpfs rw,mode=755
+33 32 0:29 / /sys/fs/cgroup/cpu rw,relatime shared:5 - cgroup cgroup rw,cpu
+42 32 0:38 / /sys/fs/cgroup/uni\040fied rw,relatime - cgroup2 cgroup2 rw
+`
+
+	mnt, err := findMountpoint(strings.NewReader(mountinfo), "cgroup2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mnt != "/sys/fs/cgroup/uni fied" {
+		t.Error("Unexpected mountpoint", mnt)
+	}
+
+	_, err = findMountpoint(strings.NewReader(mountinfo), "bpf")
+	if !xerrors.Is(err, ErrNotFound) {
+		t.Error("Expected ErrNotFound, got", err)
+	}
+}
+
+func TestFindUnifiedCgroup(t *testing.T) {
+	cg, err := findUnifiedCgroup(strings.NewReader("4:memory:/foo\n0::/system.slice/foo.service\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cg != "/system.slice/foo.service" {
+		t.Error("Unexpected cgroup", cg)
+	}
+
+	_, err = findUnifiedCgroup(strings.NewReader("4:memory:/foo\n"))
+	if !xerrors.Is(err, ErrNotFound) {
+		t.Error("Expected ErrNotFound, got", err)
+	}
+}
+
+func TestPathOfPID(t *testing.T) {
+	if _, err := Mountpoint(); err != nil {
+		t.Skip("No cgroup2 hierarchy:", err)
+	}
+
+	cg, err := PathOfPID(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cg); err != nil {
+		t.Error("Cgroup doesn't exist:", err)
+	}
+}
+
+func TestFindContainer(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	for _, dir := range []string{
+		"system.slice/docker-abcdef.scope/nested-abcdef",
+		"system.slice/cri-containerd-abc123.scope",
+		"kubepods/burstable/pod1/fedcba",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for id, want := range map[string]string{
+		"abcdef": "system.slice/docker-abcdef.scope",
+		"abc1":   "system.slice/cri-containerd-abc123.scope",
+		"fed":    "kubepods/burstable/pod1/fedcba",
+	} {
+		have, err := findContainer(root, id)
+		if err != nil {
+			t.Errorf("%s: %s", id, err)
+			continue
+		}
+		if have != filepath.Join(root, want) {
+			t.Errorf("%s: expected %s, got %s", id, want, have)
+		}
+	}
+
+	if _, err := findContainer(root, "abc"); err == nil {
+		t.Error("Ambiguous id doesn't return an error")
+	}
+
+	if _, err := findContainer(root, "123"); !xerrors.Is(err, ErrNotFound) {
+		t.Error("Expected ErrNotFound, got", err)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	if err := os.Mkdir(filepath.Join(root, "existing"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWatcher(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for _, dir := range []string{"existing/a", "b", "b/c"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for len(seen) < 3 {
+		path, err := w.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[path] {
+			t.Error("Duplicate cgroup", path)
+		}
+		seen[path] = true
+	}
+
+	for _, dir := range []string{"existing/a", "b", "b/c"} {
+		if !seen[filepath.Join(root, dir)] {
+			t.Error("Missing cgroup", dir)
+		}
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := w.Read()
+		errs <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	w.Close()
+
+	select {
+	case err := <-errs:
+		if !xerrors.Is(err, os.ErrClosed) {
+			t.Error("Expected os.ErrClosed, got", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close doesn't interrupt Read")
+	}
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "cgroup-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -0,0 +1,8 @@
+// Package cgroup locates cgroups in the unified hierarchy (cgroup v2).
+//
+// BPF programs of type CGroupSKB, CGroupSock and similar are attached to
+// a cgroup, identified by a path below the cgroup2 mountpoint. This
+// package finds the cgroup of a process or container, and watches for
+// new cgroups so that programs can be attached to containers as they
+// are started.
+package cgroup
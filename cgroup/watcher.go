@@ -0,0 +1,170 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// ErrLostEvents is returned by Watcher.Read if the kernel dropped
+// notifications about new cgroups.
+var ErrLostEvents = xerrors.New("lost cgroup creation events")
+
+// The size of struct inotify_event without the trailing name.
+const inotifyEventSize = 16
+
+const watchMask = unix.IN_CREATE | unix.IN_MOVED_TO | unix.IN_ONLYDIR
+
+// Watcher reports cgroups created below a directory.
+//
+// It allows attaching programs to containers as they are started:
+//
+//	for {
+//		path, err := w.Read()
+//		if err != nil {
+//			return err
+//		}
+//		// Attach to the cgroup at path.
+//	}
+type Watcher struct {
+	// fd is owned by file. file.Fd mustn't be used since it makes
+	// reads blocking.
+	fd   int
+	file *os.File
+	// Maps inotify watch descriptors to directories.
+	watches map[int32]string
+	// Cgroups which haven't been returned by Read yet.
+	pending []string
+	buf     []byte
+}
+
+// NewWatcher watches root and all cgroups below it.
+//
+// root is usually the cgroup2 mountpoint returned by Mountpoint, or the
+// cgroup a container runtime creates containers in. Cgroups which exist
+// when NewWatcher is called are not reported.
+func NewWatcher(root string) (*Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, xerrors.Errorf("can't create inotify instance: %w", err)
+	}
+
+	// The file is non-blocking, which allows Close to interrupt Read.
+	w := &Watcher{
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watches: make(map[int32]string),
+		buf:     make([]byte, 4096),
+	}
+
+	if err := w.watch(root, false); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// watch adds root and all directories below it to the watcher.
+//
+// The directories are added to the pending cgroups if report is true.
+func (w *Watcher) watch(root string, report bool) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path != root && os.IsNotExist(err) {
+				// The cgroup was removed already.
+				return nil
+			}
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		wd, err := unix.InotifyAddWatch(w.fd, path, watchMask)
+		if xerrors.Is(err, unix.ENOENT) && path != root {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return xerrors.Errorf("can't watch %s: %w", path, err)
+		}
+
+		w.watches[int32(wd)] = path
+		if report {
+			w.pending = append(w.pending, path)
+		}
+		return nil
+	})
+}
+
+// Read blocks until a cgroup is created, and returns its path.
+//
+// Cgroups created concurrently with their parent may be returned before
+// the parent. Returns os.ErrClosed once the Watcher is closed, and
+// ErrLostEvents if the kernel's event queue overflowed.
+func (w *Watcher) Read() (string, error) {
+	for len(w.pending) == 0 {
+		if err := w.readEvents(); err != nil {
+			return "", err
+		}
+	}
+
+	path := w.pending[0]
+	w.pending = w.pending[1:]
+	return path, nil
+}
+
+func (w *Watcher) readEvents() error {
+	n, err := w.file.Read(w.buf)
+	if err != nil {
+		return err
+	}
+
+	for buf := w.buf[:n]; len(buf) >= inotifyEventSize; {
+		var (
+			wd      = int32(internal.NativeEndian.Uint32(buf[0:4]))
+			mask    = internal.NativeEndian.Uint32(buf[4:8])
+			nameLen = int(internal.NativeEndian.Uint32(buf[12:16]))
+		)
+		if inotifyEventSize+nameLen > len(buf) {
+			return xerrors.New("truncated inotify event")
+		}
+
+		name := buf[inotifyEventSize : inotifyEventSize+nameLen]
+		buf = buf[inotifyEventSize+nameLen:]
+
+		switch {
+		case mask&unix.IN_Q_OVERFLOW != 0:
+			return ErrLostEvents
+
+		case mask&unix.IN_IGNORED != 0:
+			// The directory was removed.
+			delete(w.watches, wd)
+
+		case mask&unix.IN_ISDIR != 0:
+			dir, ok := w.watches[wd]
+			if !ok {
+				continue
+			}
+
+			path := filepath.Join(dir, internal.CString(name))
+			if err := w.watch(path, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close stops watching for new cgroups.
+//
+// It interrupts a concurrent call to Read.
+func (w *Watcher) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,396 @@
+// Command ebpf-inspect shows eBPF objects loaded into the kernel.
+//
+// Usage:
+//
+//	ebpf-inspect prog list
+//	ebpf-inspect prog xlated <id|path>
+//	ebpf-inspect prog jited <id|path>
+//	ebpf-inspect map list
+//	ebpf-inspect map dump [-json] <id|path>
+//	ebpf-inspect link list
+//	ebpf-inspect pin prog|map|link <id> <path>
+//	ebpf-inspect unpin <path>
+//
+// Objects are identified by their ID, or by a path in bpffs. Most
+// commands require CAP_SYS_ADMIN.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	"golang.org/x/xerrors"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+const usage = `usage: ebpf-inspect <command>
+
+commands:
+  prog list
+  prog xlated <id|path>
+  prog jited <id|path>
+  map list
+  map dump [-json] <id|path>
+  link list
+  pin prog|map|link <id> <path>
+  unpin <path>`
+
+var errUsage = xerrors.New(usage)
+
+func run(args []string, w io.Writer) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+
+	switch args[0] + " " + args[1] {
+	case "prog list":
+		return listPrograms(w)
+	case "prog xlated":
+		return withProgram(args[2:], func(prog *ebpf.Program) error {
+			return showXlated(w, prog)
+		})
+	case "prog jited":
+		return withProgram(args[2:], func(prog *ebpf.Program) error {
+			return showJITed(w, prog)
+		})
+	case "map list":
+		return listMaps(w)
+	case "map dump":
+		asJSON := len(args) > 2 && args[2] == "-json"
+		if asJSON {
+			args = args[1:]
+		}
+		return withMap(args[2:], func(m *ebpf.Map) error {
+			return dumpMap(w, m, asJSON)
+		})
+	case "link list":
+		return listLinks(w)
+	}
+
+	switch args[0] {
+	case "pin":
+		if len(args) != 4 {
+			return errUsage
+		}
+		return pin(args[1], args[2], args[3])
+	case "unpin":
+		if len(args) != 2 {
+			return errUsage
+		}
+		return os.Remove(args[1])
+	}
+
+	return errUsage
+}
+
+func listPrograms(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTYPE\tNAME\tRUNS\tRUNTIME")
+
+	var id ebpf.ProgramID
+	for {
+		var err error
+		id, err = ebpf.ProgramGetNextID(id)
+		if xerrors.Is(err, ebpf.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		prog, err := ebpf.NewProgramFromID(id)
+		if xerrors.Is(err, ebpf.ErrNotExist) {
+			// The program was unloaded in the meantime.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var name string
+		if spec, err := prog.Spec(); err == nil {
+			name = spec.Name
+		}
+
+		var runs, runtime string
+		if stats, err := prog.Stats(); err == nil {
+			runs, runtime = strconv.FormatUint(stats.RunCount, 10), stats.Runtime.String()
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", id, prog.ABI().Type, name, runs, runtime)
+		prog.Close()
+	}
+
+	return tw.Flush()
+}
+
+func listMaps(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTYPE\tNAME\tKEY\tVALUE\tMAX ENTRIES")
+
+	var id ebpf.MapID
+	for {
+		var err error
+		id, err = ebpf.MapGetNextID(id)
+		if xerrors.Is(err, ebpf.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		m, err := ebpf.NewMapFromID(id)
+		if xerrors.Is(err, ebpf.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		spec := m.Spec()
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%d\n", id, spec.Type, spec.Name, spec.KeySize, spec.ValueSize, spec.MaxEntries)
+		m.Close()
+	}
+
+	return tw.Flush()
+}
+
+func listLinks(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTYPE\tPROGRAM")
+
+	var id link.ID
+	for {
+		var err error
+		id, err = link.GetNextID(id)
+		if xerrors.Is(err, ebpf.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		l, err := link.NewFromID(id)
+		if xerrors.Is(err, ebpf.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		info, err := l.Info()
+		l.Close()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%d\n", info.ID, info.Type, info.Program)
+	}
+
+	return tw.Flush()
+}
+
+func showXlated(w io.Writer, prog *ebpf.Program) error {
+	insns, err := prog.XlatedInstructions()
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%v", insns)
+	return err
+}
+
+func showJITed(w io.Writer, prog *ebpf.Program) error {
+	image, err := prog.JITedImage()
+	if err != nil {
+		return err
+	}
+
+	if len(image.Functions) == 0 {
+		return hexdump(w, 0, image.Code)
+	}
+
+	for i, fn := range image.Functions {
+		fmt.Fprintf(w, "function %d at %#x:\n", i, fn.Address)
+		if err := hexdump(w, fn.Address, fn.Code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hexdump(w io.Writer, addr uint64, code []byte) error {
+	for off := 0; off < len(code); off += 16 {
+		end := off + 16
+		if end > len(code) {
+			end = len(code)
+		}
+
+		if _, err := fmt.Fprintf(w, "%16x: % x\n", addr+uint64(off), code[off:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dumpMap(w io.Writer, m *ebpf.Map, asJSON bool) error {
+	var buf bytes.Buffer
+	if err := m.Dump(&buf); err != nil {
+		return err
+	}
+
+	if asJSON {
+		_, err := buf.WriteTo(w)
+		return err
+	}
+
+	var entries []struct {
+		Key    []string `json:"key"`
+		Value  []string `json:"value"`
+		Values []struct {
+			CPU   int      `json:"cpu"`
+			Value []string `json:"value"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		return err
+	}
+
+	// Dump contains the raw bytes, decode them again to use the
+	// formatter of the map.
+	for _, entry := range entries {
+		key, err := parseHex(entry.Key)
+		if err != nil {
+			return err
+		}
+
+		if entry.Values == nil {
+			value, err := parseHex(entry.Value)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(w, "%v: %v\n", m.FormatKey(key), m.FormatValue(value))
+			continue
+		}
+
+		fmt.Fprintf(w, "%v:\n", m.FormatKey(key))
+		for _, v := range entry.Values {
+			value, err := parseHex(v.Value)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(w, "\tcpu %d: %v\n", v.CPU, m.FormatValue(value))
+		}
+	}
+
+	return nil
+}
+
+func parseHex(strs []string) ([]byte, error) {
+	buf := make([]byte, len(strs))
+	for i, str := range strs {
+		b, err := strconv.ParseUint(str, 0, 8)
+		if err != nil {
+			return nil, err
+		}
+		buf[i] = byte(b)
+	}
+	return buf, nil
+}
+
+func pin(kind, ref, path string) error {
+	id, err := strconv.ParseUint(ref, 10, 32)
+	if err != nil {
+		return xerrors.Errorf("invalid ID %q", ref)
+	}
+
+	switch kind {
+	case "prog":
+		prog, err := ebpf.NewProgramFromID(ebpf.ProgramID(id))
+		if err != nil {
+			return err
+		}
+		defer prog.Close()
+		return prog.Pin(path)
+
+	case "map":
+		m, err := ebpf.NewMapFromID(ebpf.MapID(id))
+		if err != nil {
+			return err
+		}
+		defer m.Close()
+		return m.Pin(path)
+
+	case "link":
+		l, err := link.NewFromID(link.ID(id))
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		return l.Pin(path)
+	}
+
+	return errUsage
+}
+
+// withProgram calls fn with the program identified by args.
+func withProgram(args []string, fn func(*ebpf.Program) error) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	var (
+		prog *ebpf.Program
+		err  error
+	)
+	if id, parseErr := strconv.ParseUint(args[0], 10, 32); parseErr == nil {
+		prog, err = ebpf.NewProgramFromID(ebpf.ProgramID(id))
+	} else {
+		prog, err = ebpf.LoadPinnedProgram(args[0])
+	}
+	if err != nil {
+		return err
+	}
+	defer prog.Close()
+
+	return fn(prog)
+}
+
+// withMap calls fn with the map identified by args.
+func withMap(args []string, fn func(*ebpf.Map) error) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	var (
+		m   *ebpf.Map
+		err error
+	)
+	if id, parseErr := strconv.ParseUint(args[0], 10, 32); parseErr == nil {
+		m, err = ebpf.NewMapFromID(ebpf.MapID(id))
+	} else {
+		m, err = ebpf.LoadPinnedMap(args[0])
+	}
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return fn(m)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	"golang.org/x/xerrors"
+)
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"prog"},
+		{"prog", "bogus"},
+		{"prog", "xlated"},
+		{"pin", "prog", "1"},
+		{"unpin"},
+	} {
+		if err := run(args, ioutil.Discard); !xerrors.Is(err, errUsage) {
+			t.Errorf("%q: expected usage, got %v", args, err)
+		}
+	}
+
+	if err := run([]string{"pin", "prog", "abc", "/sys/fs/bpf/x"}, ioutil.Discard); err == nil {
+		t.Error("pin accepts an invalid ID")
+	}
+}
+
+func TestMap(t *testing.T) {
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Name:       "inspect_test",
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := m.Put(uint32(0), uint32(0x2a)); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := m.ID()
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := runCommand(t, "map", "list")
+	if !strings.Contains(out, fmt.Sprintf("%d  ", id)) || !strings.Contains(out, "inspect_test") {
+		t.Errorf("map list doesn't contain map %d:\n%s", id, out)
+	}
+
+	out = runCommand(t, "map", "dump", fmt.Sprint(id))
+	if !strings.Contains(out, "2a000000") {
+		t.Errorf("map dump doesn't contain the value:\n%s", out)
+	}
+
+	out = runCommand(t, "map", "dump", "-json", fmt.Sprint(id))
+	if !strings.Contains(out, `"0x2a"`) {
+		t.Errorf("JSON dump doesn't contain the value:\n%s", out)
+	}
+
+	tmp, err := ioutil.TempDir("/sys/fs/bpf", "ebpf-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	path := filepath.Join(tmp, "map")
+	runCommand(t, "pin", "map", fmt.Sprint(id), path)
+
+	out = runCommand(t, "map", "dump", path)
+	if !strings.Contains(out, "2a000000") {
+		t.Errorf("Dump of pinned map doesn't contain the value:\n%s", out)
+	}
+
+	runCommand(t, "unpin", path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("unpin doesn't remove the pin:", err)
+	}
+}
+
+func TestProgram(t *testing.T) {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name: "inspect_test",
+		Type: ebpf.SocketFilter,
+		Instructions: asm.Instructions{
+			asm.LoadImm(asm.R0, 0, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	id, err := prog.ID()
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := runCommand(t, "prog", "list")
+	if !strings.Contains(out, fmt.Sprintf("%d  ", id)) {
+		t.Errorf("prog list doesn't contain program %d:\n%s", id, out)
+	}
+
+	out = runCommand(t, "prog", "xlated", fmt.Sprint(id))
+	if !strings.Contains(out, "Exit") {
+		t.Errorf("prog xlated doesn't show the instructions:\n%s", out)
+	}
+}
+
+func runCommand(t *testing.T, args ...string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := run(args, &buf); err != nil {
+		t.Fatalf("%q: %s", args, err)
+	}
+	return buf.String()
+}
@@ -1,17 +1,121 @@
 package ebpf
 
 import (
+	"fmt"
+	"hash"
 	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 
 	"github.com/cilium/ebpf/asm"
 	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/btf"
+	"github.com/cilium/ebpf/internal/unix"
 	"golang.org/x/xerrors"
 )
 
 // CollectionOptions control loading a collection into the kernel.
 type CollectionOptions struct {
 	Programs ProgramOptions
+
+	// RequireBTF fails loading the collection if the kernel rejects the
+	// BTF of a map or program. By default, maps and programs are created
+	// without BTF instead, unless they can't work without it.
+	RequireBTF bool
+
+	// ProgramFilter selects which programs are loaded. Only maps
+	// referenced by at least one of the selected programs are created.
+	//
+	// All programs and maps are loaded if ProgramFilter is nil.
+	ProgramFilter func(name string) bool
+
+	// MapReplacements takes a set of Maps that will be used instead of
+	// creating new ones when loading the CollectionSpec.
+	//
+	// Each replacement Map must be compatible with the MapSpec of the
+	// same name, otherwise ErrMapIncompatible is returned. The
+	// Collection holds a clone of each replacement, which means the
+	// caller remains responsible for closing the Map passed in.
+	MapReplacements map[string]*Map
+
+	// Limits are checked before any maps or programs are created.
+	Limits Limits
+
+	// VerifyProgram is called for every program in the spec before any
+	// maps or programs are created, regardless of ProgramFilter. Loading
+	// fails if it returns an error.
+	//
+	// Use it to check a detached signature over ProgramSpec.Digest, for
+	// environments which require the provenance of programs to be known.
+	// The digest of a program doesn't cover the maps it uses, check a
+	// signature over CollectionSpec.Digest before loading to include them.
+	// Signatures over object files can be checked before calling
+	// LoadCollectionSpecFromReader instead.
+	VerifyProgram func(name string, spec *ProgramSpec) error
+}
+
+// ErrLimitExceeded is returned if a CollectionSpec exceeds Limits.
+var ErrLimitExceeded = xerrors.New("limit exceeded")
+
+// Limits restricts the resources used by a CollectionSpec, for example
+// when loading object files from untrusted sources.
+//
+// A limit of zero means that there is no limit.
+type Limits struct {
+	// MaxInstructions limits the total number of instructions of all
+	// programs in the spec, regardless of ProgramFilter. Wide
+	// instructions count twice, like in the kernel.
+	MaxInstructions int
+
+	// MaxEntries limits MaxEntries of each map.
+	MaxEntries uint32
+
+	// MaxValueSize limits ValueSize of each map.
+	MaxValueSize uint32
+}
+
+// check returns an error wrapping ErrLimitExceeded if the spec uses more
+// resources than allowed. Maps in replacements aren't checked, since they
+// aren't created.
+func (l *Limits) check(cs *CollectionSpec, replacements map[string]*Map) error {
+	if l.MaxInstructions > 0 {
+		var total int
+		for _, spec := range cs.Programs {
+			total += spec.Instructions.Size() / asm.InstructionSize
+		}
+
+		if total > l.MaxInstructions {
+			return xerrors.Errorf("%d instructions exceed maximum of %d: %w", total, l.MaxInstructions, ErrLimitExceeded)
+		}
+	}
+
+	for name, spec := range cs.Maps {
+		if _, ok := replacements[name]; ok {
+			continue
+		}
+
+		for ; spec != nil; spec = spec.InnerMap {
+			if err := l.checkMap(spec); err != nil {
+				return xerrors.Errorf("map %s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l *Limits) checkMap(spec *MapSpec) error {
+	if l.MaxEntries > 0 && spec.MaxEntries > l.MaxEntries {
+		return xerrors.Errorf("%d entries exceed maximum of %d: %w", spec.MaxEntries, l.MaxEntries, ErrLimitExceeded)
+	}
+
+	if l.MaxValueSize > 0 && spec.ValueSize > l.MaxValueSize {
+		return xerrors.Errorf("value size %d exceeds maximum of %d: %w", spec.ValueSize, l.MaxValueSize, ErrLimitExceeded)
+	}
+
+	return nil
 }
 
 // CollectionSpec describes a collection.
@@ -42,6 +146,49 @@ func (cs *CollectionSpec) Copy() *CollectionSpec {
 	return &cpy
 }
 
+// Digest writes the maps and programs of the spec to h, for example to
+// compute or check a signature over everything which is loaded.
+//
+// Programs are covered as by ProgramSpec.Digest, maps by their
+// attributes and initial contents, which includes constants in .rodata.
+// Contents which aren't byte slices are marshaled in the byte order of
+// the host.
+func (cs *CollectionSpec) Digest(h hash.Hash) error {
+	mapNames := make([]string, 0, len(cs.Maps))
+	for name := range cs.Maps {
+		mapNames = append(mapNames, name)
+	}
+	sort.Strings(mapNames)
+
+	for _, name := range mapNames {
+		if _, err := fmt.Fprintf(h, "map\x00%s\x00", name); err != nil {
+			return err
+		}
+
+		if err := cs.Maps[name].digest(h); err != nil {
+			return xerrors.Errorf("map %s: %w", name, err)
+		}
+	}
+
+	progNames := make([]string, 0, len(cs.Programs))
+	for name := range cs.Programs {
+		progNames = append(progNames, name)
+	}
+	sort.Strings(progNames)
+
+	for _, name := range progNames {
+		if _, err := fmt.Fprintf(h, "program\x00%s\x00", name); err != nil {
+			return err
+		}
+
+		if err := cs.Programs[name].Digest(h); err != nil {
+			return xerrors.Errorf("program %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
 // RewriteMaps replaces all references to specific maps.
 //
 // Use this function to use pre-existing maps instead of creating new ones
@@ -125,6 +272,111 @@ func (cs *CollectionSpec) RewriteConstants(consts map[string]interface{}) error
 	return nil
 }
 
+// Assign the contents of a CollectionSpec to a struct.
+//
+// This function is a short-cut to manually checking the presence
+// of maps and programs in a collection spec. Consider using bpf2go if this
+// sounds useful.
+//
+// The argument to must be a pointer to a struct. A field of the
+// struct is updated with values from Programs or Maps if it
+// has an `ebpf` tag and its type is *ProgramSpec or *MapSpec.
+// The tag gives the name of the program or map as found in
+// the CollectionSpec.
+//
+//    struct {
+//        Foo     *ebpf.ProgramSpec `ebpf:"xdp_foo"`
+//        Bar     *ebpf.MapSpec     `ebpf:"bar_map"`
+//        Ignored int
+//    }
+//
+// Returns an error if any of the fields can't be found, or
+// if the same spec is assigned multiple times.
+func (cs *CollectionSpec) Assign(to interface{}) error {
+	getValue := func(typ reflect.Type, name string) (interface{}, error) {
+		switch typ {
+		case reflect.TypeOf((*ProgramSpec)(nil)):
+			p := cs.Programs[name]
+			if p == nil {
+				return nil, xerrors.Errorf("missing program %q", name)
+			}
+			return p, nil
+		case reflect.TypeOf((*MapSpec)(nil)):
+			m := cs.Maps[name]
+			if m == nil {
+				return nil, xerrors.Errorf("missing map %q", name)
+			}
+			return m, nil
+		default:
+			return nil, xerrors.Errorf("unsupported type %s", typ)
+		}
+	}
+
+	return assignValues(to, getValue)
+}
+
+// LoadAndAssign creates a collection from a spec, and assigns it to a struct.
+//
+// Only the programs and maps named in the struct are loaded into the
+// kernel, as well as any maps referenced by these programs. Maps which
+// are only needed by a program are closed once the program is loaded.
+//
+// The argument to must be a pointer to a struct. A field of the
+// struct is updated with values from Programs or Maps if it
+// has an `ebpf` tag and its type is *Program or *Map.
+// The tag gives the name of the program or map as found in
+// the CollectionSpec.
+//
+//    struct {
+//        Foo     *ebpf.Program `ebpf:"xdp_foo"`
+//        Bar     *ebpf.Map     `ebpf:"bar_map"`
+//        Ignored int
+//    }
+//
+// opts may be nil. opts.ProgramFilter is ignored.
+//
+// Returns an error if any of the fields can't be found, or if the same
+// object is assigned multiple times. No field of to is modified and all
+// objects are closed if an error is returned.
+func (cs *CollectionSpec) LoadAndAssign(to interface{}, opts *CollectionOptions) error {
+	loader, err := newCollectionLoader(cs, opts)
+	if err != nil {
+		return err
+	}
+	defer loader.cleanup()
+
+	assignedMaps := make(map[string]bool)
+	assignedProgs := make(map[string]bool)
+
+	getValue := func(typ reflect.Type, name string) (interface{}, error) {
+		switch typ {
+		case reflect.TypeOf((*Program)(nil)):
+			assignedProgs[name] = true
+			return loader.loadProgram(name)
+		case reflect.TypeOf((*Map)(nil)):
+			assignedMaps[name] = true
+			return loader.loadMap(name)
+		default:
+			return nil, xerrors.Errorf("unsupported type %s", typ)
+		}
+	}
+
+	if err := assignValues(to, getValue); err != nil {
+		return err
+	}
+
+	// Hand over ownership of assigned objects, cleanup closes the rest.
+	for name := range assignedMaps {
+		delete(loader.maps, name)
+	}
+
+	for name := range assignedProgs {
+		delete(loader.programs, name)
+	}
+
+	return nil
+}
+
 // Collection is a collection of Programs and Maps associated
 // with their symbols
 type Collection struct {
@@ -141,112 +393,274 @@ func NewCollection(spec *CollectionSpec) (*Collection, error) {
 
 // NewCollectionWithOptions creates a Collection from a specification.
 //
-// Only maps referenced by at least one of the programs are initialized.
-func NewCollectionWithOptions(spec *CollectionSpec, opts CollectionOptions) (coll *Collection, err error) {
-	var (
-		maps  = make(map[string]*Map)
-		progs = make(map[string]*Program)
-		btfs  = make(map[*btf.Spec]*btf.Handle)
-	)
+// Only maps referenced by at least one of the programs are initialized
+// if opts.ProgramFilter is set.
+func NewCollectionWithOptions(spec *CollectionSpec, opts CollectionOptions) (*Collection, error) {
+	loader, err := newCollectionLoader(spec, &opts)
+	if err != nil {
+		return nil, err
+	}
+	defer loader.cleanup()
 
-	defer func() {
-		for _, btf := range btfs {
-			btf.Close()
+	for progName := range spec.Programs {
+		if opts.ProgramFilter != nil && !opts.ProgramFilter(progName) {
+			continue
 		}
 
-		if err == nil {
-			return
+		if _, err := loader.loadProgram(progName); err != nil {
+			return nil, err
 		}
+	}
 
-		for _, m := range maps {
-			m.Close()
+	if opts.ProgramFilter == nil {
+		for mapName := range spec.Maps {
+			if _, err := loader.loadMap(mapName); err != nil {
+				return nil, err
+			}
 		}
+	}
+
+	maps, progs := loader.maps, loader.programs
+	loader.finalize()
+
+	return &Collection{
+		progs,
+		maps,
+	}, nil
+}
+
+// collectionLoader creates the objects of a CollectionSpec on demand.
+type collectionLoader struct {
+	coll     *CollectionSpec
+	opts     *CollectionOptions
+	btfs     map[*btf.Spec]*btf.Handle
+	maps     map[string]*Map
+	programs map[string]*Program
+}
+
+func newCollectionLoader(coll *CollectionSpec, opts *CollectionOptions) (*collectionLoader, error) {
+	if opts == nil {
+		opts = &CollectionOptions{}
+	}
+
+	for name, m := range opts.MapReplacements {
+		if m == nil {
+			return nil, xerrors.Errorf("replacement map %s is nil", name)
+		}
+		if _, ok := coll.Maps[name]; !ok {
+			return nil, xerrors.Errorf("replacement map %s not found in CollectionSpec", name)
+		}
+	}
+
+	if err := opts.Limits.check(coll, opts.MapReplacements); err != nil {
+		return nil, err
+	}
+
+	if opts.VerifyProgram != nil {
+		for name, spec := range coll.Programs {
+			if err := opts.VerifyProgram(name, spec); err != nil {
+				return nil, xerrors.Errorf("program %s: verification failed: %w", name, err)
+			}
+		}
+	}
+
+	return &collectionLoader{
+		coll,
+		opts,
+		make(map[*btf.Spec]*btf.Handle),
+		make(map[string]*Map),
+		make(map[string]*Program),
+	}, nil
+}
+
+// finalize hands over ownership of all created objects to the caller.
+func (cl *collectionLoader) finalize() {
+	cl.maps = nil
+	cl.programs = nil
+}
+
+// cleanup frees BTF handles, as well as any objects which haven't been
+// handed over by finalize.
+func (cl *collectionLoader) cleanup() {
+	for _, handle := range cl.btfs {
+		handle.Close()
+	}
+
+	for _, m := range cl.maps {
+		m.Close()
+	}
+
+	for _, p := range cl.programs {
+		p.Close()
+	}
+}
+
+func (cl *collectionLoader) loadBTF(spec *btf.Spec) (*btf.Handle, error) {
+	if cl.btfs[spec] != nil {
+		return cl.btfs[spec], nil
+	}
+
+	handle, err := btf.NewHandle(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	cl.btfs[spec] = handle
+	return handle, nil
+}
+
+func (cl *collectionLoader) loadMap(mapName string) (*Map, error) {
+	if m := cl.maps[mapName]; m != nil {
+		return m, nil
+	}
+
+	mapSpec := cl.coll.Maps[mapName]
+	if mapSpec == nil {
+		return nil, xerrors.Errorf("missing map %s", mapName)
+	}
 
-		for _, p := range progs {
-			p.Close()
+	if replacement, ok := cl.opts.MapReplacements[mapName]; ok {
+		if err := mapSpec.checkCompatible(replacement); err != nil {
+			return nil, xerrors.Errorf("map %s: %w", mapName, err)
 		}
-	}()
 
-	loadBTF := func(spec *btf.Spec) (*btf.Handle, error) {
-		if btfs[spec] != nil {
-			return btfs[spec], nil
+		m, err := replacement.Clone()
+		if err != nil {
+			return nil, xerrors.Errorf("map %s: %w", mapName, err)
 		}
+		internal.Debug("Using replacement map", "map", mapName)
 
-		handle, err := btf.NewHandle(spec)
+		cl.maps[mapName] = m
+		return m, nil
+	}
+
+	var handle *btf.Handle
+	if mapSpec.BTF != nil {
+		var err error
+		handle, err = cl.loadBTF(btf.MapSpec(mapSpec.BTF))
+		if err != nil && cl.opts.RequireBTF {
+			return nil, xerrors.Errorf("map %s: can't load BTF: %w", mapName, err)
+		}
 		if err != nil {
-			return nil, err
+			internal.Debug("Can't load BTF, creating map without it", "map", mapName, "error", err)
 		}
+	}
 
-		btfs[spec] = handle
-		return handle, nil
+	// Contents which refer to programs are added once the map exists,
+	// since the programs may in turn refer to the map.
+	var (
+		deferred []MapKV
+		spec     = mapSpec
+	)
+	for _, kv := range mapSpec.Contents {
+		if name, _ := programReference(kv.Value); name != "" {
+			deferred = append(deferred, kv)
+		}
 	}
 
-	for mapName, mapSpec := range spec.Maps {
-		var handle *btf.Handle
-		if mapSpec.BTF != nil {
-			handle, err = loadBTF(btf.MapSpec(mapSpec.BTF))
-			if err != nil && !xerrors.Is(err, btf.ErrNotSupported) {
-				return nil, err
+	if len(deferred) > 0 {
+		spec = mapSpec.Copy()
+		spec.Contents = nil
+		spec.Freeze = false
+		for _, kv := range mapSpec.Contents {
+			if name, _ := programReference(kv.Value); name == "" {
+				spec.Contents = append(spec.Contents, kv)
 			}
 		}
+	}
 
-		m, err := newMapWithBTF(mapSpec, handle)
+	m, err := newMapWithBTF(spec, handle, cl.opts.RequireBTF)
+	if err != nil {
+		return nil, xerrors.Errorf("map %s: %w", mapName, err)
+	}
+	internal.Debug("Created map", "map", mapName, "type", spec.Type)
+
+	cl.maps[mapName] = m
+
+	for _, kv := range deferred {
+		progName, resolve := programReference(kv.Value)
+		prog, err := cl.loadProgram(progName)
 		if err != nil {
 			return nil, xerrors.Errorf("map %s: %w", mapName, err)
 		}
-		maps[mapName] = m
+
+		if err := m.Put(kv.Key, resolve(prog)); err != nil {
+			return nil, xerrors.Errorf("map %s: key %v: %w", mapName, kv.Key, err)
+		}
 	}
 
-	for progName, origProgSpec := range spec.Programs {
-		progSpec := origProgSpec.Copy()
+	if len(deferred) > 0 && mapSpec.Freeze {
+		if err := m.Freeze(); err != nil {
+			return nil, xerrors.Errorf("map %s: can't freeze map: %w", mapName, err)
+		}
+	}
 
-		// Rewrite any reference to a valid map.
-		for i := range progSpec.Instructions {
-			ins := &progSpec.Instructions[i]
+	return m, nil
+}
 
-			if ins.OpCode != asm.LoadImmOp(asm.DWord) || ins.Reference == "" {
-				continue
-			}
+func (cl *collectionLoader) loadProgram(progName string) (*Program, error) {
+	if prog := cl.programs[progName]; prog != nil {
+		return prog, nil
+	}
 
-			if uint32(ins.Constant) != math.MaxUint32 {
-				// Don't overwrite maps already rewritten, users can
-				// rewrite programs in the spec themselves
-				continue
-			}
+	origProgSpec := cl.coll.Programs[progName]
+	if origProgSpec == nil {
+		return nil, xerrors.Errorf("missing program %s", progName)
+	}
 
-			m := maps[ins.Reference]
-			if m == nil {
-				return nil, xerrors.Errorf("program %s: missing map %s", progName, ins.Reference)
-			}
+	progSpec := origProgSpec.Copy()
 
-			fd := m.FD()
-			if fd < 0 {
-				return nil, xerrors.Errorf("map %s: %w", ins.Reference, internal.ErrClosedFd)
-			}
-			if err := ins.RewriteMapPtr(m.FD()); err != nil {
-				return nil, xerrors.Errorf("progam %s: map %s: %w", progName, ins.Reference, err)
-			}
+	// Rewrite any reference to a valid map.
+	for i := range progSpec.Instructions {
+		ins := &progSpec.Instructions[i]
+
+		if ins.OpCode != asm.LoadImmOp(asm.DWord) || ins.Reference == "" {
+			continue
 		}
 
-		var handle *btf.Handle
-		if progSpec.BTF != nil {
-			handle, err = loadBTF(btf.ProgramSpec(progSpec.BTF))
-			if err != nil && !xerrors.Is(err, btf.ErrNotSupported) {
-				return nil, err
-			}
+		if uint32(ins.Constant) != math.MaxUint32 {
+			// Don't overwrite maps already rewritten, users can
+			// rewrite programs in the spec themselves
+			continue
 		}
 
-		prog, err := newProgramWithBTF(progSpec, handle, opts.Programs)
+		m, err := cl.loadMap(ins.Reference)
 		if err != nil {
 			return nil, xerrors.Errorf("program %s: %w", progName, err)
 		}
-		progs[progName] = prog
+
+		fd := m.FD()
+		if fd < 0 {
+			return nil, xerrors.Errorf("map %s: %w", ins.Reference, internal.ErrClosedFd)
+		}
+		if err := ins.RewriteMapPtr(m.FD()); err != nil {
+			return nil, xerrors.Errorf("progam %s: map %s: %w", progName, ins.Reference, err)
+		}
 	}
 
-	return &Collection{
-		progs,
-		maps,
-	}, nil
+	var handle *btf.Handle
+	if progSpec.BTF != nil {
+		var err error
+		handle, err = cl.loadBTF(btf.ProgramSpec(progSpec.BTF))
+		if err != nil && cl.opts.RequireBTF {
+			return nil, xerrors.Errorf("program %s: can't load BTF: %w", progName, err)
+		}
+		if err != nil {
+			internal.Debug("Can't load BTF, loading program without it", "program", progName, "error", err)
+		}
+	}
+
+	progOpts := cl.opts.Programs
+	progOpts.RequireBTF = progOpts.RequireBTF || cl.opts.RequireBTF
+
+	prog, err := newProgramWithSplit(progSpec, handle, progOpts)
+	if err != nil {
+		return nil, xerrors.Errorf("program %s: %w", progName, err)
+	}
+
+	internal.Debug("Loaded program", "program", progName, "type", progSpec.Type)
+	cl.programs[progName] = prog
+	return prog, nil
 }
 
 // LoadCollection parses an object file and converts it to a collection.
@@ -291,3 +705,143 @@ func (coll *Collection) DetachProgram(name string) *Program {
 	delete(coll.Programs, name)
 	return p
 }
+
+// Pin persists all maps and programs of the collection in dir, using
+// their names in the collection as file names.
+//
+// The objects are pinned to a temporary directory next to dir first,
+// which then atomically replaces dir. Other processes either see the
+// previous contents of dir or all objects of the collection, never a
+// mix of both. Objects which were pinned in dir before are unpinned.
+//
+// Replacing an existing dir requires support for RENAME_EXCHANGE on
+// bpffs, which was added in Linux 5.15.
+func (coll *Collection) Pin(dir string) error {
+	dir = filepath.Clean(dir)
+	tmp := fmt.Sprintf("%s_pin_%d", dir, os.Getpid())
+	if err := os.Mkdir(tmp, 0755); err != nil {
+		return xerrors.Errorf("pin collection: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	for name, m := range coll.Maps {
+		if err := m.Pin(filepath.Join(tmp, name)); err != nil {
+			return xerrors.Errorf("pin map %s: %w", name, err)
+		}
+	}
+	for name, prog := range coll.Programs {
+		if err := prog.Pin(filepath.Join(tmp, name)); err != nil {
+			return xerrors.Errorf("pin program %s: %w", name, err)
+		}
+	}
+
+	// Swap tmp and dir, the deferred RemoveAll then deletes the old pins.
+	err := unix.Renameat2(unix.AT_FDCWD, tmp, unix.AT_FDCWD, dir, unix.RENAME_EXCHANGE)
+	if xerrors.Is(err, unix.ENOENT) {
+		err = os.Rename(tmp, dir)
+	}
+	if err != nil {
+		return xerrors.Errorf("pin collection: replace %s: %w", dir, err)
+	}
+
+	return nil
+}
+
+// structField is a field of a struct which may have an ebpf tag.
+type structField struct {
+	reflect.StructField
+	value reflect.Value
+}
+
+// ebpfFields returns all fields of a struct, including the fields of
+// embedded structs.
+func ebpfFields(structVal reflect.Value, visited map[reflect.Type]bool) ([]structField, error) {
+	structType := structVal.Type()
+	if visited[structType] {
+		return nil, xerrors.Errorf("recursion on type %s", structType)
+	}
+	visited[structType] = true
+	defer delete(visited, structType)
+
+	var fields []structField
+	for i := 0; i < structType.NumField(); i++ {
+		field := structField{structType.Field(i), structVal.Field(i)}
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("ebpf") == "" {
+			embedded, err := ebpfFields(field.value, visited)
+			if err != nil {
+				return nil, xerrors.Errorf("field %s: %w", field.Name, err)
+			}
+
+			fields = append(fields, embedded...)
+			continue
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// assignValues sets all fields of to which have an ebpf tag to the
+// result of getValue.
+//
+// to is only modified if getValue succeeds for all tagged fields.
+func assignValues(to interface{}, getValue func(typ reflect.Type, name string) (interface{}, error)) error {
+	toValue := reflect.ValueOf(to)
+	if toValue.Kind() != reflect.Ptr || toValue.IsNil() {
+		return xerrors.Errorf("%T is not a pointer to a struct", to)
+	}
+
+	structVal := toValue.Elem()
+	if structVal.Kind() != reflect.Struct {
+		return xerrors.Errorf("%T is not a pointer to a struct", to)
+	}
+
+	fields, err := ebpfFields(structVal, make(map[reflect.Type]bool))
+	if err != nil {
+		return err
+	}
+
+	type elem struct {
+		typ  reflect.Type
+		name string
+	}
+
+	var (
+		assignedTo = make(map[elem]string)
+		values     = make([]reflect.Value, len(fields))
+	)
+
+	for i, field := range fields {
+		name := field.Tag.Get("ebpf")
+		if name == "" {
+			continue
+		}
+
+		if field.PkgPath != "" {
+			return xerrors.Errorf("field %s: can't set unexported field", field.Name)
+		}
+
+		e := elem{field.Type, name}
+		if assignedField := assignedTo[e]; assignedField != "" {
+			return xerrors.Errorf("field %s: %q was already assigned to %s", field.Name, name, assignedField)
+		}
+
+		value, err := getValue(field.Type, name)
+		if err != nil {
+			return xerrors.Errorf("field %s: %w", field.Name, err)
+		}
+
+		assignedTo[e] = field.Name
+		values[i] = reflect.ValueOf(value)
+	}
+
+	for i, field := range fields {
+		if values[i].IsValid() {
+			field.value.Set(values[i])
+		}
+	}
+
+	return nil
+}
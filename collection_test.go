@@ -1,10 +1,19 @@
 package ebpf
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/cilium/ebpf/asm"
 	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
 )
 
 func TestCollectionSpecNotModified(t *testing.T) {
@@ -80,6 +89,71 @@ func TestCollectionSpecCopy(t *testing.T) {
 	}
 }
 
+func TestCollectionSpecDigest(t *testing.T) {
+	spec := &CollectionSpec{
+		Maps: map[string]*MapSpec{
+			".rodata": {
+				Type:       Array,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 1,
+				Contents:   []MapKV{{uint32(0), []byte{1, 2, 3, 4}}},
+				Freeze:     true,
+			},
+		},
+		Programs: map[string]*ProgramSpec{
+			"prog": {
+				Type: SocketFilter,
+				Instructions: asm.Instructions{
+					asm.Mov.Imm(asm.R0, 0),
+					asm.Return(),
+				},
+				License: "MIT",
+			},
+		},
+	}
+
+	digest := func(spec *CollectionSpec) string {
+		t.Helper()
+
+		h := sha256.New()
+		if err := spec.Digest(h); err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprintf("%x", h.Sum(nil))
+	}
+
+	want := digest(spec)
+	if digest(spec.Copy()) != want {
+		t.Error("Digest of a copy differs")
+	}
+
+	cpy := spec.Copy()
+	cpy.Maps[".rodata"].Contents[0].Value = []byte{4, 3, 2, 1}
+	if digest(cpy) == want {
+		t.Error("Digest doesn't depend on map contents")
+	}
+
+	cpy = spec.Copy()
+	cpy.Maps[".rodata"].Freeze = false
+	if digest(cpy) == want {
+		t.Error("Digest doesn't depend on map attributes")
+	}
+
+	cpy = spec.Copy()
+	cpy.Programs["prog"].License = "GPL"
+	if digest(cpy) == want {
+		t.Error("Digest doesn't depend on programs")
+	}
+
+	cpy = spec.Copy()
+	cpy.Programs["other"] = cpy.Programs["prog"]
+	delete(cpy.Programs, "prog")
+	if digest(cpy) == want {
+		t.Error("Digest doesn't depend on program names")
+	}
+}
+
 func TestCollectionSpecRewriteMaps(t *testing.T) {
 	insns := asm.Instructions{
 		// R1 map
@@ -153,3 +227,482 @@ func TestCollectionSpecRewriteMaps(t *testing.T) {
 		t.Fatal("new / override map not used")
 	}
 }
+
+func TestCollectionProgramFilter(t *testing.T) {
+	newProg := func(mapName string) *ProgramSpec {
+		insns := asm.Instructions{
+			asm.LoadMapPtr(asm.R1, 0),
+			asm.LoadImm(asm.R0, 0, asm.DWord),
+			asm.Return(),
+		}
+		insns[0].Reference = mapName
+		insns[0].Constant = math.MaxUint32
+
+		return &ProgramSpec{
+			Type:         SocketFilter,
+			Instructions: insns,
+			License:      "MIT",
+		}
+	}
+
+	mapSpec := &MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	}
+
+	cs := &CollectionSpec{
+		Maps: map[string]*MapSpec{
+			"map-a":  mapSpec.Copy(),
+			"map-b":  mapSpec.Copy(),
+			"unused": mapSpec.Copy(),
+		},
+		Programs: map[string]*ProgramSpec{
+			"prog-a": newProg("map-a"),
+			"prog-b": newProg("map-b"),
+		},
+	}
+
+	coll, err := NewCollectionWithOptions(cs, CollectionOptions{
+		ProgramFilter: func(name string) bool { return name == "prog-a" },
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coll.Close()
+
+	if len(coll.Programs) != 1 || coll.Programs["prog-a"] == nil {
+		t.Error("Expected only prog-a to be loaded, got", coll.Programs)
+	}
+
+	if len(coll.Maps) != 1 || coll.Maps["map-a"] == nil {
+		t.Error("Expected only map-a to be loaded, got", coll.Maps)
+	}
+
+	coll, err = NewCollection(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coll.Close()
+
+	if len(coll.Programs) != 2 {
+		t.Error("Expected all programs to be loaded, got", coll.Programs)
+	}
+
+	if len(coll.Maps) != 3 {
+		t.Error("Expected all maps to be loaded, got", coll.Maps)
+	}
+}
+
+func TestCollectionMapReplacements(t *testing.T) {
+	insns := asm.Instructions{
+		asm.LoadMapPtr(asm.R1, 0),
+		asm.LoadImm(asm.R0, 0, asm.DWord),
+		asm.Return(),
+	}
+	insns[0].Reference = "my-map"
+	insns[0].Constant = math.MaxUint32
+
+	cs := &CollectionSpec{
+		Maps: map[string]*MapSpec{
+			"my-map": {
+				Type:       Array,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 1,
+			},
+		},
+		Programs: map[string]*ProgramSpec{
+			"test": {
+				Type:         SocketFilter,
+				Instructions: insns,
+				License:      "MIT",
+			},
+		},
+	}
+
+	replacement, err := NewMap(cs.Maps["my-map"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replacement.Close()
+
+	if err := replacement.Put(uint32(0), uint32(42)); err != nil {
+		t.Fatal(err)
+	}
+
+	coll, err := NewCollectionWithOptions(cs, CollectionOptions{
+		MapReplacements: map[string]*Map{
+			"my-map": replacement,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coll.Close()
+
+	var value uint32
+	if err := coll.Maps["my-map"].Lookup(uint32(0), &value); err != nil {
+		t.Fatal(err)
+	}
+
+	if value != 42 {
+		t.Error("Collection doesn't use the replacement map")
+	}
+
+	incompatible := cs.Copy()
+	incompatible.Maps["my-map"].MaxEntries = 2
+
+	_, err = NewCollectionWithOptions(incompatible, CollectionOptions{
+		MapReplacements: map[string]*Map{
+			"my-map": replacement,
+		},
+	})
+	if !xerrors.Is(err, ErrMapIncompatible) {
+		t.Error("Expected ErrMapIncompatible, got", err)
+	}
+
+	_, err = NewCollectionWithOptions(cs, CollectionOptions{
+		MapReplacements: map[string]*Map{
+			"missing": replacement,
+		},
+	})
+	if err == nil {
+		t.Error("Replacing a map which isn't in the spec should fail")
+	}
+}
+
+func TestCollectionLimits(t *testing.T) {
+	cs := &CollectionSpec{
+		Maps: map[string]*MapSpec{
+			"my-map": {
+				Type:       Array,
+				KeySize:    4,
+				ValueSize:  8,
+				MaxEntries: 16,
+			},
+		},
+		Programs: map[string]*ProgramSpec{
+			"test": {
+				Type: SocketFilter,
+				Instructions: asm.Instructions{
+					asm.LoadImm(asm.R0, 0, asm.DWord),
+					asm.Return(),
+				},
+				License: "MIT",
+			},
+		},
+	}
+
+	for _, limits := range []Limits{
+		{MaxInstructions: 2},
+		{MaxEntries: 15},
+		{MaxValueSize: 4},
+	} {
+		_, err := NewCollectionWithOptions(cs, CollectionOptions{Limits: limits})
+		if !xerrors.Is(err, ErrLimitExceeded) {
+			t.Errorf("Limits %+v: expected ErrLimitExceeded, got %v", limits, err)
+		}
+	}
+
+	coll, err := NewCollectionWithOptions(cs, CollectionOptions{
+		Limits: Limits{MaxInstructions: 3, MaxEntries: 16, MaxValueSize: 8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	coll.Close()
+}
+
+func TestCollectionSplitLargePrograms(t *testing.T) {
+	insns := largeSplittableInstructions()
+
+	cs := &CollectionSpec{
+		Programs: map[string]*ProgramSpec{
+			"test": {
+				Type:         SocketFilter,
+				Instructions: insns,
+				License:      "MIT",
+			},
+		},
+	}
+
+	_, err := NewCollection(cs)
+	testutils.SkipIfNotSupported(t, err)
+	if !xerrors.Is(err, unix.E2BIG) {
+		t.Skip("Program isn't rejected as too large:", err)
+	}
+
+	coll, err := NewCollectionWithOptions(cs, CollectionOptions{
+		Programs: ProgramOptions{SplitLargePrograms: true},
+	})
+	if err != nil {
+		t.Fatal("Can't load split program:", err)
+	}
+	defer coll.Close()
+
+	split := coll.Programs["test"].Split
+	if split == nil {
+		t.Fatal("Program wasn't split")
+	}
+	if split.After >= split.Before {
+		t.Errorf("Splitting didn't reduce the instruction count from %d to %d", split.Before, split.After)
+	}
+}
+
+func TestCollectionVerifyProgram(t *testing.T) {
+	cs := &CollectionSpec{
+		Programs: map[string]*ProgramSpec{
+			"test": {
+				Type: SocketFilter,
+				Instructions: asm.Instructions{
+					asm.LoadImm(asm.R0, 0, asm.DWord),
+					asm.Return(),
+				},
+				License: "MIT",
+			},
+		},
+	}
+
+	errUnsigned := xerrors.New("unsigned")
+	_, err := NewCollectionWithOptions(cs, CollectionOptions{
+		VerifyProgram: func(name string, spec *ProgramSpec) error {
+			return errUnsigned
+		},
+	})
+	if !xerrors.Is(err, errUnsigned) {
+		t.Fatal("Expected error from VerifyProgram, got", err)
+	}
+
+	var verified []string
+	coll, err := NewCollectionWithOptions(cs, CollectionOptions{
+		VerifyProgram: func(name string, spec *ProgramSpec) error {
+			verified = append(verified, name)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	coll.Close()
+
+	if len(verified) != 1 || verified[0] != "test" {
+		t.Error("VerifyProgram wasn't called for all programs:", verified)
+	}
+}
+
+func TestCollectionSpecAssign(t *testing.T) {
+	cs := &CollectionSpec{
+		Maps: map[string]*MapSpec{
+			"map1": {
+				Type:       Array,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 1,
+			},
+		},
+		Programs: map[string]*ProgramSpec{
+			"prog1": {
+				Type: SocketFilter,
+				Instructions: asm.Instructions{
+					asm.LoadImm(asm.R0, 0, asm.DWord),
+					asm.Return(),
+				},
+				License: "MIT",
+			},
+		},
+	}
+
+	var specs struct {
+		Program *ProgramSpec `ebpf:"prog1"`
+		Map     *MapSpec     `ebpf:"map1"`
+		Ignored int
+	}
+
+	if err := cs.Assign(&specs); err != nil {
+		t.Fatal("Can't assign spec:", err)
+	}
+
+	if specs.Program != cs.Programs["prog1"] {
+		t.Error("Program is not assigned")
+	}
+
+	if specs.Map != cs.Maps["map1"] {
+		t.Error("Map is not assigned")
+	}
+
+	var objs struct {
+		Program *Program `ebpf:"prog1"`
+		Map     *Map     `ebpf:"map1"`
+	}
+
+	if err := cs.LoadAndAssign(&objs, nil); err != nil {
+		t.Fatal("Can't load and assign:", err)
+	}
+	defer objs.Program.Close()
+	defer objs.Map.Close()
+
+	if objs.Program == nil || objs.Map == nil {
+		t.Error("Objects are not assigned")
+	}
+
+	var missing struct {
+		Program *Program `ebpf:"prog1"`
+		Map     *Map     `ebpf:"missing"`
+	}
+
+	if err := cs.LoadAndAssign(&missing, nil); err == nil {
+		t.Error("Assigning a missing map should fail")
+	}
+
+	if missing.Program != nil {
+		t.Error("Struct is modified on error")
+	}
+
+	var duplicate struct {
+		Map1 *MapSpec `ebpf:"map1"`
+		Map2 *MapSpec `ebpf:"map1"`
+	}
+
+	if err := cs.Assign(&duplicate); err == nil {
+		t.Error("Assigning the same spec twice should fail")
+	}
+
+	if err := cs.Assign(specs); err == nil {
+		t.Error("Assigning to a non-pointer should fail")
+	}
+}
+
+func TestCollectionRedirectMapProgram(t *testing.T) {
+	redirect := asm.Instructions{
+		asm.LoadMapPtr(asm.R1, 0),
+		asm.Mov.Imm(asm.R2, 0),
+		asm.Mov.Imm(asm.R3, 0),
+		asm.FnRedirectMap.Call(),
+		asm.Return(),
+	}
+	redirect[0].Reference = "devmap"
+	redirect[0].Constant = math.MaxUint32
+
+	cs := &CollectionSpec{
+		Maps: map[string]*MapSpec{
+			"devmap": {
+				Type:       DevMap,
+				KeySize:    4,
+				ValueSize:  8,
+				MaxEntries: 1,
+				Contents: []MapKV{
+					{uint32(0), DevMapValue{Ifindex: 1, ProgramName: "egress"}},
+				},
+			},
+		},
+		Programs: map[string]*ProgramSpec{
+			"redirect": {
+				Type:         XDP,
+				Instructions: redirect,
+				License:      "MIT",
+			},
+			"egress": {
+				Type:       XDP,
+				AttachType: AttachXDPDevMap,
+				Instructions: asm.Instructions{
+					asm.Mov.Imm(asm.R0, 2), // XDP_PASS
+					asm.Return(),
+				},
+				License: "MIT",
+			},
+		},
+	}
+
+	coll, err := NewCollection(cs)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coll.Close()
+
+	var value DevMapValue
+	if err := coll.Maps["devmap"].Lookup(uint32(0), &value); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := coll.Programs["egress"].ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value.Ifindex != 1 || value.ProgramID != uint32(id) {
+		t.Errorf("Expected ifindex 1 and program ID %d, got %+v", id, value)
+	}
+
+	if _, err := NewMap(cs.Maps["devmap"]); err == nil {
+		t.Error("Creating a map with an unresolved program reference doesn't fail")
+	}
+}
+
+func TestCollectionPin(t *testing.T) {
+	tmp, err := ioutil.TempDir("/sys/fs/bpf", "ebpf-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	arr := createArray(t)
+	prog := createSocketFilter(t)
+	coll := &Collection{
+		Maps:     map[string]*Map{"map": arr},
+		Programs: map[string]*Program{"prog": prog},
+	}
+	defer coll.Close()
+
+	dir := filepath.Join(tmp, "coll")
+	if err := coll.Pin(dir); err != nil {
+		t.Fatal("Can't pin to new directory:", err)
+	}
+
+	checkPins := func(t *testing.T, want ...string) {
+		t.Helper()
+
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var have []string
+		for _, file := range files {
+			have = append(have, file.Name())
+		}
+		if len(have) != len(want) {
+			t.Fatalf("Expected pins %v, got %v", want, have)
+		}
+		for i := range want {
+			if have[i] != want[i] {
+				t.Fatalf("Expected pins %v, got %v", want, have)
+			}
+		}
+	}
+
+	checkPins(t, "map", "prog")
+
+	coll2 := &Collection{Maps: map[string]*Map{"other": arr}}
+	err = coll2.Pin(dir)
+	testutils.SkipIfNotSupported(t, err)
+	if xerrors.Is(err, unix.EINVAL) {
+		t.Skip("bpffs doesn't support RENAME_EXCHANGE")
+	}
+	if err != nil {
+		t.Fatal("Can't replace directory:", err)
+	}
+
+	checkPins(t, "other")
+
+	files, err := ioutil.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Error("Temporary directory wasn't removed")
+	}
+}
@@ -0,0 +1,221 @@
+package ebpf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cilium/ebpf/internal/btf"
+
+	"golang.org/x/xerrors"
+)
+
+// PinAction is the recommended way of dealing with a pinned object.
+//
+// Actions are ordered from least to most disruptive.
+type PinAction int
+
+const (
+	// PinReuse means that the pinned object matches the spec and can
+	// be used as is.
+	PinReuse PinAction = iota
+	// PinCreate means that nothing is pinned, the object has to be
+	// created from the spec.
+	PinCreate
+	// PinMigrate means that a pinned map can't be reused, but has the
+	// same key and value sizes as the spec. Its contents can be copied
+	// into a new map, possibly after converting them.
+	PinMigrate
+	// PinRecreate means that the pinned object has to be replaced, and
+	// its state is lost.
+	PinRecreate
+)
+
+func (pa PinAction) String() string {
+	switch pa {
+	case PinReuse:
+		return "reuse"
+	case PinCreate:
+		return "create"
+	case PinMigrate:
+		return "migrate"
+	case PinRecreate:
+		return "recreate"
+	default:
+		return fmt.Sprintf("PinAction(%d)", int(pa))
+	}
+}
+
+// PinCompatibility describes how a spec relates to the object pinned
+// for it.
+type PinCompatibility struct {
+	// Path of the pinned object.
+	Path   string
+	Action PinAction
+	// A human readable description of each difference between the
+	// spec and the pinned object.
+	Differences []string
+}
+
+func (pc *PinCompatibility) differ(action PinAction, format string, args ...interface{}) {
+	if action > pc.Action {
+		pc.Action = action
+	}
+	pc.Differences = append(pc.Differences, fmt.Sprintf(format, args...))
+}
+
+// CompatibilityReport describes how a CollectionSpec relates to the
+// objects pinned for it, keyed by the name of the spec.
+type CompatibilityReport struct {
+	Maps     map[string]*PinCompatibility
+	Programs map[string]*PinCompatibility
+}
+
+// Action returns the most disruptive action required by any object
+// in the report.
+func (cr *CompatibilityReport) Action() PinAction {
+	action := PinReuse
+	for _, pcs := range []map[string]*PinCompatibility{cr.Maps, cr.Programs} {
+		for _, pc := range pcs {
+			if pc.Action > action {
+				action = pc.Action
+			}
+		}
+	}
+	return action
+}
+
+// CheckPinned compares the spec with objects pinned in dir.
+//
+// The object for a map or program is expected at dir/<name>, where name
+// is the key of the spec in CollectionSpec.Maps or CollectionSpec.Programs.
+// Maps are compared by their ABI and, if both sides have it, by the BTF
+// of their keys and values. Programs are compared by type and tag.
+//
+// Checking programs requires at least Linux 4.13.
+func (cs *CollectionSpec) CheckPinned(dir string) (*CompatibilityReport, error) {
+	report := &CompatibilityReport{
+		Maps:     make(map[string]*PinCompatibility),
+		Programs: make(map[string]*PinCompatibility),
+	}
+
+	for name, spec := range cs.Maps {
+		pc, err := checkPinnedMap(spec, filepath.Join(dir, name))
+		if err != nil {
+			return nil, xerrors.Errorf("map %s: %w", name, err)
+		}
+		report.Maps[name] = pc
+	}
+
+	for name, spec := range cs.Programs {
+		pc, err := checkPinnedProgram(spec, filepath.Join(dir, name))
+		if err != nil {
+			return nil, xerrors.Errorf("program %s: %w", name, err)
+		}
+		report.Programs[name] = pc
+	}
+
+	return report, nil
+}
+
+func checkPinnedMap(spec *MapSpec, path string) (*PinCompatibility, error) {
+	pc := &PinCompatibility{Path: path}
+
+	m, err := LoadPinnedMap(path)
+	if xerrors.Is(err, os.ErrNotExist) {
+		pc.Action = PinCreate
+		return pc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	have := m.abi
+	want := spec.expectedABI(&have)
+
+	if want.Type != have.Type {
+		pc.differ(PinRecreate, "type: %s != %s", want.Type, have.Type)
+	}
+	if want.KeySize != have.KeySize {
+		pc.differ(PinRecreate, "key size: %d != %d", want.KeySize, have.KeySize)
+	}
+	if want.ValueSize != have.ValueSize {
+		pc.differ(PinRecreate, "value size: %d != %d", want.ValueSize, have.ValueSize)
+	}
+	if want.MaxEntries != have.MaxEntries {
+		pc.differ(PinMigrate, "max entries: %d != %d", want.MaxEntries, have.MaxEntries)
+	}
+	if want.Flags != have.Flags {
+		pc.differ(PinMigrate, "flags: %#x != %#x", want.Flags, have.Flags)
+	}
+
+	types := m.types.get()
+	if spec.BTF == nil || types == nil {
+		return pc, nil
+	}
+
+	for _, part := range []struct {
+		name       string
+		want, have btf.Type
+	}{
+		{"key", btf.MapKey(spec.BTF), btf.MapKey(types)},
+		{"value", btf.MapValue(spec.BTF), btf.MapValue(types)},
+	} {
+		same, err := sameBTFType(part.want, part.have)
+		if err != nil {
+			return nil, xerrors.Errorf("compare BTF of %s: %w", part.name, err)
+		}
+		if !same {
+			pc.differ(PinMigrate, "BTF of %s differs", part.name)
+		}
+	}
+
+	return pc, nil
+}
+
+func checkPinnedProgram(spec *ProgramSpec, path string) (*PinCompatibility, error) {
+	pc := &PinCompatibility{Path: path}
+
+	prog, err := LoadPinnedProgram(path)
+	if xerrors.Is(err, os.ErrNotExist) {
+		pc.Action = PinCreate
+		return pc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer prog.Close()
+
+	if want, have := spec.Type, prog.abi.Type; want != have {
+		pc.differ(PinRecreate, "type: %s != %s", want, have)
+		return pc, nil
+	}
+
+	want, err := spec.Tag()
+	if err != nil {
+		return nil, err
+	}
+	have, err := prog.Tag()
+	if err != nil {
+		return nil, err
+	}
+	if want != have {
+		pc.differ(PinRecreate, "tag: %s != %s", want, have)
+	}
+
+	return pc, nil
+}
+
+// sameBTFType returns true if a and b have the same C definition.
+func sameBTFType(a, b btf.Type) (bool, error) {
+	aC, err := btf.DumpC(a)
+	if err != nil {
+		return false, err
+	}
+	bC, err := btf.DumpC(b)
+	if err != nil {
+		return false, err
+	}
+	return aC == bC, nil
+}
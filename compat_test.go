@@ -0,0 +1,100 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestCollectionSpecCheckPinned(t *testing.T) {
+	tmp, err := ioutil.TempDir("/sys/fs/bpf", "ebpf-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	m := createArray(t)
+	defer m.Close()
+	if err := m.Pin(filepath.Join(tmp, "map")); err != nil {
+		t.Fatal(err)
+	}
+
+	prog := createSocketFilter(t)
+	defer prog.Close()
+	if err := prog.Pin(filepath.Join(tmp, "prog")); err != nil {
+		t.Fatal(err)
+	}
+
+	arraySpec := func(keySize, maxEntries uint32) *MapSpec {
+		return &MapSpec{
+			Type:       Array,
+			KeySize:    keySize,
+			ValueSize:  4,
+			MaxEntries: maxEntries,
+		}
+	}
+
+	changedProg := socketFilterSpec.Copy()
+	changedProg.Instructions = asm.Instructions{
+		asm.LoadImm(asm.R0, 1, asm.DWord),
+		asm.Return(),
+	}
+
+	for _, test := range []struct {
+		name   string
+		spec   *CollectionSpec
+		action PinAction
+	}{
+		{
+			"reuse",
+			&CollectionSpec{
+				Maps:     map[string]*MapSpec{"map": arraySpec(4, 2)},
+				Programs: map[string]*ProgramSpec{"prog": socketFilterSpec},
+			},
+			PinReuse,
+		},
+		{
+			"create",
+			&CollectionSpec{Maps: map[string]*MapSpec{"other": arraySpec(4, 2)}},
+			PinCreate,
+		},
+		{
+			"migrate",
+			&CollectionSpec{Maps: map[string]*MapSpec{"map": arraySpec(4, 3)}},
+			PinMigrate,
+		},
+		{
+			"recreate map",
+			&CollectionSpec{Maps: map[string]*MapSpec{"map": arraySpec(8, 3)}},
+			PinRecreate,
+		},
+		{
+			"recreate program",
+			&CollectionSpec{Programs: map[string]*ProgramSpec{"prog": changedProg}},
+			PinRecreate,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			report, err := test.spec.CheckPinned(tmp)
+			testutils.SkipIfNotSupported(t, err)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if action := report.Action(); action != test.action {
+				t.Errorf("Expected action %s, got %s", test.action, action)
+			}
+
+			for name, pc := range report.Maps {
+				t.Logf("map %s: %s %v", name, pc.Action, pc.Differences)
+			}
+			for name, pc := range report.Programs {
+				t.Logf("program %s: %s %v", name, pc.Action, pc.Differences)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/cilium/ebpf/cgroup"
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// Container is a running container.
+type Container struct {
+	// ID is assigned by the container runtime.
+	ID string
+	// PID is the process ID of the container's init process, in the PID
+	// namespace of the caller.
+	PID int
+	// Bundle is the directory containing the OCI bundle of the
+	// container. It may be empty.
+	Bundle      string
+	Annotations map[string]string
+}
+
+// Path returns the location of a file in the root filesystem of the
+// container, as seen from outside of the container. Symlinks are
+// resolved relative to the root filesystem of the container.
+func (c *Container) Path(path string) (string, error) {
+	return internal.ResolveInRoot(fmt.Sprintf("/proc/%d/root", c.PID), path)
+}
+
+// Cgroup returns the location of the container's cgroup in the unified
+// hierarchy.
+func (c *Container) Cgroup() (string, error) {
+	return cgroup.PathOfPID(c.PID)
+}
+
+// ociState is the state of a container as defined by the OCI runtime
+// specification.
+type ociState struct {
+	Version     string            `json:"ociVersion"`
+	ID          string            `json:"id"`
+	Status      string            `json:"status"`
+	PID         int               `json:"pid"`
+	Bundle      string            `json:"bundle"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// ReadOCIState decodes the state of a container which OCI runtimes pass
+// to hooks on stdin.
+//
+// Hooks are short lived processes, use TrackerOptions.PinDir to keep
+// probes attached after the hook exits. Attach in a createRuntime or
+// poststart hook, and detach in a poststop hook. The latter receives a
+// state without PID.
+func ReadOCIState(r io.Reader) (*Container, error) {
+	var state ociState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return nil, xerrors.Errorf("can't decode OCI state: %w", err)
+	}
+
+	if state.ID == "" {
+		return nil, xerrors.New("OCI state has no container id")
+	}
+
+	return &Container{
+		ID:          state.ID,
+		PID:         state.PID,
+		Bundle:      state.Bundle,
+		Annotations: state.Annotations,
+	}, nil
+}
@@ -0,0 +1,217 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/cgroup"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestReadOCIState(t *testing.T) {
+	c, err := ReadOCIState(strings.NewReader(`{
+		"ociVersion": "1.0.2",
+		"id": "abc",
+		"status": "created",
+		"pid": 42,
+		"bundle": "/run/bundle",
+		"annotations": {"foo": "bar"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.ID != "abc" || c.PID != 42 || c.Bundle != "/run/bundle" || c.Annotations["foo"] != "bar" {
+		t.Errorf("Unexpected container %+v", c)
+	}
+
+	c.PID = os.Getpid()
+	path, err := c.Path("/bin/sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.Stat("/bin/sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(have, want) {
+		t.Error("Path doesn't resolve to /bin/sh:", path)
+	}
+
+	if _, err := ReadOCIState(strings.NewReader(`{"pid": 1}`)); err == nil {
+		t.Error("ReadOCIState accepts state without id")
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	for str, want := range map[string]*target{
+		"cgroup":                         {kind: "cgroup"},
+		"uprobe//bin/bash:readline":      {"uprobe", "/bin/bash", "readline"},
+		"uretprobe//usr/lib/libc.so:foo": {"uretprobe", "/usr/lib/libc.so", "foo"},
+		"cgroup/foo":                     nil,
+		"uprobe//bin/bash":               nil,
+		"kprobe/foo":                     nil,
+	} {
+		have, err := parseTarget(str)
+		if want == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error", str)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", str, err)
+			continue
+		}
+		if *have != *want {
+			t.Errorf("%s: have %+v, want %+v", str, have, want)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.7", "cgroup links")
+
+	if _, err := cgroup.Mountpoint(); err != nil {
+		t.Skip("No cgroup2 hierarchy:", err)
+	}
+
+	pinDir, err := ioutil.TempDir("/sys/fs/bpf", "ebpf-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pinDir)
+
+	// Allow all packets, the test process is treated as a container.
+	skb := mustProgram(t, ebpf.CGroupSKB, ebpf.AttachCGroupInetEgress, 1)
+	defer skb.Close()
+
+	kprobe := mustProgram(t, ebpf.Kprobe, 0, 0)
+	defer kprobe.Close()
+
+	var skipped []Probe
+	tr, err := NewTracker(TrackerOptions{
+		Probes: []Probe{
+			{Program: skb, Target: "cgroup", Attach: ebpf.AttachCGroupInetEgress},
+			{Program: kprobe, Target: "uprobe//does/not/exist:main", Optional: true},
+		},
+		PinDir: pinDir,
+		OnSkip: func(c *Container, probe Probe, err error) {
+			skipped = append(skipped, probe)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	c := &Container{ID: "test", PID: os.Getpid()}
+	err = tr.Start(c)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(skipped) != 1 {
+		t.Error("Optional probe wasn't skipped")
+	}
+	if ids := tr.Containers(); len(ids) != 1 || ids[0] != "test" {
+		t.Error("Unexpected containers", ids)
+	}
+	if _, err := os.Stat(filepath.Join(pinDir, "test", "0")); err != nil {
+		t.Error("Link isn't pinned:", err)
+	}
+
+	if err := tr.Start(c); err == nil {
+		t.Error("Starting a container twice doesn't return an error")
+	}
+
+	if err := tr.Stop("test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(pinDir, "test")); !os.IsNotExist(err) {
+		t.Error("Pins weren't removed")
+	}
+	if ids := tr.Containers(); len(ids) != 0 {
+		t.Error("Unexpected containers", ids)
+	}
+}
+
+func mustProgram(t *testing.T, typ ebpf.ProgramType, attachType ebpf.AttachType, ret int32) *ebpf.Program {
+	t.Helper()
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:       typ,
+		AttachType: attachType,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, ret),
+			asm.Return(),
+		},
+		License: "GPL",
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prog
+}
+
+func TestContainerFromCRIInfo(t *testing.T) {
+	info := map[string]string{
+		"info": `{"sandboxID": "pod", "pid": 42, "runtimeSpec": {"annotations": {"foo": "bar"}}}`,
+	}
+
+	c, err := ContainerFromCRIInfo("abc", info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ID != "abc" || c.PID != 42 || c.Annotations["foo"] != "bar" {
+		t.Errorf("Unexpected container %+v", c)
+	}
+
+	if _, err := ContainerFromCRIInfo("abc", nil); err == nil {
+		t.Error("ContainerFromCRIInfo accepts missing info")
+	}
+	if _, err := ContainerFromCRIInfo("abc", map[string]string{"info": `{}`}); err == nil {
+		t.Error("ContainerFromCRIInfo accepts info without pid")
+	}
+}
+
+func TestTrackerHandleCRIEvent(t *testing.T) {
+	tr, err := NewTracker(TrackerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	info := map[string]string{"info": `{"pid": 42}`}
+	if err := tr.HandleCRIEvent(CRIContainerCreated, "abc", nil); err != nil {
+		t.Fatal(err)
+	}
+	if ids := tr.Containers(); len(ids) != 0 {
+		t.Error("Created container is started", ids)
+	}
+
+	if err := tr.HandleCRIEvent(CRIContainerStarted, "abc", info); err != nil {
+		t.Fatal(err)
+	}
+	if ids := tr.Containers(); len(ids) != 1 || ids[0] != "abc" {
+		t.Error("Unexpected containers", ids)
+	}
+
+	if err := tr.HandleCRIEvent(CRIContainerStopped, "abc", nil); err != nil {
+		t.Fatal(err)
+	}
+	if ids := tr.Containers(); len(ids) != 0 {
+		t.Error("Stopped container isn't removed", ids)
+	}
+}
@@ -0,0 +1,83 @@
+package container
+
+import (
+	"encoding/json"
+
+	"golang.org/x/xerrors"
+)
+
+// CRIEventType is the type of an event of the CRI GetContainerEvents
+// stream, see runtime.v1.ContainerEventType.
+type CRIEventType int32
+
+// Values of runtime.v1.ContainerEventType.
+const (
+	CRIContainerCreated CRIEventType = iota
+	CRIContainerStarted
+	CRIContainerStopped
+	CRIContainerDeleted
+)
+
+// criInfo is the part of the verbose info of a container which containerd
+// and CRI-O return under the "info" key of ContainerStatusResponse.Info.
+type criInfo struct {
+	PID         int `json:"pid"`
+	RuntimeSpec struct {
+		Annotations map[string]string `json:"annotations"`
+	} `json:"runtimeSpec"`
+}
+
+// ContainerFromCRIInfo returns the container described by the verbose
+// info of a CRI ContainerStatus call.
+//
+// info is ContainerStatusResponse.Info of a request with verbose set.
+// The events of the CRI don't contain the PID of a container, so the
+// status has to be queried separately.
+func ContainerFromCRIInfo(id string, info map[string]string) (*Container, error) {
+	if id == "" {
+		return nil, xerrors.New("CRI container has no id")
+	}
+
+	raw, ok := info["info"]
+	if !ok {
+		return nil, xerrors.Errorf("container %s: CRI status has no verbose info", id)
+	}
+
+	var ci criInfo
+	if err := json.Unmarshal([]byte(raw), &ci); err != nil {
+		return nil, xerrors.Errorf("container %s: can't decode CRI info: %w", id, err)
+	}
+
+	if ci.PID <= 0 {
+		return nil, xerrors.Errorf("container %s: CRI info has no pid", id)
+	}
+
+	return &Container{
+		ID:          id,
+		PID:         ci.PID,
+		Annotations: ci.RuntimeSpec.Annotations,
+	}, nil
+}
+
+// HandleCRIEvent starts or stops a container according to an event of
+// the CRI GetContainerEvents stream.
+//
+// info is the verbose info of the container as accepted by
+// ContainerFromCRIInfo. It is only used for started containers, and may
+// be nil otherwise. Events of other types are ignored.
+func (t *Tracker) HandleCRIEvent(typ CRIEventType, id string, info map[string]string) error {
+	switch typ {
+	case CRIContainerStarted:
+		c, err := ContainerFromCRIInfo(id, info)
+		if err != nil {
+			return err
+		}
+		return t.Start(c)
+
+	case CRIContainerStopped, CRIContainerDeleted:
+		return t.Stop(id)
+
+	default:
+		return nil
+	}
+}
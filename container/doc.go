@@ -0,0 +1,8 @@
+// Package container attaches programs to containers as they are started.
+//
+// A Tracker attaches a set of probes to each container it is told
+// about, and detaches them again once the container stops. Containers
+// are described either by the state OCI runtimes pass to hooks, see
+// ReadOCIState, or by the CRI event stream of containerd or CRI-O, see
+// Tracker.HandleCRIEvent.
+package container
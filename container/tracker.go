@@ -0,0 +1,269 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	"golang.org/x/xerrors"
+)
+
+// Probe declares where a program is attached in each container.
+type Probe struct {
+	Program *ebpf.Program
+
+	// Target is the attach point of the program. It is one of
+	//
+	//	cgroup
+	//	uprobe/<path>:<symbol>
+	//	uretprobe/<path>:<symbol>
+	//
+	// "cgroup" attaches to the cgroup of the container, using Attach.
+	// Paths of uprobes are resolved in the root filesystem of the
+	// container. Uprobes only fire in the init process of the container,
+	// since the kernel filters them by process.
+	Target string
+	Attach ebpf.AttachType
+
+	// Optional probes which fail to attach are skipped instead of
+	// failing Start.
+	Optional bool
+}
+
+// TrackerOptions control a Tracker.
+type TrackerOptions struct {
+	// Probes are attached to every container in order, and detached in
+	// reverse order.
+	Probes []Probe
+
+	// PinDir is a directory on bpffs. If it is not empty, the links of a
+	// container are pinned in a subdirectory named after the container,
+	// and Stop removes the subdirectory. This allows attaching and
+	// detaching from different processes, for example OCI hooks.
+	PinDir string
+
+	// OnSkip is called for optional probes which failed to attach.
+	OnSkip func(c *Container, probe Probe, err error)
+}
+
+// Tracker manages the links of containers.
+//
+// It is safe to use a Tracker from multiple goroutines.
+type Tracker struct {
+	opts    TrackerOptions
+	targets []*target
+
+	mu         sync.Mutex
+	containers map[string][]link.Link
+}
+
+// NewTracker creates a Tracker.
+//
+// The Tracker doesn't own the programs of the probes, which must stay
+// open while it is used.
+func NewTracker(opts TrackerOptions) (*Tracker, error) {
+	targets := make([]*target, 0, len(opts.Probes))
+	for i, probe := range opts.Probes {
+		if probe.Program == nil {
+			return nil, xerrors.Errorf("probe %d: program is nil", i)
+		}
+
+		t, err := parseTarget(probe.Target)
+		if err != nil {
+			return nil, xerrors.Errorf("probe %d: %w", i, err)
+		}
+		targets = append(targets, t)
+	}
+
+	return &Tracker{
+		opts:       opts,
+		targets:    targets,
+		containers: make(map[string][]link.Link),
+	}, nil
+}
+
+// Start attaches all probes to a container.
+//
+// If a probe fails to attach, probes which were already attached to the
+// container are detached again. It is an error to start a container
+// twice.
+func (t *Tracker) Start(c *Container) error {
+	if c.ID == "" || strings.ContainsAny(c.ID, "/.") {
+		return xerrors.Errorf("invalid container id %q", c.ID)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.containers[c.ID]; ok {
+		return xerrors.Errorf("container %s is already started", c.ID)
+	}
+
+	var pinDir string
+	if t.opts.PinDir != "" {
+		pinDir = filepath.Join(t.opts.PinDir, c.ID)
+		if err := os.Mkdir(pinDir, 0755); err != nil {
+			return xerrors.Errorf("container %s: %w", c.ID, err)
+		}
+	}
+
+	var links []link.Link
+	for i, probe := range t.opts.Probes {
+		l, err := t.targets[i].attach(c, probe)
+		if err == nil && pinDir != "" {
+			err = l.Pin(filepath.Join(pinDir, strconv.Itoa(i)))
+			if err != nil {
+				l.Close()
+			}
+		}
+		if err != nil && probe.Optional {
+			if t.opts.OnSkip != nil {
+				t.opts.OnSkip(c, probe, err)
+			}
+			continue
+		}
+		if err != nil {
+			closeLinks(links)
+			if pinDir != "" {
+				os.RemoveAll(pinDir)
+			}
+			return xerrors.Errorf("container %s: probe %s: %w", c.ID, probe.Target, err)
+		}
+
+		links = append(links, l)
+	}
+
+	t.containers[c.ID] = links
+	return nil
+}
+
+// Stop detaches all probes from a container.
+//
+// If PinDir is set, this also detaches probes which were attached by
+// another process. Stopping an unknown container is not an error.
+func (t *Tracker) Stop(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.stop(id)
+}
+
+func (t *Tracker) stop(id string) error {
+	err := closeLinks(t.containers[id])
+	delete(t.containers, id)
+
+	if t.opts.PinDir != "" && id != "" && !strings.ContainsAny(id, "/.") {
+		if rmErr := os.RemoveAll(filepath.Join(t.opts.PinDir, id)); rmErr != nil && err == nil {
+			err = rmErr
+		}
+	}
+
+	if err != nil {
+		return xerrors.Errorf("container %s: %w", id, err)
+	}
+	return nil
+}
+
+// Containers returns the IDs of started containers.
+func (t *Tracker) Containers() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ids := make([]string, 0, len(t.containers))
+	for id := range t.containers {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Close stops all containers.
+//
+// Close continues after errors and returns the first one.
+func (t *Tracker) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var firstErr error
+	for id := range t.containers {
+		if err := t.stop(id); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// closeLinks closes links in reverse order, and returns the first error.
+func closeLinks(links []link.Link) error {
+	var firstErr error
+	for i := len(links) - 1; i >= 0; i-- {
+		if err := links[i].Close(); err != nil && firstErr == nil {
+			firstErr = xerrors.Errorf("can't detach probe: %w", err)
+		}
+	}
+	return firstErr
+}
+
+// target is a parsed attach target.
+type target struct {
+	kind         string
+	path, symbol string
+}
+
+func parseTarget(str string) (*target, error) {
+	if str == "cgroup" {
+		return &target{kind: str}, nil
+	}
+
+	parts := strings.SplitN(str, "/", 2)
+	switch kind := parts[0]; kind {
+	case "uprobe", "uretprobe":
+		var rest string
+		if len(parts) == 2 {
+			rest = parts[1]
+		}
+
+		// The path of the executable may contain slashes, the symbol
+		// follows the last colon.
+		i := strings.LastIndexByte(rest, ':')
+		if i <= 0 || i == len(rest)-1 {
+			return nil, xerrors.Errorf("target %q: expected %s/<path>:<symbol>", str, kind)
+		}
+		return &target{kind, rest[:i], rest[i+1:]}, nil
+
+	default:
+		return nil, xerrors.Errorf("target %q: unknown kind %q", str, kind)
+	}
+}
+
+func (t *target) attach(c *Container, probe Probe) (link.Link, error) {
+	switch t.kind {
+	case "cgroup":
+		cg, err := c.Cgroup()
+		if err != nil {
+			return nil, err
+		}
+		return link.AttachCgroup(link.CgroupOptions{
+			Path:    cg,
+			Attach:  probe.Attach,
+			Program: probe.Program,
+		})
+
+	default:
+		ex, err := link.OpenExecutableInProcess(c.PID, t.path)
+		if err != nil {
+			return nil, err
+		}
+		// Executables in the root filesystem of a container may be
+		// shared with other containers or the host.
+		opts := &link.UprobeOptions{PID: c.PID}
+		if t.kind == "uprobe" {
+			return ex.Uprobe(t.symbol, probe.Program, opts)
+		}
+		return ex.Uretprobe(t.symbol, probe.Program, opts)
+	}
+}
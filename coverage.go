@@ -0,0 +1,165 @@
+package ebpf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// CoverageSpec describes the counters added to a program by
+// InstrumentCoverage.
+type CoverageSpec struct {
+	// Symbol is the name under which the instrumented program refers
+	// to Map.
+	Symbol string
+	// Map holds a 64 bit counter for each basic block.
+	Map *MapSpec
+	// Instructions of the program before instrumentation.
+	Instructions asm.Instructions
+	// Blocks of Instructions, in the order of their counters.
+	Blocks []asm.BasicBlock
+}
+
+// InstrumentCoverage returns a copy of the spec which counts how often
+// each of its basic blocks executes.
+//
+// The counters are stored in an Array map, which the instrumented
+// program refers to by CoverageSpec.Symbol. Add the map to the Maps of a
+// CollectionSpec, or use RewriteMapPtr to load the program on its own.
+// Function and line infos are dropped, since they don't match the
+// instrumented instructions.
+//
+// Requires at least Linux 5.5.
+func (ps *ProgramSpec) InstrumentCoverage() (*ProgramSpec, *CoverageSpec, error) {
+	symbol := ps.Name + "_coverage"
+
+	insns, blocks, err := ps.Instructions.InstrumentCoverage(symbol)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("can't instrument program %s: %w", ps.Name, err)
+	}
+
+	cpy := ps.Copy()
+	cpy.Instructions = insns
+	cpy.BTF = nil
+
+	cov := &CoverageSpec{
+		Symbol: symbol,
+		Map: &MapSpec{
+			Name:       "coverage",
+			Type:       Array,
+			KeySize:    4,
+			ValueSize:  uint32(len(blocks) * 8),
+			MaxEntries: 1,
+		},
+		Instructions: ps.Instructions,
+		Blocks:       blocks,
+	}
+
+	return cpy, cov, nil
+}
+
+// InstrumentCoverage instruments all programs in the collection, and
+// adds the maps holding their counters.
+//
+// Returns the coverage of each program, keyed by program name.
+func (cs *CollectionSpec) InstrumentCoverage() (map[string]*CoverageSpec, error) {
+	coverage := make(map[string]*CoverageSpec, len(cs.Programs))
+	for name, spec := range cs.Programs {
+		instrumented, cov, err := spec.InstrumentCoverage()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := cs.Maps[cov.Symbol]; ok {
+			return nil, xerrors.Errorf("program %s: map %s already exists", name, cov.Symbol)
+		}
+
+		coverage[name] = cov
+		cs.Maps[cov.Symbol] = cov.Map
+		cs.Programs[name] = instrumented
+	}
+
+	return coverage, nil
+}
+
+// Read returns the counters of an instrumented program.
+//
+// m is the map created from CoverageSpec.Map. Counters aren't reset.
+func (cs *CoverageSpec) Read(m *Map) (*CoverageReport, error) {
+	var value []byte
+	if err := m.Lookup(uint32(0), &value); err != nil {
+		return nil, xerrors.Errorf("can't read coverage counters: %w", err)
+	}
+
+	if len(value) < len(cs.Blocks)*8 {
+		return nil, xerrors.Errorf("coverage map has %d bytes, expected %d", len(value), len(cs.Blocks)*8)
+	}
+
+	counts := make([]uint64, len(cs.Blocks))
+	for i := range counts {
+		counts[i] = internal.NativeEndian.Uint64(value[i*8:])
+	}
+
+	return &CoverageReport{cs.Instructions, cs.Blocks, counts}, nil
+}
+
+// CoverageReport contains the execution counts of basic blocks.
+type CoverageReport struct {
+	Instructions asm.Instructions
+	Blocks       []asm.BasicBlock
+	// Counts contains the number of executions of each block.
+	Counts []uint64
+}
+
+// Covered returns the number of blocks which executed at least once.
+func (cr *CoverageReport) Covered() int {
+	var n int
+	for _, count := range cr.Counts {
+		if count > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Ratio returns the fraction of blocks which executed at least once.
+func (cr *CoverageReport) Ratio() float64 {
+	if len(cr.Blocks) == 0 {
+		return 0
+	}
+	return float64(cr.Covered()) / float64(len(cr.Blocks))
+}
+
+// String lists the instructions, prefixed with the execution count of
+// their block. Blocks which never executed are marked with a dash.
+func (cr *CoverageReport) String() string {
+	width := 1
+	for _, count := range cr.Counts {
+		if n := len(fmt.Sprint(count)); n > width {
+			width = n
+		}
+	}
+
+	var sb strings.Builder
+	for i, block := range cr.Blocks {
+		count := fmt.Sprint(cr.Counts[i])
+		if cr.Counts[i] == 0 {
+			count = "-"
+		}
+
+		for j := block.Start; j < block.Start+block.Length; j++ {
+			ins := cr.Instructions[j]
+			if ins.Symbol != "" {
+				fmt.Fprintf(&sb, "%s:\n", ins.Symbol)
+			}
+			fmt.Fprintf(&sb, "%*s\t%4d: %v\n", width, count, j, ins)
+		}
+	}
+
+	fmt.Fprintf(&sb, "%d of %d blocks covered (%.1f%%)\n", cr.Covered(), len(cr.Blocks), cr.Ratio()*100)
+	return sb.String()
+}
@@ -0,0 +1,73 @@
+package ebpf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestInstrumentCoverage(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.5", "direct map value access")
+
+	spec := &CollectionSpec{
+		Maps: map[string]*MapSpec{},
+		Programs: map[string]*ProgramSpec{
+			"prog": {
+				Name: "prog",
+				Type: SocketFilter,
+				Instructions: asm.Instructions{
+					// Packets passed to Test are short, the branch is
+					// always taken.
+					asm.LoadMem(asm.R2, asm.R1, 0, asm.Word),
+					asm.Mov.Imm(asm.R0, 0),
+					asm.JLT.Imm(asm.R2, 1000, "exit"),
+					asm.Mov.Imm(asm.R0, 1),
+					asm.Return().Sym("exit"),
+				},
+				License: "MIT",
+			},
+		},
+	}
+
+	coverage, err := spec.InstrumentCoverage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coll, err := NewCollection(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coll.Close()
+
+	cov := coverage["prog"]
+	for i := 0; i < 3; i++ {
+		if _, _, err := coll.Programs["prog"].Test(make([]byte, 14)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := cov.Read(coll.Maps[cov.Symbol])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("\n" + report.String())
+
+	want := []uint64{3, 0, 3}
+	for i, count := range report.Counts {
+		if count != want[i] {
+			t.Errorf("Block %d executed %d times, expected %d", i, count, want[i])
+		}
+	}
+
+	if report.Covered() != 2 {
+		t.Error("Expected two covered blocks, got", report.Covered())
+	}
+
+	if !strings.Contains(report.String(), "2 of 3 blocks covered") {
+		t.Error("Report doesn't contain summary")
+	}
+}
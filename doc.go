@@ -14,4 +14,9 @@
 //
 // This package doesn't include code required to attach eBPF to Linux
 // subsystems, since this varies per subsystem.
+//
+// Instructions, specs and ELF parsing work on all platforms. On Windows,
+// there is experimental support for loading maps and programs via
+// ebpf-for-windows, which must be installed separately. Note that it
+// uses its own numbering for program and attach types.
 package ebpf
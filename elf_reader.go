@@ -18,11 +18,16 @@ import (
 )
 
 type elfCode struct {
-	*elf.File
+	*internal.SafeELFFile
 	symbols           []elf.Symbol
 	symbolsPerSection map[elf.SectionIndex]map[uint64]string
 	license           string
 	version           uint32
+	ksyms             map[string]bool
+	// The binding of each symbol in symbolsPerSection, and whether a
+	// map definition came from a weak symbol.
+	symbolBindings map[elf.SectionIndex]map[uint64]elf.SymBind
+	weakMaps       map[string]bool
 }
 
 // LoadCollectionSpec parses an ELF file into a CollectionSpec.
@@ -41,8 +46,12 @@ func LoadCollectionSpec(file string) (*CollectionSpec, error) {
 }
 
 // LoadCollectionSpecFromReader parses an ELF file into a CollectionSpec.
+//
+// It is safe to use with untrusted input: malformed files return an
+// error, and memory is only allocated for data which is present in rd.
+// Compressed sections are not supported.
 func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
-	f, err := elf.NewFile(rd)
+	f, err := internal.NewSafeELFFile(rd)
 	if err != nil {
 		return nil, err
 	}
@@ -53,7 +62,13 @@ func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
 		return nil, xerrors.Errorf("load symbols: %v", err)
 	}
 
-	ec := &elfCode{f, symbols, symbolsPerSection(symbols), "", 0}
+	ec := &elfCode{
+		SafeELFFile:       f,
+		symbols:           symbols,
+		symbolsPerSection: symbolsPerSection(symbols),
+		symbolBindings:    symbolBindingsPerSection(symbols),
+		weakMaps:          make(map[string]bool),
+	}
 
 	var (
 		licenseSection *elf.Section
@@ -90,6 +105,8 @@ func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
 			relSections[idx] = sec
 		case sec.Type == elf.SHT_PROGBITS && (sec.Flags&elf.SHF_EXECINSTR) != 0 && sec.Size > 0:
 			progSections[elf.SectionIndex(i)] = sec
+		default:
+			internal.Debug("Ignoring ELF section", "section", sec.Name, "type", sec.Type)
 		}
 	}
 
@@ -107,6 +124,7 @@ func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
 	if err != nil {
 		return nil, xerrors.Errorf("load BTF: %w", err)
 	}
+	internal.Debug("Loaded BTF", "present", btfSpec != nil)
 
 	maps := make(map[string]*MapSpec)
 	if err := ec.loadMaps(maps, mapSections); err != nil {
@@ -125,6 +143,11 @@ func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
 		}
 	}
 
+	ec.ksyms, err = loadKsyms(btfSpec)
+	if err != nil {
+		return nil, xerrors.Errorf("load ksyms: %w", err)
+	}
+
 	relocations, err := ec.loadRelocations(relSections)
 	if err != nil {
 		return nil, xerrors.Errorf("load relocations: %w", err)
@@ -142,11 +165,16 @@ func loadLicense(sec *elf.Section) (string, error) {
 	if sec == nil {
 		return "", xerrors.New("missing license section")
 	}
-	data, err := sec.Data()
+	data, err := internal.ReadSection(sec)
 	if err != nil {
-		return "", xerrors.Errorf("section %s: %v", sec.Name, err)
+		return "", err
 	}
-	return string(bytes.TrimRight(data, "\000")), nil
+
+	// The kernel only uses the first string of the section.
+	if i := bytes.IndexByte(data, 0); i != -1 {
+		data = data[:i]
+	}
+	return string(data), nil
 }
 
 func loadVersion(sec *elf.Section, bo binary.ByteOrder) (uint32, error) {
@@ -154,60 +182,86 @@ func loadVersion(sec *elf.Section, bo binary.ByteOrder) (uint32, error) {
 		return 0, nil
 	}
 
+	r, err := internal.OpenSection(sec)
+	if err != nil {
+		return 0, err
+	}
+
 	var version uint32
-	if err := binary.Read(sec.Open(), bo, &version); err != nil {
+	if err := binary.Read(r, bo, &version); err != nil {
 		return 0, xerrors.Errorf("section %s: %v", sec.Name, err)
 	}
 	return version, nil
 }
 
-func (ec *elfCode) loadPrograms(progSections map[elf.SectionIndex]*elf.Section, relocations map[elf.SectionIndex]map[uint64]elf.Symbol, btf *btf.Spec) (map[string]*ProgramSpec, error) {
+func (ec *elfCode) loadPrograms(progSections map[elf.SectionIndex]*elf.Section, relocations map[elf.SectionIndex]map[uint64]elf.Symbol, btfSpec *btf.Spec) (map[string]*ProgramSpec, error) {
 	var (
-		progs []*ProgramSpec
-		libs  []*ProgramSpec
+		progs      []*ProgramSpec
+		libs       []*ProgramSpec
+		overridden = ec.overriddenSymbols(progSections)
 	)
 
-	for idx, prog := range progSections {
+	for idx, sec := range progSections {
 		syms := ec.symbolsPerSection[idx]
 		if len(syms) == 0 {
-			return nil, xerrors.Errorf("section %v: missing symbols", prog.Name)
+			return nil, xerrors.Errorf("section %v: missing symbols", sec.Name)
 		}
 
 		funcSym := syms[0]
 		if funcSym == "" {
-			return nil, xerrors.Errorf("section %v: no label at start", prog.Name)
+			return nil, xerrors.Errorf("section %v: no label at start", sec.Name)
 		}
 
-		insns, length, err := ec.loadInstructions(prog, syms, relocations[idx])
+		insns, length, err := ec.loadInstructions(idx, sec, syms, relocations[idx])
 		if err != nil {
 			return nil, xerrors.Errorf("program %s: can't unmarshal instructions: %w", funcSym, err)
 		}
 
-		progType, attachType := getProgType(prog.Name)
+		var secBTF *btf.Program
+		if btfSpec != nil {
+			secBTF, err = btfSpec.Program(sec.Name, length)
+			if err != nil {
+				return nil, xerrors.Errorf("BTF for section %s (program %s): %w", sec.Name, funcSym, err)
+			}
 
-		spec := &ProgramSpec{
-			Name:          funcSym,
-			Type:          progType,
-			AttachType:    attachType,
-			License:       ec.license,
-			KernelVersion: ec.version,
-			Instructions:  insns,
+			if err := assignSourceLines(insns, secBTF); err != nil {
+				return nil, xerrors.Errorf("BTF for section %s (program %s): %w", sec.Name, funcSym, err)
+			}
 		}
 
-		if btf != nil {
-			spec.BTF, err = btf.Program(prog.Name, length)
-			if err != nil {
-				return nil, xerrors.Errorf("BTF for section %s (program %s): %w", prog.Name, funcSym, err)
+		progType, attachType := getProgType(sec.Name)
+
+		funcs := splitFunctions(insns, syms, length)
+		for _, fn := range funcs {
+			if overridden[idx][fn.start] {
+				// Calls resolve to the definition which overrides
+				// the weak one.
+				continue
+			}
+
+			spec := &ProgramSpec{
+				Name:          fn.name,
+				Type:          progType,
+				AttachType:    attachType,
+				License:       ec.license,
+				KernelVersion: ec.version,
+				Instructions:  fn.insns,
 			}
-		}
 
-		if spec.Type == UnspecifiedProgram {
-			// There is no single name we can use for "library" sections,
-			// since they may contain multiple functions. We'll decode the
-			// labels they contain later on, and then link sections that way.
-			libs = append(libs, spec)
-		} else {
-			progs = append(progs, spec)
+			if secBTF != nil {
+				spec.BTF = btf.ProgramSlice(secBTF, fn.start, fn.end)
+			}
+
+			// Functions in "library" sections like .text, and static
+			// functions next to programs are subprograms. They are
+			// linked into the programs which call them later on.
+			if spec.Type == UnspecifiedProgram || (len(funcs) > 1 && ec.symbolBindings[idx][fn.start] == elf.STB_LOCAL) {
+				internal.Debug("Found subprogram", "name", fn.name, "section", sec.Name)
+				libs = append(libs, spec)
+			} else {
+				internal.Debug("Found program", "name", fn.name, "section", sec.Name, "type", progType, "attach", attachType)
+				progs = append(progs, spec)
+			}
 		}
 	}
 
@@ -223,33 +277,156 @@ func (ec *elfCode) loadPrograms(progSections map[elf.SectionIndex]*elf.Section,
 	return res, nil
 }
 
-func (ec *elfCode) loadInstructions(section *elf.Section, symbols map[uint64]string, relocations map[uint64]elf.Symbol) (asm.Instructions, uint64, error) {
+type function struct {
+	name       string
+	start, end uint64
+	insns      asm.Instructions
+}
+
+// splitFunctions splits the instructions of a section at each symbol.
+//
+// Offsets are in bytes, and length is the size of the section.
+func splitFunctions(insns asm.Instructions, syms map[uint64]string, length uint64) []function {
 	var (
-		r      = section.Open()
-		insns  asm.Instructions
-		offset uint64
+		funcs []function
+		first int
+		iter  = insns.Iterate()
 	)
-	for {
-		var ins asm.Instruction
-		n, err := ins.Unmarshal(r, ec.ByteOrder)
-		if err == io.EOF {
-			return insns, offset, nil
+	for iter.Next() {
+		offset := iter.Offset.Bytes()
+		name := syms[offset]
+		if name == "" {
+			continue
 		}
-		if err != nil {
-			return nil, 0, xerrors.Errorf("offset %d: %w", offset, err)
+
+		if len(funcs) > 0 {
+			prev := &funcs[len(funcs)-1]
+			prev.end = offset
+			prev.insns = insns[first:iter.Index:iter.Index]
+		}
+
+		first = iter.Index
+		funcs = append(funcs, function{name: name, start: offset})
+	}
+
+	if len(funcs) > 0 {
+		last := &funcs[len(funcs)-1]
+		last.end = length
+		last.insns = insns[first:len(insns):len(insns)]
+	}
+	return funcs
+}
+
+// overriddenSymbols returns the weak definitions in sections which are
+// overridden by another definition of the same symbol.
+//
+// Like the static linker, a strong definition takes precedence over weak
+// ones. Of multiple weak definitions the one in the lowest section wins.
+func (ec *elfCode) overriddenSymbols(sections map[elf.SectionIndex]*elf.Section) map[elf.SectionIndex]map[uint64]bool {
+	type definition struct {
+		section elf.SectionIndex
+		offset  uint64
+		weak    bool
+	}
+
+	chosen := make(map[string]definition)
+	for idx := range sections {
+		for offset, sym := range ec.symbolsPerSection[idx] {
+			def := definition{idx, offset, ec.isWeak(idx, offset)}
+
+			prev, ok := chosen[sym]
+			switch {
+			case !ok:
+			case prev.weak && !def.weak:
+			case prev.weak && def.weak && def.section < prev.section:
+			default:
+				continue
+			}
+			chosen[sym] = def
+		}
+	}
+
+	overridden := make(map[elf.SectionIndex]map[uint64]bool)
+	for idx := range sections {
+		for offset, sym := range ec.symbolsPerSection[idx] {
+			if !ec.isWeak(idx, offset) {
+				continue
+			}
+
+			if def := chosen[sym]; def.section == idx && def.offset == offset {
+				continue
+			}
+
+			if overridden[idx] == nil {
+				overridden[idx] = make(map[uint64]bool)
+			}
+			overridden[idx][offset] = true
 		}
+	}
+
+	return overridden
+}
+
+// assignSourceLines sets the Source of each instruction that has BTF
+// line info.
+func assignSourceLines(insns asm.Instructions, prog *btf.Program) error {
+	lines, err := btf.ProgramLines(prog)
+	if err != nil {
+		return err
+	}
+
+	iter := insns.Iterate()
+	for iter.Next() {
+		if line, ok := lines[uint64(iter.Offset)]; ok {
+			*iter.Ins = iter.Ins.WithSource(line)
+		}
+	}
+
+	return nil
+}
+
+func (ec *elfCode) loadInstructions(idx elf.SectionIndex, section *elf.Section, symbols map[uint64]string, relocations map[uint64]elf.Symbol) (asm.Instructions, uint64, error) {
+	data, err := internal.ReadSection(section)
+	if err != nil {
+		return nil, 0, xerrors.Errorf("can't read section: %w", err)
+	}
+
+	var (
+		insns asm.Instructions
+		dec   = asm.NewDecoder(data, ec.ByteOrder)
+	)
+	for dec.Next() {
+		ins, offset := dec.Ins, dec.Offset
 
 		ins.Symbol = symbols[offset]
+		ins = ins.WithSectionOffset(offset)
 
 		if rel, ok := relocations[offset]; ok {
 			if err = ec.relocateInstruction(&ins, rel); err != nil {
 				return nil, 0, xerrors.Errorf("offset %d: can't relocate instruction: %w", offset, err)
 			}
+		} else if ins.OpCode.JumpOp() == asm.Call && ins.Src == asm.PseudoCall {
+			// Calls to functions in the same section don't have a
+			// relocation, since they are encoded relative to the
+			// instruction. Make them symbolic, so that the section
+			// can be split into functions.
+			name, err := ec.callTarget(ins, idx, int64(offset))
+			if err != nil {
+				return nil, 0, xerrors.Errorf("offset %d: %w", offset, err)
+			}
+
+			ins.Reference = name
+			ins.Constant = -1
+			ins = ins.WithRelocation(&asm.Relocation{Kind: asm.SubprogRelocation, Symbol: name})
 		}
 
 		insns = append(insns, ins)
-		offset += n
 	}
+	if err := dec.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return insns, uint64(len(data)), nil
 }
 
 func (ec *elfCode) relocateInstruction(ins *asm.Instruction, rel elf.Symbol) error {
@@ -257,8 +434,16 @@ func (ec *elfCode) relocateInstruction(ins *asm.Instruction, rel elf.Symbol) err
 		typ  = elf.ST_TYPE(rel.Info)
 		bind = elf.ST_BIND(rel.Info)
 		ref  = rel.Name
+		kind asm.RelocationKind
+		weak = bind == elf.STB_WEAK
 	)
 
+	// Weak symbols are resolved like global ones, the ELF only contains
+	// a single definition.
+	if weak {
+		bind = elf.STB_GLOBAL
+	}
+
 outer:
 	switch {
 	case ins.OpCode == asm.LoadImmOp(asm.DWord):
@@ -289,11 +474,18 @@ outer:
 			// expects it in the second one.
 			ins.Constant <<= 32
 			ins.Src = asm.PseudoMapValue
+			kind = asm.MapValueRelocation
 
 		case elf.STT_NOTYPE:
 			if bind == elf.STB_GLOBAL && rel.Section == elf.SHN_UNDEF {
-				// This is a relocation generated by inline assembly.
-				// We can't do more than assing ins.Reference.
+				// This is either a kernel symbol declared via __ksym, or
+				// a relocation generated by inline assembly. Both have
+				// to be resolved by the user. Weak symbols are zero
+				// until they are resolved.
+				kind = asm.ExternRelocation
+				if ec.ksyms[ref] {
+					kind = asm.KsymRelocation
+				}
 				break outer
 			}
 
@@ -307,6 +499,7 @@ outer:
 			}
 
 			ins.Src = asm.PseudoMapFD
+			kind = asm.MapRelocation
 		}
 
 		// Mark the instruction as needing an update when creating the
@@ -316,22 +509,77 @@ outer:
 		}
 
 	case ins.OpCode.JumpOp() == asm.Call:
-		if bind != elf.STB_GLOBAL {
-			return xerrors.Errorf("call: %s: unsupported relocation %s", ref, bind)
-		}
+		switch {
+		case typ == elf.STT_SECTION && bind == elf.STB_LOCAL:
+			// This is a call to a static function in another section.
+			// The offset of the function is encoded in the instruction,
+			// relative to the section symbol.
+			name, err := ec.callTarget(*ins, rel.Section, int64(rel.Value))
+			if err != nil {
+				return err
+			}
+			ref = name
 
-		if typ != elf.STT_NOTYPE && typ != elf.STT_FUNC {
+		case typ != elf.STT_NOTYPE && typ != elf.STT_FUNC:
 			return xerrors.Errorf("call: %s: invalid symbol type %s", ref, typ)
+
+		case bind != elf.STB_GLOBAL && (bind != elf.STB_LOCAL || typ != elf.STT_FUNC):
+			return xerrors.Errorf("call: %s: unsupported relocation %s", ref, bind)
 		}
 
+		// The call is resolved via the reference during linking.
+		ins.Constant = -1
+		kind = asm.SubprogRelocation
+
 	default:
 		return xerrors.Errorf("relocation for unsupported instruction: %s", ins.OpCode)
 	}
 
 	ins.Reference = ref
+	*ins = ins.WithRelocation(&asm.Relocation{Kind: kind, Symbol: ref, Weak: weak})
 	return nil
 }
 
+// callTarget returns the name of the function called by a bpf-to-bpf
+// call, whose constant is relative to base.
+func (ec *elfCode) callTarget(ins asm.Instruction, section elf.SectionIndex, base int64) (string, error) {
+	target := base + (ins.Constant+1)*asm.InstructionSize
+	if target < 0 {
+		return "", xerrors.Errorf("call: negative target offset %d", target)
+	}
+
+	name := ec.symbolsPerSection[section][uint64(target)]
+	if name == "" {
+		return "", xerrors.Errorf("call: no function at offset %d of section %d", target, section)
+	}
+	return name, nil
+}
+
+// loadKsyms returns the kernel symbols declared via __ksym, which
+// are recorded in the .ksyms BTF section.
+func loadKsyms(spec *btf.Spec) (map[string]bool, error) {
+	if spec == nil {
+		return nil, nil
+	}
+
+	var ksyms btf.Datasec
+	err := spec.FindType(".ksyms", &ksyms)
+	if xerrors.Is(err, btf.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(ksyms.Vars))
+	for _, v := range ksyms.Vars {
+		if v, ok := v.Type.(*btf.Var); ok {
+			names[string(v.Name)] = true
+		}
+	}
+	return names, nil
+}
+
 func (ec *elfCode) loadMaps(maps map[string]*MapSpec, mapSections map[elf.SectionIndex]*elf.Section) error {
 	for idx, sec := range mapSections {
 		syms := ec.symbolsPerSection[idx]
@@ -343,20 +591,18 @@ func (ec *elfCode) loadMaps(maps map[string]*MapSpec, mapSections map[elf.Sectio
 			return xerrors.Errorf("section %v: map descriptors are not of equal size", sec.Name)
 		}
 
-		var (
-			r    = sec.Open()
-			size = sec.Size / uint64(len(syms))
-		)
+		r, err := internal.OpenSection(sec)
+		if err != nil {
+			return err
+		}
+
+		size := sec.Size / uint64(len(syms))
 		for i, offset := 0, uint64(0); i < len(syms); i, offset = i+1, offset+size {
 			mapSym := syms[offset]
 			if mapSym == "" {
 				return xerrors.Errorf("section %s: missing symbol for map at offset %d", sec.Name, offset)
 			}
 
-			if maps[mapSym] != nil {
-				return xerrors.Errorf("section %v: map %v already exists", sec.Name, mapSym)
-			}
-
 			lr := io.LimitReader(r, int64(size))
 
 			spec := MapSpec{
@@ -379,13 +625,33 @@ func (ec *elfCode) loadMaps(maps map[string]*MapSpec, mapSections map[elf.Sectio
 				return xerrors.Errorf("map %v: unknown and non-zero fields in definition", mapSym)
 			}
 
-			maps[mapSym] = &spec
+			if err := ec.addMap(maps, mapSym, &spec, ec.isWeak(idx, offset)); err != nil {
+				return xerrors.Errorf("section %v: %w", sec.Name, err)
+			}
 		}
 	}
 
 	return nil
 }
 
+// addMap adds a map definition, resolving duplicates like the static
+// linker: a strong definition overrides a weak one, and the first of
+// multiple weak definitions is used.
+func (ec *elfCode) addMap(maps map[string]*MapSpec, name string, spec *MapSpec, weak bool) error {
+	if maps[name] != nil {
+		if weak {
+			return nil
+		}
+		if !ec.weakMaps[name] {
+			return xerrors.Errorf("map %v already exists", name)
+		}
+	}
+
+	maps[name] = spec
+	ec.weakMaps[name] = weak
+	return nil
+}
+
 func (ec *elfCode) loadBTFMaps(maps map[string]*MapSpec, mapSections map[elf.SectionIndex]*elf.Section, spec *btf.Spec) error {
 	if spec == nil {
 		return xerrors.Errorf("missing BTF")
@@ -397,11 +663,7 @@ func (ec *elfCode) loadBTFMaps(maps map[string]*MapSpec, mapSections map[elf.Sec
 			return xerrors.Errorf("section %v: no symbols", sec.Name)
 		}
 
-		for _, sym := range syms {
-			if maps[sym] != nil {
-				return xerrors.Errorf("section %v: map %v already exists", sec.Name, sym)
-			}
-
+		for offset, sym := range syms {
 			btfMap, btfMapMembers, err := spec.Map(sym)
 			if err != nil {
 				return xerrors.Errorf("map %v: can't get BTF: %w", sym, err)
@@ -412,7 +674,9 @@ func (ec *elfCode) loadBTFMaps(maps map[string]*MapSpec, mapSections map[elf.Sec
 				return xerrors.Errorf("map %v: %w", sym, err)
 			}
 
-			maps[sym] = spec
+			if err := ec.addMap(maps, sym, spec, ec.isWeak(idx, offset)); err != nil {
+				return xerrors.Errorf("section %v: %w", sec.Name, err)
+			}
 		}
 	}
 
@@ -498,12 +762,7 @@ func (ec *elfCode) loadDataSections(maps map[string]*MapSpec, dataSections map[e
 			return err
 		}
 
-		data, err := sec.Data()
-		if err != nil {
-			return xerrors.Errorf("data section %s: can't get contents: %w", sec.Name, err)
-		}
-
-		if uint64(len(data)) > math.MaxUint32 {
+		if sec.Size > math.MaxUint32 {
 			return xerrors.Errorf("data section %s: contents exceed maximum size", sec.Name)
 		}
 
@@ -511,19 +770,24 @@ func (ec *elfCode) loadDataSections(maps map[string]*MapSpec, dataSections map[e
 			Name:       SanitizeName(sec.Name, -1),
 			Type:       Array,
 			KeySize:    4,
-			ValueSize:  uint32(len(data)),
+			ValueSize:  uint32(sec.Size),
 			MaxEntries: 1,
-			Contents:   []MapKV{{uint32(0), data}},
 			BTF:        btfMap,
 		}
 
-		switch sec.Name {
-		case ".rodata":
+		// The kernel already zero-initializes the map, so the contents
+		// of .bss don't have to be read.
+		if sec.Name != ".bss" {
+			data, err := internal.ReadSection(sec)
+			if err != nil {
+				return xerrors.Errorf("data section %s: can't get contents: %w", sec.Name, err)
+			}
+			mapSpec.Contents = []MapKV{{uint32(0), data}}
+		}
+
+		if sec.Name == ".rodata" {
 			mapSpec.Flags = unix.BPF_F_RDONLY_PROG
 			mapSpec.Freeze = true
-		case ".bss":
-			// The kernel already zero-initializes the map
-			mapSpec.Contents = nil
 		}
 
 		maps[sec.Name] = mapSpec
@@ -553,6 +817,7 @@ func getProgType(v string) (ProgramType, AttachType) {
 		"sk_msg":          SkMsg,
 		"lirc_mode2":      LircMode2,
 		"flow_dissector":  FlowDissector,
+		"sk_lookup":       SkLookup,
 
 		"cgroup_skb/":       CGroupSKB,
 		"cgroup/dev":        CGroupDevice,
@@ -582,6 +847,7 @@ func getProgType(v string) (ProgramType, AttachType) {
 		"sk_msg":                AttachSkSKBStreamVerdict,
 		"lirc_mode2":            AttachLircMode2,
 		"flow_dissector":        AttachFlowDissector,
+		"sk_lookup":             AttachSkLookup,
 		"cgroup/bind4":          AttachCGroupInet4Bind,
 		"cgroup/bind6":          AttachCGroupInet6Bind,
 		"cgroup/connect4":       AttachCGroupInet4Connect,
@@ -618,7 +884,11 @@ func (ec *elfCode) loadRelocations(sections map[elf.SectionIndex]*elf.Section) (
 			return nil, xerrors.Errorf("section %s: relocations are less than 16 bytes", sec.Name)
 		}
 
-		r := sec.Open()
+		r, err := internal.OpenSection(sec)
+		if err != nil {
+			return nil, err
+		}
+
 		for off := uint64(0); off < sec.Size; off += sec.Entsize {
 			ent := io.LimitReader(r, int64(sec.Entsize))
 
@@ -640,6 +910,32 @@ func (ec *elfCode) loadRelocations(sections map[elf.SectionIndex]*elf.Section) (
 	return result, nil
 }
 
+func (ec *elfCode) isWeak(idx elf.SectionIndex, offset uint64) bool {
+	return ec.symbolBindings[idx][offset] == elf.STB_WEAK
+}
+
+// symbolBindingsPerSection returns the binding of the symbols returned
+// by symbolsPerSection.
+func symbolBindingsPerSection(symbols []elf.Symbol) map[elf.SectionIndex]map[uint64]elf.SymBind {
+	result := make(map[elf.SectionIndex]map[uint64]elf.SymBind)
+	for _, sym := range symbols {
+		if sym.Section == elf.SHN_UNDEF || sym.Section >= elf.SHN_LORESERVE {
+			continue
+		}
+
+		if sym.Name == "" || elf.ST_TYPE(sym.Info) > elf.STT_FUNC {
+			continue
+		}
+
+		idx := sym.Section
+		if _, ok := result[idx]; !ok {
+			result[idx] = make(map[uint64]elf.SymBind)
+		}
+		result[idx][sym.Value] = elf.ST_BIND(sym.Info)
+	}
+	return result
+}
+
 func symbolsPerSection(symbols []elf.Symbol) map[elf.SectionIndex]map[uint64]string {
 	result := make(map[elf.SectionIndex]map[uint64]string)
 	for i, sym := range symbols {
@@ -1,11 +1,15 @@
 package ebpf
 
 import (
+	"debug/elf"
 	"flag"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/btf"
 	"github.com/cilium/ebpf/internal/testutils"
 )
 
@@ -178,6 +182,45 @@ func checkProgramSpec(t *testing.T, progs map[string]*ProgramSpec, name string,
 	}
 }
 
+func TestLoadSourceLines(t *testing.T) {
+	spec, err := LoadCollectionSpec("testdata/loader-clang-8.elf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ins := spec.Programs["xdp_prog"].Instructions[0]
+	line, ok := ins.Source().(*btf.Line)
+	if !ok {
+		t.Fatalf("Expected first instruction to have a *btf.Line, got %T", ins.Source())
+	}
+
+	if !strings.HasSuffix(line.FileName(), "loader.c") {
+		t.Error("Unexpected file name", line.FileName())
+	}
+
+	if line.LineNumber() == 0 || line.Line() == "" {
+		t.Error("Line is missing source information:", line)
+	}
+}
+
+func TestLoadRelocations(t *testing.T) {
+	spec, err := LoadCollectionSpec("testdata/loader-clang-8.elf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relos := spec.Programs["xdp_prog"].UnresolvedRelocations()
+	if len(relos) == 0 {
+		t.Fatal("Expected xdp_prog to have relocations")
+	}
+
+	for i, rel := range relos {
+		if rel.Kind != asm.MapRelocation {
+			t.Errorf("Instruction %d: expected a map relocation, got %s", i, rel)
+		}
+	}
+}
+
 func TestCollectionSpecDetach(t *testing.T) {
 	coll := Collection{
 		Maps: map[string]*Map{
@@ -248,3 +291,138 @@ func TestLibBPFCompat(t *testing.T) {
 		})
 	}
 }
+
+func TestWeakSymbols(t *testing.T) {
+	symbols := []elf.Symbol{
+		{Name: "lib_func", Info: elf.ST_INFO(elf.STB_WEAK, elf.STT_FUNC), Section: 3, Value: 0},
+		{Name: "other_func", Info: elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC), Section: 3, Value: 16},
+		{Name: "lib_func", Info: elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC), Section: 4, Value: 0},
+		{Name: "weak_func", Info: elf.ST_INFO(elf.STB_WEAK, elf.STT_FUNC), Section: 4, Value: 8},
+		{Name: "weak_func", Info: elf.ST_INFO(elf.STB_WEAK, elf.STT_FUNC), Section: 5, Value: 0},
+	}
+
+	ec := &elfCode{
+		symbolsPerSection: symbolsPerSection(symbols),
+		symbolBindings:    symbolBindingsPerSection(symbols),
+		weakMaps:          make(map[string]bool),
+	}
+
+	overridden := ec.overriddenSymbols(map[elf.SectionIndex]*elf.Section{3: nil, 4: nil, 5: nil})
+	if !overridden[3][0] {
+		t.Error("Strong definition doesn't override weak one")
+	}
+	if overridden[3][16] || overridden[4][0] {
+		t.Error("Strong definition is overridden")
+	}
+	if overridden[4][8] || !overridden[5][0] {
+		t.Error("Weak definition in the lowest section isn't used")
+	}
+
+	maps := make(map[string]*MapSpec)
+	weak, strong := &MapSpec{Name: "weak"}, &MapSpec{Name: "strong"}
+	if err := ec.addMap(maps, "map", weak, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.addMap(maps, "map", strong, false); err != nil {
+		t.Fatal("Can't override weak map:", err)
+	}
+	if err := ec.addMap(maps, "map", weak, true); err != nil {
+		t.Fatal(err)
+	}
+	if maps["map"] != strong {
+		t.Error("Weak map definition overrides strong one")
+	}
+	if err := ec.addMap(maps, "map", strong, false); err == nil {
+		t.Error("Duplicate strong map definition doesn't fail")
+	}
+}
+
+func TestSplitFunctions(t *testing.T) {
+	insns := asm.Instructions{
+		asm.LoadImm(asm.R0, 0, asm.DWord),
+		asm.Call.Label("static_func"),
+		asm.Return(),
+		asm.Mov.Imm(asm.R0, 1),
+		asm.Return(),
+	}
+	syms := map[uint64]string{
+		0:  "prog",
+		32: "static_func",
+	}
+
+	funcs := splitFunctions(insns, syms, 48)
+	if len(funcs) != 2 {
+		t.Fatal("Expected two functions, got", len(funcs))
+	}
+
+	prog, static := funcs[0], funcs[1]
+	if prog.name != "prog" || prog.start != 0 || prog.end != 32 || len(prog.insns) != 3 {
+		t.Errorf("Invalid first function: %+v", prog)
+	}
+	if static.name != "static_func" || static.start != 32 || static.end != 48 || len(static.insns) != 2 {
+		t.Errorf("Invalid second function: %+v", static)
+	}
+
+	if cap(prog.insns) != len(prog.insns) {
+		t.Error("Appending to a function overwrites the next one")
+	}
+}
+
+func TestCallTarget(t *testing.T) {
+	symbols := []elf.Symbol{
+		{Name: "global_func", Info: elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC), Section: 3, Value: 0},
+		{Name: "static_func", Info: elf.ST_INFO(elf.STB_LOCAL, elf.STT_FUNC), Section: 3, Value: 24},
+	}
+
+	ec := &elfCode{
+		symbolsPerSection: symbolsPerSection(symbols),
+		symbolBindings:    symbolBindingsPerSection(symbols),
+	}
+
+	// A call to a static function in another section is relocated
+	// against the section symbol.
+	ins := asm.Instruction{
+		OpCode:   asm.OpCode(asm.JumpClass).SetJumpOp(asm.Call),
+		Src:      asm.PseudoCall,
+		Constant: 2,
+	}
+	section := elf.Symbol{
+		Name:    ".text",
+		Info:    elf.ST_INFO(elf.STB_LOCAL, elf.STT_SECTION),
+		Section: 3,
+	}
+	if err := ec.relocateInstruction(&ins, section); err != nil {
+		t.Fatal(err)
+	}
+
+	if ins.Reference != "static_func" || ins.Constant != -1 {
+		t.Errorf("Call isn't resolved to static_func: %v", ins)
+	}
+
+	ins.Constant = 0
+	if err := ec.relocateInstruction(&ins, section); err == nil {
+		t.Error("Call to an offset without a function doesn't fail")
+	}
+}
+
+func TestWeakExtern(t *testing.T) {
+	ec := &elfCode{}
+	ins := asm.LoadImm(asm.R1, 0, asm.DWord)
+
+	sym := elf.Symbol{
+		Name:    "maybe_missing",
+		Info:    elf.ST_INFO(elf.STB_WEAK, elf.STT_NOTYPE),
+		Section: elf.SHN_UNDEF,
+	}
+	if err := ec.relocateInstruction(&ins, sym); err != nil {
+		t.Fatal(err)
+	}
+
+	rel := ins.Relocation()
+	if rel == nil || rel.Kind != asm.ExternRelocation || !rel.Weak {
+		t.Fatal("Expected a weak extern relocation, got", rel)
+	}
+	if ins.Constant != 0 {
+		t.Error("Unresolved weak extern isn't zero")
+	}
+}
@@ -0,0 +1,278 @@
+package emulator
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// Mismatch is a difference between running a program in the emulator
+// and in the kernel.
+type Mismatch struct {
+	// Input is the index of the input which caused the mismatch.
+	Input int
+	// What differs, for example "return value" or "context".
+	What string
+	// The results of the emulator and the kernel.
+	Emulator, Kernel string
+}
+
+func (mm Mismatch) String() string {
+	return fmt.Sprintf("input %d: %s differs: emulator %s, kernel %s", mm.Input, mm.What, mm.Emulator, mm.Kernel)
+}
+
+// Compare runs a program in the emulator and in the kernel via
+// BPF_PROG_TEST_RUN, and returns the differences in return values,
+// contexts and map contents after each input.
+//
+// The program is loaded as an ebpf.Syscall program, whose context is an
+// arbitrary buffer like in the emulator. Each input is passed as the
+// context. The program must be deterministic and may only use helpers
+// available to Syscall programs.
+//
+// maps contains the maps used by the program, by the name of the symbol
+// they are referenced by. Only empty Hash and Array maps are supported.
+// Map contents are carried over from one input to the next, like
+// between calls to Machine.Run.
+//
+// Errors returned by the emulator are reported as mismatches. Requires
+// at least Linux 5.14.
+func Compare(insns asm.Instructions, maps map[string]*ebpf.MapSpec, inputs [][]byte) ([]Mismatch, error) {
+	if err := haveSyscallPrograms(); err != nil {
+		return nil, err
+	}
+
+	m, err := New(insns)
+	if err != nil {
+		return nil, err
+	}
+
+	kernelMaps := make(map[string]*ebpf.Map, len(maps))
+	defer func() {
+		for _, km := range kernelMaps {
+			km.Close()
+		}
+	}()
+
+	kernelInsns := make(asm.Instructions, len(insns))
+	copy(kernelInsns, insns)
+
+	for name, spec := range maps {
+		em, err := newMap(spec)
+		if err != nil {
+			return nil, xerrors.Errorf("map %s: %w", name, err)
+		}
+		m.Maps[name] = em
+
+		km, err := ebpf.NewMap(spec)
+		if err != nil {
+			return nil, xerrors.Errorf("map %s: %w", name, err)
+		}
+		kernelMaps[name] = km
+
+		err = kernelInsns.RewriteMapPtr(name, km.FD())
+		if err != nil && !asm.IsUnreferencedSymbol(err) {
+			return nil, xerrors.Errorf("map %s: %w", name, err)
+		}
+	}
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:         ebpf.Syscall,
+		Instructions: kernelInsns,
+		License:      "GPL",
+		Flags:        unix.BPF_F_SLEEPABLE,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer prog.Close()
+
+	var names []string
+	for name := range maps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var mismatches []Mismatch
+	for i, input := range inputs {
+		ctx := append([]byte(nil), input...)
+		kernelRet, err := prog.Run(&ebpf.RunOptions{
+			Context:    ctx,
+			ContextOut: &ctx,
+		})
+		if err != nil {
+			return nil, xerrors.Errorf("input %d: %w", i, err)
+		}
+
+		emulatorRet, err := m.Run(input)
+		if err != nil {
+			mismatches = append(mismatches, Mismatch{i, "result", err.Error(), fmt.Sprint(kernelRet)})
+			continue
+		}
+
+		if uint32(emulatorRet) != kernelRet {
+			mismatches = append(mismatches, Mismatch{i, "return value", fmt.Sprint(uint32(emulatorRet)), fmt.Sprint(kernelRet)})
+		}
+
+		if !bytes.Equal(m.Context(), ctx) {
+			mismatches = append(mismatches, Mismatch{i, "context", fmt.Sprintf("%x", m.Context()), fmt.Sprintf("%x", ctx)})
+		}
+
+		for _, name := range names {
+			mm, err := compareMap(m.Maps[name], kernelMaps[name])
+			if err != nil {
+				return nil, xerrors.Errorf("input %d: map %s: %w", i, name, err)
+			}
+
+			for _, mismatch := range mm {
+				mismatch.Input = i
+				mismatch.What = fmt.Sprintf("map %s %s", name, mismatch.What)
+				mismatches = append(mismatches, mismatch)
+			}
+		}
+	}
+
+	return mismatches, nil
+}
+
+// TB is the part of testing.TB used by CheckEquivalence.
+type TB interface {
+	Helper()
+	Error(args ...interface{})
+	Fatal(args ...interface{})
+	Skip(args ...interface{})
+}
+
+// CheckEquivalence calls Compare, and fails the test for each mismatch.
+//
+// The test is skipped if the kernel doesn't support Syscall programs.
+func CheckEquivalence(tb TB, insns asm.Instructions, maps map[string]*ebpf.MapSpec, inputs [][]byte) {
+	tb.Helper()
+
+	mismatches, err := Compare(insns, maps, inputs)
+	if xerrors.Is(err, ebpf.ErrNotSupported) {
+		tb.Skip(err)
+	}
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	for _, mismatch := range mismatches {
+		tb.Error(mismatch)
+	}
+}
+
+var haveSyscallPrograms = internal.FeatureTest("syscall programs", "5.14", func() bool {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type: ebpf.Syscall,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "GPL",
+		Flags:   unix.BPF_F_SLEEPABLE,
+	})
+	if err != nil {
+		// Other errors, like missing privileges, are returned when
+		// loading the program for real.
+		return !xerrors.Is(err, unix.EINVAL)
+	}
+	prog.Close()
+	return true
+})
+
+func newMap(spec *ebpf.MapSpec) (Map, error) {
+	if len(spec.Contents) > 0 {
+		return nil, xerrors.New("initial contents are not supported")
+	}
+
+	switch spec.Type {
+	case ebpf.Hash:
+		return NewHashMap(int(spec.KeySize), int(spec.ValueSize), int(spec.MaxEntries)), nil
+	case ebpf.Array:
+		return NewArrayMap(int(spec.ValueSize), int(spec.MaxEntries)), nil
+	default:
+		return nil, xerrors.Errorf("map type %s is not supported", spec.Type)
+	}
+}
+
+// compareMap returns a mismatch for each key which has a different value
+// in the emulator and the kernel, ordered by key.
+func compareMap(em Map, km *ebpf.Map) ([]Mismatch, error) {
+	emulated := mapContents(em)
+
+	kernel := make(map[string][]byte)
+	var key, value []byte
+	iter := km.Iterate()
+	for iter.Next(&key, &value) {
+		kernel[string(key)] = append([]byte(nil), value...)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+	for k := range emulated {
+		keys[k] = true
+	}
+	for k := range kernel {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var mismatches []Mismatch
+	for _, k := range sorted {
+		ev, inEmulator := emulated[k]
+		kv, inKernel := kernel[k]
+		if inEmulator == inKernel && bytes.Equal(ev, kv) {
+			continue
+		}
+
+		mismatches = append(mismatches, Mismatch{
+			What:     fmt.Sprintf("key %x", k),
+			Emulator: formatValue(ev, inEmulator),
+			Kernel:   formatValue(kv, inKernel),
+		})
+	}
+
+	return mismatches, nil
+}
+
+// mapContents returns all keys and values of a map created by newMap.
+func mapContents(m Map) map[string][]byte {
+	contents := make(map[string][]byte)
+	switch m := m.(type) {
+	case *HashMap:
+		for k, v := range m.values {
+			contents[k] = v
+		}
+
+	case *ArrayMap:
+		key := make([]byte, 4)
+		for i := 0; i < len(m.values)/m.valueSize; i++ {
+			internal.NativeEndian.PutUint32(key, uint32(i))
+			v, _ := m.value(key)
+			contents[string(key)] = v
+		}
+	}
+	return contents
+}
+
+func formatValue(value []byte, ok bool) string {
+	if !ok {
+		return "missing"
+	}
+	return fmt.Sprintf("%x", value)
+}
@@ -0,0 +1,98 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestCompare(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.14", "BPF_PROG_TYPE_SYSCALL")
+
+	// Add the second word of the context to the value at the key in the
+	// first word, and return the first word shifted by the second.
+	insns := asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.LoadMem(asm.R7, asm.R6, 0, asm.Word),
+		asm.LoadMem(asm.R8, asm.R6, 4, asm.Word),
+		asm.StoreMem(asm.RFP, -8, asm.R7, asm.Word),
+		asm.StoreMem(asm.RFP, -16, asm.R8, asm.DWord),
+		asm.LoadMapPtr(asm.R1, 0),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -8),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "create"),
+		asm.StoreXAdd(asm.R0, asm.R8, asm.DWord),
+		asm.Ja.Label("exit"),
+
+		asm.LoadMapPtr(asm.R1, 0).Sym("create"),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -8),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, -16),
+		asm.Mov.Imm(asm.R4, 0),
+		asm.FnMapUpdateElem.Call(),
+
+		asm.Mov.Reg(asm.R0, asm.R7).Sym("exit"),
+		asm.RSh.Reg32(asm.R0, asm.R8),
+		asm.Add.Imm32(asm.R7, 1),
+		asm.StoreMem(asm.R6, 0, asm.R7, asm.Word),
+		asm.Return(),
+	}
+	insns[5].Reference = "sums"
+	insns[12].Reference = "sums"
+
+	maps := map[string]*ebpf.MapSpec{
+		"sums": {
+			Type:       ebpf.Hash,
+			KeySize:    4,
+			ValueSize:  8,
+			MaxEntries: 8,
+		},
+	}
+
+	inputs := [][]byte{
+		{1, 0, 0, 0, 2, 0, 0, 0},
+		{1, 0, 0, 0, 3, 0, 0, 0},
+		{0xff, 0xff, 0xff, 0xff, 31, 0, 0, 0},
+		{0x80, 0, 0, 0x80, 33, 0, 0, 0},
+	}
+
+	CheckEquivalence(t, insns, maps, inputs)
+}
+
+func TestCompareMap(t *testing.T) {
+	km, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer km.Close()
+
+	if err := km.Put(uint32(1), uint32(42)); err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches, err := compareMap(NewArrayMap(4, 2), km)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mismatches) != 1 {
+		t.Fatal("Expected one mismatch, got", mismatches)
+	}
+
+	if mm := mismatches[0]; mm.What != "key 01000000" || mm.Emulator != "00000000" || mm.Kernel != "2a000000" {
+		t.Error("Unexpected mismatch", mm)
+	}
+}
+
+func TestHaveSyscallPrograms(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveSyscallPrograms)
+}
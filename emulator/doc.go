@@ -0,0 +1,11 @@
+// Package emulator executes eBPF programs in user space.
+//
+// It is intended for unit testing the logic of programs on machines
+// without root or a recent kernel. Helpers are implemented in Go and can
+// be replaced, and maps are backed by mock implementations.
+//
+// The emulator doesn't verify programs. A program which runs in the
+// emulator may still be rejected by the kernel, and pointer arithmetic
+// only works within the object a pointer refers to. Use Compare to check
+// that the emulator and the kernel agree on the behaviour of a program.
+package emulator
@@ -0,0 +1,562 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// StackSize is the size of the stack of each function in bytes.
+	StackSize = 512
+	// DefaultMaxInstructions is used if Machine.MaxInstructions is zero.
+	DefaultMaxInstructions = 1000000
+	// Same as MAX_CALL_FRAMES in the kernel.
+	maxCallFrames = 8
+)
+
+// Helper implements a BPF helper function.
+//
+// args contains R1 to R5, and the return value is stored in R0.
+// Returning an error aborts the program.
+type Helper func(m *Machine, args [5]uint64) (uint64, error)
+
+// Machine executes a program.
+//
+// It is not safe for concurrent use.
+type Machine struct {
+	// Helpers available to the program. Defaults to DefaultHelpers.
+	Helpers map[asm.BuiltinFunc]Helper
+	// Maps used by the program, by the name of the symbol
+	// they are referenced by.
+	Maps map[string]Map
+	// The number of instructions after which a program is aborted.
+	// Defaults to DefaultMaxInstructions.
+	MaxInstructions int
+
+	insns asm.Instructions
+	// The index of the target of each jump or call, or -1.
+	targets []int
+
+	mem     memory
+	ctx     []byte
+	mapPtrs map[Map]uint64
+	regs    [asm.R10 + 1]uint64
+	frames  []frame
+}
+
+type frame struct {
+	returnTo int
+	saved    [4]uint64
+	fp       uint64
+}
+
+// New prepares instructions for execution.
+//
+// Jumps and calls may refer to symbols or use raw offsets.
+func New(insns asm.Instructions) (*Machine, error) {
+	symbols, err := insns.SymbolOffsets()
+	if err != nil {
+		return nil, err
+	}
+
+	// Offsets in raw instructions don't match indices into insns,
+	// due to 64 bit loads.
+	indices := make(map[asm.RawInstructionOffset]int)
+	offsets := make([]asm.RawInstructionOffset, len(insns))
+	iter := insns.Iterate()
+	for iter.Next() {
+		indices[iter.Offset] = iter.Index
+		offsets[iter.Index] = iter.Offset
+	}
+
+	targets := make([]int, len(insns))
+	for i, ins := range insns {
+		targets[i] = -1
+
+		var delta int64
+		switch {
+		case ins.OpCode.JumpOp() == asm.Call && ins.Src == asm.PseudoCall:
+			delta = ins.Constant
+		case (ins.OpCode.Class() == asm.JumpClass || ins.OpCode.Class() == asm.Jump32Class) && ins.OpCode.JumpOp() != asm.Call && ins.OpCode.JumpOp() != asm.Exit:
+			delta = int64(ins.Offset)
+		default:
+			continue
+		}
+
+		if delta == -1 && ins.Reference != "" {
+			target, ok := symbols[ins.Reference]
+			if !ok {
+				return nil, xerrors.Errorf("instruction %d: reference to missing symbol %s", i, ins.Reference)
+			}
+			targets[i] = target
+			continue
+		}
+
+		raw := int64(offsets[i]) + 1 + delta
+		target, ok := indices[asm.RawInstructionOffset(raw)]
+		if raw < 0 || !ok {
+			return nil, xerrors.Errorf("instruction %d: invalid jump target %d", i, raw)
+		}
+		targets[i] = target
+	}
+
+	return &Machine{
+		Helpers:         DefaultHelpers(),
+		Maps:            make(map[string]Map),
+		MaxInstructions: DefaultMaxInstructions,
+		insns:           insns,
+		targets:         targets,
+	}, nil
+}
+
+// Run executes the program with ctx as its context, and returns R0.
+//
+// R1 points at a copy of ctx, which is discarded afterwards.
+// Map state is preserved between runs.
+func (m *Machine) Run(ctx []byte) (uint64, error) {
+	m.mem.reset()
+	m.mapPtrs = make(map[Map]uint64)
+	m.regs = [asm.R10 + 1]uint64{}
+	m.frames = m.frames[:0]
+
+	m.ctx = append([]byte(nil), ctx...)
+	m.regs[asm.R1] = m.mem.add(region{data: m.ctx})
+	m.regs[asm.RFP] = m.newStack()
+
+	maxInsns := m.MaxInstructions
+	if maxInsns == 0 {
+		maxInsns = DefaultMaxInstructions
+	}
+
+	pc := 0
+	for executed := 0; ; executed++ {
+		if executed >= maxInsns {
+			return 0, xerrors.Errorf("exceeded limit of %d instructions", maxInsns)
+		}
+
+		if pc < 0 || pc >= len(m.insns) {
+			return 0, xerrors.Errorf("instruction %d: out of bounds", pc)
+		}
+
+		next, done, err := m.step(pc)
+		if err != nil {
+			return 0, xerrors.Errorf("instruction %d: %w", pc, err)
+		}
+		if done {
+			return m.regs[asm.R0], nil
+		}
+		pc = next
+	}
+}
+
+// Context returns the context of the last run, including modifications
+// made by the program.
+func (m *Machine) Context() []byte {
+	return m.ctx
+}
+
+// NewPointer makes data available to the program, and returns a pointer
+// to it.
+//
+// Use it from helpers which return pointers. The pointer is only valid
+// for the current run.
+func (m *Machine) NewPointer(data []byte) uint64 {
+	return m.mem.add(region{data: data})
+}
+
+// Memory returns size bytes starting at addr.
+//
+// Modifying the returned slice modifies the memory of the program.
+func (m *Machine) Memory(addr uint64, size int) ([]byte, error) {
+	return m.mem.slice(addr, size)
+}
+
+// Map returns the map which addr points to.
+func (m *Machine) Map(addr uint64) (Map, error) {
+	return m.mem.mapAt(addr)
+}
+
+func (m *Machine) newStack() uint64 {
+	return m.mem.add(region{data: make([]byte, StackSize)}) + StackSize
+}
+
+func (m *Machine) mapPtr(name string) (uint64, Map, error) {
+	mp := m.Maps[name]
+	if mp == nil {
+		return 0, nil, xerrors.Errorf("missing map %s", name)
+	}
+
+	ptr, ok := m.mapPtrs[mp]
+	if !ok {
+		ptr = m.mem.add(region{m: mp})
+		m.mapPtrs[mp] = ptr
+	}
+	return ptr, mp, nil
+}
+
+// step executes the instruction at pc and returns the next pc.
+func (m *Machine) step(pc int) (int, bool, error) {
+	ins := &m.insns[pc]
+	op := ins.OpCode
+
+	switch op.Class() {
+	case asm.ALUClass, asm.ALU64Class:
+		return pc + 1, false, m.alu(ins)
+
+	case asm.JumpClass, asm.Jump32Class:
+		return m.jump(pc, ins)
+
+	case asm.LdClass:
+		return pc + 1, false, m.load(ins)
+
+	case asm.LdXClass:
+		if op.Mode() != asm.MemMode {
+			return 0, false, xerrors.Errorf("unsupported opcode %v", op)
+		}
+
+		value, err := m.read(m.regs[ins.Src]+uint64(int64(ins.Offset)), op.Size())
+		if err != nil {
+			return 0, false, err
+		}
+		m.regs[ins.Dst] = value
+		return pc + 1, false, nil
+
+	case asm.StClass, asm.StXClass:
+		addr := m.regs[ins.Dst] + uint64(int64(ins.Offset))
+		value := uint64(ins.Constant)
+		if op.Class() == asm.StXClass {
+			value = m.regs[ins.Src]
+		}
+
+		switch op.Mode() {
+		case asm.MemMode:
+			return pc + 1, false, m.write(addr, op.Size(), value)
+
+		case asm.XAddMode:
+			if op.Size() != asm.Word && op.Size() != asm.DWord {
+				return 0, false, xerrors.Errorf("unsupported opcode %v", op)
+			}
+
+			old, err := m.read(addr, op.Size())
+			if err != nil {
+				return 0, false, err
+			}
+			return pc + 1, false, m.write(addr, op.Size(), old+value)
+		}
+	}
+
+	return 0, false, xerrors.Errorf("unsupported opcode %v", op)
+}
+
+func (m *Machine) alu(ins *asm.Instruction) error {
+	op := ins.OpCode
+	is64 := op.Class() == asm.ALU64Class
+
+	dst := m.regs[ins.Dst]
+	src := uint64(ins.Constant)
+	if op.Source() == asm.RegSource {
+		src = m.regs[ins.Src]
+	}
+
+	if !is64 {
+		dst, src = uint64(uint32(dst)), uint64(uint32(src))
+	}
+
+	shiftMask := uint64(63)
+	if !is64 {
+		shiftMask = 31
+	}
+
+	switch op.ALUOp() {
+	case asm.Add:
+		dst += src
+	case asm.Sub:
+		dst -= src
+	case asm.Mul:
+		dst *= src
+	case asm.Div:
+		if src == 0 {
+			dst = 0
+		} else {
+			dst /= src
+		}
+	case asm.Or:
+		dst |= src
+	case asm.And:
+		dst &= src
+	case asm.LSh:
+		dst <<= src & shiftMask
+	case asm.RSh:
+		dst >>= src & shiftMask
+	case asm.Neg:
+		dst = -dst
+	case asm.Mod:
+		if src != 0 {
+			dst %= src
+		}
+	case asm.Xor:
+		dst ^= src
+	case asm.Mov:
+		dst = src
+	case asm.ArSh:
+		if is64 {
+			dst = uint64(int64(dst) >> (src & shiftMask))
+		} else {
+			dst = uint64(uint32(int32(dst) >> (src & shiftMask)))
+		}
+	case asm.Swap:
+		// Swap is always encoded in the 32 bit class, and operates
+		// on the whole register.
+		var err error
+		dst, err = swap(m.regs[ins.Dst], op.Endianness(), ins.Constant)
+		if err != nil {
+			return err
+		}
+		m.regs[ins.Dst] = dst
+		return nil
+	default:
+		return xerrors.Errorf("unsupported opcode %v", op)
+	}
+
+	if !is64 {
+		dst = uint64(uint32(dst))
+	}
+
+	m.regs[ins.Dst] = dst
+	return nil
+}
+
+func swap(value uint64, endian asm.Endianness, size int64) (uint64, error) {
+	// Converting to the native endianness is a no-op.
+	native := (endian == asm.LE) == (internal.NativeEndian == binary.LittleEndian)
+
+	switch size {
+	case 16:
+		if native {
+			return uint64(uint16(value)), nil
+		}
+		return uint64(bits.ReverseBytes16(uint16(value))), nil
+	case 32:
+		if native {
+			return uint64(uint32(value)), nil
+		}
+		return uint64(bits.ReverseBytes32(uint32(value))), nil
+	case 64:
+		if native {
+			return value, nil
+		}
+		return bits.ReverseBytes64(value), nil
+	default:
+		return 0, xerrors.Errorf("invalid swap size %d", size)
+	}
+}
+
+func (m *Machine) jump(pc int, ins *asm.Instruction) (int, bool, error) {
+	op := ins.OpCode
+	is32 := op.Class() == asm.Jump32Class
+
+	switch op.JumpOp() {
+	case asm.Exit, asm.Call, asm.Ja:
+		if is32 {
+			return 0, false, xerrors.Errorf("unsupported opcode %v", op)
+		}
+	}
+
+	switch op.JumpOp() {
+	case asm.Exit:
+		if len(m.frames) == 0 {
+			return 0, true, nil
+		}
+
+		caller := m.frames[len(m.frames)-1]
+		m.frames = m.frames[:len(m.frames)-1]
+		copy(m.regs[asm.R6:asm.R10], caller.saved[:])
+		m.regs[asm.RFP] = caller.fp
+		return caller.returnTo, false, nil
+
+	case asm.Call:
+		if ins.Src == asm.PseudoCall {
+			if len(m.frames) >= maxCallFrames-1 {
+				return 0, false, xerrors.New("too many nested calls")
+			}
+
+			caller := frame{returnTo: pc + 1, fp: m.regs[asm.RFP]}
+			copy(caller.saved[:], m.regs[asm.R6:asm.R10])
+			m.frames = append(m.frames, caller)
+			m.regs[asm.RFP] = m.newStack()
+			return m.targets[pc], false, nil
+		}
+
+		fn := asm.BuiltinFunc(ins.Constant)
+		helper := m.Helpers[fn]
+		if helper == nil {
+			return 0, false, xerrors.Errorf("helper %v is not implemented", fn)
+		}
+
+		var args [5]uint64
+		copy(args[:], m.regs[asm.R1:asm.R6])
+		ret, err := helper(m, args)
+		if err != nil {
+			return 0, false, xerrors.Errorf("helper %v: %w", fn, err)
+		}
+
+		// Helpers clobber the argument registers.
+		for r := asm.R1; r <= asm.R5; r++ {
+			m.regs[r] = 0
+		}
+		m.regs[asm.R0] = ret
+		return pc + 1, false, nil
+	}
+
+	dst := m.regs[ins.Dst]
+	src := uint64(ins.Constant)
+	if op.Source() == asm.RegSource {
+		src = m.regs[ins.Src]
+	}
+
+	sdst, ssrc := int64(dst), int64(src)
+	if is32 {
+		dst, src = uint64(uint32(dst)), uint64(uint32(src))
+		sdst, ssrc = int64(int32(dst)), int64(int32(src))
+	}
+
+	var taken bool
+	switch op.JumpOp() {
+	case asm.Ja:
+		taken = true
+	case asm.JEq:
+		taken = dst == src
+	case asm.JNE:
+		taken = dst != src
+	case asm.JGT:
+		taken = dst > src
+	case asm.JGE:
+		taken = dst >= src
+	case asm.JLT:
+		taken = dst < src
+	case asm.JLE:
+		taken = dst <= src
+	case asm.JSet:
+		taken = dst&src != 0
+	case asm.JSGT:
+		taken = sdst > ssrc
+	case asm.JSGE:
+		taken = sdst >= ssrc
+	case asm.JSLT:
+		taken = sdst < ssrc
+	case asm.JSLE:
+		taken = sdst <= ssrc
+	default:
+		return 0, false, xerrors.Errorf("unsupported opcode %v", op)
+	}
+
+	if taken {
+		return m.targets[pc], false, nil
+	}
+	return pc + 1, false, nil
+}
+
+func (m *Machine) load(ins *asm.Instruction) error {
+	op := ins.OpCode
+
+	switch op.Mode() {
+	case asm.ImmMode:
+		if op.Size() != asm.DWord {
+			break
+		}
+
+		switch ins.Src {
+		case asm.PseudoMapFD:
+			ptr, _, err := m.mapPtr(ins.Reference)
+			if err != nil {
+				return err
+			}
+			m.regs[ins.Dst] = ptr
+
+		case asm.PseudoMapValue:
+			_, mp, err := m.mapPtr(ins.Reference)
+			if err != nil {
+				return err
+			}
+
+			value, err := mp.Lookup(make([]byte, mp.KeySize()))
+			if err != nil {
+				return xerrors.Errorf("map %s: %w", ins.Reference, err)
+			}
+			m.regs[ins.Dst] = m.NewPointer(value) + uint64(ins.Constant)>>32
+
+		default:
+			m.regs[ins.Dst] = uint64(ins.Constant)
+		}
+		return nil
+
+	case asm.AbsMode, asm.IndMode:
+		// Legacy packet access reads from the context in R6, and
+		// converts from network byte order.
+		offset := uint64(ins.Constant)
+		if op.Mode() == asm.IndMode {
+			offset += uint64(uint32(m.regs[ins.Src]))
+		}
+
+		buf, err := m.mem.slice(m.regs[asm.R6]+uint64(uint32(offset)), int(op.Size().Sizeof()))
+		if err != nil {
+			return err
+		}
+
+		switch op.Size() {
+		case asm.Byte:
+			m.regs[asm.R0] = uint64(buf[0])
+		case asm.Half:
+			m.regs[asm.R0] = uint64(binary.BigEndian.Uint16(buf))
+		case asm.Word:
+			m.regs[asm.R0] = uint64(binary.BigEndian.Uint32(buf))
+		default:
+			return xerrors.Errorf("unsupported opcode %v", op)
+		}
+		return nil
+	}
+
+	return xerrors.Errorf("unsupported opcode %v", op)
+}
+
+func (m *Machine) read(addr uint64, size asm.Size) (uint64, error) {
+	buf, err := m.mem.slice(addr, size.Sizeof())
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case asm.Byte:
+		return uint64(buf[0]), nil
+	case asm.Half:
+		return uint64(internal.NativeEndian.Uint16(buf)), nil
+	case asm.Word:
+		return uint64(internal.NativeEndian.Uint32(buf)), nil
+	default:
+		return internal.NativeEndian.Uint64(buf), nil
+	}
+}
+
+func (m *Machine) write(addr uint64, size asm.Size, value uint64) error {
+	buf, err := m.mem.slice(addr, size.Sizeof())
+	if err != nil {
+		return err
+	}
+
+	switch size {
+	case asm.Byte:
+		buf[0] = byte(value)
+	case asm.Half:
+		internal.NativeEndian.PutUint16(buf, uint16(value))
+	case asm.Word:
+		internal.NativeEndian.PutUint32(buf, uint32(value))
+	default:
+		internal.NativeEndian.PutUint64(buf, value)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+func TestMachineLoop(t *testing.T) {
+	// Sum the numbers from 1 to 10.
+	insns := asm.Instructions{
+		asm.Mov.Imm(asm.R0, 0),
+		asm.Mov.Imm(asm.R1, 10),
+		asm.Add.Reg(asm.R0, asm.R1).Sym("loop"),
+		asm.Sub.Imm(asm.R1, 1),
+		asm.JNE.Imm(asm.R1, 0, "loop"),
+		asm.Return(),
+	}
+
+	ret := mustRun(t, insns, nil)
+	if ret != 55 {
+		t.Error("Expected 55, got", ret)
+	}
+}
+
+func TestMachineALU(t *testing.T) {
+	for name, test := range map[string]struct {
+		insns asm.Instructions
+		want  uint64
+	}{
+		"div by zero": {asm.Instructions{
+			asm.Mov.Imm(asm.R0, 10),
+			asm.Div.Imm(asm.R0, 0),
+		}, 0},
+		"mod by zero": {asm.Instructions{
+			asm.Mov.Imm(asm.R0, 10),
+			asm.Mod.Imm(asm.R0, 0),
+		}, 10},
+		"alu32 zero extends": {asm.Instructions{
+			asm.Mov.Imm(asm.R0, -1),
+			asm.Add.Imm32(asm.R0, 1),
+		}, 0},
+		"arsh": {asm.Instructions{
+			asm.Mov.Imm(asm.R0, -8),
+			asm.ArSh.Imm(asm.R0, 1),
+		}, uint64(0xfffffffffffffffc)},
+		"dword load": {asm.Instructions{
+			asm.LoadImm(asm.R0, 1<<40, asm.DWord),
+		}, 1 << 40},
+		"stack": {asm.Instructions{
+			asm.StoreImm(asm.RFP, -8, 42, asm.DWord),
+			asm.LoadMem(asm.R0, asm.RFP, -8, asm.DWord),
+		}, 42},
+		"jump32 ignores upper half": {asm.Instructions{
+			asm.LoadImm(asm.R1, 1<<32, asm.DWord),
+			asm.Mov.Imm(asm.R0, 1),
+			asm.JEq.Imm32(asm.R1, 0, "out"),
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Mov.Imm(asm.R2, 0).Sym("out"),
+		}, 1},
+		"negate": {asm.Instructions{
+			asm.Mov.Imm(asm.R0, 1),
+			asm.Negate(asm.R0),
+		}, uint64(0xffffffffffffffff)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ret := mustRun(t, append(test.insns, asm.Return()), nil)
+			if ret != test.want {
+				t.Errorf("Expected %#x, got %#x", test.want, ret)
+			}
+		})
+	}
+}
+
+func TestMachineContext(t *testing.T) {
+	insns := asm.Instructions{
+		asm.LoadMem(asm.R0, asm.R1, 4, asm.Word),
+		asm.Return(),
+	}
+
+	ctx := make([]byte, 8)
+	internal.NativeEndian.PutUint32(ctx[4:], 1234)
+
+	if ret := mustRun(t, insns, ctx); ret != 1234 {
+		t.Error("Expected 1234, got", ret)
+	}
+
+	insns = asm.Instructions{
+		asm.LoadMem(asm.R0, asm.R1, 8, asm.Word),
+		asm.Return(),
+	}
+
+	m, err := New(insns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Run(ctx); err == nil {
+		t.Error("Out of bounds access doesn't return an error")
+	}
+}
+
+func TestMachineMap(t *testing.T) {
+	// Increment the value at key 1, creating it if necessary.
+	insns := asm.Instructions{
+		asm.StoreImm(asm.RFP, -4, 1, asm.Word),
+		asm.LoadMapPtr(asm.R1, 0),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -4),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "create"),
+		asm.Mov.Imm(asm.R1, 1),
+		asm.StoreXAdd(asm.R0, asm.R1, asm.DWord),
+		asm.Return(),
+
+		asm.StoreImm(asm.RFP, -16, 1, asm.DWord).Sym("create"),
+		asm.LoadMapPtr(asm.R1, 0),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -4),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, -16),
+		asm.Mov.Imm(asm.R4, int32(ebpf.UpdateNoExist)),
+		asm.FnMapUpdateElem.Call(),
+		asm.Return(),
+	}
+	insns[1].Reference = "counters"
+	insns[10].Reference = "counters"
+
+	m, err := New(insns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counters := NewHashMap(4, 8, 1)
+	m.Maps["counters"] = counters
+
+	for i := 0; i < 3; i++ {
+		if _, err := m.Run(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key := make([]byte, 4)
+	internal.NativeEndian.PutUint32(key, 1)
+	value, err := counters.Lookup(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := internal.NativeEndian.Uint64(value); n != 3 {
+		t.Error("Expected counter to be 3, got", n)
+	}
+
+	// The map is full, so creating another key fails.
+	internal.NativeEndian.PutUint32(key, 2)
+	if err := counters.Update(key, value, ebpf.UpdateAny); !xerrors.Is(err, ErrMapFull) {
+		t.Error("Expected ErrMapFull, got", err)
+	}
+}
+
+func TestMachineHelper(t *testing.T) {
+	insns := asm.Instructions{
+		asm.Mov.Imm(asm.R1, 21),
+		asm.FnKtimeGetNs.Call(),
+		asm.Return(),
+	}
+
+	m, err := New(insns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Helpers[asm.FnKtimeGetNs] = func(_ *Machine, args [5]uint64) (uint64, error) {
+		return args[0] * 2, nil
+	}
+
+	ret, err := m.Run(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != 42 {
+		t.Error("Expected 42, got", ret)
+	}
+
+	delete(m.Helpers, asm.FnKtimeGetNs)
+	if _, err := m.Run(nil); err == nil {
+		t.Error("Calling a missing helper doesn't return an error")
+	}
+}
+
+func TestMachineCall(t *testing.T) {
+	insns := asm.Instructions{
+		asm.Mov.Imm(asm.R6, 1),
+		asm.StoreImm(asm.RFP, -8, 1, asm.DWord),
+		asm.Mov.Imm(asm.R1, 20),
+		asm.Call.Label("double"),
+		asm.Add.Reg(asm.R0, asm.R6),
+		asm.LoadMem(asm.R1, asm.RFP, -8, asm.DWord),
+		asm.Add.Reg(asm.R0, asm.R1),
+		asm.Return(),
+
+		// Clobber R6 and the caller's stack slot, which must be preserved.
+		asm.Mov.Reg(asm.R0, asm.R1).Sym("double"),
+		asm.Add.Reg(asm.R0, asm.R1),
+		asm.Mov.Imm(asm.R6, 100),
+		asm.StoreImm(asm.RFP, -8, 0, asm.DWord),
+		asm.Return(),
+	}
+
+	if ret := mustRun(t, insns, nil); ret != 42 {
+		t.Error("Expected 42, got", ret)
+	}
+
+	// Check that raw offsets work as well.
+	insns[3] = asm.Instruction{OpCode: asm.OpCode(asm.JumpClass).SetJumpOp(asm.Call), Src: asm.PseudoCall, Constant: 4}
+	if ret := mustRun(t, insns, nil); ret != 42 {
+		t.Error("Expected 42 with raw offset, got", ret)
+	}
+}
+
+func TestMachineInstructionLimit(t *testing.T) {
+	insns := asm.Instructions{
+		asm.Ja.Label("loop").Sym("loop"),
+	}
+
+	m, err := New(insns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.MaxInstructions = 100
+	if _, err := m.Run(nil); err == nil {
+		t.Error("Infinite loop doesn't return an error")
+	}
+}
+
+func mustRun(t *testing.T, insns asm.Instructions, ctx []byte) uint64 {
+	t.Helper()
+
+	m, err := New(insns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret, err := m.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ret
+}
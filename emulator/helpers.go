@@ -0,0 +1,116 @@
+package emulator
+
+import (
+	"math/rand"
+	"syscall"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// DefaultHelpers returns implementations of commonly used helpers.
+//
+// The returned map may be modified.
+func DefaultHelpers() map[asm.BuiltinFunc]Helper {
+	return map[asm.BuiltinFunc]Helper{
+		asm.FnMapLookupElem:     mapLookupElem,
+		asm.FnMapUpdateElem:     mapUpdateElem,
+		asm.FnMapDeleteElem:     mapDeleteElem,
+		asm.FnKtimeGetNs:        ktimeGetNs,
+		asm.FnGetPrandomU32:     getPrandomU32,
+		asm.FnGetSmpProcessorId: getSmpProcessorID,
+	}
+}
+
+// mapArgs returns the map in args[0] and the key in args[1].
+func mapArgs(m *Machine, args [5]uint64) (Map, []byte, error) {
+	mp, err := m.Map(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := m.Memory(args[1], mp.KeySize())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("key: %w", err)
+	}
+
+	// Maps may retain the key.
+	return mp, append([]byte(nil), key...), nil
+}
+
+func mapLookupElem(m *Machine, args [5]uint64) (uint64, error) {
+	mp, key, err := mapArgs(m, args)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := mp.Lookup(key)
+	if xerrors.Is(err, ebpf.ErrKeyNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return m.NewPointer(value), nil
+}
+
+func mapUpdateElem(m *Machine, args [5]uint64) (uint64, error) {
+	mp, key, err := mapArgs(m, args)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := m.Memory(args[2], mp.ValueSize())
+	if err != nil {
+		return 0, xerrors.Errorf("value: %w", err)
+	}
+
+	err = mp.Update(key, append([]byte(nil), value...), ebpf.MapUpdateFlags(args[3]))
+	return errnoResult(err)
+}
+
+func mapDeleteElem(m *Machine, args [5]uint64) (uint64, error) {
+	mp, key, err := mapArgs(m, args)
+	if err != nil {
+		return 0, err
+	}
+
+	return errnoResult(mp.Delete(key))
+}
+
+// errnoResult converts errors returned by a Map into the negative errno
+// which the kernel would return.
+func errnoResult(err error) (uint64, error) {
+	var errno syscall.Errno
+	switch {
+	case err == nil:
+		return 0, nil
+	case xerrors.Is(err, ebpf.ErrKeyNotExist):
+		errno = unix.ENOENT
+	case xerrors.Is(err, ebpf.ErrKeyExist):
+		errno = unix.EEXIST
+	case xerrors.Is(err, ErrMapFull):
+		errno = unix.E2BIG
+	default:
+		return 0, err
+	}
+
+	return uint64(-int64(errno)), nil
+}
+
+func ktimeGetNs(*Machine, [5]uint64) (uint64, error) {
+	return uint64(time.Now().UnixNano()), nil
+}
+
+func getPrandomU32(*Machine, [5]uint64) (uint64, error) {
+	return uint64(rand.Uint32()), nil
+}
+
+func getSmpProcessorID(*Machine, [5]uint64) (uint64, error) {
+	return 0, nil
+}
@@ -0,0 +1,160 @@
+package emulator
+
+import (
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// ErrMapFull is returned when adding a key to a map which has reached
+// its maximum number of entries.
+var ErrMapFull = xerrors.New("map is full")
+
+// Map is the backend of a map used by an emulated program.
+//
+// Keys and values are in the same format as in the kernel.
+type Map interface {
+	KeySize() int
+	ValueSize() int
+
+	// Lookup returns the value for key, or ebpf.ErrKeyNotExist.
+	//
+	// Programs modify the value in place, so the returned slice must
+	// remain valid until the key is deleted.
+	Lookup(key []byte) ([]byte, error)
+
+	// Update a value, respecting ebpf.UpdateNoExist and ebpf.UpdateExist.
+	Update(key, value []byte, flags ebpf.MapUpdateFlags) error
+
+	// Delete a key, or return ebpf.ErrKeyNotExist.
+	Delete(key []byte) error
+}
+
+// HashMap is a Map which behaves like ebpf.Hash.
+type HashMap struct {
+	keySize, valueSize, maxEntries int
+	values                         map[string][]byte
+}
+
+var _ Map = (*HashMap)(nil)
+
+// NewHashMap creates an empty HashMap.
+func NewHashMap(keySize, valueSize, maxEntries int) *HashMap {
+	return &HashMap{keySize, valueSize, maxEntries, make(map[string][]byte)}
+}
+
+// KeySize implements Map.
+func (hm *HashMap) KeySize() int { return hm.keySize }
+
+// ValueSize implements Map.
+func (hm *HashMap) ValueSize() int { return hm.valueSize }
+
+// Len returns the number of keys in the map.
+func (hm *HashMap) Len() int { return len(hm.values) }
+
+// Lookup implements Map.
+func (hm *HashMap) Lookup(key []byte) ([]byte, error) {
+	value, ok := hm.values[string(key)]
+	if !ok {
+		return nil, ebpf.ErrKeyNotExist
+	}
+	return value, nil
+}
+
+// Update implements Map.
+func (hm *HashMap) Update(key, value []byte, flags ebpf.MapUpdateFlags) error {
+	if len(key) != hm.keySize || len(value) != hm.valueSize {
+		return xerrors.New("invalid key or value size")
+	}
+
+	existing, ok := hm.values[string(key)]
+	switch {
+	case ok && flags == ebpf.UpdateNoExist:
+		return ebpf.ErrKeyExist
+	case !ok && flags == ebpf.UpdateExist:
+		return ebpf.ErrKeyNotExist
+	case ok:
+		// Update in place, since programs may hold a pointer to the value.
+		copy(existing, value)
+		return nil
+	case len(hm.values) >= hm.maxEntries:
+		return ErrMapFull
+	}
+
+	hm.values[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+// Delete implements Map.
+func (hm *HashMap) Delete(key []byte) error {
+	if _, ok := hm.values[string(key)]; !ok {
+		return ebpf.ErrKeyNotExist
+	}
+	delete(hm.values, string(key))
+	return nil
+}
+
+// ArrayMap is a Map which behaves like ebpf.Array.
+type ArrayMap struct {
+	valueSize int
+	values    []byte
+}
+
+var _ Map = (*ArrayMap)(nil)
+
+// NewArrayMap creates an ArrayMap with all values set to zero.
+func NewArrayMap(valueSize, maxEntries int) *ArrayMap {
+	return &ArrayMap{valueSize, make([]byte, valueSize*maxEntries)}
+}
+
+// KeySize implements Map.
+func (am *ArrayMap) KeySize() int { return 4 }
+
+// ValueSize implements Map.
+func (am *ArrayMap) ValueSize() int { return am.valueSize }
+
+func (am *ArrayMap) value(key []byte) ([]byte, error) {
+	if len(key) != 4 {
+		return nil, xerrors.New("invalid key size")
+	}
+
+	index := int(internal.NativeEndian.Uint32(key))
+	if index >= len(am.values)/am.valueSize {
+		return nil, ebpf.ErrKeyNotExist
+	}
+
+	start := index * am.valueSize
+	return am.values[start : start+am.valueSize : start+am.valueSize], nil
+}
+
+// Lookup implements Map.
+func (am *ArrayMap) Lookup(key []byte) ([]byte, error) {
+	return am.value(key)
+}
+
+// Update implements Map.
+func (am *ArrayMap) Update(key, value []byte, flags ebpf.MapUpdateFlags) error {
+	if flags == ebpf.UpdateNoExist {
+		return ebpf.ErrKeyExist
+	}
+
+	existing, err := am.value(key)
+	if err != nil {
+		return err
+	}
+
+	if len(value) != am.valueSize {
+		return xerrors.New("invalid value size")
+	}
+
+	copy(existing, value)
+	return nil
+}
+
+// Delete implements Map.
+//
+// Arrays don't support deleting keys.
+func (am *ArrayMap) Delete(key []byte) error {
+	return xerrors.New("can't delete from an array")
+}
@@ -0,0 +1,75 @@
+package emulator
+
+import (
+	"golang.org/x/xerrors"
+)
+
+// Pointers are made up of the index of a region in the upper 32 bits,
+// and an offset into the region in the lower 32 bits. The zero value is
+// the NULL pointer.
+const regionShift = 32
+
+// region is a contiguous piece of memory, like a stack frame or a map value.
+type region struct {
+	data []byte
+	// Set if the region represents a map, in which case data is nil.
+	m Map
+}
+
+type memory struct {
+	regions []region
+}
+
+func (mem *memory) reset() {
+	mem.regions = mem.regions[:0]
+}
+
+// add a region and return a pointer to its start.
+func (mem *memory) add(r region) uint64 {
+	mem.regions = append(mem.regions, r)
+	return uint64(len(mem.regions)) << regionShift
+}
+
+func (mem *memory) region(addr uint64) (*region, uint32, error) {
+	index := addr >> regionShift
+	if index == 0 {
+		return nil, 0, xerrors.Errorf("invalid memory access at %#x: null pointer", addr)
+	}
+	if index > uint64(len(mem.regions)) {
+		return nil, 0, xerrors.Errorf("invalid memory access at %#x: unknown pointer", addr)
+	}
+	return &mem.regions[index-1], uint32(addr), nil
+}
+
+// slice returns size bytes starting at addr.
+//
+// Writing to the slice modifies the memory.
+func (mem *memory) slice(addr uint64, size int) ([]byte, error) {
+	r, offset, err := mem.region(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.m != nil {
+		return nil, xerrors.Errorf("invalid memory access at %#x: pointer to map", addr)
+	}
+
+	if size < 0 || uint64(offset)+uint64(size) > uint64(len(r.data)) {
+		return nil, xerrors.Errorf("invalid memory access at %#x: %d bytes out of bounds", addr, size)
+	}
+
+	return r.data[offset : int(offset)+size], nil
+}
+
+func (mem *memory) mapAt(addr uint64) (Map, error) {
+	r, offset, err := mem.region(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.m == nil || offset != 0 {
+		return nil, xerrors.Errorf("%#x is not a pointer to a map", addr)
+	}
+
+	return r.m, nil
+}
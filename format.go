@@ -0,0 +1,192 @@
+package ebpf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/btf"
+
+	"golang.org/x/xerrors"
+)
+
+// FormatKey returns a fmt.Formatter which renders key using the BTF of
+// the map.
+//
+// %v renders the key on a single line, while %+v spreads structs over
+// multiple lines. Members are shown by name, enums by the name of their
+// value and arrays of char as strings. The key is shown in hex if the
+// BTF of the map isn't known.
+func (m *Map) FormatKey(key []byte) fmt.Formatter {
+	types := m.types.get()
+	if types == nil {
+		return btf.Value{Data: key}
+	}
+	return btf.Value{Type: btf.MapKey(types), Data: key}
+}
+
+// FormatValue returns a fmt.Formatter which renders value using the BTF
+// of the map.
+//
+// Values of per-CPU maps must be formatted one CPU at a time. See
+// FormatKey for details.
+func (m *Map) FormatValue(value []byte) fmt.Formatter {
+	types := m.types.get()
+	if types == nil {
+		return btf.Value{Data: value}
+	}
+	return btf.Value{Type: btf.MapValue(types), Data: value}
+}
+
+// FormatValue returns a fmt.Formatter which renders data as the C type
+// called typeName, for example an event emitted via a perf event array.
+//
+// The type is looked up in the BTF of any program or map in the
+// collection. See Map.FormatKey for details on the output.
+func (cs *CollectionSpec) FormatValue(typeName string, data []byte) (fmt.Formatter, error) {
+	typ, err := cs.findType(typeName)
+	if err != nil {
+		return nil, err
+	}
+	return btf.Value{Type: typ, Data: data}, nil
+}
+
+// findType returns a type with a definition called name.
+func (cs *CollectionSpec) findType(name string) (btf.Type, error) {
+	var specs []*btf.Spec
+	for _, prog := range cs.Programs {
+		if prog.BTF != nil {
+			specs = append(specs, btf.ProgramSpec(prog.BTF))
+		}
+	}
+	for _, m := range cs.Maps {
+		if m.BTF != nil {
+			specs = append(specs, btf.MapSpec(m.BTF))
+		}
+	}
+
+	for _, spec := range specs {
+		types, err := spec.AnyTypesByName(name)
+		if xerrors.Is(err, btf.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, typ := range types {
+			switch typ.(type) {
+			case *btf.Fwd, *btf.Func, *btf.FuncProto:
+				// Not a definition of data.
+			default:
+				return typ, nil
+			}
+		}
+	}
+
+	return nil, xerrors.Errorf("type %s: %w", name, btf.ErrNotFound)
+}
+
+// Format renders the instructions of the spec like asm.Instructions,
+// with references resolved.
+//
+// Loads of maps and map values show the name of the map instead of a
+// placeholder file descriptor, calls to subprograms show the name of the
+// function, and unresolved kernel symbols and externs are shown by name.
+// Instructions are preceded by their source line if it is known, either
+// from the instruction itself or from the line info in BTF.
+//
+// The listing is only produced for %+v and %+s, other verbs and flags
+// print the spec like a plain struct.
+func (ps *ProgramSpec) Format(f fmt.State, c rune) {
+	if (c != 's' && c != 'v') || !f.Flag('+') {
+		fmt.Fprintf(f, formatDirective(f, c), (*plainProgramSpec)(ps))
+		return
+	}
+
+	fmt.Fprintf(f, "%s (%s):\n", ps.Name, ps.Type)
+
+	var lines map[uint64]*btf.Line
+	if ps.BTF != nil {
+		// Line info is optional, and only used if the instructions
+		// don't carry their source.
+		lines, _ = btf.ProgramLines(ps.BTF)
+	}
+
+	offsetWidth := len(fmt.Sprint(ps.Instructions.Size() / asm.InstructionSize))
+	lastSource := ""
+	iter := ps.Instructions.Iterate()
+	for iter.Next() {
+		ins := iter.Ins
+		if ins.Symbol != "" {
+			fmt.Fprintf(f, "%s:\n", ins.Symbol)
+		}
+
+		var src fmt.Stringer = ins.Source()
+		if line, ok := lines[uint64(iter.Offset)]; src == nil && ok {
+			src = line
+		}
+		if src != nil {
+			line := strings.TrimSpace(src.String())
+			if line != lastSource {
+				fmt.Fprintf(f, "\t; %s\n", line)
+				lastSource = line
+			}
+		}
+
+		fmt.Fprintf(f, "\t%*d: %s\n", offsetWidth, iter.Offset, formatInstruction(*ins))
+	}
+}
+
+// plainProgramSpec has the fields of ProgramSpec, but not its Format
+// method.
+type plainProgramSpec ProgramSpec
+
+// formatDirective reconstructs the directive which invoked a Formatter.
+func formatDirective(f fmt.State, c rune) string {
+	var sb strings.Builder
+	sb.WriteByte('%')
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			sb.WriteRune(flag)
+		}
+	}
+	if width, ok := f.Width(); ok {
+		fmt.Fprint(&sb, width)
+	}
+	if prec, ok := f.Precision(); ok {
+		fmt.Fprintf(&sb, ".%d", prec)
+	}
+	sb.WriteRune(c)
+	return sb.String()
+}
+
+// formatInstruction renders an instruction, replacing placeholders with
+// the symbols they refer to.
+func formatInstruction(ins asm.Instruction) string {
+	if ins.Reference == "" {
+		return fmt.Sprint(ins)
+	}
+
+	var kind asm.RelocationKind
+	if rel := ins.Relocation(); rel != nil {
+		kind = rel.Kind
+	}
+
+	isLoadImm := ins.OpCode == asm.LoadImmOp(asm.DWord)
+	switch {
+	case ins.OpCode.JumpOp() == asm.Call && ins.Src == asm.PseudoCall:
+		return fmt.Sprintf("Call %s", ins.Reference)
+
+	case isLoadImm && ins.Src == asm.PseudoMapFD:
+		return fmt.Sprintf("LoadMapPtr dst: %s map: %s", ins.Dst, ins.Reference)
+
+	case isLoadImm && ins.Src == asm.PseudoMapValue:
+		return fmt.Sprintf("LoadMapValue dst: %s map: %s off: %d", ins.Dst, ins.Reference, uint64(ins.Constant)>>32)
+
+	case isLoadImm && (kind == asm.KsymRelocation || kind == asm.ExternRelocation):
+		return fmt.Sprintf("LoadImm dst: %s %s: %s", ins.Dst, kind, ins.Reference)
+	}
+
+	return fmt.Sprint(ins)
+}
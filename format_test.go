@@ -0,0 +1,130 @@
+package ebpf
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/btf"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestCollectionSpecFormatValue(t *testing.T) {
+	value := &btf.Struct{Name: "event", Size: 4, Members: []btf.Member{
+		{Name: "pid", Type: &btf.Int{Name: "u32", Size: 4}},
+	}}
+
+	mapBTF, err := btf.NewBuilder().Map(&btf.Int{Name: "u32", Size: 4}, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := &CollectionSpec{
+		Maps: map[string]*MapSpec{
+			"events": {BTF: mapBTF},
+		},
+	}
+
+	data := make([]byte, 4)
+	internal.NativeEndian.PutUint32(data, 1)
+
+	formatter, err := spec.FormatValue("event", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have := fmt.Sprint(formatter); have != "{pid: 1}" {
+		t.Error("Unexpected output:", have)
+	}
+
+	if _, err := spec.FormatValue("missing", nil); err == nil {
+		t.Error("FormatValue doesn't return an error for a missing type")
+	}
+}
+
+func TestMapFormatValue(t *testing.T) {
+	m := createArray(t)
+	defer m.Close()
+
+	if have := fmt.Sprint(m.FormatValue([]byte{1, 2})); have != "0102" {
+		t.Error("Value of map without BTF isn't shown in hex:", have)
+	}
+}
+
+func TestMapFormatValueFromID(t *testing.T) {
+	value := &btf.Struct{Name: "event", Size: 4, Members: []btf.Member{
+		{Name: "pid", Type: &btf.Int{Name: "u32", Size: 4}},
+	}}
+
+	mapBTF, err := btf.NewBuilder().Map(&btf.Int{Name: "u32", Size: 4}, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+		BTF:        mapBTF,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	id, err := m.ID()
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m2, err := NewMapFromID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m2.Close()
+
+	if m2.types == nil {
+		t.Skip("Map has no BTF")
+	}
+	if m2.types.types != nil {
+		t.Error("BTF is loaded before it is used")
+	}
+
+	data := make([]byte, 4)
+	internal.NativeEndian.PutUint32(data, 1)
+	if have := fmt.Sprint(m2.FormatValue(data)); have != "{pid: 1}" {
+		t.Error("Unexpected output:", have)
+	}
+}
+
+func TestProgramSpecFormat(t *testing.T) {
+	spec, err := LoadCollectionSpec("testdata/loader-clang-9.elf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := fmt.Sprintf("%+v", spec.Programs["xdp_prog"])
+
+	for _, want := range []string{
+		"xdp_prog (XDP):\n",
+		"LoadMapPtr dst: r1 map: hash_map\n",
+		"LoadMapValue dst: r2 map: .bss off: 0\n",
+		"Call FnMapLookupElem\n",
+		"Call helper_func\n",
+		"helper_func2:\n",
+		"; return helper_func(arg);\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Output doesn't contain %q", want)
+		}
+	}
+
+	prog := spec.Programs["xdp_prog"]
+	if out, want := fmt.Sprint(prog), fmt.Sprint((*plainProgramSpec)(prog)); out != want {
+		t.Errorf("Sprint doesn't print the plain struct:\n%s", out)
+	}
+}
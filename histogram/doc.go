@@ -0,0 +1,8 @@
+// Package histogram reads histograms with power of two buckets from maps.
+//
+// This is the most common way of collecting distributions such as
+// latencies in BPF, popularised by BCC. histogram.h contains the
+// matching definitions for BPF C programs.
+package histogram
+
+//go:generate go run gen_header.go
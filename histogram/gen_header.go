@@ -0,0 +1,22 @@
+// +build ignore
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+
+	"github.com/cilium/ebpf/histogram"
+)
+
+func main() {
+	var buf bytes.Buffer
+	if err := histogram.WriteHeader(&buf); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile("histogram.h", buf.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
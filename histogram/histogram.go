@@ -0,0 +1,200 @@
+package histogram
+
+import (
+	"io"
+	"math"
+	"math/bits"
+	"text/template"
+
+	"github.com/cilium/ebpf"
+
+	"golang.org/x/xerrors"
+)
+
+// MaxBuckets is the number of buckets required to count any uint64.
+const MaxBuckets = 65
+
+// Slot returns the bucket a value is counted in.
+//
+// Zero is counted in bucket 0, and any other value v in bucket
+// floor(log2(v)) + 1.
+func Slot(value uint64) int {
+	return bits.Len64(value)
+}
+
+// Bucket is a range of values and the number of values counted in it.
+type Bucket struct {
+	// Smallest value counted in the bucket.
+	Min uint64
+	// Largest value counted in the bucket.
+	Max uint64
+	// Number of values in the bucket.
+	Count uint64
+}
+
+func bucket(slot int, count uint64) Bucket {
+	switch {
+	case slot == 0:
+		return Bucket{0, 0, count}
+	case slot >= 64:
+		return Bucket{1 << 63, math.MaxUint64, count}
+	default:
+		return Bucket{1 << uint(slot-1), 1<<uint(slot) - 1, count}
+	}
+}
+
+// Histogram is a map which stores a histogram.
+//
+// The map is keyed by slot as a uint32, and each value is a uint64 counter.
+// Array, PerCPUArray, Hash and PerCPUHash maps are supported. Values of
+// per-CPU maps are summed.
+type Histogram struct {
+	m       *ebpf.Map
+	buckets int
+}
+
+// MapSpec returns the specification for a histogram map.
+func MapSpec(name string, typ ebpf.MapType) *ebpf.MapSpec {
+	return &ebpf.MapSpec{
+		Name:       name,
+		Type:       typ,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: MaxBuckets,
+	}
+}
+
+// New wraps a map containing a histogram.
+//
+// The Histogram doesn't take ownership of m.
+func New(m *ebpf.Map) (*Histogram, error) {
+	abi := m.ABI()
+	switch abi.Type {
+	case ebpf.Array, ebpf.PerCPUArray, ebpf.Hash, ebpf.PerCPUHash:
+	default:
+		return nil, xerrors.Errorf("%s: unsupported map type %s", m, abi.Type)
+	}
+
+	if abi.KeySize != 4 || abi.ValueSize != 8 {
+		return nil, xerrors.Errorf("%s: expected 4 byte keys and 8 byte values", m)
+	}
+
+	buckets := int(abi.MaxEntries)
+	if buckets > MaxBuckets {
+		buckets = MaxBuckets
+	}
+
+	return &Histogram{m, buckets}, nil
+}
+
+// Read returns all buckets of the histogram, in ascending order.
+func (h *Histogram) Read() ([]Bucket, error) {
+	counts := make([]uint64, h.buckets)
+
+	perCPU := h.m.ABI().Type == ebpf.PerCPUArray || h.m.ABI().Type == ebpf.PerCPUHash
+	var (
+		slot   uint32
+		value  uint64
+		values []uint64
+	)
+	valueOut := interface{}(&value)
+	if perCPU {
+		valueOut = &values
+	}
+
+	iter := h.m.Iterate()
+	for iter.Next(&slot, valueOut) {
+		if int(slot) >= len(counts) {
+			continue
+		}
+
+		if !perCPU {
+			counts[slot] = value
+			continue
+		}
+
+		for _, v := range values {
+			counts[slot] += v
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, xerrors.Errorf("can't read histogram: %w", err)
+	}
+
+	result := make([]Bucket, len(counts))
+	for i, count := range counts {
+		result[i] = bucket(i, count)
+	}
+	return result, nil
+}
+
+// WriteHeader writes histogram.h, which defines histogram maps and
+// a function to count values for BPF C programs.
+func WriteHeader(w io.Writer) error {
+	return headerTemplate.Execute(w, struct {
+		MaxBuckets int
+	}{MaxBuckets})
+}
+
+var headerTemplate = template.Must(template.New("histogram.h").Parse(`/* Code generated by gen_header.go. DO NOT EDIT. */
+#pragma once
+
+/* A value v is counted in bucket 0 if it is zero, and in bucket
+ * floor(log2(v)) + 1 otherwise.
+ */
+#define HISTOGRAM_MAX_BUCKETS ({{ .MaxBuckets }})
+
+#define HISTOGRAM_HASH (1)
+#define HISTOGRAM_ARRAY (2)
+#define HISTOGRAM_PERCPU_HASH (5)
+#define HISTOGRAM_PERCPU_ARRAY (6)
+
+/* Declares a histogram map, where map_type is one of the
+ * HISTOGRAM_* map types.
+ */
+#define HISTOGRAM(name, map_type) \
+	struct { \
+		int (*type)[map_type]; \
+		unsigned int *key; \
+		unsigned long long *value; \
+		int (*max_entries)[HISTOGRAM_MAX_BUCKETS]; \
+	} name __attribute__((section(".maps"), used))
+
+static void *(*histogram_map_lookup_elem)(const void *map, const void *key) = (void *)1;
+static long (*histogram_map_update_elem)(const void *map, const void *key, const void *value, unsigned long long flags) = (void *)2;
+
+static inline __attribute__((always_inline)) unsigned int histogram_slot(unsigned long long v)
+{
+	unsigned int slot = 0;
+
+	if (v >> 32) { v >>= 32; slot += 32; }
+	if (v >> 16) { v >>= 16; slot += 16; }
+	if (v >> 8) { v >>= 8; slot += 8; }
+	if (v >> 4) { v >>= 4; slot += 4; }
+	if (v >> 2) { v >>= 2; slot += 2; }
+	if (v >> 1) { v >>= 1; slot += 1; }
+
+	return slot + (unsigned int)v;
+}
+
+/* Counts a value in a map declared with HISTOGRAM. */
+static inline __attribute__((always_inline)) void histogram_observe(void *map, unsigned long long value)
+{
+	unsigned int slot = histogram_slot(value);
+	unsigned long long *count = histogram_map_lookup_elem(map, &slot);
+	if (count) {
+		__sync_fetch_and_add(count, 1);
+		return;
+	}
+
+	/* Hash maps don't contain the slot yet. BPF_NOEXIST avoids
+	 * overwriting a concurrent update.
+	 */
+	unsigned long long one = 1;
+	if (histogram_map_update_elem(map, &slot, &one, 1) != 0) {
+		count = histogram_map_lookup_elem(map, &slot);
+		if (count)
+			__sync_fetch_and_add(count, 1);
+	}
+}
+`))
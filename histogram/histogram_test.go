@@ -0,0 +1,83 @@
+package histogram
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+func TestSlot(t *testing.T) {
+	for _, value := range []uint64{0, 1, 2, 3, 4, 1000, 1 << 63, ^uint64(0)} {
+		b := bucket(Slot(value), 0)
+		if value < b.Min || value > b.Max {
+			t.Errorf("Value %d is outside of bucket %d [%d, %d]", value, Slot(value), b.Min, b.Max)
+		}
+	}
+
+	if Slot(^uint64(0)) != MaxBuckets-1 {
+		t.Error("Largest value isn't in the last bucket")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	for _, typ := range []ebpf.MapType{ebpf.Array, ebpf.Hash, ebpf.PerCPUArray} {
+		t.Run(typ.String(), func(t *testing.T) {
+			m, err := ebpf.NewMap(MapSpec("", typ))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			var value interface{} = uint64(3)
+			if typ == ebpf.PerCPUArray {
+				value = []uint64{3}
+			}
+
+			if err := m.Put(uint32(Slot(100)), value); err != nil {
+				t.Fatal(err)
+			}
+
+			h, err := New(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buckets, err := h.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(buckets) != MaxBuckets {
+				t.Fatalf("Expected %d buckets, got %d", MaxBuckets, len(buckets))
+			}
+
+			for i, b := range buckets {
+				if i == Slot(100) {
+					if b.Count != 3 || b.Min != 64 || b.Max != 127 {
+						t.Errorf("Unexpected bucket %d: %+v", i, b)
+					}
+				} else if b.Count != 0 {
+					t.Errorf("Bucket %d isn't empty: %+v", i, b)
+				}
+			}
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHeader(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := ioutil.ReadFile("histogram.h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(header, buf.Bytes()) {
+		t.Error("histogram.h is out of date, run go generate")
+	}
+}
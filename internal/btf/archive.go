@@ -0,0 +1,118 @@
+package btf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/cilium/ebpf/asm"
+
+	"golang.org/x/xerrors"
+)
+
+// MarshalSpec returns the raw BTF of a Spec, without function and line
+// infos.
+//
+// This is a free function instead of a method to hide it from users
+// of package ebpf.
+func MarshalSpec(s *Spec, bo binary.ByteOrder) ([]byte, error) {
+	return s.marshal(bo)
+}
+
+// LoadRawSpec reads BTF which isn't contained in an ELF, for example the
+// output of MarshalSpec.
+func LoadRawSpec(btf []byte, bo binary.ByteOrder) (*Spec, error) {
+	return loadRawSpec(bytes.NewReader(btf), bo)
+}
+
+// MarshalProgram encodes the function and line infos of a Program, but
+// not its Spec.
+//
+// This is a free function instead of a method to hide it from users
+// of package ebpf.
+func MarshalProgram(s *Program, bo binary.ByteOrder) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, bo, s.length); err != nil {
+		return nil, err
+	}
+
+	for _, ei := range []extInfo{s.funcInfos, s.lineInfos} {
+		header := [2]uint32{ei.recordSize, uint32(len(ei.records))}
+		if err := binary.Write(&buf, bo, header); err != nil {
+			return nil, err
+		}
+
+		for _, record := range ei.records {
+			if len(record.Opaque) != int(ei.recordSize)-4 {
+				return nil, xerrors.Errorf("ext_info record at offset %d has the wrong size", record.InsnOff)
+			}
+
+			if err := binary.Write(&buf, bo, record.InsnOff); err != nil {
+				return nil, err
+			}
+			buf.Write(record.Opaque)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalProgram decodes the output of MarshalProgram. It is safe to
+// use with untrusted input.
+func UnmarshalProgram(spec *Spec, data []byte, bo binary.ByteOrder) (*Program, error) {
+	rd := bytes.NewReader(data)
+
+	prog := &Program{spec: spec}
+	if err := binary.Read(rd, bo, &prog.length); err != nil {
+		return nil, xerrors.Errorf("can't read length: %w", err)
+	}
+
+	for _, ei := range []*extInfo{&prog.funcInfos, &prog.lineInfos} {
+		var header [2]uint32
+		if err := binary.Read(rd, bo, &header); err != nil {
+			return nil, xerrors.Errorf("can't read ext_info header: %w", err)
+		}
+
+		recordSize, count := header[0], header[1]
+		if count == 0 {
+			ei.recordSize = recordSize
+			continue
+		}
+
+		if recordSize < 4 || recordSize > maxExtInfoRecordSize {
+			return nil, xerrors.Errorf("invalid record size %d", recordSize)
+		}
+
+		// Each record is preceded by a 64 bit offset instead of a 32 bit
+		// one.
+		if uint64(count)*uint64(recordSize+4) > uint64(rd.Len()) {
+			return nil, xerrors.Errorf("%d records exceed the size of the input", count)
+		}
+
+		ei.recordSize = recordSize
+		ei.records = make([]extInfoRecord, 0, count)
+		for i := uint32(0); i < count; i++ {
+			var record extInfoRecord
+			if err := binary.Read(rd, bo, &record.InsnOff); err != nil {
+				return nil, xerrors.Errorf("can't read record: %w", err)
+			}
+
+			if record.InsnOff%asm.InstructionSize != 0 || record.InsnOff >= prog.length {
+				return nil, xerrors.Errorf("invalid instruction offset %d", record.InsnOff)
+			}
+
+			record.Opaque = make([]byte, recordSize-4)
+			if _, err := io.ReadFull(rd, record.Opaque); err != nil {
+				return nil, xerrors.Errorf("can't read record: %w", err)
+			}
+
+			ei.records = append(ei.records, record)
+		}
+	}
+
+	if rd.Len() != 0 {
+		return nil, xerrors.Errorf("%d trailing bytes", rd.Len())
+	}
+
+	return prog, nil
+}
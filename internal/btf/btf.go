@@ -7,29 +7,39 @@ import (
 	"io"
 	"io/ioutil"
 	"math"
+	"os"
 	"reflect"
+	"sync"
 	"unsafe"
 
 	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/sys"
 
 	"golang.org/x/xerrors"
 )
 
 const btfMagic = 0xeB9F
 
+// maxStringTableSize is the same as BTF_MAX_NAME_OFFSET in the kernel.
+const maxStringTableSize = 0xffffff
+
 // Errors returned by BTF functions.
 var (
-	ErrNotSupported = internal.ErrNotSupported
+	ErrNotSupported    = internal.ErrNotSupported
+	ErrNotFound        = xerrors.New("not found")
+	ErrMultipleMatches = xerrors.New("multiple matching types")
 )
 
 // Spec represents decoded BTF.
 type Spec struct {
-	rawTypes  []rawType
-	strings   stringTable
-	types     map[string][]Type
-	funcInfos map[string]extInfo
-	lineInfos map[string]extInfo
+	rawTypes []rawType
+	strings  stringTable
+	// All types, indexed by their ID.
+	types      []Type
+	namedTypes map[string][]Type
+	funcInfos  map[string]extInfo
+	lineInfos  map[string]extInfo
 }
 
 type btfHeader struct {
@@ -46,9 +56,10 @@ type btfHeader struct {
 
 // LoadSpecFromReader reads BTF sections from an ELF.
 //
-// Returns a nil Spec and no error if no BTF was present.
+// Returns a nil Spec and no error if no BTF was present. It is safe to
+// use with untrusted input, see ebpf.LoadCollectionSpecFromReader.
 func LoadSpecFromReader(rd io.ReaderAt) (*Spec, error) {
-	file, err := elf.NewFile(rd)
+	file, err := internal.NewSafeELFFile(rd)
 	if err != nil {
 		return nil, err
 	}
@@ -95,6 +106,10 @@ func LoadSpecFromReader(rd io.ReaderAt) (*Spec, error) {
 			continue
 		}
 
+		if int(symbol.Section) >= len(file.Sections) {
+			return nil, xerrors.Errorf("symbol %s: invalid section %d", symbol.Name, symbol.Section)
+		}
+
 		secName := file.Sections[symbol.Section].Name
 		if _, ok := sectionSizes[secName]; !ok {
 			continue
@@ -107,7 +122,12 @@ func LoadSpecFromReader(rd io.ReaderAt) (*Spec, error) {
 		variableOffsets[variable{secName, symbol.Name}] = uint32(symbol.Value)
 	}
 
-	rawTypes, rawStrings, err := parseBTF(btfSection.Open(), file.ByteOrder)
+	btfReader, err := internal.OpenSection(btfSection)
+	if err != nil {
+		return nil, err
+	}
+
+	rawTypes, rawStrings, err := parseBTF(btfReader, file.ByteOrder)
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +137,7 @@ func LoadSpecFromReader(rd io.ReaderAt) (*Spec, error) {
 		return nil, err
 	}
 
-	types, err := inflateRawTypes(rawTypes, rawStrings)
+	types, namedTypes, err := inflateRawTypes(rawTypes, rawStrings)
 	if err != nil {
 		return nil, err
 	}
@@ -127,27 +147,148 @@ func LoadSpecFromReader(rd io.ReaderAt) (*Spec, error) {
 		lineInfos = make(map[string]extInfo)
 	)
 	if btfExtSection != nil {
-		funcInfos, lineInfos, err = parseExtInfos(btfExtSection.Open(), file.ByteOrder, rawStrings)
+		extReader, err := internal.OpenSection(btfExtSection)
+		if err != nil {
+			return nil, err
+		}
+
+		funcInfos, lineInfos, err = parseExtInfos(extReader, file.ByteOrder, rawStrings)
 		if err != nil {
 			return nil, xerrors.Errorf("can't read ext info: %w", err)
 		}
 	}
 
 	return &Spec{
-		rawTypes:  rawTypes,
-		types:     types,
-		strings:   rawStrings,
-		funcInfos: funcInfos,
-		lineInfos: lineInfos,
+		rawTypes:   rawTypes,
+		types:      types,
+		namedTypes: namedTypes,
+		strings:    rawStrings,
+		funcInfos:  funcInfos,
+		lineInfos:  lineInfos,
+	}, nil
+}
+
+var kernelBTF struct {
+	sync.Mutex
+	*Spec
+}
+
+// LoadKernelSpec returns the current kernel's BTF information.
+//
+// The returned Spec is shared between callers and must not be modified.
+//
+// Requires a >= 5.5 kernel with CONFIG_DEBUG_INFO_BTF enabled. Returns
+// ErrNotSupported if BTF is not enabled.
+func LoadKernelSpec() (*Spec, error) {
+	kernelBTF.Lock()
+	defer kernelBTF.Unlock()
+
+	if kernelBTF.Spec != nil {
+		return kernelBTF.Spec, nil
+	}
+
+	var err error
+	kernelBTF.Spec, err = loadKernelSpec()
+	return kernelBTF.Spec, err
+}
+
+func loadKernelSpec() (*Spec, error) {
+	fh, err := os.Open("/sys/kernel/btf/vmlinux")
+	if os.IsNotExist(err) {
+		return nil, xerrors.Errorf("can't open kernel BTF at /sys/kernel/btf/vmlinux: %w", ErrNotSupported)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("can't read kernel BTF: %s", err)
+	}
+	defer fh.Close()
+
+	return loadRawSpec(fh, internal.NativeEndian)
+}
+
+// loadRawSpec reads BTF which isn't contained in an ELF.
+func loadRawSpec(btf io.ReadSeeker, bo binary.ByteOrder) (*Spec, error) {
+	rawTypes, rawStrings, err := parseBTF(btf, bo)
+	if err != nil {
+		return nil, err
+	}
+
+	types, namedTypes, err := inflateRawTypes(rawTypes, rawStrings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Spec{
+		rawTypes:   rawTypes,
+		types:      types,
+		namedTypes: namedTypes,
+		strings:    rawStrings,
 	}, nil
 }
 
+// LoadSpecFromID reads BTF which has been loaded into the kernel, for
+// example by the creator of a map.
+//
+// BTF of the kernel and of kernel modules is not supported, use
+// LoadKernelSpec instead.
+func LoadSpecFromID(id uint32) (*Spec, error) {
+	fd, err := sys.BTFGetFDByID(&sys.GetIDAttr{StartID: id})
+	if err != nil {
+		return nil, xerrors.Errorf("BTF %d: %w", id, err)
+	}
+	defer fd.Close()
+
+	var info sys.BTFInfo
+	if err := btfInfoByFD(fd, &info); err != nil {
+		return nil, xerrors.Errorf("BTF %d: %w", id, err)
+	}
+
+	if info.KernelBTF != 0 {
+		return nil, xerrors.Errorf("BTF %d is kernel BTF: %w", id, ErrNotSupported)
+	}
+
+	btf := make([]byte, info.BTFSize)
+	info = sys.BTFInfo{
+		BTF:     sys.NewSlicePointer(btf),
+		BTFSize: uint32(len(btf)),
+	}
+	if err := btfInfoByFD(fd, &info); err != nil {
+		return nil, xerrors.Errorf("BTF %d: %w", id, err)
+	}
+
+	spec, err := loadRawSpec(bytes.NewReader(btf[:info.BTFSize]), internal.NativeEndian)
+	if err != nil {
+		return nil, xerrors.Errorf("BTF %d: %w", id, err)
+	}
+	return spec, nil
+}
+
 func parseBTF(btf io.ReadSeeker, bo binary.ByteOrder) ([]rawType, stringTable, error) {
 	rawBTF, err := ioutil.ReadAll(btf)
 	if err != nil {
 		return nil, nil, xerrors.Errorf("can't read BTF: %v", err)
 	}
 
+	types, strings, err := btfSections(rawBTF, bo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawStrings, err := readStringTable(bytes.NewReader(strings))
+	if err != nil {
+		return nil, nil, xerrors.Errorf("can't read type names: %w", err)
+	}
+
+	rawTypes, err := readTypes(bytes.NewReader(types), bo)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("can't read types: %w", err)
+	}
+
+	return rawTypes, rawStrings, nil
+}
+
+// btfSections validates the header of raw BTF and returns its type and
+// string sections.
+func btfSections(rawBTF []byte, bo binary.ByteOrder) (types, strings []byte, err error) {
 	rd := bytes.NewReader(rawBTF)
 
 	var header btfHeader
@@ -176,25 +317,32 @@ func parseBTF(btf io.ReadSeeker, bo binary.ByteOrder) ([]rawType, stringTable, e
 		return nil, nil, xerrors.Errorf("header padding: %v", err)
 	}
 
-	if _, err := rd.Seek(int64(header.HdrLen+header.StringOff), io.SeekStart); err != nil {
-		return nil, nil, xerrors.Errorf("can't seek to start of string section: %v", err)
+	if header.StringLen > maxStringTableSize {
+		return nil, nil, xerrors.Errorf("string section exceeds maximum size of %d bytes", maxStringTableSize)
 	}
 
-	rawStrings, err := readStringTable(io.LimitReader(rd, int64(header.StringLen)))
+	strings, err = subsection(rawBTF, header.HdrLen, header.StringOff, header.StringLen)
 	if err != nil {
-		return nil, nil, xerrors.Errorf("can't read type names: %w", err)
-	}
-
-	if _, err := rd.Seek(int64(header.HdrLen+header.TypeOff), io.SeekStart); err != nil {
-		return nil, nil, xerrors.Errorf("can't seek to start of type section: %v", err)
+		return nil, nil, xerrors.Errorf("string section: %w", err)
 	}
 
-	rawTypes, err := readTypes(io.LimitReader(rd, int64(header.TypeLen)), bo)
+	types, err = subsection(rawBTF, header.HdrLen, header.TypeOff, header.TypeLen)
 	if err != nil {
-		return nil, nil, xerrors.Errorf("can't read types: %w", err)
+		return nil, nil, xerrors.Errorf("type section: %w", err)
 	}
 
-	return rawTypes, rawStrings, nil
+	return types, strings, nil
+}
+
+// subsection returns length bytes at offset, relative to the end of the
+// header of BTF or BTF.ext.
+func subsection(raw []byte, hdrLen, offset, length uint32) ([]byte, error) {
+	start := uint64(hdrLen) + uint64(offset)
+	end := start + uint64(length)
+	if end > uint64(len(raw)) {
+		return nil, xerrors.Errorf("offset %d and length %d exceed size of %d bytes", offset, length, len(raw))
+	}
+	return raw[start:end], nil
 }
 
 type variable struct {
@@ -364,8 +512,6 @@ func (s *Spec) Datasec(name string) (*Map, error) {
 	return &Map{s, &Void{}, &datasec}, nil
 }
 
-var errNotFound = xerrors.New("not found")
-
 // FindType searches for a type with a specific name.
 //
 // hint determines the type of the returned Type.
@@ -377,7 +523,7 @@ func (s *Spec) FindType(name string, typ Type) error {
 		candidate Type
 	)
 
-	for _, typ := range s.types[name] {
+	for _, typ := range s.namedTypes[name] {
 		if reflect.TypeOf(typ) != wanted {
 			continue
 		}
@@ -390,7 +536,7 @@ func (s *Spec) FindType(name string, typ Type) error {
 	}
 
 	if candidate == nil {
-		return xerrors.Errorf("type %s: %w", name, errNotFound)
+		return xerrors.Errorf("type %s: %w", name, ErrNotFound)
 	}
 
 	value := reflect.Indirect(reflect.ValueOf(copyType(candidate)))
@@ -398,6 +544,132 @@ func (s *Spec) FindType(name string, typ Type) error {
 	return nil
 }
 
+// TypeByID returns the type with the given ID.
+//
+// The returned type is shared with the Spec and must not be modified.
+func (s *Spec) TypeByID(id TypeID) (Type, error) {
+	if int(id) >= len(s.types) {
+		return nil, xerrors.Errorf("type ID %d: %w", id, ErrNotFound)
+	}
+	return s.types[id], nil
+}
+
+// AnyTypesByName returns all types with the given name.
+//
+// BTF doesn't track compilation units, so types from different source
+// files may share a name. A struct and its forward declaration also have
+// the same name.
+//
+// The returned types are shared with the Spec and must not be modified.
+func (s *Spec) AnyTypesByName(name string) ([]Type, error) {
+	types := s.namedTypes[name]
+	if len(types) == 0 {
+		return nil, xerrors.Errorf("type %s: %w", name, ErrNotFound)
+	}
+
+	result := make([]Type, len(types))
+	copy(result, types)
+	return result, nil
+}
+
+var typeInterface = reflect.TypeOf((*Type)(nil)).Elem()
+
+// TypeByName searches for a type with a specific name.
+//
+// typ must be a non-nil pointer to a variable holding a Type, for example
+// a **Struct, which determines the kind of the returned type. A *Type
+// matches any kind.
+//
+// Returns an error wrapping ErrMultipleMatches if more than one type of
+// the requested kind has the name. The returned type is shared with the
+// Spec and must not be modified.
+func (s *Spec) TypeByName(name string, typ interface{}) error {
+	typValue := reflect.ValueOf(typ)
+	if typValue.Kind() != reflect.Ptr || typValue.IsNil() {
+		return xerrors.Errorf("%T is not a non-nil pointer", typ)
+	}
+
+	wanted := typValue.Elem().Type()
+	if !wanted.Implements(typeInterface) {
+		return xerrors.Errorf("%T does not point to a Type", typ)
+	}
+
+	var candidate Type
+	for _, t := range s.namedTypes[name] {
+		if !reflect.TypeOf(t).AssignableTo(wanted) {
+			continue
+		}
+
+		if candidate != nil {
+			return xerrors.Errorf("type %s: %w", name, ErrMultipleMatches)
+		}
+
+		candidate = t
+	}
+
+	if candidate == nil {
+		return xerrors.Errorf("type %s: %w", name, ErrNotFound)
+	}
+
+	typValue.Elem().Set(reflect.ValueOf(candidate))
+	return nil
+}
+
+// TypesOf appends all types of a kind to a slice, ordered by ID.
+// Void is omitted.
+//
+// types must be a pointer to a slice of Types, for example a *[]*Struct,
+// which determines the kind of the returned types.
+//
+// The returned types are shared with the Spec and must not be modified.
+func (s *Spec) TypesOf(types interface{}) error {
+	sliceValue := reflect.ValueOf(types)
+	if sliceValue.Kind() != reflect.Ptr || sliceValue.IsNil() || sliceValue.Elem().Kind() != reflect.Slice {
+		return xerrors.Errorf("%T is not a non-nil pointer to a slice", types)
+	}
+
+	slice := sliceValue.Elem()
+	wanted := slice.Type().Elem()
+	if !wanted.Implements(typeInterface) {
+		return xerrors.Errorf("%T is not a slice of Types", types)
+	}
+
+	for _, t := range s.types[1:] {
+		if reflect.TypeOf(t).AssignableTo(wanted) {
+			slice = reflect.Append(slice, reflect.ValueOf(t))
+		}
+	}
+
+	sliceValue.Elem().Set(slice)
+	return nil
+}
+
+// Iterate returns an iterator over all types in the Spec, ordered by ID.
+//
+// Void, which has ID zero, is omitted.
+func (s *Spec) Iterate() *TypesIterator {
+	return &TypesIterator{types: s.types[1:]}
+}
+
+// TypesIterator iterates over the types of a Spec.
+type TypesIterator struct {
+	types []Type
+	// The current type. It is shared with the Spec and must
+	// not be modified.
+	Type Type
+}
+
+// Next returns true as long as there are any types remaining.
+func (iter *TypesIterator) Next() bool {
+	if len(iter.types) == 0 {
+		return false
+	}
+
+	iter.Type = iter.types[0]
+	iter.types = iter.types[1:]
+	return true
+}
+
 // Handle is a reference to BTF loaded into the kernel.
 type Handle struct {
 	fd *internal.FD
@@ -405,13 +677,20 @@ type Handle struct {
 
 // NewHandle loads BTF into the kernel.
 //
+// Types which the kernel doesn't understand, like floats on kernels older
+// than 5.13, are replaced with ones that it does.
+//
 // Returns ErrNotSupported if BTF is not supported.
 func NewHandle(spec *Spec) (*Handle, error) {
 	if err := haveBTF(); err != nil {
 		return nil, err
 	}
 
-	btf, err := spec.marshal(internal.NativeEndian)
+	// Downgrade types which the kernel doesn't understand.
+	sanitized := *spec
+	sanitized.rawTypes = sanitizeTypes(spec.rawTypes, probeKernelFeatures())
+
+	btf, err := sanitized.marshal(internal.NativeEndian)
 	if err != nil {
 		return nil, xerrors.Errorf("can't marshal BTF: %w", err)
 	}
@@ -420,18 +699,18 @@ func NewHandle(spec *Spec) (*Handle, error) {
 		return nil, xerrors.New("BTF exceeds the maximum size")
 	}
 
-	attr := &bpfLoadBTFAttr{
-		btf:     internal.NewSlicePointer(btf),
-		btfSize: uint32(len(btf)),
+	attr := &sys.BTFLoadAttr{
+		BTF:     sys.NewSlicePointer(btf),
+		BTFSize: uint32(len(btf)),
 	}
 
-	fd, err := bpfLoadBTF(attr)
+	fd, err := sys.BTFLoad(attr)
 	if err != nil {
 		logBuf := make([]byte, 64*1024)
-		attr.logBuf = internal.NewSlicePointer(logBuf)
-		attr.btfLogSize = uint32(len(logBuf))
-		attr.btfLogLevel = 1
-		_, logErr := bpfLoadBTF(attr)
+		attr.BTFLogBuf = sys.NewSlicePointer(logBuf)
+		attr.BTFLogSize = uint32(len(logBuf))
+		attr.BTFLogLevel = 1
+		_, logErr := sys.BTFLoad(attr)
 		return nil, internal.ErrorWithLog(err, logBuf, logErr)
 	}
 
@@ -479,6 +758,39 @@ func MapValue(m *Map) Type {
 	return m.value
 }
 
+// MapFromTypeIDs returns the BTF for a map with key and value types
+// from spec.
+func MapFromTypeIDs(spec *Spec, key, value TypeID) (*Map, error) {
+	keyType, err := spec.TypeByID(key)
+	if err != nil {
+		return nil, xerrors.Errorf("key: %w", err)
+	}
+
+	valueType, err := spec.TypeByID(value)
+	if err != nil {
+		return nil, xerrors.Errorf("value: %w", err)
+	}
+
+	return &Map{spec, keyType, valueType}, nil
+}
+
+// LocalStorageMap returns the BTF for a local storage map, which has an
+// int key and a value of valueSize bytes without further structure.
+//
+// The kernel requires BTF for these maps.
+func LocalStorageMap(valueSize uint32) (*Map, error) {
+	key := &Int{Name: "int", Size: 4, Encoding: Signed}
+	value := &Typedef{
+		Name: "local_storage_value",
+		Type: &Array{
+			Type:   &Int{Name: "char", Size: 1},
+			Nelems: valueSize,
+		},
+	}
+
+	return NewBuilder().Map(key, value)
+}
+
 // Program is the BTF information for a stream of instructions.
 type Program struct {
 	spec                 *Spec
@@ -515,49 +827,45 @@ func ProgramAppend(s, other *Program) error {
 	return nil
 }
 
-// ProgramFuncInfos returns the binary form of BTF function infos.
+// ProgramSlice returns the information for the instructions between start
+// and end, which are offsets in bytes.
 //
 // This is a free function instead of a method to hide it from users
 // of package ebpf.
-func ProgramFuncInfos(s *Program) (recordSize uint32, bytes []byte, err error) {
-	bytes, err = s.funcInfos.MarshalBinary()
-	if err != nil {
-		return 0, nil, err
+func ProgramSlice(s *Program, start, end uint64) *Program {
+	return &Program{
+		s.spec,
+		end - start,
+		s.funcInfos.slice(start, end),
+		s.lineInfos.slice(start, end),
 	}
-
-	return s.funcInfos.recordSize, bytes, nil
 }
 
-// ProgramLineInfos returns the binary form of BTF line infos.
+// ProgramFuncInfos returns the binary form of BTF function infos.
 //
 // This is a free function instead of a method to hide it from users
 // of package ebpf.
-func ProgramLineInfos(s *Program) (recordSize uint32, bytes []byte, err error) {
-	bytes, err = s.lineInfos.MarshalBinary()
+func ProgramFuncInfos(s *Program) (recordSize uint32, bytes []byte, err error) {
+	bytes, err = s.funcInfos.MarshalBinary()
 	if err != nil {
 		return 0, nil, err
 	}
 
-	return s.lineInfos.recordSize, bytes, nil
-}
-
-type bpfLoadBTFAttr struct {
-	btf         internal.Pointer
-	logBuf      internal.Pointer
-	btfSize     uint32
-	btfLogSize  uint32
-	btfLogLevel uint32
+	return s.funcInfos.recordSize, bytes, nil
 }
 
-func bpfLoadBTF(attr *bpfLoadBTFAttr) (*internal.FD, error) {
-	const _BTFLoad = 18
-
-	fd, err := internal.BPF(_BTFLoad, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+// btfInfoByFD retrieves information about the BTF behind fd.
+func btfInfoByFD(fd *sys.FD, info *sys.BTFInfo) error {
+	value, err := fd.Value()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return internal.NewFD(uint32(fd)), nil
+	return sys.ObjGetInfoByFD(&sys.ObjGetInfoByFDAttr{
+		BPFFD:   value,
+		InfoLen: uint32(unsafe.Sizeof(*info)),
+		Info:    sys.NewPointer(unsafe.Pointer(info)),
+	})
 }
 
 func minimalBTF(bo binary.ByteOrder) []byte {
@@ -598,11 +906,16 @@ func minimalBTF(bo binary.ByteOrder) []byte {
 	return buf.Bytes()
 }
 
+// HaveBTF returns nil if the kernel supports loading BTF.
+func HaveBTF() error {
+	return haveBTF()
+}
+
 var haveBTF = internal.FeatureTest("BTF", "5.1", func() bool {
 	btf := minimalBTF(internal.NativeEndian)
-	fd, err := bpfLoadBTF(&bpfLoadBTFAttr{
-		btf:     internal.NewSlicePointer(btf),
-		btfSize: uint32(len(btf)),
+	fd, err := sys.BTFLoad(&sys.BTFLoadAttr{
+		BTF:     sys.NewSlicePointer(btf),
+		BTFSize: uint32(len(btf)),
 	})
 	if err == nil {
 		fd.Close()
@@ -10,28 +10,117 @@ import (
 	"testing"
 
 	"github.com/cilium/ebpf/internal/testutils"
+
+	"golang.org/x/xerrors"
 )
 
 func TestParseVmlinux(t *testing.T) {
+	_ = parseVmlinux(t)
+}
+
+func parseVmlinux(tb testing.TB) *Spec {
+	tb.Helper()
+
 	fh, err := os.Open("testdata/vmlinux-btf.gz")
 	if err != nil {
-		t.Fatal(err)
+		tb.Fatal(err)
 	}
 	defer fh.Close()
 
 	rd, err := gzip.NewReader(fh)
 	if err != nil {
-		t.Fatal(err)
+		tb.Fatal(err)
 	}
 
 	buf, err := ioutil.ReadAll(rd)
 	if err != nil {
-		t.Fatal(err)
+		tb.Fatal(err)
 	}
 
-	_, _, err = parseBTF(bytes.NewReader(buf), binary.LittleEndian)
+	rawTypes, rawStrings, err := parseBTF(bytes.NewReader(buf), binary.LittleEndian)
 	if err != nil {
-		t.Fatal("Can't load BTF:", err)
+		tb.Fatal("Can't load BTF:", err)
+	}
+
+	types, namedTypes, err := inflateRawTypes(rawTypes, rawStrings)
+	if err != nil {
+		tb.Fatal("Can't inflate BTF:", err)
+	}
+
+	return &Spec{
+		rawTypes:   rawTypes,
+		types:      types,
+		namedTypes: namedTypes,
+		strings:    rawStrings,
+	}
+}
+
+func TestSpecTypeQueries(t *testing.T) {
+	spec := parseVmlinux(t)
+
+	var skb *Struct
+	if err := spec.TypeByName("__sk_buff", &skb); err != nil {
+		t.Fatal("Can't find struct __sk_buff:", err)
+	}
+
+	if typ, err := spec.TypeByID(skb.ID()); err != nil {
+		t.Error("Can't get type by ID:", err)
+	} else if typ != skb {
+		t.Error("TypeByID returns a different type")
+	}
+
+	if _, err := spec.TypeByID(TypeID(len(spec.types))); !xerrors.Is(err, ErrNotFound) {
+		t.Error("Expected ErrNotFound for invalid ID, got", err)
+	}
+
+	var typ Type
+	if err := spec.TypeByName("__sk_buff", &typ); err != nil {
+		t.Error("Can't find type of any kind:", err)
+	} else if typ != skb {
+		t.Error("TypeByName returns a different type for *Type")
+	}
+
+	var typedef *Typedef
+	if err := spec.TypeByName("__sk_buff", &typedef); !xerrors.Is(err, ErrNotFound) {
+		t.Error("Expected ErrNotFound for wrong kind, got", err)
+	}
+	if err := spec.TypeByName("__sk_buff", skb); err == nil {
+		t.Error("TypeByName accepts a pointer to a struct")
+	}
+
+	// There are multiple definitions of struct sk_buff.
+	candidates, err := spec.AnyTypesByName("sk_buff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) < 2 {
+		t.Fatal("Expected multiple candidates for sk_buff, got", len(candidates))
+	}
+	if err := spec.TypeByName("sk_buff", &skb); !xerrors.Is(err, ErrMultipleMatches) {
+		t.Error("Expected ErrMultipleMatches, got", err)
+	}
+
+	var structs []*Struct
+	if err := spec.TypesOf(&structs); err != nil {
+		t.Fatal(err)
+	}
+	if len(structs) == 0 {
+		t.Fatal("No structs returned")
+	}
+	for i := 1; i < len(structs); i++ {
+		if structs[i-1].ID() >= structs[i].ID() {
+			t.Fatal("Structs aren't ordered by ID")
+		}
+	}
+
+	var n int
+	for iter := spec.Iterate(); iter.Next(); n++ {
+		if iter.Type.ID() != TypeID(n+1) {
+			t.Fatalf("Type %d has ID %d", n+1, iter.Type.ID())
+		}
+	}
+	if n != len(spec.types)-1 {
+		t.Errorf("Iterated over %d types, expected %d", n, len(spec.types)-1)
 	}
 }
 
@@ -52,6 +141,35 @@ func TestParseCurrentKernelBTF(t *testing.T) {
 	}
 }
 
+func TestLoadKernelSpec(t *testing.T) {
+	if _, err := os.Stat("/sys/kernel/btf/vmlinux"); os.IsNotExist(err) {
+		t.Skip("/sys/kernel/btf/vmlinux is not available")
+	}
+
+	spec, err := LoadKernelSpec()
+	if err != nil {
+		t.Fatal("Can't load kernel spec:", err)
+	}
+
+	var typedef Typedef
+	if err := spec.FindType("btf_trace_sched_switch", &typedef); err != nil {
+		t.Fatal("Can't find btf_trace_sched_switch:", err)
+	}
+
+	if typedef.ID() == 0 {
+		t.Error("Type ID of btf_trace_sched_switch is zero")
+	}
+
+	again, err := LoadKernelSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again != spec {
+		t.Error("LoadKernelSpec doesn't cache the spec")
+	}
+}
+
 func TestLoadSpecFromElf(t *testing.T) {
 	fh, err := os.Open("../../testdata/loader-clang-9.elf")
 	if err != nil {
@@ -118,3 +236,52 @@ func ExampleSpec_FindType() {
 	// We've found struct foo
 	fmt.Println(foo.Name)
 }
+
+func ExampleSpec_TypeByName() {
+	// Acquire a Spec via one of its constructors.
+	spec := new(Spec)
+
+	var skb *Struct
+	if err := spec.TypeByName("sk_buff", &skb); err != nil {
+		// There is no struct with name sk_buff, or there
+		// are multiple possibilities.
+		return
+	}
+
+	// Compute the offset of a field at runtime.
+	for _, member := range skb.Members {
+		if member.Name == "len" {
+			fmt.Println("offset of sk_buff.len:", member.Offset/8)
+		}
+	}
+}
+
+func TestParseBTFOutOfBounds(t *testing.T) {
+	for _, header := range []btfHeader{
+		{Magic: btfMagic, Version: 1, StringOff: 0xffffffff, StringLen: 1},
+		{Magic: btfMagic, Version: 1, TypeOff: 0xfffffff0, TypeLen: 0x20},
+		{Magic: btfMagic, Version: 1, StringLen: maxStringTableSize + 1},
+	} {
+		header.HdrLen = uint32(binary.Size(&header))
+
+		var buf bytes.Buffer
+		if err := binary.Write(&buf, binary.LittleEndian, &header); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := parseBTF(bytes.NewReader(buf.Bytes()), binary.LittleEndian); err == nil {
+			t.Errorf("Accepted header %+v", header)
+		}
+	}
+}
+
+func TestParseExtInfoRecordSize(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(maxExtInfoRecordSize+4))
+	binary.Write(&buf, binary.LittleEndian, btfExtInfoSec{NumInfo: 1})
+	buf.Write(make([]byte, maxExtInfoRecordSize+4))
+
+	if _, err := parseExtInfo(&buf, binary.LittleEndian, stringTable("\x00")); err == nil {
+		t.Error("Accepted oversized record")
+	}
+}
@@ -31,13 +31,22 @@ const (
 	// Added ~5.1
 	kindVar
 	kindDatasec
+	// Added ~5.13
+	kindFloat
+	// Added ~5.16
+	kindDeclTag
+	kindTypeTag
+	// Added ~6.0
+	kindEnum64
 )
 
 const (
 	btfTypeKindShift = 24
-	btfTypeKindLen   = 4
+	btfTypeKindLen   = 5
 	btfTypeVlenShift = 0
 	btfTypeVlenMask  = 16
+
+	btfTypeKindFlagShift = 31
 )
 
 // btfType is equivalent to struct btf_type in Documentation/bpf/btf.rst.
@@ -46,8 +55,8 @@ type btfType struct {
 	/* "info" bits arrangement
 	 * bits  0-15: vlen (e.g. # of struct's members)
 	 * bits 16-23: unused
-	 * bits 24-27: kind (e.g. int, ptr, array...etc)
-	 * bits 28-30: unused
+	 * bits 24-28: kind (e.g. int, ptr, array...etc)
+	 * bits 29-30: unused
 	 * bit     31: kind_flag, currently used by
 	 *             struct, union and fwd
 	 */
@@ -96,6 +105,14 @@ func (k btfKind) String() string {
 		return "Variable"
 	case kindDatasec:
 		return "Section"
+	case kindFloat:
+		return "Float"
+	case kindDeclTag:
+		return "Decl Tag"
+	case kindTypeTag:
+		return "Type Tag"
+	case kindEnum64:
+		return "Enumeration64"
 	default:
 		return fmt.Sprintf("Unknown (%d)", k)
 	}
@@ -122,6 +139,18 @@ func (bt *btfType) SetKind(kind btfKind) {
 	bt.setInfo(uint32(kind), btfTypeKindLen, btfTypeKindShift)
 }
 
+func (bt *btfType) KindFlag() bool {
+	return bt.info(1, btfTypeKindFlagShift) == 1
+}
+
+func (bt *btfType) SetKindFlag(flag bool) {
+	var value uint32
+	if flag {
+		value = 1
+	}
+	bt.setInfo(value, 1, btfTypeKindFlagShift)
+}
+
 func (bt *btfType) Vlen() int {
 	return int(bt.info(btfTypeVlenMask, btfTypeVlenShift))
 }
@@ -157,6 +186,11 @@ func (rt *rawType) Marshal(w io.Writer, bo binary.ByteOrder) error {
 	return binary.Write(w, bo, rt.data)
 }
 
+const (
+	btfIntEncodingShift = 24
+	btfIntEncodingMask  = 0xf
+)
+
 type btfArray struct {
 	Type      TypeID
 	IndexType TypeID
@@ -169,6 +203,22 @@ type btfMember struct {
 	Offset  uint32
 }
 
+type btfEnum struct {
+	NameOff uint32
+	Val     int32
+}
+
+type btfEnum64 struct {
+	NameOff uint32
+	ValLo32 uint32
+	ValHi32 uint32
+}
+
+type btfParam struct {
+	NameOff uint32
+	Type    TypeID
+}
+
 type btfVarSecinfo struct {
 	Type   TypeID
 	Offset uint32
@@ -179,6 +229,10 @@ type btfVariable struct {
 	Linkage uint32
 }
 
+type btfDeclTag struct {
+	ComponentIdx int32
+}
+
 func readTypes(r io.Reader, bo binary.ByteOrder) ([]rawType, error) {
 	var (
 		header btfType
@@ -195,8 +249,7 @@ func readTypes(r io.Reader, bo binary.ByteOrder) ([]rawType, error) {
 		var data interface{}
 		switch header.Kind() {
 		case kindInt:
-			// sizeof(uint32)
-			data = make([]byte, 4)
+			data = new(uint32)
 		case kindPointer:
 		case kindArray:
 			data = new(btfArray)
@@ -205,8 +258,7 @@ func readTypes(r io.Reader, bo binary.ByteOrder) ([]rawType, error) {
 		case kindUnion:
 			data = make([]btfMember, header.Vlen())
 		case kindEnum:
-			// sizeof(struct btf_enum)
-			data = make([]byte, header.Vlen()*4*2)
+			data = make([]btfEnum, header.Vlen())
 		case kindForward:
 		case kindTypedef:
 		case kindVolatile:
@@ -214,12 +266,17 @@ func readTypes(r io.Reader, bo binary.ByteOrder) ([]rawType, error) {
 		case kindRestrict:
 		case kindFunc:
 		case kindFuncProto:
-			// sizeof(struct btf_param)
-			data = make([]byte, header.Vlen()*4*2)
+			data = make([]btfParam, header.Vlen())
 		case kindVar:
 			data = new(btfVariable)
 		case kindDatasec:
 			data = make([]btfVarSecinfo, header.Vlen())
+		case kindFloat:
+		case kindDeclTag:
+			data = new(btfDeclTag)
+		case kindTypeTag:
+		case kindEnum64:
+			data = make([]btfEnum64, header.Vlen())
 		default:
 			return nil, xerrors.Errorf("type id %v: unknown kind: %v", id, header.Kind())
 		}
@@ -0,0 +1,445 @@
+package btf
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// arrayIndexType is used as the index type of all arrays, since the
+// kernel requires one but doesn't interpret it.
+var arrayIndexType = &Int{Name: "__ARRAY_SIZE_TYPE__", Size: 4}
+
+// Builder creates BTF from Types.
+//
+// Types which are structurally equivalent are only encoded once,
+// even if they come from different Specs.
+//
+// The zero value is not valid, use NewBuilder.
+type Builder struct {
+	// Canonical types in the order they were added, starting at ID 1.
+	types []Type
+	ids   map[Type]TypeID
+	dedup *deduper
+}
+
+// NewBuilder creates a Builder without any types.
+func NewBuilder() *Builder {
+	return &Builder{
+		ids:   make(map[Type]TypeID),
+		dedup: newDeduper(),
+	}
+}
+
+// Add a Type and all types it refers to.
+//
+// Adding the same or an equivalent Type multiple times is allowed and
+// returns the same ID. The TypeID embedded in typ is ignored, use the
+// returned ID instead.
+func (b *Builder) Add(typ Type) (TypeID, error) {
+	var (
+		work  copyStack
+		added []Type
+		seen  = make(map[Type]bool)
+	)
+
+	for t := &typ; t != nil; t = work.pop() {
+		if *t == nil {
+			return 0, xerrors.New("type contains a nil Type")
+		}
+
+		if _, ok := (*t).(Void); ok {
+			continue
+		}
+
+		if _, ok := b.ids[*t]; ok || seen[*t] {
+			continue
+		}
+
+		seen[*t] = true
+		added = append(added, *t)
+
+		if _, ok := (*t).(*Array); ok {
+			index := Type(arrayIndexType)
+			work.push(&index)
+		}
+
+		(*t).walk(&work)
+	}
+
+	canonical := b.dedup.add(added)
+	if len(b.types)+len(canonical) > math.MaxInt32 {
+		return 0, xerrors.New("too many types")
+	}
+
+	for _, typ := range canonical {
+		b.types = append(b.types, typ)
+		b.ids[typ] = TypeID(len(b.types))
+	}
+
+	for _, typ := range added {
+		b.ids[typ] = b.ids[b.dedup.find(typ)]
+	}
+
+	return b.id(typ), nil
+}
+
+func (b *Builder) id(typ Type) TypeID {
+	if _, ok := typ.(Void); ok {
+		return 0
+	}
+	return b.ids[typ]
+}
+
+// Spec encodes all added types and decodes them again.
+//
+// The types in the returned Spec are copies, which carry the IDs
+// returned by Add.
+func (b *Builder) Spec() (*Spec, error) {
+	var (
+		strings  = newStringTableBuilder()
+		rawTypes = make([]rawType, 0, len(b.types))
+	)
+
+	for _, typ := range b.types {
+		raw, err := b.marshalType(typ, strings)
+		if err != nil {
+			return nil, xerrors.Errorf("type id %d: %w", b.ids[typ], err)
+		}
+		rawTypes = append(rawTypes, raw)
+	}
+
+	rawStrings := strings.Table()
+	types, namedTypes, err := inflateRawTypes(rawTypes, rawStrings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Spec{
+		rawTypes:   rawTypes,
+		strings:    rawStrings,
+		types:      types,
+		namedTypes: namedTypes,
+		funcInfos:  make(map[string]extInfo),
+		lineInfos:  make(map[string]extInfo),
+	}, nil
+}
+
+// Map returns the BTF for a map with the given key and value.
+//
+// key and value are added to the Builder if necessary.
+func (b *Builder) Map(key, value Type) (*Map, error) {
+	keyID, err := b.Add(key)
+	if err != nil {
+		return nil, xerrors.Errorf("key: %w", err)
+	}
+
+	valueID, err := b.Add(value)
+	if err != nil {
+		return nil, xerrors.Errorf("value: %w", err)
+	}
+
+	spec, err := b.Spec()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Map{spec, spec.types[keyID], spec.types[valueID]}, nil
+}
+
+// FuncOffset places a function in a stream of instructions.
+type FuncOffset struct {
+	Func *Func
+	// Offset of the first instruction of the function, in bytes.
+	Offset uint64
+}
+
+// Program returns the BTF for length bytes of instructions, which
+// contain funcs. The instructions don't have line information.
+//
+// funcs are added to the Builder if necessary. The kernel requires a
+// function at offset zero.
+func (b *Builder) Program(length uint64, funcs []FuncOffset) (*Program, error) {
+	ids := make([]TypeID, 0, len(funcs))
+	for _, fn := range funcs {
+		if fn.Offset >= length {
+			return nil, xerrors.Errorf("func %s: offset %d is out of bounds", fn.Func.Name, fn.Offset)
+		}
+
+		id, err := b.Add(fn.Func)
+		if err != nil {
+			return nil, xerrors.Errorf("func %s: %w", fn.Func.Name, err)
+		}
+		ids = append(ids, id)
+	}
+
+	spec, err := b.Spec()
+	if err != nil {
+		return nil, err
+	}
+
+	// struct bpf_func_info is an instruction offset followed by a type ID.
+	funcInfos := extInfo{recordSize: 8}
+	for i, fn := range funcs {
+		opaque := make([]byte, 4)
+		internal.NativeEndian.PutUint32(opaque, uint32(ids[i]))
+		funcInfos.records = append(funcInfos.records, extInfoRecord{fn.Offset, opaque})
+	}
+	sort.SliceStable(funcInfos.records, func(i, j int) bool {
+		return funcInfos.records[i].InsnOff < funcInfos.records[j].InsnOff
+	})
+
+	return &Program{
+		spec,
+		length,
+		funcInfos,
+		extInfo{recordSize: uint32(binary.Size(bpfLineInfo{}))},
+	}, nil
+}
+
+func (b *Builder) marshalType(typ Type, strings *stringTableBuilder) (rawType, error) {
+	var (
+		raw  rawType
+		name string
+	)
+
+	if namer, ok := typ.(namer); ok {
+		name = namer.name()
+	}
+
+	switch v := typ.(type) {
+	case *Int:
+		if v.Size > math.MaxUint32/8 {
+			return raw, xerrors.Errorf("int %s: size %d is too large", v.Name, v.Size)
+		}
+		raw.SetKind(kindInt)
+		raw.SizeType = v.Size
+		data := uint32(v.Encoding)<<btfIntEncodingShift | v.Size*8
+		raw.data = &data
+
+	case *Pointer:
+		raw.SetKind(kindPointer)
+		raw.SizeType = uint32(b.id(v.Target))
+
+	case *Array:
+		raw.SetKind(kindArray)
+		raw.data = &btfArray{
+			Type:      b.id(v.Type),
+			IndexType: b.id(arrayIndexType),
+			Nelems:    v.Nelems,
+		}
+
+	case *Struct:
+		raw.SetKind(kindStruct)
+		raw.SizeType = v.Size
+		if err := b.marshalMembers(&raw, v.Members, strings); err != nil {
+			return raw, xerrors.Errorf("struct %s: %w", v.Name, err)
+		}
+
+	case *Union:
+		raw.SetKind(kindUnion)
+		raw.SizeType = v.Size
+		if err := b.marshalMembers(&raw, v.Members, strings); err != nil {
+			return raw, xerrors.Errorf("union %s: %w", v.Name, err)
+		}
+
+	case *Enum:
+		if err := b.marshalEnum(&raw, v, strings); err != nil {
+			return raw, xerrors.Errorf("enum %s: %w", v.Name, err)
+		}
+
+	case *Fwd:
+		raw.SetKind(kindForward)
+		raw.SetKindFlag(v.Kind == FwdUnion)
+
+	case *Typedef:
+		raw.SetKind(kindTypedef)
+		raw.SizeType = uint32(b.id(v.Type))
+
+	case *Volatile:
+		raw.SetKind(kindVolatile)
+		raw.SizeType = uint32(b.id(v.Type))
+
+	case *Const:
+		raw.SetKind(kindConst)
+		raw.SizeType = uint32(b.id(v.Type))
+
+	case *Restrict:
+		raw.SetKind(kindRestrict)
+		raw.SizeType = uint32(b.id(v.Type))
+
+	case *Func:
+		if _, ok := v.Type.(*FuncProto); !ok {
+			return raw, xerrors.Errorf("func %s: type %T is not a FuncProto", v.Name, v.Type)
+		}
+		raw.SetKind(kindFunc)
+		raw.SetVlen(int(v.Linkage))
+		raw.SizeType = uint32(b.id(v.Type))
+
+	case *FuncProto:
+		if len(v.Params) > maxVlen {
+			return raw, xerrors.Errorf("func proto: too many parameters")
+		}
+
+		params := make([]btfParam, 0, len(v.Params))
+		for _, param := range v.Params {
+			nameOff, err := strings.Add(string(param.Name))
+			if err != nil {
+				return raw, xerrors.Errorf("func proto: %w", err)
+			}
+			params = append(params, btfParam{nameOff, b.id(param.Type)})
+		}
+
+		raw.SetKind(kindFuncProto)
+		raw.SetVlen(len(params))
+		raw.SizeType = uint32(b.id(v.Return))
+		raw.data = params
+
+	case *Var:
+		raw.SetKind(kindVar)
+		raw.SizeType = uint32(b.id(v.Type))
+		raw.data = &btfVariable{}
+
+	case *Datasec:
+		if len(v.Vars) > maxVlen {
+			return raw, xerrors.Errorf("datasec %s: too many variables", v.Name)
+		}
+
+		vars := make([]btfVarSecinfo, 0, len(v.Vars))
+		for _, secinfo := range v.Vars {
+			if _, ok := secinfo.Type.(*Var); !ok {
+				return raw, xerrors.Errorf("datasec %s: type %T is not a Var", v.Name, secinfo.Type)
+			}
+			vars = append(vars, btfVarSecinfo{b.id(secinfo.Type), secinfo.Offset, secinfo.Size})
+		}
+
+		raw.SetKind(kindDatasec)
+		raw.SetVlen(len(vars))
+		raw.SizeType = v.Size
+		raw.data = vars
+
+	case *Float:
+		raw.SetKind(kindFloat)
+		raw.SizeType = v.Size
+
+	case *DeclTag:
+		if v.Index < -1 || v.Index > math.MaxInt32 {
+			return raw, xerrors.Errorf("decl tag %s: invalid index %d", v.Value, v.Index)
+		}
+		raw.SetKind(kindDeclTag)
+		raw.SizeType = uint32(b.id(v.Type))
+		raw.data = &btfDeclTag{int32(v.Index)}
+		name = v.Value
+
+	case *TypeTag:
+		raw.SetKind(kindTypeTag)
+		raw.SizeType = uint32(b.id(v.Type))
+		name = v.Value
+
+	default:
+		return raw, xerrors.Errorf("unsupported type %T", typ)
+	}
+
+	nameOff, err := strings.Add(name)
+	if err != nil {
+		return raw, err
+	}
+	raw.NameOff = nameOff
+
+	return raw, nil
+}
+
+// maxVlen is the maximum number of members, values, parameters or
+// variables a type may have.
+const maxVlen = 1<<btfTypeVlenMask - 1
+
+func (b *Builder) marshalMembers(raw *rawType, members []Member, strings *stringTableBuilder) error {
+	if len(members) > maxVlen {
+		return xerrors.New("too many members")
+	}
+
+	// Bitfields can only be encoded if the kind flag is set,
+	// which changes the encoding of all offsets.
+	var kindFlag bool
+	for _, member := range members {
+		if member.BitfieldSize > 0 {
+			kindFlag = true
+			break
+		}
+	}
+
+	btfMembers := make([]btfMember, 0, len(members))
+	for _, member := range members {
+		nameOff, err := strings.Add(string(member.Name))
+		if err != nil {
+			return xerrors.Errorf("member %s: %w", member.Name, err)
+		}
+
+		offset := member.Offset
+		if kindFlag {
+			if offset > 0xffffff || member.BitfieldSize > 0xff {
+				return xerrors.Errorf("member %s: offset or bitfield size too large", member.Name)
+			}
+			offset |= member.BitfieldSize << 24
+		}
+
+		btfMembers = append(btfMembers, btfMember{nameOff, b.id(member.Type), offset})
+	}
+
+	raw.SetKindFlag(kindFlag)
+	raw.SetVlen(len(btfMembers))
+	raw.data = btfMembers
+	return nil
+}
+
+func (b *Builder) marshalEnum(raw *rawType, enum *Enum, strings *stringTableBuilder) error {
+	if len(enum.Values) > maxVlen {
+		return xerrors.New("too many values")
+	}
+
+	// Use the traditional encoding unless a value doesn't fit into 32 bits.
+	is64 := false
+	for _, value := range enum.Values {
+		if enum.Signed {
+			is64 = is64 || int64(value.Value) != int64(int32(value.Value))
+		} else {
+			is64 = is64 || value.Value > math.MaxUint32
+		}
+	}
+
+	raw.SetKindFlag(enum.Signed)
+	raw.SetVlen(len(enum.Values))
+	raw.SizeType = enum.Size
+
+	if is64 {
+		values := make([]btfEnum64, 0, len(enum.Values))
+		for _, value := range enum.Values {
+			nameOff, err := strings.Add(string(value.Name))
+			if err != nil {
+				return err
+			}
+			values = append(values, btfEnum64{nameOff, uint32(value.Value), uint32(value.Value >> 32)})
+		}
+
+		raw.SetKind(kindEnum64)
+		raw.data = values
+		return nil
+	}
+
+	values := make([]btfEnum, 0, len(enum.Values))
+	for _, value := range enum.Values {
+		nameOff, err := strings.Add(string(value.Name))
+		if err != nil {
+			return err
+		}
+		values = append(values, btfEnum{nameOff, int32(value.Value)})
+	}
+
+	raw.SetKind(kindEnum)
+	raw.data = values
+	return nil
+}
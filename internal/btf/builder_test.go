@@ -0,0 +1,244 @@
+package btf
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestBuilder(t *testing.T) {
+	u32 := &Int{Name: "u32", Size: 4}
+	s64 := &Int{Name: "s64", Size: 8, Encoding: Signed}
+	char := &Int{Name: "char", Size: 1, Encoding: Char}
+
+	list := &Struct{Name: "list", Size: 16}
+	list.Members = []Member{
+		{Name: "next", Type: &Pointer{Target: list}, Offset: 0},
+		{Name: "flags", Type: u32, Offset: 64, BitfieldSize: 3},
+		{Name: "tag", Type: &Array{Type: char, Nelems: 3}, Offset: 72},
+	}
+
+	value := &Union{Name: "value", Size: 16, Members: []Member{
+		{Name: "list", Type: list},
+		{Name: "num", Type: &Const{Type: &Volatile{Type: s64}}},
+		{Name: "kind", Type: &Enum{Name: "kind", Size: 8, Values: []EnumValue{
+			{"SMALL", 1},
+			{"LARGE", 1 << 40},
+		}}},
+		{Name: "fwd", Type: &Pointer{Target: &Fwd{Name: "opaque", Kind: FwdUnion}}},
+	}}
+
+	fn := &Func{Name: "fn", Type: &FuncProto{
+		Return: &Typedef{Name: "ret_t", Type: s64},
+		Params: []FuncParam{
+			{Name: "v", Type: &Pointer{Target: &Restrict{Type: value}}},
+		},
+	}}
+
+	datasec := &Datasec{Name: ".data", Size: 8, Vars: []VarSecinfo{
+		{Type: &Var{Name: "global", Type: s64}, Offset: 0, Size: 8},
+	}}
+
+	b := NewBuilder()
+	for _, typ := range []Type{value, fn, datasec} {
+		if _, err := b.Add(typ); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	id, err := b.Add(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Error("Adding a type twice doesn't return the same ID")
+	}
+
+	spec, err := b.Spec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if typ, err := spec.TypeByID(id); err != nil {
+		t.Fatal(err)
+	} else if typ.(*Union).Name != "value" {
+		t.Error("Type with ID", id, "is not the union")
+	}
+
+	var s64Copy *Int
+	if err := spec.TypeByName("s64", &s64Copy); err != nil {
+		t.Fatal(err)
+	}
+	if s64Copy.Encoding != Signed {
+		t.Error("Encoding of int isn't preserved")
+	}
+
+	want, err := DumpC(value, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		valueCopy *Union
+		fnCopy    *Func
+	)
+	if err := spec.TypeByName("value", &valueCopy); err != nil {
+		t.Fatal(err)
+	}
+	if err := spec.TypeByName("fn", &fnCopy); err != nil {
+		t.Fatal(err)
+	}
+	have, err := DumpC(valueCopy, fnCopy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have != want {
+		t.Errorf("Types don't survive a round trip:\n%s\nwant:\n%s", have, want)
+	}
+
+	handle, err := NewHandle(spec)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't load BTF:", err)
+	}
+	handle.Close()
+}
+
+func TestBuilderMap(t *testing.T) {
+	m, err := LocalStorageMap(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id := MapKey(m).ID(); id == 0 {
+		t.Error("Key has no ID")
+	}
+
+	if size, err := Sizeof(MapValue(m)); err != nil {
+		t.Fatal(err)
+	} else if size != 16 {
+		t.Error("Expected a value of 16 bytes, got", size)
+	}
+}
+
+func TestBuilderProgram(t *testing.T) {
+	proto := &FuncProto{Return: &Int{Name: "int", Size: 4, Encoding: Signed}}
+	main := &Func{Name: "main", Type: proto, Linkage: GlobalFunc}
+	sub := &Func{Name: "sub", Type: proto, Linkage: StaticFunc}
+
+	b := NewBuilder()
+	prog, err := b.Program(32, []FuncOffset{{sub, 16}, {main, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fn := new(Func)
+	if err := ProgramSpec(prog).FindType("main", fn); err != nil {
+		t.Fatal(err)
+	}
+	if fn.Linkage != GlobalFunc {
+		t.Error("Linkage of main is", fn.Linkage)
+	}
+
+	recSize, infos, err := ProgramFuncInfos(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recSize != 8 {
+		t.Fatal("Unexpected record size", recSize)
+	}
+	if len(infos) != 16 {
+		t.Fatal("Expected two func infos, got", len(infos)/8)
+	}
+	if off := internal.NativeEndian.Uint32(infos[8:]); off != 2 {
+		t.Error("Expected sub at instruction 2, got", off)
+	}
+
+	if _, err := b.Program(16, []FuncOffset{{sub, 16}}); err == nil {
+		t.Error("Program accepts a function past the end")
+	}
+}
+
+func TestBuilderVmlinux(t *testing.T) {
+	spec := parseVmlinux(t)
+
+	b := NewBuilder()
+	for _, typ := range spec.types {
+		if _, err := b.Add(typ); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	built, err := b.Spec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handle, err := NewHandle(built)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't load BTF:", err)
+	}
+	handle.Close()
+}
+
+func TestBuilderDedup(t *testing.T) {
+	newList := func(valueSize uint32) *Struct {
+		list := &Struct{Name: "list", Size: 16}
+		list.Members = []Member{
+			{Name: "next", Type: &Pointer{Target: list}},
+			{Name: "value", Type: &Int{Name: "int", Size: valueSize}, Offset: 64},
+		}
+		return list
+	}
+
+	b := NewBuilder()
+	first, err := b.Add(newList(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := b.Add(newList(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Error("Equivalent types have different IDs")
+	}
+
+	if n := len(b.types); n != 3 {
+		t.Error("Expected three types, got", n)
+	}
+
+	third, err := b.Add(newList(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if third == first {
+		t.Error("Different types have the same ID")
+	}
+}
+
+func TestBuilderDedupVmlinux(t *testing.T) {
+	b := NewBuilder()
+	for _, typ := range parseVmlinux(t).types {
+		if _, err := b.Add(typ); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n := len(b.types)
+	for _, typ := range parseVmlinux(t).types {
+		if _, err := b.Add(typ); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(b.types) != n {
+		t.Errorf("Adding the same BTF twice added %d types", len(b.types)-n)
+	}
+}
@@ -0,0 +1,192 @@
+package btf
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+)
+
+// How deep to look into referenced types when hashing a type.
+const dedupHashDepth = 3
+
+// deduper finds types which are structurally equivalent, similar to
+// btf__dedup in libbpf.
+//
+// Two types are equivalent if they are of the same kind, have the same
+// properties and refer to equivalent types. Equivalence is decided
+// coinductively, which allows comparing cyclic types.
+type deduper struct {
+	// Maps a type to an equivalent type. Types which map to
+	// themselves are canonical.
+	canon map[Type]Type
+	// Canonical types by their hash.
+	buckets map[uint64][]Type
+}
+
+func newDeduper() *deduper {
+	return &deduper{
+		make(map[Type]Type),
+		make(map[uint64][]Type),
+	}
+}
+
+// find returns the type typ is known to be equivalent to, or typ itself.
+func (dd *deduper) find(typ Type) Type {
+	for {
+		next, ok := dd.canon[typ]
+		if !ok || next == typ {
+			return typ
+		}
+		typ = next
+	}
+}
+
+func (dd *deduper) isCanonical(typ Type) bool {
+	return dd.canon[typ] == typ
+}
+
+// add finds an equivalent for each type, or makes it canonical.
+//
+// Returns the types which became canonical, in order.
+func (dd *deduper) add(types []Type) []Type {
+	var canonical []Type
+	for _, typ := range types {
+		if _, ok := dd.canon[typ]; ok {
+			continue
+		}
+
+		hash := dedupHash(typ, dedupHashDepth)
+
+		var equal typePairs
+		for _, candidate := range dd.buckets[hash] {
+			equal = make(typePairs)
+			if dd.equivalent(typ, candidate, equal) {
+				break
+			}
+			equal = nil
+		}
+
+		if equal == nil {
+			dd.canon[typ] = typ
+			dd.buckets[hash] = append(dd.buckets[hash], typ)
+			canonical = append(canonical, typ)
+			continue
+		}
+
+		// All pairs which were compared are equivalent.
+		for pair := range equal {
+			a, b := dd.find(pair.a), dd.find(pair.b)
+			if a == b {
+				continue
+			}
+
+			if dd.isCanonical(a) {
+				dd.canon[b] = a
+			} else {
+				dd.canon[a] = b
+			}
+		}
+	}
+
+	return canonical
+}
+
+type typePair struct {
+	a, b Type
+}
+
+type typePairs map[typePair]bool
+
+// equivalent compares a and b, assuming that types in assumed are equal.
+func (dd *deduper) equivalent(a, b Type, assumed typePairs) bool {
+	canonA, canonB := dd.find(a), dd.find(b)
+	if canonA == canonB {
+		return true
+	}
+
+	if dd.isCanonical(canonA) && dd.isCanonical(canonB) {
+		return false
+	}
+
+	pair := typePair{a, b}
+	if assumed[pair] {
+		return true
+	}
+
+	if !reflect.DeepEqual(shallowCopy(a), shallowCopy(b)) {
+		return false
+	}
+
+	assumed[pair] = true
+
+	childrenA, childrenB := children(a), children(b)
+	for i := range childrenA {
+		if !dd.equivalent(*childrenA[i], *childrenB[i], assumed) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// shallowCopy returns a copy of typ without an ID or references to
+// other types.
+func shallowCopy(typ Type) Type {
+	if _, ok := typ.(Void); ok {
+		return typ
+	}
+
+	cpy := typ.copy()
+	for _, child := range children(cpy) {
+		*child = nil
+	}
+
+	if id := reflect.ValueOf(cpy).Elem().FieldByName("TypeID"); id.IsValid() {
+		id.SetUint(0)
+	}
+
+	return cpy
+}
+
+// children returns the types referred to by typ, in a stable order.
+func children(typ Type) []*Type {
+	var cs copyStack
+	typ.walk(&cs)
+	return cs
+}
+
+// dedupHash returns a hash which is equal for equivalent types.
+//
+// Referenced types are hashed up to depth, except for the members of
+// structs and unions which contribute only their kind and name.
+func dedupHash(typ Type, depth int) uint64 {
+	h := fnv.New64a()
+	writeDedupHash(h, typ, depth)
+	return h.Sum64()
+}
+
+func writeDedupHash(h interface{ Write([]byte) (int, error) }, typ Type, depth int) {
+	switch typ.(type) {
+	case *Struct, *Union:
+		depth = 0
+	}
+
+	fmt.Fprintf(h, "%#v;", shallowCopy(typ))
+	if depth <= 0 {
+		for _, child := range children(typ) {
+			fmt.Fprintf(h, "%T:%s;", *child, typeName(*child))
+		}
+		return
+	}
+
+	for _, child := range children(typ) {
+		writeDedupHash(h, *child, depth-1)
+	}
+}
+
+func typeName(typ Type) string {
+	if namer, ok := typ.(namer); ok {
+		return namer.name()
+	}
+	return ""
+}
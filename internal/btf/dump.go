@@ -0,0 +1,752 @@
+package btf
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// DumpC returns C definitions for types and all types they depend on,
+// similar to "bpftool btf dump format c".
+//
+// Named structs, unions, enums and typedefs are defined at the top level,
+// anonymous ones are defined inline. Funcs and Vars are declared. Types
+// with clashing names are renamed by appending ___N, where N is a counter.
+//
+// The output doesn't contain include guards.
+func DumpC(types ...Type) (string, error) {
+	d := &cDumper{
+		names:  make(map[Type]string),
+		used:   make(map[string]int),
+		state:  make(map[Type]dumpState),
+		fwds:   make(map[string]bool),
+		aligns: make(map[Type]uint32),
+		packed: make(map[Type]bool),
+	}
+
+	for _, typ := range types {
+		switch v := typ.(type) {
+		case *Func, *Var:
+			d.decls = append(d.decls, typ)
+		case *Datasec:
+			for _, vsi := range v.Vars {
+				d.decls = append(d.decls, vsi.Type)
+			}
+		}
+
+		if err := d.walk(typ, true, 0); err != nil {
+			return "", err
+		}
+	}
+
+	var buf strings.Builder
+	for _, fwd := range d.fwdOrder {
+		fmt.Fprintf(&buf, "%s;\n", fwd)
+	}
+	if len(d.fwdOrder) > 0 {
+		buf.WriteString("\n")
+	}
+
+	for _, typ := range d.order {
+		def, err := d.definition(typ)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&buf, "%s;\n\n", def)
+	}
+
+	for _, typ := range d.decls {
+		decl, err := d.declaration(typ)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&buf, "%s;\n", decl)
+	}
+
+	return strings.TrimRight(buf.String(), "\n") + "\n", nil
+}
+
+type dumpState int
+
+const (
+	dumpUnvisited dumpState = iota
+	dumpVisiting
+	dumpDone
+)
+
+type cDumper struct {
+	// The C name of named types.
+	names map[Type]string
+	// The number of types using a name, per namespace.
+	used map[string]int
+	// Whether a named type was visited, and the order in which
+	// named types are defined.
+	state map[Type]dumpState
+	order []Type
+	// Forward declarations, emitted before any definitions.
+	fwds     map[string]bool
+	fwdOrder []string
+	// Funcs and Vars to declare.
+	decls []Type
+
+	aligns map[Type]uint32
+	packed map[Type]bool
+}
+
+// walk makes sure that the types typ depends on are defined or forward
+// declared.
+//
+// Types used by value need a definition, while structs and unions
+// which are only used via a pointer merely need a forward declaration.
+func (d *cDumper) walk(typ Type, byValue bool, depth int) error {
+	if depth > maxTypeDepth {
+		return xerrors.New("exceeded type depth")
+	}
+
+	switch v := typ.(type) {
+	case *Struct:
+		return d.walkComposite(v, v.Name, v.Members, byValue, depth)
+
+	case *Union:
+		return d.walkComposite(v, v.Name, v.Members, byValue, depth)
+
+	case *Enum:
+		if v.Name == "" {
+			return nil
+		}
+		if len(v.Values) == 0 {
+			// An enum without values is a forward declaration.
+			d.forward(v)
+			return nil
+		}
+		return d.define(v)
+
+	case *Fwd:
+		d.forward(v)
+
+	case *Typedef:
+		if err := d.define(v); err != nil {
+			return err
+		}
+		if byValue {
+			// The target of the typedef has to be complete as well.
+			return d.walk(v.Type, true, depth+1)
+		}
+
+	case *Pointer:
+		return d.walk(v.Target, false, depth+1)
+
+	case *Array:
+		// Elements of an array have to be complete, even if the
+		// array itself is behind a pointer.
+		return d.walk(v.Type, true, depth+1)
+
+	case *Const:
+		return d.walk(v.Type, byValue, depth+1)
+	case *Volatile:
+		return d.walk(v.Type, byValue, depth+1)
+	case *Restrict:
+		return d.walk(v.Type, byValue, depth+1)
+	case *TypeTag:
+		return d.walk(v.Type, byValue, depth+1)
+
+	case *FuncProto:
+		if err := d.walk(v.Return, false, depth+1); err != nil {
+			return err
+		}
+		for _, param := range v.Params {
+			if err := d.walk(param.Type, false, depth+1); err != nil {
+				return err
+			}
+		}
+
+	case *Func:
+		return d.walk(v.Type, false, depth+1)
+
+	case *Var:
+		return d.walk(v.Type, true, depth+1)
+
+	case *Datasec:
+		for _, vsi := range v.Vars {
+			if err := d.walk(vsi.Type, true, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *cDumper) walkComposite(typ Type, name Name, members []Member, byValue bool, depth int) error {
+	if name != "" {
+		if !byValue {
+			d.forward(typ)
+			return nil
+		}
+		return d.define(typ)
+	}
+
+	// Anonymous types are defined inline.
+	for _, member := range members {
+		if err := d.walk(member.Type, true, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// define adds a named type to the list of definitions, after all
+// types it depends on.
+func (d *cDumper) define(typ Type) error {
+	switch d.state[typ] {
+	case dumpDone:
+		return nil
+	case dumpVisiting:
+		// The type refers to itself, which is only valid C if it
+		// does so via a forward declaration.
+		return nil
+	}
+
+	d.state[typ] = dumpVisiting
+	d.name(typ)
+
+	var err error
+	switch v := typ.(type) {
+	case *Struct:
+		err = d.walkMembers(v.Members)
+	case *Union:
+		err = d.walkMembers(v.Members)
+	case *Typedef:
+		// A typedef of a struct may refer to an incomplete type.
+		err = d.walk(v.Type, false, 0)
+	}
+	if err != nil {
+		return xerrors.Errorf("%s: %w", d.name(typ), err)
+	}
+
+	d.state[typ] = dumpDone
+	d.order = append(d.order, typ)
+	return nil
+}
+
+func (d *cDumper) walkMembers(members []Member) error {
+	for _, member := range members {
+		if err := d.walk(member.Type, true, 0); err != nil {
+			return xerrors.Errorf("member %s: %w", member.Name, err)
+		}
+	}
+	return nil
+}
+
+func (d *cDumper) forward(typ Type) {
+	var kind string
+	switch v := typ.(type) {
+	case *Struct:
+		kind = "struct"
+	case *Union:
+		kind = "union"
+	case *Enum:
+		kind = "enum"
+	case *Fwd:
+		kind = v.Kind.String()
+	default:
+		return
+	}
+
+	fwd := kind + " " + d.name(typ)
+	if !d.fwds[fwd] {
+		d.fwds[fwd] = true
+		d.fwdOrder = append(d.fwdOrder, fwd)
+	}
+}
+
+// name returns the C name of a named type.
+func (d *cDumper) name(typ Type) string {
+	if name, ok := d.names[typ]; ok {
+		return name
+	}
+
+	var (
+		name string
+		// Struct, union and enum tags share a namespace, which is
+		// separate from typedefs and enumerators.
+		namespace = tagNamespace
+	)
+	switch v := typ.(type) {
+	case *Struct:
+		name = string(v.Name)
+	case *Union:
+		name = string(v.Name)
+	case *Enum:
+		name = string(v.Name)
+	case *Fwd:
+		// A forward declaration refers to a definition of the same
+		// name, so it doesn't claim the name.
+		return string(v.Name)
+	case *Typedef:
+		name = string(v.Name)
+		namespace = identNamespace
+	case namer:
+		return v.name()
+	default:
+		return ""
+	}
+
+	name = d.claim(namespace, name)
+	d.names[typ] = name
+	return name
+}
+
+const (
+	tagNamespace   = "tag:"
+	identNamespace = "ident:"
+)
+
+// claim returns a unique name in a namespace.
+func (d *cDumper) claim(namespace, name string) string {
+	n := d.used[namespace+name]
+	d.used[namespace+name]++
+	if n > 0 {
+		return fmt.Sprintf("%s___%d", name, n+1)
+	}
+	return name
+}
+
+func (d *cDumper) definition(typ Type) (string, error) {
+	switch v := typ.(type) {
+	case *Struct:
+		return d.composite(v, "struct", d.name(v), v.Members, v.Size, 0)
+	case *Union:
+		return d.composite(v, "union", d.name(v), v.Members, v.Size, 0)
+	case *Enum:
+		return d.enum(v, d.name(v), 0), nil
+	case *Typedef:
+		decl, err := d.decl(v.Type, d.name(v), 0)
+		if err != nil {
+			return "", xerrors.Errorf("typedef %s: %w", v.Name, err)
+		}
+		return "typedef " + decl, nil
+	default:
+		return "", xerrors.Errorf("can't define %T", typ)
+	}
+}
+
+func (d *cDumper) declaration(typ Type) (string, error) {
+	switch v := typ.(type) {
+	case *Func:
+		return d.decl(v.Type, string(v.Name), 0)
+	case *Var:
+		decl, err := d.decl(v.Type, string(v.Name), 0)
+		if err != nil {
+			return "", err
+		}
+		return "extern " + decl, nil
+	default:
+		return "", xerrors.Errorf("can't declare %T", typ)
+	}
+}
+
+// decl returns a C declaration of declarator with type typ.
+//
+// The declarator is the part of a declaration which names the
+// declared identifier, for example *foo[2] in int *foo[2].
+func (d *cDumper) decl(typ Type, declarator string, depth int) (string, error) {
+	if depth > maxTypeDepth {
+		return "", xerrors.New("exceeded type depth")
+	}
+
+	switch v := typ.(type) {
+	case Void:
+		return join("void", declarator), nil
+
+	case *Int:
+		return join(string(v.Name), declarator), nil
+
+	case *Float:
+		return join(string(v.Name), declarator), nil
+
+	case *Pointer:
+		declarator = "*" + declarator
+		switch skipTypeTags(v.Target).(type) {
+		case *Array, *FuncProto:
+			declarator = "(" + declarator + ")"
+		}
+		return d.decl(v.Target, declarator, depth)
+
+	case *Array:
+		return d.decl(v.Type, fmt.Sprintf("%s[%d]", declarator, v.Nelems), depth)
+
+	case *FuncProto:
+		params := make([]string, 0, len(v.Params))
+		for i, param := range v.Params {
+			if _, ok := param.Type.(Void); ok && param.Name == "" && i == len(v.Params)-1 {
+				params = append(params, "...")
+				continue
+			}
+
+			decl, err := d.decl(param.Type, string(param.Name), depth)
+			if err != nil {
+				return "", xerrors.Errorf("parameter %d: %w", i, err)
+			}
+			params = append(params, decl)
+		}
+		if len(params) == 0 {
+			params = append(params, "void")
+		}
+		return d.decl(v.Return, fmt.Sprintf("%s(%s)", declarator, strings.Join(params, ", ")), depth)
+
+	case *Const:
+		return d.qualified("const", v.Type, declarator, depth)
+	case *Volatile:
+		return d.qualified("volatile", v.Type, declarator, depth)
+	case *Restrict:
+		return d.qualified("restrict", v.Type, declarator, depth)
+	case *TypeTag:
+		return d.decl(v.Type, declarator, depth)
+
+	case *Struct:
+		if v.Name != "" {
+			return join("struct "+d.name(v), declarator), nil
+		}
+		def, err := d.composite(v, "struct", "", v.Members, v.Size, depth)
+		return join(def, declarator), err
+
+	case *Union:
+		if v.Name != "" {
+			return join("union "+d.name(v), declarator), nil
+		}
+		def, err := d.composite(v, "union", "", v.Members, v.Size, depth)
+		return join(def, declarator), err
+
+	case *Enum:
+		if v.Name != "" {
+			return join("enum "+d.name(v), declarator), nil
+		}
+		return join(d.enum(v, "", depth), declarator), nil
+
+	case *Fwd:
+		return join(v.Kind.String()+" "+d.name(v), declarator), nil
+
+	case *Typedef:
+		return join(d.name(v), declarator), nil
+
+	default:
+		return "", xerrors.Errorf("unsupported type %T", typ)
+	}
+}
+
+// qualified declares a type with a qualifier.
+func (d *cDumper) qualified(qualifier string, typ Type, declarator string, depth int) (string, error) {
+	if _, ok := skipModifiers(typ).(*Pointer); ok {
+		// The qualifier applies to the pointer: int *const foo.
+		return d.decl(typ, qualifier+" "+declarator, depth)
+	}
+
+	decl, err := d.decl(typ, declarator, depth)
+	if err != nil {
+		return "", err
+	}
+	return qualifier + " " + decl, nil
+}
+
+func (d *cDumper) composite(typ Type, kind, name string, members []Member, size uint32, depth int) (string, error) {
+	var (
+		buf    strings.Builder
+		indent = strings.Repeat("\t", depth+1)
+		packed = d.isPacked(typ, members, size)
+		// The end of the previous member in bits.
+		end uint64
+	)
+
+	buf.WriteString(kind)
+	if name != "" {
+		buf.WriteString(" " + name)
+	}
+	buf.WriteString(" {\n")
+
+	for _, member := range members {
+		offset := uint64(member.Offset)
+
+		if kind == "struct" {
+			next := d.nextOffset(end, member, packed)
+			if offset < next {
+				return "", xerrors.Errorf("%s %s: member %s overlaps previous member", kind, name, member.Name)
+			}
+			if offset > next {
+				writePadding(&buf, indent, end, offset)
+			}
+		}
+
+		decl, err := d.decl(member.Type, string(member.Name), depth+1)
+		if err != nil {
+			return "", xerrors.Errorf("%s %s: member %s: %w", kind, name, member.Name, err)
+		}
+
+		buf.WriteString(indent + decl)
+		if member.BitfieldSize > 0 {
+			fmt.Fprintf(&buf, ": %d", member.BitfieldSize)
+			offset += uint64(member.BitfieldSize)
+		} else {
+			size, err := Sizeof(member.Type)
+			if err != nil {
+				return "", xerrors.Errorf("%s %s: member %s: %w", kind, name, member.Name, err)
+			}
+			offset += uint64(size) * 8
+		}
+		buf.WriteString(";\n")
+
+		if offset > end {
+			end = offset
+		}
+	}
+
+	if kind == "struct" && end < uint64(size)*8 {
+		natural := alignUp(end, uint64(d.alignof(typ))*8)
+		if natural != uint64(size)*8 {
+			writePadding(&buf, indent, end, uint64(size)*8)
+		}
+	}
+
+	buf.WriteString(indent[:depth] + "}")
+	if packed {
+		buf.WriteString(" __attribute__((packed))")
+	}
+	return buf.String(), nil
+}
+
+// nextOffset returns the offset in bits at which a compiler places member
+// after a previous member ending at end.
+func (d *cDumper) nextOffset(end uint64, member Member, packed bool) uint64 {
+	if member.BitfieldSize == 0 {
+		align := uint64(8)
+		if !packed {
+			align *= uint64(d.alignof(member.Type))
+		}
+		return alignUp(end, align)
+	}
+
+	// A bitfield moves to the next storage unit of its type if it
+	// doesn't fit into the current one.
+	size, err := Sizeof(member.Type)
+	if err != nil || packed {
+		return end
+	}
+
+	unit := uint64(size) * 8
+	if end/unit != (end+uint64(member.BitfieldSize)-1)/unit {
+		return alignUp(end, unit)
+	}
+	return end
+}
+
+// writePadding emits unnamed bitfields covering the bits between from
+// and to.
+//
+// Unnamed bitfields don't influence the alignment of a struct.
+func writePadding(buf *strings.Builder, indent string, from, to uint64) {
+	units := []struct {
+		typ  string
+		bits uint64
+	}{
+		{"long", 64},
+		{"int", 32},
+		{"short", 16},
+		{"char", 8},
+	}
+
+	for from < to {
+		var (
+			typ  = "char"
+			bits = to - from
+		)
+
+		if rem := from % 8; rem != 0 || bits < 8 {
+			// Fill up the current byte.
+			if bits > 8-rem {
+				bits = 8 - rem
+			}
+		} else {
+			for _, unit := range units {
+				if from%unit.bits == 0 && bits >= unit.bits {
+					typ, bits = unit.typ, unit.bits
+					break
+				}
+			}
+		}
+
+		fmt.Fprintf(buf, "%s%s: %d;\n", indent, typ, bits)
+		from += bits
+	}
+}
+
+func (d *cDumper) enum(e *Enum, name string, depth int) string {
+	var (
+		buf    strings.Builder
+		indent = strings.Repeat("\t", depth+1)
+	)
+
+	buf.WriteString("enum ")
+	if name != "" {
+		buf.WriteString(name + " ")
+	}
+	buf.WriteString("{\n")
+
+	for _, value := range e.Values {
+		name := d.claim(identNamespace, string(value.Name))
+		switch {
+		case e.Signed:
+			fmt.Fprintf(&buf, "%s%s = %d,\n", indent, name, int64(value.Value))
+		case value.Value > math.MaxInt64:
+			fmt.Fprintf(&buf, "%s%s = %dULL,\n", indent, name, value.Value)
+		default:
+			fmt.Fprintf(&buf, "%s%s = %d,\n", indent, name, value.Value)
+		}
+	}
+
+	buf.WriteString(indent[:depth] + "}")
+
+	// Compilers use the size of an int for enums, unless values
+	// don't fit.
+	switch e.Size {
+	case 1:
+		buf.WriteString(" __attribute__((mode(QI)))")
+	case 2:
+		buf.WriteString(" __attribute__((mode(HI)))")
+	case 8:
+		buf.WriteString(" __attribute__((mode(DI)))")
+	}
+	return buf.String()
+}
+
+// alignof returns the natural alignment of a type in bytes.
+func (d *cDumper) alignof(typ Type) uint32 {
+	if align, ok := d.aligns[typ]; ok {
+		return align
+	}
+
+	// Guard against malformed types which contain themselves.
+	d.aligns[typ] = 1
+
+	var align uint32 = 1
+	switch v := typ.(type) {
+	case *Int:
+		align = v.Size
+	case *Enum:
+		align = v.Size
+	case *Float:
+		align = v.Size
+	case *Pointer:
+		align = 8
+	case *Array:
+		align = d.alignof(v.Type)
+	case *Struct:
+		align = d.compositeAlign(v, v.Members, v.Size)
+	case *Union:
+		align = d.compositeAlign(v, v.Members, v.Size)
+	case *Typedef:
+		align = d.alignof(v.Type)
+	case *Const:
+		align = d.alignof(v.Type)
+	case *Volatile:
+		align = d.alignof(v.Type)
+	case *Restrict:
+		align = d.alignof(v.Type)
+	case *TypeTag:
+		align = d.alignof(v.Type)
+	}
+
+	if align == 0 || align > 16 {
+		align = 1
+	}
+
+	d.aligns[typ] = align
+	return align
+}
+
+func (d *cDumper) compositeAlign(typ Type, members []Member, size uint32) uint32 {
+	if d.isPacked(typ, members, size) {
+		return 1
+	}
+	return d.naturalAlign(members)
+}
+
+func (d *cDumper) naturalAlign(members []Member) uint32 {
+	var align uint32 = 1
+	for _, member := range members {
+		if a := d.alignof(member.Type); a > align {
+			align = a
+		}
+	}
+	return align
+}
+
+// isPacked returns true if a struct or union can't be laid out
+// with natural alignment.
+func (d *cDumper) isPacked(typ Type, members []Member, size uint32) bool {
+	if packed, ok := d.packed[typ]; ok {
+		return packed
+	}
+
+	d.packed[typ] = false
+
+	align := d.naturalAlign(members)
+	packed := size%align != 0
+	for _, member := range members {
+		if member.BitfieldSize > 0 {
+			continue
+		}
+
+		if member.Offset%(d.alignof(member.Type)*8) != 0 {
+			packed = true
+		}
+	}
+
+	d.packed[typ] = packed
+	return packed
+}
+
+func join(base, declarator string) string {
+	if declarator == "" {
+		return base
+	}
+	return strings.TrimRight(base+" "+declarator, " ")
+}
+
+func alignUp(n, align uint64) uint64 {
+	if align == 0 {
+		return n
+	}
+	return (n + align - 1) / align * align
+}
+
+func skipTypeTags(typ Type) Type {
+	for i := 0; i < maxTypeDepth; i++ {
+		tag, ok := typ.(*TypeTag)
+		if !ok {
+			break
+		}
+		typ = tag.Type
+	}
+	return typ
+}
+
+// skipModifiers skips qualifiers and type tags, but not typedefs.
+func skipModifiers(typ Type) Type {
+	for i := 0; i < maxTypeDepth; i++ {
+		switch v := typ.(type) {
+		case *Const:
+			typ = v.Type
+		case *Volatile:
+			typ = v.Type
+		case *Restrict:
+			typ = v.Type
+		case *TypeTag:
+			typ = v.Type
+		default:
+			return typ
+		}
+	}
+	return typ
+}
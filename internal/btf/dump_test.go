@@ -0,0 +1,118 @@
+package btf
+
+import (
+	"testing"
+)
+
+func TestDumpC(t *testing.T) {
+	u8 := &Int{Name: "unsigned char", Size: 1}
+	u32 := &Int{Name: "unsigned int", Size: 4}
+	u64 := &Int{Name: "long long unsigned int", Size: 8}
+	u32t := &Typedef{Name: "u32", Type: u32}
+
+	node := &Struct{Name: "node", Size: 24}
+	node.Members = []Member{
+		{Name: "next", Type: &Pointer{Target: node}, Offset: 0},
+		{Name: "flags", Type: u8, Offset: 64},
+		{Name: "value", Type: u64, Offset: 128},
+	}
+
+	color := &Enum{Name: "color", Size: 4, Values: []EnumValue{
+		{"RED", 0},
+		{"GREEN", 1},
+	}}
+
+	callback := &FuncProto{
+		Return: Void{},
+		Params: []FuncParam{
+			{Name: "arg", Type: &Pointer{Target: &Const{Type: Void{}}}},
+			{Type: Void{}},
+		},
+	}
+
+	packet := &Struct{Name: "packet", Size: 24, Members: []Member{
+		{Name: "len", Type: u32t, Offset: 0},
+		{Name: "kind", Type: u32, Offset: 32, BitfieldSize: 4},
+		{Name: "col", Type: color, Offset: 64},
+		{Name: "cb", Type: &Pointer{Target: callback}, Offset: 128},
+	}}
+
+	have, err := DumpC(node, packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `struct node;
+
+struct node {
+	struct node *next;
+	unsigned char flags;
+	long long unsigned int value;
+};
+
+typedef unsigned int u32;
+
+enum color {
+	RED = 0,
+	GREEN = 1,
+};
+
+struct packet {
+	u32 len;
+	unsigned int kind: 4;
+	enum color col;
+	void (*cb)(const void *arg, ...);
+};
+`
+	if have != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", have, want)
+	}
+}
+
+func TestDumpCPadding(t *testing.T) {
+	u8 := &Int{Name: "unsigned char", Size: 1}
+	s32 := &Int{Name: "int", Size: 4}
+	padded := &Struct{Name: "padded", Size: 64, Members: []Member{
+		{Name: "a", Type: s32, Offset: 0},
+		{Name: "b", Type: s32, Offset: 128},
+	}}
+	packed := &Struct{Name: "packed", Size: 5, Members: []Member{
+		{Name: "a", Type: u8, Offset: 0},
+		{Name: "b", Type: s32, Offset: 8},
+	}}
+
+	have, err := DumpC(padded, packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `struct padded {
+	int a;
+	int: 32;
+	long: 64;
+	int b;
+	int: 32;
+	long: 64;
+	long: 64;
+	long: 64;
+	long: 64;
+	long: 64;
+};
+
+struct packed {
+	unsigned char a;
+	int b;
+} __attribute__((packed));
+`
+	if have != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", have, want)
+	}
+}
+
+func TestDumpCVmlinux(t *testing.T) {
+	spec := parseVmlinux(t)
+
+	if _, err := DumpC(spec.types...); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -24,11 +24,22 @@ type btfExtHeader struct {
 	LineInfoLen uint32
 }
 
+// maxExtInfoRecordSize is the same as MAX_FUNCINFO_REC_SIZE in the
+// kernel, which also limits line infos.
+const maxExtInfoRecordSize = 252
+
 func parseExtInfos(r io.ReadSeeker, bo binary.ByteOrder, strings stringTable) (funcInfo, lineInfo map[string]extInfo, err error) {
 	const expectedMagic = 0xeB9F
 
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("can't read ext info: %v", err)
+	}
+
+	rd := bytes.NewReader(raw)
+
 	var header btfExtHeader
-	if err := binary.Read(r, bo, &header); err != nil {
+	if err := binary.Read(rd, bo, &header); err != nil {
 		return nil, nil, xerrors.Errorf("can't read header: %v", err)
 	}
 
@@ -51,25 +62,27 @@ func parseExtInfos(r io.ReadSeeker, bo binary.ByteOrder, strings stringTable) (f
 
 	// Of course, the .BTF.ext header has different semantics than the
 	// .BTF ext header. We need to ignore non-null values.
-	_, err = io.CopyN(ioutil.Discard, r, remainder)
+	_, err = io.CopyN(ioutil.Discard, rd, remainder)
 	if err != nil {
 		return nil, nil, xerrors.Errorf("header padding: %v", err)
 	}
 
-	if _, err := r.Seek(int64(header.HdrLen+header.FuncInfoOff), io.SeekStart); err != nil {
-		return nil, nil, xerrors.Errorf("can't seek to function info section: %v", err)
+	funcInfoRaw, err := subsection(raw, header.HdrLen, header.FuncInfoOff, header.FuncInfoLen)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("function info: %w", err)
 	}
 
-	funcInfo, err = parseExtInfo(io.LimitReader(r, int64(header.FuncInfoLen)), bo, strings)
+	funcInfo, err = parseExtInfo(bytes.NewReader(funcInfoRaw), bo, strings)
 	if err != nil {
 		return nil, nil, xerrors.Errorf("function info: %w", err)
 	}
 
-	if _, err := r.Seek(int64(header.HdrLen+header.LineInfoOff), io.SeekStart); err != nil {
-		return nil, nil, xerrors.Errorf("can't seek to line info section: %v", err)
+	lineInfoRaw, err := subsection(raw, header.HdrLen, header.LineInfoOff, header.LineInfoLen)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("line info: %w", err)
 	}
 
-	lineInfo, err = parseExtInfo(io.LimitReader(r, int64(header.LineInfoLen)), bo, strings)
+	lineInfo, err = parseExtInfo(bytes.NewReader(lineInfoRaw), bo, strings)
 	if err != nil {
 		return nil, nil, xerrors.Errorf("line info: %w", err)
 	}
@@ -108,6 +121,23 @@ func (ei extInfo) append(other extInfo, offset uint64) (extInfo, error) {
 	return extInfo{ei.recordSize, records}, nil
 }
 
+// slice returns the records for instructions between start and end,
+// relative to start.
+func (ei extInfo) slice(start, end uint64) extInfo {
+	var records []extInfoRecord
+	for _, info := range ei.records {
+		if info.InsnOff < start || info.InsnOff >= end {
+			continue
+		}
+
+		records = append(records, extInfoRecord{
+			InsnOff: info.InsnOff - start,
+			Opaque:  info.Opaque,
+		})
+	}
+	return extInfo{ei.recordSize, records}
+}
+
 func (ei extInfo) MarshalBinary() ([]byte, error) {
 	if len(ei.records) == 0 {
 		return nil, nil
@@ -139,6 +169,10 @@ func parseExtInfo(r io.Reader, bo binary.ByteOrder, strings stringTable) (map[st
 		return nil, xerrors.New("record size too short")
 	}
 
+	if recordSize > maxExtInfoRecordSize {
+		return nil, xerrors.Errorf("record size %d exceeds maximum of %d", recordSize, maxExtInfoRecordSize)
+	}
+
 	result := make(map[string]extInfo)
 	for {
 		var infoHeader btfExtInfoSec
@@ -0,0 +1,204 @@
+package btf
+
+import (
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// KernelField is a part of a map value which is managed by the kernel,
+// like a bpf_timer or a kptr.
+type KernelField struct {
+	// The name of the special type, or the type tag of a kptr.
+	Kind string
+	// Offset and size in bytes.
+	Offset uint32
+	Size   uint32
+}
+
+// Structs which are managed by the kernel if they are part of a map value.
+var kernelStructs = map[string]bool{
+	"bpf_spin_lock": true,
+	"bpf_timer":     true,
+	"bpf_dynptr":    true,
+	"bpf_list_head": true,
+	"bpf_list_node": true,
+	"bpf_rb_root":   true,
+	"bpf_rb_node":   true,
+	"bpf_refcount":  true,
+	"bpf_wq":        true,
+}
+
+// Type tags of pointers which are managed by the kernel.
+var kptrTags = map[string]bool{
+	"kptr":           true,
+	"kptr_untrusted": true,
+	"kptr_ref":       true,
+	"percpu_kptr":    true,
+}
+
+// KernelFields returns the parts of typ which are managed by the kernel.
+func KernelFields(typ Type) ([]KernelField, error) {
+	var fields []KernelField
+	if err := kernelFields(typ, 0, &fields, 0); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func kernelFields(typ Type, offset uint32, fields *[]KernelField, depth int) error {
+	if depth > maxTypeDepth {
+		return xerrors.New("exceeded type depth")
+	}
+
+	switch v := skipQualifiers(typ).(type) {
+	case *Struct:
+		if kernelStructs[string(v.Name)] {
+			*fields = append(*fields, KernelField{string(v.Name), offset, v.Size})
+			return nil
+		}
+
+		for _, member := range v.Members {
+			if member.Offset%8 != 0 || member.BitfieldSize > 0 {
+				// Bitfields can't contain special types.
+				continue
+			}
+
+			if err := kernelFields(member.Type, offset+member.Offset/8, fields, depth+1); err != nil {
+				return xerrors.Errorf("member %s: %w", member.Name, err)
+			}
+		}
+
+	case *Array:
+		size, err := Sizeof(v.Type)
+		if err != nil {
+			return err
+		}
+
+		n := len(*fields)
+		if err := kernelFields(v.Type, offset, fields, depth+1); err != nil {
+			return err
+		}
+		if len(*fields) == n {
+			return nil
+		}
+
+		elem := (*fields)[n:]
+		for i := uint32(1); i < v.Nelems; i++ {
+			for _, field := range elem {
+				field.Offset += i * uint32(size)
+				*fields = append(*fields, field)
+			}
+		}
+
+	case *Pointer:
+		// The tags are part of the pointer's target: ptr -> tag -> struct.
+		for target := v.Target; ; {
+			tag, ok := target.(*TypeTag)
+			if !ok {
+				break
+			}
+
+			if kptrTags[tag.Value] {
+				*fields = append(*fields, KernelField{tag.Value, offset, 8})
+				break
+			}
+			target = tag.Type
+		}
+	}
+
+	return nil
+}
+
+func skipQualifiers(typ Type) Type {
+	for i := 0; i < maxTypeDepth; i++ {
+		switch v := typ.(type) {
+		case *Typedef:
+			typ = v.Type
+		case *Volatile:
+			typ = v.Type
+		case *Const:
+			typ = v.Type
+		case *Restrict:
+			typ = v.Type
+		case *TypeTag:
+			typ = v.Type
+		default:
+			return typ
+		}
+	}
+	return typ
+}
+
+// FieldOffset returns the offset and type of a member of a struct or
+// union. Members of nested types are separated by dots, for example
+// "stats.last_seen". Anonymous structs and unions are searched as if
+// their members were part of the enclosing type.
+//
+// Returns an error wrapping ErrNotFound if there is no such member, and
+// an error if it is a bitfield.
+func FieldOffset(typ Type, path string) (uint32, Type, error) {
+	var offset uint32
+	for _, name := range strings.Split(path, ".") {
+		off, member, err := findMember(typ, name, 0)
+		if err != nil {
+			return 0, nil, xerrors.Errorf("field %s: %w", path, err)
+		}
+
+		if member.BitfieldSize > 0 || off%8 != 0 {
+			return 0, nil, xerrors.Errorf("field %s is a bitfield", path)
+		}
+
+		offset += off / 8
+		typ = member.Type
+	}
+
+	return offset, typ, nil
+}
+
+// findMember returns the offset in bits and the member called name.
+func findMember(typ Type, name string, depth int) (uint32, *Member, error) {
+	if depth > maxTypeDepth {
+		return 0, nil, xerrors.New("exceeded type depth")
+	}
+
+	var members []Member
+	switch v := skipQualifiers(typ).(type) {
+	case *Struct:
+		members = v.Members
+	case *Union:
+		members = v.Members
+	default:
+		return 0, nil, xerrors.Errorf("%s isn't a struct or union", typ)
+	}
+
+	for i := range members {
+		if string(members[i].Name) == name {
+			return members[i].Offset, &members[i], nil
+		}
+	}
+
+	for _, member := range members {
+		if member.Name != "" {
+			continue
+		}
+
+		switch skipQualifiers(member.Type).(type) {
+		case *Struct, *Union:
+		default:
+			// Unnamed bitfields used as padding.
+			continue
+		}
+
+		off, found, err := findMember(member.Type, name, depth+1)
+		if xerrors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		return member.Offset + off, found, nil
+	}
+
+	return 0, nil, xerrors.Errorf("member %s: %w", name, ErrNotFound)
+}
@@ -0,0 +1,116 @@
+package btf
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestKernelFields(t *testing.T) {
+	u64 := &Int{Size: 8}
+	timer := &Struct{Name: "bpf_timer", Size: 16}
+	lock := &Struct{Name: "bpf_spin_lock", Size: 4}
+	task := &Struct{Name: "task_struct"}
+
+	// struct {
+	//     __u64 a;
+	//     struct bpf_timer t;
+	//     struct task_struct __kptr *p;
+	//     struct bpf_spin_lock locks[2];
+	// }
+	value := &Typedef{
+		Type: &Struct{
+			Size: 40,
+			Members: []Member{
+				{Name: "a", Type: u64, Offset: 0},
+				{Name: "t", Type: timer, Offset: 64},
+				{Name: "p", Type: &Pointer{Target: &TypeTag{Type: task, Value: "kptr"}}, Offset: 192},
+				{Name: "locks", Type: &Array{Type: &Volatile{Type: lock}, Nelems: 2}, Offset: 256},
+			},
+		},
+	}
+
+	fields, err := KernelFields(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []KernelField{
+		{"bpf_timer", 8, 16},
+		{"kptr", 24, 8},
+		{"bpf_spin_lock", 32, 4},
+		{"bpf_spin_lock", 36, 4},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("Expected %v, got %v", want, fields)
+	}
+
+	fields, err = KernelFields(u64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 0 {
+		t.Error("Integer has kernel fields:", fields)
+	}
+}
+
+func TestFieldOffset(t *testing.T) {
+	u32 := &Int{Size: 4}
+	u64 := &Int{Size: 8}
+
+	// struct {
+	//     __u32 flags:4;
+	//     __u32 :28;
+	//     union {
+	//         __u32 a;
+	//         __u64 b;
+	//     };
+	//     struct { __u64 last_seen; } stats;
+	// }
+	value := &Typedef{
+		Type: &Struct{
+			Size: 24,
+			Members: []Member{
+				{Name: "flags", Type: u32, Offset: 0, BitfieldSize: 4},
+				{Type: u32, Offset: 4, BitfieldSize: 28},
+				{Type: &Union{Size: 8, Members: []Member{
+					{Name: "a", Type: u32},
+					{Name: "b", Type: u64},
+				}}, Offset: 64},
+				{Name: "stats", Type: &Const{Type: &Struct{Size: 8, Members: []Member{
+					{Name: "last_seen", Type: u64},
+				}}}, Offset: 128},
+			},
+		},
+	}
+
+	for path, want := range map[string]uint32{
+		"b":               8,
+		"stats":           16,
+		"stats.last_seen": 16,
+	} {
+		offset, _, err := FieldOffset(value, path)
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+		} else if offset != want {
+			t.Errorf("%s: got offset %d, want %d", path, offset, want)
+		}
+	}
+
+	if _, typ, _ := FieldOffset(value, "stats.last_seen"); typ != u64 {
+		t.Error("Wrong type for stats.last_seen:", typ)
+	}
+
+	if _, _, err := FieldOffset(value, "missing"); !xerrors.Is(err, ErrNotFound) {
+		t.Error("Expected ErrNotFound for missing member, got", err)
+	}
+
+	if _, _, err := FieldOffset(value, "flags"); err == nil {
+		t.Error("Bitfield is accepted")
+	}
+
+	if _, _, err := FieldOffset(value, "b.c"); err == nil {
+		t.Error("Member of an integer is accepted")
+	}
+}
@@ -0,0 +1,316 @@
+package btf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Value is raw data in the layout described by a Type.
+//
+// It implements fmt.Formatter: %v renders the value on a single line,
+// %+v spreads structs, unions and datasecs over multiple lines. Member
+// names are shown, enums are shown by name and arrays of char which
+// contain a string are shown as a string.
+//
+// If Type is nil, the bytes are shown in hex. If Data can't be
+// interpreted as Type, the bytes are shown in hex followed by the error.
+type Value struct {
+	Type Type
+	Data []byte
+}
+
+var _ fmt.Formatter = Value{}
+
+// Format implements fmt.Formatter.
+func (v Value) Format(f fmt.State, verb rune) {
+	if verb != 'v' && verb != 's' {
+		fmt.Fprintf(f, "%%!%c(btf.Value=%x)", verb, v.Data)
+		return
+	}
+
+	if v.Type == nil {
+		fmt.Fprintf(f, "%x", v.Data)
+		return
+	}
+
+	vf := valueFormatter{multiline: f.Flag('+')}
+	if err := vf.value(v.Type, v.Data, 0); err != nil {
+		fmt.Fprintf(f, "%x (%s)", v.Data, err)
+		return
+	}
+
+	f.Write(vf.buf.Bytes())
+}
+
+func (v Value) String() string {
+	return fmt.Sprint(v)
+}
+
+type valueFormatter struct {
+	buf       bytes.Buffer
+	multiline bool
+}
+
+func (vf *valueFormatter) value(typ Type, data []byte, depth int) error {
+	if depth > maxTypeDepth {
+		return xerrors.New("exceeded type depth")
+	}
+
+	typ = skipQualifiers(typ)
+
+	switch v := typ.(type) {
+	case *Int:
+		if len(data) < int(v.Size) {
+			return xerrors.Errorf("%s: data too short", v.Name)
+		}
+
+		if v.Size > 8 {
+			vf.buf.WriteString(wideIntHex(data[:v.Size]))
+			return nil
+		}
+
+		value, err := readUint(data, v.Size)
+		if err != nil {
+			return xerrors.Errorf("%s: %w", v.Name, err)
+		}
+
+		vf.int(v, value, v.Size*8)
+		return nil
+
+	case *Pointer:
+		value, err := readUint(data, 8)
+		if err != nil {
+			return xerrors.Errorf("pointer: %w", err)
+		}
+
+		fmt.Fprintf(&vf.buf, "%#x", value)
+		return nil
+
+	case *Enum:
+		value, err := readUint(data, v.Size)
+		if err != nil {
+			return xerrors.Errorf("enum %s: %w", v.Name, err)
+		}
+
+		vf.enum(v, value, v.Size*8)
+		return nil
+
+	case *Float:
+		value, err := readUint(data, v.Size)
+		if err != nil {
+			return xerrors.Errorf("float %s: %w", v.Name, err)
+		}
+
+		switch v.Size {
+		case 4:
+			vf.buf.WriteString(strconv.FormatFloat(float64(math.Float32frombits(uint32(value))), 'g', -1, 32))
+		case 8:
+			vf.buf.WriteString(strconv.FormatFloat(math.Float64frombits(value), 'g', -1, 64))
+		default:
+			return xerrors.Errorf("float %s: unsupported size %d", v.Name, v.Size)
+		}
+		return nil
+
+	case *Array:
+		return vf.array(v, data, depth)
+
+	case *Struct:
+		return vf.composite(v.Members, data, depth)
+
+	case *Union:
+		return vf.composite(v.Members, data, depth)
+
+	case *Datasec:
+		vf.open()
+		for i, vsi := range v.Vars {
+			if uint64(vsi.Offset)+uint64(vsi.Size) > uint64(len(data)) {
+				return xerrors.Errorf("datasec %s: data too short", v.Name)
+			}
+
+			vf.field(i == 0, typeName(vsi.Type), depth)
+			if err := vf.value(vsi.Type, data[vsi.Offset:vsi.Offset+vsi.Size], depth+1); err != nil {
+				return err
+			}
+		}
+		vf.close(len(v.Vars) == 0, depth)
+		return nil
+
+	case *Var:
+		return vf.value(v.Type, data, depth)
+
+	default:
+		return xerrors.Errorf("can't format %T", typ)
+	}
+}
+
+func (vf *valueFormatter) int(i *Int, value uint64, bits uint32) {
+	switch {
+	case i.Encoding&Bool != 0:
+		vf.buf.WriteString(strconv.FormatBool(value != 0))
+	case i.Encoding&Char != 0 && bits == 8 && value >= 0x20 && value < 0x7f:
+		vf.buf.WriteString(strconv.QuoteRune(rune(value)))
+	case i.Encoding&Signed != 0:
+		fmt.Fprintf(&vf.buf, "%d", signExtend(value, bits))
+	default:
+		fmt.Fprintf(&vf.buf, "%d", value)
+	}
+}
+
+func (vf *valueFormatter) enum(e *Enum, value uint64, bits uint32) {
+	if name, ok := enumValueName(e, value, bits); ok {
+		vf.buf.WriteString(name)
+		return
+	}
+
+	if e.Signed {
+		fmt.Fprintf(&vf.buf, "%d", signExtend(value, bits))
+	} else {
+		fmt.Fprintf(&vf.buf, "%d", value)
+	}
+}
+
+// enumValueName returns the name of a value of e which is bits wide.
+func enumValueName(e *Enum, value uint64, bits uint32) (string, bool) {
+	if e.Signed {
+		// Values of signed enums are stored sign extended to 64 bits.
+		value = uint64(signExtend(value, bits))
+	}
+
+	for _, ev := range e.Values {
+		if ev.Value == value {
+			return string(ev.Name), true
+		}
+	}
+	return "", false
+}
+
+func (vf *valueFormatter) array(arr *Array, data []byte, depth int) error {
+	size, err := Sizeof(arr.Type)
+	if err != nil {
+		return xerrors.Errorf("array: %w", err)
+	}
+
+	if uint64(size)*uint64(arr.Nelems) > uint64(len(data)) {
+		return xerrors.New("array: data too short")
+	}
+
+	if elem, ok := skipQualifiers(arr.Type).(*Int); ok && size == 1 && isCharInt(elem) {
+		if str, ok := cString(data[:arr.Nelems]); ok {
+			vf.buf.WriteString(strconv.Quote(str))
+			return nil
+		}
+	}
+
+	vf.buf.WriteByte('[')
+	for i := 0; i < int(arr.Nelems); i++ {
+		if i > 0 {
+			vf.buf.WriteString(", ")
+		}
+
+		if err := vf.value(arr.Type, data[i*size:(i+1)*size], depth+1); err != nil {
+			return err
+		}
+	}
+	vf.buf.WriteByte(']')
+	return nil
+}
+
+func (vf *valueFormatter) composite(members []Member, data []byte, depth int) error {
+	vf.open()
+	first := true
+	if err := vf.members(members, data, 0, &first, depth); err != nil {
+		return err
+	}
+	vf.close(first, depth)
+	return nil
+}
+
+func (vf *valueFormatter) members(members []Member, data []byte, base uint64, first *bool, depth int) error {
+	for _, member := range members {
+		offset := base + uint64(member.Offset)
+
+		if member.Name == "" {
+			switch v := skipQualifiers(member.Type).(type) {
+			case *Struct:
+				if err := vf.members(v.Members, data, offset, first, depth); err != nil {
+					return err
+				}
+			case *Union:
+				if err := vf.members(v.Members, data, offset, first, depth); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		vf.field(*first, string(member.Name), depth)
+		*first = false
+
+		if member.BitfieldSize > 0 {
+			value, err := readBitfield(data, offset, member.BitfieldSize)
+			if err != nil {
+				return xerrors.Errorf("member %s: %w", member.Name, err)
+			}
+
+			switch v := skipQualifiers(member.Type).(type) {
+			case *Int:
+				vf.int(v, value, member.BitfieldSize)
+			case *Enum:
+				vf.enum(v, value, member.BitfieldSize)
+			default:
+				fmt.Fprintf(&vf.buf, "%d", value)
+			}
+			continue
+		}
+
+		if offset%8 != 0 {
+			return xerrors.Errorf("member %s: unaligned offset %d", member.Name, offset)
+		}
+
+		size, err := Sizeof(member.Type)
+		if err != nil {
+			return xerrors.Errorf("member %s: %w", member.Name, err)
+		}
+
+		start := offset / 8
+		if start+uint64(size) > uint64(len(data)) {
+			return xerrors.Errorf("member %s: data too short", member.Name)
+		}
+
+		if err := vf.value(member.Type, data[start:start+uint64(size)], depth+1); err != nil {
+			return xerrors.Errorf("member %s: %w", member.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (vf *valueFormatter) open() {
+	vf.buf.WriteByte('{')
+}
+
+func (vf *valueFormatter) field(first bool, name string, depth int) {
+	switch {
+	case vf.multiline:
+		vf.buf.WriteByte('\n')
+		vf.buf.WriteString(strings.Repeat("\t", depth+1))
+	case !first:
+		vf.buf.WriteString(", ")
+	}
+
+	vf.buf.WriteString(name)
+	vf.buf.WriteString(": ")
+}
+
+func (vf *valueFormatter) close(empty bool, depth int) {
+	if vf.multiline && !empty {
+		vf.buf.WriteByte('\n')
+		vf.buf.WriteString(strings.Repeat("\t", depth))
+	}
+	vf.buf.WriteByte('}')
+}
@@ -0,0 +1,80 @@
+package btf
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+func TestValueFormat(t *testing.T) {
+	u32 := &Int{Name: "u32", Size: 4}
+	color := &Enum{Name: "color", Size: 4, Values: []EnumValue{
+		{"RED", 0},
+		{"GREEN", 1},
+	}}
+
+	inner := &Struct{Name: "inner", Size: 4, Members: []Member{
+		{Name: "col", Type: color},
+	}}
+
+	value := &Struct{Name: "value", Size: 16, Members: []Member{
+		{Name: "count", Type: u32, Offset: 0},
+		{Name: "comm", Type: &Array{Type: &Int{Name: "char", Size: 1, Encoding: Signed | Char}, Nelems: 4}, Offset: 32},
+		{Name: "inner", Type: inner, Offset: 64},
+		{Name: "flag", Type: u32, Offset: 96, BitfieldSize: 1},
+	}}
+
+	data := make([]byte, 16)
+	internal.NativeEndian.PutUint32(data[0:], 42)
+	copy(data[4:], "foo\x00")
+	internal.NativeEndian.PutUint32(data[8:], 1)
+	data[12] = 1
+
+	v := Value{value, data}
+
+	have := fmt.Sprintf("%v", v)
+	want := `{count: 42, comm: "foo", inner: {col: GREEN}, flag: 1}`
+	if have != want {
+		t.Errorf("%%v: have %s\nwant %s", have, want)
+	}
+
+	have = fmt.Sprintf("%+v", v)
+	want = "{\n\tcount: 42\n\tcomm: \"foo\"\n\tinner: {\n\t\tcol: GREEN\n\t}\n\tflag: 1\n}"
+	if have != want {
+		t.Errorf("%%+v: have %s\nwant %s", have, want)
+	}
+
+	have = fmt.Sprint(Value{value, data[:4]})
+	if have[:8] != fmt.Sprintf("%x", data[:4]) {
+		t.Error("Invalid data isn't shown in hex:", have)
+	}
+}
+
+func TestValueFormatSignedEnum(t *testing.T) {
+	fault := int64(-14)
+	errno := &Enum{Name: "errno", Size: 4, Signed: true, Values: []EnumValue{
+		{"ERR_FAULT", uint64(fault)},
+		{"OK", 0},
+	}}
+
+	data := make([]byte, 4)
+	internal.NativeEndian.PutUint32(data, uint32(0xfffffff2))
+
+	if have := fmt.Sprint(Value{errno, data}); have != "ERR_FAULT" {
+		t.Errorf("Expected ERR_FAULT, got %s", have)
+	}
+
+	have, err := DumpJSON(errno, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(have) != `"ERR_FAULT"` {
+		t.Errorf("Expected \"ERR_FAULT\", got %s", have)
+	}
+
+	internal.NativeEndian.PutUint32(data, uint32(0xfffffffe))
+	if have := fmt.Sprint(Value{errno, data}); have != "-2" {
+		t.Errorf("Expected -2, got %s", have)
+	}
+}
@@ -0,0 +1,55 @@
+// +build gofuzz
+
+// Use with https://github.com/dvyukov/go-fuzz
+
+package btf
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+// FuzzSpec is an entry point for go-fuzz, which parses raw BTF.
+func FuzzSpec(data []byte) int {
+	if len(data) < binary.Size(btfHeader{}) {
+		return -1
+	}
+
+	spec, err := loadRawSpec(bytes.NewReader(data), internal.NativeEndian)
+	if err != nil {
+		if spec != nil {
+			panic("spec is not nil")
+		}
+		return 0
+	}
+	if spec == nil {
+		panic("spec is nil")
+	}
+	return 1
+}
+
+// FuzzExtInfo is an entry point for go-fuzz, which parses raw BTF.ext.
+//
+// The first byte of data is used as the length of the string table.
+func FuzzExtInfo(data []byte) int {
+	if len(data) < 1 || len(data)-1 < int(data[0]) {
+		return -1
+	}
+
+	table, err := readStringTable(bytes.NewReader(data[1 : 1+data[0]]))
+	if err != nil {
+		return -1
+	}
+
+	info := data[1+data[0]:]
+	funcInfo, lineInfo, err := parseExtInfos(bytes.NewReader(info), internal.NativeEndian, table)
+	if err != nil {
+		if funcInfo != nil || lineInfo != nil {
+			panic("info is not nil")
+		}
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,373 @@
+package btf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// DumpJSON formats data, which is in the layout described by typ,
+// as JSON similar to "bpftool map dump -j".
+//
+// Structs, unions and datasecs become objects keyed by member name,
+// with the members of anonymous structs and unions inlined. Enums
+// become the name of their value if it is known. Arrays of char that
+// contain a string become a string.
+func DumpJSON(typ Type, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := dumpJSON(&buf, typ, data, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func dumpJSON(buf *bytes.Buffer, typ Type, data []byte, depth int) error {
+	if depth > maxTypeDepth {
+		return xerrors.New("exceeded type depth")
+	}
+
+	typ = skipQualifiers(typ)
+
+	switch v := typ.(type) {
+	case *Int:
+		if len(data) < int(v.Size) {
+			return xerrors.Errorf("%s: data too short", typeName(v))
+		}
+		return dumpIntJSON(buf, v, data[:v.Size])
+
+	case *Pointer:
+		if len(data) < 8 {
+			return xerrors.New("pointer: data too short")
+		}
+		fmt.Fprintf(buf, "%d", internal.NativeEndian.Uint64(data))
+		return nil
+
+	case *Enum:
+		value, err := readUint(data, v.Size)
+		if err != nil {
+			return xerrors.Errorf("enum %s: %w", v.Name, err)
+		}
+
+		if name, ok := enumValueName(v, value, v.Size*8); ok {
+			return writeJSONString(buf, name)
+		}
+
+		if v.Signed {
+			fmt.Fprintf(buf, "%d", signExtend(value, v.Size*8))
+		} else {
+			fmt.Fprintf(buf, "%d", value)
+		}
+		return nil
+
+	case *Float:
+		value, err := readUint(data, v.Size)
+		if err != nil {
+			return xerrors.Errorf("float %s: %w", v.Name, err)
+		}
+
+		var f float64
+		switch v.Size {
+		case 4:
+			f = float64(math.Float32frombits(uint32(value)))
+		case 8:
+			f = math.Float64frombits(value)
+		default:
+			return xerrors.Errorf("float %s: unsupported size %d", v.Name, v.Size)
+		}
+
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return writeJSONString(buf, strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		return nil
+
+	case *Array:
+		return dumpArrayJSON(buf, v, data, depth)
+
+	case *Struct:
+		return dumpMembersJSON(buf, v.Members, data, depth)
+
+	case *Union:
+		return dumpMembersJSON(buf, v.Members, data, depth)
+
+	case *Datasec:
+		buf.WriteByte('{')
+		for i, vsi := range v.Vars {
+			if uint64(vsi.Offset)+uint64(vsi.Size) > uint64(len(data)) {
+				return xerrors.Errorf("datasec %s: data too short", v.Name)
+			}
+
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONString(buf, typeName(vsi.Type)); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+
+			if err := dumpJSON(buf, vsi.Type, data[vsi.Offset:vsi.Offset+vsi.Size], depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+
+	case *Var:
+		return dumpJSON(buf, v.Type, data, depth+1)
+
+	default:
+		return xerrors.Errorf("can't format %T as JSON", typ)
+	}
+}
+
+func dumpIntJSON(buf *bytes.Buffer, i *Int, data []byte) error {
+	if i.Size > 8 {
+		// Integers larger than 64 bits don't fit into a JSON number,
+		// use a hex string like bpftool.
+		return writeJSONString(buf, wideIntHex(data))
+	}
+
+	value, err := readUint(data, i.Size)
+	if err != nil {
+		return xerrors.Errorf("%s: %w", i.Name, err)
+	}
+
+	return writeIntJSON(buf, i, value, i.Size*8)
+}
+
+func writeIntJSON(buf *bytes.Buffer, i *Int, value uint64, bits uint32) error {
+	switch {
+	case i.Encoding&Bool != 0:
+		buf.WriteString(strconv.FormatBool(value != 0))
+	case i.Encoding&Char != 0 && bits == 8 && value >= 0x20 && value < 0x7f:
+		return writeJSONString(buf, string(rune(value)))
+	case i.Encoding&Signed != 0:
+		fmt.Fprintf(buf, "%d", signExtend(value, bits))
+	default:
+		fmt.Fprintf(buf, "%d", value)
+	}
+	return nil
+}
+
+func dumpArrayJSON(buf *bytes.Buffer, arr *Array, data []byte, depth int) error {
+	size, err := Sizeof(arr.Type)
+	if err != nil {
+		return xerrors.Errorf("array: %w", err)
+	}
+
+	if uint64(size)*uint64(arr.Nelems) > uint64(len(data)) {
+		return xerrors.New("array: data too short")
+	}
+
+	if elem, ok := skipQualifiers(arr.Type).(*Int); ok && size == 1 && isCharInt(elem) {
+		if str, ok := cString(data[:arr.Nelems]); ok {
+			return writeJSONString(buf, str)
+		}
+	}
+
+	buf.WriteByte('[')
+	for i := 0; i < int(arr.Nelems); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		if err := dumpJSON(buf, arr.Type, data[i*size:(i+1)*size], depth+1); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+func isCharInt(i *Int) bool {
+	return i.Encoding&Char != 0 || i.Name == "char"
+}
+
+// cString returns the NUL terminated string at the start of data, if
+// it only contains printable characters.
+func cString(data []byte) (string, bool) {
+	end := bytes.IndexByte(data, 0)
+	if end == -1 {
+		return "", false
+	}
+
+	for _, c := range data[:end] {
+		if c < 0x20 || c >= 0x7f {
+			return "", false
+		}
+	}
+
+	return string(data[:end]), true
+}
+
+func dumpMembersJSON(buf *bytes.Buffer, members []Member, data []byte, depth int) error {
+	buf.WriteByte('{')
+	first := true
+	err := dumpMembersJSONInline(buf, members, data, 0, &first, depth)
+	buf.WriteByte('}')
+	return err
+}
+
+func dumpMembersJSONInline(buf *bytes.Buffer, members []Member, data []byte, base uint64, first *bool, depth int) error {
+	for _, member := range members {
+		offset := base + uint64(member.Offset)
+
+		if member.Name == "" {
+			switch v := skipQualifiers(member.Type).(type) {
+			case *Struct:
+				if err := dumpMembersJSONInline(buf, v.Members, data, offset, first, depth+1); err != nil {
+					return err
+				}
+			case *Union:
+				if err := dumpMembersJSONInline(buf, v.Members, data, offset, first, depth+1); err != nil {
+					return err
+				}
+			}
+
+			// Other anonymous members are padding.
+			continue
+		}
+
+		if !*first {
+			buf.WriteByte(',')
+		}
+		*first = false
+
+		if err := writeJSONString(buf, string(member.Name)); err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+
+		if member.BitfieldSize > 0 {
+			if err := dumpBitfieldJSON(buf, member, offset, data); err != nil {
+				return xerrors.Errorf("member %s: %w", member.Name, err)
+			}
+			continue
+		}
+
+		if offset%8 != 0 {
+			return xerrors.Errorf("member %s: unaligned offset %d", member.Name, offset)
+		}
+
+		size, err := Sizeof(member.Type)
+		if err != nil {
+			return xerrors.Errorf("member %s: %w", member.Name, err)
+		}
+
+		start := offset / 8
+		if start+uint64(size) > uint64(len(data)) {
+			return xerrors.Errorf("member %s: data too short", member.Name)
+		}
+
+		if err := dumpJSON(buf, member.Type, data[start:start+uint64(size)], depth+1); err != nil {
+			return xerrors.Errorf("member %s: %w", member.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func dumpBitfieldJSON(buf *bytes.Buffer, member Member, offset uint64, data []byte) error {
+	value, err := readBitfield(data, offset, member.BitfieldSize)
+	if err != nil {
+		return err
+	}
+
+	switch v := skipQualifiers(member.Type).(type) {
+	case *Int:
+		return writeIntJSON(buf, v, value, member.BitfieldSize)
+	case *Enum:
+		if name, ok := enumValueName(v, value, member.BitfieldSize); ok {
+			return writeJSONString(buf, name)
+		}
+	}
+
+	fmt.Fprintf(buf, "%d", value)
+	return nil
+}
+
+// readBitfield returns the value of a bitfield at offset bits into data.
+func readBitfield(data []byte, offset uint64, size uint32) (uint64, error) {
+	bits := uint64(size)
+	if bits > 64 {
+		return 0, xerrors.Errorf("bitfield of %d bits is too large", bits)
+	}
+
+	// Load the bytes covering the bitfield into an integer.
+	start, end := offset/8, (offset+bits+7)/8
+	if end > uint64(len(data)) {
+		return 0, xerrors.New("data too short")
+	}
+	if end-start > 8 {
+		return 0, xerrors.New("bitfield spans more than eight bytes")
+	}
+
+	var raw [8]byte
+	n := end - start
+	var value uint64
+	if internal.NativeEndian == binary.LittleEndian {
+		copy(raw[:], data[start:end])
+		value = binary.LittleEndian.Uint64(raw[:]) >> (offset % 8)
+	} else {
+		copy(raw[8-n:], data[start:end])
+		value = binary.BigEndian.Uint64(raw[:]) >> (n*8 - offset%8 - bits)
+	}
+	if bits < 64 {
+		value &= 1<<bits - 1
+	}
+
+	return value, nil
+}
+
+// wideIntHex formats an integer of arbitrary size as hex.
+func wideIntHex(data []byte) string {
+	value := make([]byte, len(data))
+	copy(value, data)
+	if internal.NativeEndian == binary.LittleEndian {
+		for l, r := 0, len(value)-1; l < r; l, r = l+1, r-1 {
+			value[l], value[r] = value[r], value[l]
+		}
+	}
+	return "0x" + new(big.Int).SetBytes(value).Text(16)
+}
+
+func readUint(data []byte, size uint32) (uint64, error) {
+	if len(data) < int(size) {
+		return 0, xerrors.New("data too short")
+	}
+
+	switch size {
+	case 1:
+		return uint64(data[0]), nil
+	case 2:
+		return uint64(internal.NativeEndian.Uint16(data)), nil
+	case 4:
+		return uint64(internal.NativeEndian.Uint32(data)), nil
+	case 8:
+		return internal.NativeEndian.Uint64(data), nil
+	default:
+		return 0, xerrors.Errorf("unsupported size %d", size)
+	}
+}
+
+func signExtend(value uint64, bits uint32) int64 {
+	shift := 64 - bits
+	return int64(value<<shift) >> shift
+}
+
+func writeJSONString(buf *bytes.Buffer, str string) error {
+	enc, err := json.Marshal(str)
+	if err != nil {
+		return err
+	}
+	buf.Write(enc)
+	return nil
+}
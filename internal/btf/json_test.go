@@ -0,0 +1,53 @@
+package btf
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+func TestDumpJSON(t *testing.T) {
+	u32 := &Int{Name: "unsigned int", Size: 4}
+	s32 := &Int{Name: "int", Size: 4, Encoding: Signed}
+	char := &Int{Name: "char", Size: 1, Encoding: Signed | Char}
+	boolean := &Int{Name: "_Bool", Size: 1, Encoding: Bool}
+	color := &Enum{Name: "color", Size: 4, Values: []EnumValue{
+		{"RED", 0},
+		{"GREEN", 1},
+	}}
+
+	value := &Struct{Name: "value", Size: 24, Members: []Member{
+		{Name: "count", Type: &Typedef{Name: "u32", Type: u32}, Offset: 0},
+		{Name: "delta", Type: &Const{Type: s32}, Offset: 32},
+		{Name: "comm", Type: &Array{Type: char, Nelems: 4}, Offset: 64},
+		{Name: "ok", Type: boolean, Offset: 96},
+		{Name: "kind", Type: u32, Offset: 104, BitfieldSize: 3},
+		{Type: &Union{Size: 4, Members: []Member{
+			{Name: "col", Type: color},
+		}}, Offset: 128},
+		{Name: "raw", Type: &Array{Type: u32, Nelems: 1}, Offset: 160},
+	}}
+
+	data := make([]byte, 24)
+	internal.NativeEndian.PutUint32(data[0:], 42)
+	internal.NativeEndian.PutUint32(data[4:], uint32(0xffffffff))
+	copy(data[8:], "foo\x00")
+	data[12] = 1
+	internal.NativeEndian.PutUint16(data[13:], 5)
+	internal.NativeEndian.PutUint32(data[16:], 1)
+	internal.NativeEndian.PutUint32(data[20:], 7)
+
+	have, err := DumpJSON(value, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"count":42,"delta":-1,"comm":"foo","ok":true,"kind":5,"col":"GREEN","raw":[7]}`
+	if string(have) != want {
+		t.Errorf("Have %s\nwant %s", have, want)
+	}
+
+	if _, err := DumpJSON(value, data[:8]); err == nil {
+		t.Error("DumpJSON accepts short data")
+	}
+}
@@ -0,0 +1,130 @@
+package btf
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// Line is the source code location of an instruction, as described by
+// BTF line info.
+type Line struct {
+	spec       *Spec
+	fileName   string
+	line       string
+	lineNumber uint32
+	lineColumn uint32
+	raw        bpfLineInfo
+}
+
+// FileName returns the name of the source file.
+func (li *Line) FileName() string {
+	return li.fileName
+}
+
+// Line returns the source code of the line.
+func (li *Line) Line() string {
+	return li.line
+}
+
+// LineNumber returns the line number in the source file.
+func (li *Line) LineNumber() uint32 {
+	return li.lineNumber
+}
+
+// LineColumn returns the column in the source line.
+func (li *Line) LineColumn() uint32 {
+	return li.lineColumn
+}
+
+func (li *Line) String() string {
+	return li.line
+}
+
+type bpfLineInfo struct {
+	InsnOff     uint32
+	FileNameOff uint32
+	LineOff     uint32
+	LineCol     uint32
+}
+
+// ProgramLines returns the source lines of a program, keyed by
+// the offset of the first raw instruction they belong to.
+//
+// This is a free function instead of a method to hide it from users
+// of package ebpf.
+func ProgramLines(s *Program) (map[uint64]*Line, error) {
+	lines := make(map[uint64]*Line, len(s.lineInfos.records))
+	for _, record := range s.lineInfos.records {
+		var raw bpfLineInfo
+		if len(record.Opaque) < binary.Size(raw)-4 {
+			return nil, xerrors.Errorf("line info record at offset %d is too short", record.InsnOff)
+		}
+
+		// Records are kept in the format expected by the kernel, minus the
+		// instruction offset.
+		fields := make([]uint32, 3)
+		if err := binary.Read(bytes.NewReader(record.Opaque), internal.NativeEndian, fields); err != nil {
+			return nil, xerrors.Errorf("can't read line info: %w", err)
+		}
+
+		raw.FileNameOff, raw.LineOff, raw.LineCol = fields[0], fields[1], fields[2]
+
+		fileName, err := s.spec.strings.Lookup(raw.FileNameOff)
+		if err != nil {
+			return nil, xerrors.Errorf("line info file name: %w", err)
+		}
+
+		line, err := s.spec.strings.Lookup(raw.LineOff)
+		if err != nil {
+			return nil, xerrors.Errorf("line info source: %w", err)
+		}
+
+		insnOff := record.InsnOff / asm.InstructionSize
+		lines[insnOff] = &Line{
+			s.spec,
+			fileName,
+			line,
+			raw.LineCol >> 10,
+			raw.LineCol & 0x3ff,
+			raw,
+		}
+	}
+
+	return lines, nil
+}
+
+// ProgramLineInfos returns the binary form of BTF line infos for insns.
+//
+// Line infos are taken from the Source of each instruction, which allows
+// modifying insns without invalidating them. All lines must originate
+// from the same Spec as s.
+//
+// This is a free function instead of a method to hide it from users
+// of package ebpf.
+func ProgramLineInfos(s *Program, insns asm.Instructions) (recordSize uint32, lineInfos []byte, err error) {
+	var buf bytes.Buffer
+	iter := insns.Iterate()
+	for iter.Next() {
+		li, ok := iter.Ins.Source().(*Line)
+		if !ok {
+			continue
+		}
+
+		if li.spec != s.spec {
+			return 0, nil, xerrors.Errorf("instruction %d: line info from different BTF", iter.Index)
+		}
+
+		raw := li.raw
+		raw.InsnOff = uint32(iter.Offset)
+		if err := binary.Write(&buf, internal.NativeEndian, &raw); err != nil {
+			return 0, nil, xerrors.Errorf("can't write line info: %v", err)
+		}
+	}
+
+	return uint32(binary.Size(bpfLineInfo{})), buf.Bytes(), nil
+}
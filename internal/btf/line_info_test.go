@@ -0,0 +1,79 @@
+package btf
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+)
+
+func TestProgramLineInfos(t *testing.T) {
+	fh, err := os.Open("../../testdata/loader-clang-9.elf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+
+	spec, err := LoadSpecFromReader(fh)
+	if err != nil {
+		t.Fatal("Can't load BTF:", err)
+	}
+
+	records := spec.lineInfos["xdp"].records
+	if len(records) == 0 {
+		t.Fatal("No line info for the xdp section")
+	}
+	length := records[len(records)-1].InsnOff + asm.InstructionSize
+
+	prog, err := spec.Program("xdp", length)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := ProgramLines(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lines) != len(records) {
+		t.Fatalf("Expected %d lines, got %d", len(records), len(lines))
+	}
+
+	insns := make(asm.Instructions, length/asm.InstructionSize)
+	for i := range insns {
+		insns[i] = asm.Mov.Imm(asm.R0, 0)
+		if line, ok := lines[uint64(i)]; ok {
+			insns[i] = insns[i].WithSource(line)
+		}
+	}
+
+	recSize, have, err := ProgramLineInfos(prog, insns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := prog.lineInfos.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recSize != prog.lineInfos.recordSize {
+		t.Errorf("Expected record size %d, got %d", prog.lineInfos.recordSize, recSize)
+	}
+
+	if !bytes.Equal(have, want) {
+		t.Error("Line infos generated from instructions don't match the ELF")
+	}
+
+	// Inserting an instruction shifts all line infos.
+	insns = append(asm.Instructions{asm.Mov.Imm(asm.R0, 0)}, insns...)
+	_, shifted, err := ProgramLineInfos(prog, insns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if off := shifted[0]; off != have[0]+1 {
+		t.Errorf("Expected first line info at offset %d, got %d", have[0]+1, off)
+	}
+}
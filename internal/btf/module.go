@@ -0,0 +1,189 @@
+package btf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/sys"
+
+	"golang.org/x/xerrors"
+)
+
+// ModuleFunc is a function in the BTF of a kernel module.
+type ModuleFunc struct {
+	// Module is the name of the module containing the function.
+	Module string
+	// ID of the function. Module BTF extends the BTF of the kernel, so
+	// IDs are only unique within a module.
+	ID TypeID
+	// BTF is the module BTF, which has to be passed to the kernel
+	// together with ID.
+	BTF *internal.FD
+}
+
+// FindModuleFunc finds a function in the BTF of a loaded kernel module.
+//
+// All loaded modules are searched if module is empty. The caller must
+// close the BTF of the returned function. Returns an error wrapping
+// ErrNotFound if no module contains the function, and os.ErrNotExist if
+// the module isn't loaded.
+//
+// Requires at least Linux 5.11.
+func FindModuleFunc(module, name string) (*ModuleFunc, error) {
+	base, err := LoadKernelSpec()
+	if err != nil {
+		return nil, err
+	}
+
+	modules := []string{module}
+	if module == "" {
+		modules, err = loadedModules()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, module := range modules {
+		raw, err := readModuleBTF(module)
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := findSplitFunc(raw, base, name, internal.NativeEndian)
+		if xerrors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("module %s: %w", module, err)
+		}
+
+		fd, err := moduleBTF(module)
+		if err != nil {
+			return nil, xerrors.Errorf("module %s: %w", module, err)
+		}
+
+		return &ModuleFunc{module, id, fd}, nil
+	}
+
+	if module != "" {
+		return nil, xerrors.Errorf("function %s in module %s: %w", name, module, ErrNotFound)
+	}
+	return nil, xerrors.Errorf("function %s in kernel modules: %w", name, ErrNotFound)
+}
+
+// loadedModules returns the names of the modules which have BTF.
+func loadedModules() ([]string, error) {
+	entries, err := ioutil.ReadDir("/sys/kernel/btf")
+	if os.IsNotExist(err) {
+		return nil, xerrors.Errorf("can't list module BTF: %w", ErrNotSupported)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("can't list module BTF: %w", err)
+	}
+
+	var modules []string
+	for _, entry := range entries {
+		if entry.Name() != "vmlinux" {
+			modules = append(modules, entry.Name())
+		}
+	}
+	return modules, nil
+}
+
+func readModuleBTF(module string) ([]byte, error) {
+	if module == "vmlinux" || filepath.Base(module) != module || strings.HasPrefix(module, ".") {
+		return nil, xerrors.Errorf("invalid module name %q", module)
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Join("/sys/kernel/btf", module))
+	if os.IsNotExist(err) {
+		return nil, xerrors.Errorf("module %s isn't loaded or has no BTF: %w", module, os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("can't read BTF of module %s: %w", module, err)
+	}
+	return raw, nil
+}
+
+// findSplitFunc finds a function in BTF which extends base.
+//
+// Split BTF continues the type IDs and string offsets of its base. Its
+// string table doesn't start with an empty string.
+func findSplitFunc(raw []byte, base *Spec, name string, bo binary.ByteOrder) (TypeID, error) {
+	types, strs, err := btfSections(raw, bo)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(strs) > 0 && strs[len(strs)-1] != 0 {
+		return 0, xerrors.New("string table isn't null terminated")
+	}
+
+	rawTypes, err := readTypes(bytes.NewReader(types), bo)
+	if err != nil {
+		return 0, xerrors.Errorf("can't read types: %w", err)
+	}
+
+	lookup := func(offset uint32) (string, error) {
+		if int64(offset) < int64(len(base.strings)) {
+			return base.strings.Lookup(offset)
+		}
+		return stringTable(strs).Lookup(offset - uint32(len(base.strings)))
+	}
+
+	for i, raw := range rawTypes {
+		if raw.Kind() != kindFunc {
+			continue
+		}
+
+		fnName, err := lookup(raw.NameOff)
+		if err != nil {
+			return 0, xerrors.Errorf("name of type %d: %w", i, err)
+		}
+
+		if fnName == name {
+			// rawTypes of base starts at ID 1, since Void isn't encoded.
+			return TypeID(len(base.rawTypes) + 1 + i), nil
+		}
+	}
+
+	return 0, xerrors.Errorf("function %s: %w", name, ErrNotFound)
+}
+
+// moduleBTF returns the BTF which the kernel has loaded for a module.
+func moduleBTF(module string) (*internal.FD, error) {
+	var nameBuf [64]byte
+
+	for id := uint32(0); ; {
+		attr := sys.GetIDAttr{StartID: id}
+		if err := sys.BTFGetNextID(&attr); err != nil {
+			return nil, xerrors.Errorf("can't find BTF of module %s: %w", module, err)
+		}
+		id = attr.NextID
+
+		fd, err := sys.BTFGetFDByID(&sys.GetIDAttr{StartID: id})
+		if err != nil {
+			// The BTF has been unloaded in the meantime.
+			continue
+		}
+
+		info := sys.BTFInfo{
+			Name:    sys.NewSlicePointer(nameBuf[:]),
+			NameLen: uint32(len(nameBuf)),
+		}
+		if err := btfInfoByFD(fd, &info); err != nil {
+			fd.Close()
+			return nil, xerrors.Errorf("BTF %d: %w", id, err)
+		}
+
+		if info.KernelBTF != 0 && internal.CString(nameBuf[:]) == module {
+			return fd, nil
+		}
+		fd.Close()
+	}
+}
@@ -0,0 +1,53 @@
+package btf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestFindSplitFunc(t *testing.T) {
+	base := parseVmlinux(t)
+
+	strs := []byte("helper\x00mod_fn\x00")
+	baseLen := uint32(len(base.strings))
+
+	var types bytes.Buffer
+	for _, nameOff := range []uint32{baseLen, baseLen + 7} {
+		raw := rawType{btfType: btfType{NameOff: nameOff}}
+		raw.SetKind(kindFunc)
+		if err := raw.Marshal(&types, binary.LittleEndian); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	header := btfHeader{
+		Magic:     btfMagic,
+		Version:   1,
+		HdrLen:    uint32(binary.Size(btfHeader{})),
+		TypeLen:   uint32(types.Len()),
+		StringOff: uint32(types.Len()),
+		StringLen: uint32(len(strs)),
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &header)
+	buf.Write(types.Bytes())
+	buf.Write(strs)
+
+	id, err := findSplitFunc(buf.Bytes(), base, "mod_fn", binary.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := TypeID(len(base.rawTypes) + 2); id != want {
+		t.Errorf("Expected ID %d, got %d", want, id)
+	}
+
+	_, err = findSplitFunc(buf.Bytes(), base, "missing", binary.LittleEndian)
+	if !xerrors.Is(err, ErrNotFound) {
+		t.Error("Missing function doesn't return ErrNotFound:", err)
+	}
+}
@@ -0,0 +1,183 @@
+package btf
+
+import (
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/sys"
+
+	"golang.org/x/xerrors"
+)
+
+// kernelFeatures lists which parts of BTF the kernel understands,
+// beyond what haveBTF requires.
+type kernelFeatures struct {
+	float       bool
+	declTag     bool
+	typeTag     bool
+	enum64      bool
+	funcLinkage bool
+}
+
+func probeKernelFeatures() kernelFeatures {
+	return kernelFeatures{
+		float:       haveFloat() == nil,
+		declTag:     haveDeclTag() == nil,
+		typeTag:     haveTypeTag() == nil,
+		enum64:      haveEnum64() == nil,
+		funcLinkage: haveFuncLinkage() == nil,
+	}
+}
+
+// sanitizeTypes replaces kinds which the kernel doesn't understand with
+// ones that it does.
+//
+// Type IDs and sizes are preserved, so the result can be used in place of
+// the original types. Types which aren't changed are shared with the input.
+func sanitizeTypes(types []rawType, features kernelFeatures) []rawType {
+	var sanitized []rawType
+	replace := func(i int, raw rawType) {
+		if sanitized == nil {
+			sanitized = make([]rawType, len(types))
+			copy(sanitized, types)
+		}
+		sanitized[i] = raw
+	}
+
+	for i, raw := range types {
+		switch raw.Kind() {
+		case kindFloat:
+			if features.float {
+				continue
+			}
+
+			// A struct without members has the same size and name.
+			replacement := rawType{btfType{NameOff: raw.NameOff, SizeType: raw.SizeType}, []btfMember{}}
+			replacement.SetKind(kindStruct)
+			replace(i, replacement)
+
+		case kindDeclTag:
+			if features.declTag {
+				continue
+			}
+
+			// Nothing refers to a decl tag, so any type works.
+			bits := uint32(8)
+			replacement := rawType{btfType{SizeType: 1}, &bits}
+			replacement.SetKind(kindInt)
+			replace(i, replacement)
+
+		case kindTypeTag:
+			if features.typeTag {
+				continue
+			}
+
+			replacement := rawType{btfType{SizeType: raw.SizeType}, nil}
+			replacement.SetKind(kindConst)
+			replace(i, replacement)
+
+		case kindEnum64:
+			if features.enum64 {
+				continue
+			}
+
+			// Members of enums can't be expressed without enum64,
+			// so retain only the name and size.
+			replacement := rawType{btfType{NameOff: raw.NameOff, SizeType: raw.SizeType}, []btfMember{}}
+			replacement.SetKind(kindUnion)
+			replace(i, replacement)
+
+		case kindEnum:
+			if features.enum64 || !raw.KindFlag() {
+				continue
+			}
+
+			// Signed enums were added together with enum64.
+			raw.SetKindFlag(false)
+			replace(i, raw)
+
+		case kindFunc:
+			if features.funcLinkage || raw.Vlen() == 0 {
+				continue
+			}
+
+			// Treat all functions as static.
+			raw.SetVlen(0)
+			replace(i, raw)
+		}
+	}
+
+	if sanitized == nil {
+		return types
+	}
+	return sanitized
+}
+
+// probeBTF returns false if the kernel rejects types as invalid.
+func probeBTF(types ...Type) bool {
+	b := NewBuilder()
+	for _, typ := range types {
+		if _, err := b.Add(typ); err != nil {
+			return false
+		}
+	}
+
+	spec, err := b.Spec()
+	if err != nil {
+		return false
+	}
+
+	return probeSpec(spec)
+}
+
+func probeSpec(spec *Spec) bool {
+	btf, err := spec.marshal(internal.NativeEndian)
+	if err != nil {
+		return false
+	}
+
+	fd, err := sys.BTFLoad(&sys.BTFLoadAttr{
+		BTF:     sys.NewSlicePointer(btf),
+		BTFSize: uint32(len(btf)),
+	})
+	if err == nil {
+		fd.Close()
+	}
+	return !xerrors.Is(err, unix.EINVAL)
+}
+
+var haveFloat = internal.FeatureTest("BTF_KIND_FLOAT", "5.13", func() bool {
+	return probeBTF(&Float{Name: "float", Size: 4})
+})
+
+var haveDeclTag = internal.FeatureTest("BTF_KIND_DECL_TAG", "5.16", func() bool {
+	typedef := &Typedef{Name: "t", Type: &Int{Name: "int", Size: 4}}
+	return probeBTF(&DeclTag{Type: typedef, Value: "tag", Index: -1})
+})
+
+var haveTypeTag = internal.FeatureTest("BTF_KIND_TYPE_TAG", "5.17", func() bool {
+	tag := &TypeTag{Type: &Int{Name: "int", Size: 4}, Value: "tag"}
+	return probeBTF(&Pointer{Target: tag})
+})
+
+var haveEnum64 = internal.FeatureTest("BTF_KIND_ENUM64", "6.0", func() bool {
+	return probeBTF(&Enum{Name: "e", Size: 8, Values: []EnumValue{{"A", 1 << 40}}})
+})
+
+var haveFuncLinkage = internal.FeatureTest("BTF func linkage", "5.6", func() bool {
+	b := NewBuilder()
+	fn := &Func{Name: "fn", Type: &FuncProto{Return: &Int{Name: "int", Size: 4}}}
+	id, err := b.Add(fn)
+	if err != nil {
+		return false
+	}
+
+	spec, err := b.Spec()
+	if err != nil {
+		return false
+	}
+
+	// The Builder only creates static functions.
+	const btfFuncGlobal = 1
+	spec.rawTypes[id-1].SetVlen(btfFuncGlobal)
+	return probeSpec(spec)
+})
@@ -0,0 +1,85 @@
+package btf
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestHaveFloat(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveFloat)
+}
+
+func TestHaveDeclTag(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveDeclTag)
+}
+
+func TestHaveTypeTag(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveTypeTag)
+}
+
+func TestHaveEnum64(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveEnum64)
+}
+
+func TestHaveFuncLinkage(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveFuncLinkage)
+}
+
+func TestSanitizeTypes(t *testing.T) {
+	s32 := &Int{Name: "int", Size: 4, Encoding: Signed}
+	value := &Struct{Name: "value", Size: 32, Members: []Member{
+		{Name: "f", Type: &Float{Name: "double", Size: 8}},
+		{Name: "e", Type: &Enum{Name: "e", Size: 8, Values: []EnumValue{{"A", 1 << 40}}}, Offset: 64},
+		{Name: "s", Type: &Enum{Name: "s", Size: 4, Signed: true, Values: []EnumValue{{"B", 1}}}, Offset: 128},
+		{Name: "p", Type: &Pointer{Target: &TypeTag{Type: s32, Value: "tag"}}, Offset: 192},
+	}}
+	tag := &DeclTag{Type: value, Value: "tag", Index: -1}
+
+	b := NewBuilder()
+	for _, typ := range []Type{value, tag} {
+		if _, err := b.Add(typ); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	spec, err := b.Spec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if types := sanitizeTypes(spec.rawTypes, kernelFeatures{true, true, true, true, true}); &types[0] != &spec.rawTypes[0] {
+		t.Error("Types are copied even though all features are supported")
+	}
+
+	sanitized := *spec
+	sanitized.rawTypes = sanitizeTypes(spec.rawTypes, kernelFeatures{})
+	for _, raw := range sanitized.rawTypes {
+		switch raw.Kind() {
+		case kindFloat, kindDeclTag, kindTypeTag, kindEnum64:
+			t.Error("Sanitized types contain", raw.Kind())
+		case kindEnum:
+			if raw.KindFlag() {
+				t.Error("Sanitized types contain a signed enum")
+			}
+		}
+	}
+
+	types, _, err := inflateRawTypes(sanitized.rawTypes, sanitized.strings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size, err := Sizeof(types[1]); err != nil {
+		t.Fatal(err)
+	} else if size != 32 {
+		t.Error("Sanitizing changes the size of the struct to", size)
+	}
+
+	handle, err := NewHandle(&sanitized)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't load sanitized BTF:", err)
+	}
+	handle.Close()
+}
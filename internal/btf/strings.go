@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
+	"math"
+	"strings"
 
 	"golang.org/x/xerrors"
 )
@@ -58,3 +60,43 @@ func (st stringTable) LookupName(offset uint32) (Name, error) {
 	str, err := st.Lookup(offset)
 	return Name(str), err
 }
+
+// stringTableBuilder creates a stringTable, deduplicating strings.
+type stringTableBuilder struct {
+	table   []byte
+	offsets map[string]uint32
+}
+
+func newStringTableBuilder() *stringTableBuilder {
+	return &stringTableBuilder{
+		// The first string is always empty.
+		table:   []byte{0},
+		offsets: map[string]uint32{"": 0},
+	}
+}
+
+// Add a string to the table and return its offset.
+func (stb *stringTableBuilder) Add(str string) (uint32, error) {
+	if offset, ok := stb.offsets[str]; ok {
+		return offset, nil
+	}
+
+	if strings.IndexByte(str, 0) != -1 {
+		return 0, xerrors.Errorf("string %q contains a null byte", str)
+	}
+
+	if uint64(len(stb.table))+uint64(len(str)) >= math.MaxUint32 {
+		return 0, xerrors.New("string table exceeds the maximum size")
+	}
+
+	offset := uint32(len(stb.table))
+	stb.table = append(stb.table, str...)
+	stb.table = append(stb.table, 0)
+	stb.offsets[str] = offset
+	return offset, nil
+}
+
+// Table returns a copy of the string table.
+func (stb *stringTableBuilder) Table() stringTable {
+	return append(stringTable(nil), stb.table...)
+}
@@ -1,6 +1,7 @@
 package btf
 
 import (
+	"fmt"
 	"math"
 
 	"golang.org/x/xerrors"
@@ -48,9 +49,20 @@ type Int struct {
 	Name
 
 	// The size of the integer in bytes.
-	Size uint32
+	Size     uint32
+	Encoding IntEncoding
 }
 
+// IntEncoding describes how an Int is interpreted.
+type IntEncoding byte
+
+// Valid encodings of an Int.
+const (
+	Signed IntEncoding = 1 << iota
+	Char
+	Bool
+)
+
 func (i *Int) size() uint32    { return i.Size }
 func (i *Int) walk(*copyStack) {}
 func (i *Int) copy() Type {
@@ -137,27 +149,66 @@ func (u *Union) copy() Type {
 // It is not a valid Type.
 type Member struct {
 	Name
-	Type   Type
+	Type Type
+	// The offset of the member in bits.
 	Offset uint32
+	// The size of a bitfield in bits, or zero.
+	BitfieldSize uint32
 }
 
 // Enum lists possible values.
 type Enum struct {
 	TypeID
 	Name
+
+	// The size of the enum in bytes.
+	Size   uint32
+	Signed bool
+	Values []EnumValue
 }
 
-func (e *Enum) size() uint32    { return 4 }
+// EnumValue is part of an Enum.
+//
+// Values of signed enums are stored in two's complement.
+type EnumValue struct {
+	Name
+	Value uint64
+}
+
+func (e *Enum) size() uint32    { return e.Size }
 func (e *Enum) walk(*copyStack) {}
 func (e *Enum) copy() Type {
 	cpy := *e
+	cpy.Values = make([]EnumValue, len(e.Values))
+	copy(cpy.Values, e.Values)
 	return &cpy
 }
 
+// FwdKind is the type of a forward declaration.
+type FwdKind int
+
+// Valid types of forward declaration.
+const (
+	FwdStruct FwdKind = iota
+	FwdUnion
+)
+
+func (fk FwdKind) String() string {
+	switch fk {
+	case FwdStruct:
+		return "struct"
+	case FwdUnion:
+		return "union"
+	default:
+		return fmt.Sprintf("%T(%d)", fk, int(fk))
+	}
+}
+
 // Fwd is a forward declaration of a Type.
 type Fwd struct {
 	TypeID
 	Name
+	Kind FwdKind
 }
 
 func (f *Fwd) walk(*copyStack) {}
@@ -219,9 +270,20 @@ func (r *Restrict) copy() Type {
 type Func struct {
 	TypeID
 	Name
-	Type Type
+	Type    Type
+	Linkage FuncLinkage
 }
 
+// FuncLinkage describes BTF function linkage metadata.
+type FuncLinkage int
+
+// Valid function linkages.
+const (
+	StaticFunc FuncLinkage = iota
+	GlobalFunc
+	ExternFunc
+)
+
 func (f *Func) walk(cs *copyStack) { cs.push(&f.Type) }
 func (f *Func) copy() Type {
 	cpy := *f
@@ -232,15 +294,31 @@ func (f *Func) copy() Type {
 type FuncProto struct {
 	TypeID
 	Return Type
-	// Parameters not supported yet
+	// A variadic function has a last parameter without name
+	// and of type Void.
+	Params []FuncParam
+}
+
+func (fp *FuncProto) walk(cs *copyStack) {
+	cs.push(&fp.Return)
+	for i := range fp.Params {
+		cs.push(&fp.Params[i].Type)
+	}
 }
 
-func (fp *FuncProto) walk(cs *copyStack) { cs.push(&fp.Return) }
 func (fp *FuncProto) copy() Type {
 	cpy := *fp
+	cpy.Params = make([]FuncParam, len(fp.Params))
+	copy(cpy.Params, fp.Params)
 	return &cpy
 }
 
+// FuncParam is a parameter of a FuncProto.
+type FuncParam struct {
+	Name
+	Type Type
+}
+
 // Var is a global variable.
 type Var struct {
 	TypeID
@@ -277,6 +355,52 @@ func (ds *Datasec) copy() Type {
 	return &cpy
 }
 
+// Float is a float of a given length.
+type Float struct {
+	TypeID
+	Name
+
+	// The size of the float in bytes.
+	Size uint32
+}
+
+func (f *Float) size() uint32    { return f.Size }
+func (f *Float) walk(*copyStack) {}
+func (f *Float) copy() Type {
+	cpy := *f
+	return &cpy
+}
+
+// DeclTag associates metadata with a declaration.
+type DeclTag struct {
+	TypeID
+	Type  Type
+	Value string
+	// The index this tag refers to in the target type. For composite types,
+	// a value of -1 indicates that the tag refers to the whole type. Otherwise
+	// it indicates which member or argument the tag applies to.
+	Index int
+}
+
+func (dt *DeclTag) walk(cs *copyStack) { cs.push(&dt.Type) }
+func (dt *DeclTag) copy() Type {
+	cpy := *dt
+	return &cpy
+}
+
+// TypeTag associates metadata with a type.
+type TypeTag struct {
+	TypeID
+	Type  Type
+	Value string
+}
+
+func (tt *TypeTag) walk(cs *copyStack) { cs.push(&tt.Type) }
+func (tt *TypeTag) copy() Type {
+	cpy := *tt
+	return &cpy
+}
+
 // VarSecinfo describes variable in a Datasec
 type VarSecinfo struct {
 	Type   Type
@@ -295,6 +419,7 @@ var (
 	_ sizer = (*Union)(nil)
 	_ sizer = (*Enum)(nil)
 	_ sizer = (*Datasec)(nil)
+	_ sizer = (*Float)(nil)
 )
 
 // Sizeof returns the size of a type in bytes.
@@ -334,6 +459,9 @@ func Sizeof(typ Type) (int, error) {
 		case *Restrict:
 			typ = v.Type
 			continue
+		case *TypeTag:
+			typ = v.Type
+			continue
 
 		default:
 			return 0, xerrors.Errorf("unrecognized type %T", typ)
@@ -411,10 +539,10 @@ var _ namer = Name("")
 // inflateRawTypes takes a list of raw btf types linked via type IDs, and turns
 // it into a graph of Types connected via pointers.
 //
-// Returns a map of named types (so, where NameOff is non-zero). Since BTF ignores
-// compilation units, multiple types may share the same name. A Type may form a
-// cyclic graph by pointing at itself.
-func inflateRawTypes(rawTypes []rawType, rawStrings stringTable) (namedTypes map[string][]Type, err error) {
+// Returns all types indexed by their ID, and a map of named types (so, where
+// NameOff is non-zero). Since BTF ignores compilation units, multiple types may
+// share the same name. A Type may form a cyclic graph by pointing at itself.
+func inflateRawTypes(rawTypes []rawType, rawStrings stringTable) (types []Type, namedTypes map[string][]Type, err error) {
 	type fixupDef struct {
 		id           TypeID
 		expectedKind btfKind
@@ -426,7 +554,7 @@ func inflateRawTypes(rawTypes []rawType, rawStrings stringTable) (namedTypes map
 		fixups = append(fixups, fixupDef{id, expectedKind, typ})
 	}
 
-	convertMembers := func(raw []btfMember) ([]Member, error) {
+	convertMembers := func(raw []btfMember, kindFlag bool) ([]Member, error) {
 		// NB: The fixup below relies on pre-allocating this array to
 		// work, since otherwise append might re-allocate members.
 		members := make([]Member, 0, len(raw))
@@ -435,10 +563,16 @@ func inflateRawTypes(rawTypes []rawType, rawStrings stringTable) (namedTypes map
 			if err != nil {
 				return nil, xerrors.Errorf("can't get name for member %d: %w", i, err)
 			}
-			members = append(members, Member{
+			member := Member{
 				Name:   name,
 				Offset: btfMember.Offset,
-			})
+			}
+			if kindFlag {
+				// The offset encodes the size of bitfields.
+				member.BitfieldSize = btfMember.Offset >> 24
+				member.Offset &= 0xffffff
+			}
+			members = append(members, member)
 		}
 		for i := range members {
 			fixup(raw[i].Type, kindUnknown, &members[i].Type)
@@ -446,7 +580,7 @@ func inflateRawTypes(rawTypes []rawType, rawStrings stringTable) (namedTypes map
 		return members, nil
 	}
 
-	types := make([]Type, 0, len(rawTypes))
+	types = make([]Type, 0, len(rawTypes)+1)
 	types = append(types, Void{})
 	namedTypes = make(map[string][]Type)
 
@@ -460,12 +594,17 @@ func inflateRawTypes(rawTypes []rawType, rawStrings stringTable) (namedTypes map
 
 		name, err := rawStrings.LookupName(raw.NameOff)
 		if err != nil {
-			return nil, xerrors.Errorf("can't get name for type id %d: %w", id, err)
+			return nil, nil, xerrors.Errorf("can't get name for type id %d: %w", id, err)
 		}
 
 		switch raw.Kind() {
 		case kindInt:
-			typ = &Int{id, name, raw.Size()}
+			data, ok := raw.data.(*uint32)
+			if !ok {
+				return nil, nil, xerrors.Errorf("int %s (id %d): missing encoding", name, id)
+			}
+			encoding := IntEncoding((*data >> btfIntEncodingShift) & btfIntEncodingMask)
+			typ = &Int{id, name, raw.Size(), encoding}
 
 		case kindPointer:
 			ptr := &Pointer{id, nil}
@@ -482,24 +621,55 @@ func inflateRawTypes(rawTypes []rawType, rawStrings stringTable) (namedTypes map
 			typ = arr
 
 		case kindStruct:
-			members, err := convertMembers(raw.data.([]btfMember))
+			members, err := convertMembers(raw.data.([]btfMember), raw.KindFlag())
 			if err != nil {
-				return nil, xerrors.Errorf("struct %s (id %d): %w", name, id, err)
+				return nil, nil, xerrors.Errorf("struct %s (id %d): %w", name, id, err)
 			}
 			typ = &Struct{id, name, raw.Size(), members}
 
 		case kindUnion:
-			members, err := convertMembers(raw.data.([]btfMember))
+			members, err := convertMembers(raw.data.([]btfMember), raw.KindFlag())
 			if err != nil {
-				return nil, xerrors.Errorf("union %s (id %d): %w", name, id, err)
+				return nil, nil, xerrors.Errorf("union %s (id %d): %w", name, id, err)
 			}
 			typ = &Union{id, name, raw.Size(), members}
 
 		case kindEnum:
-			typ = &Enum{id, name}
+			rawValues := raw.data.([]btfEnum)
+			values := make([]EnumValue, 0, len(rawValues))
+			for i, btfVal := range rawValues {
+				valueName, err := rawStrings.LookupName(btfVal.NameOff)
+				if err != nil {
+					return nil, nil, xerrors.Errorf("enum %s (id %d): can't get name for value %d: %w", name, id, i, err)
+				}
+
+				// Only sign extend values of signed enums.
+				value := uint64(uint32(btfVal.Val))
+				if raw.KindFlag() {
+					value = uint64(int64(btfVal.Val))
+				}
+				values = append(values, EnumValue{valueName, value})
+			}
+			typ = &Enum{id, name, raw.Size(), raw.KindFlag(), values}
+
+		case kindEnum64:
+			rawValues := raw.data.([]btfEnum64)
+			values := make([]EnumValue, 0, len(rawValues))
+			for i, btfVal := range rawValues {
+				valueName, err := rawStrings.LookupName(btfVal.NameOff)
+				if err != nil {
+					return nil, nil, xerrors.Errorf("enum %s (id %d): can't get name for value %d: %w", name, id, i, err)
+				}
+				values = append(values, EnumValue{valueName, uint64(btfVal.ValHi32)<<32 | uint64(btfVal.ValLo32)})
+			}
+			typ = &Enum{id, name, raw.Size(), raw.KindFlag(), values}
 
 		case kindForward:
-			typ = &Fwd{id, name}
+			kind := FwdStruct
+			if raw.KindFlag() {
+				kind = FwdUnion
+			}
+			typ = &Fwd{id, name, kind}
 
 		case kindTypedef:
 			typedef := &Typedef{id, name, nil}
@@ -522,12 +692,25 @@ func inflateRawTypes(rawTypes []rawType, rawStrings stringTable) (namedTypes map
 			typ = restrict
 
 		case kindFunc:
-			fn := &Func{id, name, nil}
+			fn := &Func{id, name, nil, FuncLinkage(raw.Vlen())}
 			fixup(raw.Type(), kindFuncProto, &fn.Type)
 			typ = fn
 
 		case kindFuncProto:
-			fp := &FuncProto{id, nil}
+			rawParams := raw.data.([]btfParam)
+			params := make([]FuncParam, 0, len(rawParams))
+			for i, param := range rawParams {
+				paramName, err := rawStrings.LookupName(param.NameOff)
+				if err != nil {
+					return nil, nil, xerrors.Errorf("func proto (id %d): can't get name for parameter %d: %w", id, i, err)
+				}
+				params = append(params, FuncParam{Name: paramName})
+			}
+			for i := range params {
+				fixup(rawParams[i].Type, kindUnknown, &params[i].Type)
+			}
+
+			fp := &FuncProto{id, nil, params}
 			fixup(raw.Type(), kindUnknown, &fp.Return)
 			typ = fp
 
@@ -550,8 +733,22 @@ func inflateRawTypes(rawTypes []rawType, rawStrings stringTable) (namedTypes map
 			}
 			typ = &Datasec{id, name, raw.SizeType, vars}
 
+		case kindFloat:
+			typ = &Float{id, name, raw.Size()}
+
+		case kindDeclTag:
+			btfIndex := raw.data.(*btfDeclTag).ComponentIdx
+			dt := &DeclTag{id, nil, string(name), int(btfIndex)}
+			fixup(raw.Type(), kindUnknown, &dt.Type)
+			typ = dt
+
+		case kindTypeTag:
+			tt := &TypeTag{id, nil, string(name)}
+			fixup(raw.Type(), kindUnknown, &tt.Type)
+			typ = tt
+
 		default:
-			return nil, xerrors.Errorf("type id %d: unknown kind: %v", id, raw.Kind())
+			return nil, nil, xerrors.Errorf("type id %d: unknown kind: %v", id, raw.Kind())
 		}
 
 		types = append(types, typ)
@@ -566,7 +763,7 @@ func inflateRawTypes(rawTypes []rawType, rawStrings stringTable) (namedTypes map
 	for _, fixup := range fixups {
 		i := int(fixup.id)
 		if i >= len(types) {
-			return nil, xerrors.Errorf("reference to invalid type id: %d", fixup.id)
+			return nil, nil, xerrors.Errorf("reference to invalid type id: %d", fixup.id)
 		}
 
 		// Default void (id 0) to unknown
@@ -576,11 +773,11 @@ func inflateRawTypes(rawTypes []rawType, rawStrings stringTable) (namedTypes map
 		}
 
 		if expected := fixup.expectedKind; expected != kindUnknown && rawKind != expected {
-			return nil, xerrors.Errorf("expected type id %d to have kind %s, found %s", fixup.id, expected, rawKind)
+			return nil, nil, xerrors.Errorf("expected type id %d to have kind %s, found %s", fixup.id, expected, rawKind)
 		}
 
 		*fixup.typ = types[i]
 	}
 
-	return namedTypes, nil
+	return types, namedTypes, nil
 }
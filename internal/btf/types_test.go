@@ -10,9 +10,9 @@ func TestSizeof(t *testing.T) {
 		typ  Type
 	}{
 		{1, &Int{Size: 1}},
-		{4, &Enum{}},
+		{4, &Enum{Size: 4}},
 		{0, &Array{Type: &Pointer{Target: Void{}}, Nelems: 0}},
-		{12, &Array{Type: &Enum{}, Nelems: 3}},
+		{12, &Array{Type: &Enum{Size: 4}, Nelems: 3}},
 	}
 
 	for _, tc := range testcases {
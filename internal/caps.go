@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// EffectiveCapabilities returns the effective capability set of the
+// current process as a bit mask.
+func EffectiveCapabilities() (uint64, error) {
+	status, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+
+		caps, err := strconv.ParseUint(strings.TrimSpace(line[len("CapEff:"):]), 16, 64)
+		if err != nil {
+			return 0, xerrors.Errorf("can't parse CapEff: %w", err)
+		}
+		return caps, nil
+	}
+
+	return 0, xerrors.New("no CapEff in /proc/self/status")
+}
+
+// UnprivilegedBPFDisabled returns the value of the
+// kernel.unprivileged_bpf_disabled sysctl.
+//
+// Zero means that unprivileged users may use the bpf syscall.
+func UnprivilegedBPFDisabled() (int, error) {
+	value, err := ioutil.ReadFile("/proc/sys/kernel/unprivileged_bpf_disabled")
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(value)))
+}
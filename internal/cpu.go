@@ -2,7 +2,10 @@ package internal
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 
 	"golang.org/x/xerrors"
@@ -40,6 +43,48 @@ func OnlineCPUs() (int, error) {
 	return onlineCPU.num, onlineCPU.err
 }
 
+// OnlineCPUList returns the numbers of the currently online CPUs, which
+// may have gaps if CPUs were taken offline.
+func OnlineCPUList() ([]int, error) {
+	raw, err := ioutil.ReadFile("/sys/devices/system/cpu/online")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCPUList(strings.TrimSpace(string(raw)))
+}
+
+// parseCPUList parses a list of CPUs in the format of
+// /sys/devices/system/cpu/online, for example "0-3,5,7-8".
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	for _, span := range strings.Split(list, ",") {
+		bounds := strings.SplitN(span, "-", 2)
+		low, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, xerrors.Errorf("invalid CPU list %q: %w", list, err)
+		}
+
+		high := low
+		if len(bounds) == 2 {
+			high, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return nil, xerrors.Errorf("invalid CPU list %q: %w", list, err)
+			}
+		}
+
+		if low < 0 || high < low {
+			return nil, xerrors.Errorf("invalid CPU list %q", list)
+		}
+
+		for cpu := low; cpu <= high; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	return cpus, nil
+}
+
 // parseCPUs parses the number of cpus from sysfs,
 // in the format of "/sys/devices/system/cpu/{possible,online,..}.
 // Logical CPU numbers must be of the form 0-n
@@ -3,6 +3,7 @@ package internal
 import (
 	"io"
 	"io/ioutil"
+	"reflect"
 	"testing"
 )
 
@@ -29,3 +30,24 @@ func TestParseCPUs(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCPUList(t *testing.T) {
+	for str, result := range map[string][]int{
+		"0":         {0},
+		"0-3":       {0, 1, 2, 3},
+		"0-1,3,5-6": {0, 1, 3, 5, 6},
+	} {
+		cpus, err := parseCPUList(str)
+		if err != nil {
+			t.Error("Can't parse", str, err)
+		} else if !reflect.DeepEqual(cpus, result) {
+			t.Error("Parsing", str, "returns", cpus, "instead of", result)
+		}
+	}
+
+	for _, str := range []string{"", "a", "3-1", "0-"} {
+		if _, err := parseCPUList(str); err == nil {
+			t.Errorf("Parsing %q doesn't return an error", str)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"debug/elf"
+	"io"
+	"io/ioutil"
+
+	"golang.org/x/xerrors"
+)
+
+// SafeELFFile wraps *elf.File and recovers from panics in debug/elf,
+// which can be triggered by malformed input.
+type SafeELFFile struct {
+	*elf.File
+}
+
+// NewSafeELFFile reads an ELF safely.
+//
+// Any panic during parsing is turned into an error. This is necessary
+// since there are a bunch of unfixed bugs in debug/elf.
+//
+// https://github.com/golang/go/issues?q=is%3Aissue+is%3Aopen+debug%2Felf+in%3Atitle
+func NewSafeELFFile(r io.ReaderAt) (safe *SafeELFFile, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		safe = nil
+		err = xerrors.Errorf("reading ELF file panicked: %s", r)
+	}()
+
+	file, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SafeELFFile{file}, nil
+}
+
+// Symbols is the safe version of elf.File.Symbols.
+func (se *SafeELFFile) Symbols() (syms []elf.Symbol, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		syms = nil
+		err = xerrors.Errorf("reading ELF symbols panicked: %s", r)
+	}()
+
+	if sec := se.SectionByType(elf.SHT_SYMTAB); sec != nil {
+		if _, err := OpenSection(sec); err != nil {
+			return nil, err
+		}
+	}
+
+	syms, err = se.File.Symbols()
+	return
+}
+
+// OpenSection returns a reader for the contents of a section.
+//
+// Sections which don't have contents in the file and compressed sections
+// are rejected, since their size isn't bounded by the size of the file.
+// This prevents untrusted input from causing large allocations.
+func OpenSection(sec *elf.Section) (io.ReadSeeker, error) {
+	if sec.Type == elf.SHT_NOBITS {
+		return nil, xerrors.Errorf("section %s has no contents", sec.Name)
+	}
+
+	if sec.Flags&elf.SHF_COMPRESSED != 0 {
+		return nil, xerrors.Errorf("section %s is compressed", sec.Name)
+	}
+
+	return sec.Open(), nil
+}
+
+// ReadSection returns the contents of a section, see OpenSection.
+//
+// Unlike elf.Section.Data, memory is allocated as the contents are read,
+// instead of based on the size in the section header.
+func ReadSection(sec *elf.Section) ([]byte, error) {
+	r, err := OpenSection(sec)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, xerrors.Errorf("section %s: %w", sec.Name, err)
+	}
+
+	if uint64(len(data)) != sec.Size {
+		return nil, xerrors.Errorf("section %s: expected %d bytes, got %d", sec.Name, sec.Size, len(data))
+	}
+
+	return data, nil
+}
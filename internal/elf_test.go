@@ -0,0 +1,17 @@
+package internal
+
+import (
+	"debug/elf"
+	"testing"
+)
+
+func TestReadSectionWithoutContents(t *testing.T) {
+	for _, sec := range []*elf.Section{
+		{SectionHeader: elf.SectionHeader{Name: ".bss", Type: elf.SHT_NOBITS, Size: 1 << 40}},
+		{SectionHeader: elf.SectionHeader{Name: ".data", Type: elf.SHT_PROGBITS, Flags: elf.SHF_COMPRESSED}},
+	} {
+		if _, err := ReadSection(sec); err == nil {
+			t.Errorf("Section %s: no error", sec.Name)
+		}
+	}
+}
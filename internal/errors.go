@@ -23,6 +23,36 @@ func ErrorWithLog(err error, log []byte, logErr error) error {
 	return &VerifierError{err, logStr}
 }
 
+// SyscallError wraps an error returned by a syscall with a sentinel error.
+//
+// Both the sentinel and the original error, usually an errno, can be
+// tested for using errors.Is.
+func SyscallError(sentinel, err error) error {
+	return &syscallError{sentinel, err}
+}
+
+type syscallError struct {
+	sentinel, err error
+}
+
+func (se *syscallError) Error() string {
+	return fmt.Sprintf("%s: %s", se.sentinel, se.err)
+}
+
+func (se *syscallError) Is(target error) bool {
+	return target == se.sentinel
+}
+
+func (se *syscallError) Unwrap() error {
+	return se.err
+}
+
+// IsNotSupported returns true if err is an errno which indicates that
+// an operation isn't supported.
+func IsNotSupported(err error) bool {
+	return xerrors.Is(err, unix.EOPNOTSUPP) || xerrors.Is(err, unix.ENOTSUPP)
+}
+
 // VerifierError includes information from the eBPF verifier.
 type VerifierError struct {
 	cause error
@@ -37,6 +67,16 @@ func (le *VerifierError) Error() string {
 	return fmt.Sprintf("%s: %s", le.cause, le.log)
 }
 
+// Unwrap returns the underlying error.
+func (le *VerifierError) Unwrap() error {
+	return le.cause
+}
+
+// Log returns the output of the verifier, which may be empty.
+func (le *VerifierError) Log() string {
+	return le.log
+}
+
 // CString turns a NUL / zero terminated byte buffer into a string.
 func CString(in []byte) string {
 	inLen := bytes.IndexByte(in, 0)
@@ -3,8 +3,8 @@ package internal
 import (
 	"runtime"
 	"strconv"
-
-	"github.com/cilium/ebpf/internal/unix"
+	"sync"
+	"sync/atomic"
 
 	"golang.org/x/xerrors"
 )
@@ -13,51 +13,102 @@ var ErrClosedFd = xerrors.New("use of closed file descriptor")
 
 type FD struct {
 	raw int64
+	// mu prevents Close from racing with users of the raw value, which
+	// might otherwise refer to an unrelated file descriptor that reused
+	// the same number.
+	mu sync.RWMutex
 }
 
 func NewFD(value uint32) *FD {
-	fd := &FD{int64(value)}
-	runtime.SetFinalizer(fd, (*FD).Close)
+	fd := &FD{raw: int64(value)}
+	runtime.SetFinalizer(fd, (*FD).finalize)
+	trackFD(int(value))
 	return fd
 }
 
+// NewFDFromBorrowed duplicates value, which remains owned by the caller.
+func NewFDFromBorrowed(value int) (*FD, error) {
+	if value < 0 {
+		return nil, xerrors.New("invalid fd")
+	}
+
+	dup, err := dupFD(value)
+	if err != nil {
+		return nil, xerrors.Errorf("can't dup fd: %v", err)
+	}
+
+	return NewFD(uint32(dup)), nil
+}
+
 func (fd *FD) String() string {
-	return strconv.FormatInt(fd.raw, 10)
+	return strconv.FormatInt(atomic.LoadInt64(&fd.raw), 10)
 }
 
 func (fd *FD) Value() (uint32, error) {
-	if fd.raw < 0 {
+	raw := atomic.LoadInt64(&fd.raw)
+	if raw < 0 {
 		return 0, ErrClosedFd
 	}
 
-	return uint32(fd.raw), nil
+	return uint32(raw), nil
 }
 
+// Acquire returns the raw file descriptor and prevents it from being
+// closed until Release is called.
+//
+// Release must only be called if Acquire doesn't return an error.
+func (fd *FD) Acquire() (uint32, error) {
+	fd.mu.RLock()
+	raw := atomic.LoadInt64(&fd.raw)
+	if raw < 0 {
+		fd.mu.RUnlock()
+		return 0, ErrClosedFd
+	}
+
+	return uint32(raw), nil
+}
+
+// Release undoes Acquire.
+func (fd *FD) Release() {
+	fd.mu.RUnlock()
+}
+
+// Close the file descriptor.
+//
+// It is safe to call Close multiple times, also concurrently. Only
+// the first call closes the file descriptor, after waiting for all
+// users which called Acquire.
 func (fd *FD) Close() error {
-	if fd.raw < 0 {
+	if fd == nil {
 		return nil
 	}
 
-	value := int(fd.raw)
-	fd.raw = -1
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
 
-	fd.Forget()
-	return unix.Close(value)
+	value := atomic.SwapInt64(&fd.raw, -1)
+	if value < 0 {
+		return nil
+	}
+
+	runtime.SetFinalizer(fd, nil)
+	untrackFD(int(value))
+	return closeFD(int(value))
 }
 
+// Forget releases ownership of the file descriptor without closing it.
 func (fd *FD) Forget() {
+	if raw := atomic.LoadInt64(&fd.raw); raw >= 0 {
+		untrackFD(int(raw))
+	}
 	runtime.SetFinalizer(fd, nil)
 }
 
 func (fd *FD) Dup() (*FD, error) {
-	if fd.raw < 0 {
+	raw := atomic.LoadInt64(&fd.raw)
+	if raw < 0 {
 		return nil, ErrClosedFd
 	}
 
-	dup, err := unix.FcntlInt(uintptr(fd.raw), unix.F_DUPFD_CLOEXEC, 0)
-	if err != nil {
-		return nil, xerrors.Errorf("can't dup fd: %v", err)
-	}
-
-	return NewFD(uint32(dup)), nil
+	return NewFDFromBorrowed(int(raw))
 }
@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// fdTracking records where file descriptors were created, to find
+// descriptors which are never closed.
+var fdTracking struct {
+	enabled int32
+
+	sync.Mutex
+	fds map[int]*fdTrace
+}
+
+type fdTrace struct {
+	stack  []uintptr
+	pinned bool
+}
+
+// TrackedFD is a file descriptor which was created while tracking
+// was enabled and which hasn't been closed yet.
+type TrackedFD struct {
+	FD     int
+	Kind   string
+	Pinned bool
+	Stack  string
+}
+
+// TrackFDs enables or disables recording file descriptors.
+//
+// Disabling tracking forgets all recorded file descriptors.
+func TrackFDs(enable bool) {
+	fdTracking.Lock()
+	defer fdTracking.Unlock()
+
+	if enable {
+		if fdTracking.fds == nil {
+			fdTracking.fds = make(map[int]*fdTrace)
+		}
+		atomic.StoreInt32(&fdTracking.enabled, 1)
+		return
+	}
+
+	atomic.StoreInt32(&fdTracking.enabled, 0)
+	fdTracking.fds = nil
+}
+
+// TrackedFDs returns all recorded file descriptors, ordered by value.
+func TrackedFDs() []TrackedFD {
+	fdTracking.Lock()
+	defer fdTracking.Unlock()
+
+	var fds []TrackedFD
+	for fd, trace := range fdTracking.fds {
+		fds = append(fds, TrackedFD{
+			FD:     fd,
+			Kind:   fdKind(fd),
+			Pinned: trace.pinned,
+			Stack:  formatStack(trace.stack),
+		})
+	}
+
+	sort.Slice(fds, func(i, j int) bool {
+		return fds[i].FD < fds[j].FD
+	})
+	return fds
+}
+
+func trackFD(fd int) {
+	if atomic.LoadInt32(&fdTracking.enabled) == 0 {
+		return
+	}
+
+	// Skip runtime.Callers, trackFD and NewFD.
+	pcs := make([]uintptr, 32)
+	pcs = pcs[:runtime.Callers(3, pcs)]
+
+	fdTracking.Lock()
+	defer fdTracking.Unlock()
+
+	if fdTracking.fds != nil {
+		fdTracking.fds[fd] = &fdTrace{stack: pcs}
+	}
+}
+
+func untrackFD(fd int) {
+	if atomic.LoadInt32(&fdTracking.enabled) == 0 {
+		return
+	}
+
+	fdTracking.Lock()
+	defer fdTracking.Unlock()
+
+	delete(fdTracking.fds, fd)
+}
+
+func markFDPinned(fd int) {
+	if atomic.LoadInt32(&fdTracking.enabled) == 0 {
+		return
+	}
+
+	fdTracking.Lock()
+	defer fdTracking.Unlock()
+
+	if trace := fdTracking.fds[fd]; trace != nil {
+		trace.pinned = true
+	}
+}
+
+// trackedStack returns the stack trace recorded for fd, if any.
+func trackedStack(fd int) string {
+	if atomic.LoadInt32(&fdTracking.enabled) == 0 {
+		return ""
+	}
+
+	fdTracking.Lock()
+	defer fdTracking.Unlock()
+
+	if trace := fdTracking.fds[fd]; trace != nil {
+		return formatStack(trace.stack)
+	}
+	return ""
+}
+
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// fdKind returns the kind of BPF object referred to by fd, based on
+// the fields in /proc/self/fdinfo.
+func fdKind(fd int) string {
+	info, err := ioutil.ReadFile(fmt.Sprintf("/proc/self/fdinfo/%d", fd))
+	if err != nil {
+		return ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(info))
+	for scanner.Scan() {
+		switch field := strings.SplitN(scanner.Text(), ":", 2)[0]; field {
+		case "prog_type":
+			return "program"
+		case "map_type":
+			return "map"
+		case "link_type":
+			return "link"
+		case "btf_id":
+			return "btf"
+		}
+	}
+	return ""
+}
+
+// finalizerOptions control what happens to file descriptors which are
+// garbage collected without being closed.
+var finalizerOptions struct {
+	sync.Mutex
+	keepAlive bool
+	warn      func(TrackedFD)
+}
+
+// SetFinalizer configures the finalizer of FD.
+//
+// If keepAlive is true, garbage collected file descriptors are not
+// closed. warn is called for every garbage collected file descriptor
+// if it is not nil.
+func SetFinalizer(keepAlive bool, warn func(TrackedFD)) {
+	finalizerOptions.Lock()
+	defer finalizerOptions.Unlock()
+
+	finalizerOptions.keepAlive = keepAlive
+	finalizerOptions.warn = warn
+}
+
+func (fd *FD) finalize() {
+	raw := atomic.LoadInt64(&fd.raw)
+	if raw < 0 {
+		return
+	}
+
+	finalizerOptions.Lock()
+	keepAlive, warn := finalizerOptions.keepAlive, finalizerOptions.warn
+	finalizerOptions.Unlock()
+
+	if warn != nil {
+		warn(TrackedFD{
+			FD:    int(raw),
+			Kind:  fdKind(int(raw)),
+			Stack: trackedStack(int(raw)),
+		})
+	}
+
+	if keepAlive {
+		// The file descriptor stays open, and tracked, until the process
+		// exits.
+		return
+	}
+
+	fd.Close()
+}
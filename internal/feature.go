@@ -59,6 +59,48 @@ func FeatureTest(name, version string, fn func() bool) func() error {
 	}
 }
 
+// FeatureTestErr is like FeatureTest, except that fn returns an error
+// wrapping ErrNotSupported if the feature isn't available.
+//
+// Other errors, for example missing privileges, don't say anything about
+// the feature. They are returned as is, and fn is called again the next
+// time.
+func FeatureTestErr(name, version string, fn func() error) func() error {
+	v, err := NewVersion(version)
+	if err != nil {
+		return func() error { return err }
+	}
+
+	var (
+		mu     sync.Mutex
+		done   bool
+		result error
+	)
+
+	return func() error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if done {
+			return result
+		}
+
+		err := fn()
+		if err != nil && !xerrors.Is(err, ErrNotSupported) {
+			return err
+		}
+
+		done = true
+		if err != nil {
+			result = &UnsupportedFeatureError{
+				MinimumVersion: v,
+				Name:           name,
+			}
+		}
+		return result
+	}
+}
+
 // A Version in the form Major.Minor.Patch.
 type Version [3]uint16
 
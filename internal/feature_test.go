@@ -51,6 +51,32 @@ func TestFeatureTest(t *testing.T) {
 	}
 }
 
+func TestFeatureTestErr(t *testing.T) {
+	var (
+		calls  int
+		result = xerrors.New("permission denied")
+	)
+
+	fn := FeatureTestErr("foo", "1.0", func() error {
+		calls++
+		return result
+	})
+
+	if err := fn(); err != result {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	result = xerrors.Errorf("foo: %w", ErrNotSupported)
+	err := fn()
+	if _, ok := err.(*UnsupportedFeatureError); !ok {
+		t.Fatal("Result is not a *UnsupportedFeatureError:", err)
+	}
+
+	if err := fn(); err == nil || calls != 2 {
+		t.Error("Result isn't cached")
+	}
+}
+
 func TestVersion(t *testing.T) {
 	a, err := NewVersion("1.2")
 	if err != nil {
@@ -0,0 +1,29 @@
+package internal
+
+import "sync/atomic"
+
+// Logger receives debug messages, see ebpf.SetLogger.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+}
+
+// loggerHolder allows storing a nil Logger in an atomic.Value.
+type loggerHolder struct {
+	Logger
+}
+
+var logger atomic.Value
+
+// SetLogger replaces the Logger used by Debug. A nil Logger disables
+// logging.
+func SetLogger(l Logger) {
+	logger.Store(loggerHolder{l})
+}
+
+// Debug logs a message and alternating keys and values, if a Logger is
+// set.
+func Debug(msg string, args ...interface{}) {
+	if h, _ := logger.Load().(loggerHolder); h.Logger != nil {
+		h.Debug(msg, args...)
+	}
+}
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// KernelLockdown returns the active kernel lockdown mode, which is one
+// of "none", "integrity" or "confidentiality".
+//
+// Returns an error if the kernel doesn't support lockdown, or if
+// securityfs isn't mounted.
+func KernelLockdown() (string, error) {
+	data, err := ioutil.ReadFile("/sys/kernel/security/lockdown")
+	if err != nil {
+		return "", err
+	}
+
+	// The file lists all modes, with the active one in brackets:
+	// "none [integrity] confidentiality"
+	for _, mode := range strings.Fields(string(data)) {
+		if strings.HasPrefix(mode, "[") && strings.HasSuffix(mode, "]") {
+			return strings.Trim(mode, "[]"), nil
+		}
+	}
+
+	return "", xerrors.Errorf("can't parse lockdown mode %q", data)
+}
+
+// SELinuxEnforcing returns true if SELinux is enabled and enforcing its
+// policy.
+func SELinuxEnforcing() bool {
+	data, err := ioutil.ReadFile("/sys/fs/selinux/enforce")
+	if err != nil {
+		return false
+	}
+
+	return strings.TrimSpace(string(data)) == "1"
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// maxSymlinks is the number of symlinks the kernel follows while
+// resolving a path.
+const maxSymlinks = 40
+
+// ResolveInRoot returns the location of path as seen by a process whose
+// root directory is root, for example /proc/<pid>/root.
+//
+// Unlike filepath.Join, symlinks are resolved relative to root, so that
+// absolute symlinks and ".." don't escape it. Components which aren't
+// symlinks, including the ones which don't exist, are used as is.
+func ResolveInRoot(root, path string) (string, error) {
+	var (
+		resolved  = "/"
+		remaining = path
+		links     int
+	)
+
+	for remaining != "" {
+		component := remaining
+		remaining = ""
+		if i := strings.IndexByte(component, '/'); i != -1 {
+			component, remaining = component[:i], component[i+1:]
+		}
+
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, component)
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			// Not a symlink, opening the result reports other errors.
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", xerrors.Errorf("resolve %s: %w", path, unix.ELOOP)
+		}
+
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = target + "/" + remaining
+	}
+
+	return filepath.Join(root, resolved), nil
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+func TestResolveInRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "ebpf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := os.MkdirAll(filepath.Join(root, "usr", "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	links := map[string]string{
+		"lib":                "/usr/lib",
+		"usr/lib/libc.so":    "libc.so.6",
+		"usr/lib/escape":     "../../../../etc",
+		"usr/lib/loop":       "loop",
+		"usr/lib/host_abs":   "/lib/libc.so",
+		"usr/lib/dotdot_abs": "/../../lib",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for path, want := range map[string]string{
+		"/usr/lib/libc.so.6":            "/usr/lib/libc.so.6",
+		"/lib/libc.so":                  "/usr/lib/libc.so.6",
+		"/usr/lib/host_abs":             "/usr/lib/libc.so.6",
+		"/usr/lib/escape/passwd":        "/etc/passwd",
+		"/usr/lib/dotdot_abs/libc.so.6": "/usr/lib/libc.so.6",
+		"/../../usr/./lib/":             "/usr/lib",
+		"/missing/file":                 "/missing/file",
+	} {
+		have, err := ResolveInRoot(root, path)
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			continue
+		}
+		if have != filepath.Join(root, want) {
+			t.Errorf("%s: expected %s, got %s", path, filepath.Join(root, want), have)
+		}
+	}
+
+	if _, err := ResolveInRoot(root, "/lib/loop"); !xerrors.Is(err, unix.ELOOP) {
+		t.Error("Expected ELOOP for a symlink loop, got", err)
+	}
+}
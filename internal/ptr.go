@@ -28,3 +28,21 @@ func NewStringPointer(str string) Pointer {
 
 	return Pointer{ptr: unsafe.Pointer(&buf[0])}
 }
+
+// CString returns the NUL terminated string p points at, truncated to
+// max bytes.
+func (p Pointer) CString(max int) string {
+	if p.ptr == nil {
+		return ""
+	}
+
+	var buf []byte
+	for i := 0; i < max; i++ {
+		b := *(*byte)(unsafe.Pointer(uintptr(p.ptr) + uintptr(i)))
+		if b == 0 {
+			break
+		}
+		buf = append(buf, b)
+	}
+	return string(buf)
+}
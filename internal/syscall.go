@@ -2,22 +2,90 @@ package internal
 
 import (
 	"runtime"
+	"sync/atomic"
 	"unsafe"
 
-	"github.com/cilium/ebpf/internal/unix"
+	"golang.org/x/xerrors"
 )
 
+// BPF commands which are used by more than one package.
+const (
+	BPF_OBJ_PIN             = 6
+	BPF_OBJ_GET             = 7
+	BPF_OBJ_GET_INFO_BY_FD  = 15
+	BPF_RAW_TRACEPOINT_OPEN = 17
+	BPF_LINK_CREATE         = 28
+)
+
+// AuditHook is called after every invocation of BPF with a copy of the
+// attributes. Pointers contained in attr remain valid until the hook
+// returns.
+type AuditHook func(cmd int, attr []byte, r1 uintptr, err error)
+
+var auditHook atomic.Value
+
+// SetAuditHook replaces the hook called by BPF. A nil hook disables
+// auditing.
+func SetAuditHook(hook AuditHook) {
+	auditHook.Store(hook)
+}
+
 // BPF wraps SYS_BPF.
 //
 // Any pointers contained in attr must use the Pointer type from this package.
 func BPF(cmd int, attr unsafe.Pointer, size uintptr) (uintptr, error) {
-	r1, _, errNo := unix.Syscall(unix.SYS_BPF, uintptr(cmd), uintptr(attr), size)
+	r1, err := rawBPF(cmd, attr, size)
+	if hook, _ := auditHook.Load().(AuditHook); hook != nil {
+		// Passing attr to the hook would make it escape to the heap
+		// for all callers.
+		buf := make([]byte, size)
+		if attr != nil {
+			copy(buf, (*[1 << 16]byte)(attr)[:size:size])
+		}
+		hook(cmd, buf, r1, err)
+	}
 	runtime.KeepAlive(attr)
+	return r1, err
+}
+
+type bpfObjAttr struct {
+	fileName  Pointer
+	fd        uint32
+	fileFlags uint32
+}
 
-	var err error
-	if errNo != 0 {
-		err = errNo
+// BPFObjPin wraps BPF_OBJ_PIN.
+func BPFObjPin(fileName string, fd *FD) error {
+	if err := checkPinPath(fileName); err != nil {
+		return err
 	}
 
-	return r1, err
+	value, err := fd.Value()
+	if err != nil {
+		return err
+	}
+
+	attr := bpfObjAttr{
+		fileName: NewStringPointer(fileName),
+		fd:       value,
+	}
+	_, err = BPF(BPF_OBJ_PIN, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return xerrors.Errorf("pin object %s: %w", fileName, err)
+	}
+
+	markFDPinned(int(value))
+	return nil
+}
+
+// BPFObjGet wraps BPF_OBJ_GET.
+func BPFObjGet(fileName string) (*FD, error) {
+	attr := bpfObjAttr{
+		fileName: NewStringPointer(fileName),
+	}
+	ptr, err := BPF(BPF_OBJ_GET, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return nil, xerrors.Errorf("get object %s: %w", fileName, err)
+	}
+	return NewFD(uint32(ptr)), nil
 }
@@ -0,0 +1,43 @@
+//go:build linux
+// +build linux
+
+package internal
+
+import (
+	"path/filepath"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+const bpfFSType = 0xcafe4a11
+
+func rawBPF(cmd int, attr unsafe.Pointer, size uintptr) (uintptr, error) {
+	r1, _, errNo := unix.Syscall(unix.SYS_BPF, uintptr(cmd), uintptr(attr), size)
+	if errNo != 0 {
+		return r1, errNo
+	}
+	return r1, nil
+}
+
+func closeFD(fd int) error {
+	return unix.Close(fd)
+}
+
+func dupFD(fd int) (int, error) {
+	return unix.FcntlInt(uintptr(fd), unix.F_DUPFD_CLOEXEC, 0)
+}
+
+// checkPinPath returns an error if fileName isn't on a bpf filesystem.
+func checkPinPath(fileName string) error {
+	var statfs unix.Statfs_t
+	if err := unix.Statfs(filepath.Dir(fileName), &statfs); err != nil {
+		return err
+	}
+	if uint64(statfs.Type) != bpfFSType {
+		return xerrors.Errorf("%s is not on a bpf filesystem", fileName)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package internal
+
+import (
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+func rawBPF(cmd int, attr unsafe.Pointer, size uintptr) (uintptr, error) {
+	return 0, xerrors.Errorf("bpf syscall: %w", ErrNotSupported)
+}
+
+func closeFD(fd int) error {
+	return unix.Close(fd)
+}
+
+func dupFD(fd int) (int, error) {
+	return unix.FcntlInt(uintptr(fd), unix.F_DUPFD_CLOEXEC, 0)
+}
+
+func checkPinPath(fileName string) error {
+	return xerrors.Errorf("pinning: %w", ErrNotSupported)
+}
@@ -0,0 +1,80 @@
+//go:build windows
+// +build windows
+
+package internal
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// ebpf-for-windows exports a bpf() function from its user space library,
+// which mirrors the Linux syscall. File descriptors are C runtime fds.
+var (
+	ebpfAPI   = syscall.NewLazyDLL("ebpfapi.dll")
+	procBPF   = ebpfAPI.NewProc("bpf")
+	ucrt      = syscall.NewLazyDLL("ucrtbase.dll")
+	procClose = ucrt.NewProc("_close")
+	procDup   = ucrt.NewProc("_dup")
+)
+
+// Values of errno used by the C runtime on Windows, which differ from
+// the constants in package syscall.
+var crtErrnos = map[int32]syscall.Errno{
+	1:   unix.EPERM,
+	2:   unix.ENOENT,
+	3:   unix.ESRCH,
+	4:   unix.EINTR,
+	7:   unix.E2BIG,
+	9:   unix.EBADF,
+	11:  unix.EAGAIN,
+	16:  unix.EBUSY,
+	17:  unix.EEXIST,
+	22:  unix.EINVAL,
+	28:  unix.ENOSPC,
+	105: unix.ENOBUFS,
+	130: unix.EOPNOTSUPP,
+	524: unix.ENOTSUPP,
+}
+
+func rawBPF(cmd int, attr unsafe.Pointer, size uintptr) (uintptr, error) {
+	if err := procBPF.Find(); err != nil {
+		return 0, xerrors.Errorf("ebpf-for-windows is not installed: %s: %w", err, ErrNotSupported)
+	}
+
+	// bpf() returns a negative errno on failure.
+	r1, _, _ := procBPF.Call(uintptr(cmd), uintptr(attr), size)
+	if ret := int32(r1); ret < 0 {
+		if errno, ok := crtErrnos[-ret]; ok {
+			return 0, errno
+		}
+		return 0, xerrors.Errorf("bpf: errno %d", -ret)
+	}
+
+	return r1, nil
+}
+
+func closeFD(fd int) error {
+	if ret, _, _ := procClose.Call(uintptr(fd)); int32(ret) != 0 {
+		return unix.EBADF
+	}
+	return nil
+}
+
+func dupFD(fd int) (int, error) {
+	ret, _, _ := procDup.Call(uintptr(fd))
+	if int32(ret) < 0 {
+		return -1, unix.EBADF
+	}
+	return int(int32(ret)), nil
+}
+
+// checkPinPath allows any path, since ebpf-for-windows stores pins in
+// its own namespace.
+func checkPinPath(fileName string) error {
+	return nil
+}
@@ -1,38 +1,20 @@
 package testutils
 
 import (
-	"bytes"
-	"sync"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/cilium/ebpf/internal"
-	"github.com/cilium/ebpf/internal/unix"
 	"golang.org/x/xerrors"
 )
 
-var (
-	kernelVersionOnce sync.Once
-	kernelVersion     internal.Version
-)
-
 func mustKernelVersion() internal.Version {
-	kernelVersionOnce.Do(func() {
-		var uname unix.Utsname
-		err := unix.Uname(&uname)
-		if err != nil {
-			panic(err)
-		}
-
-		end := bytes.IndexByte(uname.Release[:], 0)
-		release := string(uname.Release[:end])
-
-		kernelVersion, err = internal.NewVersion(release)
-		if err != nil {
-			panic(err)
-		}
-	})
-
-	return kernelVersion
+	v, err := internal.KernelVersion()
+	if err != nil {
+		panic(err)
+	}
+	return v
 }
 
 func CheckFeatureTest(t *testing.T, fn func() error) {
@@ -55,6 +37,18 @@ func SkipIfNotSupported(tb testing.TB, err error) {
 	}
 }
 
+// SkipIfNoTracefs skips the test if tracefs isn't mounted. Unlike kernel
+// features, tracefs doesn't have a minimum kernel version.
+func SkipIfNoTracefs(tb testing.TB) {
+	for _, tracefs := range []string{"/sys/kernel/tracing", "/sys/kernel/debug/tracing"} {
+		if _, err := os.Stat(filepath.Join(tracefs, "events")); err == nil {
+			return
+		}
+	}
+
+	tb.Skip("tracefs isn't mounted")
+}
+
 func checkKernelVersion(tb testing.TB, ufe *internal.UnsupportedFeatureError) {
 	kernelVersion := mustKernelVersion()
 	if ufe.MinimumVersion.Less(kernelVersion) {
@@ -4,35 +4,111 @@ package unix
 
 import (
 	"syscall"
+	"unsafe"
 
 	linux "golang.org/x/sys/unix"
 )
 
 const (
-	ENOENT                   = linux.ENOENT
-	EAGAIN                   = linux.EAGAIN
-	ENOSPC                   = linux.ENOSPC
-	EINVAL                   = linux.EINVAL
-	EPOLLIN                  = linux.EPOLLIN
-	BPF_F_RDONLY_PROG        = linux.BPF_F_RDONLY_PROG
-	BPF_F_WRONLY_PROG        = linux.BPF_F_WRONLY_PROG
-	BPF_OBJ_NAME_LEN         = linux.BPF_OBJ_NAME_LEN
-	BPF_TAG_SIZE             = linux.BPF_TAG_SIZE
-	SYS_BPF                  = linux.SYS_BPF
-	F_DUPFD_CLOEXEC          = linux.F_DUPFD_CLOEXEC
-	EPOLL_CTL_ADD            = linux.EPOLL_CTL_ADD
-	EPOLL_CLOEXEC            = linux.EPOLL_CLOEXEC
-	O_CLOEXEC                = linux.O_CLOEXEC
-	O_NONBLOCK               = linux.O_NONBLOCK
-	PROT_READ                = linux.PROT_READ
-	PROT_WRITE               = linux.PROT_WRITE
-	MAP_SHARED               = linux.MAP_SHARED
-	PERF_TYPE_SOFTWARE       = linux.PERF_TYPE_SOFTWARE
-	PERF_COUNT_SW_BPF_OUTPUT = linux.PERF_COUNT_SW_BPF_OUTPUT
-	PerfBitWatermark         = linux.PerfBitWatermark
-	PERF_SAMPLE_RAW          = linux.PERF_SAMPLE_RAW
-	PERF_FLAG_FD_CLOEXEC     = linux.PERF_FLAG_FD_CLOEXEC
-	RLIM_INFINITY            = linux.RLIM_INFINITY
+	ENOENT                         = linux.ENOENT
+	EPERM                          = linux.EPERM
+	EACCES                         = linux.EACCES
+	EBADF                          = linux.EBADF
+	ESRCH                          = linux.ESRCH
+	EAGAIN                         = linux.EAGAIN
+	ENOSPC                         = linux.ENOSPC
+	E2BIG                          = linux.E2BIG
+	EINVAL                         = linux.EINVAL
+	EOPNOTSUPP                     = linux.EOPNOTSUPP
+	EEXIST                         = linux.EEXIST
+	ENODEV                         = linux.ENODEV
+	ELOOP                          = linux.ELOOP
+	ENOTSUPP                       = syscall.Errno(524)
+	EPOLLIN                        = linux.EPOLLIN
+	BPF_F_NO_PREALLOC              = linux.BPF_F_NO_PREALLOC
+	BPF_F_NO_COMMON_LRU            = linux.BPF_F_NO_COMMON_LRU
+	BPF_F_RDONLY_PROG              = linux.BPF_F_RDONLY_PROG
+	BPF_F_WRONLY_PROG              = linux.BPF_F_WRONLY_PROG
+	BPF_OBJ_NAME_LEN               = linux.BPF_OBJ_NAME_LEN
+	BPF_TAG_SIZE                   = linux.BPF_TAG_SIZE
+	SYS_BPF                        = linux.SYS_BPF
+	F_DUPFD_CLOEXEC                = linux.F_DUPFD_CLOEXEC
+	EPOLL_CTL_ADD                  = linux.EPOLL_CTL_ADD
+	EPOLL_CTL_DEL                  = linux.EPOLL_CTL_DEL
+	EPOLL_CLOEXEC                  = linux.EPOLL_CLOEXEC
+	O_CLOEXEC                      = linux.O_CLOEXEC
+	O_NONBLOCK                     = linux.O_NONBLOCK
+	PROT_READ                      = linux.PROT_READ
+	PROT_WRITE                     = linux.PROT_WRITE
+	MAP_SHARED                     = linux.MAP_SHARED
+	PERF_TYPE_SOFTWARE             = linux.PERF_TYPE_SOFTWARE
+	PERF_COUNT_SW_BPF_OUTPUT       = linux.PERF_COUNT_SW_BPF_OUTPUT
+	PerfBitWatermark               = linux.PerfBitWatermark
+	PERF_SAMPLE_RAW                = linux.PERF_SAMPLE_RAW
+	PERF_FLAG_FD_CLOEXEC           = linux.PERF_FLAG_FD_CLOEXEC
+	PERF_TYPE_TRACEPOINT           = linux.PERF_TYPE_TRACEPOINT
+	PERF_TYPE_BREAKPOINT           = linux.PERF_TYPE_BREAKPOINT
+	PERF_EVENT_IOC_ENABLE          = linux.PERF_EVENT_IOC_ENABLE
+	PERF_EVENT_IOC_SET_BPF         = linux.PERF_EVENT_IOC_SET_BPF
+	RLIM_INFINITY                  = linux.RLIM_INFINITY
+	RLIMIT_MEMLOCK                 = linux.RLIMIT_MEMLOCK
+	ENOBUFS                        = linux.ENOBUFS
+	EINTR                          = linux.EINTR
+	EBUSY                          = linux.EBUSY
+	ENETDOWN                       = linux.ENETDOWN
+	AF_XDP                         = linux.AF_XDP
+	AF_NETLINK                     = linux.AF_NETLINK
+	CLOCK_MONOTONIC                = linux.CLOCK_MONOTONIC
+	NETLINK_KOBJECT_UEVENT         = linux.NETLINK_KOBJECT_UEVENT
+	SOCK_RAW                       = linux.SOCK_RAW
+	SOCK_CLOEXEC                   = linux.SOCK_CLOEXEC
+	SOL_XDP                        = linux.SOL_XDP
+	SOL_SOCKET                     = linux.SOL_SOCKET
+	SO_COOKIE                      = linux.SO_COOKIE
+	AT_FDCWD                       = linux.AT_FDCWD
+	RENAME_EXCHANGE                = linux.RENAME_EXCHANGE
+	CLONE_NEWNET                   = linux.CLONE_NEWNET
+	IN_CLOEXEC                     = linux.IN_CLOEXEC
+	IN_NONBLOCK                    = linux.IN_NONBLOCK
+	IN_CREATE                      = linux.IN_CREATE
+	IN_MOVED_TO                    = linux.IN_MOVED_TO
+	IN_ONLYDIR                     = linux.IN_ONLYDIR
+	IN_ISDIR                       = linux.IN_ISDIR
+	IN_IGNORED                     = linux.IN_IGNORED
+	IN_Q_OVERFLOW                  = linux.IN_Q_OVERFLOW
+	MSG_DONTWAIT                   = linux.MSG_DONTWAIT
+	POLLIN                         = linux.POLLIN
+	MAP_PRIVATE                    = linux.MAP_PRIVATE
+	MAP_ANONYMOUS                  = linux.MAP_ANONYMOUS
+	MAP_POPULATE                   = linux.MAP_POPULATE
+	XDP_MMAP_OFFSETS               = linux.XDP_MMAP_OFFSETS
+	XDP_RX_RING                    = linux.XDP_RX_RING
+	XDP_TX_RING                    = linux.XDP_TX_RING
+	XDP_UMEM_REG                   = linux.XDP_UMEM_REG
+	XDP_UMEM_FILL_RING             = linux.XDP_UMEM_FILL_RING
+	XDP_UMEM_COMPLETION_RING       = linux.XDP_UMEM_COMPLETION_RING
+	XDP_STATISTICS                 = linux.XDP_STATISTICS
+	XDP_PGOFF_RX_RING              = linux.XDP_PGOFF_RX_RING
+	XDP_PGOFF_TX_RING              = linux.XDP_PGOFF_TX_RING
+	XDP_UMEM_PGOFF_FILL_RING       = linux.XDP_UMEM_PGOFF_FILL_RING
+	XDP_UMEM_PGOFF_COMPLETION_RING = linux.XDP_UMEM_PGOFF_COMPLETION_RING
+	XDP_COPY                       = linux.XDP_COPY
+	XDP_ZEROCOPY                   = linux.XDP_ZEROCOPY
+	XDP_USE_NEED_WAKEUP            = linux.XDP_USE_NEED_WAKEUP
+	XDP_RING_NEED_WAKEUP           = linux.XDP_RING_NEED_WAKEUP
+	XDP_PACKET_HEADROOM            = linux.XDP_PACKET_HEADROOM
+)
+
+// Flags which aren't available in golang.org/x/sys/unix yet.
+const (
+	BPF_F_SLEEPABLE            = 1 << 4
+	BPF_F_XDP_HAS_FRAGS        = 1 << 5
+	BPF_F_TEST_RUN_ON_CPU      = 1 << 0
+	BPF_F_TEST_XDP_LIVE_FRAMES = 1 << 1
+	BPF_F_KPROBE_MULTI_RETURN  = 1 << 0
+	BPF_F_UPROBE_MULTI_RETURN  = 1 << 0
+	BPF_F_REPLACE              = 1 << 2
+	PerfBitWriteBackward       = 1 << 27
 )
 
 // Statfs_t is a wrapper
@@ -41,6 +117,11 @@ type Statfs_t = linux.Statfs_t
 // Rlimit is a wrapper
 type Rlimit = linux.Rlimit
 
+// Getrlimit is a wrapper
+func Getrlimit(resource int, rlim *Rlimit) (err error) {
+	return linux.Getrlimit(resource, rlim)
+}
+
 // Setrlimit is a wrapper
 func Setrlimit(resource int, rlim *Rlimit) (err error) {
 	return linux.Setrlimit(resource, rlim)
@@ -84,6 +165,11 @@ func Eventfd(initval uint, flags int) (fd int, err error) {
 	return linux.Eventfd(initval, flags)
 }
 
+// Read is a wrapper
+func Read(fd int, p []byte) (n int, err error) {
+	return linux.Read(fd, p)
+}
+
 // Write is a wrapper
 func Write(fd int, p []byte) (n int, err error) {
 	return linux.Write(fd, p)
@@ -120,6 +206,11 @@ func PerfEventOpen(attr *PerfEventAttr, pid int, cpu int, groupFd int, flags int
 	return linux.PerfEventOpen(attr, pid, cpu, groupFd, flags)
 }
 
+// IoctlSetInt is a wrapper
+func IoctlSetInt(fd int, req uint, value int) error {
+	return linux.IoctlSetInt(fd, req, value)
+}
+
 // Utsname is a wrapper
 type Utsname = linux.Utsname
 
@@ -127,3 +218,113 @@ type Utsname = linux.Utsname
 func Uname(buf *Utsname) (err error) {
 	return linux.Uname(buf)
 }
+
+// Sockaddr is a wrapper
+type Sockaddr = linux.Sockaddr
+
+// SockaddrXDP is a wrapper
+type SockaddrXDP = linux.SockaddrXDP
+
+// SockaddrNetlink is a wrapper
+type SockaddrNetlink = linux.SockaddrNetlink
+
+// XDPUmemReg is a wrapper
+type XDPUmemReg = linux.XDPUmemReg
+
+// XDPRingOffset is a wrapper
+type XDPRingOffset = linux.XDPRingOffset
+
+// XDPMmapOffsets is a wrapper
+type XDPMmapOffsets = linux.XDPMmapOffsets
+
+// XDPStatistics is a wrapper
+type XDPStatistics = linux.XDPStatistics
+
+// Socket is a wrapper
+func Socket(domain, typ, proto int) (fd int, err error) {
+	return linux.Socket(domain, typ, proto)
+}
+
+// Bind is a wrapper
+func Bind(fd int, sa Sockaddr) (err error) {
+	return linux.Bind(fd, sa)
+}
+
+// SendmsgN is a wrapper
+func SendmsgN(fd int, p, oob []byte, to Sockaddr, flags int) (n int, err error) {
+	return linux.SendmsgN(fd, p, oob, to, flags)
+}
+
+// Recvfrom is a wrapper
+func Recvfrom(fd int, p []byte, flags int) (n int, from Sockaddr, err error) {
+	return linux.Recvfrom(fd, p, flags)
+}
+
+// SetsockoptInt is a wrapper
+func SetsockoptInt(fd, level, opt int, value int) (err error) {
+	return linux.SetsockoptInt(fd, level, opt, value)
+}
+
+// GetsockoptUint64 is a wrapper
+func GetsockoptUint64(fd, level, opt int) (value uint64, err error) {
+	return linux.GetsockoptUint64(fd, level, opt)
+}
+
+// Renameat2 is a wrapper
+func Renameat2(olddirfd int, oldpath string, newdirfd int, newpath string, flags uint) error {
+	return linux.Renameat2(olddirfd, oldpath, newdirfd, newpath, flags)
+}
+
+// Setns is a wrapper
+func Setns(fd int, nstype int) error {
+	return linux.Setns(fd, nstype)
+}
+
+// Gettid is a wrapper
+func Gettid() int {
+	return linux.Gettid()
+}
+
+// InotifyInit1 is a wrapper
+func InotifyInit1(flags int) (fd int, err error) {
+	return linux.InotifyInit1(flags)
+}
+
+// InotifyAddWatch is a wrapper
+func InotifyAddWatch(fd int, pathname string, mask uint32) (watchdesc int, err error) {
+	return linux.InotifyAddWatch(fd, pathname, mask)
+}
+
+// Setsockopt sets a socket option which doesn't have a typed wrapper.
+func Setsockopt(fd, level, opt int, value unsafe.Pointer, size uintptr) error {
+	_, _, errNo := linux.Syscall6(linux.SYS_SETSOCKOPT, uintptr(fd), uintptr(level), uintptr(opt), uintptr(value), size, 0)
+	if errNo != 0 {
+		return errNo
+	}
+	return nil
+}
+
+// Getsockopt gets a socket option which doesn't have a typed wrapper.
+func Getsockopt(fd, level, opt int, value unsafe.Pointer, size *uint32) error {
+	_, _, errNo := linux.Syscall6(linux.SYS_GETSOCKOPT, uintptr(fd), uintptr(level), uintptr(opt), uintptr(value), uintptr(unsafe.Pointer(size)), 0)
+	if errNo != 0 {
+		return errNo
+	}
+	return nil
+}
+
+// PollFd is a wrapper
+type PollFd = linux.PollFd
+
+// Poll is a wrapper
+func Poll(fds []PollFd, timeout int) (n int, err error) {
+	return linux.Poll(fds, timeout)
+}
+
+// Timespec is a wrapper
+type Timespec = linux.Timespec
+
+// ClockGettime is a wrapper
+func ClockGettime(clockid int32, time *Timespec) error {
+	return linux.ClockGettime(clockid, time)
+}
@@ -6,34 +6,111 @@ import (
 	"fmt"
 	"runtime"
 	"syscall"
+	"unsafe"
 )
 
 var errNonLinux = fmt.Errorf("unsupported platform %s/%s", runtime.GOOS, runtime.GOARCH)
 
 const (
-	ENOENT                   = syscall.ENOENT
-	EAGAIN                   = syscall.EAGAIN
-	ENOSPC                   = syscall.ENOSPC
-	EINVAL                   = syscall.EINVAL
-	BPF_F_RDONLY_PROG        = 0
-	BPF_F_WRONLY_PROG        = 0
-	BPF_OBJ_NAME_LEN         = 0x10
-	BPF_TAG_SIZE             = 0x8
-	SYS_BPF                  = 321
-	F_DUPFD_CLOEXEC          = 0x406
-	EPOLLIN                  = 0x1
-	EPOLL_CTL_ADD            = 0x1
-	EPOLL_CLOEXEC            = 0x80000
-	O_CLOEXEC                = 0x80000
-	O_NONBLOCK               = 0x800
-	PROT_READ                = 0x1
-	PROT_WRITE               = 0x2
-	MAP_SHARED               = 0x1
-	PERF_TYPE_SOFTWARE       = 0x1
-	PERF_COUNT_SW_BPF_OUTPUT = 0xa
-	PerfBitWatermark         = 0x4000
-	PERF_SAMPLE_RAW          = 0x400
-	PERF_FLAG_FD_CLOEXEC     = 0x8
+	ENOENT                         = syscall.ENOENT
+	EPERM                          = syscall.EPERM
+	EACCES                         = syscall.EACCES
+	EBADF                          = syscall.EBADF
+	ESRCH                          = syscall.ESRCH
+	EAGAIN                         = syscall.EAGAIN
+	ENOSPC                         = syscall.ENOSPC
+	E2BIG                          = syscall.E2BIG
+	EINVAL                         = syscall.EINVAL
+	EOPNOTSUPP                     = syscall.EOPNOTSUPP
+	EEXIST                         = syscall.EEXIST
+	ENODEV                         = syscall.ENODEV
+	ELOOP                          = syscall.ELOOP
+	ENOTSUPP                       = syscall.Errno(524)
+	BPF_F_NO_PREALLOC              = 0x1
+	BPF_F_NO_COMMON_LRU            = 0x2
+	BPF_F_RDONLY_PROG              = 0
+	BPF_F_WRONLY_PROG              = 0
+	BPF_OBJ_NAME_LEN               = 0x10
+	BPF_TAG_SIZE                   = 0x8
+	SYS_BPF                        = 321
+	F_DUPFD_CLOEXEC                = 0x406
+	EPOLLIN                        = 0x1
+	EPOLL_CTL_ADD                  = 0x1
+	EPOLL_CTL_DEL                  = 0x2
+	EPOLL_CLOEXEC                  = 0x80000
+	O_CLOEXEC                      = 0x80000
+	O_NONBLOCK                     = 0x800
+	PROT_READ                      = 0x1
+	PROT_WRITE                     = 0x2
+	MAP_SHARED                     = 0x1
+	PERF_TYPE_SOFTWARE             = 0x1
+	PERF_COUNT_SW_BPF_OUTPUT       = 0xa
+	PerfBitWatermark               = 0x4000
+	PERF_SAMPLE_RAW                = 0x400
+	PERF_FLAG_FD_CLOEXEC           = 0x8
+	PERF_TYPE_TRACEPOINT           = 0x2
+	PERF_TYPE_BREAKPOINT           = 0x5
+	PERF_EVENT_IOC_ENABLE          = 0x2400
+	PERF_EVENT_IOC_SET_BPF         = 0x40042408
+	RLIM_INFINITY                  = 0xffffffffffffffff
+	RLIMIT_MEMLOCK                 = 8
+	ENOBUFS                        = syscall.ENOBUFS
+	EINTR                          = syscall.EINTR
+	EBUSY                          = syscall.EBUSY
+	ENETDOWN                       = syscall.ENETDOWN
+	AF_XDP                         = 0x2c
+	AF_NETLINK                     = 0x10
+	CLOCK_MONOTONIC                = 0x1
+	NETLINK_KOBJECT_UEVENT         = 0xf
+	SOCK_RAW                       = 0x3
+	SOCK_CLOEXEC                   = 0x80000
+	SOL_XDP                        = 0x11b
+	SOL_SOCKET                     = 0x1
+	SO_COOKIE                      = 0x39
+	AT_FDCWD                       = -0x64
+	RENAME_EXCHANGE                = 0x2
+	CLONE_NEWNET                   = 0x40000000
+	IN_CLOEXEC                     = 0x80000
+	IN_NONBLOCK                    = 0x800
+	IN_CREATE                      = 0x100
+	IN_MOVED_TO                    = 0x80
+	IN_ONLYDIR                     = 0x1000000
+	IN_ISDIR                       = 0x40000000
+	IN_IGNORED                     = 0x8000
+	IN_Q_OVERFLOW                  = 0x4000
+	MSG_DONTWAIT                   = 0x40
+	POLLIN                         = 0x1
+	MAP_PRIVATE                    = 0x2
+	MAP_ANONYMOUS                  = 0x20
+	MAP_POPULATE                   = 0x8000
+	XDP_MMAP_OFFSETS               = 0x1
+	XDP_RX_RING                    = 0x2
+	XDP_TX_RING                    = 0x3
+	XDP_UMEM_REG                   = 0x4
+	XDP_UMEM_FILL_RING             = 0x5
+	XDP_UMEM_COMPLETION_RING       = 0x6
+	XDP_STATISTICS                 = 0x7
+	XDP_PGOFF_RX_RING              = 0x0
+	XDP_PGOFF_TX_RING              = 0x80000000
+	XDP_UMEM_PGOFF_FILL_RING       = 0x100000000
+	XDP_UMEM_PGOFF_COMPLETION_RING = 0x180000000
+	XDP_COPY                       = 0x2
+	XDP_ZEROCOPY                   = 0x4
+	XDP_USE_NEED_WAKEUP            = 0x8
+	XDP_RING_NEED_WAKEUP           = 0x1
+	XDP_PACKET_HEADROOM            = 0x100
+)
+
+// Flags which aren't available in golang.org/x/sys/unix yet.
+const (
+	BPF_F_SLEEPABLE            = 1 << 4
+	BPF_F_XDP_HAS_FRAGS        = 1 << 5
+	BPF_F_TEST_RUN_ON_CPU      = 1 << 0
+	BPF_F_TEST_XDP_LIVE_FRAMES = 1 << 1
+	BPF_F_KPROBE_MULTI_RETURN  = 1 << 0
+	BPF_F_UPROBE_MULTI_RETURN  = 1 << 0
+	BPF_F_REPLACE              = 1 << 2
+	PerfBitWriteBackward       = 1 << 27
 )
 
 // Statfs_t is a wrapper
@@ -58,6 +135,11 @@ type Rlimit struct {
 	Max uint64
 }
 
+// Getrlimit is a wrapper
+func Getrlimit(resource int, rlim *Rlimit) (err error) {
+	return errNonLinux
+}
+
 // Setrlimit is a wrapper
 func Setrlimit(resource int, rlim *Rlimit) (err error) {
 	return errNonLinux
@@ -105,6 +187,11 @@ func Eventfd(initval uint, flags int) (fd int, err error) {
 	return 0, errNonLinux
 }
 
+// Read is a wrapper
+func Read(fd int, p []byte) (n int, err error) {
+	return 0, errNonLinux
+}
+
 // Write is a wrapper
 func Write(fd int, p []byte) (n int, err error) {
 	return 0, errNonLinux
@@ -184,12 +271,158 @@ func PerfEventOpen(attr *PerfEventAttr, pid int, cpu int, groupFd int, flags int
 	return 0, errNonLinux
 }
 
+// IoctlSetInt is a wrapper
+func IoctlSetInt(fd int, req uint, value int) error {
+	return errNonLinux
+}
+
 // Utsname is a wrapper
 type Utsname struct {
 	Release [65]byte
+	Version [65]byte
 }
 
 // Uname is a wrapper
 func Uname(buf *Utsname) (err error) {
 	return errNonLinux
 }
+
+// Sockaddr is a wrapper
+type Sockaddr interface{}
+
+// SockaddrXDP is a wrapper
+type SockaddrXDP struct {
+	Flags        uint16
+	Ifindex      uint32
+	QueueID      uint32
+	SharedUmemFD uint32
+}
+
+// SockaddrNetlink is a wrapper
+type SockaddrNetlink struct {
+	Family uint16
+	Pad    uint16
+	Pid    uint32
+	Groups uint32
+}
+
+// XDPUmemReg is a wrapper
+type XDPUmemReg struct {
+	Addr     uint64
+	Len      uint64
+	Size     uint32
+	Headroom uint32
+	Flags    uint32
+	_        [4]byte
+}
+
+// XDPRingOffset is a wrapper
+type XDPRingOffset struct {
+	Producer uint64
+	Consumer uint64
+	Desc     uint64
+	Flags    uint64
+}
+
+// XDPMmapOffsets is a wrapper
+type XDPMmapOffsets struct {
+	Rx XDPRingOffset
+	Tx XDPRingOffset
+	Fr XDPRingOffset
+	Cr XDPRingOffset
+}
+
+// XDPStatistics is a wrapper
+type XDPStatistics struct {
+	Rx_dropped       uint64
+	Rx_invalid_descs uint64
+	Tx_invalid_descs uint64
+}
+
+// Socket is a wrapper
+func Socket(domain, typ, proto int) (fd int, err error) {
+	return -1, errNonLinux
+}
+
+// Bind is a wrapper
+func Bind(fd int, sa Sockaddr) (err error) {
+	return errNonLinux
+}
+
+// SendmsgN is a wrapper
+func SendmsgN(fd int, p, oob []byte, to Sockaddr, flags int) (n int, err error) {
+	return 0, errNonLinux
+}
+
+// Recvfrom is a wrapper
+func Recvfrom(fd int, p []byte, flags int) (n int, from Sockaddr, err error) {
+	return 0, nil, errNonLinux
+}
+
+// SetsockoptInt is a wrapper
+func SetsockoptInt(fd, level, opt int, value int) (err error) {
+	return errNonLinux
+}
+
+// GetsockoptUint64 is a wrapper
+func GetsockoptUint64(fd, level, opt int) (value uint64, err error) {
+	return 0, errNonLinux
+}
+
+// Renameat2 is a wrapper
+func Renameat2(olddirfd int, oldpath string, newdirfd int, newpath string, flags uint) error {
+	return errNonLinux
+}
+
+// Setns is a wrapper
+func Setns(fd int, nstype int) error {
+	return errNonLinux
+}
+
+// Gettid is a wrapper
+func Gettid() int {
+	return -1
+}
+
+// InotifyInit1 is a wrapper
+func InotifyInit1(flags int) (fd int, err error) {
+	return -1, errNonLinux
+}
+
+// InotifyAddWatch is a wrapper
+func InotifyAddWatch(fd int, pathname string, mask uint32) (watchdesc int, err error) {
+	return -1, errNonLinux
+}
+
+// Setsockopt sets a socket option which doesn't have a typed wrapper.
+func Setsockopt(fd, level, opt int, value unsafe.Pointer, size uintptr) error {
+	return errNonLinux
+}
+
+// Getsockopt gets a socket option which doesn't have a typed wrapper.
+func Getsockopt(fd, level, opt int, value unsafe.Pointer, size *uint32) error {
+	return errNonLinux
+}
+
+// PollFd is a wrapper
+type PollFd struct {
+	Fd      int32
+	Events  int16
+	Revents int16
+}
+
+// Poll is a wrapper
+func Poll(fds []PollFd, timeout int) (n int, err error) {
+	return 0, errNonLinux
+}
+
+// Timespec is a wrapper
+type Timespec struct {
+	Sec  int64
+	Nsec int64
+}
+
+// ClockGettime is a wrapper
+func ClockGettime(clockid int32, time *Timespec) error {
+	return errNonLinux
+}
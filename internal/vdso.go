@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"unsafe"
+
+	"golang.org/x/xerrors"
+)
+
+// The auxiliary vector entry containing the address of the vDSO.
+const atSysinfoEhdr = 33
+
+// vdsoVersion returns LINUX_VERSION_CODE as embedded in the vDSO
+// of the current process.
+func vdsoVersion() (uint32, error) {
+	auxv, err := ioutil.ReadFile("/proc/self/auxv")
+	if err != nil {
+		return 0, xerrors.Errorf("can't read auxiliary vector: %w", err)
+	}
+
+	addr, err := vdsoAddress(auxv)
+	if err != nil {
+		return 0, err
+	}
+
+	mem, err := os.Open("/proc/self/mem")
+	if err != nil {
+		return 0, xerrors.Errorf("can't open process memory: %w", err)
+	}
+	defer mem.Close()
+
+	return vdsoLinuxVersionCode(io.NewSectionReader(mem, int64(addr), math.MaxInt64))
+}
+
+// vdsoAddress finds AT_SYSINFO_EHDR in an auxiliary vector, which
+// consists of pairs of native words.
+func vdsoAddress(auxv []byte) (uint64, error) {
+	wordSize := int(unsafe.Sizeof(uintptr(0)))
+	word := func(buf []byte) uint64 {
+		if wordSize == 4 {
+			return uint64(NativeEndian.Uint32(buf))
+		}
+		return NativeEndian.Uint64(buf)
+	}
+
+	for len(auxv) >= 2*wordSize {
+		tag, value := word(auxv), word(auxv[wordSize:])
+		auxv = auxv[2*wordSize:]
+
+		if tag == atSysinfoEhdr {
+			if value == 0 || value > math.MaxInt64 {
+				break
+			}
+			return value, nil
+		}
+	}
+
+	return 0, xerrors.New("no vDSO in auxiliary vector")
+}
+
+// vdsoLinuxVersionCode extracts LINUX_VERSION_CODE from the "Linux"
+// ELF note of a vDSO image.
+func vdsoLinuxVersionCode(r io.ReaderAt) (uint32, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return 0, xerrors.Errorf("can't parse vDSO: %w", err)
+	}
+	defer f.Close()
+
+	for _, sec := range f.Sections {
+		if sec.Type != elf.SHT_NOTE {
+			continue
+		}
+
+		code, err := linuxVersionNote(sec.Open(), f.ByteOrder)
+		if err != nil {
+			return 0, xerrors.Errorf("section %s: %w", sec.Name, err)
+		}
+		if code != 0 {
+			return code, nil
+		}
+	}
+
+	return 0, xerrors.New("no Linux version note in vDSO")
+}
+
+// linuxVersionNote returns the descriptor of a note named "Linux" with
+// type zero, or zero if there is none.
+func linuxVersionNote(r io.Reader, bo binary.ByteOrder) (uint32, error) {
+	var hdr struct {
+		NameSize uint32
+		DescSize uint32
+		Type     uint32
+	}
+
+	align := func(n uint32) uint32 { return (n + 3) &^ 3 }
+
+	for {
+		err := binary.Read(r, bo, &hdr)
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		name := make([]byte, align(hdr.NameSize))
+		desc := make([]byte, align(hdr.DescSize))
+		if _, err := io.ReadFull(r, name); err != nil {
+			return 0, err
+		}
+		if _, err := io.ReadFull(r, desc); err != nil {
+			return 0, err
+		}
+
+		if hdr.NameSize != 6 || string(name[:5]) != "Linux" || hdr.Type != 0 || hdr.DescSize != 4 {
+			continue
+		}
+
+		return bo.Uint32(desc), nil
+	}
+}
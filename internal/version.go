@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+var kernelVersion struct {
+	once    sync.Once
+	version Version
+	err     error
+}
+
+// KernelVersion returns the version of the currently running kernel.
+//
+// The version is read from the vDSO, which contains LINUX_VERSION_CODE
+// of the running kernel. If that isn't possible, it is parsed from
+// uname(2), taking into account that some distributions report the
+// ABI version of their package instead of the upstream version.
+func KernelVersion() (Version, error) {
+	kernelVersion.once.Do(func() {
+		kernelVersion.version, kernelVersion.err = detectKernelVersion()
+	})
+
+	return kernelVersion.version, kernelVersion.err
+}
+
+// NewVersionFromCode creates a version from LINUX_VERSION_CODE.
+func NewVersionFromCode(code uint32) Version {
+	return Version{
+		uint16(code >> 16),
+		uint16((code >> 8) & 0xff),
+		uint16(code & 0xff),
+	}
+}
+
+// Kernel encodes the version like the KERNEL_VERSION macro.
+//
+// The patch level is capped at 255, like LINUX_VERSION_CODE does.
+func (v Version) Kernel() uint32 {
+	patch := v[2]
+	if patch > 255 {
+		patch = 255
+	}
+	return uint32(v[0])<<16 | uint32(v[1]&0xff)<<8 | uint32(patch)
+}
+
+func detectKernelVersion() (Version, error) {
+	if code, err := vdsoVersion(); err == nil {
+		return NewVersionFromCode(code), nil
+	}
+
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		return Version{}, xerrors.Errorf("uname failed: %w", err)
+	}
+
+	release := CString(uname.Release[:])
+	version := CString(uname.Version[:])
+
+	// Ubuntu reports the upstream version in a separate file.
+	if sig, err := ioutil.ReadFile("/proc/version_signature"); err == nil {
+		if v, err := parseUbuntuVersionSignature(string(sig)); err == nil {
+			return v, nil
+		}
+	}
+
+	// Debian puts the upstream version into the build string.
+	if v, err := parseDebianVersion(version); err == nil {
+		return v, nil
+	}
+
+	return NewVersion(release)
+}
+
+// parseUbuntuVersionSignature parses the contents of /proc/version_signature,
+// for example "Ubuntu 4.15.0-91.92-generic 4.15.18".
+func parseUbuntuVersionSignature(sig string) (Version, error) {
+	fields := strings.Fields(sig)
+	if len(fields) < 3 {
+		return Version{}, xerrors.Errorf("invalid version signature: %q", sig)
+	}
+
+	return NewVersion(fields[len(fields)-1])
+}
+
+// parseDebianVersion parses the version field of uname, for example
+// "#1 SMP Debian 4.19.98-1 (2020-01-26)".
+func parseDebianVersion(version string) (Version, error) {
+	const prefix = "Debian "
+
+	i := strings.Index(version, prefix)
+	if i == -1 {
+		return Version{}, xerrors.New("not a Debian kernel")
+	}
+
+	fields := strings.Fields(version[i+len(prefix):])
+	if len(fields) == 0 {
+		return Version{}, xerrors.Errorf("invalid Debian version: %q", version)
+	}
+
+	return NewVersion(fields[0])
+}
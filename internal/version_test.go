@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+func TestKernelVersion(t *testing.T) {
+	v, err := KernelVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("Running kernel is", v)
+
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := NewVersion(CString(uname.Release[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v[0] != release[0] || v[1] != release[1] {
+		t.Errorf("Detected version %s doesn't match uname release %s", v, release)
+	}
+}
+
+func TestVDSOVersion(t *testing.T) {
+	code, err := vdsoVersion()
+	if err != nil {
+		t.Skip("vDSO not available:", err)
+	}
+
+	if code == 0 {
+		t.Error("LINUX_VERSION_CODE is zero")
+	}
+}
+
+func TestVersionKernel(t *testing.T) {
+	for _, tc := range []struct {
+		v    Version
+		code uint32
+	}{
+		{Version{4, 9, 0}, 0x040900},
+		{Version{4, 19, 98}, 0x041362},
+		{Version{4, 9, 337}, 0x0409ff},
+	} {
+		if code := tc.v.Kernel(); code != tc.code {
+			t.Errorf("%s: expected %#x, got %#x", tc.v, tc.code, code)
+		}
+	}
+
+	if v := NewVersionFromCode(0x041362); v != (Version{4, 19, 98}) {
+		t.Error("Version from code is", v)
+	}
+}
+
+func TestParseDistroVersions(t *testing.T) {
+	v, err := parseUbuntuVersionSignature("Ubuntu 4.15.0-91.92-generic 4.15.18\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != (Version{4, 15, 18}) {
+		t.Error("Ubuntu version is", v)
+	}
+
+	v, err = parseDebianVersion("#1 SMP Debian 4.19.98-1 (2020-01-26)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != (Version{4, 19, 98}) {
+		t.Error("Debian version is", v)
+	}
+
+	if _, err := parseDebianVersion("#1 SMP PREEMPT_DYNAMIC"); err == nil {
+		t.Error("Non-Debian version is accepted")
+	}
+}
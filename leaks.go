@@ -0,0 +1,113 @@
+package ebpf
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+// LeakTrackingEnv enables leak tracking at startup if it is set to a
+// non-empty value.
+const LeakTrackingEnv = "EBPF_TRACK_LEAKS"
+
+func init() {
+	if os.Getenv(LeakTrackingEnv) != "" {
+		internal.TrackFDs(true)
+	}
+}
+
+// Leak is a file descriptor which hasn't been closed.
+type Leak struct {
+	FD int
+	// Kind is "program", "map", "link" or "btf", or empty if the kind
+	// of the file descriptor can't be determined.
+	Kind string
+	// Stack is the stack trace at the time the file descriptor was
+	// created.
+	Stack string
+}
+
+// EnableLeakTracking records a stack trace whenever a Program, Map,
+// Link or other object backed by a file descriptor is created.
+//
+// This is a debugging aid with a cost for every object created.
+// Objects which are garbage collected without calling Close are
+// closed by a finalizer and don't show up in Leaks, see
+// SetFinalizerOptions to find them.
+//
+// Tracking can also be enabled by setting the environment variable
+// EBPF_TRACK_LEAKS.
+func EnableLeakTracking() {
+	internal.TrackFDs(true)
+}
+
+// DisableLeakTracking stops recording objects and forgets all objects
+// recorded so far.
+func DisableLeakTracking() {
+	internal.TrackFDs(false)
+}
+
+// Leaks returns all objects which were created while leak tracking was
+// enabled and which are neither closed nor pinned.
+func Leaks() []Leak {
+	var leaks []Leak
+	for _, fd := range internal.TrackedFDs() {
+		if fd.Pinned {
+			continue
+		}
+		leaks = append(leaks, Leak{fd.FD, fd.Kind, fd.Stack})
+	}
+	return leaks
+}
+
+// ReportLeaks writes the result of Leaks to w and returns the number
+// of leaked objects.
+//
+// Deferring a call in main reports leaks at exit:
+//
+//	ebpf.EnableLeakTracking()
+//	defer ebpf.ReportLeaks(os.Stderr)
+func ReportLeaks(w io.Writer) int {
+	leaks := Leaks()
+	for _, leak := range leaks {
+		kind := leak.Kind
+		if kind == "" {
+			kind = "object"
+		}
+		fmt.Fprintf(w, "%s with fd %d was created at:\n%s\n", kind, leak.FD, leak.Stack)
+	}
+	return len(leaks)
+}
+
+// FinalizerOptions control what happens to objects which are garbage
+// collected without calling Close.
+type FinalizerOptions struct {
+	// KeepAlive prevents closing the file descriptor of a garbage
+	// collected object, which keeps the kernel object alive until the
+	// process exits. Such objects are reported by Leaks.
+	KeepAlive bool
+	// Warn is called for every garbage collected object. Leak.Stack is
+	// only populated if leak tracking is enabled.
+	//
+	// Warn is called from a finalizer and must not block.
+	Warn func(Leak)
+}
+
+// SetFinalizerOptions changes how objects which are garbage collected
+// without calling Close are handled.
+//
+// By default, their file descriptors are closed silently. This is
+// usually a bug, since a Link or Program that is garbage collected
+// detaches from its hook at an arbitrary point in time.
+func SetFinalizerOptions(opts FinalizerOptions) {
+	var warn func(internal.TrackedFD)
+	if opts.Warn != nil {
+		warn = func(fd internal.TrackedFD) {
+			opts.Warn(Leak{fd.FD, fd.Kind, fd.Stack})
+		}
+	}
+
+	internal.SetFinalizer(opts.KeepAlive, warn)
+}
@@ -0,0 +1,91 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeaks(t *testing.T) {
+	EnableLeakTracking()
+	defer DisableLeakTracking()
+
+	m := createArray(t)
+	defer m.Close()
+
+	findLeak := func() *Leak {
+		t.Helper()
+
+		for _, leak := range Leaks() {
+			if leak.FD == m.FD() {
+				return &leak
+			}
+		}
+		return nil
+	}
+
+	leak := findLeak()
+	if leak == nil {
+		t.Fatal("Map isn't reported as leaked")
+	}
+	if leak.Kind != "map" {
+		t.Error("Expected kind map, got", leak.Kind)
+	}
+	if !strings.Contains(leak.Stack, "createArray") {
+		t.Error("Stack doesn't contain the caller:\n", leak.Stack)
+	}
+
+	tmp, err := ioutil.TempDir("/sys/fs/bpf", "ebpf-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := m.Pin(filepath.Join(tmp, "map")); err != nil {
+		t.Fatal(err)
+	}
+	if findLeak() != nil {
+		t.Error("Pinned map is reported as leaked")
+	}
+
+	other := createArray(t)
+	other.Close()
+	for _, leak := range Leaks() {
+		if strings.Contains(leak.Stack, "TestLeaks") {
+			t.Error("Closed map is reported as leaked")
+		}
+	}
+}
+
+func TestFinalizerOptions(t *testing.T) {
+	leaks := make(chan Leak, 1)
+	SetFinalizerOptions(FinalizerOptions{
+		Warn: func(leak Leak) {
+			select {
+			case leaks <- leak:
+			default:
+			}
+		},
+	})
+	defer SetFinalizerOptions(FinalizerOptions{})
+
+	createArray(t)
+
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		select {
+		case leak := <-leaks:
+			if leak.Kind != "map" {
+				t.Error("Expected a map, got", leak.Kind)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	t.Fatal("Garbage collected map wasn't reported")
+}
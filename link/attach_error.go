@@ -0,0 +1,108 @@
+package link
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// errNotTraceable is returned for symbols which exist but can't be
+// probed. It wraps os.ErrNotExist, which was returned for them before.
+var errNotTraceable = xerrors.Errorf("symbol can't be traced: %w", os.ErrNotExist)
+
+// AttachFailure is the reason attaching to a single target failed.
+type AttachFailure int
+
+// Reasons for attaching to a target to fail.
+const (
+	// FailureUnknown is any other error.
+	FailureUnknown AttachFailure = iota
+	// FailureNotFound means the target doesn't exist.
+	FailureNotFound
+	// FailureNotTraceable means the target exists, but can't be traced.
+	// For example, the function is marked notrace or is on the kprobe
+	// blacklist.
+	FailureNotTraceable
+	// FailureBusy means the kernel returned EBUSY, for example because
+	// too many programs are attached to the target.
+	FailureBusy
+)
+
+func (af AttachFailure) String() string {
+	switch af {
+	case FailureNotFound:
+		return "not found"
+	case FailureNotTraceable:
+		return "not traceable"
+	case FailureBusy:
+		return "busy"
+	default:
+		return "unknown"
+	}
+}
+
+func classifyAttachError(err error) AttachFailure {
+	switch {
+	case xerrors.Is(err, unix.EBUSY):
+		return FailureBusy
+	case xerrors.Is(err, errNotTraceable):
+		return FailureNotTraceable
+	case xerrors.Is(err, os.ErrNotExist):
+		return FailureNotFound
+	default:
+		return FailureUnknown
+	}
+}
+
+// TargetError is the failure to attach to a single target of a bulk
+// attach operation.
+type TargetError struct {
+	// Target is the symbol or other identifier of the attach point.
+	Target string
+	Reason AttachFailure
+	Err    error
+}
+
+func (te *TargetError) Error() string {
+	return fmt.Sprintf("%s (%s): %s", te.Target, te.Reason, te.Err)
+}
+
+func (te *TargetError) Unwrap() error {
+	return te.Err
+}
+
+// AttachError is returned by bulk attach operations like Kprobes if
+// attaching to some of the targets failed.
+//
+// It wraps the first failure, so that errors.Is works as for single
+// targets.
+type AttachError struct {
+	// Attached contains the targets which were attached to, if the
+	// operation continued past failures.
+	Attached []string
+	// Failed contains a TargetError for each target which failed, in
+	// the order of the targets.
+	Failed []*TargetError
+}
+
+func (ae *AttachError) Error() string {
+	if len(ae.Failed) == 0 {
+		return fmt.Sprintf("attached to %d targets", len(ae.Attached))
+	}
+	return fmt.Sprintf("%d targets failed (%d attached), first failure: %s", len(ae.Failed), len(ae.Attached), ae.Failed[0])
+}
+
+func (ae *AttachError) Unwrap() error {
+	if len(ae.Failed) == 0 {
+		return nil
+	}
+	return ae.Failed[0]
+}
+
+// failed adds a failure for target to the error.
+func (ae *AttachError) failed(target string, err error) {
+	ae.Failed = append(ae.Failed, &TargetError{target, classifyAttachError(err), err})
+}
@@ -0,0 +1,37 @@
+package link
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+func TestAttachError(t *testing.T) {
+	ae := &AttachError{Attached: []string{"vfs_read"}}
+	ae.failed("bogus", xerrors.Errorf("symbol bogus: %w", os.ErrNotExist))
+	ae.failed("native_get_debugreg", xerrors.Errorf("symbol native_get_debugreg: %w", errNotTraceable))
+	ae.failed("vfs_write", xerrors.Errorf("can't attach: %w", unix.EBUSY))
+	ae.failed("vfs_open", xerrors.New("other"))
+
+	for i, want := range []AttachFailure{FailureNotFound, FailureNotTraceable, FailureBusy, FailureUnknown} {
+		if have := ae.Failed[i].Reason; have != want {
+			t.Errorf("%s: expected reason %s, got %s", ae.Failed[i].Target, want, have)
+		}
+	}
+
+	if !xerrors.Is(ae, os.ErrNotExist) {
+		t.Error("AttachError doesn't wrap the first failure")
+	}
+
+	var te *TargetError
+	if !xerrors.As(ae, &te) || te.Target != "bogus" {
+		t.Error("Can't extract TargetError")
+	}
+
+	if !xerrors.Is(errNotTraceable, os.ErrNotExist) {
+		t.Error("errNotTraceable doesn't wrap os.ErrNotExist")
+	}
+}
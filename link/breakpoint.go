@@ -0,0 +1,148 @@
+package link
+
+import (
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// BreakpointType selects the accesses which trigger a hardware
+// breakpoint.
+type BreakpointType uint32
+
+// Valid breakpoint types, from include/uapi/linux/hw_breakpoint.h.
+const (
+	BreakpointRead      BreakpointType = 1
+	BreakpointWrite     BreakpointType = 2
+	BreakpointReadWrite                = BreakpointRead | BreakpointWrite
+	BreakpointExecute   BreakpointType = 4
+)
+
+// BreakpointOptions control AttachBreakpoint.
+type BreakpointOptions struct {
+	// Program must be of type PerfEvent.
+	Program *ebpf.Program
+	// Address is the code or data address to watch. It is a user space
+	// address in the process given by PID, or a kernel address.
+	Address uint64
+	Type    BreakpointType
+	// Length is the number of bytes to watch, one of 1, 2, 4 or 8.
+	// Execute breakpoints always use the size of a long.
+	Length uint64
+	// PID is the thread to watch. All threads on all CPUs are watched if
+	// PID is zero.
+	PID int
+	// Cookie is an arbitrary value that can be fetched from the program
+	// via bpf_get_attach_cookie().
+	//
+	// Requires at least Linux 5.15.
+	Cookie uint64
+}
+
+// AttachBreakpoint runs a program whenever a hardware breakpoint
+// triggers, which allows tracing accesses to a variable or the execution
+// of an instruction.
+//
+// The number of hardware breakpoints is small, usually four per CPU.
+// Watching kernel addresses requires CAP_PERFMON or CAP_SYS_ADMIN.
+//
+// Requires at least Linux 4.9.
+func AttachBreakpoint(opts BreakpointOptions) (Link, error) {
+	if _, err := programFD(opts.Program); err != nil {
+		return nil, err
+	}
+
+	if t := opts.Program.ABI().Type; t != ebpf.PerfEvent {
+		return nil, xerrors.Errorf("invalid program type %s, expected PerfEvent", t)
+	}
+
+	length := opts.Length
+	switch opts.Type {
+	case BreakpointRead, BreakpointWrite, BreakpointReadWrite:
+		if length != 1 && length != 2 && length != 4 && length != 8 {
+			return nil, xerrors.Errorf("invalid breakpoint length %d", length)
+		}
+	case BreakpointExecute:
+		length = uint64(unsafe.Sizeof(uintptr(0)))
+	default:
+		return nil, xerrors.Errorf("invalid breakpoint type %d", opts.Type)
+	}
+
+	attr := unix.PerfEventAttr{
+		Type:    unix.PERF_TYPE_BREAKPOINT,
+		Size:    uint32(unsafe.Sizeof(unix.PerfEventAttr{})),
+		Sample:  1,
+		Bp_type: uint32(opts.Type),
+		Ext1:    opts.Address,
+		Ext2:    length,
+	}
+
+	if opts.PID != 0 {
+		return attachBreakpoint(&attr, opts.PID, -1, opts)
+	}
+
+	cpus, err := internal.OnlineCPUList()
+	if err != nil {
+		return nil, err
+	}
+
+	bl := &breakpointLink{}
+	for _, cpu := range cpus {
+		l, err := attachBreakpoint(&attr, -1, cpu, opts)
+		if err != nil {
+			bl.Close()
+			return nil, xerrors.Errorf("cpu %d: %w", cpu, err)
+		}
+		bl.links = append(bl.links, l)
+	}
+
+	return bl, nil
+}
+
+func attachBreakpoint(attr *unix.PerfEventAttr, pid, cpu int, opts BreakpointOptions) (Link, error) {
+	fd, err := unix.PerfEventOpen(attr, pid, cpu, -1, unix.PERF_FLAG_FD_CLOEXEC)
+	if err != nil {
+		return nil, xerrors.Errorf("can't create breakpoint: %w", wrapDenied(err))
+	}
+
+	return attachPerfEvent(internal.NewFD(uint32(fd)), nil, opts.Program, opts.Cookie)
+}
+
+// breakpointLink is a breakpoint watching all processes, which consists
+// of a perf event per CPU.
+type breakpointLink struct {
+	links []Link
+}
+
+var _ Link = (*breakpointLink)(nil)
+
+func (bl *breakpointLink) isLink() {}
+
+// Pin is not supported, since the breakpoint consists of multiple
+// links.
+func (bl *breakpointLink) Pin(string) error {
+	return xerrors.Errorf("can't pin breakpoint: %w", internal.ErrNotSupported)
+}
+
+// Update is not supported, since the breakpoint consists of a perf
+// event per CPU which can't be switched to another program at once.
+// Attach a Dispatcher to switch programs.
+func (bl *breakpointLink) Update(*ebpf.Program) error {
+	return xerrors.Errorf("can't update breakpoint: %w", internal.ErrNotSupported)
+}
+
+// Close removes the breakpoint from all CPUs.
+func (bl *breakpointLink) Close() error {
+	var firstErr error
+	for _, l := range bl.links {
+		if err := l.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	bl.links = nil
+	return firstErr
+}
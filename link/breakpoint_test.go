@@ -0,0 +1,65 @@
+package link
+
+import (
+	"os"
+	"testing"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+var breakpointTarget uint64
+
+func TestAttachBreakpoint(t *testing.T) {
+	prog := mustLoadProgram(t, ebpf.PerfEvent, 0, "")
+	defer prog.Close()
+
+	addr := uint64(uintptr(unsafe.Pointer(&breakpointTarget)))
+
+	_, err := AttachBreakpoint(BreakpointOptions{
+		Program: prog,
+		Address: addr,
+		Type:    BreakpointWrite,
+		Length:  3,
+		PID:     os.Getpid(),
+	})
+	if err == nil {
+		t.Error("Invalid length should be rejected")
+	}
+
+	_, err = AttachBreakpoint(BreakpointOptions{
+		Program: prog,
+		Address: addr,
+		Type:    8,
+		Length:  8,
+		PID:     os.Getpid(),
+	})
+	if err == nil {
+		t.Error("Invalid type should be rejected")
+	}
+
+	for _, pid := range []int{os.Getpid(), 0} {
+		bp, err := AttachBreakpoint(BreakpointOptions{
+			Program: prog,
+			Address: addr,
+			Type:    BreakpointWrite,
+			Length:  8,
+			PID:     pid,
+		})
+		if xerrors.Is(err, unix.ENOENT) || xerrors.Is(err, unix.EOPNOTSUPP) || xerrors.Is(err, unix.ENOSPC) {
+			t.Skip("Hardware breakpoints are not supported:", err)
+		}
+		if err != nil {
+			t.Fatalf("Can't attach breakpoint for pid %d: %s", pid, err)
+		}
+
+		breakpointTarget++
+
+		if err := bp.Close(); err != nil {
+			t.Fatal("Can't close link:", err)
+		}
+	}
+}
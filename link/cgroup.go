@@ -0,0 +1,49 @@
+package link
+
+import (
+	"os"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+
+	"golang.org/x/xerrors"
+)
+
+// CgroupOptions control AttachCgroup.
+type CgroupOptions struct {
+	// Path is the location of a cgroup in the unified hierarchy, see
+	// package cgroup.
+	Path string
+	// Attach is the hook in the cgroup, for example
+	// AttachCGroupInetIngress.
+	Attach  ebpf.AttachType
+	Program *ebpf.Program
+}
+
+// AttachCgroup links a program to a cgroup.
+//
+// Requires at least Linux 5.7.
+func AttachCgroup(opts CgroupOptions) (Link, error) {
+	progFd, err := programFD(opts.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	cg, err := os.Open(opts.Path)
+	if err != nil {
+		return nil, xerrors.Errorf("can't open cgroup: %w", err)
+	}
+	defer cg.Close()
+
+	attr := bpfLinkCreateAttr{
+		progFd:     progFd,
+		targetFd:   uint32(cg.Fd()),
+		attachType: opts.Attach,
+	}
+	fd, err := bpfLinkCreate(unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return nil, xerrors.Errorf("can't attach to cgroup: %w", err)
+	}
+
+	return &RawLink{fd}, nil
+}
@@ -0,0 +1,53 @@
+package link
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/cgroup"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestAttachCgroup(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.7", "cgroup links")
+
+	cg, err := cgroup.Mountpoint()
+	if err != nil {
+		t.Skip("No cgroup2 hierarchy:", err)
+	}
+
+	// Allow all packets, since the program is attached to the root cgroup.
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:       ebpf.CGroupSKB,
+		AttachType: ebpf.AttachCGroupInetEgress,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 1),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	link, err := AttachCgroup(CgroupOptions{
+		Path:    cg,
+		Attach:  ebpf.AttachCGroupInetEgress,
+		Program: prog,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't attach to cgroup:", err)
+	}
+	defer link.Close()
+
+	info, err := link.(*RawLink).Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Type != CgroupType {
+		t.Error("Expected CgroupType, got", info.Type)
+	}
+}
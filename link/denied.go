@@ -0,0 +1,72 @@
+package link
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// LockdownError is returned if kernel lockdown prevents an operation.
+//
+// Lockdown in confidentiality mode forbids kprobes, access to tracefs
+// and some perf events, even for privileged users.
+type LockdownError struct {
+	// Mode is the active lockdown mode.
+	Mode string
+	Err  error
+}
+
+func (le *LockdownError) Error() string {
+	return fmt.Sprintf("denied by kernel lockdown (%s): %s", le.Mode, le.Err)
+}
+
+func (le *LockdownError) Unwrap() error {
+	return le.Err
+}
+
+// SELinuxError is returned if an operation is denied while SELinux is
+// enforcing its policy. The audit log contains the exact denial.
+type SELinuxError struct {
+	Err error
+}
+
+func (se *SELinuxError) Error() string {
+	return fmt.Sprintf("denied by SELinux policy: %s", se.Err)
+}
+
+func (se *SELinuxError) Unwrap() error {
+	return se.Err
+}
+
+// Capabilities which override file permissions and perf_event_paranoid.
+const (
+	capDACOverride = 1
+	capSysAdmin    = 21
+)
+
+// wrapDenied returns a LockdownError or SELinuxError if either explains
+// err, and err otherwise.
+//
+// Lockdown returns EPERM, while LSMs like SELinux return EACCES. So do
+// file permissions and perf_event_paranoid, so EACCES is only attributed
+// to SELinux if the process has the capabilities to override them.
+func wrapDenied(err error) error {
+	switch {
+	case xerrors.Is(err, unix.EPERM):
+		if mode, lockErr := internal.KernelLockdown(); lockErr == nil && mode == "confidentiality" {
+			return &LockdownError{mode, err}
+		}
+
+	case xerrors.Is(err, unix.EACCES):
+		caps, capErr := internal.EffectiveCapabilities()
+		privileged := capErr == nil && caps&(1<<capDACOverride) != 0 && caps&(1<<capSysAdmin) != 0
+		if privileged && internal.SELinuxEnforcing() {
+			return &SELinuxError{err}
+		}
+	}
+
+	return err
+}
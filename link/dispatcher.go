@@ -0,0 +1,169 @@
+package link
+
+import (
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+
+	"golang.org/x/xerrors"
+)
+
+// DispatcherOptions control the program generated by NewDispatcher.
+type DispatcherOptions struct {
+	// Type, AttachType and AttachTo must match the programs which are
+	// dispatched to.
+	Type       ebpf.ProgramType
+	AttachType ebpf.AttachType
+	AttachTo   string
+	License    string
+	// Default is returned by the dispatcher if no program is set.
+	Default int32
+}
+
+// Dispatcher allows replacing a program at hooks which don't support
+// BPF_LINK_UPDATE.
+//
+// The dispatcher is a small program that tail calls the current
+// program. Attach Dispatcher.Program instead of the actual program,
+// then use Update to switch between programs without missing events.
+type Dispatcher struct {
+	prog  *ebpf.Program
+	slots *ebpf.Map
+}
+
+// NewDispatcher creates a dispatcher which doesn't dispatch to any
+// program yet.
+//
+// Requires at least Linux 4.2.
+func NewDispatcher(opts DispatcherOptions) (*Dispatcher, error) {
+	slots, err := ebpf.NewMap(&ebpf.MapSpec{
+		Name:       "dispatch",
+		Type:       ebpf.ProgramArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("can't create dispatch map: %w", err)
+	}
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:       "dispatcher",
+		Type:       opts.Type,
+		AttachType: opts.AttachType,
+		AttachTo:   opts.AttachTo,
+		License:    opts.License,
+		Instructions: asm.Instructions{
+			// R1 already contains the context.
+			asm.LoadMapPtr(asm.R2, slots.FD()),
+			asm.Mov.Imm(asm.R3, 0),
+			asm.FnTailCall.Call(),
+			asm.Mov.Imm(asm.R0, opts.Default),
+			asm.Return(),
+		},
+	})
+	if err != nil {
+		slots.Close()
+		return nil, xerrors.Errorf("can't load dispatcher: %w", err)
+	}
+
+	return &Dispatcher{prog, slots}, nil
+}
+
+// Program returns the dispatcher program, which should be attached
+// to the hook.
+//
+// The program is owned by the Dispatcher and mustn't be closed.
+func (d *Dispatcher) Program() *ebpf.Program {
+	return d.prog
+}
+
+// Update atomically switches to prog.
+//
+// Update returns once all invocations of the previous program have
+// finished, so the previous program and its maps can be closed
+// without losing data. See Synchronize for the kernel requirements.
+// Passing nil makes the dispatcher return the default value.
+func (d *Dispatcher) Update(prog *ebpf.Program) error {
+	var err error
+	if prog == nil {
+		err = d.slots.Delete(uint32(0))
+		if xerrors.Is(err, ebpf.ErrKeyNotExist) {
+			err = nil
+		}
+	} else {
+		err = d.slots.Put(uint32(0), prog)
+	}
+	if err != nil {
+		return xerrors.Errorf("can't update dispatcher: %w", err)
+	}
+
+	return Synchronize()
+}
+
+// Close frees the dispatcher.
+//
+// Hooks the dispatcher is attached to keep using it until they are
+// detached.
+func (d *Dispatcher) Close() error {
+	progErr := d.prog.Close()
+	if err := d.slots.Close(); err != nil {
+		return err
+	}
+	return progErr
+}
+
+var barrier struct {
+	sync.Mutex
+	outer, inner *ebpf.Map
+}
+
+// Synchronize waits until all BPF programs which were running when it
+// was called have finished.
+//
+// This allows closing a program, or reading the final state of its
+// maps, after it has been replaced. It relies on the kernel waiting
+// for an RCU grace period when updating a map of maps from user space,
+// which it only does since Linux 4.20. Earlier kernels with support for
+// maps of maps return without waiting.
+//
+// Requires at least Linux 4.20.
+func Synchronize() error {
+	barrier.Lock()
+	defer barrier.Unlock()
+
+	if barrier.outer == nil {
+		inner := &ebpf.MapSpec{
+			Type:       ebpf.Array,
+			KeySize:    4,
+			ValueSize:  4,
+			MaxEntries: 1,
+		}
+
+		outer, err := ebpf.NewMap(&ebpf.MapSpec{
+			Name:       "barrier",
+			Type:       ebpf.ArrayOfMaps,
+			KeySize:    4,
+			ValueSize:  4,
+			MaxEntries: 1,
+			InnerMap:   inner,
+		})
+		if err != nil {
+			return xerrors.Errorf("can't create barrier: %w", err)
+		}
+
+		m, err := ebpf.NewMap(inner)
+		if err != nil {
+			outer.Close()
+			return xerrors.Errorf("can't create barrier: %w", err)
+		}
+
+		barrier.outer, barrier.inner = outer, m
+	}
+
+	if err := barrier.outer.Put(uint32(0), barrier.inner); err != nil {
+		return xerrors.Errorf("barrier: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package link
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+)
+
+func TestDispatcher(t *testing.T) {
+	d, err := NewDispatcher(DispatcherOptions{
+		Type:    ebpf.SocketFilter,
+		License: "MIT",
+		Default: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	mustReturn := func(want uint32) {
+		t.Helper()
+
+		ret, _, err := d.Program().Test(make([]byte, 14))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ret != want {
+			t.Errorf("Expected %d, got %d", want, ret)
+		}
+	}
+
+	mustReturn(1)
+
+	for _, value := range []int32{2, 3} {
+		prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+			Type: ebpf.SocketFilter,
+			Instructions: asm.Instructions{
+				asm.Mov.Imm(asm.R0, value),
+				asm.Return(),
+			},
+			License: "MIT",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := d.Update(prog); err != nil {
+			t.Fatal("Can't update dispatcher:", err)
+		}
+		prog.Close()
+
+		mustReturn(uint32(value))
+	}
+
+	if err := d.Update(nil); err != nil {
+		t.Fatal("Can't reset dispatcher:", err)
+	}
+	mustReturn(1)
+}
+
+func TestRawLinkUpdateNotSupported(t *testing.T) {
+	prog := mustLoadProgram(t, ebpf.RawTracepoint, 0, "")
+	defer prog.Close()
+
+	link, err := AttachRawTracepoint(RawTracepointOptions{
+		Name:    "sched_process_exec",
+		Program: prog,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer link.Close()
+
+	// Raw tracepoints don't support replacing the program.
+	if err := link.Update(prog); err == nil {
+		t.Error("Updating a raw tracepoint doesn't return an error")
+	}
+}
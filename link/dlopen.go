@@ -0,0 +1,330 @@
+package link
+
+import (
+	"bytes"
+	"debug/elf"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/perf"
+
+	"golang.org/x/xerrors"
+)
+
+// LibraryWatcherOptions control WatchLibraries.
+type LibraryWatcherOptions struct {
+	// PID restricts the watcher to a single process. All processes using
+	// Interpreter are watched if zero.
+	PID int
+	// Interpreter is the path of the dynamic linker. Defaults to the one
+	// mapped by PID, or the interpreter of /bin/sh if PID is zero.
+	Interpreter string
+}
+
+// LibraryLoad is a shared library which was mapped by a process.
+type LibraryLoad struct {
+	PID int
+	// Path of the library in the mount namespace of the process. Pass it
+	// to OpenExecutableInProcess to attach uprobes.
+	Path string
+}
+
+// LibraryWatcher reports shared libraries loaded via dlopen.
+//
+// The dynamic linker calls _dl_debug_state whenever it changes the list
+// of loaded libraries, so that debuggers can notice. The watcher uses a
+// uprobe on it, and compares the executable mappings of the process
+// afterwards.
+type LibraryWatcher struct {
+	prog   *ebpf.Program
+	events *ebpf.Map
+	link   Link
+	rd     *perf.Reader
+
+	// Processes which have been scanned, by PID.
+	known map[int]*knownProcess
+	// Size of known after it was last pruned.
+	pruned  int
+	pending []LibraryLoad
+}
+
+// knownProcess is a process scanned by LibraryWatcher.
+type knownProcess struct {
+	// Distinguishes the process from earlier ones with the same PID.
+	startTime uint64
+	// Executable mappings of the process, by path.
+	paths map[string]bool
+}
+
+// WatchLibraries starts watching for shared libraries being loaded, so
+// that uprobes can be attached to them even if they are loaded after the
+// uprobes for the executable are.
+//
+// If opts.PID is zero, the first load of a process reports all libraries
+// it has mapped at that point.
+//
+// Requires at least Linux 4.4.
+func WatchLibraries(opts LibraryWatcherOptions) (*LibraryWatcher, error) {
+	if opts.PID < 0 {
+		return nil, xerrors.Errorf("invalid PID %d", opts.PID)
+	}
+
+	interp := opts.Interpreter
+	if interp == "" {
+		var err error
+		interp, err = findInterpreter(opts.PID)
+		if err != nil {
+			return nil, xerrors.Errorf("can't find dynamic linker: %w", err)
+		}
+	}
+
+	ld, err := OpenExecutable(interp)
+	if err != nil {
+		return nil, err
+	}
+
+	lw := &LibraryWatcher{known: make(map[int]*knownProcess)}
+	if opts.PID != 0 {
+		// Only report libraries loaded from now on.
+		lw.scan(opts.PID)
+		lw.pending = nil
+	}
+
+	lw.events, err = ebpf.NewMap(&ebpf.MapSpec{
+		Name: "dlopen_events",
+		Type: ebpf.PerfEventArray,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("can't create event map: %w", err)
+	}
+
+	lw.prog, err = ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:    "dlopen_watch",
+		Type:    ebpf.Kprobe,
+		License: "GPL",
+		Instructions: asm.Instructions{
+			asm.Mov.Reg(asm.R6, asm.R1),
+			asm.FnGetCurrentPidTgid.Call(),
+			// The tgid is the process ID in user space.
+			asm.RSh.Imm(asm.R0, 32),
+			asm.StoreMem(asm.RFP, -8, asm.R0, asm.DWord),
+			asm.Mov.Reg(asm.R1, asm.R6),
+			asm.LoadMapPtr(asm.R2, lw.events.FD()),
+			// BPF_F_CURRENT_CPU
+			asm.LoadImm(asm.R3, 0xffffffff, asm.DWord),
+			asm.Mov.Reg(asm.R4, asm.RFP),
+			asm.Add.Imm(asm.R4, -8),
+			asm.Mov.Imm(asm.R5, 8),
+			asm.FnPerfEventOutput.Call(),
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+	})
+	if err != nil {
+		lw.Close()
+		return nil, xerrors.Errorf("can't load program: %w", err)
+	}
+
+	lw.rd, err = perf.NewReader(lw.events, os.Getpagesize())
+	if err != nil {
+		lw.Close()
+		return nil, err
+	}
+
+	lw.link, err = ld.Uprobe("_dl_debug_state", lw.prog, &UprobeOptions{PID: opts.PID})
+	if err != nil {
+		lw.Close()
+		return nil, err
+	}
+
+	return lw, nil
+}
+
+// findInterpreter returns the path of the dynamic linker used by a process,
+// or by /bin/sh if pid is zero.
+func findInterpreter(pid int) (string, error) {
+	if pid == 0 {
+		f, err := elf.Open("/bin/sh")
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		for _, prog := range f.Progs {
+			if prog.Type != elf.PT_INTERP {
+				continue
+			}
+
+			data := make([]byte, prog.Filesz)
+			if _, err := prog.ReadAt(data, 0); err != nil {
+				return "", xerrors.Errorf("read interpreter: %w", err)
+			}
+
+			return string(bytes.TrimRight(data, "\x00")), nil
+		}
+
+		return "", xerrors.Errorf("/bin/sh doesn't have an interpreter: %w", os.ErrNotExist)
+	}
+
+	proc := filepath.Join("/proc", strconv.Itoa(pid))
+	maps, err := os.Open(filepath.Join(proc, "maps"))
+	if err != nil {
+		return "", err
+	}
+	defer maps.Close()
+
+	paths, err := mappedFiles(maps)
+	if err != nil {
+		return "", err
+	}
+
+	for _, path := range paths {
+		// glibc and musl, respectively.
+		base := filepath.Base(path)
+		if matchLibrary(base, "ld-linux") || matchLibrary(base, "ld-musl") {
+			return filepath.Join(proc, "root", path), nil
+		}
+	}
+
+	return "", xerrors.Errorf("pid %d isn't dynamically linked: %w", pid, os.ErrNotExist)
+}
+
+// Read blocks until a process loads a shared library.
+//
+// Calling Close interrupts the function.
+func (lw *LibraryWatcher) Read() (LibraryLoad, error) {
+	for len(lw.pending) == 0 {
+		rec, err := lw.rd.Read()
+		if err != nil {
+			return LibraryLoad{}, err
+		}
+
+		if rec.LostSamples > 0 {
+			// The processes which loaded libraries are unknown, check
+			// all of them.
+			for pid := range lw.known {
+				lw.scan(pid)
+			}
+			continue
+		}
+
+		if len(rec.RawSample) < 8 {
+			return LibraryLoad{}, xerrors.Errorf("invalid sample of length %d", len(rec.RawSample))
+		}
+
+		lw.scan(int(internal.NativeEndian.Uint64(rec.RawSample)))
+	}
+
+	load := lw.pending[0]
+	lw.pending = lw.pending[1:]
+	return load, nil
+}
+
+// scan adds libraries newly mapped by pid to the pending loads.
+func (lw *LibraryWatcher) scan(pid int) {
+	startTime, err := processStartTime(pid)
+	if err != nil {
+		// The process has exited.
+		delete(lw.known, pid)
+		return
+	}
+
+	maps, err := os.Open(filepath.Join("/proc", strconv.Itoa(pid), "maps"))
+	if err != nil {
+		delete(lw.known, pid)
+		return
+	}
+	defer maps.Close()
+
+	paths, err := mappedFiles(maps)
+	if err != nil {
+		return
+	}
+
+	proc := lw.known[pid]
+	if proc == nil || proc.startTime != startTime {
+		// The PID is new or has been reused.
+		proc = &knownProcess{startTime, make(map[string]bool)}
+		lw.known[pid] = proc
+		lw.prune()
+	}
+
+	for _, path := range paths {
+		if proc.paths[path] {
+			continue
+		}
+
+		proc.paths[path] = true
+		lw.pending = append(lw.pending, LibraryLoad{pid, path})
+	}
+}
+
+// prune forgets processes which have exited.
+//
+// Processes are only scanned when they load a library, so exited ones
+// aren't noticed otherwise. known is pruned each time it has doubled in
+// size, which keeps the cost per scan constant.
+func (lw *LibraryWatcher) prune() {
+	if len(lw.known) < 2*lw.pruned {
+		return
+	}
+
+	for pid, proc := range lw.known {
+		if startTime, err := processStartTime(pid); err != nil || startTime != proc.startTime {
+			delete(lw.known, pid)
+		}
+	}
+
+	lw.pruned = len(lw.known)
+}
+
+// processStartTime returns the time a process started at, in clock
+// ticks after boot.
+func processStartTime(pid int) (uint64, error) {
+	stat, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, err
+	}
+
+	// The command name in the second field may contain spaces and
+	// parentheses.
+	idx := bytes.LastIndexByte(stat, ')')
+	if idx == -1 {
+		return 0, xerrors.Errorf("pid %d: invalid stat", pid)
+	}
+
+	// Fields after the command name start with the third field, starttime
+	// is the 22nd.
+	fields := strings.Fields(string(stat[idx+1:]))
+	if len(fields) < 20 {
+		return 0, xerrors.Errorf("pid %d: invalid stat", pid)
+	}
+
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// Close stops watching for libraries.
+func (lw *LibraryWatcher) Close() error {
+	var firstErr error
+	if lw.link != nil {
+		firstErr = lw.link.Close()
+	}
+	if lw.rd != nil {
+		if err := lw.rd.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if lw.prog != nil {
+		lw.prog.Close()
+	}
+	if lw.events != nil {
+		lw.events.Close()
+	}
+	return firstErr
+}
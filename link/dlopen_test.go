@@ -0,0 +1,110 @@
+package link
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchLibraries(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is required to call dlopen")
+	}
+
+	cmd := exec.Command(python, "-c", `import sys
+print("ready", flush=True)
+sys.stdin.readline()
+import ctypes
+sys.stdin.readline()`)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer stdin.Close()
+
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatal("Can't read from python:", err)
+	}
+
+	lw, err := WatchLibraries(LibraryWatcherOptions{PID: cmd.Process.Pid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lw.Close()
+
+	timer := time.AfterFunc(5*time.Second, func() { lw.Close() })
+	defer timer.Stop()
+
+	if _, err := stdin.Write([]byte("\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		load, err := lw.Read()
+		if err != nil {
+			t.Fatal("No load of _ctypes:", err)
+		}
+
+		if load.PID != cmd.Process.Pid {
+			t.Fatal("Unexpected PID", load.PID)
+		}
+
+		if strings.Contains(load.Path, "_ctypes") {
+			break
+		}
+	}
+
+	if _, err := WatchLibraries(LibraryWatcherOptions{PID: -1}); err == nil {
+		t.Error("WatchLibraries accepts a negative PID")
+	}
+}
+
+func TestLibraryWatcherScan(t *testing.T) {
+	lw := &LibraryWatcher{known: make(map[int]*knownProcess)}
+
+	pid := os.Getpid()
+	lw.scan(pid)
+	if len(lw.pending) == 0 {
+		t.Fatal("No libraries found")
+	}
+	n := len(lw.pending)
+
+	lw.pending = nil
+	lw.scan(pid)
+	if len(lw.pending) != 0 {
+		t.Fatal("Known libraries are reported again")
+	}
+
+	// Pretend the PID belonged to a process which has exited.
+	lw.known[pid].startTime++
+	lw.scan(pid)
+	if len(lw.pending) != n {
+		t.Errorf("Expected %d libraries after PID reuse, got %d", n, len(lw.pending))
+	}
+
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	lw.known[cmd.Process.Pid] = &knownProcess{paths: make(map[string]bool)}
+	lw.pruned = 0
+	lw.prune()
+	if _, ok := lw.known[cmd.Process.Pid]; ok {
+		t.Error("Exited process isn't pruned")
+	}
+	if _, ok := lw.known[pid]; !ok {
+		t.Error("Running process is pruned")
+	}
+}
@@ -0,0 +1,278 @@
+package link
+
+import (
+	"bufio"
+	"debug/elf"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// Executable defines an executable program on the filesystem.
+type Executable struct {
+	// Path of the executable on the filesystem.
+	path string
+	// Parsed ELF symbols and dynamic symbols offsets.
+	offsets map[string]uint64
+	// Functions in the pclntab, nil if the executable wasn't built by
+	// the Go toolchain.
+	goFuncs map[string]goFunc
+	// Needed to decode the instructions of Go functions.
+	machine   elf.Machine
+	byteOrder binary.ByteOrder
+}
+
+// OpenExecutable opens an executable and parses its symbols, so that
+// they can be used as attach points for uprobes.
+func OpenExecutable(path string) (*Executable, error) {
+	if path == "" {
+		return nil, xerrors.New("path cannot be empty")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, xerrors.Errorf("open file '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	se, err := internal.NewSafeELFFile(f)
+	if err != nil {
+		return nil, xerrors.Errorf("parse ELF file: %w", err)
+	}
+	defer se.Close()
+
+	ex := Executable{
+		path:      path,
+		offsets:   make(map[string]uint64),
+		machine:   se.Machine,
+		byteOrder: se.ByteOrder,
+	}
+
+	if err := ex.load(se); err != nil {
+		return nil, err
+	}
+
+	return &ex, nil
+}
+
+// OpenExecutableInProcess opens an executable as seen by the process
+// with the given pid, which may live in a different mount namespace, for
+// example inside a container.
+//
+// path is interpreted relative to the root directory of the process,
+// including any symlinks in it. An empty path opens the main executable
+// of the process.
+func OpenExecutableInProcess(pid int, path string) (*Executable, error) {
+	if pid <= 0 {
+		return nil, xerrors.Errorf("invalid pid %d", pid)
+	}
+
+	proc := filepath.Join("/proc", strconv.Itoa(pid))
+	if path == "" {
+		// The kernel resolves the magic link even if its target isn't
+		// reachable from the current mount namespace.
+		return OpenExecutable(filepath.Join(proc, "exe"))
+	}
+
+	if !filepath.IsAbs(path) {
+		return nil, xerrors.Errorf("path %s isn't absolute", path)
+	}
+
+	resolved, err := internal.ResolveInRoot(filepath.Join(proc, "root"), path)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenExecutable(resolved)
+}
+
+// OpenSharedLibrary opens a shared library mapped by the process with the
+// given pid, which may live in a different mount namespace.
+//
+// name is either the file name of the library, for example "libc.so.6",
+// or a prefix of it, for example "libc". Returns an error wrapping
+// os.ErrNotExist if the process hasn't mapped a matching library.
+func OpenSharedLibrary(pid int, name string) (*Executable, error) {
+	if pid <= 0 {
+		return nil, xerrors.Errorf("invalid pid %d", pid)
+	}
+
+	if name == "" || strings.ContainsRune(name, '/') {
+		return nil, xerrors.Errorf("invalid library name %q", name)
+	}
+
+	proc := filepath.Join("/proc", strconv.Itoa(pid))
+	maps, err := os.Open(filepath.Join(proc, "maps"))
+	if err != nil {
+		return nil, err
+	}
+	defer maps.Close()
+
+	path, err := findMappedLibrary(maps, name)
+	if err != nil {
+		return nil, xerrors.Errorf("pid %d: %w", pid, err)
+	}
+
+	resolved, err := internal.ResolveInRoot(filepath.Join(proc, "root"), path)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenExecutable(resolved)
+}
+
+// findMappedLibrary returns the path of the first executable mapping in
+// the format of /proc/<pid>/maps which matches the library name.
+func findMappedLibrary(r io.Reader, name string) (string, error) {
+	paths, err := mappedFiles(r)
+	if err != nil {
+		return "", err
+	}
+
+	for _, path := range paths {
+		if matchLibrary(filepath.Base(path), name) {
+			return path, nil
+		}
+	}
+
+	return "", xerrors.Errorf("library %s: %w", name, os.ErrNotExist)
+}
+
+// matchLibrary returns true if the file name of a library is name, or
+// name followed by a version or suffix.
+func matchLibrary(base, name string) bool {
+	return base == name || strings.HasPrefix(base, name+".so") || strings.HasPrefix(base, name+"-")
+}
+
+// mappedFiles returns the paths of all files with an executable mapping
+// in the format of /proc/<pid>/maps, in the order of the mappings.
+func mappedFiles(r io.Reader) ([]string, error) {
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// Lines look like this, the path may contain spaces:
+		// 7f5c8c200000-7f5c8c228000 r-xp 00028000 08:01 1234 /usr/lib/libc.so.6
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) < 6 || !strings.Contains(fields[1], "x") {
+			continue
+		}
+
+		idx := strings.IndexByte(line, '/')
+		if idx == -1 || strings.HasSuffix(line, " (deleted)") {
+			continue
+		}
+
+		paths = append(paths, line[idx:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+func (ex *Executable) load(f *internal.SafeELFFile) error {
+	syms, err := f.Symbols()
+	if err != nil && !xerrors.Is(err, elf.ErrNoSymbols) {
+		return err
+	}
+
+	dynsyms, err := f.DynamicSymbols()
+	if err != nil && !xerrors.Is(err, elf.ErrNoSymbols) {
+		return err
+	}
+
+	syms = append(syms, dynsyms...)
+
+	// Imported functions stay unresolved if the PLT can't be parsed,
+	// the rest of the executable is still usable.
+	stubs, _ := pltStubs(f.File)
+
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) != elf.STT_FUNC {
+			// Symbol not associated with a function or other executable code.
+			continue
+		}
+
+		addr := s.Value
+		if s.Section == elf.SHN_UNDEF {
+			// Imported functions are called via their PLT stub.
+			addr = stubs[s.Name]
+		}
+
+		if _, ok := ex.offsets[s.Name]; ok && addr == 0 {
+			continue
+		}
+
+		ex.offsets[s.Name] = fileOffset(f.File, addr)
+	}
+
+	// Stripped Go binaries retain function names in the pclntab. The
+	// executable is still usable via its ELF symbols if the pclntab
+	// can't be parsed, so the error is ignored.
+	funcs, _ := goFuncs(f)
+	if funcs == nil {
+		return nil
+	}
+
+	ex.goFuncs = make(map[string]goFunc, len(funcs))
+	for _, fn := range funcs {
+		start := fileOffset(f.File, fn.Entry)
+		ex.goFuncs[fn.Name] = goFunc{start, fn.End - fn.Entry}
+
+		if _, ok := ex.offsets[fn.Name]; !ok {
+			ex.offsets[fn.Name] = start
+		}
+	}
+
+	return nil
+}
+
+// fileOffset converts a virtual address in an executable segment to an
+// offset in the file. The address is returned unchanged if no segment
+// contains it.
+func fileOffset(f *elf.File, addr uint64) uint64 {
+	for _, prog := range f.Progs {
+		// Skip uninteresting segments.
+		if prog.Type != elf.PT_LOAD || (prog.Flags&elf.PF_X) == 0 {
+			continue
+		}
+
+		if prog.Vaddr <= addr && addr < (prog.Vaddr+prog.Memsz) {
+			// If the symbol value is contained in the segment, calculate
+			// the symbol offset.
+			//
+			// fn symbol offset = fn symbol VA - .text VA + .text offset
+			//
+			// stackoverflow.com/a/40249502
+			return addr - prog.Vaddr + prog.Off
+		}
+	}
+
+	return addr
+}
+
+// offset returns the offset of symbol in the executable.
+func (ex *Executable) offset(symbol string) (uint64, error) {
+	off, ok := ex.offsets[symbol]
+	if !ok {
+		return 0, xerrors.Errorf("symbol %s: %w", symbol, os.ErrNotExist)
+	}
+
+	if off == 0 {
+		// Symbols with location 0 from section undef are shared library calls
+		// without a PLT stub, their address is only known at runtime.
+		return 0, xerrors.Errorf("cannot resolve %s library call '%s', "+
+			"consider attaching to the library instead", ex.path, symbol)
+	}
+
+	return off, nil
+}
@@ -0,0 +1,174 @@
+package link
+
+import (
+	"bytes"
+	"debug/elf"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf"
+
+	"golang.org/x/xerrors"
+)
+
+func TestOpenExecutableInProcess(t *testing.T) {
+	if _, err := OpenExecutableInProcess(os.Getpid(), ""); err != nil {
+		t.Fatal("Can't open main executable:", err)
+	}
+
+	ex, err := OpenExecutableInProcess(os.Getpid(), "/bin/bash")
+	if err != nil {
+		t.Fatal("Can't open /bin/bash:", err)
+	}
+
+	prog := mustLoadProgram(t, ebpf.Kprobe, 0, "")
+	defer prog.Close()
+
+	up, err := ex.Uprobe("main", prog, nil)
+	if err != nil {
+		t.Fatal("Can't attach uprobe:", err)
+	}
+	up.Close()
+
+	if _, err := OpenExecutableInProcess(os.Getpid(), "bin/bash"); err == nil {
+		t.Error("Relative path is accepted")
+	}
+
+	if _, err := OpenExecutableInProcess(0, "/bin/bash"); err == nil {
+		t.Error("Invalid pid is accepted")
+	}
+}
+
+func TestOpenExecutableCorruptGoSymbols(t *testing.T) {
+	// A .gopclntab without contents can't be read.
+	path := corruptBash(t, ".gnu_debuglink", ".gopclntab")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	ex, err := OpenExecutable(path)
+	if err != nil {
+		t.Fatal("Can't open executable with corrupt pclntab:", err)
+	}
+
+	if _, err := ex.offset("main"); err != nil {
+		t.Error("Can't find ELF symbol:", err)
+	}
+}
+
+func TestOpenExecutableCorruptPLT(t *testing.T) {
+	path := corruptBash(t, ".rela.plt", "")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	ex, err := OpenExecutable(path)
+	if err != nil {
+		t.Fatal("Can't open executable with corrupt PLT:", err)
+	}
+
+	if _, err := ex.offset("main"); err != nil {
+		t.Error("Can't find ELF symbol:", err)
+	}
+
+	if _, err := ex.offset("getpid"); err == nil {
+		t.Error("Imported function is resolved without PLT")
+	}
+}
+
+// corruptBash writes a copy of /bin/bash in which a section doesn't
+// have contents, and optionally renames the section. The new name
+// mustn't be longer than the old one. The caller must remove the
+// directory of the copy.
+func corruptBash(t *testing.T, section, rename string) string {
+	t.Helper()
+
+	data, err := ioutil.ReadFile("/bin/bash")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := -1
+	for i, sec := range f.Sections {
+		if sec.Name == section {
+			idx = i
+		}
+	}
+	if idx == -1 || f.Class != elf.ELFCLASS64 {
+		t.Skipf("/bin/bash doesn't have a %s section", section)
+	}
+
+	if rename != "" {
+		name := []byte(section + "\x00")
+		if bytes.Count(data, name) != 1 {
+			t.Skipf("Can't find name of %s", section)
+		}
+
+		fake := make([]byte, len(name))
+		copy(fake, rename)
+		data = bytes.Replace(data, name, fake, 1)
+	}
+
+	// sh_type follows sh_name in the section header.
+	shoff := f.ByteOrder.Uint64(data[0x28:])
+	shentsize := uint64(f.ByteOrder.Uint16(data[0x3a:]))
+	f.ByteOrder.PutUint32(data[shoff+uint64(idx)*shentsize+4:], uint32(elf.SHT_NOBITS))
+
+	dir, err := ioutil.TempDir("", "ebpf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "bash")
+	if err := ioutil.WriteFile(path, data, 0755); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestOpenSharedLibrary(t *testing.T) {
+	_, err := OpenSharedLibrary(os.Getpid(), "libbogus_ebpf")
+	if !xerrors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist for missing library, got", err)
+	}
+
+	if _, err := OpenSharedLibrary(os.Getpid(), "../libc"); err == nil {
+		t.Error("Library name with a slash is accepted")
+	}
+}
+
+func TestFindMappedLibrary(t *testing.T) {
+	maps := strings.Join([]string{
+		"55d0c0a00000-55d0c0a28000 r--p 00000000 08:01 100 /usr/bin/app",
+		"7f5c8c200000-7f5c8c228000 r--p 00000000 08:01 200 /usr/lib/libc-ro.so",
+		"7f5c8c228000-7f5c8c3bd000 r-xp 00028000 08:01 201 /usr/lib/x86_64-linux-gnu/libc.so.6",
+		"7f5c8c400000-7f5c8c410000 r-xp 00000000 08:01 300 /opt/my libs/libfoo-1.2.so",
+		"7f5c8c500000-7f5c8c510000 r-xp 00000000 08:01 400 /usr/lib/libbar.so.1 (deleted)",
+		"7ffd1c3fe000-7ffd1c400000 r-xp 00000000 00:00 0 [vdso]",
+	}, "\n")
+
+	for name, want := range map[string]string{
+		"libc":      "/usr/lib/x86_64-linux-gnu/libc.so.6",
+		"libc.so.6": "/usr/lib/x86_64-linux-gnu/libc.so.6",
+		"libfoo":    "/opt/my libs/libfoo-1.2.so",
+	} {
+		path, err := findMappedLibrary(strings.NewReader(maps), name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+		} else if path != want {
+			t.Errorf("%s: got %s, want %s", name, path, want)
+		}
+	}
+
+	for _, name := range []string{"libbar", "vdso", "libba"} {
+		if _, err := findMappedLibrary(strings.NewReader(maps), name); !xerrors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: expected os.ErrNotExist, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package link
+
+import (
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// AttachFreplace links an Extension program to the function it
+// replaces.
+//
+// The program must be loaded with ProgramSpec.AttachTarget set to the
+// program containing the function, and ProgramSpec.AttachTo set to the
+// name of the function.
+//
+// Requires at least Linux 5.6.
+func AttachFreplace(prog *ebpf.Program) (Link, error) {
+	progFd, err := programFD(prog)
+	if err != nil {
+		return nil, err
+	}
+
+	if t := prog.ABI().Type; t != ebpf.Extension {
+		return nil, xerrors.Errorf("invalid program type %s, expected Extension", t)
+	}
+
+	attr := bpfRawTracepointOpenAttr{fd: progFd}
+	fd, err := internal.BPF(internal.BPF_RAW_TRACEPOINT_OPEN, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return nil, xerrors.Errorf("can't attach extension: %w", err)
+	}
+
+	return &RawLink{internal.NewFD(uint32(fd))}, nil
+}
@@ -0,0 +1,215 @@
+package link
+
+import (
+	"debug/elf"
+	"debug/gosym"
+	"encoding/binary"
+	"os"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// goFunc is the location of a Go function in the executable.
+type goFunc struct {
+	// Offset of the first instruction in the file.
+	offset uint64
+	size   uint64
+}
+
+// goFuncs returns the functions in the pclntab of a Go executable, or
+// nil if f wasn't built by the Go toolchain.
+func goFuncs(f *internal.SafeELFFile) (funcs []gosym.Func, err error) {
+	defer func() {
+		// debug/gosym doesn't validate the pclntab.
+		if r := recover(); r != nil {
+			funcs = nil
+			err = xerrors.Errorf("reading pclntab panicked: %s", r)
+		}
+	}()
+
+	pclntab := f.Section(".gopclntab")
+	if pclntab == nil {
+		return nil, nil
+	}
+
+	data, err := internal.ReadSection(pclntab)
+	if err != nil {
+		return nil, err
+	}
+
+	// Older pclntab formats are relative to runtime.text, which only
+	// differs from the start of .text if the binary uses cgo.
+	var textStart uint64
+	if text := f.Section(".text"); text != nil {
+		textStart = text.Addr
+	}
+	if syms, err := f.Symbols(); err == nil {
+		for _, sym := range syms {
+			if sym.Name == "runtime.text" {
+				textStart = sym.Value
+				break
+			}
+		}
+	}
+
+	var symtab []byte
+	if sec := f.Section(".gosymtab"); sec != nil {
+		if symtab, err = internal.ReadSection(sec); err != nil {
+			return nil, err
+		}
+	}
+
+	table, err := gosym.NewTable(symtab, gosym.NewLineTable(data, textStart))
+	if err != nil {
+		return nil, err
+	}
+
+	return table.Funcs, nil
+}
+
+// GoReturns returns the offsets of all return instructions of a Go
+// function in the executable, which is found via the pclntab even if the
+// executable is stripped.
+//
+// Returns an error wrapping os.ErrNotExist if the executable doesn't
+// contain the function, and ErrNotSupported if the executable isn't
+// for amd64 or arm64.
+func (ex *Executable) GoReturns(symbol string) ([]uint64, error) {
+	if ex.goFuncs == nil {
+		return nil, xerrors.Errorf("%s isn't a Go executable", ex.path)
+	}
+
+	fn, ok := ex.goFuncs[symbol]
+	if !ok {
+		return nil, xerrors.Errorf("symbol %s: %w", symbol, os.ErrNotExist)
+	}
+
+	file, err := os.Open(ex.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	code := make([]byte, fn.size)
+	if _, err := file.ReadAt(code, int64(fn.offset)); err != nil {
+		return nil, xerrors.Errorf("read %s: %w", symbol, err)
+	}
+
+	var returns []uint64
+	switch ex.machine {
+	case elf.EM_X86_64:
+		returns, err = x86Returns(code)
+	case elf.EM_AARCH64:
+		returns, err = arm64Returns(code, ex.byteOrder)
+	default:
+		return nil, xerrors.Errorf("find returns on %s: %w", ex.machine, internal.ErrNotSupported)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("decode %s: %w", symbol, err)
+	}
+
+	for i := range returns {
+		returns[i] += fn.offset
+	}
+
+	return returns, nil
+}
+
+// arm64Returns returns the offsets of all RET instructions in code.
+func arm64Returns(code []byte, bo binary.ByteOrder) ([]uint64, error) {
+	if len(code)%4 != 0 {
+		return nil, xerrors.Errorf("length %d isn't a multiple of the instruction size", len(code))
+	}
+
+	var returns []uint64
+	for off := 0; off < len(code); off += 4 {
+		// RET Xn, which defaults to X30.
+		if bo.Uint32(code[off:])&0xfffffc1f == 0xd65f0000 {
+			returns = append(returns, uint64(off))
+		}
+	}
+
+	return returns, nil
+}
+
+// GoUretprobe attaches the given eBPF program to all return instructions
+// of a Go function.
+//
+// Uretprobes overwrite the return address on the stack, which breaks Go
+// programs when the runtime moves or unwinds a goroutine stack. Use this
+// instead of Uretprobe for Go executables. Unlike a uretprobe, the program
+// sees the state just before the function returns, so results are still
+// in registers. Tail calls aren't covered.
+//
+// opts.Offset must be zero. Returns an error wrapping ErrNotSupported
+// for architectures other than amd64 and arm64.
+func (ex *Executable) GoUretprobe(symbol string, prog *ebpf.Program, opts *UprobeOptions) (Link, error) {
+	if opts == nil {
+		opts = &UprobeOptions{}
+	}
+
+	if opts.Offset != 0 {
+		return nil, xerrors.New("offset must be zero")
+	}
+
+	returns, err := ex.GoReturns(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(returns) == 0 {
+		return nil, xerrors.Errorf("symbol %s doesn't return", symbol)
+	}
+
+	ul := &uprobesLink{}
+	for _, off := range returns {
+		o := *opts
+		o.Offset = off
+
+		l, err := ex.uprobe(symbol, prog, &o, false)
+		if err != nil {
+			ul.Close()
+			return nil, xerrors.Errorf("return at %#x: %w", off, err)
+		}
+
+		ul.links = append(ul.links, l)
+	}
+
+	return ul, nil
+}
+
+// uprobesLink is a program attached to multiple uprobes.
+type uprobesLink struct {
+	links []Link
+}
+
+var _ Link = (*uprobesLink)(nil)
+
+func (ul *uprobesLink) isLink() {}
+
+// Pin is not supported, since the uprobes consist of multiple links.
+func (ul *uprobesLink) Pin(string) error {
+	return xerrors.Errorf("can't pin uprobes: %w", internal.ErrNotSupported)
+}
+
+// Update is not supported, since the uprobes on the return
+// instructions of the function would briefly run different programs.
+// Attach a Dispatcher to switch programs.
+func (ul *uprobesLink) Update(*ebpf.Program) error {
+	return xerrors.Errorf("can't update uprobes: %w", internal.ErrNotSupported)
+}
+
+// Close removes all uprobes.
+func (ul *uprobesLink) Close() error {
+	var firstErr error
+	for _, l := range ul.links {
+		if err := l.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	ul.links = nil
+	return firstErr
+}
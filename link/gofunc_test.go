@@ -0,0 +1,176 @@
+package link
+
+import (
+	"encoding/binary"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/cilium/ebpf"
+
+	"golang.org/x/xerrors"
+)
+
+//go:noinline
+func goUretprobeTarget(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestGoUretprobe(t *testing.T) {
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		t.Skip("Unsupported architecture", runtime.GOARCH)
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ex, err := OpenExecutable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const symbol = "github.com/cilium/ebpf/link.goUretprobeTarget"
+	returns, err := ex.GoReturns(symbol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(returns) == 0 {
+		t.Fatal("No returns found")
+	}
+
+	if runtime.GOARCH == "amd64" {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		for _, off := range returns {
+			var op [1]byte
+			if _, err := f.ReadAt(op[:], int64(off)); err != nil {
+				t.Fatal(err)
+			}
+			if op[0] != 0xc3 {
+				t.Errorf("Return at %#x is %#x, not RET", off, op[0])
+			}
+		}
+	}
+
+	prog := mustLoadProgram(t, ebpf.Kprobe, 0, "")
+	defer prog.Close()
+
+	l, err := ex.GoUretprobe(symbol, prog, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goUretprobeTarget(1, 2)
+	if err := l.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+
+	if _, err := ex.GoReturns("bogus_ebpf_symbol"); !xerrors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist for missing symbol, got", err)
+	}
+
+	if _, err := ex.GoUretprobe(symbol, prog, &UprobeOptions{Offset: 1}); err == nil {
+		t.Error("GoUretprobe accepts an offset")
+	}
+
+	bash, err := OpenExecutable("/bin/bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bash.GoReturns("main"); err == nil {
+		t.Error("GoReturns accepts a C executable")
+	}
+}
+
+func TestX86InstructionLength(t *testing.T) {
+	for _, tc := range []struct {
+		code   []byte
+		length int
+	}{
+		{[]byte{0xc3}, 1},                                                 // RET
+		{[]byte{0xc2, 0x08, 0x00}, 3},                                     // RET $8
+		{[]byte{0x48, 0x89, 0xe5}, 3},                                     // MOVQ SP, BP
+		{[]byte{0x48, 0x83, 0xec, 0x18}, 4},                               // SUBQ $0x18, SP
+		{[]byte{0x48, 0x8b, 0x44, 0x24, 0x08}, 5},                         // MOVQ 8(SP), AX
+		{[]byte{0x48, 0x8d, 0x05, 0x00, 0x00, 0x00, 0x00}, 7},             // LEAQ (RIP), AX
+		{[]byte{0x48, 0xb8, 1, 2, 3, 4, 5, 6, 7, 8}, 10},                  // MOVQ $imm64, AX
+		{[]byte{0x66, 0xb8, 0x01, 0x00}, 4},                               // MOVW $1, AX
+		{[]byte{0xe8, 0x00, 0x00, 0x00, 0x00}, 5},                         // CALL rel32
+		{[]byte{0x0f, 0x84, 0x00, 0x00, 0x00, 0x00}, 6},                   // JE rel32
+		{[]byte{0xf6, 0xc1, 0x01}, 3},                                     // TESTB $1, CL
+		{[]byte{0xf7, 0xd8}, 2},                                           // NEGL AX
+		{[]byte{0x66, 0x0f, 0x1f, 0x84, 0x00, 0x00, 0x00, 0x00, 0x00}, 9}, // NOPW
+		{[]byte{0xc5, 0xf8, 0x77}, 3},                                     // VZEROUPPER
+		{[]byte{0xc4, 0xe3, 0x7d, 0x39, 0xc1, 0x01}, 6},                   // VEXTRACTI128
+		{[]byte{0x62, 0xf1, 0x7c, 0x48, 0x10, 0x06}, 6},                   // VMOVUPS (SI), Z0
+		{[]byte{0xf3, 0xc3}, 2},                                           // REP RET
+	} {
+		length, err := x86InstructionLength(tc.code)
+		if err != nil {
+			t.Errorf("% x: %s", tc.code, err)
+		} else if length != tc.length {
+			t.Errorf("% x: got length %d, want %d", tc.code, length, tc.length)
+		}
+	}
+
+	for _, code := range [][]byte{
+		{},
+		{0x06},
+		{0x48},
+		{0x48, 0x8b},
+		{0xe8, 0x00},
+	} {
+		if _, err := x86InstructionLength(code); err == nil {
+			t.Errorf("% x: no error", code)
+		}
+	}
+}
+
+func TestX86Returns(t *testing.T) {
+	code := []byte{
+		0x48, 0x39, 0xd8, // CMPQ BX, AX
+		0x7e, 0x01, // JLE +1
+		0xc3,             // RET
+		0x48, 0x29, 0xc3, // SUBQ AX, BX, contains the RET opcode
+		0xf3, 0xc3, // REP RET
+		0xcc, // INT3 padding
+	}
+
+	returns, err := x86Returns(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []uint64{5, 9}; !reflect.DeepEqual(returns, want) {
+		t.Errorf("Got returns %v, want %v", returns, want)
+	}
+}
+
+func TestArm64Returns(t *testing.T) {
+	code := make([]byte, 12)
+	binary.LittleEndian.PutUint32(code[0:], 0xd10043ff) // SUB SP, SP, #16
+	binary.LittleEndian.PutUint32(code[4:], 0xd65f03c0) // RET
+	binary.LittleEndian.PutUint32(code[8:], 0xd65f0200) // RET X16
+
+	returns, err := arm64Returns(code, binary.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []uint64{4, 8}; !reflect.DeepEqual(returns, want) {
+		t.Errorf("Got returns %v, want %v", returns, want)
+	}
+
+	if _, err := arm64Returns(code[:3], binary.LittleEndian); err == nil {
+		t.Error("Accepts truncated code")
+	}
+}
@@ -0,0 +1,160 @@
+package link
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// KprobeModule attaches the given eBPF program to a perf event that fires
+// when the given symbol of a kernel module starts executing.
+//
+// The module must be loaded, unless opts.ModuleTimeout is set. In that
+// case KprobeModule waits for the module to be loaded before attaching.
+// Returns an error wrapping os.ErrNotExist if the module isn't loaded or
+// doesn't contain symbol.
+//
+// Requires at least Linux 4.1.
+func KprobeModule(module, symbol string, prog *ebpf.Program, opts *KprobeOptions) (Link, error) {
+	return kprobeModule(module, symbol, prog, opts, false)
+}
+
+// KretprobeModule attaches the given eBPF program to a perf event that
+// fires right before the given symbol of a kernel module exits.
+//
+// See KprobeModule.
+func KretprobeModule(module, symbol string, prog *ebpf.Program, opts *KprobeOptions) (Link, error) {
+	return kprobeModule(module, symbol, prog, opts, true)
+}
+
+func kprobeModule(module, symbol string, prog *ebpf.Program, opts *KprobeOptions, ret bool) (Link, error) {
+	if opts == nil {
+		opts = &KprobeOptions{}
+	}
+
+	module, err := moduleName(module)
+	if err != nil {
+		return nil, err
+	}
+
+	if !moduleLoaded(module) {
+		if opts.ModuleTimeout == 0 {
+			return nil, xerrors.Errorf("module %s isn't loaded: %w", module, os.ErrNotExist)
+		}
+
+		if err := WaitForModule(module, opts.ModuleTimeout); err != nil {
+			return nil, err
+		}
+	}
+
+	// Kprobes accept symbols of the form module:symbol.
+	return kprobe(module+":"+symbol, prog, opts, ret)
+}
+
+// WaitForModule blocks until a kernel module is loaded, or the timeout
+// expires. A negative timeout waits indefinitely.
+//
+// Returns an error wrapping os.ErrNotExist if the module wasn't loaded
+// in time. Modules are detected via uevents, which requires at least
+// Linux 4.18 to not return before the module is initialized.
+func WaitForModule(module string, timeout time.Duration) error {
+	module, err := moduleName(module)
+	if err != nil {
+		return err
+	}
+
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return xerrors.Errorf("can't open uevent socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	// Group 1 receives uevents from the kernel, group 2 those
+	// forwarded by udev.
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1}); err != nil {
+		return xerrors.Errorf("can't subscribe to uevents: %w", err)
+	}
+
+	// Check after subscribing to not miss the module being loaded in
+	// between.
+	if moduleLoaded(module) {
+		return nil
+	}
+
+	var (
+		deadline = time.Now().Add(timeout)
+		buf      = make([]byte, 8192)
+		fds      = []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		added    = []byte("add@/module/" + module + "\x00")
+	)
+
+	for {
+		msec := -1
+		if timeout >= 0 {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return xerrors.Errorf("module %s: %w", module, os.ErrNotExist)
+			}
+
+			ms := (remaining + time.Millisecond - 1) / time.Millisecond
+			if ms > math.MaxInt32 {
+				ms = math.MaxInt32
+			}
+			msec = int(ms)
+		}
+
+		n, err := unix.Poll(fds, msec)
+		if xerrors.Is(err, unix.EINTR) || n == 0 {
+			continue
+		}
+		if err != nil {
+			return xerrors.Errorf("can't poll uevent socket: %w", err)
+		}
+
+		n, from, err := unix.Recvfrom(fd, buf, 0)
+		if xerrors.Is(err, unix.ENOBUFS) {
+			// Uevents were dropped, one of them may have been ours.
+			if moduleLoaded(module) {
+				return nil
+			}
+			continue
+		}
+		if err != nil {
+			return xerrors.Errorf("can't read uevent: %w", err)
+		}
+
+		// Only trust uevents sent by the kernel.
+		if sa, ok := from.(*unix.SockaddrNetlink); !ok || sa.Pid != 0 {
+			continue
+		}
+
+		if bytes.HasPrefix(buf[:n], added) {
+			return nil
+		}
+	}
+}
+
+// moduleName validates the name of a kernel module. The kernel treats
+// dashes and underscores in module names the same, but uses underscores
+// in sysfs and kallsyms.
+func moduleName(module string) (string, error) {
+	if module == "" || filepath.Base(module) != module || strings.HasPrefix(module, ".") {
+		return "", xerrors.Errorf("invalid module name %q", module)
+	}
+	return strings.Replace(module, "-", "_", -1), nil
+}
+
+// moduleLoaded returns true if a module has been initialized.
+func moduleLoaded(module string) bool {
+	state, err := ioutil.ReadFile(filepath.Join("/sys/module", module, "initstate"))
+	return err == nil && string(bytes.TrimSpace(state)) == "live"
+}
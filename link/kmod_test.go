@@ -0,0 +1,43 @@
+package link
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+
+	"golang.org/x/xerrors"
+)
+
+func TestKprobeModule(t *testing.T) {
+	prog := mustLoadProgram(t, ebpf.Kprobe, 0, "")
+	defer prog.Close()
+
+	_, err := KprobeModule("bogus_ebpf_module", "bogus_ebpf_symbol", prog, nil)
+	if !xerrors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist for missing module, got", err)
+	}
+
+	opts := &KprobeOptions{ModuleTimeout: 10 * time.Millisecond}
+	_, err = KretprobeModule("bogus-ebpf-module", "bogus_ebpf_symbol", prog, opts)
+	if !xerrors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist after waiting for module, got", err)
+	}
+
+	if _, err := KprobeModule("../bogus", "bogus_ebpf_symbol", prog, nil); err == nil {
+		t.Error("Invalid module name is accepted")
+	}
+}
+
+func TestWaitForModule(t *testing.T) {
+	start := time.Now()
+	err := WaitForModule("bogus_ebpf_module", 10*time.Millisecond)
+	if !xerrors.Is(err, os.ErrNotExist) {
+		t.Fatal("Expected os.ErrNotExist, got", err)
+	}
+
+	if time.Since(start) < 10*time.Millisecond {
+		t.Error("WaitForModule returned before the timeout")
+	}
+}
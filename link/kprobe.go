@@ -0,0 +1,85 @@
+package link
+
+import (
+	"os"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// KprobeOptions defines additional parameters that will be used
+// when opening a Kprobe Link.
+//
+// There is no option to scope a kprobe to a process or cgroup: the kernel
+// runs programs attached to kprobes before it applies the pid and cgroup
+// filters of perf_event_open. Filter via bpf_get_current_pid_tgid() or
+// bpf_get_current_cgroup_id() in the program instead.
+type KprobeOptions struct {
+	// Cookie is an arbitrary value that can be fetched from the program
+	// via bpf_get_attach_cookie().
+	//
+	// Requires at least Linux 5.15.
+	Cookie uint64
+	// ModuleTimeout is how long KprobeModule and KretprobeModule wait
+	// for the module to be loaded. Zero doesn't wait, a negative value
+	// waits indefinitely.
+	ModuleTimeout time.Duration
+}
+
+// Kprobe attaches the given eBPF program to a perf event that fires when the
+// given kernel symbol starts executing.
+//
+// opts may be nil. Probes are created via tracefs on kernels without the
+// kprobe PMU, which was added in Linux 4.17. Kernel lockdown and SELinux
+// may deny creating probes, see LockdownError and SELinuxError.
+//
+// Requires at least Linux 4.1.
+func Kprobe(symbol string, prog *ebpf.Program, opts *KprobeOptions) (Link, error) {
+	return kprobe(symbol, prog, opts, false)
+}
+
+// Kretprobe attaches the given eBPF program to a perf event that fires right
+// before the given kernel symbol exits.
+//
+// opts may be nil.
+//
+// Requires at least Linux 4.1.
+func Kretprobe(symbol string, prog *ebpf.Program, opts *KprobeOptions) (Link, error) {
+	return kprobe(symbol, prog, opts, true)
+}
+
+func kprobe(symbol string, prog *ebpf.Program, opts *KprobeOptions, ret bool) (Link, error) {
+	if opts == nil {
+		opts = &KprobeOptions{}
+	}
+
+	if symbol == "" {
+		return nil, xerrors.New("symbol name cannot be empty")
+	}
+
+	if _, err := programFD(prog); err != nil {
+		return nil, err
+	}
+
+	if t := prog.ABI().Type; t != ebpf.Kprobe {
+		return nil, xerrors.Errorf("invalid program type %s, expected Kprobe", t)
+	}
+
+	pe, event, err := openProbe("kprobe", ret, symbol, 0, -1)
+	if xerrors.Is(err, unix.ENOENT) {
+		return nil, xerrors.Errorf("symbol %s: %w", symbol, os.ErrNotExist)
+	}
+	if xerrors.Is(err, unix.EINVAL) {
+		// The kernel refuses to probe functions on its blacklist, or
+		// in the middle of an instruction.
+		return nil, xerrors.Errorf("symbol %s: %w", symbol, errNotTraceable)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("can't create kprobe: %w", err)
+	}
+
+	return attachPerfEvent(pe, event, prog, opts.Cookie)
+}
@@ -0,0 +1,350 @@
+package link
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// KprobeMultiOptions defines additional parameters that will be used
+// when opening a KprobeMulti Link.
+type KprobeMultiOptions struct {
+	// Symbols takes a list of kernel symbol names to attach an ebpf program to.
+	//
+	// Symbols may contain shell patterns as understood by filepath.Match,
+	// for example "vfs_*". Patterns are expanded using the list of
+	// traceable functions in tracefs, or /proc/kallsyms if tracefs is
+	// not accessible.
+	//
+	// Mutually exclusive with Addresses.
+	Symbols []string
+
+	// Addresses takes a list of kernel symbol addresses in case they can not
+	// be referred to by name.
+	//
+	// Note that only start addresses can be specified, since the fprobe API
+	// limits the attach point to the function entry or return.
+	//
+	// Mutually exclusive with Symbols.
+	Addresses []uintptr
+
+	// Cookies specifies arbitrary values that can be fetched from an eBPF
+	// program via `bpf_get_attach_cookie()`.
+	//
+	// If set, its length should be equal to the length of Symbols or Addresses.
+	// Each Cookie is assigned to the Symbol or Address specified at the
+	// corresponding slice index. Cookies can't be combined with patterns
+	// in Symbols.
+	Cookies []uint64
+}
+
+// KprobeMulti attaches the given eBPF program to the entry point of a given set
+// of kernel symbols.
+//
+// The program must have been loaded with AttachTraceKprobeMulti.
+//
+// Requires at least Linux 5.18.
+func KprobeMulti(prog *ebpf.Program, opts KprobeMultiOptions) (Link, error) {
+	return kprobeMulti(prog, opts, 0)
+}
+
+// KretprobeMulti attaches the given eBPF program to the return point of a given
+// set of kernel symbols.
+//
+// The program must have been loaded with AttachTraceKprobeMulti.
+//
+// Requires at least Linux 5.18.
+func KretprobeMulti(prog *ebpf.Program, opts KprobeMultiOptions) (Link, error) {
+	return kprobeMulti(prog, opts, unix.BPF_F_KPROBE_MULTI_RETURN)
+}
+
+type bpfLinkCreateKprobeMultiAttr struct {
+	bpfLinkCreateAttr
+	kprobeMultiFlags uint32
+	count            uint32
+	syms             internal.Pointer
+	addrs            internal.Pointer
+	cookies          internal.Pointer
+}
+
+func kprobeMulti(prog *ebpf.Program, opts KprobeMultiOptions, flags uint32) (Link, error) {
+	progFd, err := programFD(prog)
+	if err != nil {
+		return nil, err
+	}
+
+	if t := prog.ABI().Type; t != ebpf.Kprobe {
+		return nil, xerrors.Errorf("invalid program type %s, expected Kprobe", t)
+	}
+
+	syms := opts.Symbols
+	if hasPatterns(syms) {
+		if len(opts.Cookies) > 0 {
+			return nil, xerrors.New("cookies can't be combined with symbol patterns")
+		}
+
+		syms, err = expandKernelSymbols(syms)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	addrs := len(opts.Addresses)
+	cookies := len(opts.Cookies)
+
+	switch {
+	case len(syms) == 0 && addrs == 0:
+		return nil, xerrors.New("one of Symbols or Addresses is required")
+	case len(syms) != 0 && addrs != 0:
+		return nil, xerrors.New("Symbols and Addresses are mutually exclusive")
+	case cookies > 0 && cookies != len(syms) && cookies != addrs:
+		return nil, xerrors.New("Cookies must be exactly Symbols or Addresses in length")
+	}
+
+	if err := haveBPFLinkKprobeMulti(); err != nil {
+		return nil, err
+	}
+
+	attr := bpfLinkCreateKprobeMultiAttr{
+		bpfLinkCreateAttr: bpfLinkCreateAttr{
+			progFd:     progFd,
+			attachType: ebpf.AttachTraceKprobeMulti,
+		},
+		kprobeMultiFlags: flags,
+	}
+
+	var symPtrs []unsafe.Pointer
+	if len(syms) != 0 {
+		symPtrs = make([]unsafe.Pointer, 0, len(syms))
+		for _, sym := range syms {
+			buf := make([]byte, len(sym)+1)
+			copy(buf, sym)
+			symPtrs = append(symPtrs, unsafe.Pointer(&buf[0]))
+		}
+
+		attr.count = uint32(len(symPtrs))
+		attr.syms = internal.NewPointer(unsafe.Pointer(&symPtrs[0]))
+	}
+
+	if addrs != 0 {
+		attr.count = uint32(addrs)
+		attr.addrs = internal.NewPointer(unsafe.Pointer(&opts.Addresses[0]))
+	}
+
+	if cookies != 0 {
+		attr.cookies = internal.NewPointer(unsafe.Pointer(&opts.Cookies[0]))
+	}
+
+	fd, err := bpfLinkCreate(unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if xerrors.Is(err, unix.ESRCH) {
+		return nil, xerrors.Errorf("couldn't find one or more symbols: %w", os.ErrNotExist)
+	}
+	if xerrors.Is(err, unix.EOPNOTSUPP) {
+		// The kernel was built without CONFIG_FPROBE.
+		return nil, xerrors.Errorf("can't attach kprobe.multi: %w", internal.ErrNotSupported)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("can't attach kprobe.multi: %w", err)
+	}
+
+	return &RawLink{fd}, nil
+}
+
+func hasPatterns(syms []string) bool {
+	for _, sym := range syms {
+		if strings.ContainsAny(sym, "*?[") {
+			return true
+		}
+	}
+	return false
+}
+
+// expandKernelSymbols replaces any patterns in syms with the matching
+// traceable kernel functions. Functions on the kprobe blacklist don't
+// match patterns.
+func expandKernelSymbols(syms []string) ([]string, error) {
+	available, err := traceableFunctions()
+	if err != nil {
+		return nil, err
+	}
+
+	blacklist := readKprobeBlacklist(kprobeBlacklistPath)
+
+	seen := make(map[string]bool)
+	var result []string
+	for _, sym := range syms {
+		if !strings.ContainsAny(sym, "*?[") {
+			if !seen[sym] {
+				seen[sym] = true
+				result = append(result, sym)
+			}
+			continue
+		}
+
+		matched := false
+		for _, fn := range available {
+			ok, err := filepath.Match(sym, fn)
+			if err != nil {
+				return nil, xerrors.Errorf("symbol pattern %q: %w", sym, err)
+			}
+			if !ok || blacklist[fn] {
+				continue
+			}
+
+			matched = true
+			if !seen[fn] {
+				seen[fn] = true
+				result = append(result, fn)
+			}
+		}
+
+		if !matched {
+			return nil, xerrors.Errorf("symbol pattern %q: %w", sym, os.ErrNotExist)
+		}
+	}
+
+	return result, nil
+}
+
+// traceableFunctions returns a sorted list of kernel functions which
+// can be attached to.
+func traceableFunctions() ([]string, error) {
+	for _, path := range []string{
+		"/sys/kernel/tracing/available_filter_functions",
+		"/sys/kernel/debug/tracing/available_filter_functions",
+	} {
+		fns, err := readSymbolList(path, func(fields []string) string {
+			return fields[0]
+		})
+		if err == nil {
+			return fns, nil
+		}
+	}
+
+	// Fall back to text symbols in kallsyms, which includes functions
+	// that can't be traced.
+	fns, err := readSymbolList("/proc/kallsyms", func(fields []string) string {
+		if len(fields) < 3 || (fields[1] != "t" && fields[1] != "T") {
+			return ""
+		}
+		return fields[2]
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("can't read kernel symbols: %w", err)
+	}
+
+	return fns, nil
+}
+
+// kprobeBlacklistPath lists the functions which can't be probed.
+const kprobeBlacklistPath = "/sys/kernel/debug/kprobes/blacklist"
+
+// readKprobeBlacklist returns the functions on the kprobe blacklist.
+//
+// Returns an empty blacklist if debugfs isn't accessible, in which case
+// attaching to blacklisted functions fails instead.
+func readKprobeBlacklist(path string) map[string]bool {
+	// 0xffffffff81000000-0xffffffff81000010	native_get_debugreg
+	fns, err := readSymbolList(path, func(fields []string) string {
+		if len(fields) < 2 {
+			return ""
+		}
+		return fields[1]
+	})
+	if err != nil {
+		internal.Debug("Can't read kprobe blacklist", "error", err)
+		return nil
+	}
+
+	blacklist := make(map[string]bool, len(fns))
+	for _, fn := range fns {
+		blacklist[fn] = true
+	}
+	return blacklist
+}
+
+func readSymbolList(path string, symbol func(fields []string) string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var fns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		// Skip functions in kernel modules, which are suffixed with
+		// the module name in brackets.
+		if last := fields[len(fields)-1]; strings.HasPrefix(last, "[") {
+			continue
+		}
+
+		if fn := symbol(fields); fn != "" {
+			fns = append(fns, fn)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(fns)
+	return fns, nil
+}
+
+var haveBPFLinkKprobeMulti = internal.FeatureTest("bpf_link_kprobe_multi", "5.18", func() bool {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:       "probe_kpm_link",
+		Type:       ebpf.Kprobe,
+		AttachType: ebpf.AttachTraceKprobeMulti,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		return false
+	}
+	defer prog.Close()
+
+	progFd, err := programFD(prog)
+	if err != nil {
+		return false
+	}
+
+	// Attaching to a non-existent symbol returns ESRCH on supported
+	// kernels, and EINVAL otherwise.
+	sym := []byte("ebpf_feature_probe_nonexistent\x00")
+	syms := []unsafe.Pointer{unsafe.Pointer(&sym[0])}
+	attr := bpfLinkCreateKprobeMultiAttr{
+		bpfLinkCreateAttr: bpfLinkCreateAttr{
+			progFd:     progFd,
+			attachType: ebpf.AttachTraceKprobeMulti,
+		},
+		count: 1,
+		syms:  internal.NewPointer(unsafe.Pointer(&syms[0])),
+	}
+
+	fd, err := bpfLinkCreate(unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return !xerrors.Is(err, unix.EINVAL)
+	}
+
+	fd.Close()
+	return true
+})
@@ -0,0 +1,74 @@
+package link
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	"golang.org/x/xerrors"
+)
+
+func TestKprobeMulti(t *testing.T) {
+	prog := mustLoadProgram(t, ebpf.Kprobe, ebpf.AttachTraceKprobeMulti, "")
+	defer prog.Close()
+
+	km, err := KprobeMulti(prog, KprobeMultiOptions{
+		Symbols: []string{"vfs_read", "vfs_write"},
+		Cookies: []uint64{1, 2},
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if xerrors.Is(err, ebpf.ErrNotSupported) {
+		t.Skip("Kernel doesn't support kprobe.multi:", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := km.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+
+	kr, err := KretprobeMulti(prog, KprobeMultiOptions{
+		Symbols: []string{"vfs_read"},
+	})
+	if err != nil {
+		t.Fatal("Can't attach kretprobe.multi:", err)
+	}
+	kr.Close()
+
+	_, err = KprobeMulti(prog, KprobeMultiOptions{
+		Symbols: []string{"bogus_ebpf_symbol"},
+	})
+	if !xerrors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist for missing symbol, got", err)
+	}
+
+	_, err = KprobeMulti(prog, KprobeMultiOptions{
+		Symbols: []string{"vfs_read"},
+		Cookies: []uint64{1, 2},
+	})
+	if err == nil {
+		t.Error("Mismatched number of cookies should fail")
+	}
+}
+
+func TestExpandKernelSymbols(t *testing.T) {
+	syms, err := expandKernelSymbols([]string{"vfs_rea?", "vfs_read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(syms) != 1 || syms[0] != "vfs_read" {
+		t.Error("Expected vfs_read, got", syms)
+	}
+
+	if _, err := expandKernelSymbols([]string{"bogus_ebpf_*"}); !xerrors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist for pattern without matches, got", err)
+	}
+}
+
+func TestHaveBPFLinkKprobeMulti(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveBPFLinkKprobeMulti)
+}
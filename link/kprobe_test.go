@@ -0,0 +1,40 @@
+package link
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+func TestKprobe(t *testing.T) {
+	prog := mustLoadProgram(t, ebpf.Kprobe, 0, "")
+	defer prog.Close()
+
+	k, err := Kprobe("vprintk", prog, &KprobeOptions{Cookie: 1})
+	if xerrors.Is(err, internal.ErrNotSupported) {
+		t.Skip("Kernel doesn't support the kprobe PMU:", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+
+	k, err = Kretprobe("vprintk", prog, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+
+	_, err = Kprobe("bogus_ebpf_symbol", prog, nil)
+	if !xerrors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist for missing symbol, got", err)
+	}
+}
@@ -0,0 +1,239 @@
+package link
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// KprobesOptions control Kprobes and Kretprobes.
+type KprobesOptions struct {
+	// BatchSize is the number of kprobes which are created concurrently
+	// if the kernel doesn't support kprobe.multi. Defaults to the number
+	// of CPUs.
+	BatchSize int
+	// ContinueOnError attaches to as many functions as possible, instead
+	// of failing if any of them can't be attached to. Failures are listed
+	// in KprobesResult.Failed, an *AttachError is only returned if no
+	// function could be attached to.
+	//
+	// kprobe.multi attaches to all functions or none, so kprobes are
+	// created one by one if it fails.
+	ContinueOnError bool
+}
+
+// KprobesResult is returned by Kprobes and Kretprobes.
+type KprobesResult struct {
+	Program *ebpf.Program
+	Link    Link
+	// Failed contains the functions which couldn't be attached to, if
+	// KprobesOptions.ContinueOnError is set.
+	Failed []*TargetError
+}
+
+// Kprobes loads a program and attaches it to the entry point of all
+// kernel functions matching patterns.
+//
+// Patterns are shell patterns as understood by filepath.Match, for
+// example "tcp_*", or plain function names. They are expanded like
+// KprobeMultiOptions.Symbols, functions on the kprobe blacklist in
+// debugfs are skipped.
+//
+// The program is attached via kprobe.multi if the kernel supports it.
+// Otherwise one kprobe is created per function, which is a lot slower
+// for many functions. spec must be of type Kprobe, its AttachType is
+// chosen depending on the mechanism.
+//
+// The caller must close the returned program and link.
+//
+// Requires at least Linux 4.1.
+func Kprobes(spec *ebpf.ProgramSpec, patterns []string, opts *KprobesOptions) (*KprobesResult, error) {
+	return kprobes(spec, patterns, opts, false)
+}
+
+// Kretprobes loads a program and attaches it to the return of all
+// kernel functions matching patterns.
+//
+// See Kprobes.
+func Kretprobes(spec *ebpf.ProgramSpec, patterns []string, opts *KprobesOptions) (*KprobesResult, error) {
+	return kprobes(spec, patterns, opts, true)
+}
+
+func kprobes(spec *ebpf.ProgramSpec, patterns []string, opts *KprobesOptions, ret bool) (*KprobesResult, error) {
+	if opts == nil {
+		opts = &KprobesOptions{}
+	}
+
+	if spec.Type != ebpf.Kprobe {
+		return nil, xerrors.Errorf("invalid program type %s, expected Kprobe", spec.Type)
+	}
+
+	if len(patterns) == 0 {
+		return nil, xerrors.New("patterns cannot be empty")
+	}
+
+	syms, err := expandKernelSymbols(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	err = haveBPFLinkKprobeMulti()
+	if err == nil {
+		var (
+			prog *ebpf.Program
+			l    Link
+		)
+		prog, l, err = loadKprobeMulti(spec, syms, ret)
+		if err == nil {
+			return &KprobesResult{Program: prog, Link: l}, nil
+		}
+		if !opts.ContinueOnError && !xerrors.Is(err, internal.ErrNotSupported) {
+			return nil, err
+		}
+	} else if !xerrors.Is(err, internal.ErrNotSupported) {
+		return nil, err
+	}
+
+	internal.Debug("Attaching kprobes one by one", "count", len(syms), "reason", err)
+
+	// Programs loaded for kprobe.multi can't be attached to perf events.
+	spec = spec.Copy()
+	spec.AttachType = ebpf.AttachNone
+
+	prog, err := ebpf.NewProgram(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	l, failed, err := attachKprobes(prog, syms, opts, ret)
+	if err != nil {
+		prog.Close()
+		return nil, err
+	}
+
+	return &KprobesResult{Program: prog, Link: l, Failed: failed}, nil
+}
+
+// loadKprobeMulti loads spec for kprobe.multi and attaches it to syms.
+//
+// Returns an error wrapping ErrNotSupported if the kernel lacks fprobe
+// support, which is only detected when attaching.
+func loadKprobeMulti(spec *ebpf.ProgramSpec, syms []string, ret bool) (*ebpf.Program, Link, error) {
+	spec = spec.Copy()
+	spec.AttachType = ebpf.AttachTraceKprobeMulti
+
+	prog, err := ebpf.NewProgram(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var flags uint32
+	if ret {
+		flags = unix.BPF_F_KPROBE_MULTI_RETURN
+	}
+
+	l, err := kprobeMulti(prog, KprobeMultiOptions{Symbols: syms}, flags)
+	if err != nil {
+		prog.Close()
+		return nil, nil, err
+	}
+
+	return prog, l, nil
+}
+
+// attachKprobes creates a kprobe for each symbol, opts.BatchSize of
+// them at a time.
+//
+// Returns the symbols which failed if opts.ContinueOnError is set, and
+// an error if no kprobe could be created.
+func attachKprobes(prog *ebpf.Program, syms []string, opts *KprobesOptions, ret bool) (*kprobesLink, []*TargetError, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = runtime.NumCPU()
+	}
+
+	var (
+		kl       = &kprobesLink{}
+		links    = make([]Link, len(syms))
+		errs     = make([]error, len(syms))
+		attachEr = &AttachError{}
+	)
+
+	for start := 0; start < len(syms); start += batchSize {
+		end := start + batchSize
+		if end > len(syms) {
+			end = len(syms)
+		}
+
+		var wg sync.WaitGroup
+		for i := start; i < end; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				links[i], errs[i] = kprobe(syms[i], prog, nil, ret)
+			}(i)
+		}
+		wg.Wait()
+
+		for i := start; i < end; i++ {
+			if errs[i] != nil {
+				attachEr.failed(syms[i], errs[i])
+				continue
+			}
+
+			kl.links = append(kl.links, links[i])
+			attachEr.Attached = append(attachEr.Attached, syms[i])
+		}
+
+		if len(attachEr.Failed) > 0 && !opts.ContinueOnError {
+			kl.Close()
+			// Remaining symbols are neither attached nor failed.
+			attachEr.Attached = nil
+			return nil, nil, attachEr
+		}
+	}
+
+	if len(kl.links) == 0 {
+		return nil, nil, attachEr
+	}
+
+	return kl, attachEr.Failed, nil
+}
+
+// kprobesLink is a program attached to multiple kprobes.
+type kprobesLink struct {
+	links []Link
+}
+
+var _ Link = (*kprobesLink)(nil)
+
+func (kl *kprobesLink) isLink() {}
+
+// Pin is not supported, since the kprobes consist of multiple links.
+func (kl *kprobesLink) Pin(string) error {
+	return xerrors.Errorf("can't pin kprobes: %w", internal.ErrNotSupported)
+}
+
+// Update is not supported, since kprobes are attached one by one and
+// would run different programs while being updated. Attach a Dispatcher
+// to switch programs.
+func (kl *kprobesLink) Update(*ebpf.Program) error {
+	return xerrors.Errorf("can't update kprobes: %w", internal.ErrNotSupported)
+}
+
+// Close removes all kprobes.
+func (kl *kprobesLink) Close() error {
+	var firstErr error
+	for _, l := range kl.links {
+		if err := l.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	kl.links = nil
+	return firstErr
+}
@@ -0,0 +1,103 @@
+package link
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+func TestKprobes(t *testing.T) {
+	spec := &ebpf.ProgramSpec{
+		Type: ebpf.Kprobe,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "GPL",
+	}
+
+	res, err := Kprobes(spec, []string{"vfs_rea?", "vfs_write"}, &KprobesOptions{BatchSize: 1})
+	if xerrors.Is(err, internal.ErrNotSupported) {
+		t.Skip("Kernel doesn't support kprobes:", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Program.Close()
+
+	if err := res.Link.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+
+	res, err = Kretprobes(spec, []string{"vfs_read"}, nil)
+	if err != nil {
+		t.Fatal("Can't attach kretprobes:", err)
+	}
+	res.Program.Close()
+	res.Link.Close()
+
+	if _, err := Kprobes(spec, []string{"bogus_ebpf_*"}, nil); !xerrors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist for pattern without matches, got", err)
+	}
+
+	opts := &KprobesOptions{ContinueOnError: true}
+	res, err = Kprobes(spec, []string{"vfs_read", "bogus_ebpf_symbol"}, opts)
+	if err != nil {
+		t.Fatal("Partial failure returns an error:", err)
+	}
+	defer res.Program.Close()
+	defer res.Link.Close()
+
+	if len(res.Failed) != 1 || res.Failed[0].Target != "bogus_ebpf_symbol" || res.Failed[0].Reason != FailureNotFound {
+		t.Error("Unexpected failures:", res.Failed)
+	}
+
+	_, err = Kprobes(spec, []string{"bogus_ebpf_symbol"}, opts)
+	var ae *AttachError
+	if !xerrors.As(err, &ae) {
+		t.Fatal("Expected an AttachError if nothing is attached, got", err)
+	}
+}
+
+func TestKprobesInvalid(t *testing.T) {
+	spec := &ebpf.ProgramSpec{Type: ebpf.SocketFilter}
+	if _, err := Kprobes(spec, []string{"vfs_read"}, nil); err == nil {
+		t.Error("Kprobes accepts a socket filter")
+	}
+
+	spec = &ebpf.ProgramSpec{Type: ebpf.Kprobe}
+	if _, err := Kprobes(spec, nil, nil); err == nil {
+		t.Error("Kprobes accepts empty patterns")
+	}
+}
+
+func TestReadKprobeBlacklist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ebpf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "blacklist")
+	contents := "0xffffffff81000000-0xffffffff81000010\tnative_get_debugreg\n" +
+		"0xffffffffc0000000-0xffffffffc0000010\tmod_fn [mod]\n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	blacklist := readKprobeBlacklist(path)
+	if len(blacklist) != 1 || !blacklist["native_get_debugreg"] {
+		t.Error("Unexpected blacklist:", blacklist)
+	}
+
+	if blacklist := readKprobeBlacklist(filepath.Join(dir, "missing")); len(blacklist) != 0 {
+		t.Error("Missing blacklist isn't empty:", blacklist)
+	}
+}
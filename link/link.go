@@ -0,0 +1,233 @@
+package link
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/sys"
+
+	"golang.org/x/xerrors"
+)
+
+// Link represents a Program attached to a BPF hook.
+type Link interface {
+	// Pin persists a link past the lifetime of the process.
+	//
+	// Calling Close on a pinned Link will not break the link
+	// until the pin is removed.
+	Pin(fileName string) error
+
+	// Close frees resources.
+	//
+	// The link will be broken unless it has been pinned. A link
+	// may continue past the lifetime of the process if Close is
+	// not called.
+	Close() error
+
+	// Update replaces the attached program without detaching it.
+	//
+	// Programs which are currently running may continue to use the old
+	// program until they finish. Use Synchronize to wait for them.
+	Update(new *ebpf.Program) error
+
+	// Prevent external users from implementing this interface.
+	isLink()
+}
+
+// RawLink is the low-level API to bpf_link.
+//
+// You should consider using the higher level interfaces in this
+// package instead.
+type RawLink struct {
+	fd *internal.FD
+}
+
+var _ Link = (*RawLink)(nil)
+
+// LoadPinnedRawLink loads a persisted link from a bpffs.
+func LoadPinnedRawLink(fileName string) (*RawLink, error) {
+	fd, err := internal.BPFObjGet(fileName)
+	if err != nil {
+		return nil, xerrors.Errorf("can't load pinned link: %w", err)
+	}
+
+	return &RawLink{fd}, nil
+}
+
+func (l *RawLink) isLink() {}
+
+// FD returns the raw file descriptor.
+func (l *RawLink) FD() int {
+	fd, err := l.fd.Value()
+	if err != nil {
+		return -1
+	}
+	return int(fd)
+}
+
+// Close breaks the link.
+//
+// Use Pin if you want to make the link persistent.
+//
+// It is safe to call Close multiple times.
+func (l *RawLink) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.fd.Close()
+}
+
+// Pin persists a link past the lifetime of the process.
+//
+// Calling Close on a pinned Link will not break the link
+// until the pin is removed.
+func (l *RawLink) Pin(fileName string) error {
+	if err := internal.BPFObjPin(fileName, l.fd); err != nil {
+		return xerrors.Errorf("can't pin link: %w", err)
+	}
+	return nil
+}
+
+// RawLinkUpdateOptions control the behaviour of RawLink.UpdateArgs.
+type RawLinkUpdateOptions struct {
+	New *ebpf.Program
+	// Old is the program that is expected to be attached. The update
+	// fails if another program is attached. Ignored if nil.
+	Old   *ebpf.Program
+	Flags uint32
+}
+
+// Update implements the Link interface.
+func (l *RawLink) Update(new *ebpf.Program) error {
+	return l.UpdateArgs(RawLinkUpdateOptions{New: new})
+}
+
+// UpdateArgs atomically replaces the program attached to the link.
+//
+// Requires at least Linux 5.7. Not all kinds of links can be updated.
+func (l *RawLink) UpdateArgs(opts RawLinkUpdateOptions) error {
+	newFd, err := programFD(opts.New)
+	if err != nil {
+		return err
+	}
+
+	var oldFd uint32
+	flags := opts.Flags
+	if opts.Old != nil {
+		oldFd, err = programFD(opts.Old)
+		if err != nil {
+			return err
+		}
+		flags |= unix.BPF_F_REPLACE
+	}
+
+	linkFd, err := l.fd.Value()
+	if err != nil {
+		return xerrors.Errorf("can't update link: %w", err)
+	}
+
+	attr := sys.LinkUpdateAttr{
+		LinkFD:    linkFd,
+		NewProgFD: newFd,
+		Flags:     flags,
+		OldProgFD: oldFd,
+	}
+	if err := bpfLinkUpdate(&attr); err != nil {
+		return xerrors.Errorf("can't update link: %w", err)
+	}
+	return nil
+}
+
+// ID uniquely identifies a link.
+type ID uint32
+
+// Type is the kind of a link.
+type Type uint32
+
+// Valid link types.
+const (
+	UnspecifiedType Type = iota
+	RawTracepointType
+	TracingType
+	CgroupType
+	IterType
+	NetNsType
+	XDPType
+	PerfEventType
+	KprobeMultiType
+	StructOpsType
+	NetfilterType
+	TCXType
+	UprobeMultiType
+	NetkitType
+)
+
+var typeNames = []string{
+	"Unspecified",
+	"RawTracepoint",
+	"Tracing",
+	"Cgroup",
+	"Iter",
+	"NetNs",
+	"XDP",
+	"PerfEvent",
+	"KprobeMulti",
+	"StructOps",
+	"Netfilter",
+	"TCX",
+	"UprobeMulti",
+	"Netkit",
+}
+
+func (t Type) String() string {
+	if int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return fmt.Sprintf("Type(%d)", uint32(t))
+}
+
+// Info contains metadata about a link.
+type Info struct {
+	Type Type
+	ID   ID
+	// The program the link attaches.
+	Program ebpf.ProgramID
+}
+
+// GetNextID returns the ID of the next link.
+//
+// Returns ebpf.ErrNotExist if there is no next link.
+func GetNextID(startID ID) (ID, error) {
+	id, err := bpfLinkGetNextID(uint32(startID))
+	return ID(id), err
+}
+
+// NewFromID returns the link with the given ID.
+//
+// Returns ebpf.ErrNotExist if there is no link with the given ID.
+func NewFromID(id ID) (*RawLink, error) {
+	fd, err := bpfLinkGetFDByID(uint32(id))
+	if err != nil {
+		return nil, xerrors.Errorf("link %d: %w", id, err)
+	}
+
+	return &RawLink{fd}, nil
+}
+
+// Info returns metadata about the link.
+//
+// Requires at least Linux 5.8.
+func (l *RawLink) Info() (*Info, error) {
+	info, err := bpfGetLinkInfoByFD(l.fd)
+	if err != nil {
+		return nil, xerrors.Errorf("link info: %w", err)
+	}
+
+	return &Info{
+		Type(info.Type),
+		ID(info.ID),
+		ebpf.ProgramID(info.ProgID),
+	}, nil
+}
@@ -0,0 +1,60 @@
+package link
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+func TestRawLinkInfo(t *testing.T) {
+	prog := mustLoadProgram(t, ebpf.RawTracepoint, 0, "")
+	defer prog.Close()
+
+	link, err := AttachRawTracepoint(RawTracepointOptions{
+		Name:    "sched_process_exec",
+		Program: prog,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer link.Close()
+
+	var ids []ID
+	for id := ID(0); ; {
+		id, err = GetNextID(id)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		t.Fatal("GetNextID doesn't return any links:", err)
+	}
+
+	progID, err := prog.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range ids {
+		raw, err := NewFromID(id)
+		if err != nil {
+			continue
+		}
+
+		info, err := raw.Info()
+		raw.Close()
+		if err != nil {
+			t.Fatal("Can't get link info:", err)
+		}
+
+		if info.Program == progID {
+			if info.Type != RawTracepointType {
+				t.Error("Expected RawTracepointType, got", info.Type)
+			}
+			return
+		}
+	}
+
+	t.Error("Link isn't returned by GetNextID")
+}
@@ -0,0 +1,89 @@
+package link
+
+import (
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+
+	"golang.org/x/xerrors"
+)
+
+// Protocol families supported by netfilter programs, see NFPROTO_* in
+// linux/netfilter.h.
+const (
+	NetfilterProtoIPv4 uint32 = 2
+	NetfilterProtoIPv6 uint32 = 10
+)
+
+// Netfilter hooks, see enum nf_inet_hooks in linux/netfilter.h.
+const (
+	NetfilterInetPreRouting uint32 = iota
+	NetfilterInetLocalIn
+	NetfilterInetForward
+	NetfilterInetLocalOut
+	NetfilterInetPostRouting
+)
+
+// NetfilterAttachFlags change the behaviour of a netfilter link.
+type NetfilterAttachFlags uint32
+
+const (
+	// NetfilterIPDefrag enables IP packet defragmentation before the
+	// program is invoked. Requires at least Linux 6.6.
+	NetfilterIPDefrag NetfilterAttachFlags = 1 << 0
+)
+
+// NetfilterOptions control attaching a program to a netfilter hook.
+type NetfilterOptions struct {
+	// Program must be of type Netfilter, loaded with AttachNetfilter.
+	Program *ebpf.Program
+	// The protocol family, for example NetfilterProtoIPv4.
+	ProtocolFamily uint32
+	// The hook to attach to, for example NetfilterInetLocalIn.
+	HookNumber uint32
+	// Priority within the hook. Programs with a lower priority run first.
+	// The extreme values NF_IP_PRI_FIRST and NF_IP_PRI_LAST are reserved.
+	Priority int32
+	// Extra flags passed to the netfilter hook.
+	NetfilterFlags NetfilterAttachFlags
+}
+
+type bpfLinkCreateNetfilterAttr struct {
+	bpfLinkCreateAttr
+	protocolFamily uint32
+	hookNumber     uint32
+	priority       int32
+	netfilterFlags NetfilterAttachFlags
+}
+
+// AttachNetfilter links a netfilter BPF program to a netfilter hook.
+//
+// Requires at least Linux 6.4.
+func AttachNetfilter(opts NetfilterOptions) (Link, error) {
+	progFd, err := programFD(opts.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	if t := opts.Program.ABI().Type; t != ebpf.Netfilter {
+		return nil, xerrors.Errorf("invalid program type %s, expected Netfilter", t)
+	}
+
+	attr := bpfLinkCreateNetfilterAttr{
+		bpfLinkCreateAttr: bpfLinkCreateAttr{
+			progFd:     progFd,
+			attachType: ebpf.AttachNetfilter,
+		},
+		protocolFamily: opts.ProtocolFamily,
+		hookNumber:     opts.HookNumber,
+		priority:       opts.Priority,
+		netfilterFlags: opts.NetfilterFlags,
+	}
+
+	fd, err := bpfLinkCreate(unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return nil, xerrors.Errorf("can't attach netfilter program: %w", err)
+	}
+
+	return &RawLink{fd}, nil
+}
@@ -0,0 +1,53 @@
+package link
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestAttachNetfilter(t *testing.T) {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:       ebpf.Netfilter,
+		AttachType: ebpf.AttachNetfilter,
+		Instructions: asm.Instructions{
+			// Return NF_ACCEPT
+			asm.Mov.Imm(asm.R0, 1),
+			asm.Return(),
+		},
+		License: "GPL",
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	l, err := AttachNetfilter(NetfilterOptions{
+		Program:        prog,
+		ProtocolFamily: NetfilterProtoIPv4,
+		HookNumber:     NetfilterInetLocalOut,
+		Priority:       -128,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+
+	wrongType := mustLoadProgram(t, ebpf.RawTracepoint, 0, "")
+	defer wrongType.Close()
+
+	_, err = AttachNetfilter(NetfilterOptions{
+		Program:        wrongType,
+		ProtocolFamily: NetfilterProtoIPv4,
+		HookNumber:     NetfilterInetLocalOut,
+	})
+	if err == nil {
+		t.Error("Attaching a program of the wrong type should fail")
+	}
+}
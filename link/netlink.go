@@ -0,0 +1,39 @@
+package link
+
+// Netlink attaches programs to network interfaces via rtnetlink.
+//
+// AttachXDP and AttachTC use bpf_link whenever the kernel supports it,
+// and only fall back to Netlink on older kernels. The package doesn't
+// speak netlink itself, so that applications can plug in the netlink
+// stack they already use, for example github.com/vishvananda/netlink.
+// This avoids opening additional netlink sockets, which is error prone
+// when switching between network namespaces.
+//
+// Implementations are responsible for operating in the network
+// namespace the interface belongs to.
+type Netlink interface {
+	// SetXDP attaches a program to an interface, replacing any program
+	// attached in the same mode. A progFd of -1 detaches the program.
+	SetXDP(ifindex, progFd int, flags XDPAttachFlags) error
+
+	// AttachTCFilter attaches a program as a direct action classifier,
+	// creating a clsact qdisc on the interface if necessary.
+	//
+	// A filter with a zero Handle is created, and Priority and Handle
+	// are updated to identify it. Otherwise the program of the existing
+	// filter is replaced.
+	AttachTCFilter(filter *TCFilter, progFd int) error
+
+	// DetachTCFilter removes a filter created by AttachTCFilter.
+	DetachTCFilter(filter *TCFilter) error
+}
+
+// TCFilter identifies a traffic control filter.
+type TCFilter struct {
+	Ifindex int
+	// Egress selects the egress hook of the clsact qdisc instead
+	// of ingress.
+	Egress   bool
+	Priority uint16
+	Handle   uint32
+}
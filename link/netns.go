@@ -0,0 +1,130 @@
+package link
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// NetNsLink is a program attached to a network namespace.
+type NetNsLink struct {
+	RawLink
+}
+
+// AttachNetNs attaches a program to a network namespace.
+//
+// ns is a file descriptor of a network namespace, for example obtained
+// by opening /proc/self/ns/net. prog must be of type SkLookup or
+// FlowDissector.
+//
+// Requires at least Linux 5.8, SkLookup requires at least Linux 5.9.
+func AttachNetNs(ns int, prog *ebpf.Program) (*NetNsLink, error) {
+	progFd, err := programFD(prog)
+	if err != nil {
+		return nil, err
+	}
+
+	if ns < 0 {
+		return nil, xerrors.New("invalid network namespace fd")
+	}
+
+	var attachType ebpf.AttachType
+	switch t := prog.ABI().Type; t {
+	case ebpf.SkLookup:
+		attachType = ebpf.AttachSkLookup
+	case ebpf.FlowDissector:
+		attachType = ebpf.AttachFlowDissector
+	default:
+		return nil, xerrors.Errorf("invalid program type %s, expected SkLookup or FlowDissector", t)
+	}
+
+	attr := bpfLinkCreateAttr{
+		progFd:     progFd,
+		targetFd:   uint32(ns),
+		attachType: attachType,
+	}
+
+	fd, err := bpfLinkCreate(unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return nil, xerrors.Errorf("can't attach to network namespace: %w", err)
+	}
+
+	return &NetNsLink{RawLink{fd}}, nil
+}
+
+// LoadPinnedNetNs loads a network namespace link from a bpffs.
+func LoadPinnedNetNs(fileName string) (*NetNsLink, error) {
+	link, err := LoadPinnedRawLink(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NetNsLink{*link}, nil
+}
+
+// DoInNetNs runs fn in the network namespace ns, a file descriptor
+// like the one accepted by AttachNetNs.
+//
+// XDP and TC programs attach to interfaces in the network namespace of
+// the calling thread. DoInNetNs allows attaching to interfaces in other
+// namespaces, for example of containers:
+//
+//	err := link.DoInNetNs(ns, func() error {
+//		iface, err := net.InterfaceByName("eth0")
+//		if err != nil {
+//			return err
+//		}
+//		l, err = link.AttachXDP(link.XDPOptions{Program: prog, Interface: iface.Index})
+//		return err
+//	})
+//
+// fn runs on a locked OS thread, which is switched back to its original
+// namespace afterwards, even if fn panics. Goroutines started by fn don't run in ns. If the
+// thread can't be switched back it stays locked, so that the runtime
+// discards it once the calling goroutine exits.
+func DoInNetNs(ns int, fn func() error) (err error) {
+	runtime.LockOSThread()
+
+	orig, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return xerrors.Errorf("can't open current network namespace: %w", err)
+	}
+	defer orig.Close()
+
+	if err := unix.Setns(ns, unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return xerrors.Errorf("can't enter network namespace: %w", err)
+	}
+
+	// Restore the namespace even if fn panics, since the caller may
+	// recover and keep using the thread.
+	defer func() {
+		if restoreErr := unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET); restoreErr != nil {
+			err = xerrors.Errorf("can't restore network namespace: %w", restoreErr)
+			return
+		}
+		runtime.UnlockOSThread()
+	}()
+
+	return fn()
+}
+
+// DoInNetNsPath is like DoInNetNs, but opens the network namespace at
+// path. This is either /proc/<pid>/ns/net or a bind mount of it, like
+// the ones created by "ip netns add".
+func DoInNetNsPath(path string, fn func() error) error {
+	ns, err := os.Open(path)
+	if err != nil {
+		return xerrors.Errorf("can't open network namespace: %w", err)
+	}
+	defer ns.Close()
+
+	return DoInNetNs(int(ns.Fd()), fn)
+}
@@ -0,0 +1,85 @@
+package link
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+func TestAttachNetNs(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.9", "sk_lookup links")
+
+	prog := mustLoadProgram(t, ebpf.SkLookup, ebpf.AttachSkLookup, "")
+	defer prog.Close()
+
+	ns, err := os.Open("/proc/self/ns/net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ns.Close()
+
+	link, err := AttachNetNs(int(ns.Fd()), prog)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't attach to network namespace:", err)
+	}
+
+	info, err := link.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Type != NetNsType {
+		t.Error("Expected NetNsType, got", info.Type)
+	}
+
+	if err := link.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAttachNetNsInvalidProgram(t *testing.T) {
+	prog := mustLoadProgram(t, ebpf.SocketFilter, 0, "")
+	defer prog.Close()
+
+	if _, err := AttachNetNs(0, prog); err == nil {
+		t.Error("AttachNetNs accepts a socket filter")
+	}
+}
+
+func TestDoInNetNs(t *testing.T) {
+	want, err := os.Readlink("/proc/self/ns/net")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var have string
+	err = DoInNetNsPath("/proc/self/ns/net", func() error {
+		have, err = os.Readlink(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+		return err
+	})
+	if xerrors.Is(err, unix.EPERM) {
+		t.Skip("Can't switch network namespace:", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have != want {
+		t.Errorf("fn ran in %s instead of %s", have, want)
+	}
+
+	fnErr := xerrors.New("fn failed")
+	err = DoInNetNsPath("/proc/self/ns/net", func() error { return fnErr })
+	if !xerrors.Is(err, fnErr) {
+		t.Error("Error of fn isn't returned, got", err)
+	}
+
+	if err := DoInNetNs(-1, func() error { return nil }); err == nil {
+		t.Error("DoInNetNs accepts an invalid fd")
+	}
+}
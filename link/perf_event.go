@@ -0,0 +1,283 @@
+package link
+
+import (
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// Kprobes, uprobes and tracepoints are all exposed to user space as
+// perf events. A program is attached to such an event either via a
+// bpf_link (Linux 5.15+), which also allows passing a cookie, or via
+// PERF_EVENT_IOC_SET_BPF on older kernels.
+
+// PerfEventOptions control attaching a program to a perf event.
+type PerfEventOptions struct {
+	// Program must be of type Kprobe, TracePoint or PerfEvent.
+	Program *ebpf.Program
+	// PerfEvent is a file descriptor returned by perf_event_open(2).
+	// The caller retains ownership of the descriptor.
+	PerfEvent int
+	// Cookie is an arbitrary value that can be fetched from the program
+	// via bpf_get_attach_cookie().
+	Cookie uint64
+}
+
+// AttachPerfEvent links a BPF program to an existing perf event.
+//
+// Requires at least Linux 5.15.
+func AttachPerfEvent(opts PerfEventOptions) (Link, error) {
+	progFd, err := programFD(opts.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.PerfEvent < 0 {
+		return nil, xerrors.Errorf("invalid perf event: %w", internal.ErrClosedFd)
+	}
+
+	if err := haveBPFLinkPerfEvent(); err != nil {
+		return nil, err
+	}
+
+	fd, err := bpfLinkCreatePerfEvent(progFd, uint32(opts.PerfEvent), opts.Cookie)
+	if err != nil {
+		return nil, xerrors.Errorf("can't attach perf event: %w", err)
+	}
+
+	return &RawLink{fd}, nil
+}
+
+type bpfLinkCreatePerfEventAttr struct {
+	bpfLinkCreateAttr
+	bpfCookie uint64
+}
+
+func bpfLinkCreatePerfEvent(progFd, perfFd uint32, cookie uint64) (*internal.FD, error) {
+	attr := bpfLinkCreatePerfEventAttr{
+		bpfLinkCreateAttr: bpfLinkCreateAttr{
+			progFd:     progFd,
+			targetFd:   perfFd,
+			attachType: ebpf.AttachPerfEvent,
+		},
+		bpfCookie: cookie,
+	}
+	return bpfLinkCreate(unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+}
+
+// perfEventLink is a program attached to a perf event which is owned
+// by the link.
+type perfEventLink struct {
+	// link is nil if the program was attached via ioctl.
+	link *RawLink
+	pe   *internal.FD
+	// event is removed once pe is closed, it is nil unless the probe
+	// was created via tracefs.
+	event *tracefsProbe
+}
+
+var _ Link = (*perfEventLink)(nil)
+
+func (pl *perfEventLink) isLink() {}
+
+// Pin persists the link past the lifetime of the process.
+//
+// Requires at least Linux 5.15.
+func (pl *perfEventLink) Pin(fileName string) error {
+	if pl.link == nil {
+		return xerrors.Errorf("can't pin perf event: %w", internal.ErrNotSupported)
+	}
+	return pl.link.Pin(fileName)
+}
+
+// Update implements the Link interface.
+//
+// Programs attached to perf events can't be replaced, use a Dispatcher
+// instead.
+func (pl *perfEventLink) Update(*ebpf.Program) error {
+	return xerrors.Errorf("can't update perf event: %w", internal.ErrNotSupported)
+}
+
+// Close detaches the program and frees the perf event.
+func (pl *perfEventLink) Close() error {
+	var linkErr error
+	if pl.link != nil {
+		linkErr = pl.link.Close()
+	}
+
+	if err := pl.pe.Close(); err != nil {
+		return xerrors.Errorf("can't close perf event: %w", err)
+	}
+	if pl.event != nil {
+		if err := pl.event.remove(); err != nil {
+			return xerrors.Errorf("can't remove probe: %w", err)
+		}
+		pl.event = nil
+	}
+	if linkErr != nil {
+		return xerrors.Errorf("can't close link: %w", linkErr)
+	}
+	return nil
+}
+
+// attachPerfEvent attaches prog to pe, which is owned by the returned
+// Link together with event, which may be nil. Both are freed if an error
+// is returned.
+func attachPerfEvent(pe *internal.FD, event *tracefsProbe, prog *ebpf.Program, cookie uint64) (Link, error) {
+	lnk, err := attachPerfEventFD(pe, prog, cookie)
+	if err != nil {
+		pe.Close()
+		if event != nil {
+			event.remove()
+		}
+		return nil, err
+	}
+	lnk.event = event
+	return lnk, nil
+}
+
+func attachPerfEventFD(pe *internal.FD, prog *ebpf.Program, cookie uint64) (*perfEventLink, error) {
+	progFd, err := programFD(prog)
+	if err != nil {
+		return nil, err
+	}
+
+	perfFd, err := pe.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	linkErr := haveBPFLinkPerfEvent()
+	if linkErr == nil {
+		fd, err := bpfLinkCreatePerfEvent(progFd, perfFd, cookie)
+		if err != nil {
+			return nil, xerrors.Errorf("can't create bpf_link: %w", err)
+		}
+		return &perfEventLink{link: &RawLink{fd}, pe: pe}, nil
+	}
+
+	if cookie != 0 {
+		return nil, xerrors.Errorf("cookies require bpf_link: %w", linkErr)
+	}
+
+	internal.Debug("Attaching to perf event via ioctl", "program", prog, "error", linkErr)
+
+	if err := unix.IoctlSetInt(int(perfFd), unix.PERF_EVENT_IOC_SET_BPF, int(progFd)); err != nil {
+		return nil, xerrors.Errorf("can't attach program to perf event: %w", err)
+	}
+
+	if err := unix.IoctlSetInt(int(perfFd), unix.PERF_EVENT_IOC_ENABLE, 0); err != nil {
+		return nil, xerrors.Errorf("can't enable perf event: %w", err)
+	}
+
+	return &perfEventLink{pe: pe}, nil
+}
+
+// openPMUProbe creates a kprobe or uprobe using the dynamic PMU of the
+// same name.
+//
+// target is the kernel symbol or path of the executable, offset is relative
+// to target. pid is -1 to trace all processes.
+func openPMUProbe(pmu string, ret bool, target string, offset uint64, pid int) (*internal.FD, error) {
+	typ, err := readUint64FromFile("/sys/bus/event_source/devices/" + pmu + "/type")
+	if err != nil {
+		return nil, xerrors.Errorf("can't find %s PMU: %w", pmu, internal.ErrNotSupported)
+	}
+
+	var config uint64
+	if ret {
+		bit, err := retprobeBit(pmu)
+		if err != nil {
+			return nil, err
+		}
+		config |= 1 << bit
+	}
+
+	str := append([]byte(target), 0)
+	attr := unix.PerfEventAttr{
+		Type:        uint32(typ),
+		Size:        uint32(unsafe.Sizeof(unix.PerfEventAttr{})),
+		Config:      config,
+		Sample_type: unix.PERF_SAMPLE_RAW,
+		Sample:      1,
+		Wakeup:      1,
+		Ext1:        uint64(uintptr(unsafe.Pointer(&str[0]))),
+		Ext2:        offset,
+	}
+
+	fd, err := unix.PerfEventOpen(&attr, pid, 0, -1, unix.PERF_FLAG_FD_CLOEXEC)
+	runtime.KeepAlive(str)
+	if err != nil {
+		return nil, wrapDenied(err)
+	}
+
+	return internal.NewFD(uint32(fd)), nil
+}
+
+// retprobeBit returns the bit in perf_event_attr.config which turns
+// a probe of the given PMU into a return probe.
+func retprobeBit(pmu string) (uint64, error) {
+	path := "/sys/bus/event_source/devices/" + pmu + "/format/retprobe"
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, xerrors.Errorf("can't read %s: %w", path, err)
+	}
+
+	// The file contains something like "config:0".
+	str := strings.TrimPrefix(strings.TrimSpace(string(data)), "config:")
+	bit, err := strconv.ParseUint(str, 10, 6)
+	if err != nil {
+		return 0, xerrors.Errorf("can't parse %s: %w", path, err)
+	}
+
+	return bit, nil
+}
+
+func readUint64FromFile(path string) (uint64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+var haveBPFLinkPerfEvent = internal.FeatureTest("bpf_link_perf_event", "5.15", func() bool {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type: ebpf.Kprobe,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		return false
+	}
+	defer prog.Close()
+
+	// Passing an invalid perf event returns EBADF on supported kernels,
+	// and EINVAL otherwise.
+	attr := bpfLinkCreatePerfEventAttr{
+		bpfLinkCreateAttr: bpfLinkCreateAttr{
+			progFd:     uint32(prog.FD()),
+			targetFd:   ^uint32(0),
+			attachType: ebpf.AttachPerfEvent,
+		},
+	}
+	fd, err := bpfLinkCreate(unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err == nil {
+		fd.Close()
+		return true
+	}
+	return xerrors.Is(err, unix.EBADF)
+})
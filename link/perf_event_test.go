@@ -0,0 +1,48 @@
+package link
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+func TestAttachPerfEvent(t *testing.T) {
+	testutils.SkipIfNoTracefs(t)
+
+	prog := mustLoadProgram(t, ebpf.TracePoint, 0, "")
+	defer prog.Close()
+
+	id, err := traceEventID("syscalls", "sys_enter_getpid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err := unix.PerfEventOpen(&unix.PerfEventAttr{
+		Type:   unix.PERF_TYPE_TRACEPOINT,
+		Config: id,
+	}, -1, 0, -1, unix.PERF_FLAG_FD_CLOEXEC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fd)
+
+	pe, err := AttachPerfEvent(PerfEventOptions{
+		Program:   prog,
+		PerfEvent: fd,
+		Cookie:    1,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pe.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+}
+
+func TestHaveBPFLinkPerfEvent(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveBPFLinkPerfEvent)
+}
@@ -0,0 +1,93 @@
+package link
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+
+	"golang.org/x/xerrors"
+)
+
+// pltStubs returns the virtual addresses of the PLT stubs of functions
+// imported from shared libraries, indexed by symbol name.
+//
+// Calls from the executable to an imported function go through its
+// stub, which jumps to the address in the GOT. The GOT entry is resolved
+// lazily by the dynamic linker, but the stub stays the same. Returns an
+// empty map if the executable doesn't have a PLT, for example because it
+// is linked statically or with -fno-plt.
+func pltStubs(f *elf.File) (map[string]uint64, error) {
+	var (
+		jumpSlot  uint32
+		firstStub uint64
+		stubSize  uint64 = 16
+	)
+
+	switch f.Machine {
+	case elf.EM_X86_64:
+		jumpSlot = uint32(elf.R_X86_64_JMP_SLOT)
+		// The first entry of .plt calls into the dynamic linker.
+		firstStub = 16
+	case elf.EM_AARCH64:
+		jumpSlot = uint32(elf.R_AARCH64_JUMP_SLOT)
+		firstStub = 32
+	default:
+		return nil, nil
+	}
+
+	rela := f.Section(".rela.plt")
+	plt := f.Section(".plt")
+	if rela == nil || plt == nil || f.Class != elf.ELFCLASS64 {
+		return nil, nil
+	}
+
+	if sec := f.Section(".plt.sec"); sec != nil {
+		// Executables built with Indirect Branch Tracking have a second
+		// PLT without the entry for the dynamic linker.
+		plt = sec
+		firstStub = 0
+	}
+
+	data, err := rela.Data()
+	if err != nil {
+		return nil, xerrors.Errorf("read .rela.plt: %w", err)
+	}
+
+	dynsyms, err := f.DynamicSymbols()
+	if xerrors.Is(err, elf.ErrNoSymbols) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("read dynamic symbols: %w", err)
+	}
+
+	stubs := make(map[string]uint64)
+	rd := bytes.NewReader(data)
+	for i := uint64(0); rd.Len() > 0; i++ {
+		var rel elf.Rela64
+		if err := binary.Read(rd, f.ByteOrder, &rel); err != nil {
+			return nil, xerrors.Errorf("read .rela.plt: %w", err)
+		}
+
+		// Other relocations like R_X86_64_IRELATIVE occupy a stub, but
+		// don't refer to a symbol.
+		if elf.R_TYPE64(rel.Info) != jumpSlot {
+			continue
+		}
+
+		// DynamicSymbols omits the null symbol at index zero.
+		sym := elf.R_SYM64(rel.Info)
+		if sym == 0 || int(sym) > len(dynsyms) {
+			continue
+		}
+
+		addr := plt.Addr + firstStub + i*stubSize
+		if addr+stubSize > plt.Addr+plt.Size {
+			return nil, xerrors.Errorf("PLT stub %d is outside of %s", i, plt.Name)
+		}
+
+		stubs[dynsyms[sym-1].Name] = addr
+	}
+
+	return stubs, nil
+}
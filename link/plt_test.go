@@ -0,0 +1,71 @@
+package link
+
+import (
+	"bytes"
+	"debug/elf"
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+func TestPLTStubs(t *testing.T) {
+	f, err := elf.Open("/bin/bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if f.Machine != elf.EM_X86_64 {
+		t.Skip("Stub contents are only checked on amd64")
+	}
+
+	stubs, err := pltStubs(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stubs) == 0 {
+		t.Fatal("No PLT stubs found")
+	}
+
+	text := f.Section(".plt")
+	if sec := f.Section(".plt.sec"); sec != nil {
+		text = sec
+	}
+	data, err := text.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, addr := range stubs {
+		stub := data[addr-text.Addr:]
+		// Either JMP *rel32(%rip) or ENDBR64 for IBT.
+		if !bytes.HasPrefix(stub, []byte{0xff, 0x25}) && !bytes.HasPrefix(stub, []byte{0xf3, 0x0f, 0x1e, 0xfa}) {
+			t.Errorf("Stub of %s at %#x starts with % x", name, addr, stub[:4])
+		}
+	}
+}
+
+func TestUprobeImportedFunction(t *testing.T) {
+	ex, err := OpenExecutable("/bin/bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// bash calls but doesn't implement getpid.
+	off, err := ex.offset("getpid")
+	if err != nil {
+		t.Fatal("Can't resolve imported function:", err)
+	}
+	if off == 0 {
+		t.Fatal("Imported function has offset zero")
+	}
+
+	prog := mustLoadProgram(t, ebpf.Kprobe, 0, "")
+	defer prog.Close()
+
+	up, err := ex.Uprobe("getpid", prog, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	up.Close()
+}
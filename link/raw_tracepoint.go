@@ -0,0 +1,65 @@
+package link
+
+import (
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// RawTracepointOptions control attaching a program to a raw tracepoint.
+type RawTracepointOptions struct {
+	// Tracepoint name. Must be empty for BTF-enabled tracepoints
+	// (tp_btf), which are resolved via ProgramSpec.AttachTo when
+	// loading the program.
+	Name string
+	// Program must be of type RawTracepoint, RawTracepointWritable, or
+	// Tracing loaded with AttachTraceRawTp.
+	Program *ebpf.Program
+}
+
+type bpfRawTracepointOpenAttr struct {
+	name internal.Pointer
+	fd   uint32
+	_    uint32
+}
+
+// AttachRawTracepoint links a BPF program to a raw_tracepoint.
+//
+// Requires at least Linux 4.17, BTF-enabled tracepoints require
+// at least Linux 5.5.
+func AttachRawTracepoint(opts RawTracepointOptions) (Link, error) {
+	progFd, err := programFD(opts.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts.Program.ABI().Type {
+	case ebpf.RawTracepoint, ebpf.RawTracepointWritable:
+		if opts.Name == "" {
+			return nil, xerrors.New("missing tracepoint name")
+		}
+	case ebpf.Tracing:
+		if opts.Name != "" {
+			return nil, xerrors.New("BTF-enabled tracepoints don't take a name, use ProgramSpec.AttachTo")
+		}
+	default:
+		return nil, xerrors.Errorf("invalid program type %s", opts.Program.ABI().Type)
+	}
+
+	attr := bpfRawTracepointOpenAttr{
+		fd: progFd,
+	}
+	if opts.Name != "" {
+		attr.name = internal.NewStringPointer(opts.Name)
+	}
+
+	fd, err := internal.BPF(internal.BPF_RAW_TRACEPOINT_OPEN, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return nil, xerrors.Errorf("can't attach raw tracepoint: %w", err)
+	}
+
+	return &RawLink{internal.NewFD(uint32(fd))}, nil
+}
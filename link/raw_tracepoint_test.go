@@ -0,0 +1,92 @@
+package link
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestRawTracepoint(t *testing.T) {
+	prog := mustLoadProgram(t, ebpf.RawTracepoint, 0, "")
+	defer prog.Close()
+
+	link, err := AttachRawTracepoint(RawTracepointOptions{
+		Name:    "sched_process_exec",
+		Program: prog,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer link.Close()
+
+	tmp, err := ioutil.TempDir("/sys/fs/bpf", "ebpf-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	path := filepath.Join(tmp, "link")
+	if err := link.Pin(path); err != nil {
+		t.Fatal("Can't pin link:", err)
+	}
+
+	pinned, err := LoadPinnedRawLink(path)
+	if err != nil {
+		t.Fatal("Can't load pinned link:", err)
+	}
+	pinned.Close()
+
+	if err := link.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+}
+
+func TestRawTracepointBTF(t *testing.T) {
+	prog := mustLoadProgram(t, ebpf.Tracing, ebpf.AttachTraceRawTp, "sched_process_exec")
+	defer prog.Close()
+
+	if _, err := AttachRawTracepoint(RawTracepointOptions{Name: "foo", Program: prog}); err == nil {
+		t.Error("Attaching tp_btf with a name should fail")
+	}
+
+	link, err := AttachRawTracepoint(RawTracepointOptions{Program: prog})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := link.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+}
+
+func TestRawTracepointNilProgram(t *testing.T) {
+	if _, err := AttachRawTracepoint(RawTracepointOptions{Name: "sched_process_exec"}); err == nil {
+		t.Error("Attaching a nil program should fail")
+	}
+}
+
+func mustLoadProgram(tb testing.TB, typ ebpf.ProgramType, attachType ebpf.AttachType, attachTo string) *ebpf.Program {
+	tb.Helper()
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:       typ,
+		AttachType: attachType,
+		AttachTo:   attachTo,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "GPL",
+	})
+	testutils.SkipIfNotSupported(tb, err)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return prog
+}
@@ -0,0 +1,95 @@
+package link
+
+import (
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/sys"
+
+	"golang.org/x/xerrors"
+)
+
+type bpfLinkCreateAttr struct {
+	progFd     uint32
+	targetFd   uint32
+	attachType ebpf.AttachType
+	flags      uint32
+}
+
+// bpfLinkCreate wraps BPF_LINK_CREATE.
+//
+// attr must start with bpfLinkCreateAttr, followed by any attach type
+// specific fields.
+func bpfLinkCreate(attr unsafe.Pointer, size uintptr) (*internal.FD, error) {
+	fd, err := internal.BPF(internal.BPF_LINK_CREATE, attr, size)
+	if internal.IsNotSupported(err) {
+		return nil, internal.SyscallError(ebpf.ErrNotSupported, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return internal.NewFD(uint32(fd)), nil
+}
+
+// programFD returns the fd of prog, or an error if it is closed.
+func programFD(prog *ebpf.Program) (uint32, error) {
+	if prog == nil {
+		return 0, xerrors.New("program is nil")
+	}
+
+	fd := prog.FD()
+	if fd < 0 {
+		return 0, xerrors.Errorf("invalid program: %w", internal.ErrClosedFd)
+	}
+
+	return uint32(fd), nil
+}
+
+func bpfLinkGetNextID(start uint32) (uint32, error) {
+	attr := sys.GetIDAttr{StartID: start}
+	err := sys.LinkGetNextID(&attr)
+	return attr.NextID, wrapObjError(err)
+}
+
+func bpfLinkGetFDByID(id uint32) (*internal.FD, error) {
+	fd, err := sys.LinkGetFDByID(&sys.GetIDAttr{StartID: id})
+	if err != nil {
+		return nil, wrapObjError(err)
+	}
+	return fd, nil
+}
+
+func bpfGetLinkInfoByFD(fd *internal.FD) (*sys.LinkInfo, error) {
+	value, err := fd.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	var info sys.LinkInfo
+	attr := sys.ObjGetInfoByFDAttr{
+		BPFFD:   value,
+		InfoLen: uint32(unsafe.Sizeof(info)),
+		Info:    sys.NewPointer(unsafe.Pointer(&info)),
+	}
+	if err := sys.ObjGetInfoByFD(&attr); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func wrapObjError(err error) error {
+	if xerrors.Is(err, unix.ENOENT) {
+		return internal.SyscallError(ebpf.ErrNotExist, err)
+	}
+	return err
+}
+
+func bpfLinkUpdate(attr *sys.LinkUpdateAttr) error {
+	err := sys.LinkUpdate(attr)
+	if internal.IsNotSupported(err) {
+		return internal.SyscallError(ebpf.ErrNotSupported, err)
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package link
+
+import (
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// TCOptions control AttachTC.
+type TCOptions struct {
+	// Program must be of type SchedCLS.
+	Program *ebpf.Program
+	// Interface is the index of the network interface to attach to.
+	Interface int
+	// Attach is either AttachTCXIngress or AttachTCXEgress.
+	Attach ebpf.AttachType
+	// Netlink attaches the program as a clsact filter on kernels which
+	// don't support TCX, which was added in Linux 6.6. AttachTC fails
+	// on such kernels if Netlink is nil.
+	Netlink Netlink
+}
+
+// AttachTC attaches a traffic control program to a network interface.
+func AttachTC(opts TCOptions) (Link, error) {
+	progFd, err := programFD(opts.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	if t := opts.Program.ABI().Type; t != ebpf.SchedCLS {
+		return nil, xerrors.Errorf("invalid program type %s, expected SchedCLS", t)
+	}
+
+	if opts.Attach != ebpf.AttachTCXIngress && opts.Attach != ebpf.AttachTCXEgress {
+		return nil, xerrors.Errorf("invalid attach type %d, expected AttachTCXIngress or AttachTCXEgress", opts.Attach)
+	}
+
+	if opts.Interface < 1 {
+		return nil, xerrors.Errorf("invalid interface index %d", opts.Interface)
+	}
+
+	if err := haveTCX(); err != nil {
+		if opts.Netlink == nil {
+			return nil, xerrors.Errorf("can't attach TC program: %w", err)
+		}
+		return attachTCNetlink(opts, int(progFd))
+	}
+
+	attr := bpfLinkCreateAttr{
+		progFd:     progFd,
+		targetFd:   uint32(opts.Interface),
+		attachType: opts.Attach,
+	}
+	fd, err := bpfLinkCreate(unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return nil, xerrors.Errorf("can't attach TC program: %w", err)
+	}
+
+	return &RawLink{fd}, nil
+}
+
+func attachTCNetlink(opts TCOptions, progFd int) (*netlinkTCLink, error) {
+	filter := &TCFilter{
+		Ifindex: opts.Interface,
+		Egress:  opts.Attach == ebpf.AttachTCXEgress,
+	}
+	if err := opts.Netlink.AttachTCFilter(filter, progFd); err != nil {
+		return nil, xerrors.Errorf("can't attach TC program via netlink: %w", err)
+	}
+
+	return &netlinkTCLink{opts.Netlink, filter}, nil
+}
+
+// netlinkTCLink is a program attached as a clsact filter via netlink.
+type netlinkTCLink struct {
+	netlink Netlink
+	filter  *TCFilter
+}
+
+var _ Link = (*netlinkTCLink)(nil)
+
+func (nl *netlinkTCLink) isLink() {}
+
+// Pin is not supported for programs attached via netlink, since they
+// stay attached until they are detached explicitly.
+func (nl *netlinkTCLink) Pin(string) error {
+	return xerrors.Errorf("can't pin TC filter: %w", internal.ErrNotSupported)
+}
+
+// Update implements the Link interface.
+func (nl *netlinkTCLink) Update(new *ebpf.Program) error {
+	progFd, err := programFD(new)
+	if err != nil {
+		return err
+	}
+
+	if err := nl.netlink.AttachTCFilter(nl.filter, int(progFd)); err != nil {
+		return xerrors.Errorf("can't update TC filter: %w", err)
+	}
+	return nil
+}
+
+// Close removes the filter.
+func (nl *netlinkTCLink) Close() error {
+	if err := nl.netlink.DetachTCFilter(nl.filter); err != nil {
+		return xerrors.Errorf("can't remove TC filter: %w", err)
+	}
+	return nil
+}
+
+var haveTCX = internal.FeatureTest("tcx", "6.6", func() bool {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type: ebpf.SchedCLS,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		return false
+	}
+	defer prog.Close()
+
+	// Attaching to a non-existent interface returns ENODEV on supported
+	// kernels, and EINVAL otherwise.
+	attr := bpfLinkCreateAttr{
+		progFd:     uint32(prog.FD()),
+		attachType: ebpf.AttachTCXIngress,
+	}
+	fd, err := bpfLinkCreate(unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err == nil {
+		fd.Close()
+		return true
+	}
+	return xerrors.Is(err, unix.ENODEV)
+})
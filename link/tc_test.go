@@ -0,0 +1,83 @@
+package link
+
+import (
+	"net"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestAttachTC(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "6.6", "TCX")
+
+	prog := mustLoadProgram(t, ebpf.SchedCLS, 0, "")
+	defer prog.Close()
+
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, attach := range []ebpf.AttachType{ebpf.AttachTCXIngress, ebpf.AttachTCXEgress} {
+		link, err := AttachTC(TCOptions{
+			Program:   prog,
+			Interface: lo.Index,
+			Attach:    attach,
+		})
+		testutils.SkipIfNotSupported(t, err)
+		if err != nil {
+			t.Fatal("Can't attach TC program:", err)
+		}
+
+		info, err := link.(*RawLink).Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Type != TCXType {
+			t.Error("Expected TCXType, got", info.Type)
+		}
+
+		if err := link.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAttachTCNetlink(t *testing.T) {
+	prog := mustLoadProgram(t, ebpf.SchedCLS, 0, "")
+	defer prog.Close()
+
+	nl := &fakeNetlink{}
+	link, err := attachTCNetlink(TCOptions{
+		Program:   prog,
+		Interface: 1,
+		Attach:    ebpf.AttachTCXEgress,
+		Netlink:   nl,
+	}, prog.FD())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !link.filter.Egress || link.filter.Handle == 0 {
+		t.Errorf("Invalid filter %+v", link.filter)
+	}
+
+	if err := link.Update(prog); err != nil {
+		t.Fatal(err)
+	}
+	if len(nl.filters) != 1 {
+		t.Error("Update created a new filter")
+	}
+
+	if err := link.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(nl.filters) != 0 {
+		t.Error("Filter wasn't removed")
+	}
+}
+
+func TestHaveTCX(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveTCX)
+}
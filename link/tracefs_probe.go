@@ -0,0 +1,160 @@
+package link
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// Kernels before 4.17 don't have PMUs to create kprobes and uprobes via
+// perf_event_open. Instead, probes are added to kprobe_events or
+// uprobe_events in tracefs, which turns them into tracepoints. These
+// tracepoints are global and must be removed explicitly.
+
+// tracefsProbeGroup is the group of all probes created via tracefs.
+const tracefsProbeGroup = "ebpf"
+
+var (
+	rgxProbeName       = regexp.MustCompile("[^a-zA-Z0-9_]")
+	tracefsProbeNumber uint64
+)
+
+// tracefsProbe is a kprobe or uprobe created via tracefs.
+type tracefsProbe struct {
+	tracefs string
+	// typ is either kprobe or uprobe.
+	typ  string
+	name string
+}
+
+// openProbe creates a kprobe or uprobe, preferring the PMU of the same
+// name over tracefs.
+//
+// The returned tracefsProbe is nil if the PMU was used.
+func openProbe(typ string, ret bool, target string, offset uint64, pid int) (*internal.FD, *tracefsProbe, error) {
+	pe, err := openPMUProbe(typ, ret, target, offset, pid)
+	if !xerrors.Is(err, internal.ErrNotSupported) {
+		return pe, nil, err
+	}
+
+	internal.Debug("Can't create probe via PMU, falling back to tracefs", "type", typ, "target", target, "error", err)
+	probe, tracefsErr := createTracefsProbe(typ, ret, target, offset)
+	if xerrors.Is(tracefsErr, internal.ErrNotSupported) {
+		return nil, nil, err
+	}
+	if tracefsErr != nil {
+		return nil, nil, tracefsErr
+	}
+
+	pe, err = probe.open(pid)
+	if err != nil {
+		probe.remove()
+		return nil, nil, err
+	}
+
+	return pe, probe, nil
+}
+
+// createTracefsProbe adds a probe to tracefs.
+//
+// target is a kernel symbol for kprobes, and the path of an executable
+// for uprobes. offset is relative to target.
+func createTracefsProbe(typ string, ret bool, target string, offset uint64) (*tracefsProbe, error) {
+	events := typ + "_events"
+	tracefs, err := findTracefs(events)
+	if err != nil {
+		return nil, err
+	}
+
+	base := target
+	if typ == "uprobe" {
+		base = filepath.Base(target)
+	}
+	if len(base) > 32 {
+		base = base[:32]
+	}
+
+	probe := &tracefsProbe{
+		tracefs: tracefs,
+		typ:     typ,
+		name: fmt.Sprintf("%s_%d_%d", rgxProbeName.ReplaceAllString(base, "_"),
+			os.Getpid(), atomic.AddUint64(&tracefsProbeNumber, 1)),
+	}
+
+	kind := "p"
+	if ret {
+		kind = "r"
+	}
+
+	location := target
+	if typ == "uprobe" {
+		location = fmt.Sprintf("%s:%#x", target, offset)
+	} else if offset != 0 {
+		location = fmt.Sprintf("%s+%#x", target, offset)
+	}
+
+	cmd := fmt.Sprintf("%s:%s/%s %s", kind, tracefsProbeGroup, probe.name, location)
+	if err := probe.write(cmd); err != nil {
+		return nil, xerrors.Errorf("can't create %s via tracefs: %w", typ, wrapDenied(err))
+	}
+
+	return probe, nil
+}
+
+// open creates a perf event for the probe.
+func (tp *tracefsProbe) open(pid int) (*internal.FD, error) {
+	id, err := readUint64FromFile(filepath.Join(tp.tracefs, "events", tracefsProbeGroup, tp.name, "id"))
+	if err != nil {
+		return nil, xerrors.Errorf("can't read ID of %s: %w", tp.name, wrapDenied(err))
+	}
+
+	attr := unix.PerfEventAttr{
+		Type:        unix.PERF_TYPE_TRACEPOINT,
+		Config:      id,
+		Sample_type: unix.PERF_SAMPLE_RAW,
+		Sample:      1,
+		Wakeup:      1,
+	}
+
+	fd, err := unix.PerfEventOpen(&attr, pid, 0, -1, unix.PERF_FLAG_FD_CLOEXEC)
+	if err != nil {
+		return nil, xerrors.Errorf("can't open %s: %w", tp.name, wrapDenied(err))
+	}
+
+	return internal.NewFD(uint32(fd)), nil
+}
+
+// remove deletes the probe from tracefs. All perf events of the probe
+// must be closed.
+func (tp *tracefsProbe) remove() error {
+	return tp.write(fmt.Sprintf("-:%s/%s", tracefsProbeGroup, tp.name))
+}
+
+func (tp *tracefsProbe) write(cmd string) error {
+	f, err := os.OpenFile(filepath.Join(tp.tracefs, tp.typ+"_events"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(cmd)
+	return err
+}
+
+// findTracefs returns the mount point of tracefs which contains file.
+func findTracefs(file string) (string, error) {
+	for _, tracefs := range tracefsPaths {
+		if _, err := os.Stat(filepath.Join(tracefs, file)); err == nil {
+			return tracefs, nil
+		}
+	}
+
+	return "", xerrors.Errorf("can't find %s in tracefs: %w", file, internal.ErrNotSupported)
+}
@@ -0,0 +1,76 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+func TestTracefsUprobe(t *testing.T) {
+	ex, err := OpenExecutable("/bin/bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offset, err := ex.offset("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prog := mustLoadProgram(t, ebpf.Kprobe, 0, "")
+	defer prog.Close()
+
+	probe, err := createTracefsProbe("uprobe", true, ex.path, offset)
+	if xerrors.Is(err, internal.ErrNotSupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := filepath.Join(probe.tracefs, "events", tracefsProbeGroup, probe.name)
+	if _, err := os.Stat(events); err != nil {
+		t.Fatal("Probe wasn't created:", err)
+	}
+
+	pe, err := probe.open(-1)
+	if err != nil {
+		probe.remove()
+		t.Fatal(err)
+	}
+
+	lnk, err := attachPerfEvent(pe, probe, prog, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lnk.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+
+	if _, err := os.Stat(events); !os.IsNotExist(err) {
+		t.Error("Probe wasn't removed:", err)
+	}
+}
+
+func TestWrapDenied(t *testing.T) {
+	if err := wrapDenied(unix.EINVAL); err != unix.EINVAL {
+		t.Error("Unrelated errors are modified:", err)
+	}
+
+	err := xerrors.Errorf("open: %w", &LockdownError{"confidentiality", unix.EPERM})
+	if !xerrors.Is(err, unix.EPERM) {
+		t.Error("LockdownError doesn't wrap EPERM")
+	}
+
+	if !internal.SELinuxEnforcing() {
+		if err := wrapDenied(unix.EACCES); err != unix.EACCES {
+			t.Error("EACCES is attributed to SELinux, which isn't enforcing:", err)
+		}
+	}
+}
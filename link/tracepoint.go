@@ -0,0 +1,101 @@
+package link
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// tracefsPaths are the locations at which tracefs is commonly mounted.
+var tracefsPaths = []string{
+	"/sys/kernel/tracing",
+	"/sys/kernel/debug/tracing",
+}
+
+// rgxTraceEvent matches valid tracepoint group and event names.
+var rgxTraceEvent = regexp.MustCompile("^[a-zA-Z0-9_]+$")
+
+// TracepointOptions defines additional parameters that will be used
+// when opening a Tracepoint Link.
+type TracepointOptions struct {
+	// Cookie is an arbitrary value that can be fetched from the program
+	// via bpf_get_attach_cookie().
+	//
+	// Requires at least Linux 5.15.
+	Cookie uint64
+}
+
+// Tracepoint attaches the given eBPF program to the tracepoint with the given
+// group and name. See /sys/kernel/tracing/events to find available
+// tracepoints. The top-level directory is the group, the event's subdirectory
+// is the name. Example:
+//
+//	tp, err := Tracepoint("syscalls", "sys_enter_fork", prog, nil)
+//
+// opts may be nil.
+//
+// Requires at least Linux 4.7.
+func Tracepoint(group, name string, prog *ebpf.Program, opts *TracepointOptions) (Link, error) {
+	if opts == nil {
+		opts = &TracepointOptions{}
+	}
+
+	if !rgxTraceEvent.MatchString(group) || !rgxTraceEvent.MatchString(name) {
+		return nil, xerrors.Errorf("invalid tracepoint %s/%s", group, name)
+	}
+
+	if _, err := programFD(prog); err != nil {
+		return nil, err
+	}
+
+	if t := prog.ABI().Type; t != ebpf.TracePoint {
+		return nil, xerrors.Errorf("invalid program type %s, expected TracePoint", t)
+	}
+
+	id, err := traceEventID(group, name)
+	if err != nil {
+		return nil, err
+	}
+
+	attr := unix.PerfEventAttr{
+		Type:        unix.PERF_TYPE_TRACEPOINT,
+		Config:      id,
+		Sample_type: unix.PERF_SAMPLE_RAW,
+		Sample:      1,
+		Wakeup:      1,
+	}
+
+	fd, err := unix.PerfEventOpen(&attr, -1, 0, -1, unix.PERF_FLAG_FD_CLOEXEC)
+	if err != nil {
+		return nil, xerrors.Errorf("can't open tracepoint %s/%s: %w", group, name, wrapDenied(err))
+	}
+
+	return attachPerfEvent(internal.NewFD(uint32(fd)), nil, prog, opts.Cookie)
+}
+
+// traceEventID reads the ID of a trace event from tracefs.
+func traceEventID(group, name string) (uint64, error) {
+	for _, tracefs := range tracefsPaths {
+		path := filepath.Join(tracefs, "events", group, name, "id")
+		id, err := readUint64FromFile(path)
+		if os.IsNotExist(err) {
+			if _, statErr := os.Stat(filepath.Join(tracefs, "events")); statErr == nil {
+				// tracefs is mounted but the event doesn't exist.
+				return 0, xerrors.Errorf("tracepoint %s/%s: %w", group, name, os.ErrNotExist)
+			}
+			continue
+		}
+		if err != nil {
+			return 0, xerrors.Errorf("can't read tracepoint ID for %s/%s: %w", group, name, wrapDenied(err))
+		}
+		return id, nil
+	}
+
+	return 0, xerrors.Errorf("can't find tracefs: %w", internal.ErrNotSupported)
+}
@@ -0,0 +1,102 @@
+package link
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	"golang.org/x/xerrors"
+)
+
+func TestTracepoint(t *testing.T) {
+	testutils.SkipIfNoTracefs(t)
+
+	prog := mustLoadProgram(t, ebpf.TracePoint, 0, "")
+	defer prog.Close()
+
+	if _, err := Tracepoint("syscalls", "../foo", prog, nil); err == nil {
+		t.Error("Invalid tracepoint name should be rejected")
+	}
+
+	_, err := Tracepoint("syscalls", "sys_enter_nonexistent", prog, nil)
+	if !xerrors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist for missing tracepoint, got", err)
+	}
+
+	tp, err := Tracepoint("syscalls", "sys_enter_getpid", prog, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tp.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+}
+
+func TestTracepointCookie(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.15", "bpf_link_perf_event")
+	testutils.SkipIfNoTracefs(t)
+
+	cookies, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cookies.Close()
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:         ebpf.TracePoint,
+		Instructions: cookieToMap(cookies),
+		License:      "GPL",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	tp, err := Tracepoint("syscalls", "sys_enter_getpid", prog, &TracepointOptions{Cookie: 0xcafe})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.Close()
+
+	os.Getpid()
+
+	var cookie uint64
+	if err := cookies.Lookup(uint32(0), &cookie); err != nil {
+		t.Fatal(err)
+	}
+
+	if cookie != 0xcafe {
+		t.Errorf("Expected cookie 0xcafe, got %#x", cookie)
+	}
+}
+
+// cookieToMap returns instructions which store the attach cookie at
+// index 0 of an array map.
+func cookieToMap(m *ebpf.Map) asm.Instructions {
+	// bpf_get_attach_cookie
+	const fnGetAttachCookie = asm.BuiltinFunc(174)
+
+	return asm.Instructions{
+		fnGetAttachCookie.Call(),
+		asm.StoreMem(asm.RFP, -8, asm.R0, asm.DWord),
+		asm.StoreImm(asm.RFP, -12, 0, asm.Word),
+		asm.LoadMapPtr(asm.R1, m.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -12),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, -8),
+		asm.Mov.Imm(asm.R4, 0),
+		asm.FnMapUpdateElem.Call(),
+		asm.Mov.Imm(asm.R0, 0),
+		asm.Return(),
+	}
+}
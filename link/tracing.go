@@ -0,0 +1,70 @@
+package link
+
+import (
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+
+	"golang.org/x/xerrors"
+)
+
+// TracingOptions control attaching a fentry, fexit or fmod_ret program.
+type TracingOptions struct {
+	// Program must be of type Tracing, and loaded with AttachTo set
+	// to the kernel function to trace.
+	Program *ebpf.Program
+	// AttachType must match the attach type the program was loaded with.
+	// It is only required if Cookie is set.
+	AttachType ebpf.AttachType
+	// Cookie is an arbitrary value that can be fetched from the program
+	// via bpf_get_attach_cookie().
+	//
+	// Requires at least Linux 5.19.
+	Cookie uint64
+}
+
+type bpfLinkCreateTracingAttr struct {
+	bpfLinkCreateAttr
+	targetBTFID uint32
+	_           uint32
+	cookie      uint64
+}
+
+// AttachTracing links a tracing (fentry/fexit/fmod_ret) BPF program.
+//
+// Requires at least Linux 5.5.
+func AttachTracing(opts TracingOptions) (Link, error) {
+	progFd, err := programFD(opts.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	if t := opts.Program.ABI().Type; t != ebpf.Tracing {
+		return nil, xerrors.Errorf("invalid program type %s, expected Tracing", t)
+	}
+
+	if opts.Cookie == 0 {
+		return AttachRawTracepoint(RawTracepointOptions{Program: opts.Program})
+	}
+
+	switch opts.AttachType {
+	case ebpf.AttachTraceFEntry, ebpf.AttachTraceFExit, ebpf.AttachModifyReturn:
+	default:
+		return nil, xerrors.Errorf("invalid attach type %d", opts.AttachType)
+	}
+
+	attr := bpfLinkCreateTracingAttr{
+		bpfLinkCreateAttr: bpfLinkCreateAttr{
+			progFd:     progFd,
+			attachType: opts.AttachType,
+		},
+		cookie: opts.Cookie,
+	}
+
+	fd, err := bpfLinkCreate(unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return nil, xerrors.Errorf("can't attach tracing program: %w", err)
+	}
+
+	return &RawLink{fd}, nil
+}
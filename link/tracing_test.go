@@ -0,0 +1,56 @@
+package link
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+func TestAttachTracing(t *testing.T) {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:       ebpf.Tracing,
+		AttachType: ebpf.AttachTraceFEntry,
+		AttachTo:   "vprintk",
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "GPL",
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if xerrors.Is(err, unix.EPERM) {
+		// Kernels without CONFIG_FUNCTION_TRACER refuse to load fentry.
+		t.Skip("Can't load fentry program:", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	link, err := AttachTracing(TracingOptions{Program: prog})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := link.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+
+	testutils.SkipOnOldKernel(t, "5.19", "tracing cookies")
+
+	link, err = AttachTracing(TracingOptions{
+		Program:    prog,
+		AttachType: ebpf.AttachTraceFEntry,
+		Cookie:     1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := link.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+}
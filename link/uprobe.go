@@ -0,0 +1,95 @@
+package link
+
+import (
+	"github.com/cilium/ebpf"
+
+	"golang.org/x/xerrors"
+)
+
+// UprobeOptions defines additional parameters that will be used
+// when opening a Uprobe Link.
+type UprobeOptions struct {
+	// Offset of the probe in the executable. Overrides the offset of the
+	// symbol if non-zero, which allows attaching to locations that don't
+	// have a symbol.
+	Offset uint64
+	// Only set the uprobe on the given process ID. Attaches to all
+	// processes if zero.
+	//
+	// The kernel only installs the uprobe in the address space of the
+	// process, so other processes executing the same code don't incur
+	// any overhead. The filter applies to all threads of the process.
+	PID int
+	// Cookie is an arbitrary value that can be fetched from the program
+	// via bpf_get_attach_cookie().
+	//
+	// Requires at least Linux 5.15.
+	Cookie uint64
+}
+
+// Uprobe attaches the given eBPF program to a perf event that fires when the
+// given symbol starts executing in the executable.
+//
+// Functions imported from a shared library are probed at their PLT stub,
+// which only fires for calls from the executable itself. Attach to the
+// library, see OpenSharedLibrary, to trace all calls.
+//
+// opts may be nil. Probes are created via tracefs on kernels without the
+// uprobe PMU, which was added in Linux 4.17. Kernel lockdown and SELinux
+// may deny creating probes, see LockdownError and SELinuxError.
+//
+// Requires at least Linux 4.3.
+func (ex *Executable) Uprobe(symbol string, prog *ebpf.Program, opts *UprobeOptions) (Link, error) {
+	return ex.uprobe(symbol, prog, opts, false)
+}
+
+// Uretprobe attaches the given eBPF program to a perf event that fires right
+// before the given symbol exits.
+//
+// Don't use uretprobes on Go executables, see GoUretprobe.
+//
+// opts may be nil.
+//
+// Requires at least Linux 4.3.
+func (ex *Executable) Uretprobe(symbol string, prog *ebpf.Program, opts *UprobeOptions) (Link, error) {
+	return ex.uprobe(symbol, prog, opts, true)
+}
+
+func (ex *Executable) uprobe(symbol string, prog *ebpf.Program, opts *UprobeOptions, ret bool) (Link, error) {
+	if opts == nil {
+		opts = &UprobeOptions{}
+	}
+
+	if _, err := programFD(prog); err != nil {
+		return nil, err
+	}
+
+	if t := prog.ABI().Type; t != ebpf.Kprobe {
+		return nil, xerrors.Errorf("invalid program type %s, expected Kprobe", t)
+	}
+
+	if opts.PID < 0 {
+		return nil, xerrors.Errorf("invalid PID %d", opts.PID)
+	}
+
+	offset := opts.Offset
+	if offset == 0 {
+		var err error
+		offset, err = ex.offset(symbol)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pid := opts.PID
+	if pid == 0 {
+		pid = -1
+	}
+
+	pe, event, err := openProbe("uprobe", ret, ex.path, offset, pid)
+	if err != nil {
+		return nil, xerrors.Errorf("can't create uprobe for symbol %s: %w", symbol, err)
+	}
+
+	return attachPerfEvent(pe, event, prog, opts.Cookie)
+}
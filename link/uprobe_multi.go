@@ -0,0 +1,217 @@
+package link
+
+import (
+	"os"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// UprobeMultiOptions defines additional parameters that will be used
+// when opening a UprobeMulti Link.
+type UprobeMultiOptions struct {
+	// Symbol offsets that uprobe programs will attach to. Optional, the
+	// offsets of the symbols passed to UprobeMulti are used if empty.
+	//
+	// Mutually exclusive with symbols.
+	Offsets []uint64
+
+	// Optional, array of reference counter offsets, which are used to
+	// enable USDT semaphores. Must be the same length as the number of
+	// symbols or offsets.
+	RefCtrOffsets []uint64
+
+	// Cookies specifies arbitrary values that can be fetched from an eBPF
+	// program via `bpf_get_attach_cookie()`.
+	//
+	// If set, its length should be equal to the number of symbols or
+	// offsets. Each Cookie is assigned to the symbol or offset at the
+	// corresponding index.
+	Cookies []uint64
+
+	// Only set the uprobes on the given process ID. Useful when tracing
+	// shared library calls or programs that have many running instances.
+	// Attaches to all processes if zero.
+	PID uint32
+}
+
+// UprobeMulti attaches the given eBPF program to the entry point of a set
+// of symbols in the executable.
+//
+// The program must have been loaded with AttachTraceUprobeMulti.
+//
+// Requires at least Linux 6.6.
+func (ex *Executable) UprobeMulti(symbols []string, prog *ebpf.Program, opts *UprobeMultiOptions) (Link, error) {
+	return ex.uprobeMulti(symbols, prog, opts, 0)
+}
+
+// UretprobeMulti attaches the given eBPF program to the return point of a
+// set of symbols in the executable.
+//
+// The program must have been loaded with AttachTraceUprobeMulti.
+//
+// Requires at least Linux 6.6.
+func (ex *Executable) UretprobeMulti(symbols []string, prog *ebpf.Program, opts *UprobeMultiOptions) (Link, error) {
+	return ex.uprobeMulti(symbols, prog, opts, unix.BPF_F_UPROBE_MULTI_RETURN)
+}
+
+type bpfLinkCreateUprobeMultiAttr struct {
+	bpfLinkCreateAttr
+	path             internal.Pointer
+	offsets          internal.Pointer
+	refCtrOffsets    internal.Pointer
+	cookies          internal.Pointer
+	count            uint32
+	uprobeMultiFlags uint32
+	pid              uint32
+	_                uint32
+}
+
+func (ex *Executable) uprobeMulti(symbols []string, prog *ebpf.Program, opts *UprobeMultiOptions, flags uint32) (Link, error) {
+	if opts == nil {
+		opts = &UprobeMultiOptions{}
+	}
+
+	progFd, err := programFD(prog)
+	if err != nil {
+		return nil, err
+	}
+
+	if t := prog.ABI().Type; t != ebpf.Kprobe {
+		return nil, xerrors.Errorf("invalid program type %s, expected Kprobe", t)
+	}
+
+	offsets, err := ex.resolveOffsets(symbols, opts.Offsets)
+	if err != nil {
+		return nil, err
+	}
+
+	count := len(offsets)
+	if n := len(opts.RefCtrOffsets); n > 0 && n != count {
+		return nil, xerrors.New("RefCtrOffsets must be exactly the number of symbols or offsets in length")
+	}
+	if n := len(opts.Cookies); n > 0 && n != count {
+		return nil, xerrors.New("Cookies must be exactly the number of symbols or offsets in length")
+	}
+
+	if err := haveBPFLinkUprobeMulti(); err != nil {
+		return nil, err
+	}
+
+	attr := bpfLinkCreateUprobeMultiAttr{
+		bpfLinkCreateAttr: bpfLinkCreateAttr{
+			progFd:     progFd,
+			attachType: ebpf.AttachTraceUprobeMulti,
+		},
+		path:             internal.NewStringPointer(ex.path),
+		offsets:          newUintptrPointer(offsets),
+		count:            uint32(count),
+		uprobeMultiFlags: flags,
+		pid:              opts.PID,
+	}
+
+	if len(opts.RefCtrOffsets) > 0 {
+		attr.refCtrOffsets = newUintptrPointer(opts.RefCtrOffsets)
+	}
+
+	if len(opts.Cookies) > 0 {
+		attr.cookies = internal.NewPointer(unsafe.Pointer(&opts.Cookies[0]))
+	}
+
+	fd, err := bpfLinkCreate(unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if xerrors.Is(err, unix.ESRCH) {
+		return nil, xerrors.Errorf("process %d: %w", opts.PID, os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("can't attach uprobe.multi: %w", err)
+	}
+
+	return &RawLink{fd}, nil
+}
+
+// resolveOffsets resolves symbols to offsets in the executable, unless
+// explicit offsets are given.
+func (ex *Executable) resolveOffsets(symbols []string, offsets []uint64) ([]uint64, error) {
+	switch {
+	case len(symbols) == 0 && len(offsets) == 0:
+		return nil, xerrors.New("one of symbols or offsets is required")
+	case len(symbols) != 0 && len(offsets) != 0:
+		return nil, xerrors.New("symbols and offsets are mutually exclusive")
+	case len(offsets) != 0:
+		return offsets, nil
+	}
+
+	result := make([]uint64, 0, len(symbols))
+	for _, symbol := range symbols {
+		off, err := ex.offset(symbol)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, off)
+	}
+
+	return result, nil
+}
+
+// newUintptrPointer converts values to the native unsigned long type
+// the kernel expects for offsets.
+func newUintptrPointer(values []uint64) internal.Pointer {
+	if len(values) == 0 {
+		return internal.Pointer{}
+	}
+
+	native := make([]uintptr, 0, len(values))
+	for _, v := range values {
+		native = append(native, uintptr(v))
+	}
+
+	return internal.NewPointer(unsafe.Pointer(&native[0]))
+}
+
+var haveBPFLinkUprobeMulti = internal.FeatureTest("bpf_link_uprobe_multi", "6.6", func() bool {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:       "probe_upm_link",
+		Type:       ebpf.Kprobe,
+		AttachType: ebpf.AttachTraceUprobeMulti,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		return false
+	}
+	defer prog.Close()
+
+	progFd, err := programFD(prog)
+	if err != nil {
+		return false
+	}
+
+	// Attaching to a directory returns EBADF on supported kernels,
+	// and EINVAL otherwise.
+	offsets := []uintptr{1}
+	attr := bpfLinkCreateUprobeMultiAttr{
+		bpfLinkCreateAttr: bpfLinkCreateAttr{
+			progFd:     progFd,
+			attachType: ebpf.AttachTraceUprobeMulti,
+		},
+		path:    internal.NewStringPointer("/"),
+		offsets: internal.NewPointer(unsafe.Pointer(&offsets[0])),
+		count:   1,
+	}
+
+	fd, err := bpfLinkCreate(unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return !xerrors.Is(err, unix.EINVAL)
+	}
+
+	fd.Close()
+	return true
+})
@@ -0,0 +1,57 @@
+package link
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	"golang.org/x/xerrors"
+)
+
+func TestUprobeMulti(t *testing.T) {
+	ex, err := OpenExecutable("/bin/bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ex.offset("bogus_ebpf_symbol"); !xerrors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist for missing symbol, got", err)
+	}
+
+	prog := mustLoadProgram(t, ebpf.Kprobe, ebpf.AttachTraceUprobeMulti, "")
+	defer prog.Close()
+
+	symbols := []string{"bash_logout", "main"}
+	um, err := ex.UprobeMulti(symbols, prog, &UprobeMultiOptions{
+		Cookies: []uint64{1, 2},
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := um.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+
+	ur, err := ex.UretprobeMulti(symbols[:1], prog, &UprobeMultiOptions{
+		PID: uint32(os.Getpid()),
+	})
+	if err != nil {
+		t.Fatal("Can't attach uretprobe.multi:", err)
+	}
+	ur.Close()
+
+	_, err = ex.UprobeMulti(symbols, prog, &UprobeMultiOptions{
+		Offsets: []uint64{1},
+	})
+	if err == nil {
+		t.Error("Symbols and offsets should be mutually exclusive")
+	}
+}
+
+func TestHaveBPFLinkUprobeMulti(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveBPFLinkUprobeMulti)
+}
@@ -0,0 +1,44 @@
+package link
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+
+	"golang.org/x/xerrors"
+)
+
+func TestUprobe(t *testing.T) {
+	ex, err := OpenExecutable("/bin/bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prog := mustLoadProgram(t, ebpf.Kprobe, 0, "")
+	defer prog.Close()
+
+	up, err := ex.Uprobe("main", prog, &UprobeOptions{Cookie: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := up.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+
+	up, err = ex.Uretprobe("main", prog, &UprobeOptions{PID: os.Getpid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := up.Close(); err != nil {
+		t.Fatal("Can't close link:", err)
+	}
+
+	if _, err := ex.Uprobe("main", prog, &UprobeOptions{PID: -1}); err == nil {
+		t.Error("Uprobe accepts a negative PID")
+	}
+
+	if _, err := ex.Uprobe("bogus_ebpf_symbol", prog, nil); !xerrors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist for missing symbol, got", err)
+	}
+}
@@ -0,0 +1,342 @@
+package link
+
+import (
+	"golang.org/x/xerrors"
+)
+
+// Operand encodings of x86-64 opcodes, as far as they affect the length
+// of an instruction.
+const (
+	x86Invalid   = 1 << iota // Not valid in 64-bit mode.
+	x86ModRM                 // Followed by a ModRM byte.
+	x86Imm8                  // 8-bit immediate.
+	x86Imm16                 // 16-bit immediate.
+	x86ImmZ                  // 16 or 32-bit immediate, depending on operand size.
+	x86ImmV                  // 16, 32 or 64-bit immediate, depending on operand size.
+	x86MemOffset             // 32 or 64-bit address, depending on address size.
+	x86Group3                // Immediate depends on the reg field of ModRM.
+	x86Prefix                // Legacy prefix.
+	x86VEX                   // VEX prefix, C4 and C5.
+	x86EVEX                  // EVEX prefix, 62.
+)
+
+// x86OneByte describes the one-byte opcode map in 64-bit mode.
+var x86OneByte = [256]uint16{
+	// 0x00
+	x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86Imm8, x86ImmZ, x86Invalid, x86Invalid,
+	x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86Imm8, x86ImmZ, x86Invalid, 0,
+	// 0x10
+	x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86Imm8, x86ImmZ, x86Invalid, x86Invalid,
+	x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86Imm8, x86ImmZ, x86Invalid, x86Invalid,
+	// 0x20
+	x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86Imm8, x86ImmZ, x86Prefix, x86Invalid,
+	x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86Imm8, x86ImmZ, x86Prefix, x86Invalid,
+	// 0x30
+	x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86Imm8, x86ImmZ, x86Prefix, x86Invalid,
+	x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86Imm8, x86ImmZ, x86Prefix, x86Invalid,
+	// 0x40, REX prefixes are handled separately.
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	// 0x50
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	// 0x60
+	x86Invalid, x86Invalid, x86EVEX, x86ModRM, x86Prefix, x86Prefix, x86Prefix, x86Prefix,
+	x86ImmZ, x86ModRM | x86ImmZ, x86Imm8, x86ModRM | x86Imm8, 0, 0, 0, 0,
+	// 0x70
+	x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8,
+	x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8,
+	// 0x80
+	x86ModRM | x86Imm8, x86ModRM | x86ImmZ, x86Invalid, x86ModRM | x86Imm8, x86ModRM, x86ModRM, x86ModRM, x86ModRM,
+	x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86ModRM,
+	// 0x90
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, x86Invalid, 0, 0, 0, 0, 0,
+	// 0xA0
+	x86MemOffset, x86MemOffset, x86MemOffset, x86MemOffset, 0, 0, 0, 0,
+	x86Imm8, x86ImmZ, 0, 0, 0, 0, 0, 0,
+	// 0xB0
+	x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8,
+	x86ImmV, x86ImmV, x86ImmV, x86ImmV, x86ImmV, x86ImmV, x86ImmV, x86ImmV,
+	// 0xC0
+	x86ModRM | x86Imm8, x86ModRM | x86Imm8, x86Imm16, 0, x86VEX, x86VEX, x86ModRM | x86Imm8, x86ModRM | x86ImmZ,
+	x86Imm16 | x86Imm8, 0, x86Imm16, 0, 0, x86Imm8, x86Invalid, 0,
+	// 0xD0
+	x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86Invalid, x86Invalid, x86Invalid, 0,
+	x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86ModRM, x86ModRM,
+	// 0xE0
+	x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8, x86Imm8,
+	x86ImmZ, x86ImmZ, x86Invalid, x86Imm8, 0, 0, 0, 0,
+	// 0xF0
+	x86Prefix, 0, x86Prefix, x86Prefix, 0, 0, x86ModRM | x86Group3, x86ModRM | x86Group3,
+	0, 0, 0, 0, 0, 0, x86ModRM, x86ModRM,
+}
+
+// x86TwoByteNoModRM lists the opcodes of the 0F map which aren't followed
+// by a ModRM byte.
+var x86TwoByteNoModRM = map[byte]uint16{
+	0x05: 0, 0x06: 0, 0x07: 0, 0x08: 0, 0x09: 0, 0x0b: 0,
+	0x30: 0, 0x31: 0, 0x32: 0, 0x33: 0, 0x34: 0, 0x35: 0, 0x37: 0,
+	0x77: 0,
+	0x80: x86ImmZ, 0x81: x86ImmZ, 0x82: x86ImmZ, 0x83: x86ImmZ,
+	0x84: x86ImmZ, 0x85: x86ImmZ, 0x86: x86ImmZ, 0x87: x86ImmZ,
+	0x88: x86ImmZ, 0x89: x86ImmZ, 0x8a: x86ImmZ, 0x8b: x86ImmZ,
+	0x8c: x86ImmZ, 0x8d: x86ImmZ, 0x8e: x86ImmZ, 0x8f: x86ImmZ,
+	0xa0: 0, 0xa1: 0, 0xa2: 0, 0xa8: 0, 0xa9: 0, 0xaa: 0,
+	0xc8: 0, 0xc9: 0, 0xca: 0, 0xcb: 0, 0xcc: 0, 0xcd: 0, 0xce: 0, 0xcf: 0,
+}
+
+// x86TwoByteInvalid lists the opcodes of the 0F map which are undefined.
+var x86TwoByteInvalid = map[byte]bool{
+	0x04: true, 0x0a: true, 0x0c: true, 0x0e: true, 0x0f: true,
+	0x24: true, 0x25: true, 0x26: true, 0x27: true, 0x36: true,
+	0x39: true, 0x3b: true, 0x3c: true, 0x3d: true, 0x3e: true, 0x3f: true,
+}
+
+// x86TwoByteImm8 lists the opcodes of the 0F map which take an 8-bit
+// immediate in addition to ModRM. They are the same for VEX and EVEX.
+var x86TwoByteImm8 = map[byte]bool{
+	0x70: true, 0x71: true, 0x72: true, 0x73: true,
+	0xa4: true, 0xac: true, 0xba: true,
+	0xc2: true, 0xc4: true, 0xc5: true, 0xc6: true,
+}
+
+// x86InstructionLength returns the length of the x86-64 instruction at
+// the start of code.
+//
+// It only decodes as much as necessary to find the length, and returns
+// an error for opcodes it doesn't know instead of guessing.
+func x86InstructionLength(code []byte) (int, error) {
+	var (
+		pos        int
+		opsize16   bool
+		addrsize32 bool
+		rexW       bool
+	)
+
+	next := func() (byte, error) {
+		if pos >= len(code) {
+			return 0, xerrors.New("truncated instruction")
+		}
+		b := code[pos]
+		pos++
+		return b, nil
+	}
+
+	// Legacy prefixes.
+	op, err := next()
+	for ; err == nil && x86OneByte[op]&x86Prefix != 0; op, err = next() {
+		switch op {
+		case 0x66:
+			opsize16 = true
+		case 0x67:
+			addrsize32 = true
+		}
+		if pos > 14 {
+			return 0, xerrors.New("too many prefixes")
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// REX prefix, which must come right before the opcode.
+	if op&0xf0 == 0x40 {
+		rexW = op&0x08 != 0
+		if op, err = next(); err != nil {
+			return 0, err
+		}
+	}
+
+	var flags uint16
+	switch {
+	case x86OneByte[op]&(x86VEX|x86EVEX) != 0:
+		var opcodeMap byte
+		switch op {
+		case 0xc5:
+			if _, err := next(); err != nil {
+				return 0, err
+			}
+			opcodeMap = 1
+		case 0xc4:
+			b, err := next()
+			if err != nil {
+				return 0, err
+			}
+			if _, err := next(); err != nil {
+				return 0, err
+			}
+			opcodeMap = b & 0x1f
+		case 0x62:
+			b, err := next()
+			if err != nil {
+				return 0, err
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := next(); err != nil {
+					return 0, err
+				}
+			}
+			opcodeMap = b & 0x07
+		}
+
+		if op, err = next(); err != nil {
+			return 0, err
+		}
+
+		switch opcodeMap {
+		case 1:
+			flags = x86ModRM
+			if x86TwoByteImm8[op] {
+				flags |= x86Imm8
+			}
+			if op == 0x77 {
+				// VZEROUPPER and VZEROALL.
+				flags = 0
+			}
+		case 2:
+			flags = x86ModRM
+		case 3:
+			flags = x86ModRM | x86Imm8
+		default:
+			return 0, xerrors.Errorf("unsupported opcode map %d", opcodeMap)
+		}
+
+	case op == 0x0f:
+		if op, err = next(); err != nil {
+			return 0, err
+		}
+
+		switch op {
+		case 0x38:
+			if _, err := next(); err != nil {
+				return 0, err
+			}
+			flags = x86ModRM
+		case 0x3a:
+			if _, err := next(); err != nil {
+				return 0, err
+			}
+			flags = x86ModRM | x86Imm8
+		default:
+			if x86TwoByteInvalid[op] {
+				return 0, xerrors.Errorf("invalid opcode 0f %02x", op)
+			}
+			var ok bool
+			if flags, ok = x86TwoByteNoModRM[op]; !ok {
+				flags = x86ModRM
+				if x86TwoByteImm8[op] {
+					flags |= x86Imm8
+				}
+			}
+		}
+
+	default:
+		flags = x86OneByte[op]
+		if flags&(x86Invalid|x86Prefix) != 0 {
+			return 0, xerrors.Errorf("invalid opcode %02x", op)
+		}
+	}
+
+	if flags&x86ModRM != 0 {
+		modrm, err := next()
+		if err != nil {
+			return 0, err
+		}
+
+		mod, reg, rm := modrm>>6, (modrm>>3)&7, modrm&7
+		disp := 0
+		if mod != 3 && rm == 4 {
+			sib, err := next()
+			if err != nil {
+				return 0, err
+			}
+			if mod == 0 && sib&7 == 5 {
+				disp = 4
+			}
+		}
+
+		switch {
+		case mod == 0 && rm == 5:
+			// RIP relative.
+			disp = 4
+		case mod == 1:
+			disp = 1
+		case mod == 2:
+			disp = 4
+		}
+		pos += disp
+
+		if flags&x86Group3 != 0 && reg <= 1 {
+			// TEST takes an immediate, the other instructions of the
+			// group don't.
+			if op == 0xf6 {
+				flags |= x86Imm8
+			} else {
+				flags |= x86ImmZ
+			}
+		}
+	}
+
+	if flags&x86Imm8 != 0 {
+		pos++
+	}
+	if flags&x86Imm16 != 0 {
+		pos += 2
+	}
+	if flags&x86ImmZ != 0 {
+		if opsize16 {
+			pos += 2
+		} else {
+			pos += 4
+		}
+	}
+	if flags&x86ImmV != 0 {
+		switch {
+		case rexW:
+			pos += 8
+		case opsize16:
+			pos += 2
+		default:
+			pos += 4
+		}
+	}
+	if flags&x86MemOffset != 0 {
+		if addrsize32 {
+			pos += 4
+		} else {
+			pos += 8
+		}
+	}
+
+	if pos > len(code) {
+		return 0, xerrors.New("truncated instruction")
+	}
+	if pos > 15 {
+		return 0, xerrors.New("instruction exceeds 15 bytes")
+	}
+
+	return pos, nil
+}
+
+// x86Returns returns the offsets of all RET instructions in code, which
+// must consist of whole instructions.
+func x86Returns(code []byte) ([]uint64, error) {
+	var returns []uint64
+	for off := 0; off < len(code); {
+		n, err := x86InstructionLength(code[off:])
+		if err != nil {
+			return nil, xerrors.Errorf("offset %#x: %w", off, err)
+		}
+
+		// Skip prefixes, for example REP RET.
+		op := off
+		for op < off+n-1 && (x86OneByte[code[op]]&x86Prefix != 0 || code[op]&0xf0 == 0x40) {
+			op++
+		}
+		if code[op] == 0xc3 || code[op] == 0xc2 {
+			returns = append(returns, uint64(off))
+		}
+
+		off += n
+	}
+
+	return returns, nil
+}
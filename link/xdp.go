@@ -0,0 +1,157 @@
+package link
+
+import (
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// XDPAttachFlags select the mode an XDP program is attached in.
+//
+// The kernel picks the best supported mode if no flag is given.
+type XDPAttachFlags uint32
+
+const (
+	// XDPGenericMode runs the program on socket buffers, which works
+	// with any interface.
+	XDPGenericMode XDPAttachFlags = 1 << (iota + 1)
+	// XDPDriverMode runs the program in the driver of the interface.
+	XDPDriverMode
+	// XDPOffloadMode runs the program on the network card.
+	XDPOffloadMode
+)
+
+// XDPOptions control AttachXDP.
+type XDPOptions struct {
+	// Program must be of type XDP.
+	Program *ebpf.Program
+	// Interface is the index of the network interface to attach to.
+	Interface int
+	Flags     XDPAttachFlags
+	// Netlink attaches the program on kernels which don't support XDP
+	// links, which were added in Linux 5.9. AttachXDP fails on such
+	// kernels if Netlink is nil.
+	Netlink Netlink
+}
+
+// AttachXDP attaches an XDP program to a network interface.
+func AttachXDP(opts XDPOptions) (Link, error) {
+	progFd, err := programFD(opts.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	if t := opts.Program.ABI().Type; t != ebpf.XDP {
+		return nil, xerrors.Errorf("invalid program type %s, expected XDP", t)
+	}
+
+	if opts.Interface < 1 {
+		return nil, xerrors.Errorf("invalid interface index %d", opts.Interface)
+	}
+
+	if err := haveBPFLinkXDP(); err != nil {
+		if opts.Netlink == nil {
+			return nil, xerrors.Errorf("can't attach XDP program: %w", err)
+		}
+		return attachXDPNetlink(opts, int(progFd))
+	}
+
+	attr := bpfLinkCreateAttr{
+		progFd:     progFd,
+		targetFd:   uint32(opts.Interface),
+		attachType: ebpf.AttachXDP,
+		flags:      uint32(opts.Flags),
+	}
+	fd, err := bpfLinkCreate(unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return nil, xerrors.Errorf("can't attach XDP program: %w", err)
+	}
+
+	return &RawLink{fd}, nil
+}
+
+func attachXDPNetlink(opts XDPOptions, progFd int) (*netlinkXDPLink, error) {
+	if err := opts.Netlink.SetXDP(opts.Interface, progFd, opts.Flags); err != nil {
+		return nil, xerrors.Errorf("can't attach XDP program via netlink: %w", err)
+	}
+
+	return &netlinkXDPLink{opts.Netlink, opts.Interface, opts.Flags}, nil
+}
+
+// netlinkXDPLink is an XDP program attached via netlink.
+type netlinkXDPLink struct {
+	netlink Netlink
+	ifindex int
+	flags   XDPAttachFlags
+}
+
+var _ Link = (*netlinkXDPLink)(nil)
+
+func (nl *netlinkXDPLink) isLink() {}
+
+// Pin is not supported for programs attached via netlink, since they
+// stay attached until they are detached explicitly.
+func (nl *netlinkXDPLink) Pin(string) error {
+	return xerrors.Errorf("can't pin XDP program attached via netlink: %w", internal.ErrNotSupported)
+}
+
+// Update implements the Link interface.
+func (nl *netlinkXDPLink) Update(new *ebpf.Program) error {
+	progFd, err := programFD(new)
+	if err != nil {
+		return err
+	}
+
+	if err := nl.netlink.SetXDP(nl.ifindex, int(progFd), nl.flags); err != nil {
+		return xerrors.Errorf("can't update XDP program via netlink: %w", err)
+	}
+	return nil
+}
+
+// Close detaches the program.
+func (nl *netlinkXDPLink) Close() error {
+	if err := nl.netlink.SetXDP(nl.ifindex, -1, nl.flags); err != nil {
+		return xerrors.Errorf("can't detach XDP program via netlink: %w", err)
+	}
+	return nil
+}
+
+var haveBPFLinkXDP = internal.FeatureTest("bpf_link_xdp", "5.9", func() bool {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:       ebpf.XDP,
+		AttachType: ebpf.AttachXDP,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 2), // XDP_PASS
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		return false
+	}
+	defer prog.Close()
+
+	// Kernels without bpf_link for XDP return EINVAL for any interface,
+	// including invalid ones, so an invalid ifindex doesn't tell them
+	// apart. Instead, ask for driver mode on the loopback interface,
+	// which is always ifindex 1 and has no XDP support in its driver.
+	// Supported kernels reject this with EOPNOTSUPP without attaching
+	// anything.
+	attr := bpfLinkCreateAttr{
+		progFd:     uint32(prog.FD()),
+		targetFd:   1,
+		attachType: ebpf.AttachXDP,
+		flags:      uint32(XDPDriverMode),
+	}
+	fd, err := internal.BPF(internal.BPF_LINK_CREATE, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err == nil {
+		internal.NewFD(uint32(fd)).Close()
+		return true
+	}
+	return xerrors.Is(err, unix.EOPNOTSUPP)
+})
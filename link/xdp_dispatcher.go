@@ -0,0 +1,378 @@
+package link
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/btf"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// XDPAction is the return value of an XDP program.
+type XDPAction uint32
+
+// Valid XDP actions, see enum xdp_action in linux/bpf.h.
+const (
+	XDPAborted XDPAction = iota
+	XDPDrop
+	XDPPass
+	XDPTx
+	XDPRedirect
+)
+
+// XDPDispatcherMaxPrograms is the number of programs an XDPDispatcher
+// can run, which is fixed by the libxdp dispatcher layout.
+const XDPDispatcherMaxPrograms = 10
+
+// XDPDefaultPriority is the priority libxdp assigns to programs which
+// don't specify one.
+const XDPDefaultPriority = 50
+
+// Constants shared with xdp-dispatcher.c in libxdp.
+const (
+	xdpDispatcherMagic   = 236
+	xdpDispatcherVersion = 2
+	// Returned by unused slots. Always part of the chain call actions,
+	// so that an empty slot continues to the next one.
+	xdpDispatcherRetval = 31
+)
+
+// xdpDispatcherConfig is struct xdp_dispatcher_config from libxdp.
+type xdpDispatcherConfig struct {
+	Magic             uint8
+	DispatcherVersion uint8
+	NumProgsEnabled   uint8
+	IsXDPFrags        uint8
+	ChainCallActions  [XDPDispatcherMaxPrograms]uint32
+	RunPrios          [XDPDispatcherMaxPrograms]uint32
+	ProgramFlags      [XDPDispatcherMaxPrograms]uint32
+}
+
+// XDPProgram is a program run by an XDPDispatcher.
+type XDPProgram struct {
+	// Program must be of type XDP. It is loaded as an Extension which
+	// replaces one of the slots of the dispatcher, and therefore must
+	// carry BTF for its entry point.
+	Program *ebpf.ProgramSpec
+	// Programs run in order of ascending priority. Programs with the
+	// same priority run in the order they are passed in.
+	Priority uint32
+	// The dispatcher continues with the next program if this program
+	// returns one of ChainCallActions. Defaults to XDPPass.
+	ChainCallActions []XDPAction
+}
+
+// XDPDispatcherOptions control the program generated by
+// NewXDPDispatcher.
+type XDPDispatcherOptions struct {
+	// At most XDPDispatcherMaxPrograms programs.
+	Programs []XDPProgram
+}
+
+// XDPDispatcher runs multiple XDP programs on the same hook.
+//
+// The dispatcher follows the conventions of libxdp, which allows
+// programs loaded from Go to coexist with programs loaded by xdp-tools.
+// It consists of a program with a slot for each member, which is
+// replaced by the member using freplace, and a read-only map holding
+// the run priorities and chain call actions of the members.
+//
+// The set of programs can't be changed. Instead, create a new
+// dispatcher and atomically replace the attached one.
+type XDPDispatcher struct {
+	prog    *ebpf.Program
+	config  *ebpf.Map
+	members []*ebpf.Program
+	links   []Link
+}
+
+// NewXDPDispatcher loads a dispatcher and its members.
+//
+// Attach XDPDispatcher.Program to a network interface.
+//
+// Requires at least Linux 5.6.
+func NewXDPDispatcher(opts XDPDispatcherOptions) (*XDPDispatcher, error) {
+	if len(opts.Programs) > XDPDispatcherMaxPrograms {
+		return nil, xerrors.Errorf("can't dispatch to more than %d programs", XDPDispatcherMaxPrograms)
+	}
+
+	progs := make([]XDPProgram, len(opts.Programs))
+	copy(progs, opts.Programs)
+	sort.SliceStable(progs, func(i, j int) bool {
+		return progs[i].Priority < progs[j].Priority
+	})
+
+	config := xdpDispatcherConfig{
+		Magic:             xdpDispatcherMagic,
+		DispatcherVersion: xdpDispatcherVersion,
+		NumProgsEnabled:   uint8(len(progs)),
+	}
+	for i, prog := range progs {
+		if prog.Program == nil {
+			return nil, xerrors.Errorf("program %d is nil", i)
+		}
+		if prog.Program.Type != ebpf.XDP {
+			return nil, xerrors.Errorf("program %s: invalid type %s, expected XDP", prog.Program.Name, prog.Program.Type)
+		}
+
+		actions := prog.ChainCallActions
+		if actions == nil {
+			actions = []XDPAction{XDPPass}
+		}
+
+		mask := uint32(1) << xdpDispatcherRetval
+		for _, action := range actions {
+			if action >= xdpDispatcherRetval {
+				return nil, xerrors.Errorf("program %s: invalid chain call action %d", prog.Program.Name, action)
+			}
+			mask |= 1 << action
+		}
+
+		config.ChainCallActions[i] = mask
+		config.RunPrios[i] = prog.Priority
+		config.ProgramFlags[i] = prog.Program.Flags
+	}
+
+	d := &XDPDispatcher{}
+	if err := d.load(config); err != nil {
+		d.Close()
+		return nil, err
+	}
+
+	for i, prog := range progs {
+		if err := d.attach(i, prog.Program); err != nil {
+			d.Close()
+			return nil, xerrors.Errorf("program %s: %w", prog.Program.Name, err)
+		}
+	}
+
+	return d, nil
+}
+
+func (d *XDPDispatcher) load(config xdpDispatcherConfig) error {
+	var err error
+	d.config, err = ebpf.NewMap(&ebpf.MapSpec{
+		Name:       "xdp_dispatcher",
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  uint32(binary.Size(config)),
+		MaxEntries: 1,
+		Flags:      unix.BPF_F_RDONLY_PROG,
+		Contents:   []ebpf.MapKV{{Key: uint32(0), Value: config}},
+		Freeze:     true,
+	})
+	if err != nil {
+		return xerrors.Errorf("can't create dispatcher config: %w", err)
+	}
+
+	insns := xdpDispatcherInstructions(d.config.FD())
+	prog, err := xdpDispatcherBTF(insns)
+	if err != nil {
+		return xerrors.Errorf("can't generate dispatcher BTF: %w", err)
+	}
+
+	d.prog, err = ebpf.NewProgramWithOptions(&ebpf.ProgramSpec{
+		Name:         "xdp_dispatcher",
+		Type:         ebpf.XDP,
+		Instructions: insns,
+		License:      "GPL",
+		BTF:          prog,
+	}, ebpf.ProgramOptions{RequireBTF: true})
+	if err != nil {
+		return xerrors.Errorf("can't load dispatcher: %w", err)
+	}
+
+	return nil
+}
+
+func (d *XDPDispatcher) attach(slot int, spec *ebpf.ProgramSpec) error {
+	spec = spec.Copy()
+	spec.Type = ebpf.Extension
+	spec.AttachType = ebpf.AttachNone
+	spec.AttachTo = xdpDispatcherSlot(slot)
+	spec.AttachTarget = d.prog
+
+	prog, err := ebpf.NewProgramWithOptions(spec, ebpf.ProgramOptions{RequireBTF: true})
+	if err != nil {
+		return err
+	}
+	d.members = append(d.members, prog)
+
+	link, err := AttachFreplace(prog)
+	if err != nil {
+		return err
+	}
+	d.links = append(d.links, link)
+
+	return nil
+}
+
+// Program returns the dispatcher program, which should be attached
+// to the hook.
+//
+// The program is owned by the XDPDispatcher and mustn't be closed.
+func (d *XDPDispatcher) Program() *ebpf.Program {
+	return d.prog
+}
+
+// Programs returns the members of the dispatcher in the order they
+// are run.
+//
+// The programs are owned by the XDPDispatcher and mustn't be closed.
+func (d *XDPDispatcher) Programs() []*ebpf.Program {
+	return d.members
+}
+
+// Pin persists the members of the dispatcher using the naming scheme
+// of libxdp.
+//
+// libxdp expects dir to be dispatch-<ifindex>-<dispatcher ID> in the
+// xdp directory of a bpffs, for example /sys/fs/bpf/xdp.
+func (d *XDPDispatcher) Pin(dir string) error {
+	for i, prog := range d.members {
+		slot := xdpDispatcherSlot(i)
+		if err := prog.Pin(filepath.Join(dir, slot+"-prog")); err != nil {
+			return xerrors.Errorf("%s: %w", slot, err)
+		}
+		if err := d.links[i].Pin(filepath.Join(dir, slot+"-link")); err != nil {
+			return xerrors.Errorf("%s: %w", slot, err)
+		}
+	}
+	return nil
+}
+
+// Close frees the dispatcher and its members.
+//
+// Hooks the dispatcher is attached to keep using it until they are
+// detached.
+func (d *XDPDispatcher) Close() error {
+	var firstErr error
+	for _, link := range d.links {
+		if err := link.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, prog := range d.members {
+		if err := prog.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if d.prog != nil {
+		if err := d.prog.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if d.config != nil {
+		if err := d.config.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func xdpDispatcherSlot(i int) string {
+	return fmt.Sprintf("prog%d", i)
+}
+
+// xdpDispatcherInstructions generates the equivalent of
+// xdp-dispatcher.c:
+//
+//	int xdp_dispatcher(struct xdp_md *ctx) {
+//		if (conf.num_progs_enabled < 1)
+//			goto out;
+//		ret = prog0(ctx);
+//		if (!((1U << ret) & conf.chain_call_actions[0]))
+//			return ret;
+//		...
+//	out:
+//		return XDP_PASS;
+//	}
+//
+// Every slot is a global function, so that the verifier doesn't make
+// assumptions about its return value.
+func xdpDispatcherInstructions(configFd int) asm.Instructions {
+	const (
+		ctx          = asm.R6
+		config       = asm.R7
+		numProgs     = asm.R8
+		returnResult = "return"
+		returnPass   = "out"
+	)
+
+	insns := asm.Instructions{
+		asm.Mov.Reg(ctx, asm.R1).Sym("xdp_dispatcher"),
+		asm.LoadMapValue(config, configFd, 0),
+		asm.LoadMem(numProgs, config, 2, asm.Byte),
+	}
+
+	for i := 0; i < XDPDispatcherMaxPrograms; i++ {
+		insns = append(insns,
+			asm.JLE.Imm(numProgs, int32(i), returnPass),
+			asm.Mov.Reg(asm.R1, ctx),
+			asm.Call.Label(xdpDispatcherSlot(i)),
+			asm.LoadMem(asm.R1, config, int16(4+4*i), asm.Word),
+			asm.Mov.Imm32(asm.R2, 1),
+			asm.LSh.Reg32(asm.R2, asm.R0),
+			asm.And.Reg32(asm.R2, asm.R1),
+			asm.JEq.Imm(asm.R2, 0, returnResult),
+		)
+	}
+
+	insns = append(insns,
+		asm.Mov.Imm(asm.R0, int32(XDPPass)).Sym(returnPass),
+		asm.Return().Sym(returnResult),
+	)
+
+	for i := 0; i < XDPDispatcherMaxPrograms; i++ {
+		insns = append(insns,
+			// Mirror the NULL check of the C version.
+			asm.Mov.Imm(asm.R0, int32(XDPAborted)).Sym(xdpDispatcherSlot(i)),
+			asm.JEq.Imm(asm.R1, 0, xdpDispatcherSlot(i)+"_out"),
+			asm.Mov.Imm(asm.R0, xdpDispatcherRetval),
+			asm.Return().Sym(xdpDispatcherSlot(i)+"_out"),
+		)
+	}
+
+	return insns
+}
+
+// xdpDispatcherBTF describes the functions of the dispatcher, which is
+// required to replace them.
+func xdpDispatcherBTF(insns asm.Instructions) (*btf.Program, error) {
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	xdpMD := &btf.Struct{Name: "xdp_md", Size: 24}
+	for i, name := range []string{"data", "data_end", "data_meta", "ingress_ifindex", "rx_queue_index", "egress_ifindex"} {
+		xdpMD.Members = append(xdpMD.Members, btf.Member{Name: btf.Name(name), Type: u32, Offset: uint32(i * 32)})
+	}
+	proto := &btf.FuncProto{
+		Return: &btf.Int{Name: "int", Size: 4, Encoding: btf.Signed},
+		Params: []btf.FuncParam{{Name: "ctx", Type: &btf.Pointer{Target: xdpMD}}},
+	}
+
+	isFunc := map[string]bool{"xdp_dispatcher": true}
+	for i := 0; i < XDPDispatcherMaxPrograms; i++ {
+		isFunc[xdpDispatcherSlot(i)] = true
+	}
+
+	var funcs []btf.FuncOffset
+	iter := insns.Iterate()
+	for iter.Next() {
+		name := iter.Ins.Symbol
+		if !isFunc[name] {
+			continue
+		}
+
+		funcs = append(funcs, btf.FuncOffset{
+			Func:   &btf.Func{Name: btf.Name(name), Type: proto, Linkage: btf.GlobalFunc},
+			Offset: iter.Offset.Bytes(),
+		})
+	}
+
+	return btf.NewBuilder().Program(uint64(insns.Size()), funcs)
+}
@@ -0,0 +1,107 @@
+package link
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/btf"
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+func TestXDPDispatcherBTF(t *testing.T) {
+	prog, err := xdpDispatcherBTF(xdpDispatcherInstructions(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, infos, err := btf.ProgramFuncInfos(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(infos) / 8; n != XDPDispatcherMaxPrograms+1 {
+		t.Errorf("Expected %d functions, got %d", XDPDispatcherMaxPrograms+1, n)
+	}
+
+	for _, name := range []string{"xdp_dispatcher", "prog0", "prog9"} {
+		fn := new(btf.Func)
+		if err := btf.ProgramSpec(prog).FindType(name, fn); err != nil {
+			t.Fatal(err)
+		}
+		if fn.Linkage != btf.GlobalFunc {
+			t.Errorf("%s isn't a global function", name)
+		}
+	}
+}
+
+func TestXDPDispatcher(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.6", "freplace")
+
+	d, err := NewXDPDispatcher(XDPDispatcherOptions{
+		Programs: []XDPProgram{
+			{Program: xdpMember(t, XDPDrop), Priority: 20},
+			{Program: xdpMember(t, XDPPass), Priority: 10},
+		},
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if xerrors.Is(err, unix.EPERM) {
+		// Loading extensions requires CAP_PERFMON.
+		t.Skip("Can't load extension:", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if n := len(d.Programs()); n != 2 {
+		t.Fatal("Expected two members, got", n)
+	}
+
+	ret, _, err := d.Program().Test(make([]byte, 14))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != uint32(XDPDrop) {
+		t.Errorf("Expected XDPDrop, got %d", ret)
+	}
+}
+
+func TestXDPDispatcherTooManyPrograms(t *testing.T) {
+	progs := make([]XDPProgram, XDPDispatcherMaxPrograms+1)
+	if _, err := NewXDPDispatcher(XDPDispatcherOptions{Programs: progs}); err == nil {
+		t.Error("NewXDPDispatcher accepts too many programs")
+	}
+}
+
+func xdpMember(t *testing.T, action XDPAction) *ebpf.ProgramSpec {
+	t.Helper()
+
+	xdpMD := &btf.Struct{Name: "xdp_md", Size: 24}
+	fn := &btf.Func{
+		Name: "member",
+		Type: &btf.FuncProto{
+			Return: &btf.Int{Name: "int", Size: 4, Encoding: btf.Signed},
+			Params: []btf.FuncParam{{Name: "ctx", Type: &btf.Pointer{Target: xdpMD}}},
+		},
+		Linkage: btf.GlobalFunc,
+	}
+
+	prog, err := btf.NewBuilder().Program(2*asm.InstructionSize, []btf.FuncOffset{{Func: fn}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &ebpf.ProgramSpec{
+		Name: "member",
+		Type: ebpf.XDP,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, int32(action)),
+			asm.Return(),
+		},
+		License: "GPL",
+		BTF:     prog,
+	}
+}
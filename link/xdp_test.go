@@ -0,0 +1,128 @@
+package link
+
+import (
+	"net"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestAttachXDP(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.9", "XDP links")
+
+	prog := mustXDPPassProgram(t)
+	defer prog.Close()
+
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := AttachXDP(XDPOptions{
+		Program:   prog,
+		Interface: lo.Index,
+		Flags:     XDPGenericMode,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't attach XDP program:", err)
+	}
+	defer link.Close()
+
+	info, err := link.(*RawLink).Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Type != XDPType {
+		t.Error("Expected XDPType, got", info.Type)
+	}
+
+	if err := link.Update(prog); err != nil {
+		t.Error("Can't update link:", err)
+	}
+}
+
+func TestAttachXDPNetlink(t *testing.T) {
+	prog := mustXDPPassProgram(t)
+	defer prog.Close()
+
+	nl := &fakeNetlink{}
+	link, err := attachXDPNetlink(XDPOptions{
+		Program:   prog,
+		Interface: 1,
+		Flags:     XDPDriverMode,
+		Netlink:   nl,
+	}, prog.FD())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nl.xdpProg != prog.FD() || nl.xdpFlags != XDPDriverMode {
+		t.Errorf("Program wasn't attached: %+v", nl)
+	}
+
+	if err := link.Pin("/sys/fs/bpf/foo"); err == nil {
+		t.Error("Pinning a netlink XDP program doesn't return an error")
+	}
+
+	if err := link.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if nl.xdpProg != -1 {
+		t.Error("Program wasn't detached")
+	}
+}
+
+func TestHaveBPFLinkXDP(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveBPFLinkXDP)
+}
+
+func mustXDPPassProgram(tb testing.TB) *ebpf.Program {
+	tb.Helper()
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type: ebpf.XDP,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, int32(XDPPass)),
+			asm.Return(),
+		},
+		License: "GPL",
+	})
+	testutils.SkipIfNotSupported(tb, err)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return prog
+}
+
+// fakeNetlink records the programs attached via Netlink.
+type fakeNetlink struct {
+	xdpProg  int
+	xdpFlags XDPAttachFlags
+	filters  map[uint32]int
+}
+
+func (fn *fakeNetlink) SetXDP(ifindex, progFd int, flags XDPAttachFlags) error {
+	fn.xdpProg = progFd
+	fn.xdpFlags = flags
+	return nil
+}
+
+func (fn *fakeNetlink) AttachTCFilter(filter *TCFilter, progFd int) error {
+	if fn.filters == nil {
+		fn.filters = make(map[uint32]int)
+	}
+	if filter.Handle == 0 {
+		filter.Priority = 1
+		filter.Handle = uint32(len(fn.filters) + 1)
+	}
+	fn.filters[filter.Handle] = progFd
+	return nil
+}
+
+func (fn *fakeNetlink) DetachTCFilter(filter *TCFilter) error {
+	delete(fn.filters, filter.Handle)
+	return nil
+}
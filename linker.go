@@ -2,6 +2,7 @@ package ebpf
 
 import (
 	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/btf"
 
 	"golang.org/x/xerrors"
@@ -10,28 +11,41 @@ import (
 // link resolves bpf-to-bpf calls.
 //
 // Each library may contain multiple functions / labels, and is only linked
-// if the program being edited references one of these functions.
-//
-// Libraries must not require linking themselves.
+// if the program being edited references one of these functions, either
+// directly or via another library.
 func link(prog *ProgramSpec, libs []*ProgramSpec) error {
-	for _, lib := range libs {
-		insns, err := linkSection(prog.Instructions, lib.Instructions)
-		if err != nil {
-			return xerrors.Errorf("linking %s: %w", lib.Name, err)
-		}
+	linked := make(map[*ProgramSpec]bool)
+	for {
+		var changed bool
+		for _, lib := range libs {
+			if linked[lib] {
+				continue
+			}
 
-		if len(insns) == len(prog.Instructions) {
-			continue
-		}
+			insns, err := linkSection(prog.Instructions, lib.Instructions)
+			if err != nil {
+				return xerrors.Errorf("linking %s: %w", lib.Name, err)
+			}
+
+			if len(insns) == len(prog.Instructions) {
+				continue
+			}
 
-		prog.Instructions = insns
-		if prog.BTF != nil && lib.BTF != nil {
-			if err := btf.ProgramAppend(prog.BTF, lib.BTF); err != nil {
-				return xerrors.Errorf("linking BTF of %s: %w", lib.Name, err)
+			internal.Debug("Linked subprogram", "program", prog.Name, "subprogram", lib.Name)
+			linked[lib] = true
+			changed = true
+			prog.Instructions = insns
+			if prog.BTF != nil && lib.BTF != nil {
+				if err := btf.ProgramAppend(prog.BTF, lib.BTF); err != nil {
+					return xerrors.Errorf("linking BTF of %s: %w", lib.Name, err)
+				}
 			}
 		}
+
+		if !changed {
+			return nil
+		}
 	}
-	return nil
 }
 
 func linkSection(insns, section asm.Instructions) (asm.Instructions, error) {
@@ -41,6 +55,11 @@ func linkSection(insns, section asm.Instructions) (asm.Instructions, error) {
 		return nil, err
 	}
 
+	defined, err := insns.SymbolOffsets()
+	if err != nil {
+		return nil, err
+	}
+
 	for _, ins := range insns {
 		if ins.Reference == "" {
 			continue
@@ -55,6 +74,11 @@ func linkSection(insns, section asm.Instructions) (asm.Instructions, error) {
 			continue
 		}
 
+		if _, ok := defined[ins.Reference]; ok {
+			// The function was already linked.
+			continue
+		}
+
 		if _, ok := symbols[ins.Reference]; !ok {
 			// Symbol isn't available in this section
 			continue
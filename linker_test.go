@@ -50,3 +50,74 @@ func TestLink(t *testing.T) {
 		t.Errorf("Expected return code 1337, got %d", ret)
 	}
 }
+
+func TestLinkTransitive(t *testing.T) {
+	spec := &ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.Call.Label("outer"),
+			asm.Return(),
+		},
+		License: "MIT",
+	}
+
+	libs := []*ProgramSpec{
+		{
+			Name: "inner",
+			Instructions: asm.Instructions{
+				asm.LoadImm(asm.R0, 1337, asm.DWord).Sym("inner"),
+				asm.Return(),
+			},
+		},
+		{
+			Name: "unused",
+			Instructions: asm.Instructions{
+				asm.LoadImm(asm.R0, 0, asm.DWord).Sym("unused"),
+				asm.Return(),
+			},
+		},
+		{
+			Name: "outer",
+			Instructions: asm.Instructions{
+				asm.Call.Label("inner").Sym("outer"),
+				asm.Return(),
+			},
+		},
+	}
+
+	if err := link(spec, libs); err != nil {
+		t.Fatal(err)
+	}
+
+	syms, err := spec.Instructions.SymbolOffsets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := syms["outer"]; !ok {
+		t.Error("Directly called function isn't linked")
+	}
+	if _, ok := syms["inner"]; !ok {
+		t.Error("Indirectly called function isn't linked")
+	}
+	if _, ok := syms["unused"]; ok {
+		t.Error("Unreferenced function is linked")
+	}
+
+	testutils.SkipOnOldKernel(t, "4.16", "bpf2bpf calls")
+
+	prog, err := NewProgram(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	ret, _, err := prog.Test(make([]byte, 14))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ret != 1337 {
+		t.Errorf("Expected return code 1337, got %d", ret)
+	}
+}
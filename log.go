@@ -0,0 +1,22 @@
+package ebpf
+
+import "github.com/cilium/ebpf/internal"
+
+// Logger receives debug messages from the library.
+//
+// Messages are followed by alternating keys and values, like the
+// arguments of the methods of *slog.Logger, which implements Logger.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+}
+
+// SetLogger enables debug logging of the decisions made while parsing
+// object files, loading collections and attaching programs, including
+// the ones made by the link package. This helps debugging failures deep
+// inside the loader. Pass nil to disable logging.
+//
+// The Logger is shared by all goroutines and must be safe for concurrent
+// use.
+func SetLogger(l Logger) {
+	internal.SetLogger(l)
+}
@@ -0,0 +1,52 @@
+package ebpf
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+type testLogger struct {
+	mu   sync.Mutex
+	msgs map[string][]interface{}
+}
+
+func (tl *testLogger) Debug(msg string, args ...interface{}) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.msgs[msg] = args
+}
+
+func TestSetLogger(t *testing.T) {
+	logger := &testLogger{msgs: make(map[string][]interface{})}
+	SetLogger(logger)
+	defer SetLogger(nil)
+
+	spec, err := LoadCollectionSpec("testdata/loader-clang-8.elf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coll, err := NewCollectionWithOptions(spec, CollectionOptions{
+		ProgramFilter: func(name string) bool { return name == "no_relocation" },
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coll.Close()
+
+	SetLogger(nil)
+
+	for _, msg := range []string{"Found program", "Loaded program"} {
+		args, ok := logger.msgs[msg]
+		if !ok {
+			t.Errorf("Missing message %q", msg)
+			continue
+		}
+		if len(args)%2 != 0 {
+			t.Errorf("Message %q has unpaired arguments: %v", msg, args)
+		}
+	}
+}
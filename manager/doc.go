@@ -0,0 +1,7 @@
+// Package manager loads a collection and attaches its programs.
+//
+// Most users of the library load a CollectionSpec, attach a handful of
+// programs and tear everything down again on exit, taking care not to
+// leak links when one of the attach points is missing. A Manager does
+// this based on a declarative list of Probes.
+package manager
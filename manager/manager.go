@@ -0,0 +1,224 @@
+package manager
+
+import (
+	"io"
+	"os"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	"golang.org/x/xerrors"
+)
+
+// Probe declares where a program of a collection is attached.
+type Probe struct {
+	// Program is the name of the program in the CollectionSpec.
+	Program string
+
+	// Target is the attach point of the program. It is one of
+	//
+	//	kprobe/<symbol>
+	//	kretprobe/<symbol>
+	//	uprobe/<path>:<symbol>
+	//	uretprobe/<path>:<symbol>
+	//	tracepoint/<group>/<name>
+	//	raw_tracepoint/<name>
+	//	tracing
+	//	cgroup/<path>
+	//
+	// "tracing" is used for fentry, fexit, fmod_ret and tp_btf programs,
+	// which specify their target via ProgramSpec.AttachTo. Programs
+	// attached to a cgroup use ProgramSpec.AttachType.
+	Target string
+
+	// Optional probes which fail to attach are skipped instead of
+	// aborting Start. Loading the program must still succeed.
+	Optional bool
+}
+
+// Options control a Manager.
+type Options struct {
+	// Probes are attached in order by Start, and detached in reverse
+	// order by Stop.
+	Probes []Probe
+
+	// Collection is passed to ebpf.NewCollectionWithOptions.
+	Collection ebpf.CollectionOptions
+
+	// OnSkip is called for optional probes which failed to attach.
+	OnSkip func(probe Probe, err error)
+}
+
+// Manager owns the programs and maps of a collection and the links
+// that attach the programs.
+type Manager struct {
+	spec   *ebpf.CollectionSpec
+	opts   Options
+	probes []*target
+
+	coll  *ebpf.Collection
+	links []io.Closer
+}
+
+// New creates a Manager for the given spec.
+//
+// Probes are validated, but nothing is loaded until Start is called.
+func New(spec *ebpf.CollectionSpec, opts Options) (*Manager, error) {
+	probes := make([]*target, 0, len(opts.Probes))
+	for _, probe := range opts.Probes {
+		if spec.Programs[probe.Program] == nil {
+			return nil, xerrors.Errorf("probe %s: %w", probe.Program, ebpf.ErrNotExist)
+		}
+
+		t, err := parseTarget(probe.Target)
+		if err != nil {
+			return nil, xerrors.Errorf("probe %s: %w", probe.Program, err)
+		}
+
+		probes = append(probes, t)
+	}
+
+	return &Manager{
+		spec:   spec,
+		opts:   opts,
+		probes: probes,
+	}, nil
+}
+
+// Start loads the collection and attaches all probes.
+//
+// If a probe fails to attach, probes which were already attached are
+// detached again and the collection is closed. It is an error to call
+// Start on a Manager which is already running.
+func (m *Manager) Start() error {
+	if m.coll != nil {
+		return xerrors.New("manager is already started")
+	}
+
+	coll, err := ebpf.NewCollectionWithOptions(m.spec, m.opts.Collection)
+	if err != nil {
+		return xerrors.Errorf("can't load collection: %w", err)
+	}
+	m.coll = coll
+
+	for i, probe := range m.opts.Probes {
+		l, err := m.attach(probe, m.probes[i])
+		if err != nil && probe.Optional {
+			if m.opts.OnSkip != nil {
+				m.opts.OnSkip(probe, err)
+			}
+			continue
+		}
+		if err != nil {
+			m.Stop()
+			return xerrors.Errorf("probe %s: %w", probe.Program, err)
+		}
+
+		m.links = append(m.links, l)
+	}
+
+	return nil
+}
+
+func (m *Manager) attach(probe Probe, t *target) (io.Closer, error) {
+	prog := m.coll.Programs[probe.Program]
+	if prog == nil {
+		return nil, xerrors.Errorf("program wasn't loaded: %w", ebpf.ErrNotExist)
+	}
+
+	switch t.kind {
+	case kprobeTarget:
+		return link.Kprobe(t.args[0], prog, nil)
+	case kretprobeTarget:
+		return link.Kretprobe(t.args[0], prog, nil)
+	case uprobeTarget, uretprobeTarget:
+		ex, err := link.OpenExecutable(t.args[0])
+		if err != nil {
+			return nil, err
+		}
+		if t.kind == uprobeTarget {
+			return ex.Uprobe(t.args[1], prog, nil)
+		}
+		return ex.Uretprobe(t.args[1], prog, nil)
+	case tracepointTarget:
+		return link.Tracepoint(t.args[0], t.args[1], prog, nil)
+	case rawTracepointTarget:
+		return link.AttachRawTracepoint(link.RawTracepointOptions{Name: t.args[0], Program: prog})
+	case tracingTarget:
+		return link.AttachTracing(link.TracingOptions{Program: prog})
+	case cgroupTarget:
+		return attachCgroup(t.args[0], prog, m.spec.Programs[probe.Program].AttachType)
+	default:
+		return nil, xerrors.Errorf("unknown target kind %q", t.kind)
+	}
+}
+
+// allowMulti is BPF_F_ALLOW_MULTI, which allows other programs to be
+// attached to the same cgroup.
+const allowMulti ebpf.AttachFlags = 1 << 1
+
+// cgroupAttachment is a program attached to a cgroup using
+// BPF_PROG_ATTACH.
+type cgroupAttachment struct {
+	cgroup *os.File
+	prog   *ebpf.Program
+	typ    ebpf.AttachType
+}
+
+func attachCgroup(path string, prog *ebpf.Program, typ ebpf.AttachType) (io.Closer, error) {
+	cgroup, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := prog.Attach(int(cgroup.Fd()), typ, allowMulti); err != nil {
+		cgroup.Close()
+		return nil, xerrors.Errorf("can't attach to cgroup %s: %w", path, err)
+	}
+
+	return &cgroupAttachment{cgroup, prog, typ}, nil
+}
+
+func (ca *cgroupAttachment) Close() error {
+	defer ca.cgroup.Close()
+	return ca.prog.Detach(int(ca.cgroup.Fd()), ca.typ, allowMulti)
+}
+
+// Stop detaches all probes in reverse order and closes the collection.
+//
+// Stop continues after errors and returns the first one. It is safe to
+// call Stop multiple times, or on a Manager which wasn't started.
+func (m *Manager) Stop() error {
+	var firstErr error
+	for i := len(m.links) - 1; i >= 0; i-- {
+		if err := m.links[i].Close(); err != nil && firstErr == nil {
+			firstErr = xerrors.Errorf("can't detach probe: %w", err)
+		}
+	}
+	m.links = nil
+
+	if m.coll != nil {
+		m.coll.Close()
+		m.coll = nil
+	}
+
+	return firstErr
+}
+
+// Program returns a loaded program by name, or nil if the Manager isn't
+// started or the program doesn't exist.
+func (m *Manager) Program(name string) *ebpf.Program {
+	if m.coll == nil {
+		return nil
+	}
+	return m.coll.Programs[name]
+}
+
+// Map returns a loaded map by name, or nil if the Manager isn't started
+// or the map doesn't exist.
+func (m *Manager) Map(name string) *ebpf.Map {
+	if m.coll == nil {
+		return nil
+	}
+	return m.coll.Maps[name]
+}
@@ -0,0 +1,104 @@
+package manager
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+)
+
+func TestParseTarget(t *testing.T) {
+	for str, want := range map[string]*target{
+		"kprobe/do_sys_open":                 {kprobeTarget, []string{"do_sys_open"}},
+		"uretprobe//bin/bash:readline":       {uretprobeTarget, []string{"/bin/bash", "readline"}},
+		"tracepoint/syscalls/sys_enter_fork": {tracepointTarget, []string{"syscalls", "sys_enter_fork"}},
+		"raw_tracepoint/sched_process_exec":  {rawTracepointTarget, []string{"sched_process_exec"}},
+		"tracing":                            {tracingTarget, nil},
+		"cgroup/sys/fs/cgroup/unified/":      {cgroupTarget, []string{"/sys/fs/cgroup/unified"}},
+		"cgroup//sys/fs/cgroup":              {cgroupTarget, []string{"/sys/fs/cgroup"}},
+		"kprobe/":                            nil,
+		"uprobe//bin/bash":                   nil,
+		"tracepoint/syscalls":                nil,
+		"tracing/foo":                        nil,
+		"xdp/eth0":                           nil,
+	} {
+		have, err := parseTarget(str)
+		if want == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error", str)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", str, err)
+			continue
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("%s: have %+v, want %+v", str, have, want)
+		}
+	}
+}
+
+func TestManager(t *testing.T) {
+	spec := &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{
+			"exec": {
+				Type: ebpf.RawTracepoint,
+				Instructions: asm.Instructions{
+					asm.LoadImm(asm.R0, 0, asm.DWord),
+					asm.Return(),
+				},
+				License: "GPL",
+			},
+		},
+	}
+
+	_, err := New(spec, Options{Probes: []Probe{{Program: "missing", Target: "tracing"}}})
+	if err == nil {
+		t.Error("New accepts a probe for a missing program")
+	}
+
+	mgr, err := New(spec, Options{
+		Probes: []Probe{
+			{Program: "exec", Target: "raw_tracepoint/sched_process_exec"},
+			{Program: "exec", Target: "raw_tracepoint/does_not_exist"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mgr.Start(); err == nil {
+		t.Fatal("Start doesn't return an error for a missing tracepoint")
+	}
+	if mgr.Program("exec") != nil || len(mgr.links) != 0 {
+		t.Error("Start doesn't roll back after an error")
+	}
+
+	var skipped []Probe
+	mgr.opts.Probes[1].Optional = true
+	mgr.opts.OnSkip = func(probe Probe, _ error) {
+		skipped = append(skipped, probe)
+	}
+
+	if err := mgr.Start(); err != nil {
+		t.Fatal("Can't start with an optional probe:", err)
+	}
+	if len(skipped) != 1 || len(mgr.links) != 1 {
+		t.Errorf("Expected one skipped and one attached probe, got %d and %d", len(skipped), len(mgr.links))
+	}
+	if mgr.Program("exec") == nil {
+		t.Error("Program returns nil for a running manager")
+	}
+	if err := mgr.Start(); err == nil {
+		t.Error("Starting twice doesn't return an error")
+	}
+
+	if err := mgr.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Stop(); err != nil {
+		t.Error("Stopping twice returns an error:", err)
+	}
+}
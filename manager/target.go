@@ -0,0 +1,81 @@
+package manager
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// targetKind is the part of an attach target before the first slash.
+type targetKind string
+
+const (
+	kprobeTarget        targetKind = "kprobe"
+	kretprobeTarget     targetKind = "kretprobe"
+	uprobeTarget        targetKind = "uprobe"
+	uretprobeTarget     targetKind = "uretprobe"
+	tracepointTarget    targetKind = "tracepoint"
+	rawTracepointTarget targetKind = "raw_tracepoint"
+	tracingTarget       targetKind = "tracing"
+	cgroupTarget        targetKind = "cgroup"
+)
+
+// target is a parsed attach target.
+type target struct {
+	kind targetKind
+	// args are the slash separated arguments of the target. The
+	// interpretation depends on kind.
+	args []string
+}
+
+func parseTarget(str string) (*target, error) {
+	parts := strings.SplitN(str, "/", 2)
+	kind := targetKind(parts[0])
+
+	var rest string
+	if len(parts) == 2 {
+		rest = parts[1]
+	}
+
+	switch kind {
+	case kprobeTarget, kretprobeTarget, rawTracepointTarget:
+		if rest == "" || strings.Contains(rest, "/") {
+			return nil, xerrors.Errorf("target %q: expected %s/<name>", str, kind)
+		}
+		return &target{kind, []string{rest}}, nil
+
+	case uprobeTarget, uretprobeTarget:
+		// The path of the executable may contain slashes, the symbol
+		// follows the last colon.
+		i := strings.LastIndexByte(rest, ':')
+		if i <= 0 || i == len(rest)-1 {
+			return nil, xerrors.Errorf("target %q: expected %s/<path>:<symbol>", str, kind)
+		}
+		return &target{kind, []string{rest[:i], rest[i+1:]}}, nil
+
+	case tracepointTarget:
+		args := strings.Split(rest, "/")
+		if len(args) != 2 || args[0] == "" || args[1] == "" {
+			return nil, xerrors.Errorf("target %q: expected %s/<group>/<name>", str, kind)
+		}
+		return &target{kind, args}, nil
+
+	case tracingTarget:
+		if rest != "" {
+			return nil, xerrors.Errorf("target %q: tracing programs take their target from ProgramSpec.AttachTo", str)
+		}
+		return &target{kind, nil}, nil
+
+	case cgroupTarget:
+		if rest == "" {
+			return nil, xerrors.Errorf("target %q: expected %s/<path>", str, kind)
+		}
+		// Cgroup paths are absolute, accept them with and without
+		// the leading slash.
+		return &target{kind, []string{filepath.Clean("/" + rest)}}, nil
+
+	default:
+		return nil, xerrors.Errorf("target %q: unknown kind %q", str, kind)
+	}
+}
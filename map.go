@@ -1,12 +1,17 @@
 package ebpf
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash"
 	"strings"
+	"sync"
+	"unsafe"
 
 	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/btf"
 	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/sys"
 
 	"golang.org/x/xerrors"
 )
@@ -14,7 +19,9 @@ import (
 // Errors returned by Map and MapIterator methods.
 var (
 	ErrKeyNotExist      = xerrors.New("key does not exist")
+	ErrKeyExist         = xerrors.New("key already exists")
 	ErrIterationAborted = xerrors.New("iteration aborted")
+	ErrMapIncompatible  = xerrors.New("map's spec is incompatible with existing map")
 )
 
 // MapID represents the unique ID of an eBPF map
@@ -42,6 +49,11 @@ type MapSpec struct {
 
 	// The BTF associated with this map.
 	BTF *btf.Map
+
+	// Ifindex offloads the map to the network device with this index.
+	// Offloaded maps can only be used by programs offloaded to the
+	// same device.
+	Ifindex uint32
 }
 
 func (ms *MapSpec) String() string {
@@ -63,6 +75,91 @@ func (ms *MapSpec) Copy() *MapSpec {
 	return &cpy
 }
 
+// digest writes the attributes and contents of the spec to h.
+func (ms *MapSpec) digest(h hash.Hash) error {
+	attrs := []uint32{uint32(ms.Type), ms.KeySize, ms.ValueSize, ms.MaxEntries, ms.Flags, 0}
+	if ms.Freeze {
+		attrs[5] = 1
+	}
+	if err := binary.Write(h, binary.LittleEndian, attrs); err != nil {
+		return err
+	}
+
+	for _, kv := range ms.Contents {
+		key, err := marshalBytes(kv.Key, int(ms.KeySize))
+		if err != nil {
+			return xerrors.Errorf("key %v: %w", kv.Key, err)
+		}
+
+		// Program references are resolved when the map is loaded.
+		value := []byte("program\x00")
+		if name, _ := programReference(kv.Value); name != "" {
+			value = append(value, name...)
+		} else if value, err = marshalBytes(kv.Value, int(ms.ValueSize)); err != nil {
+			return xerrors.Errorf("value of key %v: %w", kv.Key, err)
+		}
+
+		if err := binary.Write(h, binary.LittleEndian, uint32(len(value))); err != nil {
+			return err
+		}
+		if _, err := h.Write(key); err != nil {
+			return err
+		}
+		if _, err := h.Write(value); err != nil {
+			return err
+		}
+	}
+
+	if ms.InnerMap == nil {
+		_, err := h.Write([]byte{0})
+		return err
+	}
+
+	if _, err := h.Write([]byte{1}); err != nil {
+		return err
+	}
+	return ms.InnerMap.digest(h)
+}
+
+// checkCompatible returns an error wrapping ErrMapIncompatible if
+// an existing map can't be used in place of a map created from the spec.
+func (ms *MapSpec) checkCompatible(m *Map) error {
+	abi := ms.expectedABI(&m.abi)
+	if !abi.Equal(&m.abi) {
+		return xerrors.Errorf("expected %s, got %s: %w", ms, m, ErrMapIncompatible)
+	}
+
+	return nil
+}
+
+// expectedABI returns the ABI an existing map must have to be used in
+// place of a map created from the spec.
+func (ms *MapSpec) expectedABI(existing *MapABI) *MapABI {
+	abi := newMapABIFromSpec(ms)
+
+	switch ms.Type {
+	case PerfEventArray:
+		if abi.KeySize == 0 {
+			abi.KeySize = 4
+		}
+		if abi.ValueSize == 0 {
+			abi.ValueSize = 4
+		}
+		if abi.MaxEntries == 0 {
+			// The number of entries depends on the machine the map
+			// was created on, accept any.
+			abi.MaxEntries = existing.MaxEntries
+		}
+
+	case ArrayOfMaps, HashOfMaps:
+		if abi.ValueSize == 0 {
+			abi.ValueSize = 4
+		}
+	}
+
+	return abi
+}
+
 // MapKV is used to initialize the contents of a Map.
 type MapKV struct {
 	Key   interface{}
@@ -71,7 +168,9 @@ type MapKV struct {
 
 // Map represents a Map file descriptor.
 //
-// It is not safe to close a map which is used by other goroutines.
+// Methods are safe for concurrent use. Closing a map waits for pending
+// lookups and updates, which return an error once the map is closed.
+// The raw fd returned by FD is not protected in this way.
 //
 // Methods which take interface{} arguments by default encode
 // them using binary.Read/Write in the machine's native endianness.
@@ -84,6 +183,43 @@ type Map struct {
 	abi  MapABI
 	// Per CPU maps return values larger than the size in the spec
 	fullValueSize int
+	// Parts of the value managed by the kernel, only known for maps
+	// created from a spec with BTF.
+	kernelFields []btf.KernelField
+	// The BTF of key and value, only known for maps created from a
+	// spec with BTF, or loaded from the kernel with BTF.
+	types *mapTypes
+}
+
+// mapTypes holds the BTF of key and value of a map. The BTF of maps
+// loaded from the kernel is only fetched when it's first used, since
+// parsing it is expensive.
+type mapTypes struct {
+	once       sync.Once
+	id         uint32
+	key, value btf.TypeID
+	types      *btf.Map
+}
+
+// get returns the BTF, or nil if it isn't known.
+func (mt *mapTypes) get() *btf.Map {
+	if mt == nil {
+		return nil
+	}
+
+	mt.once.Do(func() {
+		if mt.id == 0 {
+			return
+		}
+
+		// BTF is optional, so ignore any errors.
+		spec, err := btf.LoadSpecFromID(mt.id)
+		if err != nil {
+			return
+		}
+		mt.types, _ = btf.MapFromTypeIDs(spec, mt.key, mt.value)
+	})
+	return mt.types
 }
 
 // NewMapFromFD creates a map from a raw fd.
@@ -100,7 +236,26 @@ func NewMapFromFD(fd int) (*Map, error) {
 		bpfFd.Forget()
 		return nil, err
 	}
-	return newMap(bpfFd, name, abi)
+	return newMapWithKernelBTF(bpfFd, name, abi)
+}
+
+// NewMapFromBorrowedFD creates a map from a raw fd which remains owned by
+// the caller.
+//
+// The fd is duplicated, so the caller may close it once this function
+// returns.
+func NewMapFromBorrowedFD(fd int) (*Map, error) {
+	bpfFd, err := internal.NewFDFromBorrowed(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	name, abi, err := newMapABIFromFd(bpfFd)
+	if err != nil {
+		bpfFd.Close()
+		return nil, err
+	}
+	return newMapWithKernelBTF(bpfFd, name, abi)
 }
 
 // NewMap creates a new Map.
@@ -108,39 +263,65 @@ func NewMapFromFD(fd int) (*Map, error) {
 // Creating a map for the first time will perform feature detection
 // by creating small, temporary maps.
 func NewMap(spec *MapSpec) (*Map, error) {
+	if spec.BTF == nil && spec.Type.isLocalStorage() {
+		// The kernel requires BTF for local storage, but doesn't care
+		// about the layout of the value.
+		localStorage, err := btf.LocalStorageMap(spec.ValueSize)
+		if err != nil {
+			return nil, xerrors.Errorf("can't create BTF for %s: %w", spec.Type, err)
+		}
+
+		spec = spec.Copy()
+		spec.BTF = localStorage
+	}
+
 	if spec.BTF == nil {
-		return newMapWithBTF(spec, nil)
+		return newMapWithBTF(spec, nil, false)
 	}
 
+	// Maps which don't depend on BTF are created without it if the
+	// kernel rejects it, see createMap.
 	handle, err := btf.NewHandle(btf.MapSpec(spec.BTF))
-	if err != nil && !xerrors.Is(err, btf.ErrNotSupported) {
+	if err != nil && spec.Type.isLocalStorage() {
 		return nil, xerrors.Errorf("can't load BTF: %w", err)
 	}
 
-	return newMapWithBTF(spec, handle)
+	return newMapWithBTF(spec, handle, false)
 }
 
-func newMapWithBTF(spec *MapSpec, handle *btf.Handle) (*Map, error) {
+func newMapWithBTF(spec *MapSpec, handle *btf.Handle, requireBTF bool) (*Map, error) {
 	if spec.Type != ArrayOfMaps && spec.Type != HashOfMaps {
-		return createMap(spec, nil, handle)
+		return createMap(spec, nil, handle, requireBTF)
 	}
 
 	if spec.InnerMap == nil {
 		return nil, xerrors.Errorf("%s requires InnerMap", spec.Type)
 	}
 
-	template, err := createMap(spec.InnerMap, nil, handle)
+	template, err := createMap(spec.InnerMap, nil, handle, requireBTF)
 	if err != nil {
 		return nil, err
 	}
 	defer template.Close()
 
-	return createMap(spec, template.fd, handle)
+	return createMap(spec, template.fd, handle, requireBTF)
 }
 
-func createMap(spec *MapSpec, inner *internal.FD, handle *btf.Handle) (*Map, error) {
+// createMap creates a map, using BTF if handle is not nil.
+//
+// The map is created without BTF if the kernel rejects it, unless
+// requireBTF is set or the map can't work without BTF.
+func createMap(spec *MapSpec, inner *internal.FD, handle *btf.Handle, requireBTF bool) (*Map, error) {
 	abi := newMapABIFromSpec(spec)
 
+	if abi.Flags&unix.BPF_F_NO_COMMON_LRU != 0 && !spec.Type.isLRU() {
+		return nil, xerrors.Errorf("MapFlagNoCommonLRU requires an LRU map, not %s", spec.Type)
+	}
+
+	if abi.Flags&unix.BPF_F_NO_PREALLOC != 0 && spec.Type.isLRU() {
+		return nil, xerrors.Errorf("%s can't use MapFlagNoPrealloc", spec.Type)
+	}
+
 	switch spec.Type {
 	case ArrayOfMaps:
 		fallthrough
@@ -172,6 +353,27 @@ func createMap(spec *MapSpec, inner *internal.FD, handle *btf.Handle) (*Map, err
 			}
 			abi.MaxEntries = uint32(n)
 		}
+
+	case SkStorage, InodeStorage, TaskStorage, CgroupStorage:
+		if err := haveLocalStorage[spec.Type](); err != nil {
+			return nil, err
+		}
+
+		if abi.KeySize != 0 && abi.KeySize != 4 {
+			return nil, xerrors.Errorf("KeySize must be zero or four for %s", spec.Type)
+		}
+		abi.KeySize = 4
+
+		if abi.MaxEntries != 0 {
+			return nil, xerrors.Errorf("MaxEntries must be zero for %s", spec.Type)
+		}
+
+		// Storage is allocated when it's first used.
+		abi.Flags |= unix.BPF_F_NO_PREALLOC
+
+		if handle == nil {
+			return nil, xerrors.Errorf("%s requires BTF: %w", spec.Type, ErrNotSupported)
+		}
 	}
 
 	if abi.Flags&(unix.BPF_F_RDONLY_PROG|unix.BPF_F_WRONLY_PROG) > 0 || spec.Freeze {
@@ -180,34 +382,64 @@ func createMap(spec *MapSpec, inner *internal.FD, handle *btf.Handle) (*Map, err
 		}
 	}
 
-	attr := bpfMapCreateAttr{
-		mapType:    abi.Type,
-		keySize:    abi.KeySize,
-		valueSize:  abi.ValueSize,
-		maxEntries: abi.MaxEntries,
-		flags:      abi.Flags,
+	attr := sys.MapCreateAttr{
+		MapType:    sys.MapType(abi.Type),
+		KeySize:    abi.KeySize,
+		ValueSize:  abi.ValueSize,
+		MaxEntries: abi.MaxEntries,
+		MapFlags:   abi.Flags,
+		MapIfindex: spec.Ifindex,
 	}
 
 	if inner != nil {
 		var err error
-		attr.innerMapFd, err = inner.Value()
+		attr.InnerMapFD, err = inner.Value()
 		if err != nil {
 			return nil, xerrors.Errorf("map create: %w", err)
 		}
 	}
 
+	var kernelFields []btf.KernelField
+	if spec.BTF != nil {
+		var err error
+		kernelFields, err = btf.KernelFields(btf.MapValue(spec.BTF))
+		if err != nil {
+			return nil, xerrors.Errorf("map create: value: %w", err)
+		}
+	}
+
+	for _, field := range kernelFields {
+		if field.Offset+field.Size > abi.ValueSize {
+			return nil, xerrors.Errorf("map create: %s at offset %d exceeds value size", field.Kind, field.Offset)
+		}
+	}
+
+	if len(kernelFields) > 0 && handle == nil {
+		return nil, xerrors.Errorf("map create: %s in value requires BTF: %w", kernelFields[0].Kind, ErrNotSupported)
+	}
+
 	if handle != nil && spec.BTF != nil {
-		attr.btfFd = uint32(handle.FD())
-		attr.btfKeyTypeID = btf.MapKey(spec.BTF).ID()
-		attr.btfValueTypeID = btf.MapValue(spec.BTF).ID()
+		attr.BTFFD = uint32(handle.FD())
+		attr.BTFKeyTypeID = uint32(btf.MapKey(spec.BTF).ID())
+		attr.BTFValueTypeID = uint32(btf.MapValue(spec.BTF).ID())
 	}
 
 	if haveObjName() == nil {
-		attr.mapName = newBPFObjName(spec.Name)
+		attr.MapName = newBPFObjName(spec.Name)
 	}
 
-	fd, err := bpfMapCreate(&attr)
+	fd, err := sys.MapCreate(&attr)
+	if err != nil && isBTFRejection(err) && attr.BTFFD != 0 && !requireBTF && len(kernelFields) == 0 && !spec.Type.isLocalStorage() {
+		withoutBTF := attr
+		withoutBTF.BTFFD, withoutBTF.BTFKeyTypeID, withoutBTF.BTFValueTypeID = 0, 0, 0
+		if fdWithoutBTF, errWithoutBTF := sys.MapCreate(&withoutBTF); errWithoutBTF == nil {
+			internal.Debug("Kernel rejected BTF, created map without it", "map", spec.Name, "error", err)
+			fd, err = fdWithoutBTF, nil
+		}
+	}
 	if err != nil {
+		required, unprivileged := spec.Type.capabilities()
+		err = wrapPermissionError(fmt.Sprintf("creating a map of type %s", spec.Type), required, unprivileged, err)
 		return nil, xerrors.Errorf("map create: %w", err)
 	}
 
@@ -215,6 +447,10 @@ func createMap(spec *MapSpec, inner *internal.FD, handle *btf.Handle) (*Map, err
 	if err != nil {
 		return nil, err
 	}
+	m.kernelFields = kernelFields
+	if spec.BTF != nil {
+		m.types = &mapTypes{types: spec.BTF}
+	}
 
 	if err := m.populate(spec.Contents); err != nil {
 		m.Close()
@@ -233,10 +469,10 @@ func createMap(spec *MapSpec, inner *internal.FD, handle *btf.Handle) (*Map, err
 
 func newMap(fd *internal.FD, name string, abi *MapABI) (*Map, error) {
 	m := &Map{
-		name,
-		fd,
-		*abi,
-		int(abi.ValueSize),
+		name:          name,
+		fd:            fd,
+		abi:           *abi,
+		fullValueSize: int(abi.ValueSize),
 	}
 
 	if !abi.Type.hasPerCPUValue() {
@@ -252,6 +488,28 @@ func newMap(fd *internal.FD, name string, abi *MapABI) (*Map, error) {
 	return m, nil
 }
 
+// newMapWithKernelBTF creates a map from an existing fd, and retrieves
+// the BTF of key and value from the kernel on first use if possible.
+func newMapWithKernelBTF(fd *internal.FD, name string, abi *MapABI) (*Map, error) {
+	m, err := newMap(fd, name, abi)
+	if err != nil {
+		return nil, err
+	}
+
+	// BTF is optional, so ignore any errors.
+	info, err := bpfGetMapInfoByFD(fd)
+	if err != nil || info.btfID == 0 || info.btfKeyID == 0 || info.btfValueID == 0 {
+		return m, nil
+	}
+
+	m.types = &mapTypes{
+		id:    info.btfID,
+		key:   btf.TypeID(info.btfKeyID),
+		value: btf.TypeID(info.btfValueID),
+	}
+	return m, nil
+}
+
 func (m *Map) String() string {
 	if m.name != "" {
 		return fmt.Sprintf("%s(%s)#%v", m.abi.Type, m.name, m.fd)
@@ -271,14 +529,22 @@ func (m *Map) ABI() MapABI {
 //
 // Returns an error if the key doesn't exist, see IsNotExist.
 func (m *Map) Lookup(key, valueOut interface{}) error {
-	valuePtr, valueBytes := makeBuffer(valueOut, m.fullValueSize)
+	if ptr, ok := valueOut.(unsafe.Pointer); ok {
+		return m.lookup(key, internal.NewPointer(ptr))
+	}
 
-	if err := m.lookup(key, valuePtr); err != nil {
-		return err
+	var valueBytes []byte
+	if m.abi.Type.hasPerCPUValue() || !retainsBuffer(valueOut) {
+		// The buffer is only needed until the value is unmarshaled.
+		buf := getBuffer(m.fullValueSize)
+		defer putBuffer(buf)
+		valueBytes = *buf
+	} else {
+		valueBytes = make([]byte, m.fullValueSize)
 	}
 
-	if valueBytes == nil {
-		return nil
+	if err := m.lookup(key, internal.NewSlicePointer(valueBytes)); err != nil {
+		return err
 	}
 
 	if m.abi.Type.hasPerCPUValue() {
@@ -352,6 +618,39 @@ func (m *Map) LookupBytes(key interface{}) ([]byte, error) {
 	return valueBytes, err
 }
 
+// ValueBufferSize returns the number of bytes needed to hold a value of
+// the map.
+//
+// For per-CPU maps this is the value size rounded up to a multiple of
+// eight, times the number of possible CPUs.
+func (m *Map) ValueBufferSize() int {
+	return m.fullValueSize
+}
+
+// LookupBytesInto copies the value of key into valueOut, which must be
+// ValueBufferSize bytes long.
+//
+// Returns false if the key doesn't exist. Unlike LookupBytes it doesn't
+// allocate if the key exists, which makes it suitable for polling large
+// maps, especially per-CPU maps.
+func (m *Map) LookupBytesInto(key, valueOut []byte) (bool, error) {
+	if len(key) != int(m.abi.KeySize) {
+		return false, xerrors.Errorf("key has %d bytes instead of %d", len(key), m.abi.KeySize)
+	}
+	if len(valueOut) != m.fullValueSize {
+		return false, xerrors.Errorf("value buffer has %d bytes instead of %d", len(valueOut), m.fullValueSize)
+	}
+
+	err := bpfMapLookupElem(m.fd, internal.NewSlicePointer(key), internal.NewSlicePointer(valueOut))
+	if xerrors.Is(err, ErrKeyNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, xerrors.Errorf("lookup failed: %w", err)
+	}
+	return true, nil
+}
+
 func (m *Map) lookup(key interface{}, valueOut internal.Pointer) error {
 	keyPtr, err := marshalPtr(key, int(m.abi.KeySize))
 	if err != nil {
@@ -386,6 +685,13 @@ func (m *Map) Put(key, value interface{}) error {
 }
 
 // Update changes the value of a key.
+//
+// Returns ErrKeyExist if UpdateNoExist is given and the key exists, and
+// ErrKeyNotExist if UpdateExist is given and the key doesn't exist.
+//
+// Returns an error if the value would overwrite fields managed by the
+// kernel, like a bpf_timer, with non-zero bytes. This is only checked
+// for maps created from a MapSpec with BTF.
 func (m *Map) Update(key, value interface{}, flags MapUpdateFlags) error {
 	keyPtr, err := marshalPtr(key, int(m.abi.KeySize))
 	if err != nil {
@@ -395,6 +701,8 @@ func (m *Map) Update(key, value interface{}, flags MapUpdateFlags) error {
 	var valuePtr internal.Pointer
 	if m.abi.Type.hasPerCPUValue() {
 		valuePtr, err = marshalPerCPUValue(value, int(m.abi.ValueSize))
+	} else if _, ok := value.(unsafe.Pointer); len(m.kernelFields) > 0 && !ok {
+		valuePtr, err = m.marshalValueWithKernelFields(value)
 	} else {
 		valuePtr, err = marshalPtr(value, int(m.abi.ValueSize))
 	}
@@ -409,6 +717,23 @@ func (m *Map) Update(key, value interface{}, flags MapUpdateFlags) error {
 	return nil
 }
 
+func (m *Map) marshalValueWithKernelFields(value interface{}) (internal.Pointer, error) {
+	buf, err := marshalBytes(value, int(m.abi.ValueSize))
+	if err != nil {
+		return internal.Pointer{}, err
+	}
+
+	for _, field := range m.kernelFields {
+		for _, b := range buf[field.Offset : field.Offset+field.Size] {
+			if b != 0 {
+				return internal.Pointer{}, xerrors.Errorf("%s at offset %d is managed by the kernel and must be zero", field.Kind, field.Offset)
+			}
+		}
+	}
+
+	return internal.NewSlicePointer(buf), nil
+}
+
 // Delete removes a value.
 //
 // Returns ErrKeyNotExist if the key does not exist.
@@ -465,6 +790,34 @@ func (m *Map) NextKeyBytes(key interface{}) ([]byte, error) {
 	return nextKey, err
 }
 
+// NextKeyBytesInto copies the key after key into nextKeyOut. Pass a nil
+// key to retrieve the first key.
+//
+// Returns false if there are no more keys. Like LookupBytesInto it
+// doesn't allocate, except when the end of the map is reached.
+func (m *Map) NextKeyBytesInto(key, nextKeyOut []byte) (bool, error) {
+	if key != nil && len(key) != int(m.abi.KeySize) {
+		return false, xerrors.Errorf("key has %d bytes instead of %d", len(key), m.abi.KeySize)
+	}
+	if len(nextKeyOut) != int(m.abi.KeySize) {
+		return false, xerrors.Errorf("next key buffer has %d bytes instead of %d", len(nextKeyOut), m.abi.KeySize)
+	}
+
+	var keyPtr internal.Pointer
+	if key != nil {
+		keyPtr = internal.NewSlicePointer(key)
+	}
+
+	err := bpfMapGetNextKey(m.fd, keyPtr, internal.NewSlicePointer(nextKeyOut))
+	if xerrors.Is(err, ErrKeyNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, xerrors.Errorf("next key failed: %w", err)
+	}
+	return true, nil
+}
+
 func (m *Map) nextKey(key interface{}, nextKeyOut internal.Pointer) error {
 	var (
 		keyPtr internal.Pointer
@@ -495,6 +848,8 @@ func (m *Map) Iterate() *MapIterator {
 }
 
 // Close removes a Map
+//
+// It is safe to call Close multiple times.
 func (m *Map) Close() error {
 	if m == nil {
 		// This makes it easier to clean up when iterating maps
@@ -535,14 +890,35 @@ func (m *Map) Clone() (*Map, error) {
 		return nil, xerrors.Errorf("can't clone map: %w", err)
 	}
 
-	return newMap(dup, m.name, &m.abi)
+	cpy, err := newMap(dup, m.name, &m.abi)
+	if err != nil {
+		return nil, err
+	}
+	cpy.kernelFields = m.kernelFields
+	cpy.types = m.types
+	return cpy, nil
+}
+
+// Spec returns a MapSpec describing the map.
+//
+// The spec only contains the attributes available via MapABI and the
+// name of the map. Contents, InnerMap and BTF are not available.
+func (m *Map) Spec() *MapSpec {
+	return &MapSpec{
+		Name:       m.name,
+		Type:       m.abi.Type,
+		KeySize:    m.abi.KeySize,
+		ValueSize:  m.abi.ValueSize,
+		MaxEntries: m.abi.MaxEntries,
+		Flags:      m.abi.Flags,
+	}
 }
 
 // Pin persists the map past the lifetime of the process that created it.
 //
 // This requires bpffs to be mounted above fileName. See http://cilium.readthedocs.io/en/doc-1.0/kubernetes/install/#mounting-the-bpf-fs-optional
 func (m *Map) Pin(fileName string) error {
-	return bpfPinObject(fileName, m.fd)
+	return internal.BPFObjPin(fileName, m.fd)
 }
 
 // Freeze prevents a map to be modified from user space.
@@ -573,7 +949,7 @@ func (m *Map) populate(contents []MapKV) error {
 // The function is not compatible with nested maps.
 // Use LoadPinnedMapExplicit in these situations.
 func LoadPinnedMap(fileName string) (*Map, error) {
-	fd, err := bpfGetObject(fileName)
+	fd, err := internal.BPFObjGet(fileName)
 	if err != nil {
 		return nil, err
 	}
@@ -582,12 +958,12 @@ func LoadPinnedMap(fileName string) (*Map, error) {
 		_ = fd.Close()
 		return nil, err
 	}
-	return newMap(fd, name, abi)
+	return newMapWithKernelBTF(fd, name, abi)
 }
 
 // LoadPinnedMapExplicit loads a map with explicit parameters.
 func LoadPinnedMapExplicit(fileName string, abi *MapABI) (*Map, error) {
-	fd, err := bpfGetObject(fileName)
+	fd, err := internal.BPFObjGet(fileName)
 	if err != nil {
 		return nil, err
 	}
@@ -762,7 +1138,7 @@ func (mi *MapIterator) Err() error {
 //
 // Returns ErrNotExist, if there is no next eBPF map.
 func MapGetNextID(startID MapID) (MapID, error) {
-	id, err := objGetNextID(_MapGetNextID, uint32(startID))
+	id, err := objGetNextID(sys.BPF_MAP_GET_NEXT_ID, uint32(startID))
 	return MapID(id), err
 }
 
@@ -770,7 +1146,7 @@ func MapGetNextID(startID MapID) (MapID, error) {
 //
 // Returns ErrNotExist, if there is no eBPF map with the given id.
 func NewMapFromID(id MapID) (*Map, error) {
-	fd, err := bpfObjGetFDByID(_MapGetFDByID, uint32(id))
+	fd, err := bpfObjGetFDByID(sys.BPF_MAP_GET_FD_BY_ID, uint32(id))
 	if err != nil {
 		return nil, err
 	}
@@ -781,7 +1157,7 @@ func NewMapFromID(id MapID) (*Map, error) {
 		return nil, err
 	}
 
-	return newMap(fd, name, abi)
+	return newMapWithKernelBTF(fd, name, abi)
 }
 
 // ID returns the systemwide unique ID of the map.
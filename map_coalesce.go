@@ -0,0 +1,224 @@
+package ebpf
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// LookupCoalescerOptions control a LookupCoalescer.
+type LookupCoalescerOptions struct {
+	// Window is the time lookups are collected for before they are
+	// executed. A zero Window executes lookups immediately, and only
+	// coalesces lookups which arrive while others are in progress.
+	Window time.Duration
+
+	// BatchThreshold is the number of distinct pending keys at which
+	// the whole map is read using batch lookups instead of issuing a
+	// syscall per key. Zero disables batch lookups.
+	//
+	// Batch lookups require at least Linux 5.6 and aren't supported by
+	// all map types, the coalescer falls back to single lookups if the
+	// kernel rejects them.
+	BatchThreshold int
+}
+
+// LookupCoalescer reduces the number of syscalls needed to look up keys
+// from many goroutines concurrently.
+//
+// Concurrent lookups of the same key share a single syscall. If many
+// distinct keys are pending, all of them are served by reading the
+// map in batches.
+//
+// Values may be slightly older than with Map.Lookup, since they are
+// read once for all waiting callers. A caller executes at most one batch
+// of lookups, and hands the next one over to a caller waiting for it.
+type LookupCoalescer struct {
+	m    *Map
+	opts LookupCoalescerOptions
+
+	mu      sync.Mutex
+	pending *lookupBatch
+	running bool
+	// noBatch is set if the map doesn't support batch lookups.
+	noBatch bool
+}
+
+type lookupBatch struct {
+	keys map[string]*lookupResult
+	// lead receives a value if a caller waiting for the batch has to
+	// execute it.
+	lead chan struct{}
+	done chan struct{}
+}
+
+type lookupResult struct {
+	value []byte
+	err   error
+}
+
+// NewLookupCoalescer creates a coalescer for m.
+//
+// The coalescer doesn't own m, which must stay open while the
+// coalescer is used.
+func NewLookupCoalescer(m *Map, opts LookupCoalescerOptions) *LookupCoalescer {
+	return &LookupCoalescer{m: m, opts: opts}
+}
+
+// LookupBytes gets a value from the map.
+//
+// Returns a nil value if a key doesn't exist, like Map.LookupBytes.
+// It is safe to call LookupBytes from multiple goroutines.
+func (lc *LookupCoalescer) LookupBytes(key interface{}) ([]byte, error) {
+	keyBytes, err := marshalBytes(key, int(lc.m.abi.KeySize))
+	if err != nil {
+		return nil, xerrors.Errorf("can't marshal key: %w", err)
+	}
+
+	lc.mu.Lock()
+	if lc.pending == nil {
+		lc.pending = &lookupBatch{
+			keys: make(map[string]*lookupResult),
+			lead: make(chan struct{}, 1),
+			done: make(chan struct{}),
+		}
+	}
+
+	batch := lc.pending
+	result := batch.keys[string(keyBytes)]
+	if result == nil {
+		result = new(lookupResult)
+		batch.keys[string(keyBytes)] = result
+	}
+
+	if lc.running {
+		// Another goroutine executes pending lookups.
+		lc.mu.Unlock()
+		select {
+		case <-batch.done:
+		case <-batch.lead:
+			lc.flush(batch)
+		}
+		return copyResult(result)
+	}
+
+	lc.running = true
+	lc.mu.Unlock()
+
+	if lc.opts.Window > 0 {
+		time.Sleep(lc.opts.Window)
+	}
+
+	lc.flush(batch)
+	return copyResult(result)
+}
+
+// flush executes the pending batch, and hands the batch which is pending
+// afterwards over to one of its callers.
+func (lc *LookupCoalescer) flush(batch *lookupBatch) {
+	lc.mu.Lock()
+	lc.pending = nil
+	lc.mu.Unlock()
+
+	lc.execute(batch)
+
+	lc.mu.Lock()
+	if lc.pending != nil {
+		lc.pending.lead <- struct{}{}
+	} else {
+		lc.running = false
+	}
+	lc.mu.Unlock()
+
+	close(batch.done)
+}
+
+func copyResult(result *lookupResult) ([]byte, error) {
+	if result.err != nil || result.value == nil {
+		return nil, result.err
+	}
+
+	value := make([]byte, len(result.value))
+	copy(value, result.value)
+	return value, nil
+}
+
+func (lc *LookupCoalescer) execute(batch *lookupBatch) {
+	if lc.opts.BatchThreshold > 0 && len(batch.keys) >= lc.opts.BatchThreshold && !lc.noBatch {
+		err := lc.lookupAll(batch)
+		if err == nil {
+			return
+		}
+
+		// Kernels before 5.6 don't know the command and return EINVAL.
+		if !xerrors.Is(err, ErrNotSupported) && !xerrors.Is(err, unix.EINVAL) {
+			for _, result := range batch.keys {
+				result.err = err
+			}
+			return
+		}
+
+		lc.noBatch = true
+	}
+
+	for key, result := range batch.keys {
+		result.value, result.err = lc.m.LookupBytes([]byte(key))
+	}
+}
+
+// lookupAll reads the whole map in batches and assigns values to
+// pending keys.
+func (lc *LookupCoalescer) lookupAll(batch *lookupBatch) error {
+	const chunkSize = 256
+
+	keySize := int(lc.m.abi.KeySize)
+	valueSize := lc.m.fullValueSize
+
+	// The cursor is opaque, hash maps use a 32 bit bucket index.
+	cursorSize := keySize
+	if cursorSize < 4 {
+		cursorSize = 4
+	}
+
+	var (
+		keys     = make([]byte, chunkSize*keySize)
+		values   = make([]byte, chunkSize*valueSize)
+		inBatch  = make([]byte, cursorSize)
+		outBatch = make([]byte, cursorSize)
+		inPtr    internal.Pointer
+		found    int
+	)
+
+	for {
+		n, err := bpfMapLookupBatch(lc.m.fd, inPtr, internal.NewSlicePointer(outBatch),
+			internal.NewSlicePointer(keys), internal.NewSlicePointer(values), chunkSize)
+		done := xerrors.Is(err, ErrKeyNotExist)
+		if err != nil && !done {
+			return xerrors.Errorf("batch lookup: %w", err)
+		}
+
+		for i := 0; i < int(n); i++ {
+			result := batch.keys[string(keys[i*keySize:(i+1)*keySize])]
+			if result == nil {
+				continue
+			}
+
+			value := make([]byte, valueSize)
+			copy(value, values[i*valueSize:(i+1)*valueSize])
+			result.value = value
+			found++
+		}
+
+		if done || found == len(batch.keys) {
+			// Keys which weren't found don't exist.
+			return nil
+		}
+
+		copy(inBatch, outBatch)
+		inPtr = internal.NewSlicePointer(inBatch)
+	}
+}
@@ -0,0 +1,83 @@
+package ebpf
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestLookupCoalescer(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for i := uint32(0); i < 500; i++ {
+		if err := m.Put(i, i*2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, threshold := range []int{0, 1} {
+		lc := NewLookupCoalescer(m, LookupCoalescerOptions{BatchThreshold: threshold})
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 1000)
+		for i := uint32(0); i < 1000; i++ {
+			wg.Add(1)
+			go func(key uint32) {
+				defer wg.Done()
+
+				value, err := lc.LookupBytes(key)
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				switch {
+				case key >= 500 && value != nil:
+					t.Errorf("Key %d shouldn't exist", key)
+				case key < 500 && value == nil:
+					t.Errorf("Key %d is missing", key)
+				case key < 500 && internal.NativeEndian.Uint32(value) != key*2:
+					t.Errorf("Key %d has wrong value %d", key, internal.NativeEndian.Uint32(value))
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			testutils.SkipIfNotSupported(t, err)
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMapConcurrentClose(t *testing.T) {
+	m := createArray(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if err := m.Put(uint32(0), uint32(1)); err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	m.Close()
+	wg.Wait()
+}
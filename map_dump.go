@@ -0,0 +1,203 @@
+package ebpf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/cilium/ebpf/internal/btf"
+
+	"golang.org/x/xerrors"
+)
+
+// mapDumpEntry is a single entry in the output of Map.Dump, using the
+// same layout as "bpftool map dump -j".
+type mapDumpEntry struct {
+	Key       hexBytes          `json:"key"`
+	Value     hexBytes          `json:"value,omitempty"`
+	Values    []perCPUHexBytes  `json:"values,omitempty"`
+	Formatted *mapDumpFormatted `json:"formatted,omitempty"`
+}
+
+type perCPUHexBytes struct {
+	CPU   int      `json:"cpu"`
+	Value hexBytes `json:"value"`
+}
+
+type mapDumpFormatted struct {
+	Key    json.RawMessage `json:"key"`
+	Value  json.RawMessage `json:"value,omitempty"`
+	Values []perCPUJSON    `json:"values,omitempty"`
+}
+
+type perCPUJSON struct {
+	CPU   int             `json:"cpu"`
+	Value json.RawMessage `json:"value"`
+}
+
+// hexBytes encodes a byte slice as an array of hex strings.
+type hexBytes []byte
+
+func (hb hexBytes) MarshalJSON() ([]byte, error) {
+	strs := make([]string, len(hb))
+	for i, b := range hb {
+		strs[i] = fmt.Sprintf("0x%02x", b)
+	}
+	return json.Marshal(strs)
+}
+
+func (hb *hexBytes) UnmarshalJSON(data []byte) error {
+	var strs []string
+	if err := json.Unmarshal(data, &strs); err != nil {
+		return err
+	}
+
+	buf := make([]byte, len(strs))
+	for i, str := range strs {
+		b, err := strconv.ParseUint(str, 0, 8)
+		if err != nil {
+			return xerrors.Errorf("byte %d: %w", i, err)
+		}
+		buf[i] = byte(b)
+	}
+
+	*hb = buf
+	return nil
+}
+
+// Dump writes all entries of the map to w as JSON, in the same format as
+// "bpftool map dump -j".
+//
+// Each entry contains the raw bytes of key and value. Entries of maps
+// with BTF additionally contain a formatted representation of key and
+// value.
+//
+// Maps which contain file descriptors, like ProgramArray, are dumped
+// with the IDs the kernel returns instead.
+func (m *Map) Dump(w io.Writer) error {
+	entries := []mapDumpEntry{}
+	perCPU := m.abi.Type.hasPerCPUValue()
+	stride := align(int(m.abi.ValueSize), 8)
+
+	var prevKey interface{}
+	for i := uint32(0); ; i++ {
+		if i > m.abi.MaxEntries {
+			return xerrors.Errorf("dump map: %w", ErrIterationAborted)
+		}
+
+		key, err := m.NextKeyBytes(prevKey)
+		if err != nil {
+			return xerrors.Errorf("dump map: %w", err)
+		}
+		if key == nil {
+			break
+		}
+		prevKey = key
+
+		value, err := m.LookupBytes(key)
+		if err != nil {
+			return xerrors.Errorf("dump map: %w", err)
+		}
+		if value == nil {
+			// The key was deleted concurrently.
+			continue
+		}
+
+		entry := mapDumpEntry{Key: key}
+		if perCPU {
+			for cpu := 0; cpu*stride < len(value); cpu++ {
+				cpuValue := value[cpu*stride : cpu*stride+int(m.abi.ValueSize)]
+				entry.Values = append(entry.Values, perCPUHexBytes{cpu, cpuValue})
+			}
+		} else {
+			entry.Value = value
+		}
+
+		if types := m.types.get(); types != nil {
+			entry.Formatted, err = formatEntry(types, &entry)
+			if err != nil {
+				return xerrors.Errorf("dump map: %w", err)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return json.NewEncoder(w).Encode(entries)
+}
+
+func formatEntry(types *btf.Map, entry *mapDumpEntry) (*mapDumpFormatted, error) {
+	key, err := btf.DumpJSON(btf.MapKey(types), entry.Key)
+	if err != nil {
+		return nil, xerrors.Errorf("key: %w", err)
+	}
+
+	formatted := &mapDumpFormatted{Key: key}
+	if entry.Values == nil {
+		formatted.Value, err = btf.DumpJSON(btf.MapValue(types), entry.Value)
+		if err != nil {
+			return nil, xerrors.Errorf("value: %w", err)
+		}
+		return formatted, nil
+	}
+
+	for _, value := range entry.Values {
+		raw, err := btf.DumpJSON(btf.MapValue(types), value.Value)
+		if err != nil {
+			return nil, xerrors.Errorf("value: %w", err)
+		}
+		formatted.Values = append(formatted.Values, perCPUJSON{value.CPU, raw})
+	}
+	return formatted, nil
+}
+
+// Restore reads entries written by Dump from r and adds them to the map.
+//
+// Existing entries with the same keys are overwritten. Only the raw
+// bytes of keys and values are used, the formatted representation is
+// ignored.
+//
+// Maps which contain file descriptors, like ProgramArray, can't be
+// restored since Dump only records the IDs of the objects they refer to.
+func (m *Map) Restore(r io.Reader) error {
+	if m.abi.Type.hasFileDescriptors() {
+		return xerrors.Errorf("restore map: can't restore %s, which contains file descriptors", m.abi.Type)
+	}
+
+	var entries []mapDumpEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return xerrors.Errorf("restore map: %w", err)
+	}
+
+	for i, entry := range entries {
+		if len(entry.Key) != int(m.abi.KeySize) {
+			return xerrors.Errorf("restore map: entry %d: key has %d bytes instead of %d", i, len(entry.Key), m.abi.KeySize)
+		}
+
+		var value interface{} = []byte(entry.Value)
+		if m.abi.Type.hasPerCPUValue() {
+			values := make([][]byte, len(entry.Values))
+			for _, v := range entry.Values {
+				if v.CPU < 0 || v.CPU >= len(values) {
+					return xerrors.Errorf("restore map: entry %d: invalid CPU %d", i, v.CPU)
+				}
+				values[v.CPU] = v.Value
+			}
+			for cpu := range values {
+				if values[cpu] == nil {
+					values[cpu] = make([]byte, m.abi.ValueSize)
+				}
+			}
+			value = values
+		} else if len(entry.Value) != int(m.abi.ValueSize) {
+			return xerrors.Errorf("restore map: entry %d: value has %d bytes instead of %d", i, len(entry.Value), m.abi.ValueSize)
+		}
+
+		if err := m.Update([]byte(entry.Key), value, UpdateAny); err != nil {
+			return xerrors.Errorf("restore map: entry %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package ebpf
+
+import (
+	"time"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/btf"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// SweeperOptions control a Sweeper.
+type SweeperOptions struct {
+	// Field is the name of the timestamp in the map value, for example
+	// "last_seen". Members of nested structs are separated by dots. The
+	// field is found via BTF and must be an 8 byte integer containing
+	// nanoseconds of CLOCK_MONOTONIC, as returned by bpf_ktime_get_ns().
+	Field string
+
+	// TTL is the age after which entries are deleted.
+	TTL time.Duration
+
+	// BatchSize is the number of keys deleted per syscall. Defaults to
+	// 256. Batch deletes require at least Linux 5.6, keys are deleted
+	// one by one on older kernels.
+	BatchSize int
+}
+
+// Sweeper deletes stale entries from a hash map, based on a timestamp in
+// each value which is updated by a program.
+//
+// Sweeping isn't atomic: an entry which is refreshed by a program while
+// the map is swept may still be deleted.
+type Sweeper struct {
+	m      *Map
+	opts   SweeperOptions
+	offset int
+	// noBatch is set if the map doesn't support batch deletes.
+	noBatch bool
+}
+
+// NewSweeper creates a sweeper for a map of type Hash, PerCPUHash,
+// LRUHash or LRUCPUHash, which must have BTF.
+//
+// The sweeper doesn't own m, which must stay open while the sweeper is
+// used.
+func NewSweeper(m *Map, opts SweeperOptions) (*Sweeper, error) {
+	switch m.abi.Type {
+	case Hash, PerCPUHash, LRUHash, LRUCPUHash:
+	default:
+		return nil, xerrors.Errorf("can't sweep map of type %s", m.abi.Type)
+	}
+
+	if opts.TTL <= 0 {
+		return nil, xerrors.New("TTL must be positive")
+	}
+
+	types := m.types.get()
+	if types == nil {
+		return nil, xerrors.Errorf("map %s doesn't have BTF", m)
+	}
+
+	offset, typ, err := btf.FieldOffset(btf.MapValue(types), opts.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := btf.Sizeof(typ)
+	if err != nil {
+		return nil, xerrors.Errorf("field %s: %w", opts.Field, err)
+	}
+	if size != 8 || int(offset)+size > int(m.abi.ValueSize) {
+		return nil, xerrors.Errorf("field %s isn't a 64-bit timestamp", opts.Field)
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 256
+	}
+
+	return &Sweeper{m: m, opts: opts, offset: int(offset)}, nil
+}
+
+// Sweep deletes all entries with a timestamp older than the TTL, and
+// returns the number of deleted entries.
+//
+// Call it periodically, for example from a time.Ticker.
+func (s *Sweeper) Sweep() (int, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return 0, xerrors.Errorf("read clock: %w", err)
+	}
+
+	now := uint64(ts.Sec)*uint64(time.Second) + uint64(ts.Nsec)
+	ttl := uint64(s.opts.TTL)
+	if now < ttl {
+		// Nothing can be older than the TTL yet.
+		return 0, nil
+	}
+
+	return s.DeleteBefore(now - ttl)
+}
+
+// DeleteBefore deletes all entries with a timestamp before deadline,
+// and returns the number of deleted entries.
+//
+// Per-CPU values are only deleted if the timestamps of all CPUs are
+// before deadline.
+func (s *Sweeper) DeleteBefore(deadline uint64) (int, error) {
+	keys, err := s.expiredKeys(deadline)
+	if err != nil {
+		return 0, xerrors.Errorf("sweep: %w", err)
+	}
+
+	if s.noBatch {
+		return s.deleteSingle(keys)
+	}
+
+	n, rest, err := s.deleteBatch(keys)
+	if err == nil {
+		return n, nil
+	}
+
+	// EINVAL means that the kernel doesn't know the command.
+	if !xerrors.Is(err, ErrNotSupported) && !xerrors.Is(err, unix.EINVAL) {
+		return n, xerrors.Errorf("sweep: %w", err)
+	}
+
+	s.noBatch = true
+	deleted, err := s.deleteSingle(rest)
+	return n + deleted, err
+}
+
+// expiredKeys returns the keys of expired entries, concatenated.
+func (s *Sweeper) expiredKeys(deadline uint64) ([]byte, error) {
+	var (
+		m       = s.m
+		keys    []byte
+		prevKey interface{}
+		stride  = int(m.abi.ValueSize)
+	)
+
+	if m.abi.Type.hasPerCPUValue() {
+		stride = align(stride, 8)
+	}
+
+	for i := uint32(0); ; i++ {
+		if i > m.abi.MaxEntries {
+			return nil, ErrIterationAborted
+		}
+
+		key, err := m.NextKeyBytes(prevKey)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return keys, nil
+		}
+		prevKey = key
+
+		value, err := m.LookupBytes(key)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			// The key was deleted concurrently.
+			continue
+		}
+
+		expired := true
+		for off := s.offset; off+8 <= len(value); off += stride {
+			if internal.NativeEndian.Uint64(value[off:]) >= deadline {
+				expired = false
+				break
+			}
+		}
+
+		if expired {
+			keys = append(keys, key...)
+		}
+	}
+}
+
+// deleteBatch deletes keys in batches. It returns the number of deleted
+// keys, and the keys which weren't processed if an error occurs.
+func (s *Sweeper) deleteBatch(keys []byte) (int, []byte, error) {
+	keySize := int(s.m.abi.KeySize)
+
+	deleted := 0
+	for len(keys) > 0 {
+		count := len(keys) / keySize
+		if count > s.opts.BatchSize {
+			count = s.opts.BatchSize
+		}
+
+		n, err := bpfMapDeleteBatch(s.m.fd, internal.NewSlicePointer(keys[:count*keySize]), uint32(count))
+		deleted += int(n)
+		keys = keys[int(n)*keySize:]
+		if xerrors.Is(err, ErrKeyNotExist) {
+			// The key was deleted concurrently, for example by LRU
+			// eviction. Skip it.
+			keys = keys[keySize:]
+			continue
+		}
+		if err != nil {
+			return deleted, keys, err
+		}
+	}
+
+	return deleted, nil, nil
+}
+
+// deleteSingle deletes keys one by one, and returns the number of
+// deleted keys.
+func (s *Sweeper) deleteSingle(keys []byte) (int, error) {
+	keySize := int(s.m.abi.KeySize)
+
+	deleted := 0
+	for off := 0; off < len(keys); off += keySize {
+		err := s.m.Delete(keys[off : off+keySize])
+		if xerrors.Is(err, ErrKeyNotExist) {
+			continue
+		}
+		if err != nil {
+			return deleted, xerrors.Errorf("sweep: %w", err)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,187 @@
+package ebpf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/btf"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// sweepableMap creates a map whose values are struct { u64 data; u64 last_seen; }.
+func sweepableMap(tb testing.TB, typ MapType) *Map {
+	tb.Helper()
+
+	u64 := &btf.Int{Name: "u64", Size: 8}
+	value := &btf.Struct{Name: "entry", Size: 16, Members: []btf.Member{
+		{Name: "data", Type: u64, Offset: 0},
+		{Name: "last_seen", Type: u64, Offset: 64},
+	}}
+
+	mapBTF, err := btf.NewBuilder().Map(&btf.Int{Name: "u32", Size: 4}, value)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	m, err := NewMap(&MapSpec{
+		Type:       typ,
+		KeySize:    4,
+		ValueSize:  16,
+		MaxEntries: 100,
+		BTF:        mapBTF,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return m
+}
+
+func TestSweeper(t *testing.T) {
+	m := sweepableMap(t, Hash)
+	defer m.Close()
+
+	for i := uint32(0); i < 10; i++ {
+		if err := m.Put(i, [2]uint64{0, uint64(i) * 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := NewSweeper(m, SweeperOptions{Field: "last_seen", TTL: time.Hour, BatchSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.DeleteBefore(50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Errorf("Deleted %d entries instead of 5", n)
+	}
+
+	for i := uint32(0); i < 10; i++ {
+		var value [2]uint64
+		err := m.Lookup(i, &value)
+		if i < 5 && err == nil {
+			t.Errorf("Key %d wasn't deleted", i)
+		}
+		if i >= 5 && err != nil {
+			t.Errorf("Key %d was deleted: %s", i, err)
+		}
+	}
+
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		t.Fatal(err)
+	}
+	now := uint64(ts.Sec)*uint64(time.Second) + uint64(ts.Nsec)
+	if err := m.Put(uint32(5), [2]uint64{0, now}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err = s.Sweep()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("Sweep deleted %d entries instead of 4", n)
+	}
+
+	if _, err := NewSweeper(m, SweeperOptions{Field: "missing", TTL: time.Hour}); err == nil {
+		t.Error("NewSweeper accepts a missing field")
+	}
+
+	if _, err := NewSweeper(m, SweeperOptions{Field: "last_seen"}); err == nil {
+		t.Error("NewSweeper accepts a zero TTL")
+	}
+
+	arr, err := NewMap(&MapSpec{Type: Array, KeySize: 4, ValueSize: 16, MaxEntries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arr.Close()
+
+	if _, err := NewSweeper(arr, SweeperOptions{Field: "last_seen", TTL: time.Hour}); err == nil {
+		t.Error("NewSweeper accepts an array")
+	}
+}
+
+func TestSweeperPerCPU(t *testing.T) {
+	numCPU, err := internal.PossibleCPUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := sweepableMap(t, LRUCPUHash)
+	defer m.Close()
+
+	// Key 0 is stale on all CPUs, key 1 was seen recently on the last one.
+	for key := uint32(0); key < 2; key++ {
+		values := make([][2]uint64, numCPU)
+		if key == 1 {
+			values[numCPU-1][1] = 100
+		}
+		if err := m.Put(key, values); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := NewSweeper(m, SweeperOptions{Field: "last_seen", TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.DeleteBefore(50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("Deleted %d entries instead of 1", n)
+	}
+
+	var values [][2]uint64
+	if err := m.Lookup(uint32(1), &values); err != nil {
+		t.Fatal("Key 1 was deleted:", err)
+	}
+	if len(values) != numCPU || values[numCPU-1][1] != 100 {
+		t.Error("Unexpected per-CPU values:", values)
+	}
+}
+
+func TestMapLRUFlags(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       LRUHash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 10,
+		Flags:      MapFlagNoCommonLRU,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	_, err = NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 10,
+		Flags:      MapFlagNoCommonLRU,
+	})
+	if err == nil {
+		t.Error("Hash accepts MapFlagNoCommonLRU")
+	}
+
+	_, err = NewMap(&MapSpec{
+		Type:       LRUHash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 10,
+		Flags:      MapFlagNoPrealloc,
+	})
+	if err == nil {
+		t.Error("LRUHash accepts MapFlagNoPrealloc")
+	}
+}
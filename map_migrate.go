@@ -0,0 +1,281 @@
+package ebpf
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// MapTransform converts an entry of a map into an entry of another map.
+//
+// Returning a nil key drops the entry.
+type MapTransform func(key, value []byte) ([]byte, []byte, error)
+
+// The number of entries MigrateMap copies per batch.
+const migrateChunkSize = 256
+
+// MigrateMap creates a map from newSpec and copies all entries of old
+// into it.
+//
+// transform is called with the raw key and value of each entry and returns
+// the key and value to store in the new map. The buffers passed to it are
+// reused, they must be copied to be retained. Values of per-CPU maps
+// contain the value for each possible CPU, padded to a multiple of eight
+// bytes. A nil transform copies entries unchanged, which requires that the
+// key and value sizes of both maps match.
+//
+// Entries are read and written in batches on Linux 5.6 and later, and one
+// at a time otherwise. old isn't modified, but changes made to it during
+// the migration may be missing from the new map.
+func MigrateMap(old *Map, newSpec *MapSpec, transform MapTransform) (*Map, error) {
+	for _, t := range []MapType{old.abi.Type, newSpec.Type} {
+		if t.hasFileDescriptors() || t.isLocalStorage() {
+			return nil, xerrors.Errorf("can't migrate %s", t)
+		}
+	}
+
+	m, err := NewMap(newSpec)
+	if err != nil {
+		return nil, xerrors.Errorf("can't create map: %w", err)
+	}
+
+	if err := migrateEntries(old, m, transform); err != nil {
+		m.Close()
+		return nil, xerrors.Errorf("migrate %s: %w", old, err)
+	}
+
+	return m, nil
+}
+
+// MigratePinnedMap migrates the map pinned at fileName using MigrateMap,
+// and replaces the pin with the new map.
+//
+// The pin is replaced atomically: other processes opening fileName get
+// either the old or the new map. The old map is closed, but remains
+// valid as long as something else refers to it.
+func MigratePinnedMap(fileName string, newSpec *MapSpec, transform MapTransform) (*Map, error) {
+	old, err := LoadPinnedMap(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer old.Close()
+
+	m, err := MigrateMap(old, newSpec, transform)
+	if err != nil {
+		return nil, err
+	}
+
+	// bpffs supports renaming over an existing file, pin the new map
+	// next to the old one first. Names on bpffs may not contain dots.
+	tmp := fmt.Sprintf("%s_migrate_%d", fileName, os.Getpid())
+	if err := m.Pin(tmp); err != nil {
+		m.Close()
+		return nil, xerrors.Errorf("can't pin migrated map: %w", err)
+	}
+
+	if err := os.Rename(tmp, fileName); err != nil {
+		_ = os.Remove(tmp)
+		m.Close()
+		return nil, xerrors.Errorf("can't replace pin: %w", err)
+	}
+
+	return m, nil
+}
+
+func migrateEntries(old, new *Map, transform MapTransform) error {
+	var (
+		oldKeySize   = int(old.abi.KeySize)
+		oldValueSize = old.fullValueSize
+		newKeySize   = int(new.abi.KeySize)
+		newValueSize = new.fullValueSize
+	)
+
+	if transform == nil {
+		if oldKeySize != newKeySize || oldValueSize != newValueSize {
+			return xerrors.New("key and value sizes differ, a transform is required")
+		}
+
+		transform = func(key, value []byte) ([]byte, []byte, error) {
+			return key, value, nil
+		}
+	}
+
+	var (
+		r = migrationReader{
+			m:      old,
+			keys:   make([]byte, migrateChunkSize*oldKeySize),
+			values: make([]byte, migrateChunkSize*oldValueSize),
+		}
+		keys   = make([]byte, migrateChunkSize*newKeySize)
+		values = make([]byte, migrateChunkSize*newValueSize)
+		w      = migrationWriter{m: new}
+	)
+
+	for {
+		n, done, err := r.read()
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for i := 0; i < n; i++ {
+			key, value, err := transform(
+				r.keys[i*oldKeySize:(i+1)*oldKeySize],
+				r.values[i*oldValueSize:(i+1)*oldValueSize],
+			)
+			if err != nil {
+				return xerrors.Errorf("transform: %w", err)
+			}
+			if key == nil {
+				continue
+			}
+
+			if len(key) != newKeySize {
+				return xerrors.Errorf("transform returned a key with %d bytes instead of %d", len(key), newKeySize)
+			}
+			if len(value) != newValueSize {
+				return xerrors.Errorf("transform returned a value with %d bytes instead of %d", len(value), newValueSize)
+			}
+
+			copy(keys[count*newKeySize:], key)
+			copy(values[count*newValueSize:], value)
+			count++
+		}
+
+		err = w.write(keys[:count*newKeySize], values[:count*newValueSize], count)
+		if err != nil {
+			return err
+		}
+
+		if done {
+			return nil
+		}
+	}
+}
+
+// migrationReader reads a map in chunks, using batch lookups if the
+// kernel supports them.
+type migrationReader struct {
+	m            *Map
+	keys, values []byte
+
+	// State of batch lookups.
+	inBatch, outBatch []byte
+	started           bool
+	// State of iterating one key at a time.
+	noBatch bool
+	prevKey []byte
+}
+
+// read fills keys and values with the next chunk of entries, and returns
+// their number.
+func (mr *migrationReader) read() (int, bool, error) {
+	if !mr.noBatch {
+		n, done, err := mr.readBatch()
+		if !mr.started && (xerrors.Is(err, ErrNotSupported) || xerrors.Is(err, unix.EINVAL)) {
+			mr.noBatch = true
+		} else {
+			mr.started = true
+			return n, done, err
+		}
+	}
+
+	keySize := int(mr.m.abi.KeySize)
+	valueSize := mr.m.fullValueSize
+
+	n := 0
+	for n < migrateChunkSize {
+		key := mr.keys[n*keySize : (n+1)*keySize]
+		ok, err := mr.m.NextKeyBytesInto(mr.prevKey, key)
+		if err != nil {
+			return 0, false, err
+		}
+		if !ok {
+			return n, true, nil
+		}
+
+		if mr.prevKey == nil {
+			mr.prevKey = make([]byte, keySize)
+		}
+		copy(mr.prevKey, key)
+
+		ok, err = mr.m.LookupBytesInto(key, mr.values[n*valueSize:(n+1)*valueSize])
+		if err != nil {
+			return 0, false, err
+		}
+		if ok {
+			// Skip keys which were deleted concurrently.
+			n++
+		}
+	}
+
+	return n, false, nil
+}
+
+func (mr *migrationReader) readBatch() (int, bool, error) {
+	if mr.inBatch == nil {
+		// The cursor is opaque, hash maps use a 32 bit bucket index.
+		cursorSize := int(mr.m.abi.KeySize)
+		if cursorSize < 4 {
+			cursorSize = 4
+		}
+		mr.inBatch = make([]byte, cursorSize)
+		mr.outBatch = make([]byte, cursorSize)
+	}
+
+	var inPtr internal.Pointer
+	if mr.started {
+		copy(mr.inBatch, mr.outBatch)
+		inPtr = internal.NewSlicePointer(mr.inBatch)
+	}
+
+	n, err := bpfMapLookupBatch(mr.m.fd, inPtr, internal.NewSlicePointer(mr.outBatch),
+		internal.NewSlicePointer(mr.keys), internal.NewSlicePointer(mr.values), migrateChunkSize)
+	if xerrors.Is(err, ErrKeyNotExist) {
+		return int(n), true, nil
+	}
+	if err != nil {
+		return 0, false, xerrors.Errorf("batch lookup: %w", err)
+	}
+	return int(n), false, nil
+}
+
+// migrationWriter writes chunks of entries to a map, using batch updates
+// if the kernel supports them.
+type migrationWriter struct {
+	m       *Map
+	noBatch bool
+}
+
+func (mw *migrationWriter) write(keys, values []byte, count int) error {
+	if count == 0 {
+		return nil
+	}
+
+	if !mw.noBatch {
+		_, err := bpfMapUpdateBatch(mw.m.fd, internal.NewSlicePointer(keys),
+			internal.NewSlicePointer(values), uint32(count), uint64(UpdateAny))
+		if err == nil {
+			return nil
+		}
+		if !xerrors.Is(err, ErrNotSupported) && !xerrors.Is(err, unix.EINVAL) {
+			return xerrors.Errorf("batch update: %w", err)
+		}
+		mw.noBatch = true
+	}
+
+	keySize := len(keys) / count
+	valueSize := len(values) / count
+	for i := 0; i < count; i++ {
+		key := internal.NewSlicePointer(keys[i*keySize : (i+1)*keySize])
+		value := internal.NewSlicePointer(values[i*valueSize : (i+1)*valueSize])
+		if err := bpfMapUpdateElem(mw.m.fd, key, value, uint64(UpdateAny)); err != nil {
+			return xerrors.Errorf("update failed: %w", err)
+		}
+	}
+	return nil
+}
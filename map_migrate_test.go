@@ -0,0 +1,131 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+func TestMigrateMap(t *testing.T) {
+	old, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+
+	// More entries than fit into a single chunk.
+	const entries = migrateChunkSize + 10
+	for i := uint32(0); i < entries; i++ {
+		if err := old.Put(i, i*2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newSpec := &MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 2000,
+	}
+
+	if _, err := MigrateMap(old, newSpec, nil); err == nil {
+		t.Fatal("MigrateMap accepts different value sizes without transform")
+	}
+
+	m, err := MigrateMap(old, newSpec, func(key, value []byte) ([]byte, []byte, error) {
+		if internal.NativeEndian.Uint32(key) == 0 {
+			return nil, nil, nil
+		}
+
+		newValue := make([]byte, 8)
+		internal.NativeEndian.PutUint64(newValue, uint64(internal.NativeEndian.Uint32(value)))
+		return key, newValue, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	var (
+		key, n uint32
+		value  uint64
+	)
+	iter := m.Iterate()
+	for iter.Next(&key, &value) {
+		if key == 0 {
+			t.Error("Dropped entry was migrated")
+		}
+		if value != uint64(key)*2 {
+			t.Errorf("Key %d: expected value %d, got %d", key, key*2, value)
+		}
+		n++
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != entries-1 {
+		t.Errorf("Expected %d entries, got %d", entries-1, n)
+	}
+}
+
+func TestMigratePinnedMap(t *testing.T) {
+	tmp, err := ioutil.TempDir("/sys/fs/bpf", "ebpf-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	old := createArray(t)
+	defer old.Close()
+
+	if err := old.Put(uint32(1), uint32(42)); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(tmp, "map")
+	if err := old.Pin(path); err != nil {
+		t.Fatal(err)
+	}
+
+	spec := old.Spec()
+	spec.MaxEntries = 4
+	m, err := MigratePinnedMap(path, spec, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	pinned, err := LoadPinnedMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pinned.Close()
+
+	if max := pinned.ABI().MaxEntries; max != 4 {
+		t.Error("Pin wasn't replaced, max entries is", max)
+	}
+
+	var value uint32
+	if err := pinned.Lookup(uint32(1), &value); err != nil {
+		t.Fatal(err)
+	}
+	if value != 42 {
+		t.Error("Expected value 42, got", value)
+	}
+
+	files, err := ioutil.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Error("Temporary pin wasn't removed")
+	}
+}
@@ -0,0 +1,76 @@
+package ebpf
+
+import (
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// LookupPerCPUSum retrieves an integer value and returns the sum of the
+// values of all CPUs.
+//
+// Values must be unsigned integers of 1, 2, 4 or 8 bytes, and the sum
+// wraps around on overflow. For maps which aren't per-CPU, the value
+// itself is returned.
+//
+// Returns an error if the key doesn't exist, see IsNotExist.
+func (m *Map) LookupPerCPUSum(key interface{}) (uint64, error) {
+	return m.lookupPerCPU(key, func(acc, value uint64) uint64 {
+		return acc + value
+	})
+}
+
+// LookupPerCPUMax retrieves an integer value and returns the largest
+// value of all CPUs.
+//
+// The same restrictions as for LookupPerCPUSum apply.
+func (m *Map) LookupPerCPUMax(key interface{}) (uint64, error) {
+	return m.lookupPerCPU(key, func(acc, value uint64) uint64 {
+		if value > acc {
+			return value
+		}
+		return acc
+	})
+}
+
+// lookupPerCPU reduces the per-CPU values of key using fn, without
+// decoding them into a slice first.
+func (m *Map) lookupPerCPU(key interface{}, fn func(acc, value uint64) uint64) (uint64, error) {
+	size := int(m.abi.ValueSize)
+	switch size {
+	case 1, 2, 4, 8:
+	default:
+		return 0, xerrors.Errorf("can't reduce values of %d bytes", size)
+	}
+
+	buf := getBuffer(m.fullValueSize)
+	defer putBuffer(buf)
+
+	if err := m.lookup(key, internal.NewSlicePointer(*buf)); err != nil {
+		return 0, err
+	}
+
+	stride := size
+	if m.abi.Type.hasPerCPUValue() {
+		stride = align(size, 8)
+	}
+
+	var acc uint64
+	for off := 0; off+size <= len(*buf); off += stride {
+		var value uint64
+		switch size {
+		case 1:
+			value = uint64((*buf)[off])
+		case 2:
+			value = uint64(internal.NativeEndian.Uint16((*buf)[off:]))
+		case 4:
+			value = uint64(internal.NativeEndian.Uint32((*buf)[off:]))
+		case 8:
+			value = internal.NativeEndian.Uint64((*buf)[off:])
+		}
+
+		acc = fn(acc, value)
+	}
+
+	return acc, nil
+}
@@ -6,24 +6,23 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
+	"syscall"
 	"testing"
 	"unsafe"
 
 	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/btf"
 	"github.com/cilium/ebpf/internal/testutils"
-	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/rlimit"
 
 	"golang.org/x/xerrors"
 )
 
 func TestMain(m *testing.M) {
-	err := unix.Setrlimit(8, &unix.Rlimit{
-		Cur: math.MaxUint64,
-		Max: math.MaxUint64,
-	})
-	if err != nil {
+	if err := rlimit.RemoveMemlock(); err != nil {
 		fmt.Println("WARNING: Failed to adjust rlimit, tests may fail")
 	}
 	os.Exit(m.Run())
@@ -68,6 +67,114 @@ func TestMap(t *testing.T) {
 	}
 }
 
+func TestMapKernelFields(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  16,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// Pretend that the second half of the value is a bpf_timer.
+	m.kernelFields = []btf.KernelField{{Kind: "bpf_timer", Offset: 8, Size: 8}}
+
+	value := make([]byte, 16)
+	value[0] = 1
+	if err := m.Put(uint32(0), value); err != nil {
+		t.Fatal("Can't put value with zero kernel fields:", err)
+	}
+
+	value[8] = 1
+	if err := m.Put(uint32(0), value); err == nil {
+		t.Fatal("Put overwrites kernel fields")
+	}
+}
+
+func TestMapRejectedBTF(t *testing.T) {
+	// The key in BTF doesn't match KeySize, which the kernel rejects.
+	mapBTF, err := btf.NewBuilder().Map(&btf.Int{Name: "u64", Size: 8}, &btf.Int{Name: "u32", Size: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := &MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+		BTF:        mapBTF,
+	}
+
+	m, err := NewMap(spec)
+	if err != nil {
+		t.Fatal("Can't create map without BTF:", err)
+	}
+	m.Close()
+
+	coll, err := NewCollectionWithOptions(&CollectionSpec{
+		Maps: map[string]*MapSpec{"m": spec},
+	}, CollectionOptions{RequireBTF: true})
+	testutils.SkipIfNotSupported(t, err)
+	if err == nil {
+		coll.Close()
+		t.Fatal("Creating a map with rejected BTF doesn't fail with RequireBTF")
+	}
+}
+
+func TestMapLocalStorage(t *testing.T) {
+	pidfdOpen := func() (int, error) {
+		const sysPidfdOpen = 434
+		fd, _, errno := syscall.Syscall(sysPidfdOpen, uintptr(os.Getpid()), 0, 0)
+		if errno != 0 {
+			return -1, errno
+		}
+		return int(fd), nil
+	}
+
+	socket := func() (int, error) {
+		return syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	}
+
+	for mt, open := range map[MapType]func() (int, error){
+		SkStorage:   socket,
+		TaskStorage: pidfdOpen,
+	} {
+		t.Run(mt.String(), func(t *testing.T) {
+			m, err := NewMap(&MapSpec{
+				Type:      mt,
+				ValueSize: 8,
+			})
+			testutils.SkipIfNotSupported(t, err)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			fd, err := open()
+			if err != nil {
+				t.Skip("Can't create fd:", err)
+			}
+			defer syscall.Close(fd)
+
+			if err := m.Put(uint32(fd), uint64(42)); err != nil {
+				t.Fatal("Can't put value:", err)
+			}
+
+			var value uint64
+			if err := m.Lookup(uint32(fd), &value); err != nil {
+				t.Fatal("Can't lookup value:", err)
+			}
+			if value != 42 {
+				t.Error("Expected value 42, got", value)
+			}
+		})
+	}
+}
+
 func TestMapClose(t *testing.T) {
 	m := createArray(t)
 
@@ -95,6 +202,120 @@ func TestMapCloneNil(t *testing.T) {
 	}
 }
 
+func TestNewMapFromBorrowedFD(t *testing.T) {
+	m := createArray(t)
+	defer m.Close()
+
+	if err := m.Put(uint32(0), uint32(42)); err != nil {
+		t.Fatal(err)
+	}
+
+	borrowed, err := NewMapFromBorrowedFD(m.FD())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := borrowed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := borrowed.Close(); err != nil {
+		t.Error("Closing twice returns an error:", err)
+	}
+
+	var value uint32
+	if err := m.Lookup(uint32(0), &value); err != nil {
+		t.Fatal("Closing the borrowed map closes the original:", err)
+	}
+	if value != 42 {
+		t.Error("Expected 42, got", value)
+	}
+}
+
+func TestMapLookupBytesInto(t *testing.T) {
+	m := createArray(t)
+	defer m.Close()
+
+	if err := m.Put(uint32(1), uint32(42)); err != nil {
+		t.Fatal(err)
+	}
+
+	key := make([]byte, 4)
+	internal.NativeEndian.PutUint32(key, 1)
+	value := make([]byte, m.ValueBufferSize())
+
+	if ok, err := m.LookupBytesInto(key, value); err != nil || !ok {
+		t.Fatal("Can't look up key:", err)
+	}
+	if v := internal.NativeEndian.Uint32(value); v != 42 {
+		t.Error("Expected 42, got", v)
+	}
+
+	if _, err := m.LookupBytesInto(key, value[:1]); err == nil {
+		t.Error("LookupBytesInto accepts a short buffer")
+	}
+
+	nextKey := make([]byte, 4)
+	var keys int
+	for ok, err := m.NextKeyBytesInto(nil, nextKey); ok; ok, err = m.NextKeyBytesInto(key, nextKey) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(key, nextKey)
+		keys++
+	}
+	if keys != int(m.ABI().MaxEntries) {
+		t.Errorf("Expected %d keys, got %d", m.ABI().MaxEntries, keys)
+	}
+
+	if testing.AllocsPerRun(10, func() { m.LookupBytesInto(key, value) }) != 0 {
+		t.Error("LookupBytesInto allocates")
+	}
+}
+
+func TestMapLookupPerCPU(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       PerCPUArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	cpus, err := internal.PossibleCPUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make([]uint32, cpus)
+	var sum uint64
+	for i := range values {
+		values[i] = uint32(i + 1)
+		sum += uint64(i + 1)
+	}
+	if err := m.Put(uint32(0), values); err != nil {
+		t.Fatal(err)
+	}
+
+	if have, err := m.LookupPerCPUSum(uint32(0)); err != nil {
+		t.Fatal(err)
+	} else if have != sum {
+		t.Errorf("Expected sum %d, got %d", sum, have)
+	}
+
+	if have, err := m.LookupPerCPUMax(uint32(0)); err != nil {
+		t.Fatal(err)
+	} else if have != uint64(cpus) {
+		t.Errorf("Expected max %d, got %d", cpus, have)
+	}
+
+	if _, err := m.LookupPerCPUSum(uint32(1)); !xerrors.Is(err, ErrKeyNotExist) {
+		t.Error("Expected ErrKeyNotExist, got", err)
+	}
+}
+
 func TestMapPin(t *testing.T) {
 	m := createArray(t)
 	defer m.Close()
@@ -438,6 +659,9 @@ func TestNotExist(t *testing.T) {
 	if !xerrors.Is(err, ErrKeyNotExist) {
 		t.Error("Lookup doesn't return ErrKeyNotExist")
 	}
+	if !xerrors.Is(err, syscall.ENOENT) {
+		t.Error("Lookup doesn't preserve ENOENT")
+	}
 
 	buf, err := hash.LookupBytes("hello")
 	if err != nil {
@@ -456,6 +680,27 @@ func TestNotExist(t *testing.T) {
 	}
 }
 
+func TestKeyExist(t *testing.T) {
+	hash := createHash()
+	defer hash.Close()
+
+	if err := hash.Update("hello", uint32(21), UpdateNoExist); err != nil {
+		t.Fatal(err)
+	}
+
+	err := hash.Update("hello", uint32(42), UpdateNoExist)
+	if !xerrors.Is(err, ErrKeyExist) {
+		t.Error("Update doesn't return ErrKeyExist")
+	}
+	if !xerrors.Is(err, syscall.EEXIST) {
+		t.Error("Update doesn't preserve EEXIST")
+	}
+
+	if err := hash.Update("world", uint32(42), UpdateExist); !xerrors.Is(err, ErrKeyNotExist) {
+		t.Error("Update doesn't return ErrKeyNotExist")
+	}
+}
+
 func TestIterateMapInMap(t *testing.T) {
 	const idx = uint32(1)
 
@@ -610,6 +855,42 @@ func TestMapName(t *testing.T) {
 	}
 }
 
+func TestMapSpec(t *testing.T) {
+	if err := haveObjName(); err != nil {
+		t.Skip(err)
+	}
+
+	spec := &MapSpec{
+		Name:       "test",
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 2,
+	}
+
+	m, err := NewMap(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	id, err := m.ID()
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m2, err := NewMapFromID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m2.Close()
+
+	if have := m2.Spec(); !reflect.DeepEqual(have, spec) {
+		t.Errorf("Expected %#v, got %#v", spec, have)
+	}
+}
+
 func TestMapFromFD(t *testing.T) {
 	m := createArray(t)
 	if err := m.Put(uint32(0), uint32(123)); err != nil {
@@ -674,6 +955,103 @@ func TestMapContents(t *testing.T) {
 	}
 }
 
+func TestMapDumpRestore(t *testing.T) {
+	mapBTF, err := btf.NewBuilder().Map(
+		&btf.Int{Name: "u32", Size: 4},
+		&btf.Int{Name: "u64", Size: 8},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := &MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 2,
+		BTF:        mapBTF,
+		Contents: []MapKV{
+			{uint32(1), uint64(23)},
+		},
+	}
+
+	m, err := NewMap(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	var buf strings.Builder
+	if err := m.Dump(&buf); err != nil {
+		t.Fatal("Can't dump map:", err)
+	}
+
+	if !strings.Contains(buf.String(), `"formatted":{"key":1,"value":23}`) {
+		t.Error("Dump doesn't contain formatted entry:", buf.String())
+	}
+
+	spec.Contents = nil
+	restored, err := NewMap(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+
+	if err := restored.Restore(strings.NewReader(buf.String())); err != nil {
+		t.Fatal("Can't restore map:", err)
+	}
+
+	var value uint64
+	if err := restored.Lookup(uint32(1), &value); err != nil {
+		t.Fatal("Can't look up restored key:", err)
+	}
+	if value != 23 {
+		t.Error("Expected restored value 23, got", value)
+	}
+
+	err = restored.Restore(strings.NewReader(`[{"key":["0x01"],"value":[]}]`))
+	if err == nil {
+		t.Error("Restore accepts keys of the wrong size")
+	}
+}
+
+func TestPerCPUMapDumpRestore(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       PerCPUArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	dump := `[{"key":["0x00","0x00","0x00","0x00"],"values":[{"cpu":0,"value":["0x2a","0x00","0x00","0x00"]}]}]`
+	if err := m.Restore(strings.NewReader(dump)); err != nil {
+		t.Fatal("Can't restore map:", err)
+	}
+
+	var buf strings.Builder
+	if err := m.Dump(&buf); err != nil {
+		t.Fatal("Can't dump map:", err)
+	}
+
+	if !strings.HasPrefix(buf.String(), dump[:len(dump)-3]) {
+		t.Errorf("Dump doesn't match:\n%s", buf.String())
+	}
+}
+
+func TestMapRestoreFileDescriptors(t *testing.T) {
+	arr := createProgramArray(t)
+	defer arr.Close()
+
+	dump := `[{"key":["0x00","0x00","0x00","0x00"],"value":["0x03","0x00","0x00","0x00"]}]`
+	if err := arr.Restore(strings.NewReader(dump)); err == nil {
+		t.Error("Restoring a ProgramArray doesn't return an error")
+	}
+}
+
 func TestMapFreeze(t *testing.T) {
 	arr := createArray(t)
 	defer arr.Close()
@@ -866,6 +1244,18 @@ func BenchmarkMap(b *testing.B) {
 		}
 	})
 
+	b.Run("LookupBytesInto", func(b *testing.B) {
+		key, value := make([]byte, 4), make([]byte, 4)
+
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := m.LookupBytesInto(key, value); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
 	b.Run("Update", func(b *testing.B) {
 		var key, value uint32
 
@@ -906,6 +1296,75 @@ func BenchmarkMap(b *testing.B) {
 	})
 }
 
+func BenchmarkPerCPUMap(b *testing.B) {
+	m, err := NewMap(&MapSpec{
+		Type:       PerCPUArray,
+		KeySize:    4,
+		ValueSize:  64,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer m.Close()
+
+	counters, err := NewMap(&MapSpec{
+		Type:       PerCPUArray,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer counters.Close()
+
+	b.Run("Lookup", func(b *testing.B) {
+		var values [][64]byte
+
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			if err := m.Lookup(uint32(0), &values); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("LookupBytes", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := m.LookupBytes(uint32(0)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("LookupPerCPUSum", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := counters.LookupPerCPUSum(uint32(0)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("LookupBytesInto", func(b *testing.B) {
+		key := make([]byte, 4)
+		value := make([]byte, m.ValueBufferSize())
+
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := m.LookupBytesInto(key, value); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 // Per CPU maps store a distinct value for each CPU. They are useful
 // to collect metrics.
 func ExampleMap_perCPU() {
@@ -0,0 +1,251 @@
+package ebpf
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+var errWatcherClosed = xerrors.New("map watcher was closed")
+
+// MapWatchOptions control Map.Watch.
+type MapWatchOptions struct {
+	// Interval is the time between two snapshots of the map. Defaults to
+	// one second.
+	Interval time.Duration
+}
+
+// MapChangeType is the kind of change to a key.
+type MapChangeType int
+
+// Kinds of changes reported by a MapWatcher.
+const (
+	MapKeyAdded MapChangeType = iota + 1
+	MapKeyUpdated
+	MapKeyDeleted
+)
+
+func (mct MapChangeType) String() string {
+	switch mct {
+	case MapKeyAdded:
+		return "added"
+	case MapKeyUpdated:
+		return "updated"
+	case MapKeyDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// MapChange is a change to a single key of a map.
+type MapChange struct {
+	Type MapChangeType
+	Key  []byte
+	// Value is the value after the change, or nil if the key was deleted.
+	// Per-CPU values contain the values of all CPUs, like
+	// Map.LookupBytes.
+	Value []byte
+}
+
+// MapWatcher reports changes to the contents of a map.
+type MapWatcher struct {
+	m      *Map
+	ticker *time.Ticker
+	// iter is nil if the kernel can't iterate the map.
+	iter *mapElemIter
+
+	// Contents of the map at the last snapshot, by key.
+	prev    map[string][]byte
+	pending []MapChange
+
+	// mu protects iter from being closed during a snapshot.
+	mu        sync.Mutex
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Watch reports changes to the contents of the map, relative to its
+// contents when Watch is called.
+//
+// The kernel doesn't notify user space about updates made by programs,
+// so the map is compared to a snapshot periodically. Changes between two
+// snapshots are collapsed: a key which is added and deleted again isn't
+// reported at all, and a key updated multiple times is reported once
+// with its latest value.
+//
+// Snapshots of hash and array maps are taken by a bpf_iter program on
+// Linux 5.9 and later, which is much cheaper than looking up each key
+// from user space. Other maps, older kernels and callers without the
+// privileges to load tracing programs fall back to iterating the map
+// from user space.
+//
+// The watcher doesn't own m, which must stay open while the watcher is
+// used.
+func (m *Map) Watch(opts *MapWatchOptions) (*MapWatcher, error) {
+	interval := time.Second
+	if opts != nil && opts.Interval > 0 {
+		interval = opts.Interval
+	}
+
+	mw := &MapWatcher{
+		m:      m,
+		closed: make(chan struct{}),
+	}
+
+	// The iterator needs more privileges than reading the map, fall back
+	// to syscalls without them.
+	iter, err := newMapElemIter(m)
+	if err == nil {
+		mw.iter = iter
+	} else if !xerrors.Is(err, internal.ErrNotSupported) && !xerrors.Is(err, unix.EPERM) {
+		return nil, xerrors.Errorf("watch map: %w", err)
+	}
+
+	contents, _, err := mw.snapshot()
+	if err != nil {
+		if mw.iter != nil {
+			mw.iter.Close()
+		}
+		return nil, xerrors.Errorf("watch map: %w", err)
+	}
+
+	mw.prev = contents
+	mw.ticker = time.NewTicker(interval)
+	return mw, nil
+}
+
+func (mw *MapWatcher) snapshot() (map[string][]byte, []string, error) {
+	if mw.iter == nil {
+		return mw.m.snapshot()
+	}
+
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	select {
+	case <-mw.closed:
+		return nil, nil, errWatcherClosed
+	default:
+	}
+
+	return mw.iter.snapshot()
+}
+
+// snapshot returns the contents of the map, and the keys in iteration
+// order.
+func (m *Map) snapshot() (map[string][]byte, []string, error) {
+	var (
+		contents = make(map[string][]byte)
+		order    []string
+		prevKey  interface{}
+	)
+
+	for i := uint32(0); ; i++ {
+		if i > m.abi.MaxEntries {
+			return nil, nil, ErrIterationAborted
+		}
+
+		key, err := m.NextKeyBytes(prevKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		if key == nil {
+			break
+		}
+		prevKey = key
+
+		value, err := m.LookupBytes(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if value == nil {
+			// The key was deleted concurrently.
+			continue
+		}
+
+		contents[string(key)] = value
+		order = append(order, string(key))
+	}
+
+	return contents, order, nil
+}
+
+// Read blocks until the map changes.
+//
+// Calling Close interrupts the function.
+func (mw *MapWatcher) Read() (MapChange, error) {
+	for len(mw.pending) == 0 {
+		select {
+		case <-mw.closed:
+			return MapChange{}, errWatcherClosed
+		case <-mw.ticker.C:
+		}
+
+		if err := mw.poll(); err != nil {
+			return MapChange{}, err
+		}
+	}
+
+	change := mw.pending[0]
+	mw.pending = mw.pending[1:]
+	return change, nil
+}
+
+// poll compares the map to the last snapshot, and adds the differences to
+// the pending changes.
+func (mw *MapWatcher) poll() error {
+	contents, order, err := mw.snapshot()
+	if err != nil {
+		return xerrors.Errorf("watch map: %w", err)
+	}
+
+	for _, key := range order {
+		value := contents[key]
+		prevValue, ok := mw.prev[key]
+		switch {
+		case !ok:
+			mw.pending = append(mw.pending, MapChange{MapKeyAdded, []byte(key), value})
+		case !bytes.Equal(prevValue, value):
+			mw.pending = append(mw.pending, MapChange{MapKeyUpdated, []byte(key), value})
+		}
+	}
+
+	var deleted []string
+	for key := range mw.prev {
+		if _, ok := contents[key]; !ok {
+			deleted = append(deleted, key)
+		}
+	}
+
+	// Report deletions in a stable order.
+	sort.Strings(deleted)
+	for _, key := range deleted {
+		mw.pending = append(mw.pending, MapChange{MapKeyDeleted, []byte(key), nil})
+	}
+
+	mw.prev = contents
+	return nil
+}
+
+// Close stops watching the map.
+func (mw *MapWatcher) Close() error {
+	var err error
+	mw.closeOnce.Do(func() {
+		mw.ticker.Stop()
+		close(mw.closed)
+
+		if mw.iter != nil {
+			mw.mu.Lock()
+			defer mw.mu.Unlock()
+			err = mw.iter.Close()
+		}
+	})
+	return err
+}
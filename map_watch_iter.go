@@ -0,0 +1,219 @@
+package ebpf
+
+import (
+	"unsafe"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/btf"
+	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/sys"
+
+	"golang.org/x/xerrors"
+)
+
+// mapElemIter dumps the contents of a map using a bpf_iter program,
+// which reads the whole map in a few syscalls instead of two syscalls
+// per key.
+type mapElemIter struct {
+	keySize   int
+	valueSize int
+	prog      *Program
+	link      *sys.FD
+}
+
+// newMapElemIter creates an iterator over the elements of m.
+//
+// Returns ErrNotSupported if the kernel can't iterate maps of this type.
+func newMapElemIter(m *Map) (*mapElemIter, error) {
+	if err := haveMapElemIter(); err != nil {
+		return nil, err
+	}
+
+	switch m.abi.Type {
+	case Hash, Array, PerCPUHash, PerCPUArray, LRUHash, LRUCPUHash:
+	default:
+		return nil, xerrors.Errorf("iterate %s: %w", m.abi.Type, internal.ErrNotSupported)
+	}
+
+	it := &mapElemIter{
+		keySize:   int(m.abi.KeySize),
+		valueSize: m.fullValueSize,
+	}
+
+	var err error
+	it.prog, err = newMapElemIterProgram(it.keySize, it.valueSize)
+	if err != nil {
+		return nil, xerrors.Errorf("load iterator: %w", err)
+	}
+
+	it.link, err = attachMapElemIter(it.prog, m.fd)
+	if err != nil {
+		it.prog.Close()
+		return nil, err
+	}
+
+	return it, nil
+}
+
+// newMapElemIterProgram loads a program which writes the key and value of
+// each element to the iterator.
+func newMapElemIterProgram(keySize, valueSize int) (*Program, error) {
+	// struct bpf_iter__bpf_map_elem contains pointers to the iterator
+	// metadata, the map, the key and the value. The key and value are
+	// NULL after the last element.
+	return NewProgram(&ProgramSpec{
+		Name:       "map_elem_iter",
+		Type:       Tracing,
+		AttachType: AttachTraceIter,
+		AttachTo:   "bpf_map_elem",
+		Instructions: asm.Instructions{
+			asm.LoadMem(asm.R6, asm.R1, 0, asm.DWord),
+			asm.LoadMem(asm.R7, asm.R1, 16, asm.DWord),
+			asm.LoadMem(asm.R8, asm.R1, 24, asm.DWord),
+			asm.JEq.Imm(asm.R7, 0, "exit"),
+			asm.JEq.Imm(asm.R8, 0, "exit"),
+			// meta->seq
+			asm.LoadMem(asm.R6, asm.R6, 0, asm.DWord),
+			asm.Mov.Reg(asm.R1, asm.R6),
+			asm.Mov.Reg(asm.R2, asm.R7),
+			asm.Mov.Imm(asm.R3, int32(keySize)),
+			asm.FnSeqWrite.Call(),
+			asm.Mov.Reg(asm.R1, asm.R6),
+			asm.Mov.Reg(asm.R2, asm.R8),
+			asm.Mov.Imm(asm.R3, int32(valueSize)),
+			asm.FnSeqWrite.Call(),
+			asm.Mov.Imm(asm.R0, 0).Sym("exit"),
+			asm.Return(),
+		},
+		// bpf_seq_write is only available to GPL programs.
+		License: "GPL",
+	})
+}
+
+func attachMapElemIter(prog *Program, m *internal.FD) (*sys.FD, error) {
+	mapFd, err := m.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	progFd, err := prog.fd.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	// union bpf_iter_link_info starts with the fd of the map.
+	info := mapFd
+	fd, err := sys.LinkCreateIter(&sys.LinkCreateIterAttr{
+		ProgFD:      progFd,
+		AttachType:  sys.AttachType(AttachTraceIter),
+		IterInfo:    sys.NewPointer(unsafe.Pointer(&info)),
+		IterInfoLen: uint32(unsafe.Sizeof(info)),
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("attach iterator: %w", err)
+	}
+
+	return fd, nil
+}
+
+// snapshot returns the contents of the map, and the keys in iteration
+// order.
+func (it *mapElemIter) snapshot() (map[string][]byte, []string, error) {
+	linkFd, err := it.link.Value()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fd, err := sys.IterCreate(&sys.IterCreateAttr{LinkFD: linkFd})
+	if err != nil {
+		return nil, nil, xerrors.Errorf("create iterator: %w", err)
+	}
+	defer fd.Close()
+
+	raw, err := fd.Value()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		data  []byte
+		chunk = make([]byte, 4096)
+	)
+	for {
+		n, err := unix.Read(int(raw), chunk)
+		if xerrors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, xerrors.Errorf("read iterator: %w", err)
+		}
+		if n == 0 {
+			break
+		}
+		data = append(data, chunk[:n]...)
+	}
+
+	size := it.keySize + it.valueSize
+	if len(data)%size != 0 {
+		return nil, nil, xerrors.Errorf("iterator returned %d bytes, which isn't a multiple of %d", len(data), size)
+	}
+
+	var (
+		contents = make(map[string][]byte)
+		order    []string
+	)
+	for ; len(data) > 0; data = data[size:] {
+		key := string(data[:it.keySize])
+		if _, ok := contents[key]; !ok {
+			order = append(order, key)
+		}
+		contents[key] = data[it.keySize:size:size]
+	}
+
+	return contents, order, nil
+}
+
+// Close detaches and unloads the iterator.
+func (it *mapElemIter) Close() error {
+	linkErr := it.link.Close()
+	progErr := it.prog.Close()
+	if linkErr != nil {
+		return linkErr
+	}
+	return progErr
+}
+
+var haveMapElemIter = internal.FeatureTestErr("bpf_iter for map elements", "5.9", func() error {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		// Missing privileges or a low memlock limit.
+		return err
+	}
+	defer m.Close()
+
+	prog, err := newMapElemIterProgram(4, 4)
+	if xerrors.Is(err, unix.EINVAL) || xerrors.Is(err, ErrNotSupported) || xerrors.Is(err, btf.ErrNotFound) {
+		// The kernel doesn't know the program type or the iterator.
+		return internal.ErrNotSupported
+	}
+	if err != nil {
+		return err
+	}
+	defer prog.Close()
+
+	link, err := attachMapElemIter(prog, m.fd)
+	if xerrors.Is(err, unix.EINVAL) {
+		return internal.ErrNotSupported
+	}
+	if err != nil {
+		return err
+	}
+	link.Close()
+	return nil
+})
@@ -0,0 +1,140 @@
+package ebpf
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestMapWatch(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for i := uint32(0); i < 3; i++ {
+		if err := m.Put(i, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mw, err := m.Watch(&MapWatchOptions{Interval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close()
+
+	if err := m.Put(uint32(1), uint32(42)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete(uint32(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Put(uint32(5), uint32(5)); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(map[uint32]MapChange)
+	for len(changes) < 3 {
+		change, err := mw.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes[internal.NativeEndian.Uint32(change.Key)] = change
+	}
+
+	if c := changes[1]; c.Type != MapKeyUpdated || internal.NativeEndian.Uint32(c.Value) != 42 {
+		t.Errorf("Expected key 1 to be updated to 42, got %v %v", c.Type, c.Value)
+	}
+	if c := changes[2]; c.Type != MapKeyDeleted || c.Value != nil {
+		t.Errorf("Expected key 2 to be deleted, got %v %v", c.Type, c.Value)
+	}
+	if c := changes[5]; c.Type != MapKeyAdded || internal.NativeEndian.Uint32(c.Value) != 5 {
+		t.Errorf("Expected key 5 to be added, got %v %v", c.Type, c.Value)
+	}
+	if _, ok := changes[0]; ok {
+		t.Error("Unchanged key 0 is reported")
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := mw.Read()
+		done <- err
+	}()
+
+	mw.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Read doesn't return an error after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close doesn't interrupt Read")
+	}
+}
+
+func TestHaveMapElemIter(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveMapElemIter)
+}
+
+func TestMapWatchIterator(t *testing.T) {
+	testutils.SkipIfNotSupported(t, haveMapElemIter())
+
+	for _, typ := range []MapType{Hash, PerCPUArray} {
+		t.Run(typ.String(), func(t *testing.T) {
+			m, err := NewMap(&MapSpec{
+				Type:       typ,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 4,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			for i := uint32(0); i < 4; i++ {
+				var value interface{} = i * 3
+				if typ == PerCPUArray {
+					value = []uint32{i * 3}
+				}
+				if err := m.Put(i, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			mw, err := m.Watch(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mw.Close()
+
+			if mw.iter == nil {
+				t.Fatal("Watch doesn't use an iterator")
+			}
+
+			have, _, err := mw.iter.snapshot()
+			if err != nil {
+				t.Fatal("Can't take snapshot via iterator:", err)
+			}
+
+			want, _, err := m.snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(have, want) {
+				t.Errorf("Iterator snapshot doesn't match\nhave %v\nwant %v", have, want)
+			}
+		})
+	}
+}
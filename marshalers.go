@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"reflect"
 	"runtime"
+	"sync"
 	"unsafe"
 
 	"github.com/cilium/ebpf/internal"
@@ -61,6 +62,40 @@ func marshalBytes(data interface{}, length int) (buf []byte, err error) {
 	return buf, nil
 }
 
+// bufferPool holds buffers which are used to unmarshal values.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new([]byte)
+	},
+}
+
+// getBuffer returns a buffer of length bytes from bufferPool.
+//
+// The contents of the buffer are undefined.
+func getBuffer(length int) *[]byte {
+	buf := bufferPool.Get().(*[]byte)
+	if cap(*buf) < length {
+		*buf = make([]byte, length)
+	}
+	*buf = (*buf)[:length]
+	return buf
+}
+
+func putBuffer(buf *[]byte) {
+	bufferPool.Put(buf)
+}
+
+// retainsBuffer returns true if unmarshaling into dst may hold on to
+// the buffer it is given.
+func retainsBuffer(dst interface{}) bool {
+	switch dst.(type) {
+	case *[]byte, encoding.BinaryUnmarshaler:
+		return true
+	default:
+		return false
+	}
+}
+
 func makeBuffer(dst interface{}, length int) (internal.Pointer, []byte) {
 	if ptr, ok := dst.(unsafe.Pointer); ok {
 		return internal.NewPointer(ptr), nil
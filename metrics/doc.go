@@ -0,0 +1,6 @@
+// Package metrics exposes counters and histograms stored in maps as
+// expvar values and in the Prometheus text exposition format.
+//
+// Metrics are read periodically by an Exporter, which caches the most
+// recent values so that scrapes don't cause additional syscalls.
+package metrics
@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Exporter periodically reads metrics and exposes their most recent
+// values.
+//
+// It implements expvar.Var, and http.Handler for the Prometheus text
+// exposition format.
+type Exporter struct {
+	metrics []Metric
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	mu      sync.RWMutex
+	samples []sample
+	err     error
+}
+
+// NewExporter reads metrics every interval until Close is called.
+//
+// The first read happens before NewExporter returns.
+func NewExporter(interval time.Duration, metrics ...Metric) *Exporter {
+	e := &Exporter{
+		metrics: metrics,
+		done:    make(chan struct{}),
+	}
+
+	e.update()
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				e.update()
+			case <-e.done:
+				return
+			}
+		}
+	}()
+
+	return e
+}
+
+// Close stops reading metrics.
+func (e *Exporter) Close() error {
+	close(e.done)
+	e.wg.Wait()
+	return nil
+}
+
+// Err returns the error encountered by the most recent read, if any.
+func (e *Exporter) Err() error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.err
+}
+
+func (e *Exporter) update() {
+	var (
+		samples  = make([]sample, len(e.metrics))
+		firstErr error
+	)
+	for i, metric := range e.metrics {
+		s, err := metric.read()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		samples[i] = s
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.samples, e.err = samples, firstErr
+}
+
+// String implements expvar.Var.
+//
+// It returns a JSON object mapping metric names to their values.
+// Histograms are encoded as an object with the total count and a list
+// of buckets.
+func (e *Exporter) String() string {
+	type bucket struct {
+		UpperBound uint64 `json:"le"`
+		Count      uint64 `json:"count"`
+	}
+
+	type histogram struct {
+		Count   uint64   `json:"count"`
+		Buckets []bucket `json:"buckets"`
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	values := make(map[string]interface{})
+	for i, metric := range e.metrics {
+		switch s := e.samples[i].(type) {
+		case counterSample:
+			values[metric.Name()] = uint64(s)
+
+		case histogramSample:
+			var h histogram
+			for _, b := range s {
+				h.Count += b.Count
+				h.Buckets = append(h.Buckets, bucket{b.Max, b.Count})
+			}
+			values[metric.Name()] = h
+		}
+	}
+
+	out, err := json.Marshal(values)
+	if err != nil {
+		return strconv.Quote(err.Error())
+	}
+	return string(out)
+}
+
+// ServeHTTP writes all metrics in the Prometheus text exposition format.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := e.WritePrometheus(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = buf.WriteTo(w)
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition
+// format.
+//
+// Histogram buckets are cumulative, as required by Prometheus. Metrics
+// which couldn't be read are omitted.
+func (e *Exporter) WritePrometheus(w io.Writer) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for i, metric := range e.metrics {
+		name := metric.Name()
+
+		var err error
+		switch s := e.samples[i].(type) {
+		case counterSample:
+			_, err = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n",
+				name, escapeHelp(metric.Help()), name, name, uint64(s))
+
+		case histogramSample:
+			_, err = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n",
+				name, escapeHelp(metric.Help()), name)
+
+			var cumulative uint64
+			for _, b := range s {
+				if err != nil {
+					break
+				}
+				cumulative += b.Count
+				_, err = fmt.Fprintf(w, "%s_bucket{le=\"%d\"} %d\n", name, b.Max, cumulative)
+			}
+
+			if err == nil {
+				// The sum of observed values isn't known, since buckets
+				// only record magnitudes.
+				_, err = fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_count %d\n",
+					name, cumulative, name, cumulative)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func escapeHelp(help string) string {
+	var buf bytes.Buffer
+	for _, r := range help {
+		switch r {
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/histogram"
+
+	"golang.org/x/xerrors"
+)
+
+// Metric is a value stored in a map.
+type Metric interface {
+	// Name of the metric, which should follow Prometheus conventions.
+	Name() string
+	// Help is a description of the metric.
+	Help() string
+
+	read() (sample, error)
+}
+
+type sample interface {
+	isSample()
+}
+
+// Counter is a 64 bit counter stored in a map.
+//
+// Values of per-CPU maps are summed.
+type Counter struct {
+	Map *ebpf.Map
+	Key interface{}
+
+	name, help string
+}
+
+// NewCounter creates a counter for the value of key in m.
+func NewCounter(name, help string, m *ebpf.Map, key interface{}) *Counter {
+	return &Counter{m, key, name, help}
+}
+
+// Name implements Metric.
+func (c *Counter) Name() string { return c.name }
+
+// Help implements Metric.
+func (c *Counter) Help() string { return c.help }
+
+// Value reads the current value of the counter.
+//
+// A key which doesn't exist is treated as zero.
+func (c *Counter) Value() (uint64, error) {
+	return lookupSum(c.Map, c.Key)
+}
+
+type counterSample uint64
+
+func (counterSample) isSample() {}
+
+func (c *Counter) read() (sample, error) {
+	value, err := c.Value()
+	return counterSample(value), err
+}
+
+// Histogram is a histogram with power of two buckets stored in a map.
+type Histogram struct {
+	Histogram *histogram.Histogram
+
+	name, help string
+}
+
+// NewHistogram creates a metric for a histogram.
+func NewHistogram(name, help string, h *histogram.Histogram) *Histogram {
+	return &Histogram{h, name, help}
+}
+
+// Name implements Metric.
+func (h *Histogram) Name() string { return h.name }
+
+// Help implements Metric.
+func (h *Histogram) Help() string { return h.help }
+
+type histogramSample []histogram.Bucket
+
+func (histogramSample) isSample() {}
+
+func (h *Histogram) read() (sample, error) {
+	buckets, err := h.Histogram.Read()
+	if err != nil {
+		return nil, xerrors.Errorf("histogram %s: %w", h.name, err)
+	}
+	return histogramSample(buckets), nil
+}
+
+func lookupSum(m *ebpf.Map, key interface{}) (uint64, error) {
+	sum, err := m.LookupPerCPUSum(key)
+	if xerrors.Is(err, ebpf.ErrKeyNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, xerrors.Errorf("can't read %s: %w", m, err)
+	}
+	return sum, nil
+}
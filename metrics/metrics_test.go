@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/histogram"
+	"github.com/cilium/ebpf/internal"
+)
+
+func TestExporter(t *testing.T) {
+	counters, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.PerCPUArray,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer counters.Close()
+
+	cpus, err := internal.PossibleCPUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		perCPU = make([]uint64, cpus)
+		total  uint64
+	)
+	for i := range perCPU {
+		perCPU[i] = uint64(i + 1)
+		total += perCPU[i]
+	}
+
+	if err := counters.Put(uint32(0), perCPU); err != nil {
+		t.Fatal(err)
+	}
+
+	hist, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hist.Close()
+
+	h, err := histogram.New(hist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, count := range []uint64{1, 0, 4, 2} {
+		if err := hist.Put(uint32(i), count); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := NewExporter(time.Hour,
+		NewCounter("packets_total", "Number of packets.", counters, uint32(0)),
+		NewHistogram("latency_ns", "Latency in ns.", h),
+	)
+	defer e.Close()
+
+	if err := e.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := e.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"# HELP packets_total Number of packets.",
+		"# TYPE packets_total counter",
+		fmt.Sprintf("packets_total %d", total),
+		"# HELP latency_ns Latency in ns.",
+		"# TYPE latency_ns histogram",
+		`latency_ns_bucket{le="0"} 1`,
+		`latency_ns_bucket{le="1"} 1`,
+		`latency_ns_bucket{le="3"} 5`,
+		`latency_ns_bucket{le="7"} 7`,
+		`latency_ns_bucket{le="+Inf"} 7`,
+		"latency_ns_count 7",
+		"",
+	}, "\n")
+
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(e.String()), &values); err != nil {
+		t.Fatal("Invalid JSON:", err)
+	}
+
+	if string(values["packets_total"]) != fmt.Sprint(total) {
+		t.Error("Unexpected expvar value for counter:", string(values["packets_total"]))
+	}
+}
@@ -0,0 +1,50 @@
+package ebpf
+
+import (
+	"golang.org/x/xerrors"
+)
+
+// OffloadDevice identifies the network device a program or map is
+// offloaded to.
+type OffloadDevice struct {
+	// The index of the device in its network namespace.
+	Ifindex uint32
+	// The device and inode number of the network namespace, as
+	// returned by stat(2) on /proc/self/ns/net.
+	NetnsDev uint64
+	NetnsIno uint64
+}
+
+// OffloadDevice returns the network device the program is offloaded to,
+// or nil if it runs on the host.
+//
+// Requires at least Linux 4.16.
+func (p *Program) OffloadDevice() (*OffloadDevice, error) {
+	info, err := bpfGetProgInfoByFD(p.fd)
+	if err != nil {
+		return nil, xerrors.Errorf("program %s: %w", p, err)
+	}
+
+	if info.ifindex == 0 {
+		return nil, nil
+	}
+
+	return &OffloadDevice{info.ifindex, info.netnsDev, info.netnsIno}, nil
+}
+
+// OffloadDevice returns the network device the map is offloaded to,
+// or nil if it resides in host memory.
+//
+// Requires at least Linux 4.16.
+func (m *Map) OffloadDevice() (*OffloadDevice, error) {
+	info, err := bpfGetMapInfoByFD(m.fd)
+	if err != nil {
+		return nil, xerrors.Errorf("map %s: %w", m, err)
+	}
+
+	if info.ifindex == 0 {
+		return nil, nil
+	}
+
+	return &OffloadDevice{info.ifindex, info.netnsDev, info.netnsIno}, nil
+}
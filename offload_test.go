@@ -0,0 +1,60 @@
+package ebpf
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestOffloadDeviceHost(t *testing.T) {
+	prog := createSocketFilter(t)
+	defer prog.Close()
+
+	dev, err := prog.OffloadDevice()
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev != nil {
+		t.Error("Program on the host has an offload device:", dev)
+	}
+
+	m := createArray(t)
+	defer m.Close()
+
+	dev, err = m.OffloadDevice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev != nil {
+		t.Error("Map on the host has an offload device:", dev)
+	}
+}
+
+func TestOffloadUnsupportedDevice(t *testing.T) {
+	// The loopback device doesn't support offload.
+	_, err := NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+		Ifindex:    1,
+	})
+	if err == nil {
+		t.Error("Offloading a map to the loopback device doesn't fail")
+	}
+
+	_, err = NewProgram(&ProgramSpec{
+		Type: XDP,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 2),
+			asm.Return(),
+		},
+		License: "MIT",
+		Ifindex: 1,
+	})
+	if err == nil {
+		t.Error("Offloading a program to the loopback device doesn't fail")
+	}
+}
@@ -0,0 +1,240 @@
+package perf
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+var errPollerClosed = xerrors.New("poller was closed")
+
+// Poller waits for records on multiple Readers from a single goroutine.
+//
+// Readers added to a Poller should only be read using ReadAvailable,
+// since the kernel notifies only one waiter of new records.
+type Poller struct {
+	// waitMu serialises Wait and Close. If locking both 'waitMu' and 'mu',
+	// 'waitMu' must be locked first.
+	waitMu sync.Mutex
+
+	mu      sync.Mutex
+	epollFd int
+	closeFd int
+	nFds    int
+	// Readers by the identifier stored in epoll events, and vice versa.
+	readers map[int32]*Reader
+	ids     map[*Reader]int32
+	nextID  int32
+
+	closeOnce sync.Once
+}
+
+// NewPoller creates a Poller without any Readers.
+func NewPoller() (*Poller, error) {
+	epollFd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, xerrors.Errorf("can't create epoll fd: %v", err)
+	}
+
+	closeFd, err := unix.Eventfd(0, unix.O_CLOEXEC|unix.O_NONBLOCK)
+	if err != nil {
+		unix.Close(epollFd)
+		return nil, err
+	}
+
+	if err := addToEpoll(epollFd, closeFd, -1); err != nil {
+		unix.Close(epollFd)
+		unix.Close(closeFd)
+		return nil, err
+	}
+
+	p := &Poller{
+		epollFd: epollFd,
+		closeFd: closeFd,
+		readers: make(map[int32]*Reader),
+		ids:     make(map[*Reader]int32),
+	}
+	runtime.SetFinalizer(p, (*Poller).Close)
+	return p, nil
+}
+
+// Add starts waiting for records on rd.
+//
+// It's safe to call Add while another goroutine is blocked in Wait.
+func (p *Poller) Add(rd *Reader) error {
+	fds, err := rd.ringFds()
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.epollFd == -1 {
+		return errPollerClosed
+	}
+
+	if _, ok := p.ids[rd]; ok {
+		return xerrors.New("reader was already added")
+	}
+
+	if p.nextID == math.MaxInt32 {
+		return xerrors.New("too many readers")
+	}
+
+	id := p.nextID
+	for i, fd := range fds {
+		if err := addToEpoll(p.epollFd, fd, int(id)); err != nil {
+			for _, fd := range fds[:i] {
+				unix.EpollCtl(p.epollFd, unix.EPOLL_CTL_DEL, fd, nil)
+			}
+			return err
+		}
+	}
+
+	p.nextID++
+	p.nFds += len(fds)
+	p.readers[id] = rd
+	p.ids[rd] = id
+	return nil
+}
+
+// Remove stops waiting for records on rd.
+func (p *Poller) Remove(rd *Reader) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.epollFd == -1 {
+		return errPollerClosed
+	}
+
+	id, ok := p.ids[rd]
+	if !ok {
+		return xerrors.New("reader wasn't added")
+	}
+
+	delete(p.readers, id)
+	delete(p.ids, rd)
+
+	fds, err := rd.ringFds()
+	if err != nil {
+		// The kernel removes closed fds from the epoll set.
+		return nil
+	}
+
+	p.nFds -= len(fds)
+	for _, fd := range fds {
+		if err := unix.EpollCtl(p.epollFd, unix.EPOLL_CTL_DEL, fd, nil); err != nil {
+			return xerrors.Errorf("can't remove fd from epoll: %v", err)
+		}
+	}
+	return nil
+}
+
+// Wait blocks until at least one Reader has records, and returns all
+// Readers which do.
+//
+// A negative timeout waits indefinitely. An empty slice is returned if
+// the timeout expires. Calling Close interrupts the function.
+func (p *Poller) Wait(timeout time.Duration) ([]*Reader, error) {
+	p.waitMu.Lock()
+	defer p.waitMu.Unlock()
+
+	p.mu.Lock()
+	epollFd, nFds := p.epollFd, p.nFds
+	p.mu.Unlock()
+
+	if epollFd == -1 {
+		return nil, errPollerClosed
+	}
+
+	msec := -1
+	if timeout >= 0 {
+		ms := (timeout + time.Millisecond - 1) / time.Millisecond
+		if ms > math.MaxInt32 {
+			ms = math.MaxInt32
+		}
+		msec = int(ms)
+	}
+
+	// Allocate an extra event for closeFd
+	events := make([]unix.EpollEvent, nFds+1)
+	for {
+		nEvents, err := unix.EpollWait(epollFd, events, msec)
+		if temp, ok := err.(temporaryError); ok && temp.Temporary() {
+			// Retry the syscall if we we're interrupted, see https://github.com/golang/go/issues/20400
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		events = events[:nEvents]
+		break
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var (
+		readers []*Reader
+		seen    = make(map[*Reader]bool)
+	)
+	for _, event := range events {
+		if int(event.Fd) == p.closeFd {
+			return nil, errPollerClosed
+		}
+
+		// The reader may have been removed concurrently.
+		rd := p.readers[event.Pad]
+		if rd == nil || seen[rd] {
+			continue
+		}
+
+		seen[rd] = true
+		readers = append(readers, rd)
+	}
+
+	return readers, nil
+}
+
+// Close frees resources used by the Poller, but not the Readers.
+//
+// It interrupts calls to Wait.
+func (p *Poller) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		runtime.SetFinalizer(p, nil)
+
+		// Interrupt Wait() via the event fd.
+		var value [8]byte
+		internal.NativeEndian.PutUint64(value[:], 1)
+		_, err = unix.Write(p.closeFd, value[:])
+		if err != nil {
+			err = xerrors.Errorf("can't write event fd: %v", err)
+			return
+		}
+
+		p.waitMu.Lock()
+		defer p.waitMu.Unlock()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		unix.Close(p.epollFd)
+		unix.Close(p.closeFd)
+		p.epollFd, p.closeFd = -1, -1
+		p.readers = nil
+		p.ids = nil
+	})
+	if err != nil {
+		return xerrors.Errorf("close Poller: %w", err)
+	}
+	return nil
+}
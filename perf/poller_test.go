@@ -0,0 +1,103 @@
+package perf
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestPoller(t *testing.T) {
+	prog, events := mustOutputSamplesProg(t, 5)
+	defer prog.Close()
+	defer events.Close()
+
+	_, otherEvents := mustOutputSamplesProg(t, 5)
+	defer otherEvents.Close()
+
+	rd, err := NewReader(events, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	other, err := NewReader(otherEvents, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	p, err := NewPoller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	for _, reader := range []*Reader{rd, other} {
+		if err := p.Add(reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := p.Add(rd); err == nil {
+		t.Error("Adding a reader twice doesn't return an error")
+	}
+
+	readers, err := p.Wait(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readers) != 0 {
+		t.Fatal("Wait returns readers without records")
+	}
+
+	ret, _, err := prog.Test(make([]byte, 14))
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errno := syscall.Errno(-int32(ret)); errno != 0 {
+		t.Fatal("Expected 0 as return value, got", errno)
+	}
+
+	readers, err = p.Wait(readTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readers) != 1 || readers[0] != rd {
+		t.Fatal("Expected only the reader with records, got", readers)
+	}
+
+	if _, err := rd.ReadAvailable(); err != nil {
+		t.Fatal("Can't read available record:", err)
+	}
+	if _, err := rd.ReadAvailable(); err != ErrNoRecords {
+		t.Fatal("Expected ErrNoRecords, got", err)
+	}
+
+	if err := p.Remove(rd); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := p.Wait(-1)
+		errs <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errs:
+		if !IsClosed(err) {
+			t.Error("Wait doesn't return a closed error:", err)
+		}
+	case <-time.After(readTimeout):
+		t.Fatal("Close doesn't interrupt Wait")
+	}
+}
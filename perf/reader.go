@@ -7,6 +7,8 @@ import (
 	"math"
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/internal"
@@ -16,8 +18,22 @@ import (
 )
 
 var (
-	errClosed = xerrors.New("perf reader was closed")
-	errEOR    = xerrors.New("end of ring")
+	// ErrFlushComplete is returned by Read once all records pending
+	// at the time of a call to Flush have been returned.
+	ErrFlushComplete = xerrors.New("perf reader flush complete")
+	// ErrDeadlineExceeded is returned by Read once the deadline has
+	// passed and all pending records have been returned.
+	ErrDeadlineExceeded = xerrors.New("perf reader deadline exceeded")
+	// ErrNoRecords is returned by ReadAvailable if all per CPU buffers
+	// are empty.
+	ErrNoRecords = xerrors.New("no records available")
+)
+
+var (
+	errClosed          = xerrors.New("perf reader was closed")
+	errEOR             = xerrors.New("end of ring")
+	errOverwritable    = xerrors.New("can't read from an overwritable reader, use Snapshot")
+	errNotOverwritable = xerrors.New("reader is not overwritable")
 )
 
 // perfEventHeader must match 'struct perf_event_header` in <linux/perf_event.h>.
@@ -88,7 +104,7 @@ func readRecord(rd io.Reader, cpu int) (Record, error) {
 	}
 
 	if err != nil {
-		return Record{}, xerrors.Errorf("can't read event header: %v", err)
+		return Record{}, xerrors.Errorf("can't read event header: %w", err)
 	}
 
 	switch header.Type {
@@ -114,7 +130,7 @@ func readLostRecords(rd io.Reader) (uint64, error) {
 
 	err := binary.Read(rd, internal.NativeEndian, &lostHeader)
 	if err != nil {
-		return 0, xerrors.Errorf("can't read lost records header: %v", err)
+		return 0, xerrors.Errorf("can't read lost records header: %w", err)
 	}
 
 	return lostHeader.Lost, nil
@@ -124,12 +140,12 @@ func readRawSample(rd io.Reader) ([]byte, error) {
 	// This must match 'struct perf_event_sample in kernel sources.
 	var size uint32
 	if err := binary.Read(rd, internal.NativeEndian, &size); err != nil {
-		return nil, xerrors.Errorf("can't read sample size: %v", err)
+		return nil, xerrors.Errorf("can't read sample size: %w", err)
 	}
 
 	data := make([]byte, int(size))
 	if _, err := io.ReadFull(rd, data); err != nil {
-		return nil, xerrors.Errorf("can't read sample: %v", err)
+		return nil, xerrors.Errorf("can't read sample: %w", err)
 	}
 	return data, nil
 }
@@ -152,6 +168,8 @@ type Reader struct {
 	epollRings  []*perfEventRing
 	// Eventfds for closing
 	closeFd int
+	// Eventfd to interrupt Read after Flush and SetDeadline.
+	wakeFd int
 	// Ensure we only close once
 	closeOnce sync.Once
 
@@ -160,6 +178,19 @@ type Reader struct {
 	// Read calls, which would otherwise need to be interrupted.
 	pauseMu  sync.Mutex
 	pauseFds []int
+
+	// lostSamples counts lost samples per CPU, and is accessed atomically.
+	lostSamples []uint64
+	onLost      func(cpu int, lost uint64)
+
+	overwritable bool
+
+	// Accessed atomically. deadline is in nanoseconds since the epoch,
+	// or zero.
+	deadline       int64
+	flushRequested uint32
+	// The error returned by Read once epollRings are processed.
+	flushErr error
 }
 
 // ReaderOptions control the behaviour of the user
@@ -169,6 +200,20 @@ type ReaderOptions struct {
 	// Read will process data. Must be smaller than PerCPUBuffer.
 	// The default is to start processing as soon as data is available.
 	Watermark int
+
+	// The number of samples required in any per CPU buffer before Read
+	// will process data. Mutually exclusive with Watermark.
+	WakeupEvents int
+
+	// LostSamples is called from Read for every record of lost samples,
+	// in addition to returning the record. It must not call Read.
+	LostSamples func(cpu int, lost uint64)
+
+	// Overwritable creates per CPU buffers in which the kernel overwrites
+	// the oldest records instead of dropping new ones, like a flight
+	// recorder. Records are retrieved on demand using Snapshot instead
+	// of Read.
+	Overwritable bool
 }
 
 // NewReader creates a new reader with default options.
@@ -186,6 +231,10 @@ func NewReaderWithOptions(array *ebpf.Map, perCPUBuffer int, opts ReaderOptions)
 		return nil, xerrors.New("perCPUBuffer must be larger than 0")
 	}
 
+	if opts.Watermark > 0 && opts.WakeupEvents > 0 {
+		return nil, xerrors.New("Watermark and WakeupEvents are mutually exclusive")
+	}
+
 	epollFd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
 	if err != nil {
 		return nil, xerrors.Errorf("can't create epoll fd: %v", err)
@@ -213,7 +262,7 @@ func NewReaderWithOptions(array *ebpf.Map, perCPUBuffer int, opts ReaderOptions)
 	// but doesn't allow using a wildcard like -1 to specify "all CPUs".
 	// Hence we have to create a ring for each CPU.
 	for i := 0; i < nCPU; i++ {
-		ring, err := newPerfEventRing(i, perCPUBuffer, opts.Watermark)
+		ring, err := newPerfEventRing(i, perCPUBuffer, opts)
 		if err != nil {
 			return nil, xerrors.Errorf("failed to create perf ring for CPU %d: %w", i, err)
 		}
@@ -235,6 +284,16 @@ func NewReaderWithOptions(array *ebpf.Map, perCPUBuffer int, opts ReaderOptions)
 		return nil, err
 	}
 
+	wakeFd, err := unix.Eventfd(0, unix.O_CLOEXEC|unix.O_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	fds = append(fds, wakeFd)
+
+	if err := addToEpoll(epollFd, wakeFd, -1); err != nil {
+		return nil, err
+	}
+
 	array, err = array.Clone()
 	if err != nil {
 		return nil, err
@@ -244,11 +303,16 @@ func NewReaderWithOptions(array *ebpf.Map, perCPUBuffer int, opts ReaderOptions)
 		array:   array,
 		rings:   rings,
 		epollFd: epollFd,
-		// Allocate extra event for closeFd
-		epollEvents: make([]unix.EpollEvent, len(rings)+1),
+		// Allocate extra events for closeFd and wakeFd
+		epollEvents: make([]unix.EpollEvent, len(rings)+2),
 		epollRings:  make([]*perfEventRing, 0, len(rings)),
 		closeFd:     closeFd,
+		wakeFd:      wakeFd,
 		pauseFds:    pauseFds,
+		lostSamples: make([]uint64, len(rings)),
+		onLost:      opts.LostSamples,
+
+		overwritable: opts.Overwritable,
 	}
 	if err = pr.Resume(); err != nil {
 		return nil, err
@@ -289,7 +353,8 @@ func (pr *Reader) Close() error {
 
 		unix.Close(pr.epollFd)
 		unix.Close(pr.closeFd)
-		pr.epollFd, pr.closeFd = -1, -1
+		unix.Close(pr.wakeFd)
+		pr.epollFd, pr.closeFd, pr.wakeFd = -1, -1, -1
 
 		// Close rings
 		for _, ring := range pr.rings {
@@ -311,7 +376,8 @@ func (pr *Reader) Close() error {
 // The function blocks until there are at least Watermark bytes in one
 // of the per CPU buffers.
 //
-// Records from buffers below the Watermark are not returned.
+// Records from buffers below the Watermark are not returned, unless
+// Flush is called or the deadline passes.
 //
 // Calling Close interrupts the function.
 func (pr *Reader) Read() (Record, error) {
@@ -322,9 +388,20 @@ func (pr *Reader) Read() (Record, error) {
 		return Record{}, errClosed
 	}
 
+	if pr.overwritable {
+		return Record{}, errOverwritable
+	}
+
 	for {
+		if len(pr.epollRings) == 0 && pr.flushErr != nil {
+			err := pr.flushErr
+			pr.flushErr = nil
+			return Record{}, err
+		}
+
 		if len(pr.epollRings) == 0 {
-			nEvents, err := unix.EpollWait(pr.epollFd, pr.epollEvents, -1)
+			deadline := atomic.LoadInt64(&pr.deadline)
+			nEvents, err := unix.EpollWait(pr.epollFd, pr.epollEvents, epollTimeout(deadline))
 			if temp, ok := err.(temporaryError); ok && temp.Temporary() {
 				// Retry the syscall if we we're interrupted, see https://github.com/golang/go/issues/20400
 				continue
@@ -334,11 +411,26 @@ func (pr *Reader) Read() (Record, error) {
 				return Record{}, err
 			}
 
+			if nEvents == 0 && deadline != 0 && time.Now().UnixNano() >= deadline {
+				pr.flushErr = ErrDeadlineExceeded
+			}
+
 			for _, event := range pr.epollEvents[:nEvents] {
 				if int(event.Fd) == pr.closeFd {
 					return Record{}, errClosed
 				}
 
+				if int(event.Fd) == pr.wakeFd {
+					var value [8]byte
+					if _, err := unix.Read(pr.wakeFd, value[:]); err != nil && err != unix.EAGAIN {
+						return Record{}, xerrors.Errorf("can't read event fd: %v", err)
+					}
+					if atomic.CompareAndSwapUint32(&pr.flushRequested, 1, 0) {
+						pr.flushErr = ErrFlushComplete
+					}
+					continue
+				}
+
 				ring := pr.rings[cpuForEvent(&event)]
 				pr.epollRings = append(pr.epollRings, ring)
 
@@ -347,8 +439,66 @@ func (pr *Reader) Read() (Record, error) {
 				// from keeping the reader busy.
 				ring.loadHead()
 			}
+
+			if pr.flushErr != nil {
+				// Return records from buffers below the watermark as well.
+				pr.epollRings = pr.epollRings[:0]
+				for _, ring := range pr.rings {
+					ring.loadHead()
+					pr.epollRings = append(pr.epollRings, ring)
+				}
+			}
+
+			if len(pr.epollRings) == 0 {
+				continue
+			}
+		}
+
+		record, err := pr.readEpollRings()
+		if err == errEOR {
+			continue
 		}
 
+		return record, err
+	}
+}
+
+// ReadAvailable returns the next record without blocking, regardless of
+// the watermark. It returns ErrNoRecords once all per CPU buffers are
+// empty.
+//
+// It is meant to be used together with a Poller, and doesn't consume
+// notifications for the per CPU buffers.
+func (pr *Reader) ReadAvailable() (Record, error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	if pr.epollFd == -1 {
+		return Record{}, errClosed
+	}
+
+	if pr.overwritable {
+		return Record{}, errOverwritable
+	}
+
+	if len(pr.epollRings) == 0 {
+		for _, ring := range pr.rings {
+			ring.loadHead()
+			pr.epollRings = append(pr.epollRings, ring)
+		}
+	}
+
+	record, err := pr.readEpollRings()
+	if err == errEOR {
+		return Record{}, ErrNoRecords
+	}
+	return record, err
+}
+
+// readEpollRings returns the next record from epollRings, or errEOR
+// once all of them are empty.
+func (pr *Reader) readEpollRings() (Record, error) {
+	for len(pr.epollRings) > 0 {
 		// Start at the last available event. The order in which we
 		// process them doesn't matter, and starting at the back allows
 		// resizing epollRings to keep track of processed rings.
@@ -360,8 +510,132 @@ func (pr *Reader) Read() (Record, error) {
 			continue
 		}
 
+		if err == nil && record.LostSamples > 0 {
+			atomic.AddUint64(&pr.lostSamples[record.CPU], record.LostSamples)
+			if pr.onLost != nil {
+				pr.onLost(record.CPU, record.LostSamples)
+			}
+		}
+
 		return record, err
 	}
+
+	return Record{}, errEOR
+}
+
+// Flush interrupts a pending or the next call to Read, which then
+// returns all records pending in the per CPU buffers, regardless of the
+// watermark. ErrFlushComplete is returned once all of them have been read.
+func (pr *Reader) Flush() error {
+	atomic.StoreUint32(&pr.flushRequested, 1)
+	return pr.wake()
+}
+
+// SetDeadline sets the time after which Read returns all records pending
+// in the per CPU buffers regardless of the watermark, followed by
+// ErrDeadlineExceeded.
+//
+// The zero value removes the deadline. A pending call to Read observes
+// the new deadline.
+func (pr *Reader) SetDeadline(t time.Time) error {
+	var deadline int64
+	if !t.IsZero() {
+		deadline = t.UnixNano()
+	}
+	atomic.StoreInt64(&pr.deadline, deadline)
+	return pr.wake()
+}
+
+func (pr *Reader) wake() error {
+	// Close holds pauseMu while closing wakeFd.
+	pr.pauseMu.Lock()
+	defer pr.pauseMu.Unlock()
+
+	if pr.pauseFds == nil {
+		return errClosed
+	}
+
+	var value [8]byte
+	internal.NativeEndian.PutUint64(value[:], 1)
+	if _, err := unix.Write(pr.wakeFd, value[:]); err != nil {
+		return xerrors.Errorf("can't write event fd: %v", err)
+	}
+	return nil
+}
+
+// epollTimeout returns the timeout in milliseconds until deadline, or
+// -1 if there is no deadline.
+func epollTimeout(deadline int64) int {
+	if deadline == 0 {
+		return -1
+	}
+
+	remaining := time.Duration(deadline - time.Now().UnixNano())
+	if remaining <= 0 {
+		return 0
+	}
+
+	// Round up to avoid waking up before the deadline.
+	ms := (remaining + time.Millisecond - 1) / time.Millisecond
+	if ms > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int(ms)
+}
+
+// Snapshot returns the records of an overwritable reader which were
+// written since the last call to Snapshot.
+//
+// Records of each CPU are returned newest first, starting with the
+// first CPU. The oldest records may have been overwritten by the kernel.
+//
+// Call Pause beforehand to prevent the kernel from overwriting records
+// while they are being read.
+func (pr *Reader) Snapshot() ([]Record, error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	if pr.epollFd == -1 {
+		return nil, errClosed
+	}
+
+	if !pr.overwritable {
+		return nil, errNotOverwritable
+	}
+
+	var records []Record
+	for _, ring := range pr.rings {
+		ring.loadHead()
+
+		for {
+			record, err := readRecord(ring, ring.cpu)
+			if err == errEOR || xerrors.Is(err, io.ErrUnexpectedEOF) {
+				// The oldest record is truncated if the kernel wrapped
+				// around the buffer.
+				break
+			}
+			if err != nil {
+				return nil, xerrors.Errorf("CPU %d: %w", ring.cpu, err)
+			}
+
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+// LostSamples returns the total number of samples lost on each CPU,
+// indexed by CPU.
+//
+// Samples are only accounted once the record of lost samples has been
+// returned by Read. The counters aren't reset by Close.
+func (pr *Reader) LostSamples() []uint64 {
+	lost := make([]uint64, len(pr.lostSamples))
+	for cpu := range pr.lostSamples {
+		lost[cpu] = atomic.LoadUint64(&pr.lostSamples[cpu])
+	}
+	return lost
 }
 
 // Pause stops all notifications from this Reader.
@@ -408,14 +682,26 @@ func (pr *Reader) Resume() error {
 	return nil
 }
 
+// ringFds returns the fds of the per CPU buffers.
+func (pr *Reader) ringFds() ([]int, error) {
+	pr.pauseMu.Lock()
+	defer pr.pauseMu.Unlock()
+
+	if pr.pauseFds == nil {
+		return nil, errClosed
+	}
+
+	return append([]int(nil), pr.pauseFds...), nil
+}
+
 type temporaryError interface {
 	Temporary() bool
 }
 
 // IsClosed returns true if the error occurred because
-// a Reader was closed.
+// a Reader or Poller was closed.
 func IsClosed(err error) bool {
-	return xerrors.Is(err, errClosed)
+	return xerrors.Is(err, errClosed) || xerrors.Is(err, errPollerClosed)
 }
 
 type unknownEventError struct {
@@ -14,6 +14,7 @@ import (
 	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/testutils"
 	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/rlimit"
 )
 
 var (
@@ -21,11 +22,7 @@ var (
 )
 
 func TestMain(m *testing.M) {
-	err := unix.Setrlimit(8, &unix.Rlimit{
-		Cur: unix.RLIM_INFINITY,
-		Max: unix.RLIM_INFINITY,
-	})
-	if err != nil {
+	if err := rlimit.RemoveMemlock(); err != nil {
 		fmt.Println("WARNING: Failed to adjust rlimit, tests may fail")
 	}
 	os.Exit(m.Run())
@@ -213,7 +210,12 @@ func TestPerfReaderLostSample(t *testing.T) {
 	defer prog.Close()
 	defer events.Close()
 
-	rd, err := NewReader(events, pageSize)
+	var lost []uint64
+	rd, err := NewReaderWithOptions(events, pageSize, ReaderOptions{
+		LostSamples: func(cpu int, n uint64) {
+			lost = append(lost, n)
+		},
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -239,6 +241,149 @@ func TestPerfReaderLostSample(t *testing.T) {
 			t.Fatal("Expected a record with LostSamples 1, got", record.LostSamples)
 		}
 	}
+
+	if len(lost) != 1 || lost[0] != 1 {
+		t.Error("Expected callback for a single lost sample, got", lost)
+	}
+
+	var total uint64
+	for _, n := range rd.LostSamples() {
+		total += n
+	}
+	if total != 1 {
+		t.Error("Expected one lost sample in total, got", total)
+	}
+}
+
+func TestPerfReaderOverwritable(t *testing.T) {
+	prog, events := mustOutputSamplesProg(t, 5, 13)
+	defer prog.Close()
+	defer events.Close()
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{Overwritable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	if _, err := rd.Read(); err == nil {
+		t.Fatal("Read doesn't return an error for overwritable reader")
+	}
+
+	ret, _, err := prog.Test(make([]byte, 14))
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errno := syscall.Errno(-int32(ret)); errno != 0 {
+		t.Fatal("Expected 0 as return value, got", errno)
+	}
+
+	if err := rd.Pause(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := rd.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatal("Expected two records, got", len(records))
+	}
+
+	// Samples are padded to 64 bits, including the 32 bit size.
+	if n := len(records[0].RawSample); n != 20 {
+		t.Error("Expected newest sample of 20 bytes first, got", n)
+	}
+	if n := len(records[1].RawSample); n != 12 {
+		t.Error("Expected oldest sample of 12 bytes last, got", n)
+	}
+
+	records, err = rd.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Error("Snapshot returns records twice")
+	}
+}
+
+func TestPerfReaderFlush(t *testing.T) {
+	prog, events := mustOutputSamplesProg(t, 5)
+	defer prog.Close()
+	defer events.Close()
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{Watermark: 4095})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	ret, _, err := prog.Test(make([]byte, 14))
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errno := syscall.Errno(-int32(ret)); errno != 0 {
+		t.Fatal("Expected 0 as return value, got", errno)
+	}
+
+	if err := rd.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rd.Read(); err != nil {
+		t.Fatal("Can't read sample below watermark after flush:", err)
+	}
+
+	if _, err := rd.Read(); err != ErrFlushComplete {
+		t.Fatal("Expected ErrFlushComplete, got", err)
+	}
+}
+
+func TestPerfReaderDeadline(t *testing.T) {
+	prog, events := mustOutputSamplesProg(t, 5)
+	defer prog.Close()
+	defer events.Close()
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{WakeupEvents: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	ret, _, err := prog.Test(make([]byte, 14))
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errno := syscall.Errno(-int32(ret)); errno != 0 {
+		t.Fatal("Expected 0 as return value, got", errno)
+	}
+
+	if err := rd.SetDeadline(time.Now().Add(readTimeout)); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := rd.Read(); err != nil {
+		t.Fatal("Can't read sample after deadline:", err)
+	}
+	if time.Since(start) < readTimeout {
+		t.Error("Read returns a single sample before the deadline")
+	}
+
+	if _, err := rd.Read(); err != ErrDeadlineExceeded {
+		t.Fatal("Expected ErrDeadlineExceeded, got", err)
+	}
+
+	if err := rd.SetDeadline(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestPerfReaderClose(t *testing.T) {
@@ -284,7 +429,7 @@ func TestPerfReaderClose(t *testing.T) {
 }
 
 func TestCreatePerfEvent(t *testing.T) {
-	fd, err := createPerfEvent(0, 1)
+	fd, err := createPerfEvent(0, ReaderOptions{Watermark: 1})
 	if err != nil {
 		t.Fatal("Can't create perf event:", err)
 	}
@@ -18,11 +18,18 @@ type perfEventRing struct {
 	fd   int
 	cpu  int
 	mmap []byte
-	*ringReader
+	ringBuffer
 }
 
-func newPerfEventRing(cpu, perCPUBuffer, watermark int) (*perfEventRing, error) {
-	if watermark >= perCPUBuffer {
+// ringBuffer reads records from the data pages of a perf event.
+type ringBuffer interface {
+	io.Reader
+	loadHead()
+	writeTail()
+}
+
+func newPerfEventRing(cpu, perCPUBuffer int, opts ReaderOptions) (*perfEventRing, error) {
+	if opts.Watermark >= perCPUBuffer {
 		return nil, xerrors.New("watermark must be smaller than perCPUBuffer")
 	}
 
@@ -32,7 +39,7 @@ func newPerfEventRing(cpu, perCPUBuffer, watermark int) (*perfEventRing, error)
 	nPages := (perCPUBuffer + pageSize - 1) / pageSize
 	size := (1 + nPages) * pageSize
 
-	fd, err := createPerfEvent(cpu, watermark)
+	fd, err := createPerfEvent(cpu, opts)
 	if err != nil {
 		return nil, xerrors.Errorf("can't create perf event: %w", err)
 	}
@@ -42,7 +49,13 @@ func newPerfEventRing(cpu, perCPUBuffer, watermark int) (*perfEventRing, error)
 		return nil, err
 	}
 
-	mmap, err := unix.Mmap(fd, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	// The kernel only overwrites old records if the mapping is read-only.
+	prot := unix.PROT_READ | unix.PROT_WRITE
+	if opts.Overwritable {
+		prot = unix.PROT_READ
+	}
+
+	mmap, err := unix.Mmap(fd, 0, size, prot, unix.MAP_SHARED)
 	if err != nil {
 		unix.Close(fd)
 		return nil, err
@@ -54,11 +67,17 @@ func newPerfEventRing(cpu, perCPUBuffer, watermark int) (*perfEventRing, error)
 	// documentation, since a byte is smaller than sampledPerfEvent.
 	meta := (*unix.PerfEventMmapPage)(unsafe.Pointer(&mmap[0]))
 
+	data := mmap[meta.Data_offset : meta.Data_offset+meta.Data_size]
+
 	ring := &perfEventRing{
-		fd:         fd,
-		cpu:        cpu,
-		mmap:       mmap,
-		ringReader: newRingReader(meta, mmap[meta.Data_offset:meta.Data_offset+meta.Data_size]),
+		fd:   fd,
+		cpu:  cpu,
+		mmap: mmap,
+	}
+	if opts.Overwritable {
+		ring.ringBuffer = newReverseReader(meta, data)
+	} else {
+		ring.ringBuffer = newRingReader(meta, data)
 	}
 	runtime.SetFinalizer(ring, (*perfEventRing).Close)
 
@@ -74,17 +93,27 @@ func (ring *perfEventRing) Close() {
 	ring.mmap = nil
 }
 
-func createPerfEvent(cpu, watermark int) (int, error) {
-	if watermark == 0 {
-		watermark = 1
-	}
-
+func createPerfEvent(cpu int, opts ReaderOptions) (int, error) {
 	attr := unix.PerfEventAttr{
 		Type:        unix.PERF_TYPE_SOFTWARE,
 		Config:      unix.PERF_COUNT_SW_BPF_OUTPUT,
-		Bits:        unix.PerfBitWatermark,
 		Sample_type: unix.PERF_SAMPLE_RAW,
-		Wakeup:      uint32(watermark),
+	}
+
+	if opts.WakeupEvents > 0 {
+		attr.Wakeup = uint32(opts.WakeupEvents)
+	} else {
+		watermark := opts.Watermark
+		if watermark == 0 {
+			watermark = 1
+		}
+
+		attr.Bits = unix.PerfBitWatermark
+		attr.Wakeup = uint32(watermark)
+	}
+
+	if opts.Overwritable {
+		attr.Bits |= unix.PerfBitWriteBackward
 	}
 
 	attr.Size = uint32(unsafe.Sizeof(attr))
@@ -146,3 +175,68 @@ func (rr *ringReader) Read(p []byte) (int, error) {
 
 	return n, nil
 }
+
+// reverseReader reads an overwritable ring buffer, which the kernel
+// fills from the end towards the start. Reading forward from the head
+// returns the newest record first.
+type reverseReader struct {
+	meta *unix.PerfEventMmapPage
+	// The newest record starts at head, and the data up to tail hasn't
+	// been read before. read is the position of the next read.
+	head, read, tail uint64
+	mask             uint64
+	ring             []byte
+}
+
+func newReverseReader(meta *unix.PerfEventMmapPage, ring []byte) *reverseReader {
+	head := atomic.LoadUint64(&meta.Data_head)
+	return &reverseReader{
+		meta: meta,
+		head: head,
+		read: head,
+		tail: head,
+		// cap is always a power of two
+		mask: uint64(cap(ring) - 1),
+		ring: ring,
+	}
+}
+
+func (rr *reverseReader) loadHead() {
+	// Records before the previous head have been returned already.
+	rr.tail = rr.head
+	rr.head = atomic.LoadUint64(&rr.meta.Data_head)
+	rr.read = rr.head
+
+	if rr.tail-rr.head > uint64(cap(rr.ring)) {
+		// The kernel has overwritten the oldest records.
+		rr.tail = rr.head + uint64(cap(rr.ring))
+	}
+}
+
+func (rr *reverseReader) writeTail() {
+	// The kernel doesn't track the position of the reader.
+}
+
+func (rr *reverseReader) Read(p []byte) (int, error) {
+	start := int(rr.read & rr.mask)
+
+	n := len(p)
+	// Truncate if the read wraps in the ring buffer
+	if remainder := cap(rr.ring) - start; n > remainder {
+		n = remainder
+	}
+
+	// Truncate if there isn't enough data
+	if remainder := int(rr.tail - rr.read); n > remainder {
+		n = remainder
+	}
+
+	copy(p, rr.ring[start:start+n])
+	rr.read += uint64(n)
+
+	if rr.read == rr.tail {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
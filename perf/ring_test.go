@@ -44,6 +44,49 @@ func TestRingBufferReader(t *testing.T) {
 	}
 }
 
+func TestReverseReader(t *testing.T) {
+	ring := make([]byte, 4)
+	for i := range ring {
+		ring[i] = byte(i)
+	}
+
+	// The kernel writes backwards, starting at zero.
+	meta := unix.PerfEventMmapPage{Data_size: uint64(len(ring))}
+	rr := newReverseReader(&meta, ring)
+
+	meta.Data_head = ^uint64(0) - 1
+	rr.loadHead()
+
+	buf := make([]byte, 4)
+	n, err := rr.Read(buf)
+	if err != io.EOF {
+		t.Error("Expected io.EOF, got", err)
+	}
+	if !bytes.Equal(buf[:n], []byte{2, 3}) {
+		t.Error("Expected [2, 3], got", buf[:n])
+	}
+
+	// Reads wrap around the end of the buffer.
+	meta.Data_head -= 3
+	rr.loadHead()
+
+	n, err = io.ReadFull(rr, buf[:2])
+	if err != nil {
+		t.Error("Error while reading:", err)
+	}
+	if !bytes.Equal(buf[:n], []byte{3, 0}) {
+		t.Error("Expected [3, 0], got", buf[:n])
+	}
+
+	n, err = rr.Read(buf)
+	if err != io.EOF {
+		t.Error("Expected io.EOF, got", err)
+	}
+	if !bytes.Equal(buf[:n], []byte{1}) {
+		t.Error("Expected [1], got", buf[:n])
+	}
+}
+
 func makeRing(size, offset int) *ringReader {
 	if size%2 != 0 {
 		panic("size must be power of two")
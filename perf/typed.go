@@ -0,0 +1,356 @@
+package perf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/btf"
+
+	"golang.org/x/xerrors"
+)
+
+// RecordReader reads raw records from a buffer shared with the kernel.
+//
+// It is implemented by Reader.
+type RecordReader interface {
+	Read() (Record, error)
+	Close() error
+}
+
+// Backpressure determines what a TypedReader does if the consumer
+// doesn't keep up with incoming samples.
+type Backpressure int
+
+const (
+	// Block stops reading from the kernel until the consumer is ready.
+	// Samples which don't fit into the kernel buffer in the meantime are
+	// lost, and reported via TypedRecord.LostSamples.
+	Block Backpressure = iota
+	// DropNewest discards samples which don't fit into the channel.
+	DropNewest
+	// DropOldest discards the oldest buffered sample to make room for
+	// a new one.
+	DropOldest
+)
+
+// TypedReaderOptions control the behaviour of a TypedReader.
+type TypedReaderOptions struct {
+	// The number of decoded records buffered in the channel.
+	Buffer int
+	// What to do if the channel is full.
+	Backpressure Backpressure
+	// If BTF and Struct are set, the layout of the sample type is
+	// compared to the C struct of that name before reading any records.
+	BTF    *ebpf.CollectionSpec
+	Struct string
+}
+
+// TypedRecord is a decoded sample, or a counter of the number
+// of lost samples.
+type TypedRecord struct {
+	// The CPU this record was generated on.
+	CPU int
+
+	// A pointer to the decoded sample, with the type passed to
+	// NewTypedReader. Nil if LostSamples is not zero.
+	Value interface{}
+
+	// The number of samples which could not be output, since
+	// the ring buffer was full.
+	LostSamples uint64
+}
+
+// TypedReader decodes fixed-size samples into Go values and delivers
+// them on a channel.
+//
+// Samples are decoded using encoding/binary in native endianness.
+// The Go type therefore has to declare padding explicitly, using
+// fields named _.
+type TypedReader struct {
+	rd      RecordReader
+	typ     reflect.Type
+	size    int
+	opts    TypedReaderOptions
+	records chan TypedRecord
+	done    chan struct{}
+	stopped chan struct{}
+	dropped uint64
+	lost    uint64
+
+	closeOnce sync.Once
+	err       error
+}
+
+// NewTypedReader starts decoding records from rd into values of the same
+// type as sample.
+//
+// The TypedReader takes ownership of rd.
+func NewTypedReader(rd RecordReader, sample interface{}, opts TypedReaderOptions) (*TypedReader, error) {
+	typ := reflect.TypeOf(sample)
+	if typ == nil {
+		return nil, xerrors.New("sample can't be nil")
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	size := binary.Size(reflect.New(typ).Interface())
+	if size <= 0 {
+		return nil, xerrors.Errorf("type %s doesn't have a fixed size", typ)
+	}
+
+	if opts.Buffer < 0 {
+		return nil, xerrors.New("buffer can't be negative")
+	}
+
+	switch opts.Backpressure {
+	case Block, DropNewest, DropOldest:
+	default:
+		return nil, xerrors.Errorf("unknown backpressure %d", opts.Backpressure)
+	}
+
+	if opts.BTF != nil && opts.Struct != "" {
+		s, err := findStruct(opts.BTF, opts.Struct)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := verifyLayout(typ, s); err != nil {
+			return nil, xerrors.Errorf("type %s doesn't match struct %s: %w", typ, opts.Struct, err)
+		}
+	}
+
+	tr := &TypedReader{
+		rd:      rd,
+		typ:     typ,
+		size:    size,
+		opts:    opts,
+		records: make(chan TypedRecord, opts.Buffer),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go tr.run()
+	return tr, nil
+}
+
+// Records returns the channel on which decoded records are delivered.
+//
+// The channel is closed once the TypedReader stops, see Err.
+func (tr *TypedReader) Records() <-chan TypedRecord {
+	return tr.records
+}
+
+// Err returns the error which stopped the TypedReader.
+//
+// It returns nil if the reader was closed, and must only be called
+// after the channel returned by Records has been closed.
+func (tr *TypedReader) Err() error {
+	return tr.err
+}
+
+// Dropped returns the number of samples discarded due to backpressure.
+func (tr *TypedReader) Dropped() uint64 {
+	return atomic.LoadUint64(&tr.dropped)
+}
+
+// LostSamples returns the number of samples lost in the kernel, including
+// those from records which were dropped due to backpressure.
+func (tr *TypedReader) LostSamples() uint64 {
+	return atomic.LoadUint64(&tr.lost)
+}
+
+// Close stops reading and closes the underlying RecordReader.
+func (tr *TypedReader) Close() error {
+	var err error
+	tr.closeOnce.Do(func() {
+		close(tr.done)
+		err = tr.rd.Close()
+		<-tr.stopped
+	})
+	return err
+}
+
+func (tr *TypedReader) run() {
+	defer close(tr.stopped)
+	defer close(tr.records)
+
+	for {
+		record, err := tr.rd.Read()
+		if err != nil {
+			if !IsClosed(err) {
+				tr.err = err
+			}
+			return
+		}
+
+		atomic.AddUint64(&tr.lost, record.LostSamples)
+
+		typed, err := tr.decode(record)
+		if err != nil {
+			tr.err = err
+			return
+		}
+
+		if !tr.deliver(typed) {
+			return
+		}
+	}
+}
+
+func (tr *TypedReader) decode(record Record) (TypedRecord, error) {
+	typed := TypedRecord{
+		CPU:         record.CPU,
+		LostSamples: record.LostSamples,
+	}
+	if record.LostSamples > 0 {
+		return typed, nil
+	}
+
+	// Samples may be padded, so only reject short ones.
+	if len(record.RawSample) < tr.size {
+		return TypedRecord{}, xerrors.Errorf("sample of %d bytes is shorter than %s (%d bytes)", len(record.RawSample), tr.typ, tr.size)
+	}
+
+	value := reflect.New(tr.typ).Interface()
+	rd := bytes.NewReader(record.RawSample[:tr.size])
+	if err := binary.Read(rd, internal.NativeEndian, value); err != nil {
+		return TypedRecord{}, xerrors.Errorf("can't decode sample: %w", err)
+	}
+
+	typed.Value = value
+	return typed, nil
+}
+
+// deliver sends a record to the consumer, and returns false if the
+// reader was closed.
+func (tr *TypedReader) deliver(typed TypedRecord) bool {
+	switch tr.opts.Backpressure {
+	case DropNewest:
+		select {
+		case tr.records <- typed:
+		case <-tr.done:
+			return false
+		default:
+			atomic.AddUint64(&tr.dropped, 1)
+		}
+		return true
+
+	case DropOldest:
+		for {
+			select {
+			case tr.records <- typed:
+				return true
+			case <-tr.done:
+				return false
+			default:
+			}
+
+			select {
+			case <-tr.records:
+				atomic.AddUint64(&tr.dropped, 1)
+			default:
+			}
+		}
+
+	default:
+		select {
+		case tr.records <- typed:
+			return true
+		case <-tr.done:
+			return false
+		}
+	}
+}
+
+// findStruct looks up a struct in the BTF of any program or map in spec.
+func findStruct(spec *ebpf.CollectionSpec, name string) (*btf.Struct, error) {
+	var specs []*btf.Spec
+	for _, prog := range spec.Programs {
+		if prog.BTF != nil {
+			specs = append(specs, btf.ProgramSpec(prog.BTF))
+		}
+	}
+	for _, m := range spec.Maps {
+		if m.BTF != nil {
+			specs = append(specs, btf.MapSpec(m.BTF))
+		}
+	}
+
+	for _, s := range specs {
+		var typ btf.Struct
+		err := s.FindType(name, &typ)
+		if xerrors.Is(err, btf.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &typ, nil
+	}
+
+	return nil, xerrors.Errorf("struct %s: %w", name, btf.ErrNotFound)
+}
+
+// verifyLayout checks that decoding typ with encoding/binary yields the
+// same offsets and sizes as the members of s.
+//
+// Fields named _ are treated as padding. Nested types are only compared
+// by size.
+func verifyLayout(typ reflect.Type, s *btf.Struct) error {
+	if typ.Kind() != reflect.Struct {
+		return xerrors.Errorf("%s is not a struct", typ)
+	}
+
+	size := binary.Size(reflect.New(typ).Interface())
+	if size != int(s.Size) {
+		return xerrors.Errorf("size %d doesn't match C size %d", size, s.Size)
+	}
+
+	var (
+		offset  int
+		members = s.Members
+	)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldSize := binary.Size(reflect.New(field.Type).Interface())
+
+		if field.Name != "_" {
+			if len(members) == 0 {
+				return xerrors.Errorf("field %s has no corresponding member", field.Name)
+			}
+
+			member := members[0]
+			members = members[1:]
+
+			if member.Offset%8 != 0 || member.BitfieldSize > 0 {
+				return xerrors.Errorf("member %s: bitfields are not supported", member.Name)
+			}
+
+			if int(member.Offset/8) != offset {
+				return xerrors.Errorf("field %s is at offset %d, member %s at %d", field.Name, offset, member.Name, member.Offset/8)
+			}
+
+			memberSize, err := btf.Sizeof(member.Type)
+			if err != nil {
+				return xerrors.Errorf("member %s: %w", member.Name, err)
+			}
+
+			if fieldSize != memberSize {
+				return xerrors.Errorf("field %s has size %d, member %s has size %d", field.Name, fieldSize, member.Name, memberSize)
+			}
+		}
+
+		offset += fieldSize
+	}
+
+	if len(members) > 0 {
+		return xerrors.Errorf("member %s has no corresponding field", members[0].Name)
+	}
+
+	return nil
+}
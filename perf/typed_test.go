@@ -0,0 +1,208 @@
+package perf
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal/btf"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestTypedReader(t *testing.T) {
+	prog, events := mustOutputSamplesProg(t, 5)
+	defer prog.Close()
+	defer events.Close()
+
+	rd, err := NewReader(events, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type sample struct {
+		Data  [4]uint8
+		Count uint8
+	}
+
+	tr, err := NewTypedReader(rd, sample{}, TypedReaderOptions{})
+	if err != nil {
+		rd.Close()
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	ret, _, err := prog.Test(make([]byte, 14))
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errno := syscall.Errno(-int32(ret)); errno != 0 {
+		t.Fatal("Expected 0 as return value, got", errno)
+	}
+
+	select {
+	case record := <-tr.Records():
+		value, ok := record.Value.(*sample)
+		if !ok {
+			t.Fatalf("Expected *sample, got %T", record.Value)
+		}
+		if *value != (sample{[4]uint8{1, 2, 3, 4}, 4}) {
+			t.Error("Sample doesn't match expected output:", *value)
+		}
+	case <-time.After(readTimeout):
+		t.Fatal("Timed out waiting for a sample")
+	}
+
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := <-tr.Records(); ok {
+		t.Error("Channel isn't closed")
+	}
+	if err := tr.Err(); err != nil {
+		t.Error("Close causes an error:", err)
+	}
+}
+
+type fakeRecordReader struct {
+	records chan Record
+	closed  chan struct{}
+}
+
+func newFakeRecordReader(records ...Record) *fakeRecordReader {
+	rd := &fakeRecordReader{make(chan Record, len(records)), make(chan struct{})}
+	for _, record := range records {
+		rd.records <- record
+	}
+	return rd
+}
+
+func (rd *fakeRecordReader) Read() (Record, error) {
+	select {
+	case record := <-rd.records:
+		return record, nil
+	case <-rd.closed:
+		return Record{}, errClosed
+	}
+}
+
+func (rd *fakeRecordReader) Close() error {
+	close(rd.closed)
+	return nil
+}
+
+func TestTypedReaderBackpressure(t *testing.T) {
+	records := []Record{
+		{RawSample: []byte{1}},
+		{RawSample: []byte{2}},
+		{RawSample: []byte{3}},
+	}
+
+	for policy, want := range map[Backpressure]uint8{
+		DropNewest: 1,
+		DropOldest: 3,
+	} {
+		rd := newFakeRecordReader(records...)
+		tr, err := NewTypedReader(rd, uint8(0), TypedReaderOptions{
+			Buffer:       1,
+			Backpressure: policy,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for tr.Dropped() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+
+		record := <-tr.Records()
+		if value := *record.Value.(*uint8); value != want {
+			t.Errorf("Policy %d: expected sample %d, got %d", policy, want, value)
+		}
+
+		tr.Close()
+	}
+}
+
+func TestTypedReaderShortSample(t *testing.T) {
+	rd := newFakeRecordReader(Record{RawSample: []byte{1, 2}})
+	tr, err := NewTypedReader(rd, uint32(0), TypedReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	if _, ok := <-tr.Records(); ok {
+		t.Fatal("Received a record for a short sample")
+	}
+	if tr.Err() == nil {
+		t.Error("Short sample doesn't cause an error")
+	}
+}
+
+func TestVerifyLayout(t *testing.T) {
+	u8 := &btf.Int{Size: 1}
+	u32 := &btf.Int{Size: 4}
+
+	// struct { __u8 a; __u32 b; }
+	s := &btf.Struct{
+		Size: 8,
+		Members: []btf.Member{
+			{Name: "a", Type: u8, Offset: 0},
+			{Name: "b", Type: u32, Offset: 32},
+		},
+	}
+
+	type padded struct {
+		A uint8
+		_ [3]byte
+		B uint32
+	}
+	if err := verifyLayout(reflect.TypeOf(padded{}), s); err != nil {
+		t.Error("Explicitly padded struct is rejected:", err)
+	}
+
+	type unpadded struct {
+		A uint8
+		B uint32
+	}
+	if err := verifyLayout(reflect.TypeOf(unpadded{}), s); err == nil {
+		t.Error("Struct without padding is accepted")
+	}
+
+	type wrongSize struct {
+		A uint8
+		_ [3]byte
+		B uint16
+		_ uint16
+	}
+	if err := verifyLayout(reflect.TypeOf(wrongSize{}), s); err == nil {
+		t.Error("Field of wrong size is accepted")
+	}
+}
+
+func TestTypedReaderLostSamples(t *testing.T) {
+	rd := newFakeRecordReader(
+		Record{RawSample: []byte{1}},
+		Record{LostSamples: 2},
+	)
+	tr, err := NewTypedReader(rd, uint8(0), TypedReaderOptions{
+		Buffer:       1,
+		Backpressure: DropNewest,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	for tr.Dropped() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if n := tr.LostSamples(); n != 2 {
+		t.Error("Expected 2 lost samples, got", n)
+	}
+}
@@ -0,0 +1,404 @@
+package ebpf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/sys"
+
+	"golang.org/x/xerrors"
+)
+
+// PinGCOptions control RemoveOrphanedPins.
+type PinGCOptions struct {
+	// Pattern selects the pins which may be removed, as understood by
+	// filepath.Match. It is matched against the path of the pin relative
+	// to the directory, for example "myagent_*" or "*/prog_*".
+	//
+	// Pattern is required, since removing pins which weren't created by
+	// the caller may break other applications. Use "*" to consider all
+	// pins at the top of the directory.
+	Pattern string
+	// DryRun returns the orphaned pins without removing them.
+	DryRun bool
+}
+
+// RemoveOrphanedPins removes pins of programs and maps from a directory
+// in bpffs which aren't in use anymore, for example because the agent
+// which attached them crashed. It returns the paths of the removed pins.
+//
+// A program is in use if a bpf_link attaches it, or if it is in a
+// ProgramArray used by a program which is in use, since it may be the
+// target of a tail call. A map is in use if a program which isn't
+// orphaned uses it, directly or via a map of maps. Pinned links are
+// always in use, and files which aren't BPF objects are ignored.
+//
+// Programs attached without bpf_link, for example via netlink or legacy
+// cgroup attachment, look like they aren't in use. Use opts.Pattern to
+// restrict removal to pins which follow a naming convention of objects
+// attached via bpf_link.
+//
+// Removing a pin doesn't destroy the object while file descriptors or
+// other pins refer to it.
+//
+// Requires at least Linux 5.8.
+func RemoveOrphanedPins(dir string, opts PinGCOptions) ([]string, error) {
+	if opts.Pattern == "" {
+		return nil, xerrors.New("a pattern is required")
+	}
+	if _, err := filepath.Match(opts.Pattern, ""); err != nil {
+		return nil, xerrors.Errorf("invalid pattern %q: %w", opts.Pattern, err)
+	}
+
+	pins, err := readPins(dir, opts.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, pin := range pins {
+			pin.fd.Close()
+		}
+	}()
+
+	attached, err := attachedPrograms()
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := tailCallTargets(attached)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []string
+	orphanProgs := make(map[uint32]bool)
+	for _, pin := range pins {
+		if pin.kind == "bpf-prog" && !live[pin.id] {
+			orphans = append(orphans, pin.path)
+			orphanProgs[pin.id] = true
+		}
+	}
+
+	usedMaps, err := usedMaps(orphanProgs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pin := range pins {
+		if pin.kind == "bpf-map" && !usedMaps[pin.id] {
+			orphans = append(orphans, pin.path)
+		}
+	}
+
+	if opts.DryRun {
+		return orphans, nil
+	}
+
+	var removed []string
+	for _, path := range orphans {
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+
+	return removed, nil
+}
+
+// pinnedObject is a pin in bpffs.
+type pinnedObject struct {
+	path string
+	// kind is the name of the anonymous inode, for example bpf-map.
+	kind string
+	id   uint32
+	fd   *internal.FD
+}
+
+// readPins returns all pinned objects below dir which match pattern.
+func readPins(dir, pattern string) ([]*pinnedObject, error) {
+	var pins []*pinnedObject
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if ok, _ := filepath.Match(pattern, rel); !ok {
+			return nil
+		}
+
+		fd, err := internal.BPFObjGet(path)
+		if xerrors.Is(err, unix.EACCES) {
+			// Not a BPF object, for example a symlink to a regular file.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		pin, err := newPinnedObject(path, fd)
+		if err != nil {
+			fd.Close()
+			return xerrors.Errorf("%s: %w", path, err)
+		}
+
+		pins = append(pins, pin)
+		return nil
+	})
+	if err != nil {
+		for _, pin := range pins {
+			pin.fd.Close()
+		}
+		return nil, xerrors.Errorf("read pins: %w", err)
+	}
+
+	return pins, nil
+}
+
+func newPinnedObject(path string, fd *internal.FD) (*pinnedObject, error) {
+	value, err := fd.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	// The link target looks like anon_inode:bpf-map.
+	target, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", value))
+	if err != nil {
+		return nil, err
+	}
+
+	pin := &pinnedObject{path: path, kind: strings.TrimPrefix(target, "anon_inode:"), fd: fd}
+	switch pin.kind {
+	case "bpf-prog":
+		info, err := bpfGetProgInfoByFD(fd)
+		if err != nil {
+			return nil, err
+		}
+		pin.id = info.id
+
+	case "bpf-map":
+		info, err := bpfGetMapInfoByFD(fd)
+		if err != nil {
+			return nil, err
+		}
+		pin.id = info.id
+	}
+
+	return pin, nil
+}
+
+// attachedPrograms returns the IDs of all programs attached by a
+// bpf_link.
+func attachedPrograms() (map[uint32]bool, error) {
+	progs := make(map[uint32]bool)
+	for id := uint32(0); ; {
+		var err error
+		id, err = objGetNextID(sys.BPF_LINK_GET_NEXT_ID, id)
+		if xerrors.Is(err, ErrNotExist) {
+			return progs, nil
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("list links: %w", err)
+		}
+
+		fd, err := bpfObjGetFDByID(sys.BPF_LINK_GET_FD_BY_ID, id)
+		if xerrors.Is(err, ErrNotExist) {
+			// The link was destroyed concurrently.
+			continue
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("link %d: %w", id, err)
+		}
+
+		var info sys.LinkInfo
+		err = bpfGetObjectInfoByFD(fd, unsafe.Pointer(&info), unsafe.Sizeof(info))
+		fd.Close()
+		if err != nil {
+			return nil, xerrors.Errorf("link %d: %w", id, err)
+		}
+
+		progs[info.ProgID] = true
+	}
+}
+
+// tailCallTargets returns progs and all programs which they may reach
+// via tail calls, by following the ProgramArrays they use.
+func tailCallTargets(progs map[uint32]bool) (map[uint32]bool, error) {
+	result := make(map[uint32]bool, len(progs))
+	queue := make([]uint32, 0, len(progs))
+	for id := range progs {
+		result[id] = true
+		queue = append(queue, id)
+	}
+
+	visitedMaps := make(map[uint32]bool)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		fd, err := bpfObjGetFDByID(sys.BPF_PROG_GET_FD_BY_ID, id)
+		if xerrors.Is(err, ErrNotExist) {
+			// The program was unloaded concurrently.
+			continue
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("program %d: %w", id, err)
+		}
+
+		mapIDs, err := bpfGetProgMapIDs(fd)
+		fd.Close()
+		if err != nil {
+			return nil, xerrors.Errorf("program %d: %w", id, err)
+		}
+
+		for _, mapID := range mapIDs {
+			if visitedMaps[mapID] {
+				continue
+			}
+			visitedMaps[mapID] = true
+
+			targets, err := mapValueIDs(mapID, ProgramArray)
+			if err != nil {
+				return nil, xerrors.Errorf("program %d: map %d: %w", id, mapID, err)
+			}
+
+			for _, target := range targets {
+				if !result[target] {
+					result[target] = true
+					queue = append(queue, target)
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// mapValueIDs returns the IDs of the programs or maps stored in a map,
+// if it has one of the given types.
+//
+// Returns nil for other types of maps.
+func mapValueIDs(id uint32, types ...MapType) ([]uint32, error) {
+	m, err := NewMapFromID(MapID(id))
+	if xerrors.Is(err, ErrNotExist) {
+		// The map was destroyed concurrently.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	var found bool
+	for _, typ := range types {
+		found = found || m.ABI().Type == typ
+	}
+	if !found {
+		return nil, nil
+	}
+
+	// Looking up an entry of a ProgramArray or a map of maps from user
+	// space returns the ID of the program or map.
+	var (
+		key     []byte
+		valueID uint32
+		ids     []uint32
+	)
+	entries := m.Iterate()
+	for entries.Next(&key, &valueID) {
+		ids = append(ids, valueID)
+	}
+	if err := entries.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// usedMaps returns the IDs of all maps used by programs, except the ones
+// in ignore.
+func usedMaps(ignore map[uint32]bool) (map[uint32]bool, error) {
+	maps := make(map[uint32]bool)
+	for id := uint32(0); ; {
+		var err error
+		id, err = objGetNextID(sys.BPF_PROG_GET_NEXT_ID, id)
+		if xerrors.Is(err, ErrNotExist) {
+			return maps, nil
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("list programs: %w", err)
+		}
+
+		if ignore[id] {
+			continue
+		}
+
+		fd, err := bpfObjGetFDByID(sys.BPF_PROG_GET_FD_BY_ID, id)
+		if xerrors.Is(err, ErrNotExist) {
+			// The program was unloaded concurrently.
+			continue
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("program %d: %w", id, err)
+		}
+
+		ids, err := bpfGetProgMapIDs(fd)
+		fd.Close()
+		if err != nil {
+			return nil, xerrors.Errorf("program %d: %w", id, err)
+		}
+
+		for _, mapID := range ids {
+			if maps[mapID] {
+				continue
+			}
+			maps[mapID] = true
+
+			// Maps in a map of maps are used by the same programs.
+			// They can't contain maps of maps themselves.
+			inner, err := mapValueIDs(mapID, ArrayOfMaps, HashOfMaps)
+			if err != nil {
+				return nil, xerrors.Errorf("program %d: map %d: %w", id, mapID, err)
+			}
+
+			for _, innerID := range inner {
+				maps[innerID] = true
+			}
+		}
+	}
+}
+
+// bpfGetProgMapIDs returns the IDs of the maps used by a program.
+func bpfGetProgMapIDs(fd *internal.FD) ([]uint32, error) {
+	info, err := bpfGetProgInfoByFD(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.nrMapIDs == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint32, info.nrMapIDs)
+	info = &bpfProgInfo{
+		nrMapIDs: uint32(len(ids)),
+		mapIds:   internal.NewPointer(unsafe.Pointer(&ids[0])),
+	}
+	if err := bpfGetObjectInfoByFD(fd, unsafe.Pointer(info), unsafe.Sizeof(*info)); err != nil {
+		return nil, xerrors.Errorf("can't get map IDs: %w", err)
+	}
+
+	// The program may use fewer maps by now.
+	return ids[:info.nrMapIDs], nil
+}
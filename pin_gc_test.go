@@ -0,0 +1,235 @@
+package ebpf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/sys"
+)
+
+func TestRemoveOrphanedPins(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF_LINK_GET_NEXT_ID")
+
+	tmp, err := ioutil.TempDir("/sys/fs/bpf", "ebpf-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	unused := createArray(t)
+	defer unused.Close()
+
+	used := createArray(t)
+	defer used.Close()
+
+	// The program isn't pinned, but uses the map.
+	user, err := NewProgram(&ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.LoadMapPtr(asm.R1, used.FD()),
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer user.Close()
+
+	// The program is pinned, but not attached.
+	prog := createSocketFilter(t)
+	defer prog.Close()
+
+	pin := func(obj interface{ Pin(string) error }, name string) string {
+		t.Helper()
+		path := filepath.Join(tmp, name)
+		if err := obj.Pin(path); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	unusedPath := pin(unused, "agent_unused")
+	pin(used, "agent_used")
+	progPath := pin(prog, "agent_prog")
+	otherPath := pin(unused, "other_unused")
+
+	orphans, err := RemoveOrphanedPins(tmp, PinGCOptions{Pattern: "agent_*", DryRun: true})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't find orphaned pins:", err)
+	}
+
+	sort.Strings(orphans)
+	if len(orphans) != 2 || orphans[0] != progPath || orphans[1] != unusedPath {
+		t.Fatal("Expected program and unused map to be orphaned, got", orphans)
+	}
+
+	if _, err := os.Stat(progPath); err != nil {
+		t.Fatal("Dry run removed a pin:", err)
+	}
+
+	removed, err := RemoveOrphanedPins(tmp, PinGCOptions{Pattern: "agent_*"})
+	if err != nil {
+		t.Fatal("Can't remove orphaned pins:", err)
+	}
+	if len(removed) != 2 {
+		t.Fatal("Expected two removed pins, got", removed)
+	}
+
+	for _, path := range removed {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Error("Pin wasn't removed:", path)
+		}
+	}
+
+	if _, err := os.Stat(otherPath); err != nil {
+		t.Error("Pin not matching the pattern was removed:", err)
+	}
+
+	if _, err := RemoveOrphanedPins(tmp, PinGCOptions{Pattern: "["}); err == nil {
+		t.Error("Invalid pattern doesn't return an error")
+	}
+
+	if _, err := RemoveOrphanedPins(tmp, PinGCOptions{}); err == nil {
+		t.Error("Empty pattern doesn't return an error")
+	}
+}
+
+func TestRemoveOrphanedPinsTailCall(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF_LINK_GET_NEXT_ID")
+
+	tmp, err := ioutil.TempDir("/sys/fs/bpf", "ebpf-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	arr := createProgramArray(t)
+	defer arr.Close()
+
+	target, err := NewProgram(&ProgramSpec{
+		Type: RawTracepoint,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	if err := arr.Put(uint32(0), target); err != nil {
+		t.Fatal(err)
+	}
+
+	caller, err := NewProgram(&ProgramSpec{
+		Type: RawTracepoint,
+		Instructions: asm.Instructions{
+			asm.LoadMapPtr(asm.R2, arr.FD()),
+			asm.Mov.Imm(asm.R3, 0),
+			asm.FnTailCall.Call(),
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer caller.Close()
+
+	// Attach the caller via a raw tracepoint link, since the link
+	// package can't be imported here.
+	link, err := sys.RawTracepointOpen(&sys.RawTracepointOpenAttr{
+		Name:   sys.NewStringPointer("sys_enter"),
+		ProgFD: uint32(caller.FD()),
+	})
+	if err != nil {
+		t.Fatal("Can't attach caller:", err)
+	}
+	defer link.Close()
+
+	if err := target.Pin(filepath.Join(tmp, "agent_target")); err != nil {
+		t.Fatal(err)
+	}
+	if err := arr.Pin(filepath.Join(tmp, "agent_array")); err != nil {
+		t.Fatal(err)
+	}
+
+	orphans, err := RemoveOrphanedPins(tmp, PinGCOptions{Pattern: "agent_*", DryRun: true})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't find orphaned pins:", err)
+	}
+	if len(orphans) != 0 {
+		t.Fatal("Expected tail call target and its array to be in use, got", orphans)
+	}
+}
+
+func TestRemoveOrphanedPinsMapInMap(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF_LINK_GET_NEXT_ID")
+
+	tmp, err := ioutil.TempDir("/sys/fs/bpf", "ebpf-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	outer := createMapInMap(t, HashOfMaps)
+	defer outer.Close()
+
+	inner := createArray(t)
+	defer inner.Close()
+
+	if err := outer.Put(uint32(0), inner); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := NewProgram(&ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.LoadMapPtr(asm.R1, outer.FD()),
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer user.Close()
+
+	if err := inner.Pin(filepath.Join(tmp, "agent_inner")); err != nil {
+		t.Fatal(err)
+	}
+
+	// bpffs allows symlinks, which may point at other files.
+	file, err := ioutil.TempFile("", "ebpf-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	if err := os.Symlink(file.Name(), filepath.Join(tmp, "agent_symlink")); err != nil {
+		t.Fatal(err)
+	}
+
+	orphans, err := RemoveOrphanedPins(tmp, PinGCOptions{Pattern: "agent_*", DryRun: true})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't find orphaned pins:", err)
+	}
+	if len(orphans) != 0 {
+		t.Fatal("Expected map in used map of maps to be in use, got", orphans)
+	}
+}
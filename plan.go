@@ -0,0 +1,318 @@
+package ebpf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/btf"
+	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/sys"
+
+	"golang.org/x/xerrors"
+)
+
+// Plan lists the bpf syscalls which loading a CollectionSpec issues.
+type Plan struct {
+	Steps []PlanStep
+}
+
+// PlanStep is a bpf syscall issued while loading a CollectionSpec.
+type PlanStep struct {
+	Cmd sys.Cmd
+	// Name of the map or program in the CollectionSpec. For BTF, the name
+	// of the first map or program using it.
+	Name string
+	// Details summarises the attributes of the syscall.
+	Details string
+	// Err is not nil if the step is known to fail, for example because
+	// the kernel lacks a feature.
+	Err error
+}
+
+func (ps PlanStep) String() string {
+	var sb strings.Builder
+	sb.WriteString(ps.Cmd.String())
+	if ps.Name != "" {
+		fmt.Fprintf(&sb, " %s", ps.Name)
+	}
+	if ps.Details != "" {
+		fmt.Fprintf(&sb, " (%s)", ps.Details)
+	}
+	if ps.Err != nil {
+		fmt.Fprintf(&sb, ": %s", ps.Err)
+	}
+	return sb.String()
+}
+
+func (p *Plan) String() string {
+	var sb strings.Builder
+	for _, step := range p.Steps {
+		sb.WriteString(step.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Err returns the error of the first step which is known to fail.
+func (p *Plan) Err() error {
+	for _, step := range p.Steps {
+		if step.Err != nil {
+			return xerrors.Errorf("%s %s: %w", step.Cmd, step.Name, step.Err)
+		}
+	}
+	return nil
+}
+
+// Plan returns the bpf syscalls which NewCollectionWithOptions issues to
+// load the spec, without creating any maps or programs. This is useful to
+// check a spec in CI, or to review what an object file does.
+//
+// References to maps are resolved, attach targets are looked up and
+// feature probes are run, which may create short lived objects. Missing
+// features are reported via PlanStep.Err, other problems with the spec
+// are returned as an error. The kernel may still reject a map or a
+// program when the collection is loaded, for example if the verifier
+// rejects a program.
+//
+// Programs are planned in lexical order, and maps are planned when they
+// are first referenced. The initial contents of a map are written in a
+// single step. Loading a collection doesn't attach programs, the steps
+// loading a program mention the attach type and target instead.
+//
+// opts may be nil. MapReplacements don't issue syscalls.
+func (cs *CollectionSpec) Plan(opts *CollectionOptions) (*Plan, error) {
+	if opts == nil {
+		opts = &CollectionOptions{}
+	}
+
+	// Checks options, limits and signatures without creating objects.
+	if _, err := newCollectionLoader(cs, opts); err != nil {
+		return nil, err
+	}
+
+	cp := &collectionPlanner{
+		coll:     cs,
+		opts:     opts,
+		btfs:     make(map[*btf.Spec]bool),
+		maps:     make(map[string]bool),
+		programs: make(map[string]bool),
+		plan:     new(Plan),
+	}
+
+	progNames := make([]string, 0, len(cs.Programs))
+	for name := range cs.Programs {
+		progNames = append(progNames, name)
+	}
+	sort.Strings(progNames)
+
+	for _, name := range progNames {
+		if opts.ProgramFilter != nil && !opts.ProgramFilter(name) {
+			continue
+		}
+
+		if err := cp.planProgram(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.ProgramFilter == nil {
+		mapNames := make([]string, 0, len(cs.Maps))
+		for name := range cs.Maps {
+			mapNames = append(mapNames, name)
+		}
+		sort.Strings(mapNames)
+
+		for _, name := range mapNames {
+			if err := cp.planMap(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return cp.plan, nil
+}
+
+// collectionPlanner mirrors collectionLoader, recording steps instead of
+// creating objects.
+type collectionPlanner struct {
+	coll     *CollectionSpec
+	opts     *CollectionOptions
+	btfs     map[*btf.Spec]bool
+	maps     map[string]bool
+	programs map[string]bool
+	plan     *Plan
+}
+
+func (cp *collectionPlanner) add(cmd sys.Cmd, name, details string, err error) {
+	cp.plan.Steps = append(cp.plan.Steps, PlanStep{cmd, name, details, err})
+}
+
+func (cp *collectionPlanner) planBTF(spec *btf.Spec, name string) {
+	if cp.btfs[spec] {
+		return
+	}
+	cp.btfs[spec] = true
+
+	// Loading continues without BTF if the kernel doesn't support it,
+	// without issuing a syscall.
+	err := btf.HaveBTF()
+	if err != nil && !cp.opts.RequireBTF {
+		return
+	}
+
+	cp.add(sys.BPF_BTF_LOAD, name, "", err)
+}
+
+func (cp *collectionPlanner) planMap(mapName string) error {
+	if cp.maps[mapName] {
+		return nil
+	}
+
+	mapSpec := cp.coll.Maps[mapName]
+	if mapSpec == nil {
+		return xerrors.Errorf("missing map %s", mapName)
+	}
+	cp.maps[mapName] = true
+
+	if _, ok := cp.opts.MapReplacements[mapName]; ok {
+		return nil
+	}
+
+	if mapSpec.BTF != nil {
+		cp.planBTF(btf.MapSpec(mapSpec.BTF), mapName)
+	}
+
+	var contents, deferred []MapKV
+	for _, kv := range mapSpec.Contents {
+		if name, _ := programReference(kv.Value); name != "" {
+			deferred = append(deferred, kv)
+		} else {
+			contents = append(contents, kv)
+		}
+	}
+
+	if mapSpec.Type == ArrayOfMaps || mapSpec.Type == HashOfMaps {
+		if mapSpec.InnerMap == nil {
+			return xerrors.Errorf("map %s: %s requires InnerMap", mapName, mapSpec.Type)
+		}
+
+		details, err := mapSpec.InnerMap.planCreate()
+		cp.add(sys.BPF_MAP_CREATE, mapName, "inner map template, "+details, err)
+	}
+
+	details, err := mapSpec.planCreate()
+	cp.add(sys.BPF_MAP_CREATE, mapName, details, err)
+
+	if len(contents) > 0 {
+		cp.add(sys.BPF_MAP_UPDATE_ELEM, mapName, fmt.Sprintf("%d entries", len(contents)), nil)
+	}
+
+	if mapSpec.Freeze && len(deferred) == 0 {
+		cp.add(sys.BPF_MAP_FREEZE, mapName, "", nil)
+	}
+
+	if len(deferred) == 0 {
+		return nil
+	}
+
+	for _, kv := range deferred {
+		progName, _ := programReference(kv.Value)
+		if err := cp.planProgram(progName); err != nil {
+			return xerrors.Errorf("map %s: %w", mapName, err)
+		}
+	}
+
+	cp.add(sys.BPF_MAP_UPDATE_ELEM, mapName, fmt.Sprintf("%d program entries", len(deferred)), nil)
+
+	if mapSpec.Freeze {
+		cp.add(sys.BPF_MAP_FREEZE, mapName, "", nil)
+	}
+
+	return nil
+}
+
+// planCreate returns a summary of the attributes used to create the map,
+// and the first missing feature.
+func (spec *MapSpec) planCreate() (string, error) {
+	abi := newMapABIFromSpec(spec)
+
+	var err error
+	switch spec.Type {
+	case ArrayOfMaps, HashOfMaps:
+		err = haveNestedMaps()
+
+	case PerfEventArray:
+		if abi.MaxEntries == 0 {
+			n, cpuErr := internal.OnlineCPUs()
+			if cpuErr != nil {
+				err = xerrors.Errorf("perf event array: %w", cpuErr)
+			}
+			abi.MaxEntries = uint32(n)
+		}
+
+	case SkStorage, InodeStorage, TaskStorage, CgroupStorage:
+		err = haveLocalStorage[spec.Type]()
+	}
+
+	if err == nil && (abi.Flags&(unix.BPF_F_RDONLY_PROG|unix.BPF_F_WRONLY_PROG) > 0 || spec.Freeze) {
+		err = haveMapMutabilityModifiers()
+	}
+
+	details := fmt.Sprintf("type=%s key=%d value=%d entries=%d flags=%#x",
+		abi.Type, abi.KeySize, abi.ValueSize, abi.MaxEntries, abi.Flags)
+	return details, err
+}
+
+func (cp *collectionPlanner) planProgram(progName string) error {
+	if cp.programs[progName] {
+		return nil
+	}
+
+	progSpec := cp.coll.Programs[progName]
+	if progSpec == nil {
+		return xerrors.Errorf("missing program %s", progName)
+	}
+	cp.programs[progName] = true
+
+	for _, ins := range progSpec.Instructions {
+		if ins.OpCode != asm.LoadImmOp(asm.DWord) || ins.Reference == "" {
+			continue
+		}
+
+		if uint32(ins.Constant) != math.MaxUint32 {
+			continue
+		}
+
+		if err := cp.planMap(ins.Reference); err != nil {
+			return xerrors.Errorf("program %s: %w", progName, err)
+		}
+	}
+
+	if progSpec.BTF != nil {
+		cp.planBTF(btf.ProgramSpec(progSpec.BTF), progName)
+	}
+
+	// Performs the same checks and lookups as loading the program, but
+	// without BTF, which doesn't exist yet.
+	_, target, err := convertProgramSpec(progSpec, nil)
+	target.close()
+	if err != nil && !xerrors.Is(err, ErrNotSupported) {
+		return xerrors.Errorf("program %s: %w", progName, err)
+	}
+
+	cp.add(sys.BPF_PROG_LOAD, progName, progSpec.planDetails(), err)
+	return nil
+}
+
+func (ps *ProgramSpec) planDetails() string {
+	details := fmt.Sprintf("type=%s attach=%d insns=%d license=%q",
+		ps.Type, ps.AttachType, ps.Instructions.Size()/asm.InstructionSize, ps.License)
+	if ps.AttachTo != "" {
+		details += fmt.Sprintf(" target=%s", ps.AttachTo)
+	}
+	return details
+}
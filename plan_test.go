@@ -0,0 +1,155 @@
+package ebpf
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/sys"
+)
+
+func TestCollectionSpecPlan(t *testing.T) {
+	insns := asm.Instructions{
+		asm.LoadMapPtr(asm.R1, 0),
+		asm.LoadImm(asm.R0, 0, asm.DWord),
+		asm.Return(),
+	}
+	insns[0].Reference = "my_map"
+	insns[0].Constant = math.MaxUint32
+
+	cs := &CollectionSpec{
+		Maps: map[string]*MapSpec{
+			"my_map": {
+				Name:       "my_map",
+				Type:       Array,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 1,
+				Contents:   []MapKV{{uint32(0), uint32(42)}},
+			},
+			"unused": {
+				Name:       "unused",
+				Type:       Hash,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 1,
+			},
+		},
+		Programs: map[string]*ProgramSpec{
+			"test": {
+				Name:         "test",
+				Type:         SocketFilter,
+				Instructions: insns,
+				License:      "MIT",
+			},
+		},
+	}
+
+	var created []string
+	sys.SetAuditHook(func(ar sys.AuditRecord) {
+		if ar.Cmd == sys.BPF_MAP_CREATE || ar.Cmd == sys.BPF_PROG_LOAD {
+			created = append(created, ar.Attr)
+		}
+	})
+	defer sys.SetAuditHook(nil)
+
+	plan, err := cs.Plan(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sys.SetAuditHook(nil)
+	for _, attr := range created {
+		if strings.Contains(attr, `"my_map"`) || strings.Contains(attr, `"unused"`) || strings.Contains(attr, `"test"`) {
+			t.Error("Plan created an object:", attr)
+		}
+	}
+
+	if err := plan.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		cmd  sys.Cmd
+		name string
+	}{
+		{sys.BPF_MAP_CREATE, "my_map"},
+		{sys.BPF_MAP_UPDATE_ELEM, "my_map"},
+		{sys.BPF_PROG_LOAD, "test"},
+		{sys.BPF_MAP_CREATE, "unused"},
+	}
+
+	if len(plan.Steps) != len(want) {
+		t.Fatalf("Expected %d steps, got:\n%s", len(want), plan)
+	}
+
+	for i, step := range plan.Steps {
+		if step.Cmd != want[i].cmd || step.Name != want[i].name {
+			t.Errorf("Step %d: expected %s %s, got %s", i, want[i].cmd, want[i].name, step)
+		}
+	}
+
+	plan, err = cs.Plan(&CollectionOptions{
+		ProgramFilter: func(name string) bool { return false },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Steps) != 0 {
+		t.Errorf("Filtered plan has steps:\n%s", plan)
+	}
+}
+
+func TestCollectionSpecPlanELF(t *testing.T) {
+	spec, err := LoadCollectionSpec("testdata/loader-clang-8.elf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.Maps["array_of_hash_map"].InnerMap = spec.Maps["hash_map"]
+	spec.Maps["hash_of_hash_map"].InnerMap = spec.Maps["hash_map2"]
+
+	plan, err := spec.Plan(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var progs int
+	for _, step := range plan.Steps {
+		if step.Cmd == sys.BPF_PROG_LOAD {
+			progs++
+		}
+	}
+
+	if progs != len(spec.Programs) {
+		t.Errorf("Expected %d programs, got:\n%s", len(spec.Programs), plan)
+	}
+
+	if err := plan.Err(); err != nil {
+		t.Error(err)
+	}
+
+	t.Log(plan)
+}
+
+func TestCollectionSpecPlanAttachTo(t *testing.T) {
+	cs := &CollectionSpec{
+		Programs: map[string]*ProgramSpec{
+			"test": {
+				Name:       "test",
+				Type:       Tracing,
+				AttachType: AttachTraceFEntry,
+				AttachTo:   "ebpf_go_missing_function",
+				Instructions: asm.Instructions{
+					asm.Mov.Imm(asm.R0, 0),
+					asm.Return(),
+				},
+				License: "MIT",
+			},
+		},
+	}
+
+	if _, err := cs.Plan(nil); err == nil {
+		t.Error("Plan doesn't look up the attach target")
+	}
+}
@@ -1,17 +1,21 @@
 package ebpf
 
 import (
-	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"math"
 	"strings"
 	"time"
-	"unsafe"
 
 	"github.com/cilium/ebpf/asm"
 	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/btf"
 	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/sys"
 
 	"golang.org/x/xerrors"
 )
@@ -29,6 +33,10 @@ const (
 	outputPad = 256 + 2
 )
 
+// KernelVersionCurrent is a placeholder for ProgramSpec.KernelVersion,
+// which some ELF loaders use to request the version of the running kernel.
+const KernelVersionCurrent = 0xFFFFFFFE
+
 // DefaultVerifierLogSize is the default number of bytes allocated for the
 // verifier log.
 const DefaultVerifierLogSize = 64 * 1024
@@ -41,23 +49,116 @@ type ProgramOptions struct {
 	// Controls the output buffer size for the verifier. Defaults to
 	// DefaultVerifierLogSize.
 	LogSize int
+	// SplitLargePrograms retries loading a program which the kernel
+	// rejects with E2BIG after moving repeated sections into bpf-to-bpf
+	// functions. The outcome is available via Program.Split.
+	//
+	// The kernel returns E2BIG if a program has more instructions than
+	// it allows, before verifying it. Splitting only reduces the
+	// instruction count: the verifier still explores the same paths, so
+	// it doesn't help programs which exceed the verifier's complexity
+	// limit.
+	SplitLargePrograms bool
+	// RequireBTF fails loading a program if its BTF, func infos or line
+	// infos are rejected by the kernel. By default, the program is loaded
+	// without them instead.
+	RequireBTF bool
+}
+
+// SplitResult describes how a program was split to reduce its size.
+type SplitResult struct {
+	// Cause is the error returned when loading the unmodified program.
+	Cause error
+	// Instruction counts before and after splitting.
+	Before, After int
+	// Functions which were created by splitting.
+	Functions []asm.OutlinedFunction
 }
 
 // ProgramSpec defines a Program
 type ProgramSpec struct {
 	// Name is passed to the kernel as a debug aid. Must only contain
 	// alpha numeric and '_' characters.
-	Name          string
-	Type          ProgramType
-	AttachType    AttachType
-	Instructions  asm.Instructions
-	License       string
+	Name         string
+	Type         ProgramType
+	AttachType   AttachType
+	Instructions asm.Instructions
+
+	// License of the program, for example "GPL" or "Dual MIT/GPL". Only
+	// programs with a GPL compatible license may call some functions,
+	// see asm.BuiltinFunc.GPLOnly.
+	License string
+
+	// KernelVersion is checked against the running kernel when loading
+	// Kprobe programs on kernels before 5.0. Zero or KernelVersionCurrent
+	// use the version of the running kernel, or zero if it can't be
+	// detected.
 	KernelVersion uint32
 
-	// The BTF associated with this program. Changing Instructions
-	// will most likely invalidate the contained data, and may
-	// result in errors when attempting to load it into the kernel.
+	// Name of a kernel data structure to attach to. Its interpretation
+	// depends on Type and AttachType.
+	//
+	// Tracing programs attach to functions in kernel modules if vmlinux
+	// doesn't contain the function. Use "module:function" to choose
+	// the module, which requires at least Linux 5.11.
+	AttachTo string
+
+	// AttachTarget is the program an Extension replaces a function of.
+	// AttachTo is then the name of the function.
+	AttachTarget *Program
+
+	// Flags is passed to the kernel and specifies additional program
+	// load attributes, for example BPF_F_XDP_HAS_FRAGS.
+	Flags uint32
+
+	// The BTF associated with this program. Line info is taken from
+	// the Source of each instruction, but changing Instructions will
+	// most likely invalidate function info, and may result in errors
+	// when attempting to load it into the kernel.
 	BTF *btf.Program
+
+	// Ifindex offloads the program to the network device with this
+	// index. Only XDP and SchedCLS programs can be offloaded.
+	Ifindex uint32
+}
+
+// gplCompatibleLicenses are the licenses the kernel considers GPL
+// compatible, see license_is_gpl_compatible in include/linux/license.h.
+var gplCompatibleLicenses = map[string]bool{
+	"GPL":                       true,
+	"GPL v2":                    true,
+	"GPL and additional rights": true,
+	"Dual BSD/GPL":              true,
+	"Dual MIT/GPL":              true,
+	"Dual MPL/GPL":              true,
+}
+
+// GPLCompatible returns true if the kernel considers the license of the
+// program to be compatible with the GPL.
+func (ps *ProgramSpec) GPLCompatible() bool {
+	return gplCompatibleLicenses[ps.License]
+}
+
+// checkLicense returns an error if the program calls a function which
+// requires a GPL compatible license without having one.
+//
+// The kernel rejects such programs with a generic error.
+func (ps *ProgramSpec) checkLicense() error {
+	if ps.GPLCompatible() {
+		return nil
+	}
+
+	for i, ins := range ps.Instructions {
+		if ins.OpCode.JumpOp() != asm.Call || ins.Src == asm.PseudoCall {
+			continue
+		}
+
+		if fn := asm.BuiltinFunc(ins.Constant); fn.GPLOnly() {
+			return xerrors.Errorf("instruction %d: %s requires a GPL compatible license, not %q", i, fn, ps.License)
+		}
+	}
+
+	return nil
 }
 
 // Copy returns a copy of the spec.
@@ -72,6 +173,92 @@ func (ps *ProgramSpec) Copy() *ProgramSpec {
 	return &cpy
 }
 
+// UnresolvedRelocations returns the relocations which have to be
+// resolved before the program can be loaded, keyed by instruction index.
+//
+// Map relocations are resolved by NewCollection, everything else
+// has to be resolved by the caller. Weak kernel symbols and externs
+// which aren't resolved load zero.
+func (ps *ProgramSpec) UnresolvedRelocations() map[int]asm.Relocation {
+	return ps.Instructions.UnresolvedRelocations()
+}
+
+// ResolveRelocation resolves all relocations of symbol to value.
+//
+// See asm.Instruction.ResolveRelocation for the meaning of value.
+func (ps *ProgramSpec) ResolveRelocation(symbol string, value int64) error {
+	return ps.Instructions.ResolveRelocation(symbol, value)
+}
+
+// Tag calculates the kernel tag for a series of instructions.
+//
+// It is computed the same way as by the running kernel, so it can be
+// compared with Program.Tag to find out whether a loaded program was
+// created from this spec. Linux 6.18 switched the tag from SHA1 to
+// SHA256.
+func (ps *ProgramSpec) Tag() (string, error) {
+	v, err := internal.KernelVersion()
+	if err != nil {
+		return "", xerrors.Errorf("can't detect kernel version: %w", err)
+	}
+
+	h := sha1.New()
+	if !v.Less(internal.Version{6, 18}) {
+		h = sha256.New()
+	}
+
+	if err := ps.Instructions.Hash(h, internal.NativeEndian); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)[:unix.BPF_TAG_SIZE]), nil
+}
+
+// Digest writes the parts of the spec which determine the behaviour of
+// the program to h, for example to compute or check a signature.
+//
+// This covers the type, the attach type and target, the license, the
+// instructions and the symbols and references of instructions, which
+// determine the maps and functions used by the program. File descriptors
+// of maps are ignored, since they differ between loads. The result doesn't
+// depend on the byte order of the host.
+//
+// The maps used by the program aren't covered, including the constants
+// in .rodata. Use CollectionSpec.Digest to cover them as well.
+func (ps *ProgramSpec) Digest(h hash.Hash) error {
+	insns := make(asm.Instructions, len(ps.Instructions))
+	copy(insns, ps.Instructions)
+	for i := range insns {
+		ins := &insns[i]
+		if ins.OpCode == asm.LoadImmOp(asm.DWord) && (ins.Src == asm.PseudoMapFD || ins.Src == asm.PseudoMapValue) {
+			ins.Constant &^= math.MaxUint32
+		}
+	}
+
+	if err := binary.Write(h, binary.LittleEndian, []uint32{uint32(ps.Type), uint32(ps.AttachType)}); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(h, "%s\x00%s\x00", ps.AttachTo, ps.License); err != nil {
+		return err
+	}
+
+	if err := insns.Marshal(h, binary.LittleEndian); err != nil {
+		return err
+	}
+
+	for i, ins := range insns {
+		if ins.Symbol == "" && ins.Reference == "" {
+			continue
+		}
+
+		if _, err := fmt.Fprintf(h, "%d\x00%s\x00%s\x00", i, ins.Symbol, ins.Reference); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Program represents BPF program loaded into the kernel.
 //
 // It is not safe to close a Program which is used by other goroutines.
@@ -79,6 +266,10 @@ type Program struct {
 	// Contains the output of the kernel verifier if enabled,
 	// otherwise it is empty.
 	VerifierLog string
+	// Split is non-nil if the program was split into functions
+	// to stay below the instruction limit. See
+	// ProgramOptions.SplitLargePrograms.
+	Split *SplitResult
 
 	fd   *internal.FD
 	name string
@@ -98,23 +289,72 @@ func NewProgram(spec *ProgramSpec) (*Program, error) {
 // Loading a program for the first time will perform
 // feature detection by loading small, temporary programs.
 func NewProgramWithOptions(spec *ProgramSpec, opts ProgramOptions) (*Program, error) {
-	if spec.BTF == nil {
-		return newProgramWithBTF(spec, nil, opts)
+	var handle *btf.Handle
+	if spec.BTF != nil {
+		var err error
+		handle, err = btf.NewHandle(btf.ProgramSpec(spec.BTF))
+		if err != nil && opts.RequireBTF {
+			return nil, xerrors.Errorf("can't load BTF: %w", err)
+		}
+	}
+
+	return newProgramWithSplit(spec, handle, opts)
+}
+
+// newProgramWithSplit loads a program, and splits it if the kernel
+// rejects it as too large and opts.SplitLargePrograms is set.
+func newProgramWithSplit(spec *ProgramSpec, handle *btf.Handle, opts ProgramOptions) (*Program, error) {
+	prog, err := newProgramWithBTF(spec, handle, opts)
+	if err != nil && opts.SplitLargePrograms && xerrors.Is(err, unix.E2BIG) {
+		internal.Debug("Program is too large, splitting it", "program", spec.Name)
+		return newSplitProgram(spec, opts, err)
+	}
+	return prog, err
+}
+
+// minSplitLength is the shortest section moved into a function when
+// splitting programs.
+const minSplitLength = 2
+
+func newSplitProgram(spec *ProgramSpec, opts ProgramOptions, cause error) (*Program, error) {
+	insns, fns, err := spec.Instructions.Outline(minSplitLength)
+	if err != nil {
+		return nil, xerrors.Errorf("can't split program: %w", err)
 	}
 
-	handle, err := btf.NewHandle(btf.ProgramSpec(spec.BTF))
-	if err != nil && !xerrors.Is(err, btf.ErrNotSupported) {
-		return nil, xerrors.Errorf("can't load BTF: %w", err)
+	if len(fns) == 0 {
+		return nil, cause
 	}
 
-	return newProgramWithBTF(spec, handle, opts)
+	split := spec.Copy()
+	split.Instructions = insns
+	// Function and line infos don't match the new layout.
+	split.BTF = nil
+
+	prog, err := newProgramWithBTF(split, nil, opts)
+	if err != nil {
+		return nil, xerrors.Errorf("can't load split program: %w", err)
+	}
+
+	prog.Split = &SplitResult{
+		cause,
+		len(spec.Instructions),
+		len(insns),
+		fns,
+	}
+	return prog, nil
 }
 
 func newProgramWithBTF(spec *ProgramSpec, btf *btf.Handle, opts ProgramOptions) (*Program, error) {
-	attr, err := convertProgramSpec(spec, btf)
+	attr, target, err := convertProgramSpec(spec, btf)
 	if err != nil {
 		return nil, err
 	}
+	if target != nil {
+		// The BTF of a kernel module must stay open until the program
+		// is loaded.
+		defer target.close()
+	}
 
 	logSize := DefaultVerifierLogSize
 	if opts.LogSize > 0 {
@@ -124,27 +364,56 @@ func newProgramWithBTF(spec *ProgramSpec, btf *btf.Handle, opts ProgramOptions)
 	var logBuf []byte
 	if opts.LogLevel > 0 {
 		logBuf = make([]byte, logSize)
-		attr.logLevel = opts.LogLevel
-		attr.logSize = uint32(len(logBuf))
-		attr.logBuf = internal.NewSlicePointer(logBuf)
+		attr.LogLevel = opts.LogLevel
+		attr.LogSize = uint32(len(logBuf))
+		attr.LogBuf = sys.NewSlicePointer(logBuf)
+	}
+
+	fd, err := progLoad(attr)
+	if err != nil && isBTFRejection(err) && attr.ProgBTFFD != 0 && !opts.RequireBTF {
+		// The kernel may not understand the BTF, func infos or line
+		// infos of the program. Try without them.
+		withoutBTF := *attr
+		withoutBTF.ProgBTFFD = 0
+		withoutBTF.FuncInfoRecSize, withoutBTF.FuncInfo, withoutBTF.FuncInfoCnt = 0, sys.Pointer{}, 0
+		withoutBTF.LineInfoRecSize, withoutBTF.LineInfo, withoutBTF.LineInfoCnt = 0, sys.Pointer{}, 0
+
+		// Keep the log of the first attempt in case the retry fails
+		// as well.
+		var logBufWithoutBTF []byte
+		if opts.LogLevel > 0 {
+			logBufWithoutBTF = make([]byte, logSize)
+			withoutBTF.LogSize = uint32(len(logBufWithoutBTF))
+			withoutBTF.LogBuf = sys.NewSlicePointer(logBufWithoutBTF)
+		}
+
+		if fdWithoutBTF, errWithoutBTF := progLoad(&withoutBTF); errWithoutBTF == nil {
+			internal.Debug("Kernel rejected BTF, loaded program without it", "program", spec.Name, "error", err)
+			fd, err = fdWithoutBTF, nil
+			logBuf = logBufWithoutBTF
+		}
 	}
 
-	fd, err := bpfProgLoad(attr)
 	if err == nil {
 		prog := newProgram(fd, spec.Name, &ProgramABI{spec.Type})
 		prog.VerifierLog = internal.CString(logBuf)
 		return prog, nil
 	}
 
+	required, unprivileged := spec.Type.capabilities()
+	if permErr := wrapPermissionError(fmt.Sprintf("loading a program of type %s", spec.Type), required, unprivileged, err); permErr != err {
+		return nil, xerrors.Errorf("can't load program: %w", permErr)
+	}
+
 	logErr := err
 	if opts.LogLevel == 0 {
 		// Re-run with the verifier enabled to get better error messages.
 		logBuf = make([]byte, logSize)
-		attr.logLevel = 1
-		attr.logSize = uint32(len(logBuf))
-		attr.logBuf = internal.NewSlicePointer(logBuf)
+		attr.LogLevel = 1
+		attr.LogSize = uint32(len(logBuf))
+		attr.LogBuf = sys.NewSlicePointer(logBuf)
 
-		_, logErr = bpfProgLoad(attr)
+		_, logErr = progLoad(attr)
 	}
 
 	err = internal.ErrorWithLog(err, logBuf, logErr)
@@ -171,6 +440,28 @@ func NewProgramFromFD(fd int) (*Program, error) {
 	return newProgram(bpfFd, name, abi), nil
 }
 
+// NewProgramFromBorrowedFD creates a program from a raw fd which remains
+// owned by the caller.
+//
+// The fd is duplicated, so the caller may close it once this function
+// returns.
+//
+// Requires at least Linux 4.11.
+func NewProgramFromBorrowedFD(fd int) (*Program, error) {
+	bpfFd, err := internal.NewFDFromBorrowed(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	name, abi, err := newProgramABIFromFd(bpfFd)
+	if err != nil {
+		bpfFd.Close()
+		return nil, err
+	}
+
+	return newProgram(bpfFd, name, abi), nil
+}
+
 func newProgram(fd *internal.FD, name string, abi *ProgramABI) *Program {
 	return &Program{
 		name: name,
@@ -179,56 +470,124 @@ func newProgram(fd *internal.FD, name string, abi *ProgramABI) *Program {
 	}
 }
 
-func convertProgramSpec(spec *ProgramSpec, handle *btf.Handle) (*bpfProgLoadAttr, error) {
+// convertProgramSpec returns the attributes to load spec with.
+//
+// The returned target is non-nil if the program attaches to a kernel
+// type, and must be closed after loading the program.
+func convertProgramSpec(spec *ProgramSpec, handle *btf.Handle) (*sys.ProgLoadAttr, *btfTarget, error) {
 	if len(spec.Instructions) == 0 {
-		return nil, xerrors.New("Instructions cannot be empty")
+		return nil, nil, xerrors.New("Instructions cannot be empty")
 	}
 
 	if len(spec.License) == 0 {
-		return nil, xerrors.New("License cannot be empty")
+		return nil, nil, xerrors.New("License cannot be empty")
+	}
+
+	if err := spec.checkLicense(); err != nil {
+		return nil, nil, err
+	}
+
+	// Weak kernel symbols and externs load zero if they aren't resolved.
+	for i, ins := range spec.Instructions {
+		rel := ins.Relocation()
+		if rel == nil || rel.Weak {
+			continue
+		}
+		if rel.Kind == asm.KsymRelocation || rel.Kind == asm.ExternRelocation {
+			return nil, nil, xerrors.Errorf("instruction %d: unresolved %s", i, rel)
+		}
 	}
 
-	buf := bytes.NewBuffer(make([]byte, 0, len(spec.Instructions)*asm.InstructionSize))
-	err := spec.Instructions.Marshal(buf, internal.NativeEndian)
+	bytecode := make([]byte, spec.Instructions.Size())
+	err := spec.Instructions.MarshalTo(bytecode, internal.NativeEndian)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	bytecode := buf.Bytes()
 	insCount := uint32(len(bytecode) / asm.InstructionSize)
-	attr := &bpfProgLoadAttr{
-		progType:           spec.Type,
-		expectedAttachType: spec.AttachType,
-		insCount:           insCount,
-		instructions:       internal.NewSlicePointer(bytecode),
-		license:            internal.NewStringPointer(spec.License),
+	attr := &sys.ProgLoadAttr{
+		ProgType:           sys.ProgType(spec.Type),
+		ExpectedAttachType: sys.AttachType(spec.AttachType),
+		InsnCnt:            insCount,
+		Insns:              sys.NewSlicePointer(bytecode),
+		License:            sys.NewStringPointer(spec.License),
+		ProgFlags:          spec.Flags,
+		ProgIfindex:        spec.Ifindex,
+		KernVersion:        spec.KernelVersion,
+	}
+
+	if spec.Type == Kprobe && (spec.KernelVersion == 0 || spec.KernelVersion == KernelVersionCurrent) {
+		// Kernels since 5.0 ignore the version, so a failed detection
+		// only matters on older ones, which reject the program.
+		attr.KernVersion = 0
+		if v, err := internal.KernelVersion(); err == nil {
+			attr.KernVersion = v.Kernel()
+		} else {
+			internal.Debug("Can't detect kernel version, using zero", "program", spec.Name, "error", err)
+		}
 	}
 
 	if haveObjName() == nil {
-		attr.progName = newBPFObjName(spec.Name)
+		attr.ProgName = newBPFObjName(spec.Name)
+	}
+
+	if spec.AttachTarget != nil {
+		targetFd, err := spec.AttachTarget.fd.Value()
+		if err != nil {
+			return nil, nil, xerrors.Errorf("attach target: %w", err)
+		}
+
+		target, err := resolveProgramBTFType(spec.AttachTarget, spec.AttachTo)
+		if err != nil {
+			return nil, nil, err
+		}
+		attr.AttachProgFD = targetFd
+		attr.AttachBTFID = uint32(target.ID())
+	}
+
+	var target *btfTarget
+	if spec.AttachTarget == nil && spec.AttachTo != "" {
+		var err error
+		target, err = resolveBTFType(spec.AttachTo, spec.Type, spec.AttachType)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if target != nil {
+		attr.AttachBTFID = uint32(target.id)
+		if target.module != nil {
+			// attach_btf_obj_fd shares its field with attach_prog_fd.
+			attr.AttachProgFD, err = target.module.Value()
+			if err != nil {
+				target.close()
+				return nil, nil, err
+			}
+		}
 	}
 
 	if handle != nil && spec.BTF != nil {
-		attr.progBTFFd = uint32(handle.FD())
+		attr.ProgBTFFD = uint32(handle.FD())
 
-		recSize, bytes, err := btf.ProgramLineInfos(spec.BTF)
+		recSize, bytes, err := btf.ProgramLineInfos(spec.BTF, spec.Instructions)
 		if err != nil {
-			return nil, xerrors.Errorf("can't get BTF line infos: %w", err)
+			target.close()
+			return nil, nil, xerrors.Errorf("can't get BTF line infos: %w", err)
 		}
-		attr.lineInfoRecSize = recSize
-		attr.lineInfoCnt = uint32(uint64(len(bytes)) / uint64(recSize))
-		attr.lineInfo = internal.NewSlicePointer(bytes)
+		attr.LineInfoRecSize = recSize
+		attr.LineInfoCnt = uint32(uint64(len(bytes)) / uint64(recSize))
+		attr.LineInfo = sys.NewSlicePointer(bytes)
 
 		recSize, bytes, err = btf.ProgramFuncInfos(spec.BTF)
 		if err != nil {
-			return nil, xerrors.Errorf("can't get BTF function infos: %w", err)
+			target.close()
+			return nil, nil, xerrors.Errorf("can't get BTF function infos: %w", err)
 		}
-		attr.funcInfoRecSize = recSize
-		attr.funcInfoCnt = uint32(uint64(len(bytes)) / uint64(recSize))
-		attr.funcInfo = internal.NewSlicePointer(bytes)
+		attr.FuncInfoRecSize = recSize
+		attr.FuncInfoCnt = uint32(uint64(len(bytes)) / uint64(recSize))
+		attr.FuncInfo = sys.NewSlicePointer(bytes)
 	}
 
-	return attr, nil
+	return attr, target, nil
 }
 
 func (p *Program) String() string {
@@ -275,17 +634,132 @@ func (p *Program) Clone() (*Program, error) {
 	return newProgram(dup, p.name, &p.abi), nil
 }
 
+// Spec returns a ProgramSpec reconstructed from the information the
+// kernel exposes about the program.
+//
+// Instructions are returned as rewritten by the verifier. References
+// to maps are encoded as map IDs instead of file descriptors, and calls
+// to helpers may have been inlined, so the spec usually has to be
+// modified before it can be loaded again. AttachType, KernelVersion
+// and BTF are not available.
+//
+// Requires at least Linux 4.13, and CAP_SYS_ADMIN to retrieve
+// instructions.
+func (p *Program) Spec() (*ProgramSpec, error) {
+	info, err := bpfGetProgInfoByFD(p.fd)
+	if err != nil {
+		return nil, err
+	}
+
+	insns, err := p.XlatedInstructions()
+	if err != nil {
+		return nil, err
+	}
+
+	var license string
+	if info.gplCompatible&1 != 0 {
+		license = "GPL"
+	}
+
+	return &ProgramSpec{
+		Name:         internal.CString(info.name[:]),
+		Type:         ProgramType(info.progType),
+		Instructions: insns,
+		License:      license,
+		Ifindex:      info.ifindex,
+	}, nil
+}
+
+// XlatedInstructions returns the instructions of the program as
+// rewritten by the verifier.
+//
+// Loads of maps refer to them by map ID instead of file descriptor.
+// Requires CAP_SYS_ADMIN, otherwise the kernel may not return
+// any instructions.
+func (p *Program) XlatedInstructions() (asm.Instructions, error) {
+	buf, err := bpfGetProgInstructionsByFD(p.fd)
+	if err != nil {
+		return nil, xerrors.Errorf("program %s: %w", p, err)
+	}
+
+	var (
+		insns asm.Instructions
+		dec   = asm.NewDecoder(buf, internal.NativeEndian)
+	)
+	for dec.Next() {
+		insns = append(insns, dec.Ins)
+	}
+	if err := dec.Err(); err != nil {
+		return nil, xerrors.Errorf("program %s: can't unmarshal instructions: %w", p, err)
+	}
+
+	return insns, nil
+}
+
+// JITedImage is the machine code generated for a program.
+type JITedImage struct {
+	// Code of all functions of the program, in order.
+	Code []byte
+	// Functions of the program, starting with the main program.
+	Functions []JITedFunction
+}
+
+// JITedFunction is a bpf-to-bpf function in a JITedImage.
+type JITedFunction struct {
+	// Address of the function in the kernel, as shown in /proc/kallsyms.
+	// Zero if the kernel doesn't expose addresses to the caller.
+	Address uint64
+	// Code of the function, which is a sub-slice of JITedImage.Code.
+	Code []byte
+}
+
+// JITedImage returns the machine code generated by the kernel JIT.
+//
+// Returns an error wrapping ErrNotSupported if the JIT is disabled.
+// Requires CAP_SYS_ADMIN.
+func (p *Program) JITedImage() (*JITedImage, error) {
+	code, ksyms, funcLens, err := bpfGetProgJITedImageByFD(p.fd)
+	if err != nil {
+		return nil, xerrors.Errorf("program %s: %w", p, err)
+	}
+
+	image := &JITedImage{Code: code}
+	if len(funcLens) == 0 {
+		// Kernels before 4.18 don't return function boundaries.
+		return image, nil
+	}
+
+	var offset uint32
+	for i, n := range funcLens {
+		if uint64(offset)+uint64(n) > uint64(len(code)) {
+			return nil, xerrors.Errorf("program %s: function %d exceeds JITed image", p, i)
+		}
+
+		fn := JITedFunction{Code: code[offset : offset+n]}
+		if i < len(ksyms) {
+			fn.Address = ksyms[i]
+		}
+
+		image.Functions = append(image.Functions, fn)
+		offset += n
+	}
+
+	return image, nil
+}
+
 // Pin persists the Program past the lifetime of the process that created it
 //
 // This requires bpffs to be mounted above fileName. See http://cilium.readthedocs.io/en/doc-1.0/kubernetes/install/#mounting-the-bpf-fs-optional
 func (p *Program) Pin(fileName string) error {
-	if err := bpfPinObject(fileName, p.fd); err != nil {
+	if err := internal.BPFObjPin(fileName, p.fd); err != nil {
 		return xerrors.Errorf("can't pin program: %w", err)
 	}
 	return nil
 }
 
 // Close unloads the program from the kernel.
+//
+// It is safe to call Close multiple times.
 func (p *Program) Close() error {
 	if p == nil {
 		return nil
@@ -294,6 +768,39 @@ func (p *Program) Close() error {
 	return p.fd.Close()
 }
 
+// RunOptions control the execution of a Program via Run.
+type RunOptions struct {
+	// Program's data input. Required field.
+	//
+	// The kernel expects at least 14 bytes input for an ethernet header for
+	// XDP and SKB programs.
+	Data []byte
+	// Program's data after Program has run. Caller must allocate. Optional.
+	//
+	// A buffer with space for the output of the verifier-adjusted packet
+	// is allocated if DataOut is nil.
+	DataOut []byte
+	// Program's context input. Optional field.
+	//
+	// Context is marshalled according to the same rules as map keys,
+	// for example a struct xdp_md for XDP programs.
+	Context interface{}
+	// Program's context after Program has run. Must be a pointer or slice. Optional.
+	ContextOut interface{}
+	// Number of times to run Program. Optional, defaults to 1 if zero.
+	Repeat uint32
+	// Optional flags, for example BPF_F_TEST_XDP_LIVE_FRAMES.
+	Flags uint32
+	// CPU to run Program on. Optional field.
+	// Note not all program types support this field, and
+	// BPF_F_TEST_RUN_ON_CPU must be set in Flags.
+	CPU uint32
+	// Number of frames to allocate and transmit in one go when
+	// BPF_F_TEST_XDP_LIVE_FRAMES is set. Optional, the kernel uses a
+	// default of 64 if zero.
+	BatchSize uint32
+}
+
 // Test runs the Program in the kernel with the given input and returns the
 // value returned by the eBPF program. outLen may be zero.
 //
@@ -302,13 +809,49 @@ func (p *Program) Close() error {
 //
 // This function requires at least Linux 4.12.
 func (p *Program) Test(in []byte) (uint32, []byte, error) {
-	ret, out, _, err := p.testRun(in, 1)
+	opts := RunOptions{
+		Data:   in,
+		Repeat: 1,
+	}
+
+	ret, out, _, err := p.testRun(&opts)
 	if err != nil {
 		return ret, nil, xerrors.Errorf("can't test program: %w", err)
 	}
 	return ret, out, nil
 }
 
+// Run runs the Program in the kernel with the given RunOptions and returns
+// the value returned by the eBPF program.
+//
+// Unlike Test, Run gives access to the context of the program, and
+// allows passing flags such as BPF_F_TEST_XDP_LIVE_FRAMES. The output
+// data is written to opts.DataOut, which is truncated to the size
+// reported by the kernel. An error is returned if the output doesn't fit
+// into opts.DataOut.
+//
+// Syscall programs don't take Data, and their context is written to
+// ContextOut. They must be loaded with BPF_F_SLEEPABLE.
+//
+// This function requires at least Linux 4.12. Passing a context requires
+// Linux 5.2, live frames require Linux 5.18 and Syscall programs require
+// Linux 5.14.
+func (p *Program) Run(opts *RunOptions) (uint32, error) {
+	if opts == nil {
+		return 0, xerrors.New("missing options")
+	}
+
+	ret, out, _, err := p.testRun(opts)
+	if err != nil {
+		return ret, xerrors.Errorf("can't run program: %w", err)
+	}
+
+	if opts.DataOut != nil {
+		opts.DataOut = out
+	}
+	return ret, nil
+}
+
 // Benchmark runs the Program with the given input for a number of times
 // and returns the time taken per iteration.
 //
@@ -317,7 +860,16 @@ func (p *Program) Test(in []byte) (uint32, []byte, error) {
 //
 // This function requires at least Linux 4.12.
 func (p *Program) Benchmark(in []byte, repeat int) (uint32, time.Duration, error) {
-	ret, _, total, err := p.testRun(in, repeat)
+	if uint(repeat) > math.MaxUint32 {
+		return 0, 0, fmt.Errorf("repeat is too high")
+	}
+
+	opts := RunOptions{
+		Data:   in,
+		Repeat: uint32(repeat),
+	}
+
+	ret, _, total, err := p.testRun(&opts)
 	if err != nil {
 		return ret, total, xerrors.Errorf("can't benchmark program: %w", err)
 	}
@@ -346,29 +898,26 @@ var haveProgTestRun = internal.FeatureTest("BPF_PROG_TEST_RUN", "4.12", func() b
 
 	// Programs require at least 14 bytes input
 	in := make([]byte, 14)
-	attr := bpfProgTestRunAttr{
-		fd:         fd,
-		dataSizeIn: uint32(len(in)),
-		dataIn:     internal.NewSlicePointer(in),
-	}
-
-	_, err = internal.BPF(_ProgTestRun, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	err = sys.ProgTestRun(&sys.ProgRunAttr{
+		ProgFD:     fd,
+		DataSizeIn: uint32(len(in)),
+		DataIn:     sys.NewSlicePointer(in),
+	})
 
 	// Check for EINVAL specifically, rather than err != nil since we
 	// otherwise misdetect due to insufficient permissions.
 	return !xerrors.Is(err, unix.EINVAL)
 })
 
-func (p *Program) testRun(in []byte, repeat int) (uint32, []byte, time.Duration, error) {
-	if uint(repeat) > math.MaxUint32 {
-		return 0, nil, 0, fmt.Errorf("repeat is too high")
-	}
-
-	if len(in) == 0 {
+func (p *Program) testRun(opts *RunOptions) (uint32, []byte, time.Duration, error) {
+	// Syscall programs only have a context, which the kernel writes
+	// back to the input.
+	isSyscall := p.abi.Type == Syscall
+	if len(opts.Data) == 0 && !isSyscall {
 		return 0, nil, 0, fmt.Errorf("missing input")
 	}
 
-	if uint(len(in)) > math.MaxUint32 {
+	if uint(len(opts.Data)) > math.MaxUint32 {
 		return 0, nil, 0, fmt.Errorf("input is too long")
 	}
 
@@ -376,41 +925,94 @@ func (p *Program) testRun(in []byte, repeat int) (uint32, []byte, time.Duration,
 		return 0, nil, 0, err
 	}
 
-	// Older kernels ignore the dataSizeOut argument when copying to user space.
-	// Combined with things like bpf_xdp_adjust_head() we don't really know what the final
-	// size will be. Hence we allocate an output buffer which we hope will always be large
-	// enough, and panic if the kernel wrote past the end of the allocation.
-	// See https://patchwork.ozlabs.org/cover/1006822/
-	out := make([]byte, len(in)+outputPad)
+	out := opts.DataOut
+	if out == nil && !isSyscall {
+		// Older kernels ignore the dataSizeOut argument when copying to user space.
+		// Combined with things like bpf_xdp_adjust_head() we don't really know what the final
+		// size will be. Hence we allocate an output buffer which we hope will always be large
+		// enough, and panic if the kernel wrote past the end of the allocation.
+		// See https://patchwork.ozlabs.org/cover/1006822/
+		out = make([]byte, len(opts.Data)+outputPad)
+	}
+
+	var ctxIn []byte
+	if opts.Context != nil {
+		var err error
+		ctxIn, err = marshalBytes(opts.Context, binary.Size(opts.Context))
+		if err != nil {
+			return 0, nil, 0, xerrors.Errorf("context: %w", err)
+		}
+	}
+
+	var ctxOut []byte
+	if opts.ContextOut != nil && !isSyscall {
+		size := binary.Size(opts.ContextOut)
+		if size < 0 {
+			return 0, nil, 0, xerrors.Errorf("context out: can't determine size of %T", opts.ContextOut)
+		}
+		ctxOut = make([]byte, size)
+	}
+
+	repeat := opts.Repeat
+	if repeat == 0 && !isSyscall {
+		repeat = 1
+	}
 
 	fd, err := p.fd.Value()
 	if err != nil {
 		return 0, nil, 0, err
 	}
 
-	attr := bpfProgTestRunAttr{
-		fd:          fd,
-		dataSizeIn:  uint32(len(in)),
-		dataSizeOut: uint32(len(out)),
-		dataIn:      internal.NewSlicePointer(in),
-		dataOut:     internal.NewSlicePointer(out),
-		repeat:      uint32(repeat),
+	attr := sys.ProgRunAttr{
+		ProgFD:      fd,
+		DataSizeIn:  uint32(len(opts.Data)),
+		DataSizeOut: uint32(len(out)),
+		DataIn:      sys.NewSlicePointer(opts.Data),
+		DataOut:     sys.NewSlicePointer(out),
+		Repeat:      repeat,
+		CtxSizeIn:   uint32(len(ctxIn)),
+		CtxSizeOut:  uint32(len(ctxOut)),
+		CtxIn:       sys.NewSlicePointer(ctxIn),
+		CtxOut:      sys.NewSlicePointer(ctxOut),
+		Flags:       opts.Flags,
+		CPU:         opts.CPU,
+		BatchSize:   opts.BatchSize,
 	}
 
-	_, err = internal.BPF(_ProgTestRun, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	err = sys.ProgTestRun(&attr)
+	if internal.IsNotSupported(err) {
+		return 0, nil, 0, internal.SyscallError(ErrNotSupported, err)
+	}
+	if opts.DataOut != nil && int(attr.DataSizeOut) > len(opts.DataOut) && (err == nil || xerrors.Is(err, unix.ENOSPC)) {
+		// Newer kernels truncate the output and return ENOSPC, older
+		// ones don't notice.
+		return 0, nil, 0, xerrors.Errorf("data out: output of %d bytes doesn't fit into %d bytes", attr.DataSizeOut, len(opts.DataOut))
+	}
 	if err != nil {
 		return 0, nil, 0, xerrors.Errorf("can't run test: %w", err)
 	}
 
-	if int(attr.dataSizeOut) > cap(out) {
+	if opts.DataOut == nil && int(attr.DataSizeOut) > cap(out) {
 		// Houston, we have a problem. The program created more data than we allocated,
 		// and the kernel wrote past the end of our buffer.
 		panic("kernel wrote past end of output buffer")
 	}
-	out = out[:int(attr.dataSizeOut)]
 
-	total := time.Duration(attr.duration) * time.Nanosecond
-	return attr.retval, out, total, nil
+	if int(attr.DataSizeOut) < len(out) {
+		out = out[:int(attr.DataSizeOut)]
+	}
+
+	if opts.ContextOut != nil {
+		if isSyscall {
+			ctxOut, attr.CtxSizeOut = ctxIn, uint32(len(ctxIn))
+		}
+		if err := unmarshalBytes(opts.ContextOut, ctxOut[:attr.CtxSizeOut]); err != nil {
+			return 0, nil, 0, xerrors.Errorf("context out: %w", err)
+		}
+	}
+
+	total := time.Duration(attr.Duration) * time.Nanosecond
+	return attr.Retval, out, total, nil
 }
 
 func unmarshalProgram(buf []byte) (*Program, error) {
@@ -447,14 +1049,12 @@ func (p *Program) Attach(fd int, typ AttachType, flags AttachFlags) error {
 		return err
 	}
 
-	attr := bpfProgAlterAttr{
-		targetFd:    uint32(fd),
-		attachBpfFd: pfd,
-		attachType:  uint32(typ),
-		attachFlags: uint32(flags),
-	}
-
-	return bpfProgAlter(_ProgAttach, &attr)
+	return sys.ProgAttach(&sys.ProgAttachAttr{
+		TargetFD:    uint32(fd),
+		AttachBPFFD: pfd,
+		AttachType:  sys.AttachType(typ),
+		AttachFlags: uint32(flags),
+	})
 }
 
 // Detach a Program from a container object fd
@@ -468,21 +1068,19 @@ func (p *Program) Detach(fd int, typ AttachType, flags AttachFlags) error {
 		return err
 	}
 
-	attr := bpfProgAlterAttr{
-		targetFd:    uint32(fd),
-		attachBpfFd: pfd,
-		attachType:  uint32(typ),
-		attachFlags: uint32(flags),
-	}
-
-	return bpfProgAlter(_ProgDetach, &attr)
+	return sys.ProgDetach(&sys.ProgAttachAttr{
+		TargetFD:    uint32(fd),
+		AttachBPFFD: pfd,
+		AttachType:  sys.AttachType(typ),
+		AttachFlags: uint32(flags),
+	})
 }
 
 // LoadPinnedProgram loads a Program from a BPF file.
 //
 // Requires at least Linux 4.11.
 func LoadPinnedProgram(fileName string) (*Program, error) {
-	fd, err := bpfGetObject(fileName)
+	fd, err := internal.BPFObjGet(fileName)
 	if err != nil {
 		return nil, err
 	}
@@ -496,6 +1094,108 @@ func LoadPinnedProgram(fileName string) (*Program, error) {
 	return newProgram(fd, name, abi), nil
 }
 
+// btfTarget is a kernel type a program attaches to.
+type btfTarget struct {
+	id btf.TypeID
+	// module is the BTF of the kernel module defining the type, or nil
+	// if the type is part of vmlinux.
+	module *internal.FD
+}
+
+func (bt *btfTarget) close() {
+	if bt != nil && bt.module != nil {
+		bt.module.Close()
+	}
+}
+
+// resolveBTFType finds the kernel type a program of the given type
+// attaches to.
+//
+// Functions are searched in kernel modules if vmlinux doesn't contain
+// them, or if name has the form "module:function".
+//
+// Returns a nil target and no error if the program doesn't attach
+// via BTF.
+func resolveBTFType(name string, progType ProgramType, attachType AttachType) (*btfTarget, error) {
+	type match struct {
+		p ProgramType
+		a AttachType
+	}
+
+	var (
+		target   btf.Type
+		typeName string
+	)
+	switch (match{progType, attachType}) {
+	case match{Tracing, AttachTraceRawTp}:
+		typeName = "btf_trace_" + name
+		target = new(btf.Typedef)
+	case match{Tracing, AttachTraceFEntry},
+		match{Tracing, AttachTraceFExit},
+		match{Tracing, AttachModifyReturn}:
+		if i := strings.IndexByte(name, ':'); i != -1 {
+			return resolveModuleFunc(name[:i], name[i+1:])
+		}
+		typeName = name
+		target = new(btf.Func)
+	case match{Tracing, AttachTraceIter}:
+		typeName = "bpf_iter_" + name
+		target = new(btf.Func)
+	default:
+		return nil, nil
+	}
+
+	spec, err := btf.LoadKernelSpec()
+	if err != nil {
+		return nil, xerrors.Errorf("can't resolve BTF type %s: %w", typeName, err)
+	}
+
+	err = spec.FindType(typeName, target)
+	if xerrors.Is(err, btf.ErrNotFound) && typeName == name {
+		return resolveModuleFunc("", name)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("can't resolve BTF type %s: %w", typeName, err)
+	}
+
+	return &btfTarget{id: target.ID()}, nil
+}
+
+// resolveModuleFunc finds a function in a kernel module, or in all
+// loaded modules if module is empty.
+func resolveModuleFunc(module, name string) (*btfTarget, error) {
+	fn, err := btf.FindModuleFunc(module, name)
+	if err != nil {
+		return nil, xerrors.Errorf("can't resolve BTF type %s: %w", name, err)
+	}
+
+	return &btfTarget{fn.ID, fn.BTF}, nil
+}
+
+// resolveProgramBTFType finds the function name in the BTF of prog.
+func resolveProgramBTFType(prog *Program, name string) (btf.Type, error) {
+	info, err := bpfGetProgInfoByFD(prog.fd)
+	if err != nil {
+		return nil, xerrors.Errorf("can't resolve function %s: %w", name, err)
+	}
+
+	if info.btfID == 0 {
+		return nil, xerrors.Errorf("can't resolve function %s: %s has no BTF", name, prog)
+	}
+
+	spec, err := btf.LoadSpecFromID(info.btfID)
+	if err != nil {
+		return nil, xerrors.Errorf("can't resolve function %s: %w", name, err)
+	}
+
+	target := new(btf.Func)
+	if err := spec.FindType(name, target); err != nil {
+		return nil, xerrors.Errorf("can't resolve function %s: %w", name, err)
+	}
+
+	return target, nil
+}
+
 // SanitizeName replaces all invalid characters in name.
 //
 // Use this to automatically generate valid names for maps and
@@ -516,7 +1216,7 @@ func SanitizeName(name string, replacement rune) string {
 //
 // Returns ErrNotExist, if there is no next eBPF program.
 func ProgramGetNextID(startID ProgramID) (ProgramID, error) {
-	id, err := objGetNextID(_ProgGetNextID, uint32(startID))
+	id, err := objGetNextID(sys.BPF_PROG_GET_NEXT_ID, uint32(startID))
 	return ProgramID(id), err
 }
 
@@ -524,7 +1224,7 @@ func ProgramGetNextID(startID ProgramID) (ProgramID, error) {
 //
 // Returns ErrNotExist, if there is no eBPF program with the given id.
 func NewProgramFromID(id ProgramID) (*Program, error) {
-	fd, err := bpfObjGetFDByID(_ProgGetFDByID, uint32(id))
+	fd, err := bpfObjGetFDByID(sys.BPF_PROG_GET_FD_BY_ID, uint32(id))
 	if err != nil {
 		return nil, err
 	}
@@ -538,6 +1238,45 @@ func NewProgramFromID(id ProgramID) (*Program, error) {
 	return newProgram(fd, name, abi), nil
 }
 
+// ProgramStats contains runtime statistics of a program, which the
+// kernel collects while enabled. See EnableStats.
+type ProgramStats struct {
+	// Total accumulated runtime of the program.
+	Runtime time.Duration
+	// Total number of times the program was called.
+	RunCount uint64
+	// Number of times the program wasn't run because it would have
+	// recursed. Always zero before Linux 5.12.
+	RecursionMisses uint64
+}
+
+// Stats returns runtime statistics of the program.
+//
+// Requires at least Linux 5.1.
+func (p *Program) Stats() (*ProgramStats, error) {
+	info, err := bpfGetProgInfoByFD(p.fd)
+	if err != nil {
+		return nil, xerrors.Errorf("program %s: %w", p, err)
+	}
+
+	return &ProgramStats{
+		time.Duration(info.runTimeNs),
+		info.runCnt,
+		info.recursionMisses,
+	}, nil
+}
+
+// Tag returns the tag the kernel calculated for the program.
+//
+// Requires at least Linux 4.13.
+func (p *Program) Tag() (string, error) {
+	info, err := bpfGetProgInfoByFD(p.fd)
+	if err != nil {
+		return "", xerrors.Errorf("program %s: %w", p, err)
+	}
+	return hex.EncodeToString(info.tag[:]), nil
+}
+
 // ID returns the systemwide unique ID of the program.
 func (p *Program) ID() (ProgramID, error) {
 	info, err := bpfGetProgInfoByFD(p.fd)
@@ -2,6 +2,7 @@ package ebpf
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"io/ioutil"
 	"math"
@@ -12,7 +13,9 @@ import (
 
 	"github.com/cilium/ebpf/asm"
 	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/btf"
 	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
 	"golang.org/x/xerrors"
 )
 
@@ -80,6 +83,272 @@ func TestProgramRun(t *testing.T) {
 	}
 }
 
+func TestProgramRunBlinded(t *testing.T) {
+	insns, err := asm.Instructions{
+		asm.Mov.Imm(asm.R0, 0),
+		asm.StoreImm(asm.RFP, -8, 0x11223344, asm.DWord),
+		asm.LoadMem(asm.R1, asm.RFP, -8, asm.DWord),
+		asm.JEq.Imm(asm.R1, 0x11223344, "ok"),
+		asm.Return(),
+		asm.LoadImm(asm.R0, 40, asm.DWord).Sym("ok"),
+		asm.Add.Imm(asm.R0, 2),
+		asm.Return(),
+	}.BlindConstants(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prog, err := NewProgram(&ProgramSpec{
+		Type:         XDP,
+		Instructions: insns,
+		License:      "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	ret, _, err := prog.Test(make([]byte, 14))
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ret != 42 {
+		t.Error("Expected return value to be 42, got", ret)
+	}
+}
+
+func TestProgramRunOutlined(t *testing.T) {
+	var insns asm.Instructions
+	for i := 0; i < 4; i++ {
+		insns = append(insns,
+			// Repeated section, which is outlined.
+			asm.LoadImm(asm.R1, 10, asm.DWord),
+			asm.Mov.Imm(asm.R2, 1),
+			asm.Add.Reg(asm.R1, asm.R2),
+			asm.Mov.Reg(asm.R0, asm.R1),
+			// r0 = 11 + i
+			asm.Add.Imm(asm.R0, int32(i)),
+			asm.StoreMem(asm.RFP, int16(-8*(i+1)), asm.R0, asm.DWord),
+		)
+	}
+	insns = append(insns,
+		asm.LoadMem(asm.R0, asm.RFP, -32, asm.DWord),
+		asm.Return(),
+	)
+
+	outlined, fns, err := insns.Outline(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fns) == 0 {
+		t.Fatal("Nothing was outlined")
+	}
+
+	prog, err := NewProgram(&ProgramSpec{
+		Type:         XDP,
+		Instructions: outlined,
+		License:      "MIT",
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	ret, _, err := prog.Test(make([]byte, 14))
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ret != 14 {
+		t.Error("Expected return value to be 14, got", ret)
+	}
+}
+
+func TestProgramRunWithOptions(t *testing.T) {
+	prog, err := NewProgram(&ProgramSpec{
+		Type: XDP,
+		Instructions: asm.Instructions{
+			// Return XDP_DROP
+			asm.LoadImm(asm.R0, 1, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	// struct xdp_md
+	type xdpMd struct {
+		Data           uint32
+		DataEnd        uint32
+		DataMeta       uint32
+		IngressIfindex uint32
+		RxQueueIndex   uint32
+		EgressIfindex  uint32
+	}
+
+	in := make([]byte, 14)
+	ctxIn := xdpMd{DataEnd: uint32(len(in))}
+	var ctxOut xdpMd
+
+	opts := RunOptions{
+		Data:       in,
+		DataOut:    make([]byte, len(in)),
+		Context:    ctxIn,
+		ContextOut: &ctxOut,
+	}
+
+	ret, err := prog.Run(&opts)
+	if xerrors.Is(err, unix.EINVAL) {
+		t.Skip("Kernel doesn't support xdp_md as context")
+	}
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ret != 1 {
+		t.Error("Expected return value to be 1, got", ret)
+	}
+
+	if len(opts.DataOut) != len(in) {
+		t.Errorf("Expected %d bytes of output, got %d", len(in), len(opts.DataOut))
+	}
+
+	if ctxOut.DataEnd != uint32(len(in)) {
+		t.Error("Context wasn't copied out, got", ctxOut)
+	}
+
+	live := RunOptions{
+		Data:      in,
+		Repeat:    16,
+		Flags:     unix.BPF_F_TEST_XDP_LIVE_FRAMES,
+		BatchSize: 4,
+	}
+
+	_, err = prog.Run(&live)
+	if xerrors.Is(err, unix.EINVAL) {
+		t.Skip("Kernel doesn't support live frames")
+	}
+	if err != nil {
+		t.Fatal("Can't run with live frames:", err)
+	}
+}
+
+func TestProgramRunXDPFrags(t *testing.T) {
+	prog, err := NewProgram(&ProgramSpec{
+		Type: XDP,
+		Instructions: asm.Instructions{
+			// Return XDP_PASS
+			asm.LoadImm(asm.R0, 2, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+		Flags:   unix.BPF_F_XDP_HAS_FRAGS,
+	})
+	if xerrors.Is(err, unix.EINVAL) {
+		t.Skip("Kernel doesn't support XDP frags")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	// Larger than a page, so that the input is split into frags.
+	in := make([]byte, 6000)
+	opts := RunOptions{
+		Data:    in,
+		DataOut: make([]byte, len(in)),
+	}
+
+	ret, err := prog.Run(&opts)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ret != 2 {
+		t.Error("Expected return value to be 2, got", ret)
+	}
+
+	if len(opts.DataOut) != len(in) {
+		t.Errorf("Expected %d bytes of output, got %d", len(in), len(opts.DataOut))
+	}
+}
+
+func TestProgramRunDataOutTooSmall(t *testing.T) {
+	prog, err := NewProgram(&ProgramSpec{
+		Type: XDP,
+		Instructions: asm.Instructions{
+			// Return XDP_PASS
+			asm.LoadImm(asm.R0, 2, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	opts := RunOptions{
+		Data:    make([]byte, 64),
+		DataOut: make([]byte, 32),
+	}
+
+	_, err = prog.Run(&opts)
+	testutils.SkipIfNotSupported(t, err)
+	if err == nil {
+		t.Fatal("Run doesn't return an error if DataOut is too small")
+	}
+	if len(opts.DataOut) != 32 {
+		t.Error("Run modified DataOut on error")
+	}
+}
+
+func TestProgramRunSyscall(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.14", "BPF_PROG_TYPE_SYSCALL")
+
+	prog, err := NewProgram(&ProgramSpec{
+		Type: Syscall,
+		Instructions: asm.Instructions{
+			asm.LoadMem(asm.R0, asm.R1, 4, asm.Word),
+			asm.StoreImm(asm.R1, 0, 7, asm.Word),
+			asm.Return(),
+		},
+		License: "MIT",
+		Flags:   unix.BPF_F_SLEEPABLE,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	ctx := []uint32{0, 42}
+	ret, err := prog.Run(&RunOptions{
+		Context:    ctx,
+		ContextOut: ctx,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ret != 42 {
+		t.Error("Expected return value to be 42, got", ret)
+	}
+
+	if ctx[0] != 7 {
+		t.Error("Expected context to be written back, got", ctx)
+	}
+}
+
 func TestProgramBenchmark(t *testing.T) {
 	prog := createSocketFilter(t)
 	defer prog.Close()
@@ -152,6 +421,36 @@ func TestProgramVerifierOutputOnError(t *testing.T) {
 	}
 }
 
+func TestProgramVerifierOutputWithBTF(t *testing.T) {
+	spec, err := LoadCollectionSpec("testdata/loader-clang-9.elf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prog := spec.Programs["no_relocation"]
+	if prog.BTF == nil {
+		t.Fatal("Program doesn't have BTF")
+	}
+
+	// Reading from beyond the frame pointer is rejected by the verifier,
+	// not because of BTF.
+	invalid := asm.LoadMem(asm.R0, asm.RFP, 8, asm.DWord)
+	invalid.Symbol = prog.Instructions[0].Symbol
+	invalid.Metadata = prog.Instructions[0].Metadata
+	prog.Instructions[0] = invalid
+
+	_, err = NewProgramWithOptions(prog, ProgramOptions{LogLevel: 1})
+	testutils.SkipIfNotSupported(t, err)
+	if err == nil {
+		t.Fatal("Expected program to be invalid")
+	}
+
+	// The log must come from loading with line infos.
+	if !strings.Contains(err.Error(), "return 0;") {
+		t.Error("Verifier output doesn't contain line infos:", err)
+	}
+}
+
 func TestProgramVerifierOutput(t *testing.T) {
 	prog, err := NewProgramWithOptions(socketFilterSpec, ProgramOptions{
 		LogLevel: 2,
@@ -180,9 +479,13 @@ func TestProgramVerifierOutput(t *testing.T) {
 		t.Fatal("Expected an error from invalid program")
 	}
 
-	var ve *internal.VerifierError
+	var ve *VerifierError
 	if !xerrors.As(err, &ve) {
-		t.Error("Error is not a VerifierError")
+		t.Fatal("Error is not a VerifierError")
+	}
+
+	if ve.Log() == "" {
+		t.Error("VerifierError doesn't contain the log")
 	}
 }
 
@@ -228,6 +531,311 @@ func TestProgramCloneNil(t *testing.T) {
 	}
 }
 
+func TestProgramSpec(t *testing.T) {
+	prog := createSocketFilter(t)
+	defer prog.Close()
+
+	spec, err := prog.Spec()
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't get spec:", err)
+	}
+
+	if spec.Type != socketFilterSpec.Type {
+		t.Errorf("Expected type %s, got %s", socketFilterSpec.Type, spec.Type)
+	}
+
+	if haveObjName() == nil && spec.Name != socketFilterSpec.Name {
+		t.Errorf("Expected name %s, got %s", socketFilterSpec.Name, spec.Name)
+	}
+
+	if have, want := spec.Instructions.String(), socketFilterSpec.Instructions.String(); have != want {
+		t.Errorf("Instructions don't match:\n%s\nexpected:\n%s", have, want)
+	}
+}
+
+func TestProgramSpecUnresolvedExtern(t *testing.T) {
+	spec := &ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.LoadImm(asm.R0, 0, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+	}
+
+	rel := &asm.Relocation{Kind: asm.ExternRelocation, Symbol: "maybe_missing"}
+	spec.Instructions[0] = spec.Instructions[0].WithRelocation(rel)
+	if _, err := NewProgram(spec); err == nil {
+		t.Fatal("Loading a program with an unresolved extern doesn't fail")
+	}
+
+	rel.Weak = true
+	prog, err := NewProgram(spec)
+	if err != nil {
+		t.Fatal("Can't load a program with an unresolved weak extern:", err)
+	}
+	defer prog.Close()
+
+	ret, _, err := prog.Test(make([]byte, 14))
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != 0 {
+		t.Error("Unresolved weak extern isn't zero, got", ret)
+	}
+}
+
+func TestProgramSpecLicense(t *testing.T) {
+	spec := &ProgramSpec{
+		Type: Kprobe,
+		Instructions: asm.Instructions{
+			asm.FnGetCurrentTask.Call(),
+			asm.Return(),
+		},
+		License: "Dual MIT/GPL",
+	}
+
+	if !spec.GPLCompatible() {
+		t.Error("Dual MIT/GPL isn't GPL compatible")
+	}
+
+	if err := spec.checkLicense(); err != nil {
+		t.Error("GPL compatible program is rejected:", err)
+	}
+
+	spec.License = "MIT"
+	if spec.GPLCompatible() {
+		t.Error("MIT is GPL compatible")
+	}
+
+	if _, err := NewProgram(spec); err == nil || !strings.Contains(err.Error(), "GetCurrentTask") {
+		t.Error("Calling a GPL-only function with MIT license doesn't fail:", err)
+	}
+}
+
+func TestProgramSpecLicenseNotGPLOnly(t *testing.T) {
+	// bpf_send_signal is available to programs with any license.
+	prog, err := NewProgram(&ProgramSpec{
+		Type: Kprobe,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R1, 0),
+			asm.FnSendSignal.Call(),
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't load non-GPL program calling bpf_send_signal:", err)
+	}
+	prog.Close()
+}
+
+func TestProgramSpecDigest(t *testing.T) {
+	spec := &ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.LoadMapPtr(asm.R1, 1),
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "MIT",
+	}
+	spec.Instructions[0].Reference = "map"
+
+	digest := func(spec *ProgramSpec) string {
+		t.Helper()
+
+		h := sha256.New()
+		if err := spec.Digest(h); err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprintf("%x", h.Sum(nil))
+	}
+
+	want := digest(spec)
+
+	cpy := spec.Copy()
+	cpy.Instructions[0].Constant = 2
+	if digest(cpy) != want {
+		t.Error("Digest depends on the map fd")
+	}
+
+	cpy = spec.Copy()
+	cpy.Instructions[0].Reference = "other"
+	if digest(cpy) == want {
+		t.Error("Digest doesn't depend on references")
+	}
+
+	cpy = spec.Copy()
+	cpy.Instructions[1].Constant = 1
+	if digest(cpy) == want {
+		t.Error("Digest doesn't depend on instructions")
+	}
+
+	cpy = spec.Copy()
+	cpy.Type = XDP
+	if digest(cpy) == want {
+		t.Error("Digest doesn't depend on the program type")
+	}
+
+	cpy = spec.Copy()
+	cpy.AttachType = AttachXDP
+	if digest(cpy) == want {
+		t.Error("Digest doesn't depend on the attach type")
+	}
+
+	cpy = spec.Copy()
+	cpy.AttachTo = "target"
+	if digest(cpy) == want {
+		t.Error("Digest doesn't depend on the attach target")
+	}
+
+	cpy = spec.Copy()
+	cpy.License = "GPL"
+	if digest(cpy) == want {
+		t.Error("Digest doesn't depend on the license")
+	}
+}
+
+func TestProgramTag(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	spec := &ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.LoadMapPtr(asm.R1, m.FD()),
+			asm.LoadImm(asm.R0, math.MaxUint32+1, asm.DWord),
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "MIT",
+	}
+
+	prog, err := NewProgram(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	have, err := prog.Tag()
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't get tag:", err)
+	}
+
+	want, err := spec.Tag()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have != want {
+		t.Errorf("Expected tag %s, got %s", want, have)
+	}
+
+	// The tag doesn't depend on the map fd.
+	spec.Instructions[0] = asm.LoadMapPtr(asm.R1, m.FD()+1)
+	if tag, err := spec.Tag(); err != nil {
+		t.Fatal(err)
+	} else if tag != want {
+		t.Error("Tag depends on map fd")
+	}
+}
+
+func TestProgramXlatedInstructions(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	prog, err := NewProgram(&ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.LoadMapPtr(asm.R1, m.FD()),
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	insns, err := prog.XlatedInstructions()
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := m.ID()
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if insns[0].Src != asm.PseudoMapFD || insns[0].Constant != int64(id) {
+		t.Errorf("Expected load of map ID %d, got %v", id, insns[0])
+	}
+}
+
+func TestProgramJITedImage(t *testing.T) {
+	prog, err := NewProgram(&ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.Call.Label("fn"),
+			asm.Return(),
+			asm.Mov.Imm(asm.R0, 0).Sym("fn"),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	testutils.SkipOnOldKernel(t, "4.16", "bpf2bpf calls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	image, err := prog.JITedImage()
+	if xerrors.Is(err, ErrNotSupported) || xerrors.Is(err, unix.EPERM) {
+		t.Skip("Can't get JITed image:", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(image.Code) == 0 {
+		t.Fatal("JITed image is empty")
+	}
+
+	testutils.SkipOnOldKernel(t, "4.18", "JITed function lengths")
+	if len(image.Functions) != 2 {
+		t.Fatalf("Expected two functions, got %d", len(image.Functions))
+	}
+
+	if n := len(image.Functions[0].Code) + len(image.Functions[1].Code); n != len(image.Code) {
+		t.Errorf("Functions cover %d of %d bytes", n, len(image.Code))
+	}
+}
+
 func TestProgramMarshaling(t *testing.T) {
 	const idx = uint32(0)
 
@@ -413,6 +1021,61 @@ func TestNewProgramFromID(t *testing.T) {
 	}
 }
 
+func TestProgramSplitLargePrograms(t *testing.T) {
+	spec := &ProgramSpec{
+		Type:         SocketFilter,
+		Instructions: largeSplittableInstructions(),
+		License:      "MIT",
+	}
+
+	_, err := NewProgram(spec)
+	testutils.SkipIfNotSupported(t, err)
+	if !xerrors.Is(err, unix.E2BIG) {
+		t.Skip("Program isn't rejected as too large:", err)
+	}
+
+	prog, err := NewProgramWithOptions(spec, ProgramOptions{SplitLargePrograms: true})
+	if err != nil {
+		t.Fatal("Can't load split program:", err)
+	}
+	defer prog.Close()
+
+	if prog.Split == nil {
+		t.Fatal("Program wasn't split")
+	}
+	if !xerrors.Is(prog.Split.Cause, unix.E2BIG) {
+		t.Error("Cause isn't E2BIG:", prog.Split.Cause)
+	}
+	if prog.Split.After >= prog.Split.Before {
+		t.Errorf("Splitting didn't reduce the instruction count from %d to %d", prog.Split.Before, prog.Split.After)
+	}
+
+	if _, err := NewProgramWithOptions(spec, ProgramOptions{SplitLargePrograms: false}); !xerrors.Is(err, unix.E2BIG) {
+		t.Error("Program is loaded without splitting:", err)
+	}
+}
+
+// largeSplittableInstructions returns a program which exceeds the limit
+// of one million instructions for privileged users, but fits once
+// repeated sections are moved into a function.
+//
+// The sections are skipped at runtime, so that the split program passes
+// the verifier quickly.
+func largeSplittableInstructions() asm.Instructions {
+	const sections = 17000
+
+	insns := asm.Instructions{asm.Mov.Imm(asm.R0, 0)}
+	for i := 0; i < sections; i++ {
+		insns = append(insns, asm.Instruction{OpCode: asm.JEq.Op(asm.ImmSource), Dst: asm.R0, Offset: 60})
+		for j := 0; j < 59; j++ {
+			insns = append(insns, asm.Mov.Imm(asm.R2, int32(j)))
+		}
+		// The call replacing the section clobbers R0.
+		insns = append(insns, asm.Mov.Imm(asm.R0, 0))
+	}
+	return append(insns, asm.Return())
+}
+
 func createProgramArray(t *testing.T) *Map {
 	t.Helper()
 
@@ -506,3 +1169,27 @@ func ExampleProgram_unmarshalFromMap() {
 		panic(err)
 	}
 }
+
+func TestResolveBTFTypeModule(t *testing.T) {
+	if _, err := os.Stat("/sys/kernel/btf/vmlinux"); os.IsNotExist(err) {
+		t.Skip("/sys/kernel/btf/vmlinux is not available")
+	}
+
+	_, err := resolveBTFType("bogus_ebpf_module:func", Tracing, AttachTraceFEntry)
+	if !xerrors.Is(err, os.ErrNotExist) {
+		t.Error("Expected os.ErrNotExist for missing module, got", err)
+	}
+
+	_, err = resolveBTFType("bogus_ebpf_function", Tracing, AttachTraceFExit)
+	if !xerrors.Is(err, btf.ErrNotFound) {
+		t.Error("Expected ErrNotFound for missing function, got", err)
+	}
+
+	target, err := resolveBTFType("vprintk", Tracing, AttachTraceFEntry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.id == 0 || target.module != nil {
+		t.Error("vprintk isn't resolved to vmlinux:", target)
+	}
+}
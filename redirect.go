@@ -0,0 +1,138 @@
+package ebpf
+
+import (
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// DevMapValue is the value of a DevMap or DevMapHash with a ValueSize
+// of eight bytes, see struct bpf_devmap_val.
+//
+// Maps with a ValueSize of four bytes only store the interface index.
+type DevMapValue struct {
+	// The index of the network interface to redirect to.
+	Ifindex uint32
+
+	// An optional program of type XDP and attach type AttachXDPDevMap,
+	// which runs on redirected packets before they are transmitted.
+	Program *Program
+	// ProgramName refers to a program in the same CollectionSpec, and
+	// is resolved when the map is created as part of a collection.
+	ProgramName string
+
+	// The ID of the program, set by Lookup.
+	ProgramID uint32
+}
+
+// MarshalBinary implements BinaryMarshaler.
+func (v DevMapValue) MarshalBinary() ([]byte, error) {
+	fd, err := redirectProgramFD(v.Program, v.ProgramName)
+	if err != nil {
+		return nil, err
+	}
+	return marshalRedirectValue(v.Ifindex, fd), nil
+}
+
+// UnmarshalBinary implements BinaryUnmarshaler.
+func (v *DevMapValue) UnmarshalBinary(buf []byte) error {
+	if len(buf) != 8 {
+		return xerrors.Errorf("devmap value requires 8 bytes, got %d", len(buf))
+	}
+
+	*v = DevMapValue{
+		Ifindex:   internal.NativeEndian.Uint32(buf),
+		ProgramID: internal.NativeEndian.Uint32(buf[4:]),
+	}
+	return nil
+}
+
+// CPUMapValue is the value of a CPUMap with a ValueSize of eight bytes,
+// see struct bpf_cpumap_val.
+//
+// Maps with a ValueSize of four bytes only store the queue size.
+type CPUMapValue struct {
+	// The size of the queue for packets redirected to the CPU.
+	QueueSize uint32
+
+	// An optional program of type XDP and attach type AttachXDPCPUMap,
+	// which runs on redirected packets on the target CPU.
+	Program *Program
+	// ProgramName refers to a program in the same CollectionSpec, and
+	// is resolved when the map is created as part of a collection.
+	ProgramName string
+
+	// The ID of the program, set by Lookup.
+	ProgramID uint32
+}
+
+// MarshalBinary implements BinaryMarshaler.
+func (v CPUMapValue) MarshalBinary() ([]byte, error) {
+	fd, err := redirectProgramFD(v.Program, v.ProgramName)
+	if err != nil {
+		return nil, err
+	}
+	return marshalRedirectValue(v.QueueSize, fd), nil
+}
+
+// UnmarshalBinary implements BinaryUnmarshaler.
+func (v *CPUMapValue) UnmarshalBinary(buf []byte) error {
+	if len(buf) != 8 {
+		return xerrors.Errorf("cpumap value requires 8 bytes, got %d", len(buf))
+	}
+
+	*v = CPUMapValue{
+		QueueSize: internal.NativeEndian.Uint32(buf),
+		ProgramID: internal.NativeEndian.Uint32(buf[4:]),
+	}
+	return nil
+}
+
+func redirectProgramFD(prog *Program, name string) (int, error) {
+	if prog == nil {
+		if name != "" {
+			return 0, xerrors.Errorf("unresolved reference to program %s", name)
+		}
+
+		// The kernel ignores fds which aren't positive.
+		return 0, nil
+	}
+
+	fd := prog.FD()
+	if fd < 0 {
+		return 0, internal.ErrClosedFd
+	}
+	return fd, nil
+}
+
+func marshalRedirectValue(value uint32, fd int) []byte {
+	buf := make([]byte, 8)
+	internal.NativeEndian.PutUint32(buf, value)
+	internal.NativeEndian.PutUint32(buf[4:], uint32(fd))
+	return buf
+}
+
+// programReference returns the name of the program referenced by a
+// value in MapSpec.Contents, and a copy of the value which refers to
+// prog instead.
+func programReference(value interface{}) (string, func(prog *Program) interface{}) {
+	switch v := value.(type) {
+	case DevMapValue:
+		if v.Program == nil && v.ProgramName != "" {
+			return v.ProgramName, func(prog *Program) interface{} {
+				v.Program = prog
+				return v
+			}
+		}
+
+	case CPUMapValue:
+		if v.Program == nil && v.ProgramName != "" {
+			return v.ProgramName, func(prog *Program) interface{} {
+				v.Program = prog
+				return v
+			}
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,5 @@
+// Package ringbuf allows reading from a BPF ring buffer.
+//
+// Unlike perf event arrays, a ring buffer is shared by all CPUs, so
+// records are returned in the order in which they were submitted.
+package ringbuf
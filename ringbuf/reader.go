@@ -0,0 +1,249 @@
+package ringbuf
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+var errClosed = xerrors.New("ring buffer reader was closed")
+
+const (
+	// Flags in the length of a record header.
+	busyBit    = 1 << 31
+	discardBit = 1 << 30
+
+	// headerSize is the size of struct bpf_ringbuf_hdr.
+	headerSize = 8
+)
+
+// Record is a sample submitted via bpf_ringbuf_output or
+// bpf_ringbuf_submit.
+type Record struct {
+	RawSample []byte
+}
+
+// Reader allows reading a RingBuf map from user space.
+type Reader struct {
+	// mu protects Read from running concurrently with Close.
+	mu sync.Mutex
+
+	m *ebpf.Map
+
+	// The consumer position is writable, the producer position and the
+	// data pages aren't.
+	consumer []byte
+	producer []byte
+	data     []byte
+	mask     uintptr
+
+	epollFd     int
+	epollEvents []unix.EpollEvent
+	// Eventfd to interrupt Read on Close.
+	closeFd   int
+	closeOnce sync.Once
+}
+
+// NewReader creates a reader for m, which must be a RingBuf.
+//
+// Requires at least Linux 5.8.
+func NewReader(m *ebpf.Map) (rd *Reader, err error) {
+	if typ := m.ABI().Type; typ != ebpf.RingBuf {
+		return nil, xerrors.Errorf("can't read from map of type %s", typ)
+	}
+
+	m, err = m.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	rd = &Reader{m: m, epollFd: -1, closeFd: -1}
+	defer func() {
+		if err != nil {
+			rd.close()
+		}
+	}()
+
+	var (
+		pageSize = os.Getpagesize()
+		size     = int(m.ABI().MaxEntries)
+		fd       = m.FD()
+	)
+
+	rd.consumer, err = unix.Mmap(fd, 0, pageSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, xerrors.Errorf("can't mmap consumer page: %w", err)
+	}
+
+	// The kernel maps the data pages twice in a row, so that records
+	// which wrap around can be read contiguously.
+	rd.producer, err = unix.Mmap(fd, int64(pageSize), pageSize+2*size, unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, xerrors.Errorf("can't mmap data pages: %w", err)
+	}
+	rd.data = rd.producer[pageSize:]
+	rd.mask = uintptr(size - 1)
+
+	rd.epollFd, err = unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, xerrors.Errorf("can't create epoll fd: %v", err)
+	}
+
+	rd.closeFd, err = unix.Eventfd(0, unix.O_CLOEXEC|unix.O_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fd := range []int{fd, rd.closeFd} {
+		event := unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(fd)}
+		if err := unix.EpollCtl(rd.epollFd, unix.EPOLL_CTL_ADD, fd, &event); err != nil {
+			return nil, xerrors.Errorf("can't add fd to epoll: %v", err)
+		}
+	}
+	rd.epollEvents = make([]unix.EpollEvent, 2)
+
+	runtime.SetFinalizer(rd, (*Reader).Close)
+	return rd, nil
+}
+
+// Read the next record from the ring buffer.
+//
+// The function blocks until a record is available. Calling Close
+// interrupts the function.
+func (rd *Reader) Read() (Record, error) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	if rd.epollFd == -1 {
+		return Record{}, errClosed
+	}
+
+	for {
+		record, ok := rd.readRecord()
+		if ok {
+			return record, nil
+		}
+
+		nEvents, err := unix.EpollWait(rd.epollFd, rd.epollEvents, -1)
+		if temp, ok := err.(temporaryError); ok && temp.Temporary() {
+			// Retry the syscall if we we're interrupted, see https://github.com/golang/go/issues/20400
+			continue
+		}
+
+		if err != nil {
+			return Record{}, err
+		}
+
+		for _, event := range rd.epollEvents[:nEvents] {
+			if int(event.Fd) == rd.closeFd {
+				return Record{}, errClosed
+			}
+		}
+	}
+}
+
+// readRecord consumes the next record, skipping discarded ones. It
+// returns false if no complete record is available.
+func (rd *Reader) readRecord() (Record, bool) {
+	consumerPos := (*uintptr)(unsafe.Pointer(&rd.consumer[0]))
+	producerPos := (*uintptr)(unsafe.Pointer(&rd.producer[0]))
+
+	cons := atomic.LoadUintptr(consumerPos)
+	for {
+		prod := atomic.LoadUintptr(producerPos)
+		if cons >= prod {
+			return Record{}, false
+		}
+
+		header := rd.data[cons&rd.mask:]
+		length := atomic.LoadUint32((*uint32)(unsafe.Pointer(&header[0])))
+		if length&busyBit != 0 {
+			// The record is reserved, but not submitted yet.
+			return Record{}, false
+		}
+
+		size := length &^ (busyBit | discardBit)
+		start := cons&rd.mask + headerSize
+
+		// Records are padded to 8 bytes.
+		cons += (uintptr(size) + headerSize + 7) &^ 7
+
+		var record Record
+		if length&discardBit == 0 {
+			record.RawSample = make([]byte, size)
+			copy(record.RawSample, rd.data[start:start+uintptr(size)])
+		}
+
+		// Free the space for the producer.
+		atomic.StoreUintptr(consumerPos, cons)
+
+		if record.RawSample != nil {
+			return record, true
+		}
+	}
+}
+
+// Close frees resources used by the reader.
+//
+// It interrupts calls to Read.
+func (rd *Reader) Close() error {
+	var err error
+	rd.closeOnce.Do(func() {
+		runtime.SetFinalizer(rd, nil)
+
+		// Interrupt Read() via the event fd.
+		var value [8]byte
+		internal.NativeEndian.PutUint64(value[:], 1)
+		if _, err = unix.Write(rd.closeFd, value[:]); err != nil {
+			err = xerrors.Errorf("can't write event fd: %v", err)
+			return
+		}
+
+		rd.mu.Lock()
+		defer rd.mu.Unlock()
+
+		rd.close()
+	})
+	if err != nil {
+		return xerrors.Errorf("close ring buffer reader: %w", err)
+	}
+	return nil
+}
+
+func (rd *Reader) close() {
+	if rd.epollFd != -1 {
+		unix.Close(rd.epollFd)
+	}
+	if rd.closeFd != -1 {
+		unix.Close(rd.closeFd)
+	}
+	rd.epollFd, rd.closeFd = -1, -1
+
+	if rd.consumer != nil {
+		unix.Munmap(rd.consumer)
+	}
+	if rd.producer != nil {
+		unix.Munmap(rd.producer)
+	}
+	rd.consumer, rd.producer, rd.data = nil, nil, nil
+
+	rd.m.Close()
+}
+
+type temporaryError interface {
+	Temporary() bool
+}
+
+// IsClosed returns true if the error occurred because the Reader was
+// closed.
+func IsClosed(err error) bool {
+	return xerrors.Is(err, errClosed)
+}
@@ -0,0 +1,189 @@
+package ringbuf
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/rlimit"
+)
+
+func TestMain(m *testing.M) {
+	if err := rlimit.RemoveMemlock(); err != nil {
+		fmt.Println("WARNING: Failed to adjust rlimit, tests may fail")
+	}
+	os.Exit(m.Run())
+}
+
+func mustRingBuf(t *testing.T) *ebpf.Map {
+	t.Helper()
+	testutils.SkipOnOldKernel(t, "5.8", "ring buffer")
+
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.RingBuf,
+		MaxEntries: uint32(os.Getpagesize()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestReader(t *testing.T) {
+	events := mustRingBuf(t)
+	defer events.Close()
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type: ebpf.SocketFilter,
+		Instructions: asm.Instructions{
+			asm.StoreImm(asm.RFP, -8, 0x04030201, asm.Word),
+			asm.LoadMapPtr(asm.R1, events.FD()),
+			asm.Mov.Reg(asm.R2, asm.RFP),
+			asm.Add.Imm(asm.R2, -8),
+			asm.Mov.Imm(asm.R3, 3),
+			asm.Mov.Imm(asm.R4, 0),
+			asm.FnRingbufOutput.Call(),
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	rd, err := NewReader(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	// Write enough records to wrap around the buffer.
+	for i := 0; i < os.Getpagesize()/16*3; i++ {
+		if _, _, err := prog.Test(make([]byte, 14)); err != nil {
+			t.Fatal(err)
+		}
+
+		record, err := rd.Read()
+		if err != nil {
+			t.Fatal("Can't read record:", err)
+		}
+
+		if !bytes.Equal(record.RawSample, []byte{1, 2, 3}) {
+			t.Fatalf("Record %d doesn't match: %v", i, record.RawSample)
+		}
+	}
+}
+
+func TestReaderClose(t *testing.T) {
+	events := mustRingBuf(t)
+	defer events.Close()
+
+	rd, err := NewReader(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := rd.Read()
+		errs <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if err := rd.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errs:
+		if !IsClosed(err) {
+			t.Error("Expected a closed error, got", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close doesn't interrupt Read")
+	}
+
+	if _, err := rd.Read(); !IsClosed(err) {
+		t.Error("Read after Close doesn't return a closed error")
+	}
+}
+
+func TestReaderBreakpoints(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "ring buffer")
+
+	spec := &ebpf.ProgramSpec{
+		Name: "prog",
+		Type: ebpf.SocketFilter,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 7),
+			asm.Mov.Imm(asm.R2, 42),
+			asm.Return(),
+		},
+		License: "MIT",
+	}
+
+	instrumented, bs, err := spec.InstrumentBreakpoints([]asm.Breakpoint{
+		{Index: 2, Registers: []asm.Register{asm.R0, asm.R2, asm.R3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ebpf.NewMap(bs.Map)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+
+	if err := instrumented.Instructions.RewriteMapPtr(bs.Symbol, events.FD()); err != nil {
+		t.Fatal(err)
+	}
+
+	prog, err := ebpf.NewProgram(instrumented)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	rd, err := NewReader(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	ret, _, err := prog.Test(make([]byte, 14))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != 7 {
+		t.Error("Instrumentation changed the return value to", ret)
+	}
+
+	record, err := rd.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := bs.Parse(record.RawSample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(event)
+
+	if event.Index != 2 || event.Registers[asm.R0] != 7 || event.Registers[asm.R2] != 42 {
+		t.Error("Unexpected event", event)
+	}
+
+	// R3 is uninitialized.
+	if value, ok := event.Registers[asm.R3]; !ok || value != 0 {
+		t.Error("Expected uninitialized register to be zero, got", value)
+	}
+}
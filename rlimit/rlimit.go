@@ -0,0 +1,107 @@
+// Package rlimit allows raising RLIMIT_MEMLOCK if necessary for the use of BPF.
+package rlimit
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+var rlimitMu sync.Mutex
+
+// mapCreateAttr is a subset of the attributes for BPF_MAP_CREATE. It's
+// duplicated here to avoid importing package ebpf.
+type mapCreateAttr struct {
+	mapType    uint32
+	keySize    uint32
+	valueSize  uint32
+	maxEntries uint32
+}
+
+// haveMemcgAccounting checks whether BPF memory is charged against the
+// memory cgroup of the caller instead of RLIMIT_MEMLOCK.
+var haveMemcgAccounting = internal.FeatureTest("memcg-based accounting for BPF memory", "5.11", func() bool {
+	rlimitMu.Lock()
+	defer rlimitMu.Unlock()
+
+	// Retrieve the original limit to prevent lowering Max, since
+	// doing so is a permanent operation when running unprivileged.
+	var oldLimit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &oldLimit); err != nil {
+		return false
+	}
+
+	// Drop the current limit to zero, maintaining the old Max value.
+	// This is always permitted by the kernel for unprivileged users.
+	zeroLimit := unix.Rlimit{Cur: 0, Max: oldLimit.Max}
+	if err := unix.Setrlimit(unix.RLIMIT_MEMLOCK, &zeroLimit); err != nil {
+		return false
+	}
+
+	// Creating a map allocates memory that counts against the rlimit
+	// on pre-5.11 kernels, but against the memory cgroup on 5.11 and
+	// later. If this call succeeds with the rlimit set to zero we can
+	// assume memcg accounting is in use.
+	attr := mapCreateAttr{
+		mapType:    2, // Array
+		keySize:    4,
+		valueSize:  4,
+		maxEntries: 1,
+	}
+	fd, mapErr := internal.BPF(0 /* BPF_MAP_CREATE */, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+
+	if mapErr == nil {
+		_ = unix.Close(int(fd))
+	}
+
+	// Restore the old limit regardless of what happened. If this fails
+	// we report the feature as missing, so that RemoveMemlock overrides
+	// the zero limit.
+	if err := unix.Setrlimit(unix.RLIMIT_MEMLOCK, &oldLimit); err != nil {
+		return false
+	}
+
+	if mapErr != nil {
+		// EPERM is returned when creating the map would exceed the rlimit.
+		// Any other error means that we can't tell, in which case we assume
+		// that the rlimit still applies.
+		return false
+	}
+
+	return true
+})
+
+// RemoveMemlock removes the limit on the amount of memory the current
+// process can lock into RAM, if necessary.
+//
+// This is not required to load eBPF resources on kernel versions 5.11+
+// due to the introduction of cgroup-based memory accounting. On such kernels
+// the function is a no-op.
+//
+// Since the function may change global per-process limits it should be invoked
+// at program start up, in main() or init().
+//
+// This function exists as a convenience and should only be used when
+// permanently raising RLIMIT_MEMLOCK to infinite is appropriate. Consider
+// invoking prlimit(2) directly with a more reasonable limit if desired.
+//
+// Requires CAP_SYS_RESOURCE on kernels < 5.11.
+func RemoveMemlock() error {
+	if haveMemcgAccounting() == nil {
+		return nil
+	}
+
+	rlimitMu.Lock()
+	defer rlimitMu.Unlock()
+
+	newLimit := unix.Rlimit{Cur: unix.RLIM_INFINITY, Max: unix.RLIM_INFINITY}
+	if err := unix.Setrlimit(unix.RLIMIT_MEMLOCK, &newLimit); err != nil {
+		return xerrors.Errorf("can't set memlock rlimit: %w", err)
+	}
+
+	return nil
+}
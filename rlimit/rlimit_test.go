@@ -0,0 +1,39 @@
+package rlimit
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+func TestRemoveMemlock(t *testing.T) {
+	var before unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &before); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RemoveMemlock(); err != nil {
+		t.Fatal("Can't remove memlock rlimit:", err)
+	}
+
+	var after unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &after); err != nil {
+		t.Fatal(err)
+	}
+
+	if haveMemcgAccounting() == nil {
+		if after != before {
+			t.Error("RemoveMemlock changed the rlimit even though memcg accounting is available")
+		}
+		return
+	}
+
+	if after.Cur != unix.RLIM_INFINITY {
+		t.Error("RemoveMemlock didn't raise the rlimit, current value is", after.Cur)
+	}
+}
+
+func TestHaveMemcgAccounting(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveMemcgAccounting)
+}
@@ -0,0 +1,112 @@
+package ebpf
+
+import (
+	"syscall"
+
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// SocketCookie returns the cookie of a socket, for example a *net.TCPConn.
+//
+// The cookie uniquely identifies the socket while it exists, and is the
+// same value BPF programs retrieve via bpf_get_socket_cookie. It is
+// therefore a natural key for maps shared with programs which deal with
+// sockets. Looking up a SockMap or SockHash with a value size of 8 bytes
+// also returns the cookie of the stored socket.
+//
+// Requires at least Linux 4.12.
+func SocketCookie(conn syscall.Conn) (uint64, error) {
+	var cookie uint64
+	err := withSocketFD(conn, func(fd int) (err error) {
+		cookie, err = unix.GetsockoptUint64(fd, unix.SOL_SOCKET, unix.SO_COOKIE)
+		return
+	})
+	if err != nil {
+		return 0, xerrors.Errorf("can't get socket cookie: %w", err)
+	}
+	return cookie, nil
+}
+
+// UpdateSocket stores conn in a SockMap or SockHash.
+//
+// Requires at least Linux 4.14.
+func (m *Map) UpdateSocket(key interface{}, conn syscall.Conn, flags MapUpdateFlags) error {
+	if t := m.abi.Type; t != SockMap && t != SockHash {
+		return xerrors.Errorf("can't store socket in %s", t)
+	}
+
+	return withSocketFD(conn, func(fd int) error {
+		if m.abi.ValueSize == 8 {
+			return m.Update(key, uint64(fd), flags)
+		}
+		return m.Update(key, uint32(fd), flags)
+	})
+}
+
+// LookupSocketStorage retrieves the value stored for conn in a SkStorage
+// map.
+//
+// Requires at least Linux 5.2.
+func (m *Map) LookupSocketStorage(conn syscall.Conn, valueOut interface{}) error {
+	if err := m.checkSocketStorage(); err != nil {
+		return err
+	}
+
+	return withSocketFD(conn, func(fd int) error {
+		return m.Lookup(uint32(fd), valueOut)
+	})
+}
+
+// UpdateSocketStorage stores a value for conn in a SkStorage map.
+//
+// Requires at least Linux 5.2.
+func (m *Map) UpdateSocketStorage(conn syscall.Conn, value interface{}, flags MapUpdateFlags) error {
+	if err := m.checkSocketStorage(); err != nil {
+		return err
+	}
+
+	return withSocketFD(conn, func(fd int) error {
+		return m.Update(uint32(fd), value, flags)
+	})
+}
+
+// DeleteSocketStorage removes the value stored for conn from a SkStorage
+// map.
+//
+// Requires at least Linux 5.2.
+func (m *Map) DeleteSocketStorage(conn syscall.Conn) error {
+	if err := m.checkSocketStorage(); err != nil {
+		return err
+	}
+
+	return withSocketFD(conn, func(fd int) error {
+		return m.Delete(uint32(fd))
+	})
+}
+
+func (m *Map) checkSocketStorage() error {
+	if t := m.abi.Type; t != SkStorage {
+		return xerrors.Errorf("%s is not socket storage", t)
+	}
+	return nil
+}
+
+// withSocketFD calls fn with the file descriptor of conn, which remains
+// valid until fn returns.
+func withSocketFD(conn syscall.Conn, fn func(fd int) error) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	err = raw.Control(func(fd uintptr) {
+		fnErr = fn(int(fd))
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
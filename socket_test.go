@@ -0,0 +1,121 @@
+package ebpf
+
+import (
+	"net"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestSocketCookie(t *testing.T) {
+	ln, conn := mustTCPConn(t)
+	defer ln.Close()
+	defer conn.Close()
+
+	cookie, err := SocketCookie(conn)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cookie == 0 {
+		t.Error("Cookie is zero")
+	}
+
+	other, err := SocketCookie(ln.(*net.TCPListener))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == cookie {
+		t.Error("Different sockets have the same cookie")
+	}
+}
+
+func TestMapSocketStorage(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:      SkStorage,
+		ValueSize: 8,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	ln, conn := mustTCPConn(t)
+	defer ln.Close()
+	defer conn.Close()
+
+	if err := m.UpdateSocketStorage(conn, uint64(42), UpdateAny); err != nil {
+		t.Fatal("Can't update socket storage:", err)
+	}
+
+	var value uint64
+	if err := m.LookupSocketStorage(conn, &value); err != nil {
+		t.Fatal("Can't look up socket storage:", err)
+	}
+	if value != 42 {
+		t.Error("Expected value 42, got", value)
+	}
+
+	if err := m.DeleteSocketStorage(conn); err != nil {
+		t.Fatal("Can't delete socket storage:", err)
+	}
+
+	arr := createArray(t)
+	defer arr.Close()
+	if err := arr.LookupSocketStorage(conn, &value); err == nil {
+		t.Error("LookupSocketStorage accepts an array")
+	}
+}
+
+func TestMapUpdateSocket(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       SockHash,
+		KeySize:    8,
+		ValueSize:  8,
+		MaxEntries: 1,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	ln, conn := mustTCPConn(t)
+	defer ln.Close()
+	defer conn.Close()
+
+	cookie, err := SocketCookie(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.UpdateSocket(cookie, conn, UpdateAny); err != nil {
+		t.Fatal("Can't store socket:", err)
+	}
+
+	var value uint64
+	if err := m.Lookup(cookie, &value); err != nil {
+		t.Skip("Can't look up socket cookie:", err)
+	}
+	if value != cookie {
+		t.Errorf("Expected cookie %d, got %d", cookie, value)
+	}
+}
+
+func mustTCPConn(tb testing.TB) (net.Listener, *net.TCPConn) {
+	tb.Helper()
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	conn, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		ln.Close()
+		tb.Fatal(err)
+	}
+
+	return ln, conn.(*net.TCPConn)
+}
@@ -0,0 +1,104 @@
+package ebpf
+
+import (
+	"io"
+	"time"
+
+	"github.com/cilium/ebpf/sys"
+
+	"golang.org/x/xerrors"
+)
+
+// StatsType selects the statistics collected by EnableStats.
+type StatsType uint32
+
+const (
+	// StatsRunTime collects the run count and runtime of programs.
+	StatsRunTime StatsType = 0
+)
+
+// EnableStats starts collecting statistics for all programs, until
+// the returned io.Closer is closed. Statistics are collected as long as
+// at least one caller has them enabled.
+//
+// Collecting statistics has a performance impact. Requires at least
+// Linux 5.8.
+func EnableStats(which StatsType) (io.Closer, error) {
+	if err := haveEnableStats(); err != nil {
+		return nil, err
+	}
+
+	fd, err := sys.EnableStats(&sys.EnableStatsAttr{Type: uint32(which)})
+	if err != nil {
+		return nil, xerrors.Errorf("can't enable stats: %w", err)
+	}
+	return fd, nil
+}
+
+// ProgramUsage is the CPU usage of a program between two samples.
+type ProgramUsage struct {
+	Program *Program
+	// Number of times the program was called.
+	RunCount uint64
+	// Accumulated runtime of the program.
+	Runtime time.Duration
+	// CPU is the percentage of a single CPU used by the program. It may
+	// exceed 100 if the program runs on multiple CPUs in parallel.
+	CPU float64
+}
+
+// StatsSampler computes the CPU usage of programs from the difference
+// between samples of their statistics.
+//
+// Statistics have to be enabled for the duration of sampling,
+// see EnableStats.
+type StatsSampler struct {
+	progs []*Program
+	last  []ProgramStats
+	time  time.Time
+}
+
+// NewStatsSampler creates a sampler and takes an initial sample.
+func NewStatsSampler(progs ...*Program) (*StatsSampler, error) {
+	s := &StatsSampler{
+		progs: progs,
+		last:  make([]ProgramStats, len(progs)),
+	}
+
+	if _, err := s.Sample(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Sample returns the usage of each program since the previous sample,
+// in the order they were passed to NewStatsSampler.
+func (s *StatsSampler) Sample() ([]ProgramUsage, error) {
+	stats := make([]ProgramStats, len(s.progs))
+	for i, prog := range s.progs {
+		st, err := prog.Stats()
+		if err != nil {
+			return nil, err
+		}
+		stats[i] = *st
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(s.time)
+
+	usage := make([]ProgramUsage, len(s.progs))
+	for i, prog := range s.progs {
+		u := ProgramUsage{
+			Program:  prog,
+			RunCount: stats[i].RunCount - s.last[i].RunCount,
+			Runtime:  stats[i].Runtime - s.last[i].Runtime,
+		}
+		if !s.time.IsZero() && elapsed > 0 {
+			u.CPU = 100 * float64(u.Runtime) / float64(elapsed)
+		}
+		usage[i] = u
+	}
+
+	s.last, s.time = stats, now
+	return usage, nil
+}
@@ -0,0 +1,58 @@
+package ebpf
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestHaveEnableStats(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveEnableStats)
+}
+
+func TestProgramStats(t *testing.T) {
+	stats, err := EnableStats(StatsRunTime)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stats.Close()
+
+	prog := createSocketFilter(t)
+	defer prog.Close()
+
+	sampler, err := NewStatsSampler(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const runs = 10
+	for i := 0; i < runs; i++ {
+		_, _, err := prog.Test(make([]byte, 14))
+		testutils.SkipIfNotSupported(t, err)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st, err := prog.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st.RunCount != runs {
+		t.Errorf("Expected %d runs, got %d", runs, st.RunCount)
+	}
+
+	usage, err := sampler.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usage[0].RunCount != runs {
+		t.Errorf("Expected sampler to report %d runs, got %d", runs, usage[0].RunCount)
+	}
+	if usage[0].Runtime != st.Runtime {
+		t.Errorf("Expected sampler to report runtime %v, got %v", st.Runtime, usage[0].Runtime)
+	}
+}
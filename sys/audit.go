@@ -0,0 +1,142 @@
+package sys
+
+import (
+	"bytes"
+	"fmt"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+// AuditRecord describes a bpf syscall.
+type AuditRecord struct {
+	Cmd Cmd
+	// Attr summarises the attributes of the syscall, for example the type
+	// and name of a map or program. It is empty for commands which
+	// aren't summarised.
+	Attr string
+	// Result is the value returned by the syscall, for example a file
+	// descriptor. It is only meaningful if Err is nil.
+	Result uintptr
+	Err    error
+}
+
+func (ar AuditRecord) String() string {
+	result := fmt.Sprint(ar.Result)
+	if ar.Err != nil {
+		result = ar.Err.Error()
+	}
+
+	if ar.Attr == "" {
+		return fmt.Sprintf("%s = %s", ar.Cmd, result)
+	}
+	return fmt.Sprintf("%s(%s) = %s", ar.Cmd, ar.Attr, result)
+}
+
+var cmdNames = map[Cmd]string{
+	BPF_MAP_CREATE:                  "BPF_MAP_CREATE",
+	BPF_MAP_LOOKUP_ELEM:             "BPF_MAP_LOOKUP_ELEM",
+	BPF_MAP_UPDATE_ELEM:             "BPF_MAP_UPDATE_ELEM",
+	BPF_MAP_DELETE_ELEM:             "BPF_MAP_DELETE_ELEM",
+	BPF_MAP_GET_NEXT_KEY:            "BPF_MAP_GET_NEXT_KEY",
+	BPF_PROG_LOAD:                   "BPF_PROG_LOAD",
+	BPF_OBJ_PIN:                     "BPF_OBJ_PIN",
+	BPF_OBJ_GET:                     "BPF_OBJ_GET",
+	BPF_PROG_ATTACH:                 "BPF_PROG_ATTACH",
+	BPF_PROG_DETACH:                 "BPF_PROG_DETACH",
+	BPF_PROG_TEST_RUN:               "BPF_PROG_TEST_RUN",
+	BPF_PROG_GET_NEXT_ID:            "BPF_PROG_GET_NEXT_ID",
+	BPF_MAP_GET_NEXT_ID:             "BPF_MAP_GET_NEXT_ID",
+	BPF_PROG_GET_FD_BY_ID:           "BPF_PROG_GET_FD_BY_ID",
+	BPF_MAP_GET_FD_BY_ID:            "BPF_MAP_GET_FD_BY_ID",
+	BPF_OBJ_GET_INFO_BY_FD:          "BPF_OBJ_GET_INFO_BY_FD",
+	BPF_PROG_QUERY:                  "BPF_PROG_QUERY",
+	BPF_RAW_TRACEPOINT_OPEN:         "BPF_RAW_TRACEPOINT_OPEN",
+	BPF_BTF_LOAD:                    "BPF_BTF_LOAD",
+	BPF_BTF_GET_FD_BY_ID:            "BPF_BTF_GET_FD_BY_ID",
+	BPF_TASK_FD_QUERY:               "BPF_TASK_FD_QUERY",
+	BPF_MAP_LOOKUP_AND_DELETE_ELEM:  "BPF_MAP_LOOKUP_AND_DELETE_ELEM",
+	BPF_MAP_FREEZE:                  "BPF_MAP_FREEZE",
+	BPF_BTF_GET_NEXT_ID:             "BPF_BTF_GET_NEXT_ID",
+	BPF_MAP_LOOKUP_BATCH:            "BPF_MAP_LOOKUP_BATCH",
+	BPF_MAP_LOOKUP_AND_DELETE_BATCH: "BPF_MAP_LOOKUP_AND_DELETE_BATCH",
+	BPF_MAP_UPDATE_BATCH:            "BPF_MAP_UPDATE_BATCH",
+	BPF_MAP_DELETE_BATCH:            "BPF_MAP_DELETE_BATCH",
+	BPF_LINK_CREATE:                 "BPF_LINK_CREATE",
+	BPF_LINK_UPDATE:                 "BPF_LINK_UPDATE",
+	BPF_LINK_GET_FD_BY_ID:           "BPF_LINK_GET_FD_BY_ID",
+	BPF_LINK_GET_NEXT_ID:            "BPF_LINK_GET_NEXT_ID",
+	BPF_ENABLE_STATS:                "BPF_ENABLE_STATS",
+	BPF_ITER_CREATE:                 "BPF_ITER_CREATE",
+	BPF_LINK_DETACH:                 "BPF_LINK_DETACH",
+	BPF_PROG_BIND_MAP:               "BPF_PROG_BIND_MAP",
+	BPF_TOKEN_CREATE:                "BPF_TOKEN_CREATE",
+	BPF_PROG_STREAM_READ_BY_FD:      "BPF_PROG_STREAM_READ_BY_FD",
+}
+
+func (cmd Cmd) String() string {
+	if name, ok := cmdNames[cmd]; ok {
+		return name
+	}
+	return fmt.Sprintf("Cmd(%d)", uint32(cmd))
+}
+
+// SetAuditHook installs a function which is called after every bpf
+// syscall issued by the library, including the ones issued via BPF. This
+// allows keeping an audit trail of BPF activity. Pass nil to remove the
+// hook.
+//
+// The hook is called synchronously by the goroutine which issued the
+// syscall, and must therefore be safe for concurrent use. It must not
+// issue bpf syscalls itself.
+func SetAuditHook(hook func(AuditRecord)) {
+	if hook == nil {
+		internal.SetAuditHook(nil)
+		return
+	}
+
+	internal.SetAuditHook(func(cmd int, attr []byte, r1 uintptr, err error) {
+		hook(AuditRecord{
+			Cmd:    Cmd(cmd),
+			Attr:   summarise(Cmd(cmd), attr),
+			Result: r1,
+			Err:    err,
+		})
+	})
+}
+
+func summarise(cmd Cmd, attr []byte) string {
+	switch cmd {
+	case BPF_MAP_CREATE:
+		var mc MapCreateAttr
+		copyAttr(unsafe.Pointer(&mc), unsafe.Sizeof(mc), attr)
+		return fmt.Sprintf("type=%d name=%q key=%d value=%d entries=%d flags=%#x",
+			mc.MapType, cString(mc.MapName[:]), mc.KeySize, mc.ValueSize, mc.MaxEntries, mc.MapFlags)
+
+	case BPF_PROG_LOAD:
+		var pl ProgLoadAttr
+		copyAttr(unsafe.Pointer(&pl), unsafe.Sizeof(pl), attr)
+		return fmt.Sprintf("type=%d name=%q insns=%d license=%q flags=%#x",
+			pl.ProgType, cString(pl.ProgName[:]), pl.InsnCnt, pl.License.CString(128), pl.ProgFlags)
+
+	case BPF_OBJ_PIN, BPF_OBJ_GET:
+		var obj ObjAttr
+		copyAttr(unsafe.Pointer(&obj), unsafe.Sizeof(obj), attr)
+		return fmt.Sprintf("path=%q fd=%d", obj.Pathname.CString(4096), obj.BPFFD)
+
+	default:
+		return ""
+	}
+}
+
+// copyAttr copies at most dstSize bytes of attributes into dst.
+func copyAttr(dst unsafe.Pointer, dstSize uintptr, attr []byte) {
+	copy((*[1 << 16]byte)(dst)[:dstSize:dstSize], attr)
+}
+
+func cString(buf []byte) string {
+	if i := bytes.IndexByte(buf, 0); i >= 0 {
+		buf = buf[:i]
+	}
+	return string(buf)
+}
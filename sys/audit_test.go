@@ -0,0 +1,55 @@
+package sys
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestAuditHook(t *testing.T) {
+	var records []AuditRecord
+	SetAuditHook(func(ar AuditRecord) {
+		records = append(records, ar)
+	})
+	defer SetAuditHook(nil)
+
+	attr := MapCreateAttr{
+		MapType:    BPF_MAP_TYPE_ARRAY,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	}
+	copy(attr.MapName[:], "audit")
+
+	fd, err := MapCreate(&attr)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	_, err = ObjGet(&ObjAttr{Pathname: NewStringPointer("/sys/fs/bpf/does-not-exist")})
+	if err == nil {
+		t.Fatal("ObjGet didn't fail")
+	}
+
+	SetAuditHook(nil)
+	ObjGet(&ObjAttr{Pathname: NewStringPointer("/sys/fs/bpf/does-not-exist")})
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d: %v", len(records), records)
+	}
+
+	if str := records[0].String(); !strings.HasPrefix(str, "BPF_MAP_CREATE(") || !strings.Contains(str, `name="audit"`) {
+		t.Error("Unexpected record for BPF_MAP_CREATE:", str)
+	}
+
+	if records[1].Err == nil {
+		t.Error("Record for BPF_OBJ_GET doesn't contain the error")
+	}
+
+	if str := records[1].String(); !strings.Contains(str, `path="/sys/fs/bpf/does-not-exist"`) {
+		t.Error("Unexpected record for BPF_OBJ_GET:", str)
+	}
+}
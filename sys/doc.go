@@ -0,0 +1,16 @@
+// Package sys exposes the bpf(2) syscall with minimal abstraction.
+//
+// The types in this package mirror the ones in include/uapi/linux/bpf.h
+// and are generated from the BTF of a recent kernel. Use it to issue
+// commands or to set attributes which aren't supported by the rest of
+// the library yet. Fields which aren't needed by the library are
+// represented as padding.
+//
+// The package makes no guarantees about compatibility with older
+// kernels, which return E2BIG if an attribute they don't know about is
+// non-zero.
+//
+// SetAuditHook observes all bpf syscalls issued by the library.
+package sys
+
+//go:generate go run gentypes.go
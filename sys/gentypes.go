@@ -0,0 +1,435 @@
+//go:build ignore
+// +build ignore
+
+// This program generates types.go from the BTF of the running kernel,
+// which describes the same types as include/uapi/linux/bpf.h.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/btf"
+)
+
+// enums are converted to a Go type with one constant per value.
+var enums = []struct {
+	goType, cType string
+}{
+	{"Cmd", "bpf_cmd"},
+	{"MapType", "bpf_map_type"},
+	{"ProgType", "bpf_prog_type"},
+	{"AttachType", "bpf_attach_type"},
+	{"LinkType", "bpf_link_type"},
+}
+
+// attrs are the members of union bpf_attr. They are identified by
+// the name of the member or, for anonymous structs, by the name of
+// their first field.
+//
+// Unions within a member are represented by their first member, unless
+// variant names the first field of another one. Variants get their own
+// wrapper, named after the type.
+var attrs = []struct {
+	goType, member string
+	cmds           []string
+	variant        string
+}{
+	{"MapCreateAttr", "map_type", []string{"BPF_MAP_CREATE"}, ""},
+	{"MapElemAttr", "map_fd", []string{
+		"BPF_MAP_LOOKUP_ELEM", "BPF_MAP_UPDATE_ELEM", "BPF_MAP_DELETE_ELEM",
+		"BPF_MAP_GET_NEXT_KEY", "BPF_MAP_LOOKUP_AND_DELETE_ELEM", "BPF_MAP_FREEZE",
+	}, ""},
+	{"MapBatchAttr", "batch", []string{
+		"BPF_MAP_LOOKUP_BATCH", "BPF_MAP_LOOKUP_AND_DELETE_BATCH",
+		"BPF_MAP_UPDATE_BATCH", "BPF_MAP_DELETE_BATCH",
+	}, ""},
+	{"ProgLoadAttr", "prog_type", []string{"BPF_PROG_LOAD"}, ""},
+	{"ObjAttr", "pathname", []string{"BPF_OBJ_PIN", "BPF_OBJ_GET"}, ""},
+	{"ProgAttachAttr", "target_fd", []string{"BPF_PROG_ATTACH", "BPF_PROG_DETACH"}, ""},
+	{"ProgRunAttr", "test", []string{"BPF_PROG_TEST_RUN"}, ""},
+	{"GetIDAttr", "start_id", []string{
+		"BPF_PROG_GET_NEXT_ID", "BPF_MAP_GET_NEXT_ID", "BPF_BTF_GET_NEXT_ID", "BPF_LINK_GET_NEXT_ID",
+		"BPF_PROG_GET_FD_BY_ID", "BPF_MAP_GET_FD_BY_ID", "BPF_BTF_GET_FD_BY_ID", "BPF_LINK_GET_FD_BY_ID",
+	}, ""},
+	{"ObjGetInfoByFDAttr", "info", []string{"BPF_OBJ_GET_INFO_BY_FD"}, ""},
+	{"ProgQueryAttr", "query", []string{"BPF_PROG_QUERY"}, ""},
+	{"RawTracepointOpenAttr", "raw_tracepoint", []string{"BPF_RAW_TRACEPOINT_OPEN"}, ""},
+	{"BTFLoadAttr", "btf", []string{"BPF_BTF_LOAD"}, ""},
+	{"TaskFDQueryAttr", "task_fd_query", []string{"BPF_TASK_FD_QUERY"}, ""},
+	{"LinkCreateAttr", "link_create", []string{"BPF_LINK_CREATE"}, ""},
+	{"LinkCreateIterAttr", "link_create", []string{"BPF_LINK_CREATE"}, "iter_info"},
+	{"LinkUpdateAttr", "link_update", []string{"BPF_LINK_UPDATE"}, ""},
+	{"LinkDetachAttr", "link_detach", []string{"BPF_LINK_DETACH"}, ""},
+	{"EnableStatsAttr", "enable_stats", []string{"BPF_ENABLE_STATS"}, ""},
+	{"IterCreateAttr", "iter_create", []string{"BPF_ITER_CREATE"}, ""},
+	{"ProgBindMapAttr", "prog_bind_map", []string{"BPF_PROG_BIND_MAP"}, ""},
+}
+
+// infos are structs returned by BPF_OBJ_GET_INFO_BY_FD.
+var infos = []struct {
+	goType, cType string
+}{
+	{"ProgInfo", "bpf_prog_info"},
+	{"MapInfo", "bpf_map_info"},
+	{"BTFInfo", "bpf_btf_info"},
+	{"LinkInfo", "bpf_link_info"},
+}
+
+// pointers lists fields which hold a user space address.
+var pointers = map[string]bool{
+	"key": true, "value": true, "next_key": true,
+	"in_batch": true, "out_batch": true, "keys": true, "values": true,
+	"insns": true, "license": true, "log_buf": true, "pathname": true,
+	"func_info": true, "line_info": true, "fd_array": true,
+	"data_in": true, "data_out": true, "ctx_in": true, "ctx_out": true,
+	"info": true, "prog_ids": true, "prog_attach_flags": true, "link_ids": true,
+	"link_attach_flags": true, "name": true, "btf": true, "btf_log_buf": true,
+	"buf": true, "iter_info": true, "jited_prog_insns": true,
+	"xlated_prog_insns": true, "map_ids": true, "jited_ksyms": true,
+	"jited_func_lens": true, "jited_line_info": true, "prog_tags": true,
+	"core_relos": true, "signature": true, "excl_prog_hash": true, "hash": true,
+}
+
+// fieldTypes override the Go type of fields.
+var fieldTypes = map[string]string{
+	"map_type":             "MapType",
+	"prog_type":            "ProgType",
+	"attach_type":          "AttachType",
+	"expected_attach_type": "AttachType",
+}
+
+// fds lists commands which return a file descriptor.
+var fds = map[string]bool{
+	"BPF_MAP_CREATE":          true,
+	"BPF_PROG_LOAD":           true,
+	"BPF_OBJ_GET":             true,
+	"BPF_PROG_GET_FD_BY_ID":   true,
+	"BPF_MAP_GET_FD_BY_ID":    true,
+	"BPF_BTF_GET_FD_BY_ID":    true,
+	"BPF_LINK_GET_FD_BY_ID":   true,
+	"BPF_RAW_TRACEPOINT_OPEN": true,
+	"BPF_BTF_LOAD":            true,
+	"BPF_LINK_CREATE":         true,
+	"BPF_ITER_CREATE":         true,
+	"BPF_ENABLE_STATS":        true,
+}
+
+func main() {
+	spec, err := btf.LoadKernelSpec()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	version, err := internal.KernelVersion()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gentypes.go from the BTF of Linux %s; DO NOT EDIT.\n\n", version)
+	fmt.Fprintln(&buf, "package sys")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, `import "unsafe"`)
+	fmt.Fprintln(&buf)
+	fmt.Fprintf(&buf, "// KernelVersion is the kernel the types were generated from.\n")
+	fmt.Fprintf(&buf, "const KernelVersion = %q\n\n", version)
+
+	for _, enum := range enums {
+		var typ *btf.Enum
+		if err := spec.TypeByName(enum.cType, &typ); err != nil {
+			log.Fatalf("enum %s: %v", enum.cType, err)
+		}
+		writeEnum(&buf, enum.goType, enum.cType, typ)
+	}
+
+	var attr *btf.Union
+	if err := spec.TypeByName("bpf_attr", &attr); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, a := range attrs {
+		member := findAttr(attr, a.member)
+		if member == nil {
+			log.Fatalf("bpf_attr has no member %s", a.member)
+		}
+
+		size := sizeof(member.Type)
+		fmt.Fprintf(&buf, "// %s is used by %s.\n", a.goType, strings.Join(a.cmds, ", "))
+		writeStruct(&buf, a.goType, member.Type, size, a.variant)
+	}
+
+	for _, info := range infos {
+		var typ *btf.Struct
+		if err := spec.TypeByName(info.cType, &typ); err != nil {
+			log.Fatalf("struct %s: %v", info.cType, err)
+		}
+
+		fmt.Fprintf(&buf, "// %s mirrors struct %s.\n", info.goType, info.cType)
+		writeStruct(&buf, info.goType, typ, typ.Size, "")
+	}
+
+	for _, a := range attrs {
+		for _, cmd := range a.cmds {
+			fn := cmdName(cmd)
+			if a.variant != "" {
+				fn = strings.TrimSuffix(a.goType, "Attr")
+			}
+			writeCmd(&buf, fn, cmd, a.goType)
+		}
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("can't format output: %v\n%s", err, buf.Bytes())
+	}
+
+	if err := ioutil.WriteFile("types.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func writeEnum(buf *bytes.Buffer, goType, cType string, enum *btf.Enum) {
+	fmt.Fprintf(buf, "// %s mirrors enum %s.\n", goType, cType)
+	fmt.Fprintf(buf, "type %s uint32\n\n", goType)
+	fmt.Fprintf(buf, "// Values of enum %s.\n", cType)
+	fmt.Fprintln(buf, "const (")
+	for _, value := range enum.Values {
+		name := string(value.Name)
+		if strings.HasPrefix(name, "__") || strings.HasPrefix(name, "MAX_") {
+			continue
+		}
+		fmt.Fprintf(buf, "\t%s %s = %d\n", name, goType, value.Value)
+	}
+	fmt.Fprintln(buf, ")")
+	fmt.Fprintln(buf)
+}
+
+// findAttr returns the member of bpf_attr identified by name.
+func findAttr(attr *btf.Union, name string) *btf.Member {
+	for i := range attr.Members {
+		member := &attr.Members[i]
+		if string(member.Name) == name || member.Name == "" && firstName(member.Type) == name {
+			return member
+		}
+	}
+	return nil
+}
+
+// firstName returns the name of the first named field in typ.
+func firstName(typ btf.Type) string {
+	members := compositeMembers(typ)
+	if len(members) == 0 {
+		return ""
+	}
+	if members[0].Name != "" {
+		return string(members[0].Name)
+	}
+	return firstName(members[0].Type)
+}
+
+func compositeMembers(typ btf.Type) []btf.Member {
+	switch v := skipQualifiers(typ).(type) {
+	case *btf.Struct:
+		return v.Members
+	case *btf.Union:
+		return v.Members
+	}
+	return nil
+}
+
+type field struct {
+	name   string
+	goType string
+	offset uint32
+	size   uint32
+}
+
+func writeStruct(buf *bytes.Buffer, goType string, typ btf.Type, size uint32, variant string) {
+	var fields []field
+	flatten(&fields, typ, 0, variant)
+
+	fmt.Fprintf(buf, "type %s struct {\n", goType)
+	var off uint32
+	for _, f := range fields {
+		if f.offset < off {
+			// Overlaps a previous field, e.g. in a union.
+			continue
+		}
+		if f.offset > off {
+			fmt.Fprintf(buf, "\t_ [%d]byte\n", f.offset-off)
+		}
+		fmt.Fprintf(buf, "\t%s %s\n", f.name, f.goType)
+		off = f.offset + f.size
+	}
+	if size > off {
+		fmt.Fprintf(buf, "\t_ [%d]byte\n", size-off)
+	}
+	fmt.Fprintln(buf, "}")
+	fmt.Fprintln(buf)
+}
+
+// flatten appends the fields of a struct or union at offset, which is
+// in bytes.
+//
+// Anonymous structs are inlined. Only the first member of a union
+// is used, or the one starting with the field named variant, and
+// bitfields and nested named structs are skipped, the gaps are filled
+// with padding.
+func flatten(fields *[]field, typ btf.Type, offset uint32, variant string) {
+	switch v := skipQualifiers(typ).(type) {
+	case *btf.Struct:
+		for _, member := range v.Members {
+			flattenMember(fields, member, offset, variant)
+		}
+
+	case *btf.Union:
+		if len(v.Members) == 0 {
+			return
+		}
+
+		member := v.Members[0]
+		for _, m := range v.Members {
+			if variant != "" && (string(m.Name) == variant || m.Name == "" && firstName(m.Type) == variant) {
+				member = m
+				break
+			}
+		}
+		flattenMember(fields, member, offset, variant)
+	}
+}
+
+func flattenMember(fields *[]field, member btf.Member, offset uint32, variant string) {
+	if member.BitfieldSize > 0 || member.Offset%8 != 0 {
+		return
+	}
+
+	offset += member.Offset / 8
+	name := string(member.Name)
+	typ := skipQualifiers(member.Type)
+
+	switch v := typ.(type) {
+	case *btf.Struct, *btf.Union:
+		if name == "" {
+			flatten(fields, v, offset, variant)
+		}
+		return
+	}
+
+	goType, size, ok := goScalar(name, typ)
+	if !ok {
+		return
+	}
+
+	*fields = append(*fields, field{goName(name), goType, offset, size})
+}
+
+func goScalar(name string, typ btf.Type) (string, uint32, bool) {
+	switch v := typ.(type) {
+	case *btf.Int:
+		if v.Size == 8 && pointers[name] {
+			return "Pointer", 8, true
+		}
+		if override := fieldTypes[name]; override != "" && v.Size == 4 {
+			return override, 4, true
+		}
+		prefix := "uint"
+		if v.Encoding&btf.Signed != 0 {
+			prefix = "int"
+		}
+		return fmt.Sprintf("%s%d", prefix, v.Size*8), v.Size, true
+
+	case *btf.Enum:
+		return fmt.Sprintf("uint%d", v.Size*8), v.Size, true
+
+	case *btf.Array:
+		elem, ok := skipQualifiers(v.Type).(*btf.Int)
+		if !ok {
+			return "", 0, false
+		}
+		elemType, elemSize, _ := goScalar("", elem)
+		if elem.Size == 1 {
+			elemType = "byte"
+		}
+		return fmt.Sprintf("[%d]%s", v.Nelems, elemType), v.Nelems * elemSize, true
+	}
+	return "", 0, false
+}
+
+var initialisms = map[string]string{
+	"bpf": "BPF", "id": "ID", "ids": "IDs", "pid": "PID", "fd": "FD", "fds": "FDs", "btf": "BTF",
+	"uid": "UID", "gid": "GID", "cpu": "CPU", "ns": "NS", "ip": "IP",
+	"xdp": "XDP", "jited": "JITed", "insns": "Insns", "ksyms": "Ksyms",
+}
+
+func goName(name string) string {
+	var out strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		if initialism, ok := initialisms[part]; ok {
+			out.WriteString(initialism)
+			continue
+		}
+		out.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return out.String()
+}
+
+func cmdName(cmd string) string {
+	return goName(strings.ToLower(strings.TrimPrefix(cmd, "BPF_")))
+}
+
+func writeCmd(buf *bytes.Buffer, fn, cmd, attr string) {
+	if fds[cmd] {
+		fmt.Fprintf(buf, "// %s wraps %s.\n", fn, cmd)
+		fmt.Fprintf(buf, "func %s(attr *%s) (*FD, error) {\n", fn, attr)
+		fmt.Fprintf(buf, "\tfd, err := BPF(%s, unsafe.Pointer(attr), unsafe.Sizeof(*attr))\n", cmd)
+		fmt.Fprintln(buf, "\tif err != nil {\n\t\treturn nil, err\n\t}")
+		fmt.Fprintln(buf, "\treturn NewFD(uint32(fd)), nil")
+		fmt.Fprintln(buf, "}")
+		fmt.Fprintln(buf)
+		return
+	}
+
+	fmt.Fprintf(buf, "// %s wraps %s.\n", fn, cmd)
+	fmt.Fprintf(buf, "func %s(attr *%s) error {\n", fn, attr)
+	fmt.Fprintf(buf, "\t_, err := BPF(%s, unsafe.Pointer(attr), unsafe.Sizeof(*attr))\n", cmd)
+	fmt.Fprintln(buf, "\treturn err")
+	fmt.Fprintln(buf, "}")
+	fmt.Fprintln(buf)
+}
+
+func sizeof(typ btf.Type) uint32 {
+	switch v := skipQualifiers(typ).(type) {
+	case *btf.Struct:
+		return v.Size
+	case *btf.Union:
+		return v.Size
+	}
+	log.Fatalf("can't determine size of %T", typ)
+	return 0
+}
+
+func skipQualifiers(typ btf.Type) btf.Type {
+	for {
+		switch v := typ.(type) {
+		case *btf.Typedef:
+			typ = v.Type
+		case *btf.Volatile:
+			typ = v.Type
+		case *btf.Const:
+			typ = v.Type
+		case *btf.Restrict:
+			typ = v.Type
+		default:
+			return typ
+		}
+	}
+}
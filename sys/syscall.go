@@ -0,0 +1,42 @@
+package sys
+
+import (
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+// Pointer is a 64 bit pointer, as used in attributes.
+type Pointer = internal.Pointer
+
+// NewPointer creates a Pointer from an unsafe.Pointer.
+func NewPointer(ptr unsafe.Pointer) Pointer {
+	return internal.NewPointer(ptr)
+}
+
+// NewSlicePointer creates a Pointer to the first byte of buf.
+func NewSlicePointer(buf []byte) Pointer {
+	return internal.NewSlicePointer(buf)
+}
+
+// NewStringPointer creates a Pointer to a NUL terminated copy of str.
+func NewStringPointer(str string) Pointer {
+	return internal.NewStringPointer(str)
+}
+
+// FD is a file descriptor returned by the kernel.
+type FD = internal.FD
+
+// NewFD wraps a raw file descriptor.
+//
+// The FD is closed by a finalizer if it isn't closed explicitly.
+func NewFD(value uint32) *FD {
+	return internal.NewFD(value)
+}
+
+// BPF wraps SYS_BPF.
+//
+// Any pointers contained in attr must use Pointer.
+func BPF(cmd Cmd, attr unsafe.Pointer, size uintptr) (uintptr, error) {
+	return internal.BPF(int(cmd), attr, size)
+}
@@ -0,0 +1,80 @@
+package sys
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestAttrSizes(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		size, want uintptr
+	}{
+		{"MapElemAttr", unsafe.Sizeof(MapElemAttr{}), 32},
+		{"MapBatchAttr", unsafe.Sizeof(MapBatchAttr{}), 56},
+		{"ObjGetInfoByFDAttr", unsafe.Sizeof(ObjGetInfoByFDAttr{}), 16},
+		{"LinkUpdateAttr", unsafe.Sizeof(LinkUpdateAttr{}), 16},
+	} {
+		if test.size != test.want {
+			t.Errorf("%s has size %d, expected %d", test.name, test.size, test.want)
+		}
+	}
+}
+
+func TestMapCreate(t *testing.T) {
+	fd, err := MapCreate(&MapCreateAttr{
+		MapType:    BPF_MAP_TYPE_ARRAY,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	raw, err := fd.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, value := uint32(0), uint32(42)
+	err = MapUpdateElem(&MapElemAttr{
+		MapFD: raw,
+		Key:   NewPointer(unsafe.Pointer(&key)),
+		Value: NewPointer(unsafe.Pointer(&value)),
+	})
+	if err != nil {
+		t.Fatal("Can't update element:", err)
+	}
+
+	var got uint32
+	err = MapLookupElem(&MapElemAttr{
+		MapFD: raw,
+		Key:   NewPointer(unsafe.Pointer(&key)),
+		Value: NewPointer(unsafe.Pointer(&got)),
+	})
+	if err != nil {
+		t.Fatal("Can't look up element:", err)
+	}
+	if got != value {
+		t.Errorf("Expected value %d, got %d", value, got)
+	}
+
+	var info MapInfo
+	err = ObjGetInfoByFD(&ObjGetInfoByFDAttr{
+		BPFFD:   raw,
+		InfoLen: uint32(unsafe.Sizeof(info)),
+		Info:    NewPointer(unsafe.Pointer(&info)),
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal("Can't get map info:", err)
+	}
+	if MapType(info.Type) != BPF_MAP_TYPE_ARRAY || info.MaxEntries != 1 {
+		t.Errorf("Unexpected map info: %+v", info)
+	}
+}
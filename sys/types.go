@@ -0,0 +1,803 @@
+// Code generated by gentypes.go from the BTF of Linux v6.18.44; DO NOT EDIT.
+
+package sys
+
+import "unsafe"
+
+// KernelVersion is the kernel the types were generated from.
+const KernelVersion = "v6.18.44"
+
+// Cmd mirrors enum bpf_cmd.
+type Cmd uint32
+
+// Values of enum bpf_cmd.
+const (
+	BPF_MAP_CREATE                  Cmd = 0
+	BPF_MAP_LOOKUP_ELEM             Cmd = 1
+	BPF_MAP_UPDATE_ELEM             Cmd = 2
+	BPF_MAP_DELETE_ELEM             Cmd = 3
+	BPF_MAP_GET_NEXT_KEY            Cmd = 4
+	BPF_PROG_LOAD                   Cmd = 5
+	BPF_OBJ_PIN                     Cmd = 6
+	BPF_OBJ_GET                     Cmd = 7
+	BPF_PROG_ATTACH                 Cmd = 8
+	BPF_PROG_DETACH                 Cmd = 9
+	BPF_PROG_TEST_RUN               Cmd = 10
+	BPF_PROG_RUN                    Cmd = 10
+	BPF_PROG_GET_NEXT_ID            Cmd = 11
+	BPF_MAP_GET_NEXT_ID             Cmd = 12
+	BPF_PROG_GET_FD_BY_ID           Cmd = 13
+	BPF_MAP_GET_FD_BY_ID            Cmd = 14
+	BPF_OBJ_GET_INFO_BY_FD          Cmd = 15
+	BPF_PROG_QUERY                  Cmd = 16
+	BPF_RAW_TRACEPOINT_OPEN         Cmd = 17
+	BPF_BTF_LOAD                    Cmd = 18
+	BPF_BTF_GET_FD_BY_ID            Cmd = 19
+	BPF_TASK_FD_QUERY               Cmd = 20
+	BPF_MAP_LOOKUP_AND_DELETE_ELEM  Cmd = 21
+	BPF_MAP_FREEZE                  Cmd = 22
+	BPF_BTF_GET_NEXT_ID             Cmd = 23
+	BPF_MAP_LOOKUP_BATCH            Cmd = 24
+	BPF_MAP_LOOKUP_AND_DELETE_BATCH Cmd = 25
+	BPF_MAP_UPDATE_BATCH            Cmd = 26
+	BPF_MAP_DELETE_BATCH            Cmd = 27
+	BPF_LINK_CREATE                 Cmd = 28
+	BPF_LINK_UPDATE                 Cmd = 29
+	BPF_LINK_GET_FD_BY_ID           Cmd = 30
+	BPF_LINK_GET_NEXT_ID            Cmd = 31
+	BPF_ENABLE_STATS                Cmd = 32
+	BPF_ITER_CREATE                 Cmd = 33
+	BPF_LINK_DETACH                 Cmd = 34
+	BPF_PROG_BIND_MAP               Cmd = 35
+	BPF_TOKEN_CREATE                Cmd = 36
+	BPF_PROG_STREAM_READ_BY_FD      Cmd = 37
+)
+
+// MapType mirrors enum bpf_map_type.
+type MapType uint32
+
+// Values of enum bpf_map_type.
+const (
+	BPF_MAP_TYPE_UNSPEC                           MapType = 0
+	BPF_MAP_TYPE_HASH                             MapType = 1
+	BPF_MAP_TYPE_ARRAY                            MapType = 2
+	BPF_MAP_TYPE_PROG_ARRAY                       MapType = 3
+	BPF_MAP_TYPE_PERF_EVENT_ARRAY                 MapType = 4
+	BPF_MAP_TYPE_PERCPU_HASH                      MapType = 5
+	BPF_MAP_TYPE_PERCPU_ARRAY                     MapType = 6
+	BPF_MAP_TYPE_STACK_TRACE                      MapType = 7
+	BPF_MAP_TYPE_CGROUP_ARRAY                     MapType = 8
+	BPF_MAP_TYPE_LRU_HASH                         MapType = 9
+	BPF_MAP_TYPE_LRU_PERCPU_HASH                  MapType = 10
+	BPF_MAP_TYPE_LPM_TRIE                         MapType = 11
+	BPF_MAP_TYPE_ARRAY_OF_MAPS                    MapType = 12
+	BPF_MAP_TYPE_HASH_OF_MAPS                     MapType = 13
+	BPF_MAP_TYPE_DEVMAP                           MapType = 14
+	BPF_MAP_TYPE_SOCKMAP                          MapType = 15
+	BPF_MAP_TYPE_CPUMAP                           MapType = 16
+	BPF_MAP_TYPE_XSKMAP                           MapType = 17
+	BPF_MAP_TYPE_SOCKHASH                         MapType = 18
+	BPF_MAP_TYPE_CGROUP_STORAGE_DEPRECATED        MapType = 19
+	BPF_MAP_TYPE_CGROUP_STORAGE                   MapType = 19
+	BPF_MAP_TYPE_REUSEPORT_SOCKARRAY              MapType = 20
+	BPF_MAP_TYPE_PERCPU_CGROUP_STORAGE_DEPRECATED MapType = 21
+	BPF_MAP_TYPE_PERCPU_CGROUP_STORAGE            MapType = 21
+	BPF_MAP_TYPE_QUEUE                            MapType = 22
+	BPF_MAP_TYPE_STACK                            MapType = 23
+	BPF_MAP_TYPE_SK_STORAGE                       MapType = 24
+	BPF_MAP_TYPE_DEVMAP_HASH                      MapType = 25
+	BPF_MAP_TYPE_STRUCT_OPS                       MapType = 26
+	BPF_MAP_TYPE_RINGBUF                          MapType = 27
+	BPF_MAP_TYPE_INODE_STORAGE                    MapType = 28
+	BPF_MAP_TYPE_TASK_STORAGE                     MapType = 29
+	BPF_MAP_TYPE_BLOOM_FILTER                     MapType = 30
+	BPF_MAP_TYPE_USER_RINGBUF                     MapType = 31
+	BPF_MAP_TYPE_CGRP_STORAGE                     MapType = 32
+	BPF_MAP_TYPE_ARENA                            MapType = 33
+)
+
+// ProgType mirrors enum bpf_prog_type.
+type ProgType uint32
+
+// Values of enum bpf_prog_type.
+const (
+	BPF_PROG_TYPE_UNSPEC                  ProgType = 0
+	BPF_PROG_TYPE_SOCKET_FILTER           ProgType = 1
+	BPF_PROG_TYPE_KPROBE                  ProgType = 2
+	BPF_PROG_TYPE_SCHED_CLS               ProgType = 3
+	BPF_PROG_TYPE_SCHED_ACT               ProgType = 4
+	BPF_PROG_TYPE_TRACEPOINT              ProgType = 5
+	BPF_PROG_TYPE_XDP                     ProgType = 6
+	BPF_PROG_TYPE_PERF_EVENT              ProgType = 7
+	BPF_PROG_TYPE_CGROUP_SKB              ProgType = 8
+	BPF_PROG_TYPE_CGROUP_SOCK             ProgType = 9
+	BPF_PROG_TYPE_LWT_IN                  ProgType = 10
+	BPF_PROG_TYPE_LWT_OUT                 ProgType = 11
+	BPF_PROG_TYPE_LWT_XMIT                ProgType = 12
+	BPF_PROG_TYPE_SOCK_OPS                ProgType = 13
+	BPF_PROG_TYPE_SK_SKB                  ProgType = 14
+	BPF_PROG_TYPE_CGROUP_DEVICE           ProgType = 15
+	BPF_PROG_TYPE_SK_MSG                  ProgType = 16
+	BPF_PROG_TYPE_RAW_TRACEPOINT          ProgType = 17
+	BPF_PROG_TYPE_CGROUP_SOCK_ADDR        ProgType = 18
+	BPF_PROG_TYPE_LWT_SEG6LOCAL           ProgType = 19
+	BPF_PROG_TYPE_LIRC_MODE2              ProgType = 20
+	BPF_PROG_TYPE_SK_REUSEPORT            ProgType = 21
+	BPF_PROG_TYPE_FLOW_DISSECTOR          ProgType = 22
+	BPF_PROG_TYPE_CGROUP_SYSCTL           ProgType = 23
+	BPF_PROG_TYPE_RAW_TRACEPOINT_WRITABLE ProgType = 24
+	BPF_PROG_TYPE_CGROUP_SOCKOPT          ProgType = 25
+	BPF_PROG_TYPE_TRACING                 ProgType = 26
+	BPF_PROG_TYPE_STRUCT_OPS              ProgType = 27
+	BPF_PROG_TYPE_EXT                     ProgType = 28
+	BPF_PROG_TYPE_LSM                     ProgType = 29
+	BPF_PROG_TYPE_SK_LOOKUP               ProgType = 30
+	BPF_PROG_TYPE_SYSCALL                 ProgType = 31
+	BPF_PROG_TYPE_NETFILTER               ProgType = 32
+)
+
+// AttachType mirrors enum bpf_attach_type.
+type AttachType uint32
+
+// Values of enum bpf_attach_type.
+const (
+	BPF_CGROUP_INET_INGRESS            AttachType = 0
+	BPF_CGROUP_INET_EGRESS             AttachType = 1
+	BPF_CGROUP_INET_SOCK_CREATE        AttachType = 2
+	BPF_CGROUP_SOCK_OPS                AttachType = 3
+	BPF_SK_SKB_STREAM_PARSER           AttachType = 4
+	BPF_SK_SKB_STREAM_VERDICT          AttachType = 5
+	BPF_CGROUP_DEVICE                  AttachType = 6
+	BPF_SK_MSG_VERDICT                 AttachType = 7
+	BPF_CGROUP_INET4_BIND              AttachType = 8
+	BPF_CGROUP_INET6_BIND              AttachType = 9
+	BPF_CGROUP_INET4_CONNECT           AttachType = 10
+	BPF_CGROUP_INET6_CONNECT           AttachType = 11
+	BPF_CGROUP_INET4_POST_BIND         AttachType = 12
+	BPF_CGROUP_INET6_POST_BIND         AttachType = 13
+	BPF_CGROUP_UDP4_SENDMSG            AttachType = 14
+	BPF_CGROUP_UDP6_SENDMSG            AttachType = 15
+	BPF_LIRC_MODE2                     AttachType = 16
+	BPF_FLOW_DISSECTOR                 AttachType = 17
+	BPF_CGROUP_SYSCTL                  AttachType = 18
+	BPF_CGROUP_UDP4_RECVMSG            AttachType = 19
+	BPF_CGROUP_UDP6_RECVMSG            AttachType = 20
+	BPF_CGROUP_GETSOCKOPT              AttachType = 21
+	BPF_CGROUP_SETSOCKOPT              AttachType = 22
+	BPF_TRACE_RAW_TP                   AttachType = 23
+	BPF_TRACE_FENTRY                   AttachType = 24
+	BPF_TRACE_FEXIT                    AttachType = 25
+	BPF_MODIFY_RETURN                  AttachType = 26
+	BPF_LSM_MAC                        AttachType = 27
+	BPF_TRACE_ITER                     AttachType = 28
+	BPF_CGROUP_INET4_GETPEERNAME       AttachType = 29
+	BPF_CGROUP_INET6_GETPEERNAME       AttachType = 30
+	BPF_CGROUP_INET4_GETSOCKNAME       AttachType = 31
+	BPF_CGROUP_INET6_GETSOCKNAME       AttachType = 32
+	BPF_XDP_DEVMAP                     AttachType = 33
+	BPF_CGROUP_INET_SOCK_RELEASE       AttachType = 34
+	BPF_XDP_CPUMAP                     AttachType = 35
+	BPF_SK_LOOKUP                      AttachType = 36
+	BPF_XDP                            AttachType = 37
+	BPF_SK_SKB_VERDICT                 AttachType = 38
+	BPF_SK_REUSEPORT_SELECT            AttachType = 39
+	BPF_SK_REUSEPORT_SELECT_OR_MIGRATE AttachType = 40
+	BPF_PERF_EVENT                     AttachType = 41
+	BPF_TRACE_KPROBE_MULTI             AttachType = 42
+	BPF_LSM_CGROUP                     AttachType = 43
+	BPF_STRUCT_OPS                     AttachType = 44
+	BPF_NETFILTER                      AttachType = 45
+	BPF_TCX_INGRESS                    AttachType = 46
+	BPF_TCX_EGRESS                     AttachType = 47
+	BPF_TRACE_UPROBE_MULTI             AttachType = 48
+	BPF_CGROUP_UNIX_CONNECT            AttachType = 49
+	BPF_CGROUP_UNIX_SENDMSG            AttachType = 50
+	BPF_CGROUP_UNIX_RECVMSG            AttachType = 51
+	BPF_CGROUP_UNIX_GETPEERNAME        AttachType = 52
+	BPF_CGROUP_UNIX_GETSOCKNAME        AttachType = 53
+	BPF_NETKIT_PRIMARY                 AttachType = 54
+	BPF_NETKIT_PEER                    AttachType = 55
+	BPF_TRACE_KPROBE_SESSION           AttachType = 56
+	BPF_TRACE_UPROBE_SESSION           AttachType = 57
+)
+
+// LinkType mirrors enum bpf_link_type.
+type LinkType uint32
+
+// Values of enum bpf_link_type.
+const (
+	BPF_LINK_TYPE_UNSPEC         LinkType = 0
+	BPF_LINK_TYPE_RAW_TRACEPOINT LinkType = 1
+	BPF_LINK_TYPE_TRACING        LinkType = 2
+	BPF_LINK_TYPE_CGROUP         LinkType = 3
+	BPF_LINK_TYPE_ITER           LinkType = 4
+	BPF_LINK_TYPE_NETNS          LinkType = 5
+	BPF_LINK_TYPE_XDP            LinkType = 6
+	BPF_LINK_TYPE_PERF_EVENT     LinkType = 7
+	BPF_LINK_TYPE_KPROBE_MULTI   LinkType = 8
+	BPF_LINK_TYPE_STRUCT_OPS     LinkType = 9
+	BPF_LINK_TYPE_NETFILTER      LinkType = 10
+	BPF_LINK_TYPE_TCX            LinkType = 11
+	BPF_LINK_TYPE_UPROBE_MULTI   LinkType = 12
+	BPF_LINK_TYPE_NETKIT         LinkType = 13
+	BPF_LINK_TYPE_SOCKMAP        LinkType = 14
+)
+
+// MapCreateAttr is used by BPF_MAP_CREATE.
+type MapCreateAttr struct {
+	MapType               MapType
+	KeySize               uint32
+	ValueSize             uint32
+	MaxEntries            uint32
+	MapFlags              uint32
+	InnerMapFD            uint32
+	NumaNode              uint32
+	MapName               [16]byte
+	MapIfindex            uint32
+	BTFFD                 uint32
+	BTFKeyTypeID          uint32
+	BTFValueTypeID        uint32
+	BTFVmlinuxValueTypeID uint32
+	MapExtra              uint64
+	ValueTypeBTFObjFD     int32
+	MapTokenFD            int32
+	ExclProgHash          Pointer
+	ExclProgHashSize      uint32
+	_                     [4]byte
+}
+
+// MapElemAttr is used by BPF_MAP_LOOKUP_ELEM, BPF_MAP_UPDATE_ELEM, BPF_MAP_DELETE_ELEM, BPF_MAP_GET_NEXT_KEY, BPF_MAP_LOOKUP_AND_DELETE_ELEM, BPF_MAP_FREEZE.
+type MapElemAttr struct {
+	MapFD uint32
+	_     [4]byte
+	Key   Pointer
+	Value Pointer
+	Flags uint64
+}
+
+// MapBatchAttr is used by BPF_MAP_LOOKUP_BATCH, BPF_MAP_LOOKUP_AND_DELETE_BATCH, BPF_MAP_UPDATE_BATCH, BPF_MAP_DELETE_BATCH.
+type MapBatchAttr struct {
+	InBatch   Pointer
+	OutBatch  Pointer
+	Keys      Pointer
+	Values    Pointer
+	Count     uint32
+	MapFD     uint32
+	ElemFlags uint64
+	Flags     uint64
+}
+
+// ProgLoadAttr is used by BPF_PROG_LOAD.
+type ProgLoadAttr struct {
+	ProgType           ProgType
+	InsnCnt            uint32
+	Insns              Pointer
+	License            Pointer
+	LogLevel           uint32
+	LogSize            uint32
+	LogBuf             Pointer
+	KernVersion        uint32
+	ProgFlags          uint32
+	ProgName           [16]byte
+	ProgIfindex        uint32
+	ExpectedAttachType AttachType
+	ProgBTFFD          uint32
+	FuncInfoRecSize    uint32
+	FuncInfo           Pointer
+	FuncInfoCnt        uint32
+	LineInfoRecSize    uint32
+	LineInfo           Pointer
+	LineInfoCnt        uint32
+	AttachBTFID        uint32
+	AttachProgFD       uint32
+	CoreReloCnt        uint32
+	FDArray            Pointer
+	CoreRelos          Pointer
+	CoreReloRecSize    uint32
+	LogTrueSize        uint32
+	ProgTokenFD        int32
+	FDArrayCnt         uint32
+	Signature          Pointer
+	SignatureSize      uint32
+	KeyringID          int32
+}
+
+// ObjAttr is used by BPF_OBJ_PIN, BPF_OBJ_GET.
+type ObjAttr struct {
+	Pathname  Pointer
+	BPFFD     uint32
+	FileFlags uint32
+	PathFD    int32
+	_         [4]byte
+}
+
+// ProgAttachAttr is used by BPF_PROG_ATTACH, BPF_PROG_DETACH.
+type ProgAttachAttr struct {
+	TargetFD         uint32
+	AttachBPFFD      uint32
+	AttachType       AttachType
+	AttachFlags      uint32
+	ReplaceBPFFD     uint32
+	RelativeFD       uint32
+	ExpectedRevision uint64
+}
+
+// ProgRunAttr is used by BPF_PROG_TEST_RUN.
+type ProgRunAttr struct {
+	ProgFD      uint32
+	Retval      uint32
+	DataSizeIn  uint32
+	DataSizeOut uint32
+	DataIn      Pointer
+	DataOut     Pointer
+	Repeat      uint32
+	Duration    uint32
+	CtxSizeIn   uint32
+	CtxSizeOut  uint32
+	CtxIn       Pointer
+	CtxOut      Pointer
+	Flags       uint32
+	CPU         uint32
+	BatchSize   uint32
+	_           [4]byte
+}
+
+// GetIDAttr is used by BPF_PROG_GET_NEXT_ID, BPF_MAP_GET_NEXT_ID, BPF_BTF_GET_NEXT_ID, BPF_LINK_GET_NEXT_ID, BPF_PROG_GET_FD_BY_ID, BPF_MAP_GET_FD_BY_ID, BPF_BTF_GET_FD_BY_ID, BPF_LINK_GET_FD_BY_ID.
+type GetIDAttr struct {
+	StartID       uint32
+	NextID        uint32
+	OpenFlags     uint32
+	FDByIDTokenFD int32
+}
+
+// ObjGetInfoByFDAttr is used by BPF_OBJ_GET_INFO_BY_FD.
+type ObjGetInfoByFDAttr struct {
+	BPFFD   uint32
+	InfoLen uint32
+	Info    Pointer
+}
+
+// ProgQueryAttr is used by BPF_PROG_QUERY.
+type ProgQueryAttr struct {
+	TargetFD        uint32
+	AttachType      AttachType
+	QueryFlags      uint32
+	AttachFlags     uint32
+	ProgIDs         Pointer
+	ProgCnt         uint32
+	_               [4]byte
+	ProgAttachFlags Pointer
+	LinkIDs         Pointer
+	LinkAttachFlags Pointer
+	Revision        uint64
+}
+
+// RawTracepointOpenAttr is used by BPF_RAW_TRACEPOINT_OPEN.
+type RawTracepointOpenAttr struct {
+	Name   Pointer
+	ProgFD uint32
+	_      [4]byte
+	Cookie uint64
+}
+
+// BTFLoadAttr is used by BPF_BTF_LOAD.
+type BTFLoadAttr struct {
+	BTF            Pointer
+	BTFLogBuf      Pointer
+	BTFSize        uint32
+	BTFLogSize     uint32
+	BTFLogLevel    uint32
+	BTFLogTrueSize uint32
+	BTFFlags       uint32
+	BTFTokenFD     int32
+}
+
+// TaskFDQueryAttr is used by BPF_TASK_FD_QUERY.
+type TaskFDQueryAttr struct {
+	PID         uint32
+	FD          uint32
+	Flags       uint32
+	BufLen      uint32
+	Buf         Pointer
+	ProgID      uint32
+	FDType      uint32
+	ProbeOffset uint64
+	ProbeAddr   uint64
+}
+
+// LinkCreateAttr is used by BPF_LINK_CREATE.
+type LinkCreateAttr struct {
+	ProgFD      uint32
+	TargetFD    uint32
+	AttachType  AttachType
+	Flags       uint32
+	TargetBTFID uint32
+	_           [44]byte
+}
+
+// LinkCreateIterAttr is used by BPF_LINK_CREATE.
+type LinkCreateIterAttr struct {
+	ProgFD      uint32
+	TargetFD    uint32
+	AttachType  AttachType
+	Flags       uint32
+	IterInfo    Pointer
+	IterInfoLen uint32
+	_           [36]byte
+}
+
+// LinkUpdateAttr is used by BPF_LINK_UPDATE.
+type LinkUpdateAttr struct {
+	LinkFD    uint32
+	NewProgFD uint32
+	Flags     uint32
+	OldProgFD uint32
+}
+
+// LinkDetachAttr is used by BPF_LINK_DETACH.
+type LinkDetachAttr struct {
+	LinkFD uint32
+}
+
+// EnableStatsAttr is used by BPF_ENABLE_STATS.
+type EnableStatsAttr struct {
+	Type uint32
+}
+
+// IterCreateAttr is used by BPF_ITER_CREATE.
+type IterCreateAttr struct {
+	LinkFD uint32
+	Flags  uint32
+}
+
+// ProgBindMapAttr is used by BPF_PROG_BIND_MAP.
+type ProgBindMapAttr struct {
+	ProgFD uint32
+	MapFD  uint32
+	Flags  uint32
+}
+
+// ProgInfo mirrors struct bpf_prog_info.
+type ProgInfo struct {
+	Type                 uint32
+	ID                   uint32
+	Tag                  [8]byte
+	JITedProgLen         uint32
+	XlatedProgLen        uint32
+	JITedProgInsns       Pointer
+	XlatedProgInsns      Pointer
+	LoadTime             uint64
+	CreatedByUID         uint32
+	NrMapIDs             uint32
+	MapIDs               Pointer
+	Name                 [16]byte
+	Ifindex              uint32
+	_                    [4]byte
+	NetnsDev             uint64
+	NetnsIno             uint64
+	NrJITedKsyms         uint32
+	NrJITedFuncLens      uint32
+	JITedKsyms           Pointer
+	JITedFuncLens        Pointer
+	BTFID                uint32
+	FuncInfoRecSize      uint32
+	FuncInfo             Pointer
+	NrFuncInfo           uint32
+	NrLineInfo           uint32
+	LineInfo             Pointer
+	JITedLineInfo        Pointer
+	NrJITedLineInfo      uint32
+	LineInfoRecSize      uint32
+	JITedLineInfoRecSize uint32
+	NrProgTags           uint32
+	ProgTags             Pointer
+	RunTimeNS            uint64
+	RunCnt               uint64
+	RecursionMisses      uint64
+	VerifiedInsns        uint32
+	AttachBTFObjID       uint32
+	AttachBTFID          uint32
+	_                    [4]byte
+}
+
+// MapInfo mirrors struct bpf_map_info.
+type MapInfo struct {
+	Type                  uint32
+	ID                    uint32
+	KeySize               uint32
+	ValueSize             uint32
+	MaxEntries            uint32
+	MapFlags              uint32
+	Name                  [16]byte
+	Ifindex               uint32
+	BTFVmlinuxValueTypeID uint32
+	NetnsDev              uint64
+	NetnsIno              uint64
+	BTFID                 uint32
+	BTFKeyTypeID          uint32
+	BTFValueTypeID        uint32
+	BTFVmlinuxID          uint32
+	MapExtra              uint64
+	Hash                  Pointer
+	HashSize              uint32
+	_                     [4]byte
+}
+
+// BTFInfo mirrors struct bpf_btf_info.
+type BTFInfo struct {
+	BTF       Pointer
+	BTFSize   uint32
+	ID        uint32
+	Name      Pointer
+	NameLen   uint32
+	KernelBTF uint32
+}
+
+// LinkInfo mirrors struct bpf_link_info.
+type LinkInfo struct {
+	Type   uint32
+	ID     uint32
+	ProgID uint32
+	_      [52]byte
+}
+
+// MapCreate wraps BPF_MAP_CREATE.
+func MapCreate(attr *MapCreateAttr) (*FD, error) {
+	fd, err := BPF(BPF_MAP_CREATE, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	if err != nil {
+		return nil, err
+	}
+	return NewFD(uint32(fd)), nil
+}
+
+// MapLookupElem wraps BPF_MAP_LOOKUP_ELEM.
+func MapLookupElem(attr *MapElemAttr) error {
+	_, err := BPF(BPF_MAP_LOOKUP_ELEM, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// MapUpdateElem wraps BPF_MAP_UPDATE_ELEM.
+func MapUpdateElem(attr *MapElemAttr) error {
+	_, err := BPF(BPF_MAP_UPDATE_ELEM, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// MapDeleteElem wraps BPF_MAP_DELETE_ELEM.
+func MapDeleteElem(attr *MapElemAttr) error {
+	_, err := BPF(BPF_MAP_DELETE_ELEM, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// MapGetNextKey wraps BPF_MAP_GET_NEXT_KEY.
+func MapGetNextKey(attr *MapElemAttr) error {
+	_, err := BPF(BPF_MAP_GET_NEXT_KEY, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// MapLookupAndDeleteElem wraps BPF_MAP_LOOKUP_AND_DELETE_ELEM.
+func MapLookupAndDeleteElem(attr *MapElemAttr) error {
+	_, err := BPF(BPF_MAP_LOOKUP_AND_DELETE_ELEM, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// MapFreeze wraps BPF_MAP_FREEZE.
+func MapFreeze(attr *MapElemAttr) error {
+	_, err := BPF(BPF_MAP_FREEZE, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// MapLookupBatch wraps BPF_MAP_LOOKUP_BATCH.
+func MapLookupBatch(attr *MapBatchAttr) error {
+	_, err := BPF(BPF_MAP_LOOKUP_BATCH, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// MapLookupAndDeleteBatch wraps BPF_MAP_LOOKUP_AND_DELETE_BATCH.
+func MapLookupAndDeleteBatch(attr *MapBatchAttr) error {
+	_, err := BPF(BPF_MAP_LOOKUP_AND_DELETE_BATCH, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// MapUpdateBatch wraps BPF_MAP_UPDATE_BATCH.
+func MapUpdateBatch(attr *MapBatchAttr) error {
+	_, err := BPF(BPF_MAP_UPDATE_BATCH, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// MapDeleteBatch wraps BPF_MAP_DELETE_BATCH.
+func MapDeleteBatch(attr *MapBatchAttr) error {
+	_, err := BPF(BPF_MAP_DELETE_BATCH, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// ProgLoad wraps BPF_PROG_LOAD.
+func ProgLoad(attr *ProgLoadAttr) (*FD, error) {
+	fd, err := BPF(BPF_PROG_LOAD, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	if err != nil {
+		return nil, err
+	}
+	return NewFD(uint32(fd)), nil
+}
+
+// ObjPin wraps BPF_OBJ_PIN.
+func ObjPin(attr *ObjAttr) error {
+	_, err := BPF(BPF_OBJ_PIN, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// ObjGet wraps BPF_OBJ_GET.
+func ObjGet(attr *ObjAttr) (*FD, error) {
+	fd, err := BPF(BPF_OBJ_GET, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	if err != nil {
+		return nil, err
+	}
+	return NewFD(uint32(fd)), nil
+}
+
+// ProgAttach wraps BPF_PROG_ATTACH.
+func ProgAttach(attr *ProgAttachAttr) error {
+	_, err := BPF(BPF_PROG_ATTACH, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// ProgDetach wraps BPF_PROG_DETACH.
+func ProgDetach(attr *ProgAttachAttr) error {
+	_, err := BPF(BPF_PROG_DETACH, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// ProgTestRun wraps BPF_PROG_TEST_RUN.
+func ProgTestRun(attr *ProgRunAttr) error {
+	_, err := BPF(BPF_PROG_TEST_RUN, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// ProgGetNextID wraps BPF_PROG_GET_NEXT_ID.
+func ProgGetNextID(attr *GetIDAttr) error {
+	_, err := BPF(BPF_PROG_GET_NEXT_ID, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// MapGetNextID wraps BPF_MAP_GET_NEXT_ID.
+func MapGetNextID(attr *GetIDAttr) error {
+	_, err := BPF(BPF_MAP_GET_NEXT_ID, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// BTFGetNextID wraps BPF_BTF_GET_NEXT_ID.
+func BTFGetNextID(attr *GetIDAttr) error {
+	_, err := BPF(BPF_BTF_GET_NEXT_ID, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// LinkGetNextID wraps BPF_LINK_GET_NEXT_ID.
+func LinkGetNextID(attr *GetIDAttr) error {
+	_, err := BPF(BPF_LINK_GET_NEXT_ID, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// ProgGetFDByID wraps BPF_PROG_GET_FD_BY_ID.
+func ProgGetFDByID(attr *GetIDAttr) (*FD, error) {
+	fd, err := BPF(BPF_PROG_GET_FD_BY_ID, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	if err != nil {
+		return nil, err
+	}
+	return NewFD(uint32(fd)), nil
+}
+
+// MapGetFDByID wraps BPF_MAP_GET_FD_BY_ID.
+func MapGetFDByID(attr *GetIDAttr) (*FD, error) {
+	fd, err := BPF(BPF_MAP_GET_FD_BY_ID, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	if err != nil {
+		return nil, err
+	}
+	return NewFD(uint32(fd)), nil
+}
+
+// BTFGetFDByID wraps BPF_BTF_GET_FD_BY_ID.
+func BTFGetFDByID(attr *GetIDAttr) (*FD, error) {
+	fd, err := BPF(BPF_BTF_GET_FD_BY_ID, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	if err != nil {
+		return nil, err
+	}
+	return NewFD(uint32(fd)), nil
+}
+
+// LinkGetFDByID wraps BPF_LINK_GET_FD_BY_ID.
+func LinkGetFDByID(attr *GetIDAttr) (*FD, error) {
+	fd, err := BPF(BPF_LINK_GET_FD_BY_ID, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	if err != nil {
+		return nil, err
+	}
+	return NewFD(uint32(fd)), nil
+}
+
+// ObjGetInfoByFD wraps BPF_OBJ_GET_INFO_BY_FD.
+func ObjGetInfoByFD(attr *ObjGetInfoByFDAttr) error {
+	_, err := BPF(BPF_OBJ_GET_INFO_BY_FD, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// ProgQuery wraps BPF_PROG_QUERY.
+func ProgQuery(attr *ProgQueryAttr) error {
+	_, err := BPF(BPF_PROG_QUERY, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// RawTracepointOpen wraps BPF_RAW_TRACEPOINT_OPEN.
+func RawTracepointOpen(attr *RawTracepointOpenAttr) (*FD, error) {
+	fd, err := BPF(BPF_RAW_TRACEPOINT_OPEN, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	if err != nil {
+		return nil, err
+	}
+	return NewFD(uint32(fd)), nil
+}
+
+// BTFLoad wraps BPF_BTF_LOAD.
+func BTFLoad(attr *BTFLoadAttr) (*FD, error) {
+	fd, err := BPF(BPF_BTF_LOAD, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	if err != nil {
+		return nil, err
+	}
+	return NewFD(uint32(fd)), nil
+}
+
+// TaskFDQuery wraps BPF_TASK_FD_QUERY.
+func TaskFDQuery(attr *TaskFDQueryAttr) error {
+	_, err := BPF(BPF_TASK_FD_QUERY, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// LinkCreate wraps BPF_LINK_CREATE.
+func LinkCreate(attr *LinkCreateAttr) (*FD, error) {
+	fd, err := BPF(BPF_LINK_CREATE, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	if err != nil {
+		return nil, err
+	}
+	return NewFD(uint32(fd)), nil
+}
+
+// LinkCreateIter wraps BPF_LINK_CREATE.
+func LinkCreateIter(attr *LinkCreateIterAttr) (*FD, error) {
+	fd, err := BPF(BPF_LINK_CREATE, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	if err != nil {
+		return nil, err
+	}
+	return NewFD(uint32(fd)), nil
+}
+
+// LinkUpdate wraps BPF_LINK_UPDATE.
+func LinkUpdate(attr *LinkUpdateAttr) error {
+	_, err := BPF(BPF_LINK_UPDATE, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// LinkDetach wraps BPF_LINK_DETACH.
+func LinkDetach(attr *LinkDetachAttr) error {
+	_, err := BPF(BPF_LINK_DETACH, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
+
+// EnableStats wraps BPF_ENABLE_STATS.
+func EnableStats(attr *EnableStatsAttr) (*FD, error) {
+	fd, err := BPF(BPF_ENABLE_STATS, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	if err != nil {
+		return nil, err
+	}
+	return NewFD(uint32(fd)), nil
+}
+
+// IterCreate wraps BPF_ITER_CREATE.
+func IterCreate(attr *IterCreateAttr) (*FD, error) {
+	fd, err := BPF(BPF_ITER_CREATE, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	if err != nil {
+		return nil, err
+	}
+	return NewFD(uint32(fd)), nil
+}
+
+// ProgBindMap wraps BPF_PROG_BIND_MAP.
+func ProgBindMap(attr *ProgBindMapAttr) error {
+	_, err := BPF(BPF_PROG_BIND_MAP, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+	return err
+}
@@ -1,21 +1,30 @@
 package ebpf
 
 import (
-	"path/filepath"
 	"unsafe"
 
 	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/btf"
 	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/sys"
 
 	"golang.org/x/xerrors"
 )
 
 // Generic errors returned by BPF syscalls.
+//
+// The errno returned by the kernel is preserved, and can be tested for
+// using errors.Is as well.
 var (
-	ErrNotExist = xerrors.New("requested object does not exit")
+	ErrNotExist = xerrors.New("requested object does not exist")
 )
 
+// VerifierError is returned when the kernel rejects a program, and
+// includes the output of the verifier.
+//
+// Use errors.As to get at the log.
+type VerifierError = internal.VerifierError
+
 // bpfObjName is a null-terminated string made up of
 // 'A-Za-z0-9_' characters.
 type bpfObjName [unix.BPF_OBJ_NAME_LEN]byte
@@ -46,29 +55,6 @@ func invalidBPFObjNameChar(char rune) bool {
 	}
 }
 
-type bpfMapCreateAttr struct {
-	mapType        MapType
-	keySize        uint32
-	valueSize      uint32
-	maxEntries     uint32
-	flags          uint32
-	innerMapFd     uint32     // since 4.12 56f668dfe00d
-	numaNode       uint32     // since 4.14 96eabe7a40aa
-	mapName        bpfObjName // since 4.15 ad5b177bd73f
-	mapIfIndex     uint32
-	btfFd          uint32
-	btfKeyTypeID   btf.TypeID
-	btfValueTypeID btf.TypeID
-}
-
-type bpfMapOpAttr struct {
-	mapFd   uint32
-	padding uint32
-	key     internal.Pointer
-	value   internal.Pointer
-	flags   uint64
-}
-
 type bpfMapInfo struct {
 	mapType    uint32
 	id         uint32
@@ -77,127 +63,72 @@ type bpfMapInfo struct {
 	maxEntries uint32
 	flags      uint32
 	mapName    bpfObjName // since 4.15 ad5b177bd73f
-}
-
-type bpfPinObjAttr struct {
-	fileName internal.Pointer
-	fd       uint32
-	padding  uint32
-}
-
-type bpfProgLoadAttr struct {
-	progType           ProgramType
-	insCount           uint32
-	instructions       internal.Pointer
-	license            internal.Pointer
-	logLevel           uint32
-	logSize            uint32
-	logBuf             internal.Pointer
-	kernelVersion      uint32     // since 4.1  2541517c32be
-	progFlags          uint32     // since 4.11 e07b98d9bffe
-	progName           bpfObjName // since 4.15 067cae47771c
-	progIfIndex        uint32     // since 4.15 1f6f4cb7ba21
-	expectedAttachType AttachType // since 4.17 5e43f899b03a
-	progBTFFd          uint32
-	funcInfoRecSize    uint32
-	funcInfo           internal.Pointer
-	funcInfoCnt        uint32
-	lineInfoRecSize    uint32
-	lineInfo           internal.Pointer
-	lineInfoCnt        uint32
+	ifindex    uint32     // since 4.16 52775b33bb50
+	_          uint32
+	netnsDev   uint64 // since 4.16 52775b33bb50
+	netnsIno   uint64 // since 4.16 52775b33bb50
+	btfID      uint32 // since 4.18 78958fca7ead
+	btfKeyID   uint32 // since 4.18 9b2cf328b2ec
+	btfValueID uint32 // since 4.18 9b2cf328b2ec
 }
 
 type bpfProgInfo struct {
-	progType     uint32
-	id           uint32
-	tag          [unix.BPF_TAG_SIZE]byte
-	jitedLen     uint32
-	xlatedLen    uint32
-	jited        internal.Pointer
-	xlated       internal.Pointer
-	loadTime     uint64 // since 4.15 cb4d2b3f03d8
-	createdByUID uint32
-	nrMapIDs     uint32
-	mapIds       internal.Pointer
-	name         bpfObjName
-}
-
-type bpfProgTestRunAttr struct {
-	fd          uint32
-	retval      uint32
-	dataSizeIn  uint32
-	dataSizeOut uint32
-	dataIn      internal.Pointer
-	dataOut     internal.Pointer
-	repeat      uint32
-	duration    uint32
-}
-
-type bpfProgAlterAttr struct {
-	targetFd    uint32
-	attachBpfFd uint32
-	attachType  uint32
-	attachFlags uint32
-}
-
-type bpfObjGetInfoByFDAttr struct {
-	fd      uint32
-	infoLen uint32
-	info    internal.Pointer // May be either bpfMapInfo or bpfProgInfo
-}
-
-type bpfGetFDByIDAttr struct {
-	id   uint32
-	next uint32
-}
-
-type bpfMapFreezeAttr struct {
-	mapFd uint32
-}
-
-type bpfObjGetNextIDAttr struct {
-	startID   uint32
-	nextID    uint32
-	openFlags uint32
-}
-
-func bpfProgLoad(attr *bpfProgLoadAttr) (*internal.FD, error) {
+	progType             uint32
+	id                   uint32
+	tag                  [unix.BPF_TAG_SIZE]byte
+	jitedLen             uint32
+	xlatedLen            uint32
+	jited                internal.Pointer
+	xlated               internal.Pointer
+	loadTime             uint64 // since 4.15 cb4d2b3f03d8
+	createdByUID         uint32
+	nrMapIDs             uint32
+	mapIds               internal.Pointer
+	name                 bpfObjName
+	ifindex              uint32
+	gplCompatible        uint32           // bit field, since 4.18 b85fab0e67b1
+	netnsDev             uint64           // since 4.16 675fc275a3a2
+	netnsIno             uint64           // since 4.16 675fc275a3a2
+	nrJitedKsyms         uint32           // since 4.18 dbecd7388476
+	nrJitedFuncLens      uint32           // since 4.18 815581c11cc2
+	jitedKsyms           internal.Pointer // since 4.18 dbecd7388476
+	jitedFuncLens        internal.Pointer // since 4.18 815581c11cc2
+	btfID                uint32           // since 5.0
+	funcInfoRecSize      uint32           // since 5.0
+	funcInfo             internal.Pointer // since 5.0
+	nrFuncInfo           uint32           // since 5.0
+	nrLineInfo           uint32           // since 5.0
+	lineInfo             internal.Pointer // since 5.0
+	jitedLineInfo        internal.Pointer // since 5.0
+	nrJitedLineInfo      uint32           // since 5.0
+	lineInfoRecSize      uint32           // since 5.0
+	jitedLineInfoRecSize uint32           // since 5.0
+	nrProgTags           uint32           // since 5.0
+	progTags             internal.Pointer // since 5.0
+	runTimeNs            uint64           // since 5.1
+	runCnt               uint64           // since 5.1
+	recursionMisses      uint64           // since 5.12
+}
+
+func progLoad(attr *sys.ProgLoadAttr) (*sys.FD, error) {
 	for {
-		fd, err := internal.BPF(_ProgLoad, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
+		fd, err := sys.ProgLoad(attr)
 		// As of ~4.20 the verifier can be interrupted by a signal,
 		// and returns EAGAIN in that case.
-		if err == unix.EAGAIN {
+		if xerrors.Is(err, unix.EAGAIN) {
 			continue
 		}
 
-		if err != nil {
-			return nil, err
-		}
-
-		return internal.NewFD(uint32(fd)), nil
+		return fd, err
 	}
 }
 
-func bpfProgAlter(cmd int, attr *bpfProgAlterAttr) error {
-	_, err := internal.BPF(cmd, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
-	return err
-}
-
-func bpfMapCreate(attr *bpfMapCreateAttr) (*internal.FD, error) {
-	fd, err := internal.BPF(_MapCreate, unsafe.Pointer(attr), unsafe.Sizeof(*attr))
-	if err != nil {
-		return nil, err
-	}
-
-	return internal.NewFD(uint32(fd)), nil
-}
-
 var haveNestedMaps = internal.FeatureTest("nested maps", "4.12", func() bool {
-	inner, err := bpfMapCreate(&bpfMapCreateAttr{
-		mapType:    Array,
-		keySize:    4,
-		valueSize:  4,
-		maxEntries: 1,
+	inner, err := sys.MapCreate(&sys.MapCreateAttr{
+		MapType:    sys.MapType(Array),
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
 	})
 	if err != nil {
 		return false
@@ -205,12 +136,12 @@ var haveNestedMaps = internal.FeatureTest("nested maps", "4.12", func() bool {
 	defer inner.Close()
 
 	innerFd, _ := inner.Value()
-	nested, err := bpfMapCreate(&bpfMapCreateAttr{
-		mapType:    ArrayOfMaps,
-		keySize:    4,
-		valueSize:  4,
-		maxEntries: 1,
-		innerMapFd: innerFd,
+	nested, err := sys.MapCreate(&sys.MapCreateAttr{
+		MapType:    sys.MapType(ArrayOfMaps),
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+		InnerMapFD: innerFd,
 	})
 	if err != nil {
 		return false
@@ -220,15 +151,53 @@ var haveNestedMaps = internal.FeatureTest("nested maps", "4.12", func() bool {
 	return true
 })
 
+var haveLocalStorage = map[MapType]func() error{
+	SkStorage:     internal.FeatureTest("socket local storage", "5.2", probeLocalStorage(SkStorage)),
+	InodeStorage:  internal.FeatureTest("inode local storage", "5.10", probeLocalStorage(InodeStorage)),
+	TaskStorage:   internal.FeatureTest("task local storage", "5.11", probeLocalStorage(TaskStorage)),
+	CgroupStorage: internal.FeatureTest("cgroup local storage", "6.2", probeLocalStorage(CgroupStorage)),
+}
+
+func probeLocalStorage(mt MapType) func() bool {
+	return func() bool {
+		spec, err := btf.LocalStorageMap(4)
+		if err != nil {
+			return false
+		}
+
+		handle, err := btf.NewHandle(btf.MapSpec(spec))
+		if err != nil {
+			return false
+		}
+		defer handle.Close()
+
+		m, err := sys.MapCreate(&sys.MapCreateAttr{
+			MapType:        sys.MapType(mt),
+			KeySize:        4,
+			ValueSize:      4,
+			MapFlags:       unix.BPF_F_NO_PREALLOC,
+			BTFFD:          uint32(handle.FD()),
+			BTFKeyTypeID:   uint32(btf.MapKey(spec).ID()),
+			BTFValueTypeID: uint32(btf.MapValue(spec).ID()),
+		})
+		if err != nil {
+			return false
+		}
+
+		_ = m.Close()
+		return true
+	}
+}
+
 var haveMapMutabilityModifiers = internal.FeatureTest("read- and write-only maps", "5.2", func() bool {
 	// This checks BPF_F_RDONLY_PROG and BPF_F_WRONLY_PROG. Since
 	// BPF_MAP_FREEZE appeared in 5.2 as well we don't do a separate check.
-	m, err := bpfMapCreate(&bpfMapCreateAttr{
-		mapType:    Array,
-		keySize:    4,
-		valueSize:  4,
-		maxEntries: 1,
-		flags:      unix.BPF_F_RDONLY_PROG,
+	m, err := sys.MapCreate(&sys.MapCreateAttr{
+		MapType:    sys.MapType(Array),
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+		MapFlags:   unix.BPF_F_RDONLY_PROG,
 	})
 	if err != nil {
 		return false
@@ -238,97 +207,168 @@ var haveMapMutabilityModifiers = internal.FeatureTest("read- and write-only maps
 })
 
 func bpfMapLookupElem(m *internal.FD, key, valueOut internal.Pointer) error {
-	fd, err := m.Value()
+	fd, err := m.Acquire()
 	if err != nil {
 		return err
 	}
+	defer m.Release()
 
-	attr := bpfMapOpAttr{
-		mapFd: fd,
-		key:   key,
-		value: valueOut,
+	attr := sys.MapElemAttr{
+		MapFD: fd,
+		Key:   key,
+		Value: valueOut,
 	}
-	_, err = internal.BPF(_MapLookupElem, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
-	return wrapMapError(err)
+	return wrapMapError(sys.MapLookupElem(&attr))
 }
 
 func bpfMapLookupAndDelete(m *internal.FD, key, valueOut internal.Pointer) error {
-	fd, err := m.Value()
+	fd, err := m.Acquire()
 	if err != nil {
 		return err
 	}
+	defer m.Release()
 
-	attr := bpfMapOpAttr{
-		mapFd: fd,
-		key:   key,
-		value: valueOut,
+	attr := sys.MapElemAttr{
+		MapFD: fd,
+		Key:   key,
+		Value: valueOut,
 	}
-	_, err = internal.BPF(_MapLookupAndDeleteElem, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
-	return wrapMapError(err)
+	return wrapMapError(sys.MapLookupAndDeleteElem(&attr))
 }
 
 func bpfMapUpdateElem(m *internal.FD, key, valueOut internal.Pointer, flags uint64) error {
-	fd, err := m.Value()
+	fd, err := m.Acquire()
 	if err != nil {
 		return err
 	}
+	defer m.Release()
 
-	attr := bpfMapOpAttr{
-		mapFd: fd,
-		key:   key,
-		value: valueOut,
-		flags: flags,
+	attr := sys.MapElemAttr{
+		MapFD: fd,
+		Key:   key,
+		Value: valueOut,
+		Flags: flags,
 	}
-	_, err = internal.BPF(_MapUpdateElem, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
-	return wrapMapError(err)
+	return wrapMapError(sys.MapUpdateElem(&attr))
 }
 
 func bpfMapDeleteElem(m *internal.FD, key internal.Pointer) error {
-	fd, err := m.Value()
+	fd, err := m.Acquire()
 	if err != nil {
 		return err
 	}
+	defer m.Release()
 
-	attr := bpfMapOpAttr{
-		mapFd: fd,
-		key:   key,
+	attr := sys.MapElemAttr{
+		MapFD: fd,
+		Key:   key,
 	}
-	_, err = internal.BPF(_MapDeleteElem, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
-	return wrapMapError(err)
+	return wrapMapError(sys.MapDeleteElem(&attr))
 }
 
 func bpfMapGetNextKey(m *internal.FD, key, nextKeyOut internal.Pointer) error {
-	fd, err := m.Value()
+	fd, err := m.Acquire()
 	if err != nil {
 		return err
 	}
+	defer m.Release()
+
+	attr := sys.MapElemAttr{
+		MapFD: fd,
+		Key:   key,
+		Value: nextKeyOut,
+	}
+	return wrapMapError(sys.MapGetNextKey(&attr))
+}
+
+// bpfMapLookupBatch wraps BPF_MAP_LOOKUP_BATCH and returns the number of
+// elements copied to keysOut and valuesOut. It returns ErrKeyNotExist
+// along with the last elements once the end of the map is reached.
+func bpfMapLookupBatch(m *internal.FD, inBatch, outBatch, keysOut, valuesOut internal.Pointer, count uint32) (uint32, error) {
+	fd, err := m.Acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer m.Release()
+
+	attr := sys.MapBatchAttr{
+		InBatch:  inBatch,
+		OutBatch: outBatch,
+		Keys:     keysOut,
+		Values:   valuesOut,
+		Count:    count,
+		MapFD:    fd,
+	}
+	err = sys.MapLookupBatch(&attr)
+	return attr.Count, wrapMapError(err)
+}
+
+// bpfMapUpdateBatch wraps BPF_MAP_UPDATE_BATCH and returns the number of
+// elements written, which is smaller than count if an error occurs.
+func bpfMapUpdateBatch(m *internal.FD, keys, values internal.Pointer, count uint32, flags uint64) (uint32, error) {
+	fd, err := m.Acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer m.Release()
+
+	attr := sys.MapBatchAttr{
+		Keys:      keys,
+		Values:    values,
+		Count:     count,
+		MapFD:     fd,
+		ElemFlags: flags,
+	}
+	err = sys.MapUpdateBatch(&attr)
+	return attr.Count, wrapMapError(err)
+}
+
+// bpfMapDeleteBatch wraps BPF_MAP_DELETE_BATCH and returns the number of
+// elements deleted, which is smaller than count if an error occurs.
+func bpfMapDeleteBatch(m *internal.FD, keys internal.Pointer, count uint32) (uint32, error) {
+	fd, err := m.Acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer m.Release()
 
-	attr := bpfMapOpAttr{
-		mapFd: fd,
-		key:   key,
-		value: nextKeyOut,
+	attr := sys.MapBatchAttr{
+		Keys:  keys,
+		Count: count,
+		MapFD: fd,
 	}
-	_, err = internal.BPF(_MapGetNextKey, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
-	return wrapMapError(err)
+	err = sys.MapDeleteBatch(&attr)
+	return attr.Count, wrapMapError(err)
 }
 
-func objGetNextID(cmd int, start uint32) (uint32, error) {
-	attr := bpfObjGetNextIDAttr{
-		startID: start,
+func objGetNextID(cmd sys.Cmd, start uint32) (uint32, error) {
+	attr := sys.GetIDAttr{
+		StartID: start,
 	}
-	_, err := internal.BPF(cmd, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
-	return attr.nextID, wrapObjError(err)
+	_, err := sys.BPF(cmd, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	return attr.NextID, wrapObjError(err)
+}
+
+// isBTFRejection returns true if err may be caused by the kernel
+// rejecting the BTF, func infos or line infos of an object.
+//
+// The kernel reports invalid BTF as EINVAL, and map types which don't
+// support BTF as ENOTSUPP. Verifier rejections are usually reported as
+// EACCES, and don't need to be retried without BTF.
+func isBTFRejection(err error) bool {
+	return xerrors.Is(err, unix.EINVAL) || xerrors.Is(err, unix.ENOTSUPP)
 }
 
 func wrapObjError(err error) error {
 	if err == nil {
 		return nil
 	}
+
 	if xerrors.Is(err, unix.ENOENT) {
-		return xerrors.Errorf("%w", ErrNotExist)
+		return internal.SyscallError(ErrNotExist, err)
 	}
 
-	return xerrors.New(err.Error())
+	return err
 }
 
 func wrapMapError(err error) error {
@@ -337,60 +377,28 @@ func wrapMapError(err error) error {
 	}
 
 	if xerrors.Is(err, unix.ENOENT) {
-		return ErrKeyNotExist
+		return internal.SyscallError(ErrKeyNotExist, err)
 	}
 
-	return xerrors.New(err.Error())
-}
-
-func bpfMapFreeze(m *internal.FD) error {
-	fd, err := m.Value()
-	if err != nil {
-		return err
+	if xerrors.Is(err, unix.EEXIST) {
+		return internal.SyscallError(ErrKeyExist, err)
 	}
 
-	attr := bpfMapFreezeAttr{
-		mapFd: fd,
+	if internal.IsNotSupported(err) {
+		return internal.SyscallError(ErrNotSupported, err)
 	}
-	_, err = internal.BPF(_MapFreeze, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+
 	return err
 }
 
-const bpfFSType = 0xcafe4a11
-
-func bpfPinObject(fileName string, fd *internal.FD) error {
-	dirName := filepath.Dir(fileName)
-	var statfs unix.Statfs_t
-	if err := unix.Statfs(dirName, &statfs); err != nil {
-		return err
-	}
-	if uint64(statfs.Type) != bpfFSType {
-		return xerrors.Errorf("%s is not on a bpf filesystem", fileName)
-	}
-
-	value, err := fd.Value()
+func bpfMapFreeze(m *internal.FD) error {
+	fd, err := m.Acquire()
 	if err != nil {
 		return err
 	}
+	defer m.Release()
 
-	_, err = internal.BPF(_ObjPin, unsafe.Pointer(&bpfPinObjAttr{
-		fileName: internal.NewStringPointer(fileName),
-		fd:       value,
-	}), 16)
-	if err != nil {
-		return xerrors.Errorf("pin object %s: %w", fileName, err)
-	}
-	return nil
-}
-
-func bpfGetObject(fileName string) (*internal.FD, error) {
-	ptr, err := internal.BPF(_ObjGet, unsafe.Pointer(&bpfPinObjAttr{
-		fileName: internal.NewStringPointer(fileName),
-	}), 16)
-	if err != nil {
-		return nil, xerrors.Errorf("get object %s: %w", fileName, err)
-	}
-	return internal.NewFD(uint32(ptr)), nil
+	return sys.MapFreeze(&sys.MapElemAttr{MapFD: fd})
 }
 
 func bpfGetObjectInfoByFD(fd *internal.FD, info unsafe.Pointer, size uintptr) error {
@@ -400,13 +408,12 @@ func bpfGetObjectInfoByFD(fd *internal.FD, info unsafe.Pointer, size uintptr) er
 	}
 
 	// available from 4.13
-	attr := bpfObjGetInfoByFDAttr{
-		fd:      value,
-		infoLen: uint32(size),
-		info:    internal.NewPointer(info),
+	attr := sys.ObjGetInfoByFDAttr{
+		BPFFD:   value,
+		InfoLen: uint32(size),
+		Info:    sys.NewPointer(info),
 	}
-	_, err = internal.BPF(_ObjGetInfoByFD, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
-	if err != nil {
+	if err := sys.ObjGetInfoByFD(&attr); err != nil {
 		return xerrors.Errorf("fd %d: %w", fd, err)
 	}
 	return nil
@@ -420,6 +427,66 @@ func bpfGetProgInfoByFD(fd *internal.FD) (*bpfProgInfo, error) {
 	return &info, nil
 }
 
+// bpfGetProgInstructionsByFD retrieves the instructions of a program
+// after they have been rewritten by the verifier.
+func bpfGetProgInstructionsByFD(fd *internal.FD) ([]byte, error) {
+	info, err := bpfGetProgInfoByFD(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.xlatedLen == 0 {
+		return nil, xerrors.New("kernel didn't return any instructions")
+	}
+
+	insns := make([]byte, info.xlatedLen)
+	info = &bpfProgInfo{
+		xlatedLen: uint32(len(insns)),
+		xlated:    internal.NewSlicePointer(insns),
+	}
+	if err := bpfGetObjectInfoByFD(fd, unsafe.Pointer(info), unsafe.Sizeof(*info)); err != nil {
+		return nil, xerrors.Errorf("can't get program instructions: %w", err)
+	}
+
+	return insns[:info.xlatedLen], nil
+}
+
+// bpfGetProgJITedImageByFD retrieves the machine code of a program,
+// and the kernel addresses and lengths of its functions.
+func bpfGetProgJITedImageByFD(fd *internal.FD) ([]byte, []uint64, []uint32, error) {
+	info, err := bpfGetProgInfoByFD(fd)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if info.jitedLen == 0 {
+		return nil, nil, nil, xerrors.Errorf("kernel didn't return a JITed image: %w", ErrNotSupported)
+	}
+
+	var (
+		image    = make([]byte, info.jitedLen)
+		ksyms    = make([]uint64, info.nrJitedKsyms)
+		funcLens = make([]uint32, info.nrJitedFuncLens)
+	)
+	info = &bpfProgInfo{
+		jitedLen:        uint32(len(image)),
+		jited:           internal.NewSlicePointer(image),
+		nrJitedKsyms:    uint32(len(ksyms)),
+		nrJitedFuncLens: uint32(len(funcLens)),
+	}
+	if len(ksyms) > 0 {
+		info.jitedKsyms = internal.NewPointer(unsafe.Pointer(&ksyms[0]))
+	}
+	if len(funcLens) > 0 {
+		info.jitedFuncLens = internal.NewPointer(unsafe.Pointer(&funcLens[0]))
+	}
+	if err := bpfGetObjectInfoByFD(fd, unsafe.Pointer(info), unsafe.Sizeof(*info)); err != nil {
+		return nil, nil, nil, xerrors.Errorf("can't get JITed image: %w", err)
+	}
+
+	return image[:info.jitedLen], ksyms[:info.nrJitedKsyms], funcLens[:info.nrJitedFuncLens], nil
+}
+
 func bpfGetMapInfoByFD(fd *internal.FD) (*bpfMapInfo, error) {
 	var info bpfMapInfo
 	err := bpfGetObjectInfoByFD(fd, unsafe.Pointer(&info), unsafe.Sizeof(info))
@@ -429,16 +496,25 @@ func bpfGetMapInfoByFD(fd *internal.FD) (*bpfMapInfo, error) {
 	return &info, nil
 }
 
+var haveEnableStats = internal.FeatureTest("BPF_ENABLE_STATS", "5.8", func() bool {
+	fd, err := sys.EnableStats(&sys.EnableStatsAttr{Type: uint32(StatsRunTime)})
+	if err != nil {
+		return false
+	}
+	_ = fd.Close()
+	return true
+})
+
 var haveObjName = internal.FeatureTest("object names", "4.15", func() bool {
-	attr := bpfMapCreateAttr{
-		mapType:    Array,
-		keySize:    4,
-		valueSize:  4,
-		maxEntries: 1,
-		mapName:    newBPFObjName("feature_test"),
+	attr := sys.MapCreateAttr{
+		MapType:    sys.MapType(Array),
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+		MapName:    newBPFObjName("feature_test"),
 	}
 
-	fd, err := bpfMapCreate(&attr)
+	fd, err := sys.MapCreate(&attr)
 	if err != nil {
 		return false
 	}
@@ -452,15 +528,15 @@ var objNameAllowsDot = internal.FeatureTest("dot in object names", "5.2", func()
 		return false
 	}
 
-	attr := bpfMapCreateAttr{
-		mapType:    Array,
-		keySize:    4,
-		valueSize:  4,
-		maxEntries: 1,
-		mapName:    newBPFObjName(".test"),
+	attr := sys.MapCreateAttr{
+		MapType:    sys.MapType(Array),
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+		MapName:    newBPFObjName(".test"),
 	}
 
-	fd, err := bpfMapCreate(&attr)
+	fd, err := sys.MapCreate(&attr)
 	if err != nil {
 		return false
 	}
@@ -469,10 +545,13 @@ var objNameAllowsDot = internal.FeatureTest("dot in object names", "5.2", func()
 	return true
 })
 
-func bpfObjGetFDByID(cmd int, id uint32) (*internal.FD, error) {
-	attr := bpfGetFDByIDAttr{
-		id: id,
+func bpfObjGetFDByID(cmd sys.Cmd, id uint32) (*internal.FD, error) {
+	attr := sys.GetIDAttr{
+		StartID: id,
 	}
-	ptr, err := internal.BPF(cmd, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
-	return internal.NewFD(uint32(ptr)), wrapObjError(err)
+	ptr, err := sys.BPF(cmd, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err != nil {
+		return nil, wrapObjError(err)
+	}
+	return internal.NewFD(uint32(ptr)), nil
 }
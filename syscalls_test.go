@@ -48,3 +48,11 @@ func TestHaveNestedMaps(t *testing.T) {
 func TestHaveMapMutabilityModifiers(t *testing.T) {
 	testutils.CheckFeatureTest(t, haveMapMutabilityModifiers)
 }
+
+func TestHaveLocalStorage(t *testing.T) {
+	for mt, fn := range haveLocalStorage {
+		t.Run(mt.String(), func(t *testing.T) {
+			testutils.CheckFeatureTest(t, fn)
+		})
+	}
+}
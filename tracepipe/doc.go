@@ -0,0 +1,7 @@
+// Package tracepipe reads the kernel's trace buffer.
+//
+// BPF programs can write debug messages to the trace buffer using the
+// bpf_trace_printk helper, commonly wrapped by the bpf_printk macro.
+// This package allows reading and parsing these messages without
+// resorting to "cat /sys/kernel/tracing/trace_pipe".
+package tracepipe
@@ -0,0 +1,206 @@
+package tracepipe
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf/internal"
+
+	"golang.org/x/xerrors"
+)
+
+// tracefsPaths are the locations at which tracefs is commonly mounted.
+var tracefsPaths = []string{
+	"/sys/kernel/tracing",
+	"/sys/kernel/debug/tracing",
+}
+
+// rgxEntry matches a line of trace_pipe in the default format:
+//
+//	<comm>-<pid> [(<tgid>)] [<cpu>] [<flags>] <seconds>.<micros>: <function>: <message>
+var rgxEntry = regexp.MustCompile(`^\s*(.*)-(\d+)\s+(?:\(\s*[\d-]+\)\s+)?\[(\d+)\]\s+(?:(\S{4,5})\s+)?(\d+)\.(\d+):\s+([^:\s]+):\s?(.*)$`)
+
+// Entry is a single record from the trace buffer.
+type Entry struct {
+	// Comm is the name of the task which was running when the record
+	// was written.
+	Comm string
+	PID  int
+	CPU  int
+	// Flags contains the irq-info flags, for example "d.h2.". It is
+	// empty if the irq-info option is disabled.
+	Flags string
+	// Timestamp is the time the record was written, as reported by
+	// the trace clock. For the default clock this is the time since
+	// boot.
+	Timestamp time.Duration
+	// Function is the name of the function which wrote the record,
+	// usually "bpf_trace_printk".
+	Function string
+	// Message is the text of the record, without trailing newline.
+	Message string
+}
+
+// Options control the behaviour of a Reader.
+type Options struct {
+	// Instance is the name of a tracing instance, as created in the
+	// instances directory of tracefs. The global trace buffer is used
+	// if Instance is empty.
+	Instance string
+}
+
+// Reader reads entries from trace_pipe.
+//
+// Reading from trace_pipe consumes the entries, so only a single reader
+// should be active per trace buffer.
+type Reader struct {
+	file    *os.File
+	scanner *bufio.Scanner
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewReader opens trace_pipe of the global trace buffer or of an
+// instance.
+//
+// opts may be nil.
+func NewReader(opts *Options) (*Reader, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	path, err := tracePipePath(opts.Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	// trace_pipe supports poll, which allows Close to interrupt a
+	// blocked Read.
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, xerrors.Errorf("can't open trace pipe: %w", err)
+	}
+
+	return &Reader{
+		file:    file,
+		scanner: bufio.NewScanner(file),
+	}, nil
+}
+
+func tracePipePath(instance string) (string, error) {
+	if instance != "" && (instance != filepath.Base(instance) || instance == "." || instance == "..") {
+		return "", xerrors.Errorf("invalid instance name %q", instance)
+	}
+
+	for _, tracefs := range tracefsPaths {
+		if _, err := os.Stat(filepath.Join(tracefs, "trace_pipe")); err != nil {
+			continue
+		}
+
+		if instance == "" {
+			return filepath.Join(tracefs, "trace_pipe"), nil
+		}
+
+		path := filepath.Join(tracefs, "instances", instance, "trace_pipe")
+		if _, err := os.Stat(path); err != nil {
+			return "", xerrors.Errorf("instance %s: %w", instance, err)
+		}
+		return path, nil
+	}
+
+	return "", xerrors.Errorf("can't find tracefs: %w", internal.ErrNotSupported)
+}
+
+// Read the next entry from the trace buffer.
+//
+// Blocks until an entry is available or the Reader is closed. Lines
+// which don't match the expected format, for example notes about
+// lost events, are returned as an Entry with only Message set and a
+// CPU of -1.
+func (r *Reader) Read() (*Entry, error) {
+	if !r.scanner.Scan() {
+		err := r.scanner.Err()
+		if err == nil || xerrors.Is(err, os.ErrClosed) {
+			err = ErrClosed
+		}
+		return nil, err
+	}
+
+	return ParseEntry(r.scanner.Text()), nil
+}
+
+// Entries returns a channel of entries read from the trace buffer.
+//
+// The channel is closed once the Reader is closed or reading fails,
+// Err returns the reason. Entries must only be called once, and Read
+// must not be used concurrently.
+func (r *Reader) Entries() <-chan Entry {
+	entries := make(chan Entry)
+	go func() {
+		defer close(entries)
+		for {
+			entry, err := r.Read()
+			if err != nil {
+				r.mu.Lock()
+				r.err = err
+				r.mu.Unlock()
+				return
+			}
+			entries <- *entry
+		}
+	}()
+	return entries
+}
+
+// Err returns the error which caused the channel returned by Entries
+// to be closed. It is ErrClosed if the Reader was closed.
+func (r *Reader) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close the Reader, interrupting any blocked Read.
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
+
+// ErrClosed is returned when reading from a closed Reader.
+var ErrClosed = xerrors.New("trace pipe closed")
+
+// ParseEntry parses a single line of trace_pipe.
+//
+// Lines which don't match the expected format are returned as an Entry
+// with only Message set and a CPU of -1.
+func ParseEntry(line string) *Entry {
+	match := rgxEntry.FindStringSubmatch(line)
+	if match == nil {
+		return &Entry{CPU: -1, Message: line}
+	}
+
+	pid, _ := strconv.Atoi(match[2])
+	cpu, _ := strconv.Atoi(match[3])
+	secs, _ := strconv.ParseInt(match[5], 10, 64)
+	frac, _ := strconv.ParseInt(match[6], 10, 64)
+	// The fraction has microsecond or nanosecond precision depending
+	// on the trace clock.
+	for i := len(match[6]); i < 9; i++ {
+		frac *= 10
+	}
+
+	return &Entry{
+		Comm:      match[1],
+		PID:       pid,
+		CPU:       cpu,
+		Flags:     match[4],
+		Timestamp: time.Duration(secs)*time.Second + time.Duration(frac),
+		Function:  match[7],
+		Message:   match[8],
+	}
+}
@@ -0,0 +1,87 @@
+package tracepipe
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestParseEntry(t *testing.T) {
+	for line, want := range map[string]Entry{
+		"           <...>-1234    [003] d..31 12345.678901: bpf_trace_printk: hello world": {
+			Comm: "<...>", PID: 1234, CPU: 3, Flags: "d..31",
+			Timestamp: 12345*time.Second + 678901*time.Microsecond,
+			Function:  "bpf_trace_printk", Message: "hello world",
+		},
+		"  kworker/u8:1-56 [000] ....   10.000001: 0: x: y": {
+			Comm: "kworker/u8:1", PID: 56, CPU: 0, Flags: "....",
+			Timestamp: 10*time.Second + time.Microsecond,
+			Function:  "0", Message: "x: y",
+		},
+		"my-task-7 (      7) [001] 1.5: bpf_trace_printk: ": {
+			Comm: "my-task", PID: 7, CPU: 1,
+			Timestamp: 1500 * time.Millisecond,
+			Function:  "bpf_trace_printk",
+		},
+		"CPU:1 [LOST 3 EVENTS]": {
+			CPU: -1, Message: "CPU:1 [LOST 3 EVENTS]",
+		},
+	} {
+		if have := ParseEntry(line); *have != want {
+			t.Errorf("%q:\nhave %+v\nwant %+v", line, *have, want)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	testutils.SkipIfNoTracefs(t)
+
+	rd, err := NewReader(nil)
+	testutils.SkipIfNotSupported(t, err)
+	if os.IsPermission(err) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	msg := internal.NativeEndian.Uint32([]byte("hi\n\x00"))
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type: ebpf.SocketFilter,
+		Instructions: asm.Instructions{
+			asm.StoreImm(asm.RFP, -4, int64(msg), asm.Word),
+			asm.Mov.Reg(asm.R1, asm.RFP),
+			asm.Add.Imm(asm.R1, -4),
+			asm.Mov.Imm(asm.R2, 4),
+			asm.FnTracePrintk.Call(),
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "GPL",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	if _, _, err := prog.Test(make([]byte, 14)); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.AfterFunc(5*time.Second, func() { rd.Close() })
+	defer timeout.Stop()
+
+	for entry := range rd.Entries() {
+		if entry.Message == "hi" && entry.PID > 0 {
+			return
+		}
+	}
+
+	t.Fatal("Message wasn't read:", rd.Err())
+}
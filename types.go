@@ -1,5 +1,9 @@
 package ebpf
 
+import (
+	"github.com/cilium/ebpf/internal/unix"
+)
+
 //go:generate stringer -output types_string.go -type=MapType,ProgramType
 
 // MapType indicates the type map structure
@@ -41,11 +45,9 @@ const (
 	// LRUHash - This allows you to create a small hash structure that will purge the
 	// least recently used items rather than thow an error when you run out of memory
 	LRUHash
-	// LRUCPUHash - This is NOT like PerCPUHash, this structure is shared among the CPUs,
-	// it has more to do with including the CPU id with the LRU calculation so that if a
-	// particular CPU is using a value over-and-over again, then it will be saved, but if
-	// a value is being retrieved a lot but sparsely across CPUs it is not as important, basically
-	// giving weight to CPU locality over overall usage.
+	// LRUCPUHash - Like LRUHash, but stores a value per CPU like PerCPUHash. Keys are
+	// evicted from the shared LRU list, or from a list per CPU if MapFlagNoCommonLRU is
+	// set.
 	LRUCPUHash
 	// LPMTrie - This is an implementation of Longest-Prefix-Match Trie structure. It is useful,
 	// for storing things like IP addresses which can be bit masked allowing for keys of differing
@@ -58,12 +60,15 @@ const (
 	// itself.
 	HashOfMaps
 	// DevMap - Specialized map to store references to network devices.
+	// Values are either an interface index or a DevMapValue.
 	DevMap
 	// SockMap - Specialized map to store references to sockets.
 	SockMap
 	// CPUMap - Specialized map to store references to CPUs.
+	// Values are either a queue size or a CPUMapValue.
 	CPUMap
 	// XSKMap - Specialized map for XDP programs to store references to open sockets.
+	// Values are AF_XDP socket file descriptors.
 	XSKMap
 	// SockHash - Specialized hash to store references to sockets.
 	SockHash
@@ -78,19 +83,79 @@ const (
 	// Stack - LIFO storage for BPF programs.
 	Stack
 	// SkStorage - Specialized map for local storage at SK for BPF programs.
+	// Keys are socket file descriptors when accessed from user space.
 	SkStorage
 	// DevMapHash - Hash-based indexing scheme for references to network devices.
 	DevMapHash
+	// StructOpsMap - Holds implementations of kernel structs with function pointers.
+	StructOpsMap
+	// RingBuf - Ring buffer shared with user space, across all CPUs.
+	RingBuf
+	// InodeStorage - Local storage attached to inodes. Keys are file descriptors
+	// when accessed from user space.
+	InodeStorage
+	// TaskStorage - Local storage attached to tasks. Keys are pidfds when
+	// accessed from user space.
+	TaskStorage
+	// BloomFilter - Probabilistic set membership.
+	BloomFilter
+	// UserRingbuf - Ring buffer written by user space and drained by BPF programs.
+	UserRingbuf
+	// CgroupStorage - Local storage attached to cgroups. Keys are cgroup file
+	// descriptors when accessed from user space. Unlike CGroupStorage it isn't
+	// tied to an attached program.
+	CgroupStorage
 )
 
+// isLocalStorage returns true if the Map stores values attached to
+// kernel objects, which are identified by file descriptors from user space.
+func (mt MapType) isLocalStorage() bool {
+	switch mt {
+	case SkStorage, InodeStorage, TaskStorage, CgroupStorage:
+		return true
+	}
+	return false
+}
+
+// hasFileDescriptors returns true if the Map stores references to other
+// kernel objects, which are passed as file descriptors from user space.
+func (mt MapType) hasFileDescriptors() bool {
+	switch mt {
+	case ProgramArray, PerfEventArray, CGroupArray, ArrayOfMaps, HashOfMaps,
+		SockMap, SockHash, XSKMap, ReusePortSockArray:
+		return true
+	}
+	return false
+}
+
 // hasPerCPUValue returns true if the Map stores a value per CPU.
 func (mt MapType) hasPerCPUValue() bool {
-	if mt == PerCPUHash || mt == PerCPUArray {
+	switch mt {
+	case PerCPUHash, PerCPUArray, LRUCPUHash:
 		return true
 	}
 	return false
 }
 
+// isLRU returns true if the Map evicts the least recently used keys
+// when it is full.
+func (mt MapType) isLRU() bool {
+	return mt == LRUHash || mt == LRUCPUHash
+}
+
+// Flags for MapSpec.Flags.
+const (
+	// MapFlagNoPrealloc allocates entries of hash maps when they are
+	// added, instead of when the map is created. LRU maps are always
+	// preallocated.
+	MapFlagNoPrealloc = unix.BPF_F_NO_PREALLOC
+	// MapFlagNoCommonLRU gives each CPU its own LRU list in LRUHash and
+	// LRUCPUHash maps. This avoids contention on the shared list if many
+	// CPUs add keys, for example when tracking connections, at the cost
+	// of evicting keys which are still used on other CPUs.
+	MapFlagNoCommonLRU = unix.BPF_F_NO_COMMON_LRU
+)
+
 const (
 	_MapCreate = iota
 	_MapLookupElem
@@ -116,6 +181,18 @@ const (
 	_MapLookupAndDeleteElem
 	_MapFreeze
 	_BTFGetNextID
+	_MapLookupBatch
+	_MapLookupAndDeleteBatch
+	_MapUpdateBatch
+	_MapDeleteBatch
+	_LinkCreate
+	_LinkUpdate
+	_LinkGetFDByID
+	_LinkGetNextID
+	_EnableStats
+	_IterCreate
+	_LinkDetach
+	_ProgBindMap
 )
 
 const (
@@ -183,6 +260,18 @@ const (
 	CGroupSockopt
 	// Tracing program
 	Tracing
+	// StructOps program
+	StructOps
+	// Extension program
+	Extension
+	// LSM program
+	LSM
+	// SkLookup program
+	SkLookup
+	// Syscall program
+	Syscall
+	// Netfilter program
+	Netfilter
 )
 
 // AttachType of the eBPF program, needed to differentiate allowed context accesses in
@@ -220,6 +309,29 @@ const (
 	AttachTraceRawTp
 	AttachTraceFEntry
 	AttachTraceFExit
+	AttachModifyReturn
+	AttachLSMMac
+	AttachTraceIter
+	AttachCgroupInet4GetPeername
+	AttachCgroupInet6GetPeername
+	AttachCgroupInet4GetSockname
+	AttachCgroupInet6GetSockname
+	AttachXDPDevMap
+	AttachCgroupInetSockRelease
+	AttachXDPCPUMap
+	AttachSkLookup
+	AttachXDP
+	AttachSkSKBVerdict
+	AttachSkReuseportSelect
+	AttachSkReuseportSelectOrMigrate
+	AttachPerfEvent
+	AttachTraceKprobeMulti
+	AttachLSMCgroup
+	AttachStructOps
+	AttachNetfilter
+	AttachTCXIngress
+	AttachTCXEgress
+	AttachTraceUprobeMulti
 )
 
 // AttachFlags of the eBPF program used in BPF_PROG_ATTACH command
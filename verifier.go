@@ -0,0 +1,245 @@
+package ebpf
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf/asm"
+)
+
+var (
+	// 12: (85) call bpf_map_lookup_elem#1    ; R0_w=map_value_or_null(...)
+	verifierInsnLine = regexp.MustCompile(`^(\d+): \([0-9a-f]{2}\) (.*?)(?:\s+; (.*))?$`)
+	// 12: R0_w=... or 12: frame1: R0_w=...
+	verifierStateLine = regexp.MustCompile(`^(\d+): ((?:frame\d+: )?R\d.*)$`)
+	// from 4 to 6: R0_w=...
+	verifierBranchLine = regexp.MustCompile(`^from (\d+) to (\d+):`)
+)
+
+// verifierStatsPrefixes are the lines the verifier appends after the
+// error message.
+var verifierStatsPrefixes = []string{
+	"processed ",
+	"max_states_per_insn",
+	"stack depth",
+	"verification time",
+	"peak_states",
+	"mark_precise",
+}
+
+// VerifierReport explains why the verifier rejected a program, in terms
+// of the instructions of its ProgramSpec.
+type VerifierReport struct {
+	// Message is the error reported by the verifier, for example
+	// "R1 !read_ok".
+	Message string
+	// Failed is the index of the instruction which the verifier rejected,
+	// or -1 if it is unknown.
+	Failed int
+	// Registers is the state of the registers before Failed, as logged by
+	// the verifier. It may be empty.
+	Registers string
+	// Branch describes the branch the verifier was exploring, for
+	// example "from 4 to 6". It is empty if the failure occurred on the
+	// first path through the program.
+	Branch string
+	// Path contains the indices of the instructions the verifier checked
+	// since it started exploring Branch, in order and without duplicates.
+	Path []int
+
+	insns   asm.Instructions
+	sources map[int]string
+}
+
+// ExplainVerifierLog relates the log of a failed verification to the
+// instructions of the spec. The log must have been produced by loading
+// the spec with ProgramOptions.LogLevel at least 1, which is the case
+// for the log of a VerifierError.
+//
+// Loading a spec only changes the constants of its instructions, so
+// the log of a program loaded from a CollectionSpec can be explained by
+// the ProgramSpec in the CollectionSpec.
+func (ps *ProgramSpec) ExplainVerifierLog(log string) *VerifierReport {
+	indices := make(map[int]int)
+	iter := ps.Instructions.Iterate()
+	for iter.Next() {
+		indices[int(iter.Offset)] = iter.Index
+	}
+
+	report := &VerifierReport{
+		Failed:  -1,
+		insns:   ps.Instructions,
+		sources: make(map[int]string),
+	}
+
+	var (
+		seen         = make(map[int]bool)
+		source       string
+		afterFailure []string
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(log))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if match := verifierBranchLine.FindStringSubmatch(line); match != nil {
+			report.Branch = fmt.Sprintf("from %s to %s", match[1], match[2])
+			report.Path = nil
+			seen = make(map[int]bool)
+			afterFailure = nil
+			continue
+		}
+
+		if strings.HasPrefix(line, "; ") {
+			source = strings.TrimPrefix(line, "; ")
+			continue
+		}
+
+		if match := verifierStateLine.FindStringSubmatch(line); match != nil {
+			report.Registers = match[2]
+			continue
+		}
+
+		match := verifierInsnLine.FindStringSubmatch(line)
+		if match == nil {
+			if line != "" {
+				afterFailure = append(afterFailure, line)
+			}
+			continue
+		}
+
+		offset, _ := strconv.Atoi(match[1])
+		index, ok := indices[offset]
+		if !ok {
+			// The log doesn't belong to this spec, or the kernel
+			// rewrote the program.
+			index = -1
+		}
+
+		if match[3] != "" {
+			report.Registers = match[3]
+		}
+
+		if source != "" && index >= 0 {
+			report.sources[index] = source
+			source = ""
+		}
+
+		report.Failed = index
+		afterFailure = nil
+		if index >= 0 && !seen[index] {
+			seen[index] = true
+			report.Path = append(report.Path, index)
+		}
+	}
+
+	var message []string
+outer:
+	for _, line := range afterFailure {
+		for _, prefix := range verifierStatsPrefixes {
+			if strings.HasPrefix(line, prefix) {
+				continue outer
+			}
+		}
+		message = append(message, line)
+	}
+	report.Message = strings.Join(message, "\n")
+
+	return report
+}
+
+// Function returns the name of the function containing the failed
+// instruction, or an empty string if it is unknown.
+func (vr *VerifierReport) Function() string {
+	if vr.Failed < 0 {
+		return ""
+	}
+
+	for i := vr.Failed; i >= 0; i-- {
+		if sym := vr.insns[i].Symbol; sym != "" {
+			return sym
+		}
+	}
+	return ""
+}
+
+// Source returns the source code of the failed instruction, or an empty
+// string if it is unknown.
+func (vr *VerifierReport) Source() string {
+	if vr.Failed < 0 {
+		return ""
+	}
+	return vr.source(vr.Failed)
+}
+
+func (vr *VerifierReport) source(i int) string {
+	if src := vr.insns[i].Source(); src != nil {
+		return strings.TrimSpace(src.String())
+	}
+	return vr.sources[i]
+}
+
+// String renders the report, followed by the path leading up to the
+// failure annotated with symbols and source code.
+func (vr *VerifierReport) String() string {
+	var sb strings.Builder
+
+	message := vr.Message
+	if message == "" {
+		message = "unknown error"
+	}
+	fmt.Fprintf(&sb, "verifier error: %s\n", message)
+
+	if vr.Failed < 0 {
+		return sb.String()
+	}
+
+	fmt.Fprintf(&sb, "at instruction %d", vr.Failed)
+	if fn := vr.Function(); fn != "" {
+		fmt.Fprintf(&sb, " in %s", fn)
+	}
+	sb.WriteString("\n")
+
+	if src := vr.Source(); src != "" {
+		fmt.Fprintf(&sb, "\t%s\n", src)
+	}
+
+	if vr.Registers != "" {
+		fmt.Fprintf(&sb, "registers: %s\n", vr.Registers)
+	}
+
+	if len(vr.Path) == 0 {
+		return sb.String()
+	}
+
+	sb.WriteString("\npath")
+	if vr.Branch != "" {
+		fmt.Fprintf(&sb, " %s", vr.Branch)
+	}
+	sb.WriteString(":\n")
+
+	var lastSource string
+	for _, i := range vr.Path {
+		ins := vr.insns[i]
+		if ins.Symbol != "" {
+			fmt.Fprintf(&sb, "%s:\n", ins.Symbol)
+		}
+
+		if src := vr.source(i); src != "" && src != lastSource {
+			fmt.Fprintf(&sb, "\t; %s\n", src)
+			lastSource = src
+		}
+
+		marker := "  "
+		if i == vr.Failed {
+			marker = "->"
+		}
+		fmt.Fprintf(&sb, "%s\t%d: %v\n", marker, i, ins)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,108 @@
+package ebpf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	"golang.org/x/xerrors"
+)
+
+type testSourceLine string
+
+func (tsl testSourceLine) String() string { return string(tsl) }
+
+func TestExplainVerifierLog(t *testing.T) {
+	spec := &ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.LoadImm(asm.R0, 0, asm.DWord).Sym("entry"),
+			asm.JEq.Imm(asm.R1, 0, "exit"),
+			asm.Mov.Reg(asm.R0, asm.R2).WithSource(testSourceLine("return uninit;")),
+			asm.Return().Sym("exit"),
+		},
+		License: "MIT",
+	}
+
+	_, err := NewProgram(spec)
+	testutils.SkipIfNotSupported(t, err)
+
+	var verr *VerifierError
+	if !xerrors.As(err, &verr) {
+		t.Fatal("Expected a VerifierError, got", err)
+	}
+
+	report := spec.ExplainVerifierLog(verr.Log())
+	t.Log(report)
+
+	if report.Failed != 2 {
+		t.Errorf("Expected failed instruction 2, got %d", report.Failed)
+	}
+
+	if !strings.Contains(report.Message, "R2 !read_ok") {
+		t.Errorf("Unexpected message %q", report.Message)
+	}
+
+	if fn := report.Function(); fn != "entry" {
+		t.Errorf("Expected function entry, got %q", fn)
+	}
+
+	if src := report.Source(); src != "return uninit;" {
+		t.Errorf("Unexpected source %q", src)
+	}
+
+	if str := report.String(); !strings.Contains(str, "->\t2:") {
+		t.Error("Failed instruction isn't marked:\n", str)
+	}
+}
+
+func TestExplainVerifierLogBranches(t *testing.T) {
+	spec := &ProgramSpec{
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0).Sym("prog"),
+			asm.JEq.Imm(asm.R1, 0, "out"),
+			asm.Mov.Imm(asm.R0, 1),
+			asm.Mov.Imm(asm.R0, 2).Sym("out"),
+			asm.Return(),
+		},
+	}
+
+	log := `func#0 @0
+0: R1=ctx() R10=fp0
+; return 0;
+0: (b7) r0 = 0                        ; R0_w=0
+1: (15) if r1 == 0x0 goto pc+1        ; R1=ctx()
+2: (b7) r0 = 1                        ; R0_w=1
+3: (b7) r0 = 2                        ; R0_w=2
+4: (95) exit
+from 1 to 3: R0_w=0 R1=ctx() R10=fp0
+; return 2;
+3: (b7) r0 = 2                        ; R0_w=2
+3: (b7) r0 = 2                        ; R0_w=2
+4: (95) exit
+something went wrong
+processed 7 insns (limit 1000000) max_states_per_insn 0 total_states 0 peak_states 0 mark_read 0
+`
+
+	report := spec.ExplainVerifierLog(log)
+	if report.Failed != 4 {
+		t.Errorf("Expected failed instruction 4, got %d", report.Failed)
+	}
+	if report.Message != "something went wrong" {
+		t.Errorf("Unexpected message %q", report.Message)
+	}
+	if report.Branch != "from 1 to 3" {
+		t.Errorf("Unexpected branch %q", report.Branch)
+	}
+	if len(report.Path) != 2 || report.Path[0] != 3 || report.Path[1] != 4 {
+		t.Errorf("Unexpected path %v", report.Path)
+	}
+	if src := report.source(3); src != "return 2;" {
+		t.Errorf("Unexpected source from log %q", src)
+	}
+	if report.Registers != "R0_w=2" {
+		t.Errorf("Unexpected registers %q", report.Registers)
+	}
+}
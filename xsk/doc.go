@@ -0,0 +1,15 @@
+// Package xsk allows using AF_XDP sockets.
+//
+// An XDP program redirects packets into a socket by looking it up in
+// an XSKMap. The packets are written to a Umem, a region of memory
+// shared between the kernel and user space, which is also used to
+// transmit packets.
+//
+// Ownership of frames in the Umem passes between the kernel and user
+// space via four rings:
+//
+//   - the fill ring passes free frames to the kernel, for receiving packets
+//   - the RX ring returns frames containing received packets
+//   - the TX ring passes frames containing packets to transmit to the kernel
+//   - the completion ring returns frames once they have been transmitted
+package xsk
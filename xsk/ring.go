@@ -0,0 +1,161 @@
+package xsk
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// Desc describes a packet in a Umem, see struct xdp_desc.
+type Desc struct {
+	// The address of the packet, relative to the start of the Umem.
+	Addr uint64
+	// The length of the packet in bytes.
+	Len     uint32
+	Options uint32
+}
+
+// ring is a single producer, single consumer queue shared with the kernel.
+//
+// Entries are either addresses in the Umem (fill and completion rings)
+// or descriptors (RX and TX rings).
+type ring struct {
+	mmap     []byte
+	producer *uint32
+	consumer *uint32
+	flags    *uint32
+	mask     uint32
+	addrs    []uint64
+	descs    []Desc
+}
+
+func newRing(fd int, pgoff int64, off *unix.XDPRingOffset, size int, isDesc bool) (*ring, error) {
+	entrySize := uint64(unsafe.Sizeof(uint64(0)))
+	if isDesc {
+		entrySize = uint64(unsafe.Sizeof(Desc{}))
+	}
+
+	length := int(off.Desc + uint64(size)*entrySize)
+	mmap, err := unix.Mmap(fd, pgoff, length, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		return nil, xerrors.Errorf("can't mmap ring: %v", err)
+	}
+
+	r := &ring{
+		mmap:     mmap,
+		producer: (*uint32)(unsafe.Pointer(&mmap[off.Producer])),
+		consumer: (*uint32)(unsafe.Pointer(&mmap[off.Consumer])),
+		mask:     uint32(size - 1),
+	}
+
+	// Kernels before 5.4 don't expose the ring flags.
+	if off.Flags != 0 {
+		r.flags = (*uint32)(unsafe.Pointer(&mmap[off.Flags]))
+	}
+
+	// The entries follow the producer and consumer indices.
+	if isDesc {
+		r.descs = (*[1 << 26]Desc)(unsafe.Pointer(&mmap[off.Desc]))[:size:size]
+	} else {
+		r.addrs = (*[1 << 27]uint64)(unsafe.Pointer(&mmap[off.Desc]))[:size:size]
+	}
+
+	return r, nil
+}
+
+func (r *ring) close() {
+	if r == nil || r.mmap == nil {
+		return
+	}
+
+	unix.Munmap(r.mmap)
+	r.mmap = nil
+}
+
+// free returns the index of the next entry to produce, and the
+// number of entries which can be produced.
+func (r *ring) free() (uint32, uint32) {
+	prod := atomic.LoadUint32(r.producer)
+	cons := atomic.LoadUint32(r.consumer)
+	return prod, uint32(len(r.addrs)+len(r.descs)) - (prod - cons)
+}
+
+// available returns the index of the next entry to consume, and the
+// number of entries which can be consumed.
+func (r *ring) available() (uint32, uint32) {
+	cons := atomic.LoadUint32(r.consumer)
+	prod := atomic.LoadUint32(r.producer)
+	return cons, prod - cons
+}
+
+func (r *ring) produceAddrs(addrs []uint64) int {
+	prod, n := r.free()
+	if int(n) > len(addrs) {
+		n = uint32(len(addrs))
+	}
+
+	for i := uint32(0); i < n; i++ {
+		r.addrs[(prod+i)&r.mask] = addrs[i]
+	}
+
+	atomic.StoreUint32(r.producer, prod+n)
+	return int(n)
+}
+
+func (r *ring) consumeAddrs(addrs []uint64) int {
+	cons, n := r.available()
+	if int(n) > len(addrs) {
+		n = uint32(len(addrs))
+	}
+
+	for i := uint32(0); i < n; i++ {
+		addrs[i] = r.addrs[(cons+i)&r.mask]
+	}
+
+	atomic.StoreUint32(r.consumer, cons+n)
+	return int(n)
+}
+
+func (r *ring) produceDescs(descs []Desc) int {
+	prod, n := r.free()
+	if int(n) > len(descs) {
+		n = uint32(len(descs))
+	}
+
+	for i := uint32(0); i < n; i++ {
+		r.descs[(prod+i)&r.mask] = descs[i]
+	}
+
+	atomic.StoreUint32(r.producer, prod+n)
+	return int(n)
+}
+
+func (r *ring) consumeDescs(descs []Desc) int {
+	cons, n := r.available()
+	if int(n) > len(descs) {
+		n = uint32(len(descs))
+	}
+
+	for i := uint32(0); i < n; i++ {
+		descs[i] = r.descs[(cons+i)&r.mask]
+	}
+
+	atomic.StoreUint32(r.consumer, cons+n)
+	return int(n)
+}
+
+// needsWakeup returns true if the kernel has to be kicked via a syscall
+// to process the ring.
+func (r *ring) needsWakeup() bool {
+	if r.flags == nil {
+		return true
+	}
+	return atomic.LoadUint32(r.flags)&unix.XDP_RING_NEED_WAKEUP != 0
+}
+
+func isPowerOfTwo(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
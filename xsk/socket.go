@@ -0,0 +1,265 @@
+package xsk
+
+import (
+	"math"
+	"time"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+// BindMode determines how packets are copied between the driver and a Umem.
+type BindMode int
+
+const (
+	// AnyMode uses zero-copy mode if the driver supports it, and falls
+	// back to copy mode otherwise.
+	AnyMode BindMode = iota
+	// CopyMode always copies packets.
+	CopyMode
+	// ZeroCopyMode fails if the driver doesn't support zero-copy mode.
+	ZeroCopyMode
+)
+
+// SocketOptions control the behaviour of a Socket.
+type SocketOptions struct {
+	// The number of entries in the RX and TX rings. Must be a power of two.
+	RxRingSize int
+	TxRingSize int
+	// How packets are copied between driver and Umem.
+	Mode BindMode
+}
+
+// Socket is an AF_XDP socket bound to a queue of a network interface.
+type Socket struct {
+	umem    *Umem
+	queueID int
+	rx      *ring
+	tx      *ring
+}
+
+// NewSocket binds a socket to a queue of a network interface.
+//
+// The Socket takes ownership of umem, which can't be shared with
+// other Sockets.
+func NewSocket(umem *Umem, ifindex, queueID int, opts SocketOptions) (*Socket, error) {
+	if umem.bound {
+		return nil, xerrors.New("umem is already used by another socket")
+	}
+
+	if opts.RxRingSize == 0 {
+		opts.RxRingSize = DefaultRingSize
+	}
+	if opts.TxRingSize == 0 {
+		opts.TxRingSize = DefaultRingSize
+	}
+
+	if !isPowerOfTwo(opts.RxRingSize) {
+		return nil, xerrors.Errorf("RX ring size %d is not a power of two", opts.RxRingSize)
+	}
+	if !isPowerOfTwo(opts.TxRingSize) {
+		return nil, xerrors.Errorf("TX ring size %d is not a power of two", opts.TxRingSize)
+	}
+	if ifindex <= 0 || int64(ifindex) > math.MaxUint32 {
+		return nil, xerrors.Errorf("invalid interface index %d", ifindex)
+	}
+	if queueID < 0 || int64(queueID) > math.MaxUint32 {
+		return nil, xerrors.Errorf("invalid queue %d", queueID)
+	}
+
+	var flags uint16
+	switch opts.Mode {
+	case AnyMode:
+	case CopyMode:
+		flags |= unix.XDP_COPY
+	case ZeroCopyMode:
+		flags |= unix.XDP_ZEROCOPY
+	default:
+		return nil, xerrors.Errorf("unknown bind mode %d", opts.Mode)
+	}
+
+	value, err := umem.fd.Value()
+	if err != nil {
+		return nil, err
+	}
+	fd := int(value)
+
+	if err := unix.SetsockoptInt(fd, unix.SOL_XDP, unix.XDP_RX_RING, opts.RxRingSize); err != nil {
+		return nil, xerrors.Errorf("can't set RX ring size: %v", err)
+	}
+	if err := unix.SetsockoptInt(fd, unix.SOL_XDP, unix.XDP_TX_RING, opts.TxRingSize); err != nil {
+		return nil, xerrors.Errorf("can't set TX ring size: %v", err)
+	}
+
+	s := &Socket{
+		umem:    umem,
+		queueID: queueID,
+	}
+
+	s.rx, err = newRing(fd, unix.XDP_PGOFF_RX_RING, &umem.offsets.Rx, opts.RxRingSize, true)
+	if err != nil {
+		return nil, xerrors.Errorf("RX ring: %w", err)
+	}
+
+	s.tx, err = newRing(fd, unix.XDP_PGOFF_TX_RING, &umem.offsets.Tx, opts.TxRingSize, true)
+	if err != nil {
+		s.rx.close()
+		return nil, xerrors.Errorf("TX ring: %w", err)
+	}
+
+	// Kernels without support for XDP_USE_NEED_WAKEUP reject the flag,
+	// in which case the kernel has to be woken up unconditionally.
+	sa := &unix.SockaddrXDP{
+		Flags:   flags | unix.XDP_USE_NEED_WAKEUP,
+		Ifindex: uint32(ifindex),
+		QueueID: uint32(queueID),
+	}
+	err = unix.Bind(fd, sa)
+	if xerrors.Is(err, unix.EINVAL) {
+		sa.Flags = flags
+		err = unix.Bind(fd, sa)
+	}
+	if err != nil {
+		s.rx.close()
+		s.tx.close()
+		return nil, xerrors.Errorf("can't bind to queue %d of interface %d: %w", queueID, ifindex, err)
+	}
+
+	umem.bound = true
+	return s, nil
+}
+
+// FD returns the file descriptor of the socket.
+func (s *Socket) FD() int {
+	value, err := s.umem.fd.Value()
+	if err != nil {
+		return -1
+	}
+	return int(value)
+}
+
+// QueueID returns the queue the socket is bound to.
+func (s *Socket) QueueID() int {
+	return s.queueID
+}
+
+// Umem returns the Umem of the socket.
+func (s *Socket) Umem() *Umem {
+	return s.umem
+}
+
+// Register adds the socket to an XSKMap, using the queue as the key.
+//
+// XDP programs usually redirect packets using the index of the queue
+// they were received on.
+func (s *Socket) Register(m *ebpf.Map) error {
+	if m.ABI().Type != ebpf.XSKMap {
+		return xerrors.Errorf("can't register socket in %s", m.ABI().Type)
+	}
+
+	fd := s.FD()
+	if fd < 0 {
+		return xerrors.New("socket is closed")
+	}
+
+	return m.Put(uint32(s.queueID), uint32(fd))
+}
+
+// Receive returns packets which have been received.
+//
+// Returns the number of descriptors written to descs. The frames
+// are owned by the caller until they are passed to Umem.Fill or
+// Transmit.
+func (s *Socket) Receive(descs []Desc) int {
+	return s.rx.consumeDescs(descs)
+}
+
+// Transmit passes packets to the kernel.
+//
+// Returns the number of packets passed, which is less than len(descs)
+// if the TX ring is full. Frames are returned via Umem.Complete once
+// they have been sent.
+func (s *Socket) Transmit(descs []Desc) (int, error) {
+	n := s.tx.produceDescs(descs)
+	if n == 0 || !s.tx.needsWakeup() {
+		return n, nil
+	}
+
+	_, err := unix.SendmsgN(s.FD(), nil, nil, nil, unix.MSG_DONTWAIT)
+	switch {
+	case err == nil:
+	case xerrors.Is(err, unix.EAGAIN), xerrors.Is(err, unix.ENOBUFS),
+		xerrors.Is(err, unix.EBUSY), xerrors.Is(err, unix.ENETDOWN):
+		// The kernel is busy, and will process the ring on the next wakeup.
+	default:
+		return n, xerrors.Errorf("can't wake up kernel: %w", err)
+	}
+
+	return n, nil
+}
+
+// Wait blocks until packets have been received or the timeout expires,
+// and returns the number of packets which can be received.
+//
+// A negative timeout waits indefinitely.
+func (s *Socket) Wait(timeout time.Duration) (int, error) {
+	msec := -1
+	if timeout >= 0 {
+		ms := (timeout + time.Millisecond - 1) / time.Millisecond
+		if ms > math.MaxInt32 {
+			ms = math.MaxInt32
+		}
+		msec = int(ms)
+	}
+
+	fds := []unix.PollFd{{Fd: int32(s.FD()), Events: unix.POLLIN}}
+	for {
+		_, err := unix.Poll(fds, msec)
+		if xerrors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return 0, xerrors.Errorf("can't poll socket: %w", err)
+		}
+		break
+	}
+
+	_, n := s.rx.available()
+	return int(n), nil
+}
+
+// Statistics are counters maintained by the kernel for a Socket.
+type Statistics struct {
+	// Packets dropped because there were no frames in the fill ring
+	// or the RX ring was full.
+	RxDropped uint64
+	// Descriptors in the fill ring which were invalid.
+	RxInvalidDescs uint64
+	// Descriptors in the TX ring which were invalid.
+	TxInvalidDescs uint64
+}
+
+// Stats returns the counters of the socket.
+func (s *Socket) Stats() (Statistics, error) {
+	var stats unix.XDPStatistics
+	size := uint32(unsafe.Sizeof(stats))
+	if err := unix.Getsockopt(s.FD(), unix.SOL_XDP, unix.XDP_STATISTICS, unsafe.Pointer(&stats), &size); err != nil {
+		return Statistics{}, xerrors.Errorf("can't get statistics: %w", err)
+	}
+
+	return Statistics{
+		RxDropped:      stats.Rx_dropped,
+		RxInvalidDescs: stats.Rx_invalid_descs,
+		TxInvalidDescs: stats.Tx_invalid_descs,
+	}, nil
+}
+
+// Close unbinds the socket and frees its Umem.
+func (s *Socket) Close() error {
+	s.rx.close()
+	s.tx.close()
+	return s.umem.Close()
+}
@@ -0,0 +1,151 @@
+package xsk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+func TestHaveAFXDP(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveAFXDP)
+}
+
+func mustSocket(t *testing.T) *Socket {
+	t.Helper()
+
+	testutils.SkipIfNotSupported(t, haveAFXDP())
+
+	// Interface 1 is the loopback device. The kernel releases the queue
+	// of a closed socket asynchronously, so binding may fail temporarily.
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		umem, err := NewUmem(UmemOptions{NumFrames: 16, FillRingSize: 8, CompletionRingSize: 8})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sock, err := NewSocket(umem, 1, 0, SocketOptions{RxRingSize: 8, TxRingSize: 8, Mode: CopyMode})
+		if err == nil {
+			return sock
+		}
+
+		umem.Close()
+		if !xerrors.Is(err, unix.EBUSY) || time.Since(start) > time.Second {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSocketTransmit(t *testing.T) {
+	sock := mustSocket(t)
+	defer sock.Close()
+
+	umem := sock.Umem()
+	if _, err := NewSocket(umem, 1, 0, SocketOptions{}); err == nil {
+		t.Error("Umem can be used by multiple sockets")
+	}
+
+	// An ethernet header followed by some payload.
+	packet := []byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x88, 0xb5, 'h', 'e', 'l', 'l', 'o',
+	}
+
+	addr := umem.Addr(3)
+	copy(umem.Frame(addr), packet)
+
+	desc := Desc{Addr: addr, Len: uint32(len(packet))}
+	if data := umem.Data(desc); string(data) != string(packet) {
+		t.Fatal("Data doesn't return the packet")
+	}
+
+	if n, err := sock.Transmit([]Desc{desc}); err != nil {
+		t.Fatal("Can't transmit:", err)
+	} else if n != 1 {
+		t.Fatal("Expected to transmit one packet, got", n)
+	}
+
+	completed := make([]uint64, 1)
+	for start := time.Now(); umem.Complete(completed) == 0; {
+		if time.Since(start) > time.Second {
+			t.Fatal("Timed out waiting for completion")
+		}
+
+		// Kick the kernel again in case it was busy.
+		sock.Transmit(nil)
+		time.Sleep(time.Millisecond)
+	}
+
+	if completed[0] != addr {
+		t.Errorf("Expected address %d to complete, got %d", addr, completed[0])
+	}
+
+	stats, err := sock.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TxInvalidDescs != 0 {
+		t.Error("Transmitted descriptor is invalid")
+	}
+}
+
+func TestSocketRegister(t *testing.T) {
+	sock := mustSocket(t)
+	defer sock.Close()
+
+	xsks, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.XSKMap,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer xsks.Close()
+
+	if err := sock.Register(xsks); err != nil {
+		t.Fatal("Can't register socket:", err)
+	}
+
+	hash, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hash.Close()
+
+	if err := sock.Register(hash); err == nil {
+		t.Error("Registering a socket in a hash map doesn't fail")
+	}
+}
+
+func TestSocketFill(t *testing.T) {
+	sock := mustSocket(t)
+	defer sock.Close()
+
+	umem := sock.Umem()
+	addrs := make([]uint64, umem.NumFrames())
+	for i := range addrs {
+		addrs[i] = umem.Addr(i)
+	}
+
+	if n := umem.Fill(addrs); n != 8 {
+		t.Error("Expected to fill 8 frames, got", n)
+	}
+
+	if n, err := sock.Wait(0); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Error("Received unexpected packets:", n)
+	}
+}
@@ -0,0 +1,260 @@
+package xsk
+
+import (
+	"os"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// DefaultFrameSize is used if UmemOptions.FrameSize is zero.
+	DefaultFrameSize = 4096
+	// DefaultNumFrames is used if UmemOptions.NumFrames is zero.
+	DefaultNumFrames = 4096
+	// DefaultRingSize is used if the size of a ring is zero.
+	DefaultRingSize = 2048
+)
+
+var haveAFXDP = internal.FeatureTest("AF_XDP sockets", "4.18", func() bool {
+	fd, err := unix.Socket(unix.AF_XDP, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return false
+	}
+	unix.Close(fd)
+	return true
+})
+
+// UmemOptions control the layout of a Umem.
+type UmemOptions struct {
+	// The size of a frame in bytes. Must be a power of two between
+	// 2048 and the page size.
+	FrameSize int
+	// The number of frames in the Umem.
+	NumFrames int
+	// The number of bytes reserved at the start of each frame, in
+	// addition to the XDP headroom.
+	Headroom int
+	// The number of entries in the fill and completion rings. Must be
+	// a power of two.
+	FillRingSize       int
+	CompletionRingSize int
+}
+
+// Umem is a region of memory divided into fixed-size frames, which
+// holds packets sent and received via a Socket.
+//
+// Frames are identified by their address, which is the offset of the
+// frame from the start of the Umem.
+type Umem struct {
+	// The AF_XDP socket the Umem is registered with.
+	fd         *internal.FD
+	mem        []byte
+	frameSize  int
+	headroom   int
+	fill       *ring
+	completion *ring
+	offsets    *unix.XDPMmapOffsets
+	bound      bool
+}
+
+// NewUmem allocates and registers a Umem.
+func NewUmem(opts UmemOptions) (*Umem, error) {
+	if err := haveAFXDP(); err != nil {
+		return nil, err
+	}
+
+	if opts.FrameSize == 0 {
+		opts.FrameSize = DefaultFrameSize
+	}
+	if opts.NumFrames == 0 {
+		opts.NumFrames = DefaultNumFrames
+	}
+	if opts.FillRingSize == 0 {
+		opts.FillRingSize = DefaultRingSize
+	}
+	if opts.CompletionRingSize == 0 {
+		opts.CompletionRingSize = DefaultRingSize
+	}
+
+	if !isPowerOfTwo(opts.FrameSize) || opts.FrameSize < 2048 || opts.FrameSize > os.Getpagesize() {
+		return nil, xerrors.Errorf("invalid frame size %d", opts.FrameSize)
+	}
+	if opts.NumFrames < 0 {
+		return nil, xerrors.Errorf("invalid number of frames %d", opts.NumFrames)
+	}
+	if opts.Headroom < 0 || opts.Headroom+unix.XDP_PACKET_HEADROOM >= opts.FrameSize {
+		return nil, xerrors.Errorf("headroom %d doesn't fit into frame", opts.Headroom)
+	}
+	if !isPowerOfTwo(opts.FillRingSize) {
+		return nil, xerrors.Errorf("fill ring size %d is not a power of two", opts.FillRingSize)
+	}
+	if !isPowerOfTwo(opts.CompletionRingSize) {
+		return nil, xerrors.Errorf("completion ring size %d is not a power of two", opts.CompletionRingSize)
+	}
+
+	size := opts.FrameSize * opts.NumFrames
+	mem, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS|unix.MAP_POPULATE)
+	if err != nil {
+		return nil, xerrors.Errorf("can't allocate umem: %v", err)
+	}
+
+	umem := &Umem{
+		mem:       mem,
+		frameSize: opts.FrameSize,
+		headroom:  opts.Headroom,
+	}
+
+	if err := umem.register(opts); err != nil {
+		umem.Close()
+		return nil, err
+	}
+
+	return umem, nil
+}
+
+func (u *Umem) register(opts UmemOptions) error {
+	fd, err := unix.Socket(unix.AF_XDP, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return xerrors.Errorf("can't create socket: %v", err)
+	}
+	u.fd = internal.NewFD(uint32(fd))
+
+	reg := unix.XDPUmemReg{
+		Addr:     uint64(uintptr(unsafe.Pointer(&u.mem[0]))),
+		Len:      uint64(len(u.mem)),
+		Size:     uint32(opts.FrameSize),
+		Headroom: uint32(opts.Headroom),
+	}
+	if err := unix.Setsockopt(fd, unix.SOL_XDP, unix.XDP_UMEM_REG, unsafe.Pointer(&reg), unsafe.Sizeof(reg)); err != nil {
+		return xerrors.Errorf("can't register umem: %v", err)
+	}
+
+	if err := unix.SetsockoptInt(fd, unix.SOL_XDP, unix.XDP_UMEM_FILL_RING, opts.FillRingSize); err != nil {
+		return xerrors.Errorf("can't set fill ring size: %v", err)
+	}
+	if err := unix.SetsockoptInt(fd, unix.SOL_XDP, unix.XDP_UMEM_COMPLETION_RING, opts.CompletionRingSize); err != nil {
+		return xerrors.Errorf("can't set completion ring size: %v", err)
+	}
+
+	u.offsets, err = mmapOffsets(fd)
+	if err != nil {
+		return err
+	}
+
+	u.fill, err = newRing(fd, unix.XDP_UMEM_PGOFF_FILL_RING, &u.offsets.Fr, opts.FillRingSize, false)
+	if err != nil {
+		return xerrors.Errorf("fill ring: %w", err)
+	}
+
+	u.completion, err = newRing(fd, unix.XDP_UMEM_PGOFF_COMPLETION_RING, &u.offsets.Cr, opts.CompletionRingSize, false)
+	if err != nil {
+		return xerrors.Errorf("completion ring: %w", err)
+	}
+
+	return nil
+}
+
+// FrameSize returns the size of a frame in bytes.
+func (u *Umem) FrameSize() int {
+	return u.frameSize
+}
+
+// NumFrames returns the number of frames in the Umem.
+func (u *Umem) NumFrames() int {
+	return len(u.mem) / u.frameSize
+}
+
+// Addr returns the address of the i-th frame.
+func (u *Umem) Addr(i int) uint64 {
+	return uint64(i * u.frameSize)
+}
+
+// Frame returns the remainder of the frame containing addr, starting
+// at addr.
+//
+// The slice refers to memory shared with the kernel, and must not be
+// used after passing the frame to the kernel.
+func (u *Umem) Frame(addr uint64) []byte {
+	end := (addr/uint64(u.frameSize) + 1) * uint64(u.frameSize)
+	return u.mem[addr:end:end]
+}
+
+// Data returns the packet described by desc.
+//
+// The slice refers to memory shared with the kernel, and must not be
+// used after passing the frame to the kernel.
+func (u *Umem) Data(desc Desc) []byte {
+	end := desc.Addr + uint64(desc.Len)
+	return u.mem[desc.Addr:end:end]
+}
+
+// Fill passes frames to the kernel which can be used to receive packets.
+//
+// Returns the number of frames passed, which is less than len(addrs)
+// if the fill ring is full.
+func (u *Umem) Fill(addrs []uint64) int {
+	n := u.fill.produceAddrs(addrs)
+	if n > 0 && u.bound && u.fill.needsWakeup() {
+		// Ignore errors, the kernel will process the ring on the next
+		// wakeup anyway.
+		fd, _ := u.fd.Value()
+		unix.Recvfrom(int(fd), nil, unix.MSG_DONTWAIT)
+	}
+	return n
+}
+
+// Complete returns frames which the kernel has finished transmitting.
+//
+// Returns the number of addresses written to addrs.
+func (u *Umem) Complete(addrs []uint64) int {
+	return u.completion.consumeAddrs(addrs)
+}
+
+// Close frees the Umem.
+//
+// A Umem which was passed to NewSocket is closed by the Socket.
+func (u *Umem) Close() error {
+	u.fill.close()
+	u.completion.close()
+
+	var err error
+	if u.fd != nil {
+		err = u.fd.Close()
+	}
+
+	if u.mem != nil {
+		unix.Munmap(u.mem)
+		u.mem = nil
+	}
+
+	return err
+}
+
+func mmapOffsets(fd int) (*unix.XDPMmapOffsets, error) {
+	var off unix.XDPMmapOffsets
+	size := uint32(unsafe.Sizeof(off))
+	if err := unix.Getsockopt(fd, unix.SOL_XDP, unix.XDP_MMAP_OFFSETS, unsafe.Pointer(&off), &size); err != nil {
+		return nil, xerrors.Errorf("can't get mmap offsets: %v", err)
+	}
+
+	if size == uint32(unsafe.Sizeof(off)) {
+		return &off, nil
+	}
+
+	// Kernels before 5.4 return struct xdp_mmap_offsets_v1, which
+	// lacks the flags of each ring.
+	v1 := *(*[12]uint64)(unsafe.Pointer(&off))
+	for i, ring := range []*unix.XDPRingOffset{&off.Rx, &off.Tx, &off.Fr, &off.Cr} {
+		*ring = unix.XDPRingOffset{
+			Producer: v1[i*3],
+			Consumer: v1[i*3+1],
+			Desc:     v1[i*3+2],
+		}
+	}
+
+	return &off, nil
+}